@@ -0,0 +1,264 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AdvisorOptions configure the rebalance advisor, see Advise. Fields left
+// zero take the values of DefaultAdvisorOptions.
+type AdvisorOptions struct {
+	// ImbalanceThreshold is the ratio between the part of the keyspace a
+	// member owns and its fair share, given its replica points, above which
+	// the ring is considered imbalanced.
+	ImbalanceThreshold float64
+
+	// HighLoad is the load, between 0 and 1, at which a member or a zone is
+	// considered overloaded.
+	HighLoad float64
+
+	// LoadWindow is how long a load sample gossiped by a member is used.
+	LoadWindow time.Duration
+}
+
+// DefaultAdvisorOptions are the default options of the rebalance advisor.
+var DefaultAdvisorOptions = AdvisorOptions{
+	ImbalanceThreshold: 1.25,
+	HighLoad:           0.8,
+	LoadWindow:         time.Minute,
+}
+
+// A RecommendationKind is the kind of action a Recommendation advises.
+type RecommendationKind string
+
+const (
+	// IncreaseReplicaPoints advises to increase the replica points of the
+	// ring to the Value of the recommendation.
+	IncreaseReplicaPoints RecommendationKind = "increase-replica-points"
+
+	// AdjustWeight advises to change the replica points of Member to the
+	// Value of the recommendation.
+	AdjustWeight RecommendationKind = "adjust-weight"
+
+	// AddCapacity advises to add members to Zone, or to the cluster if Zone
+	// is empty.
+	AddCapacity RecommendationKind = "add-capacity"
+)
+
+// A Recommendation is an action that would improve the balance of the ring.
+type Recommendation struct {
+	Kind   RecommendationKind `json:"kind"`
+	Member string             `json:"member,omitempty"`
+	Zone   string             `json:"zone,omitempty"`
+	Value  int                `json:"value,omitempty"`
+	Reason string             `json:"reason"`
+}
+
+// Advice is the analysis of the balance of the ring made by Advise.
+type Advice struct {
+	// Ownership is the part of the keyspace each member owns.
+	Ownership map[string]float64 `json:"ownership"`
+
+	// Imbalance is the largest ratio between the part of the keyspace a
+	// member owns and its fair share.
+	Imbalance float64 `json:"imbalance"`
+
+	// Loads are the recent load samples members gossiped with pushback.
+	Loads map[string]float64 `json:"loads"`
+
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// loadSample is the load a member gossiped and when it was received.
+type loadSample struct {
+	load     float64
+	received time.Time
+}
+
+// memberLoads tracks the load samples gossiped by members.
+type memberLoads struct {
+	samples map[string]loadSample
+	sync.Mutex
+}
+
+// recordLoad records a load sample gossiped by a member.
+func (rp *Ringpop) recordLoad(address string, load float64) {
+	rp.loads.Lock()
+	if rp.loads.samples == nil {
+		rp.loads.samples = make(map[string]loadSample)
+	}
+	rp.loads.samples[address] = loadSample{load: load, received: rp.clock.Now()}
+	rp.loads.Unlock()
+}
+
+// recentLoads returns the load samples received within the load window.
+func (rp *Ringpop) recentLoads() map[string]float64 {
+	cutoff := rp.clock.Now().Add(-rp.config.Advisor.LoadWindow)
+
+	rp.loads.Lock()
+	defer rp.loads.Unlock()
+
+	loads := make(map[string]float64)
+	for address, sample := range rp.loads.samples {
+		if sample.received.Before(cutoff) {
+			delete(rp.loads.samples, address)
+			continue
+		}
+		loads[address] = sample.load
+	}
+	return loads
+}
+
+// Advise analyzes the distribution of the keyspace over the ring, the replica
+// points of the members and the load they gossiped with pushback, see
+// SetPushback, and recommends how to improve the balance of the ring:
+//
+// - to increase the replica points of the ring when members own a part of
+// the keyspace that is far from their fair share;
+//
+// - to adjust the replica points of an overloaded member when the rest of
+// its zone is not overloaded;
+//
+// - to add capacity to a zone, or to the cluster, when it is overloaded as a
+// whole.
+//
+// Zones are taken from the annotations of the members, see ZoneAnnotation.
+// Members that did not gossip a recent load sample are assumed not to be
+// overloaded. The advice is also available through the /admin/advise
+// endpoint.
+func (rp *Ringpop) Advise() (*Advice, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+
+	opts := rp.config.Advisor
+	advice := &Advice{
+		Ownership: rp.ring.Ownership(),
+		Loads:     rp.recentLoads(),
+	}
+
+	servers := rp.ring.Servers()
+	sort.Strings(servers)
+	if len(servers) == 0 {
+		return advice, nil
+	}
+
+	points := make(map[string]int, len(servers))
+	totalPoints := 0
+	for _, server := range servers {
+		points[server] = rp.ring.ServerPoints(server)
+		totalPoints += points[server]
+	}
+
+	for _, server := range servers {
+		fair := float64(points[server]) / float64(totalPoints)
+		if imbalance := advice.Ownership[server] / fair; imbalance > advice.Imbalance {
+			advice.Imbalance = imbalance
+		}
+	}
+
+	// the balance of a rendezvous or maglev ring does not depend on replica
+	// points
+	if advice.Imbalance > opts.ImbalanceThreshold && !rp.ring.IsRendezvous() && !rp.ring.IsMaglev() {
+		// the share of a server varies by about 1/sqrt(points), so this many
+		// points keep three standard deviations within the threshold
+		needed := int(math.Ceil(9 / math.Pow(opts.ImbalanceThreshold-1, 2)))
+		if needed > rp.configHashRing.ReplicaPoints {
+			advice.Recommendations = append(advice.Recommendations, Recommendation{
+				Kind:  IncreaseReplicaPoints,
+				Value: needed,
+				Reason: fmt.Sprintf("a member owns %.2f times its fair share of the keyspace with %d replica points",
+					advice.Imbalance, rp.configHashRing.ReplicaPoints),
+			})
+		}
+	}
+
+	zones := make(map[string][]string)
+	for _, server := range servers {
+		annotations, _ := rp.Annotations(server)
+		zone := annotations[rp.config.ZoneAnnotation]
+		zones[zone] = append(zones[zone], server)
+	}
+
+	zoneNames := make([]string, 0, len(zones))
+	for zone := range zones {
+		zoneNames = append(zoneNames, zone)
+	}
+	sort.Strings(zoneNames)
+
+	var clusterLoad float64
+	clusterAdvised := false
+	for _, zone := range zoneNames {
+		var zoneLoad float64
+		for _, server := range zones[zone] {
+			zoneLoad += advice.Loads[server]
+		}
+		clusterLoad += zoneLoad
+		zoneLoad /= float64(len(zones[zone]))
+
+		if zoneLoad >= opts.HighLoad {
+			// members without a zone can only be helped by capacity added
+			// to the cluster
+			clusterAdvised = clusterAdvised || zone == ""
+			advice.Recommendations = append(advice.Recommendations, Recommendation{
+				Kind:   AddCapacity,
+				Zone:   zone,
+				Reason: fmt.Sprintf("the members of the zone have an average load of %.2f", zoneLoad),
+			})
+			continue
+		}
+
+		for _, server := range zones[zone] {
+			load := advice.Loads[server]
+			if load < opts.HighLoad {
+				continue
+			}
+			// load is assumed to be proportional to the part of the keyspace
+			// owned, which is proportional to the replica points
+			weight := int(float64(points[server]) * opts.HighLoad / load)
+			if weight < 1 {
+				weight = 1
+			}
+			advice.Recommendations = append(advice.Recommendations, Recommendation{
+				Kind:   AdjustWeight,
+				Member: server,
+				Value:  weight,
+				Reason: fmt.Sprintf("the member has a load of %.2f while its zone has an average load of %.2f",
+					load, zoneLoad),
+			})
+		}
+	}
+
+	if !clusterAdvised && clusterLoad/float64(len(servers)) >= opts.HighLoad {
+		advice.Recommendations = append(advice.Recommendations, Recommendation{
+			Kind: AddCapacity,
+			Reason: fmt.Sprintf("the members of the cluster have an average load of %.2f",
+				clusterLoad/float64(len(servers))),
+		})
+	}
+
+	return advice, nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"time"
+
+	"github.com/gl-works/ringpop-go/swim"
+)
+
+// Blacklist temporarily cuts communication with the member at address, e.g.
+// to isolate a member that corrupts the membership or attacks the cluster
+// while a fix is prepared. This instance does not ping the member, drops its
+// gossip and excludes it from lookups until ttl expires or it is removed from
+// the blacklist with Unblacklist. The ttl must be positive and at most
+// swim.MaxBlacklistTTL. Blacklisted members are counted in the
+// "blacklist.added" stat.
+func (rp *Ringpop) Blacklist(address string, ttl time.Duration) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	return rp.node.Blacklist(address, ttl)
+}
+
+// Unblacklist restores communication with a blacklisted member before its ttl
+// expires. Unblacklisting a member that is not blacklisted has no effect.
+func (rp *Ringpop) Unblacklist(address string) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	rp.node.Unblacklist(address)
+	return nil
+}
+
+// Blacklisted returns the blacklisted members and when their ttl expires.
+func (rp *Ringpop) Blacklisted() (map[string]time.Time, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+	return rp.node.BlacklistedMembers(), nil
+}
+
+// excludeBlacklisted removes a member that was blacklisted from the ring.
+func (rp *Ringpop) excludeBlacklisted(address string) {
+	rp.view.Lock()
+	defer rp.view.Unlock()
+
+	rp.ring.RemoveServer(address)
+	if rp.shadow != nil {
+		rp.shadow.ring.RemoveServer(address)
+	}
+}
+
+// restoreUnblacklisted adds a member that was removed from the blacklist back
+// to the ring, if it is a reachable member.
+func (rp *Ringpop) restoreUnblacklisted(address string) {
+	rp.view.Lock()
+	defer rp.view.Unlock()
+
+	member, ok := rp.view.members[address]
+	if !ok || (member.Status != swim.Alive && member.Status != swim.Suspect) {
+		return
+	}
+
+	rp.placeServerNoLock(member)
+	rp.ring.AddServer(address)
+	if rp.shadow != nil {
+		rp.shadow.ring.AddServer(address)
+	}
+	if member.Status == swim.Suspect {
+		rp.ring.SuspectServer(address)
+	}
+}
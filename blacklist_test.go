@@ -0,0 +1,72 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+func TestBlacklist(t *testing.T) {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()))
+	require.NoError(t, err)
+	defer rp.Destroy()
+
+	assert.Equal(t, ErrNotBootstrapped, rp.Blacklist("127.0.0.1:3002", time.Minute))
+
+	require.NoError(t, createSingleNodeCluster(rp))
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive},
+	})
+	require.True(t, rp.ring.HasServer("127.0.0.1:3002"))
+
+	require.NoError(t, rp.Blacklist("127.0.0.1:3002", time.Minute))
+	assert.False(t, rp.ring.HasServer("127.0.0.1:3002"), "expected blacklisted member to be excluded from lookups")
+	blacklisted, err := rp.Blacklisted()
+	require.NoError(t, err)
+	assert.Contains(t, blacklisted, "127.0.0.1:3002")
+
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive, Incarnation: 2},
+	})
+	assert.False(t, rp.ring.HasServer("127.0.0.1:3002"), "expected blacklisted member to stay excluded")
+
+	require.NoError(t, rp.Unblacklist("127.0.0.1:3002"))
+	assert.True(t, rp.ring.HasServer("127.0.0.1:3002"), "expected member to be restored")
+
+	// members that became unreachable while blacklisted are not restored
+	require.NoError(t, rp.Blacklist("127.0.0.1:3002", time.Minute))
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Faulty, Incarnation: 2},
+	})
+	require.NoError(t, rp.Unblacklist("127.0.0.1:3002"))
+	assert.False(t, rp.ring.HasServer("127.0.0.1:3002"))
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import "github.com/gl-works/ringpop-go/swim"
+
+// BulkUpdate applies administrative changes to several members at once, e.g.
+// to evict the members of a drained rack and relabel the members that take
+// over. The updates are validated first and either all or none are applied.
+// The ring is updated once for all of them. Applied bulk updates are counted
+// in the "membership.bulk-update" stat. See swim.Node.BulkUpdate.
+func (rp *Ringpop) BulkUpdate(updates []swim.MemberUpdate) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	_, err := rp.node.BulkUpdate(updates)
+	return err
+}
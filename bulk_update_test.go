@@ -0,0 +1,62 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"testing"
+
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkUpdateNotReady(t *testing.T) {
+	rp, ch := newListeningRingpop(t)
+	defer ch.Close()
+	defer rp.Destroy()
+
+	err := rp.BulkUpdate([]swim.MemberUpdate{{Address: "127.0.0.1:3002", Evict: true}})
+	assert.Equal(t, ErrNotBootstrapped, err)
+}
+
+func TestBulkUpdate(t *testing.T) {
+	rp1, ch1 := newListeningRingpop(t)
+	defer ch1.Close()
+	defer rp1.Destroy()
+
+	rp2, ch2 := newListeningRingpop(t)
+	defer ch2.Close()
+	defer rp2.Destroy()
+
+	address1 := ch1.PeerInfo().HostPort
+	_, err := rp1.Bootstrap(&swim.BootstrapOptions{Hosts: []string{address1}})
+	require.NoError(t, err)
+	_, err = rp2.Bootstrap(&swim.BootstrapOptions{Hosts: []string{address1}})
+	require.NoError(t, err)
+	require.True(t, rp2.ring.HasServer(address1))
+
+	// the member is shut down before it is evicted
+	rp1.Destroy()
+	updates := []swim.MemberUpdate{{Address: address1, Evict: true}}
+	require.NoError(t, rp2.BulkUpdate(updates))
+	assert.False(t, rp2.ring.HasServer(address1), "expected evicted member to be removed from the ring")
+	assert.Error(t, rp2.BulkUpdate(updates), "expected evicted member not to be evicted again")
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/uber/tchannel-go/json"
+)
+
+// defaultClusterStatsTimeout is the timeout of the requests to other members
+// when the cluster stats admin endpoint does not specify one.
+const defaultClusterStatsTimeout = time.Second
+
+// MemberReport is the view a single member has of the cluster.
+type MemberReport struct {
+	Address       string            `json:"address"`
+	Checksum      uint32            `json:"checksum"`
+	RingChecksum  uint32            `json:"ringChecksum"`
+	RingChecksums map[string]uint32 `json:"ringChecksums,omitempty"`
+	Counts        map[string]int    `json:"counts"`
+}
+
+// ClusterReport aggregates the reports of all reachable members. Members that
+// did not respond in time are listed as unreachable, so a report is still
+// returned when part of the cluster is partitioned away.
+type ClusterReport struct {
+	Source      string              `json:"source"`
+	Members     []MemberReport      `json:"members"`
+	Unreachable []string            `json:"unreachable"`
+	Checksums   map[string][]string `json:"checksums"`
+	Converged   bool                `json:"converged"`
+}
+
+// memberReport returns the view this instance has of the cluster.
+func (rp *Ringpop) memberReport() MemberReport {
+	stats := rp.node.MemberStats()
+	address, _ := rp.identity()
+
+	counts := map[string]int{
+		swim.Alive:     0,
+		swim.Suspect:   0,
+		swim.Faulty:    0,
+		swim.Leave:     0,
+		swim.Tombstone: 0,
+	}
+	for i := range stats.Members {
+		counts[stats.Members[i].Status]++
+	}
+
+	return MemberReport{
+		Address:       address,
+		Checksum:      stats.Checksum,
+		RingChecksum:  rp.ring.Checksum(),
+		RingChecksums: rp.ring.Checksums(),
+		Counts:        counts,
+	}
+}
+
+// ClusterStats asks every reachable member for its view of the cluster and
+// aggregates the responses into a single report. The cluster is converged when
+// all members responded and agree on both the membership and ring checksum.
+func (rp *Ringpop) ClusterStats(timeout time.Duration) (*ClusterReport, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+
+	local := rp.memberReport()
+	report := &ClusterReport{
+		Source:      local.Address,
+		Members:     []MemberReport{local},
+		Unreachable: []string{},
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, address := range rp.node.GetReachableMembers() {
+		if address == local.Address {
+			continue
+		}
+
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+
+			res, err := rp.requestMemberReport(address, timeout)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				rp.logger.WithFields(log.Fields{
+					"remote": address,
+					"error":  err,
+				}).Debug("ringpop member stats request failed")
+				report.Unreachable = append(report.Unreachable, address)
+				return
+			}
+			report.Members = append(report.Members, *res)
+		}(address)
+	}
+
+	wg.Wait()
+
+	report.Checksums = make(map[string][]string)
+	rings := make(map[uint32]bool)
+	for _, member := range report.Members {
+		checksum := strconv.FormatUint(uint64(member.Checksum), 10)
+		report.Checksums[checksum] = append(report.Checksums[checksum], member.Address)
+		rings[member.RingChecksum] = true
+	}
+
+	report.Converged = len(report.Unreachable) == 0 &&
+		len(report.Checksums) == 1 &&
+		len(rings) == 1
+
+	return report, nil
+}
+
+// requestMemberReport requests the view of the member at address.
+func (rp *Ringpop) requestMemberReport(address string, timeout time.Duration) (*MemberReport, error) {
+	ctx, cancel := rp.config.Mesh.NewContext(timeout, address)
+	defer cancel()
+
+	if rp.config.AdminToken != "" {
+		ctx = json.WithHeaders(ctx, map[string]string{swim.AdminTokenHeader: rp.config.AdminToken})
+	}
+
+	peer := rp.subChannel.Peers().GetOrAdd(rp.DialAddress(address))
+
+	var res MemberReport
+	err := json.CallPeer(ctx, peer, rp.subChannel.ServiceName(), "/admin/stats/member", &Arg{}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
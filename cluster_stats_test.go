@@ -0,0 +1,103 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+func newListeningRingpop(t *testing.T) (*Ringpop, *tchannel.Channel) {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	require.NoError(t, ch.ListenAndServe("127.0.0.1:0"))
+
+	rp, err := New("test", Channel(ch))
+	require.NoError(t, err)
+	return rp, ch
+}
+
+func TestClusterStatsNotReady(t *testing.T) {
+	rp, ch := newListeningRingpop(t)
+	defer ch.Close()
+	defer rp.Destroy()
+
+	_, err := rp.ClusterStats(time.Second)
+	assert.Equal(t, ErrNotBootstrapped, err)
+}
+
+func TestClusterStats(t *testing.T) {
+	rp1, ch1 := newListeningRingpop(t)
+	defer ch1.Close()
+	defer rp1.Destroy()
+
+	rp2, ch2 := newListeningRingpop(t)
+	defer ch2.Close()
+	defer rp2.Destroy()
+
+	address1 := ch1.PeerInfo().HostPort
+	_, err := rp1.Bootstrap(&swim.BootstrapOptions{Hosts: []string{address1}})
+	require.NoError(t, err)
+	_, err = rp2.Bootstrap(&swim.BootstrapOptions{Hosts: []string{address1}})
+	require.NoError(t, err)
+
+	// the first member learns about the second through gossip
+	var report *ClusterReport
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		report, err = rp1.ClusterStats(time.Second)
+		require.NoError(t, err)
+		if report.Converged && len(report.Members) == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(t, address1, report.Source)
+	assert.Len(t, report.Members, 2)
+	assert.Empty(t, report.Unreachable)
+	for _, member := range report.Members {
+		assert.Equal(t, 2, member.Counts[swim.Alive], "expected both members to be alive in %v", member.Address)
+	}
+	assert.Len(t, report.Checksums, 1)
+	assert.True(t, report.Converged, "expected cluster to be converged")
+
+	// a member that does not respond makes the report partial
+	snapshot, err := rp1.Snapshot()
+	require.NoError(t, err)
+	snapshot.Members = append(snapshot.Members, swim.Change{
+		Address:     "127.0.0.1:1",
+		Status:      swim.Alive,
+		Incarnation: 1,
+	})
+	require.NoError(t, rp1.Restore(snapshot))
+
+	report, err = rp1.ClusterStats(100 * time.Millisecond)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"127.0.0.1:1"}, report.Unreachable)
+	assert.Len(t, report.Members, 2)
+	assert.False(t, report.Converged, "expected partial report not to be converged")
+}
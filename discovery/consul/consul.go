@@ -0,0 +1,288 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package consul provides the instances of a service registered in the Consul
+// catalog, so ringpop can bootstrap from and heal with the instances that are
+// currently healthy.
+//
+// Hosts queries the Consul HTTP API, unless the provider watches the service.
+// A watching provider keeps the instances current with blocking queries in
+// the background, and Hosts returns them without a request, so the partition
+// healer has a current list of hosts even when Consul is briefly unavailable.
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Options configure the Consul provider.
+type Options struct {
+	// Address is the URL of the Consul agent, defaults to CONSUL_HTTP_ADDR
+	// or http://127.0.0.1:8500.
+	Address string
+
+	// Service is the name of the ringpop service in the catalog.
+	Service string
+
+	// Tag selects only the instances with this tag, if set.
+	Tag string
+
+	// Datacenter to query, defaults to the datacenter of the agent.
+	Datacenter string
+
+	// Token is the ACL token, defaults to CONSUL_HTTP_TOKEN.
+	Token string
+
+	// IncludeFailing includes instances that do not pass their health checks.
+	IncludeFailing bool
+
+	// WaitTime is how long a blocking query waits for the instances to
+	// change, defaults to 5 minutes.
+	WaitTime time.Duration
+
+	// Client is the HTTP client to query Consul with, defaults to
+	// http.DefaultClient. It must not time out before WaitTime.
+	Client *http.Client
+}
+
+// A Provider provides the instances of a service in the Consul catalog. It
+// implements discovery.Provider.
+type Provider struct {
+	opts Options
+
+	sync.Mutex
+	hosts  []string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a Provider for the given options.
+func New(opts Options) *Provider {
+	if opts.Address == "" {
+		opts.Address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if opts.Address == "" {
+		opts.Address = "http://127.0.0.1:8500"
+	}
+	if !strings.Contains(opts.Address, "://") {
+		opts.Address = "http://" + opts.Address
+	}
+	if opts.Token == "" {
+		opts.Token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	if opts.WaitTime == 0 {
+		opts.WaitTime = 5 * time.Minute
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	return &Provider{opts: opts}
+}
+
+// Hosts returns the instances of the service as host:port, sorted. A watching
+// provider returns the instances of its last successful query.
+func (p *Provider) Hosts() ([]string, error) {
+	p.Lock()
+	hosts := p.hosts
+	p.Unlock()
+
+	if hosts != nil {
+		return append([]string(nil), hosts...), nil
+	}
+
+	hosts, _, err := p.query(context.Background(), 0)
+	return hosts, err
+}
+
+// Watch starts watching the instances of the service in the background until
+// Stop is called. Failed queries are retried with backoff, in the meantime
+// Hosts returns the instances of the last successful query.
+func (p *Provider) Watch() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	go p.watch(ctx, p.done)
+}
+
+// Stop stops watching the instances of the service.
+func (p *Provider) Stop() {
+	p.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	p.Lock()
+	p.hosts = nil
+	p.Unlock()
+}
+
+func (p *Provider) watch(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	var index uint64
+	backoff := time.Second
+	for {
+		hosts, newIndex, err := p.query(ctx, index)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+		backoff = time.Second
+
+		p.Lock()
+		p.hosts = hosts
+		p.Unlock()
+
+		// Consul may reset the index, which must not be used to block then.
+		if newIndex < index {
+			newIndex = 0
+		}
+		index = newIndex
+	}
+}
+
+type serviceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+// query returns the instances of the service and the Consul index they are
+// at. A non-zero index blocks until the instances changed since that index,
+// or the wait time passed.
+func (p *Provider) query(ctx context.Context, index uint64) ([]string, uint64, error) {
+	params := url.Values{}
+	if !p.opts.IncludeFailing {
+		params.Set("passing", "true")
+	}
+	if p.opts.Tag != "" {
+		params.Set("tag", p.opts.Tag)
+	}
+	if p.opts.Datacenter != "" {
+		params.Set("dc", p.opts.Datacenter)
+	}
+	if index > 0 {
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", fmt.Sprintf("%ds", int(p.opts.WaitTime.Seconds())))
+	}
+
+	u := fmt.Sprintf("%s/v1/health/service/%s?%s",
+		strings.TrimSuffix(p.opts.Address, "/"), url.PathEscape(p.opts.Service), params.Encode())
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req = req.WithContext(ctx)
+	if p.opts.Token != "" {
+		req.Header.Set("X-Consul-Token", p.opts.Token)
+	}
+
+	res, err := p.opts.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return nil, 0, &APIError{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+
+	var entries []serviceEntry
+	if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+		return nil, 0, err
+	}
+
+	newIndex, _ := strconv.ParseUint(res.Header.Get("X-Consul-Index"), 10, 64)
+	return entryHosts(entries), newIndex, nil
+}
+
+// entryHosts returns the addresses of the instances, using the address of
+// the node for instances registered without an address.
+func entryHosts(entries []serviceEntry) []string {
+	hosts := make([]string, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+
+		host := net.JoinHostPort(address, strconv.Itoa(entry.Service.Port))
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// APIError is returned when Consul rejects a query, e.g. because the ACL
+// token may not read the service.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("consul: agent returned %d: %s", e.StatusCode, e.Message)
+}
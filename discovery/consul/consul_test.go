@@ -0,0 +1,127 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package consul
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	healthy = `[
+		{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "", "Port": 3000}},
+		{"Node": {"Address": "10.0.0.9"}, "Service": {"Address": "10.0.0.1", "Port": 3000}}
+	]`
+	scaledUp = `[
+		{"Node": {"Address": "10.0.0.1"}, "Service": {"Port": 3000}},
+		{"Node": {"Address": "10.0.0.2"}, "Service": {"Port": 3000}},
+		{"Node": {"Address": "10.0.0.3"}, "Service": {"Port": 3000}}
+	]`
+)
+
+func TestHosts(t *testing.T) {
+	var req *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		w.Write([]byte(healthy))
+	}))
+	defer server.Close()
+
+	p := New(Options{
+		Address:    server.URL,
+		Service:    "ringpop",
+		Tag:        "prod",
+		Datacenter: "dc1",
+		Token:      "secret",
+	})
+
+	hosts, err := p.Hosts()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:3000", "10.0.0.2:3000"}, hosts,
+		"expected the service address, or the node address if it is empty")
+
+	require.NotNil(t, req)
+	assert.Equal(t, "/v1/health/service/ringpop", req.URL.Path)
+	assert.Equal(t, "true", req.URL.Query().Get("passing"))
+	assert.Equal(t, "prod", req.URL.Query().Get("tag"))
+	assert.Equal(t, "dc1", req.URL.Query().Get("dc"))
+	assert.Equal(t, "secret", req.Header.Get("X-Consul-Token"))
+}
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := New(Options{Address: server.URL, Service: "ringpop"}).Hosts()
+	assert.Equal(t, &APIError{StatusCode: http.StatusForbidden, Message: "ACL not found"}, err)
+}
+
+func TestWatch(t *testing.T) {
+	scaleUp := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("index") {
+		case "":
+			w.Header().Set("X-Consul-Index", "1")
+			w.Write([]byte(healthy))
+		case "1":
+			select {
+			case <-scaleUp:
+			case <-r.Context().Done():
+				return
+			}
+			w.Header().Set("X-Consul-Index", "2")
+			w.Write([]byte(scaledUp))
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	p := New(Options{Address: server.URL, Service: "ringpop"})
+	p.Watch()
+	defer p.Stop()
+
+	waitForHosts(t, p, []string{"10.0.0.1:3000", "10.0.0.2:3000"})
+	close(scaleUp)
+	waitForHosts(t, p, []string{"10.0.0.1:3000", "10.0.0.2:3000", "10.0.0.3:3000"})
+}
+
+func waitForHosts(t *testing.T, p *Provider, expected []string) {
+	var hosts []string
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		p.Lock()
+		hosts = p.hosts
+		p.Unlock()
+
+		if assert.ObjectsAreEqual(expected, hosts) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("expected watched hosts %v, got %v", expected, hosts)
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package discovery provides the hosts a node bootstraps from, and that the
+// partition healer probes for members that are not part of the cluster.
+//
+// A Provider is passed to Bootstrap in swim.BootstrapOptions.DiscoverProvider.
+// It is asked for hosts when the node joins the cluster and again every time
+// the partition healer runs, so providers that read a file or DNS pick up
+// hosts that were added after the node bootstrapped. The subpackages provide
+// the pods behind Kubernetes endpoints (discovery/kubernetes), the instances
+// of a service in the Consul catalog (discovery/consul) and the hosts
+// registered under an etcd prefix (discovery/etcd).
+package discovery
+
+import "sort"
+
+// A Provider provides the addresses, as host:port, of hosts that may be
+// members of the cluster.
+type Provider interface {
+	Hosts() ([]string, error)
+}
+
+// A ProviderFunc is a function that implements Provider, to plug in custom
+// discovery.
+type ProviderFunc func() ([]string, error)
+
+// Hosts calls f.
+func (f ProviderFunc) Hosts() ([]string, error) {
+	return f()
+}
+
+// Static returns a Provider of a fixed list of hosts.
+func Static(hosts ...string) Provider {
+	return staticProvider(hosts)
+}
+
+type staticProvider []string
+
+func (p staticProvider) Hosts() ([]string, error) {
+	return []string(p), nil
+}
+
+// uniqueSorted sorts the hosts and removes duplicates, so that providers that
+// resolve hosts return them in a stable order.
+func uniqueSorted(hosts []string) []string {
+	sort.Strings(hosts)
+
+	unique := hosts[:0]
+	for i, host := range hosts {
+		if i == 0 || host != hosts[i-1] {
+			unique = append(unique, host)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package discovery
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatic(t *testing.T) {
+	hosts, err := Static("127.0.0.1:3001", "127.0.0.1:3002").Hosts()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:3001", "127.0.0.1:3002"}, hosts)
+}
+
+func TestProviderFunc(t *testing.T) {
+	var p Provider = ProviderFunc(func() ([]string, error) {
+		return nil, errors.New("no hosts")
+	})
+
+	_, err := p.Hosts()
+	assert.EqualError(t, err, "no hosts")
+}
+
+func TestUniqueSorted(t *testing.T) {
+	hosts := uniqueSorted([]string{"b", "a", "b", "c", "a"})
+	assert.Equal(t, []string{"a", "b", "c"}, hosts)
+	assert.Empty(t, uniqueSorted(nil))
+}
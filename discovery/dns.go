@@ -0,0 +1,116 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package discovery
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// A DNSProvider provides the addresses the A and AAAA records of a host name
+// resolve to, at a fixed port. The name is resolved every time hosts are
+// requested.
+type DNSProvider struct {
+	name string
+	port int
+
+	lookupHost func(host string) ([]string, error)
+}
+
+// DNS returns a DNSProvider of the addresses of name at the given port, e.g.
+// for a headless service that resolves to all instances of an application.
+func DNS(name string, port int) *DNSProvider {
+	return &DNSProvider{
+		name:       name,
+		port:       port,
+		lookupHost: net.LookupHost,
+	}
+}
+
+// Hosts resolves the name and returns its addresses with the port.
+func (p *DNSProvider) Hosts() ([]string, error) {
+	addresses, err := p.lookupHost(p.name)
+	if err != nil {
+		return nil, err
+	}
+
+	port := strconv.Itoa(p.port)
+	hosts := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		hosts = append(hosts, net.JoinHostPort(address, port))
+	}
+	return uniqueSorted(hosts), nil
+}
+
+// An SRVProvider provides the hosts listed in the SRV records of a service.
+// The targets of the records are resolved to addresses, since members are
+// identified by address. The records are looked up every time hosts are
+// requested.
+type SRVProvider struct {
+	service, proto, name string
+
+	lookupSRV  func(service, proto, name string) (string, []*net.SRV, error)
+	lookupHost func(host string) ([]string, error)
+}
+
+// SRV returns an SRVProvider of the hosts in the SRV records of the service,
+// which are looked up as described for net.LookupSRV. An empty service and
+// proto look up name directly.
+func SRV(service, proto, name string) *SRVProvider {
+	return &SRVProvider{
+		service:    service,
+		proto:      proto,
+		name:       name,
+		lookupSRV:  net.LookupSRV,
+		lookupHost: net.LookupHost,
+	}
+}
+
+// Hosts looks up the SRV records and returns the addresses of their targets
+// with the ports of the records. A target that cannot be resolved is skipped,
+// unless no target can be resolved.
+func (p *SRVProvider) Hosts() ([]string, error) {
+	_, records, err := p.lookupSRV(p.service, p.proto, p.name)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	var lookupErr error
+	for _, record := range records {
+		addresses, err := p.lookupHost(strings.TrimSuffix(record.Target, "."))
+		if err != nil {
+			lookupErr = err
+			continue
+		}
+
+		port := strconv.Itoa(int(record.Port))
+		for _, address := range addresses {
+			hosts = append(hosts, net.JoinHostPort(address, port))
+		}
+	}
+
+	if len(hosts) == 0 && lookupErr != nil {
+		return nil, lookupErr
+	}
+	return uniqueSorted(hosts), nil
+}
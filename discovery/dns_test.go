@@ -0,0 +1,76 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package discovery
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lookupHost(addresses map[string][]string) func(string) ([]string, error) {
+	return func(host string) ([]string, error) {
+		if a, ok := addresses[host]; ok {
+			return a, nil
+		}
+		return nil, errors.New("no such host")
+	}
+}
+
+func TestDNS(t *testing.T) {
+	p := DNS("ringpop.local", 3000)
+	p.lookupHost = lookupHost(map[string][]string{
+		"ringpop.local": {"10.0.0.2", "10.0.0.1", "::1"},
+	})
+
+	hosts, err := p.Hosts()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:3000", "10.0.0.2:3000", "[::1]:3000"}, hosts)
+
+	p.name = "unknown.local"
+	_, err = p.Hosts()
+	assert.Error(t, err)
+}
+
+func TestSRV(t *testing.T) {
+	p := SRV("ringpop", "tcp", "local")
+	p.lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		return "", []*net.SRV{
+			{Target: "b.local.", Port: 3001},
+			{Target: "a.local.", Port: 3000},
+			{Target: "gone.local.", Port: 3002},
+		}, nil
+	}
+	p.lookupHost = lookupHost(map[string][]string{
+		"a.local": {"10.0.0.1"},
+		"b.local": {"10.0.0.2"},
+	})
+
+	hosts, err := p.Hosts()
+	assert.NoError(t, err, "expected unresolvable targets to be skipped")
+	assert.Equal(t, []string{"10.0.0.1:3000", "10.0.0.2:3001"}, hosts)
+
+	p.lookupHost = lookupHost(nil)
+	_, err = p.Hosts()
+	assert.Error(t, err, "expected an error when no target resolves")
+}
@@ -0,0 +1,381 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package etcd provides the hosts registered under a key prefix in etcd, so
+// ringpop can bootstrap from and heal with the hosts that registered
+// themselves.
+//
+// Each host is a key under the prefix. The host is the value of the key, or,
+// if the value is empty, the rest of the key after the prefix, e.g. the key
+// /ringpop/hosts/10.0.0.1:3000 for the prefix /ringpop/hosts/.
+//
+// The provider talks to the etcd v3 JSON gateway. Hosts ranges over the
+// prefix, unless the provider watches the prefix. A watching provider keeps
+// the hosts current in the background and Hosts returns them without a
+// request, so the partition healer has a current list of hosts even when etcd
+// is briefly unavailable.
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Options configure the etcd provider.
+type Options struct {
+	// Endpoints are the URLs of the etcd members, tried in order, defaults
+	// to http://127.0.0.1:2379.
+	Endpoints []string
+
+	// Prefix is the key prefix hosts are registered under.
+	Prefix string
+
+	// Username and Password authenticate with etcd, if set.
+	Username, Password string
+
+	// Client is the HTTP client to call etcd with, defaults to
+	// http.DefaultClient. It must not time out, to keep watches open.
+	Client *http.Client
+}
+
+// A Provider provides the hosts registered under a key prefix in etcd. It
+// implements discovery.Provider.
+type Provider struct {
+	opts Options
+
+	sync.Mutex
+	hosts  []string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a Provider for the given options.
+func New(opts Options) *Provider {
+	if len(opts.Endpoints) == 0 {
+		opts.Endpoints = []string{"http://127.0.0.1:2379"}
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	return &Provider{opts: opts}
+}
+
+// Hosts returns the hosts registered under the prefix, sorted. A watching
+// provider returns the hosts it last saw.
+func (p *Provider) Hosts() ([]string, error) {
+	p.Lock()
+	hosts := p.hosts
+	p.Unlock()
+
+	if hosts != nil {
+		return append([]string(nil), hosts...), nil
+	}
+
+	hosts, _, err := p.rangePrefix(context.Background())
+	return hosts, err
+}
+
+// Watch starts watching the prefix in the background until Stop is called.
+// Failed requests are retried with backoff, in the meantime Hosts returns the
+// hosts it last saw.
+func (p *Provider) Watch() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	go p.watch(ctx, p.done)
+}
+
+// Stop stops watching the prefix.
+func (p *Provider) Stop() {
+	p.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	p.Lock()
+	p.hosts = nil
+	p.Unlock()
+}
+
+func (p *Provider) watch(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	backoff := time.Second
+	for {
+		err := p.watchOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A closed watch is not a failure, but is reopened after a pause.
+		if err == io.EOF {
+			backoff = time.Second
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// watchOnce ranges over the prefix and then watches it for changes, ranging
+// over it again after every change, until the watch ends. It returns io.EOF
+// if etcd closed the watch.
+func (p *Provider) watchOnce(ctx context.Context) error {
+	hosts, revision, err := p.rangePrefix(ctx)
+	if err != nil {
+		return err
+	}
+	p.setHosts(hosts)
+
+	key, rangeEnd := p.keyRange()
+	body, err := p.post(ctx, "/v3/watch", map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            key,
+			"range_end":      rangeEnd,
+			"start_revision": strconv.FormatInt(revision+1, 10),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	for {
+		var res struct {
+			Result struct {
+				Canceled bool              `json:"canceled"`
+				Events   []json.RawMessage `json:"events"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := decoder.Decode(&res); err != nil {
+			return err
+		}
+
+		if res.Error != nil {
+			return errors.New("etcd: watch failed: " + res.Error.Message)
+		}
+		if res.Result.Canceled {
+			return errors.New("etcd: watch canceled")
+		}
+		if len(res.Result.Events) == 0 {
+			continue
+		}
+
+		hosts, _, err := p.rangePrefix(ctx)
+		if err != nil {
+			return err
+		}
+		p.setHosts(hosts)
+	}
+}
+
+func (p *Provider) setHosts(hosts []string) {
+	p.Lock()
+	p.hosts = hosts
+	p.Unlock()
+}
+
+// rangePrefix returns the hosts registered under the prefix and the revision
+// of etcd they are at.
+func (p *Provider) rangePrefix(ctx context.Context) ([]string, int64, error) {
+	key, rangeEnd := p.keyRange()
+	body, err := p.post(ctx, "/v3/kv/range", map[string]interface{}{
+		"key":       key,
+		"range_end": rangeEnd,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer body.Close()
+
+	var res struct {
+		Header struct {
+			Revision string `json:"revision"`
+		} `json:"header"`
+		KVs []struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(body).Decode(&res); err != nil {
+		return nil, 0, err
+	}
+
+	revision, _ := strconv.ParseInt(res.Header.Revision, 10, 64)
+
+	hosts := make([]string, 0, len(res.KVs))
+	seen := make(map[string]bool, len(res.KVs))
+	for _, kv := range res.KVs {
+		host := strings.TrimSpace(string(kv.Value))
+		if host == "" {
+			host = strings.TrimPrefix(string(kv.Key), p.opts.Prefix)
+		}
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, revision, nil
+}
+
+// keyRange returns the key and range end that select all keys with the
+// prefix. encoding/json encodes them as base64, as the gateway expects.
+func (p *Provider) keyRange() (key, rangeEnd []byte) {
+	key = []byte(p.opts.Prefix)
+	rangeEnd = append([]byte(nil), key...)
+	for i := len(rangeEnd) - 1; i >= 0; i-- {
+		if rangeEnd[i] < 0xff {
+			rangeEnd[i]++
+			return key, rangeEnd[:i+1]
+		}
+	}
+	// The prefix is empty or all 0xff bytes, select all keys from the prefix.
+	return key, []byte{0}
+}
+
+// post posts the JSON request to the first endpoint that can be reached and
+// returns the body of the response.
+func (p *Provider) post(ctx context.Context, path string, request interface{}) (io.ReadCloser, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, endpoint := range p.opts.Endpoints {
+		endpoint = strings.TrimSuffix(endpoint, "/")
+
+		var token string
+		if p.opts.Username != "" {
+			token, err = p.authenticate(ctx, endpoint)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+		}
+
+		body, err := p.do(ctx, endpoint+path, token, data)
+		if err != nil {
+			lastErr = err
+			if _, ok := err.(*APIError); ok {
+				return nil, err
+			}
+			continue
+		}
+		return body, nil
+	}
+	return nil, lastErr
+}
+
+// authenticate returns a token for the username and password.
+func (p *Provider) authenticate(ctx context.Context, endpoint string) (string, error) {
+	data, err := json.Marshal(map[string]string{
+		"name":     p.opts.Username,
+		"password": p.opts.Password,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	body, err := p.do(ctx, endpoint+"/v3/auth/authenticate", "", data)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var res struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(body).Decode(&res); err != nil {
+		return "", err
+	}
+	return res.Token, nil
+}
+
+func (p *Provider) do(ctx context.Context, url, token string, data []byte) (io.ReadCloser, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	res, err := p.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		return nil, &APIError{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return res.Body, nil
+}
+
+// APIError is returned when etcd rejects a request, e.g. because the user
+// may not read the prefix.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("etcd: member returned %d: %s", e.StatusCode, e.Message)
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package etcd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEtcd serves the range and watch endpoints of the etcd gateway from a
+// map of keys.
+type fakeEtcd struct {
+	*httptest.Server
+
+	sync.Mutex
+	kvs     map[string]string
+	changed chan struct{}
+	token   string
+}
+
+func newFakeEtcd(kvs map[string]string) *fakeEtcd {
+	e := &fakeEtcd{kvs: kvs, changed: make(chan struct{}, 1)}
+	e.Server = httptest.NewServer(http.HandlerFunc(e.serve))
+	return e
+}
+
+func (e *fakeEtcd) put(key, value string) {
+	e.Lock()
+	e.kvs[key] = value
+	e.Unlock()
+	e.changed <- struct{}{}
+}
+
+func (e *fakeEtcd) serve(w http.ResponseWriter, r *http.Request) {
+	e.Lock()
+	token := e.token
+	e.Unlock()
+
+	switch r.URL.Path {
+	case "/v3/auth/authenticate":
+		json.NewEncoder(w).Encode(map[string]string{"token": "token"})
+		return
+	}
+
+	if token != "" && r.Header.Get("Authorization") != token {
+		http.Error(w, "invalid auth token", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var req struct {
+			Key      []byte `json:"key"`
+			RangeEnd []byte `json:"range_end"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		type kv struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		}
+		var kvs []kv
+		e.Lock()
+		for key, value := range e.kvs {
+			if key >= string(req.Key) && key < string(req.RangeEnd) {
+				kvs = append(kvs, kv{[]byte(key), []byte(value)})
+			}
+		}
+		e.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"header": map[string]string{"revision": "1"},
+			"kvs":    kvs,
+		})
+
+	case "/v3/watch":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"result": map[string]bool{"created": true},
+		})
+		w.(http.Flusher).Flush()
+
+		for {
+			select {
+			case <-e.changed:
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"result": map[string]interface{}{"events": []string{"put"}},
+				})
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+func TestHosts(t *testing.T) {
+	etcd := newFakeEtcd(map[string]string{
+		"/ringpop/10.0.0.2:3000": "",
+		"/ringpop/a":             "10.0.0.1:3000",
+		"/other/10.0.0.3:3000":   "",
+	})
+	defer etcd.Close()
+
+	p := New(Options{
+		Endpoints: []string{"http://127.0.0.1:1", etcd.URL},
+		Prefix:    "/ringpop/",
+	})
+
+	hosts, err := p.Hosts()
+	assert.NoError(t, err, "expected the reachable endpoint to be used")
+	assert.Equal(t, []string{"10.0.0.1:3000", "10.0.0.2:3000"}, hosts)
+}
+
+func TestAuthentication(t *testing.T) {
+	etcd := newFakeEtcd(map[string]string{"/ringpop/10.0.0.1:3000": ""})
+	etcd.token = "token"
+	defer etcd.Close()
+
+	_, err := New(Options{Endpoints: []string{etcd.URL}, Prefix: "/ringpop/"}).Hosts()
+	require.IsType(t, &APIError{}, err)
+	assert.Equal(t, http.StatusUnauthorized, err.(*APIError).StatusCode)
+
+	hosts, err := New(Options{
+		Endpoints: []string{etcd.URL},
+		Prefix:    "/ringpop/",
+		Username:  "ringpop",
+		Password:  "secret",
+	}).Hosts()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:3000"}, hosts)
+}
+
+func TestKeyRange(t *testing.T) {
+	key, rangeEnd := New(Options{Prefix: "/ringpop/"}).keyRange()
+	assert.Equal(t, []byte("/ringpop/"), key)
+	assert.Equal(t, []byte("/ringpop0"), rangeEnd)
+
+	_, rangeEnd = New(Options{Prefix: "a\xff"}).keyRange()
+	assert.Equal(t, []byte("b"), rangeEnd)
+
+	_, rangeEnd = New(Options{}).keyRange()
+	assert.Equal(t, []byte{0}, rangeEnd)
+}
+
+func TestWatch(t *testing.T) {
+	etcd := newFakeEtcd(map[string]string{"/ringpop/10.0.0.1:3000": ""})
+	defer etcd.Close()
+
+	p := New(Options{Endpoints: []string{etcd.URL}, Prefix: "/ringpop/"})
+	p.Watch()
+	defer p.Stop()
+
+	waitForHosts(t, p, []string{"10.0.0.1:3000"})
+	etcd.put("/ringpop/10.0.0.2:3000", "")
+	waitForHosts(t, p, []string{"10.0.0.1:3000", "10.0.0.2:3000"})
+}
+
+func waitForHosts(t *testing.T, p *Provider, expected []string) {
+	var hosts []string
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		p.Lock()
+		hosts = p.hosts
+		p.Unlock()
+
+		if assert.ObjectsAreEqual(expected, hosts) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("expected watched hosts %v, got %v", expected, hosts)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A FileProvider provides the hosts listed in a file. The file is read again
+// whenever it was modified since it was last read, so hosts can be added to or
+// removed from a running cluster by updating the file.
+//
+// The file holds either a JSON array of hosts, like the file of
+// swim.BootstrapOptions.File, or one host per line. Empty lines and lines
+// starting with # are ignored.
+type FileProvider struct {
+	path string
+
+	sync.Mutex
+	modTime time.Time
+	size    int64
+	hosts   []string
+}
+
+// File returns a FileProvider of the hosts listed in the file at path.
+func File(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+// Hosts returns the hosts listed in the file, reading the file only if it was
+// modified since it was last read.
+func (p *FileProvider) Hosts() ([]string, error) {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.hosts != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return append([]string(nil), p.hosts...), nil
+	}
+
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := parseHosts(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	p.hosts = hosts
+	return append([]string(nil), hosts...), nil
+}
+
+// parseHosts parses a JSON array of hosts, or a list of hosts with one host
+// per line.
+func parseHosts(data []byte) ([]string, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		hosts := []string{}
+		if err := json.Unmarshal(trimmed, &hosts); err != nil {
+			return nil, err
+		}
+		return hosts, nil
+	}
+
+	hosts := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hosts = append(hosts, line)
+	}
+	return hosts, scanner.Err()
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package discovery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeHosts(t *testing.T, path, contents string, modTime time.Time) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discovery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "hosts.json")
+	now := time.Now()
+	writeHosts(t, path, `["127.0.0.1:3001", "127.0.0.1:3002"]`, now)
+
+	p := File(path)
+	hosts, err := p.Hosts()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:3001", "127.0.0.1:3002"}, hosts)
+
+	writeHosts(t, path, `["127.0.0.1:3001", "127.0.0.1:3002", "127.0.0.1:3003"]`, now.Add(time.Second))
+
+	hosts, err = p.Hosts()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:3001", "127.0.0.1:3002", "127.0.0.1:3003"}, hosts,
+		"expected the modified file to be read again")
+}
+
+func TestFileLines(t *testing.T) {
+	hosts, err := parseHosts([]byte("# seeds\n127.0.0.1:3001\n\n  127.0.0.1:3002  \n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:3001", "127.0.0.1:3002"}, hosts)
+}
+
+func TestFileErrors(t *testing.T) {
+	_, err := File("/does/not/exist").Hosts()
+	assert.Error(t, err)
+
+	_, err = parseHosts([]byte(`["127.0.0.1:3001",`))
+	assert.Error(t, err)
+}
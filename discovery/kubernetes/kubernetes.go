@@ -0,0 +1,599 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package kubernetes provides the addresses of the pods behind Kubernetes
+// endpoints, so ringpop can bootstrap and re-discover peers in a cluster
+// without hand-rolled glue.
+//
+// The provider lists the Endpoints, or EndpointSlices, that match a label
+// selector through the Kubernetes API every time hosts are requested, and
+// returns the ready addresses at the named port, unless the provider watches
+// the endpoints. A watching provider keeps the addresses current with a watch
+// of the API in the background, and Hosts returns them without a request.
+// Run in a pod, it uses the pod's service account; the account needs
+// permission to list and watch endpoints (or endpointslices) in the
+// namespace.
+package kubernetes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// serviceAccountDir is where Kubernetes mounts the credentials of the pod's
+// service account.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// listTimeout is how long a list request may take.
+const listTimeout = 10 * time.Second
+
+// ErrNotInCluster is returned by New when no API server is configured and the
+// process does not run in a Kubernetes pod.
+var ErrNotInCluster = errors.New("kubernetes: not running in a cluster, KUBERNETES_SERVICE_HOST is not set")
+
+// Options configure the Kubernetes provider.
+type Options struct {
+	// Namespace of the endpoints, defaults to the namespace of the pod.
+	Namespace string
+
+	// LabelSelector selects the endpoints to list, e.g. "app=ringpop". For
+	// EndpointSlices, the slices of a service are selected by
+	// "kubernetes.io/service-name=<service>".
+	LabelSelector string
+
+	// PortName is the name of the port ringpop listens on. It may be left
+	// empty if the endpoints have a single port.
+	PortName string
+
+	// EndpointSlices lists discovery.k8s.io/v1 EndpointSlices instead of
+	// Endpoints.
+	EndpointSlices bool
+
+	// APIServer is the URL of the Kubernetes API server, defaults to the
+	// in-cluster address.
+	APIServer string
+
+	// TokenFile is the file of the bearer token to authenticate with,
+	// defaults to the token of the pod's service account. It is read on every
+	// request, since the token is rotated.
+	TokenFile string
+
+	// WatchTimeout is how long the API server keeps a watch open before it
+	// is renewed, defaults to 5 minutes.
+	WatchTimeout time.Duration
+
+	// Client is the HTTP client to call the API server with, defaults to a
+	// client that trusts the CA of the pod's service account. It must not
+	// time out before WatchTimeout.
+	Client *http.Client
+}
+
+// A Provider provides the addresses of the ready pods behind Kubernetes
+// endpoints. It implements discovery.Provider.
+type Provider struct {
+	opts Options
+
+	sync.Mutex
+	hosts  []string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a Provider for the given options, filling in the in-cluster
+// defaults for options that are not set.
+func New(opts Options) (*Provider, error) {
+	if opts.APIServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" {
+			return nil, ErrNotInCluster
+		}
+		if port == "" {
+			port = "443"
+		}
+		opts.APIServer = "https://" + net.JoinHostPort(host, port)
+	}
+
+	if opts.Namespace == "" {
+		namespace, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes: namespace not set: %v", err)
+		}
+		opts.Namespace = strings.TrimSpace(string(namespace))
+	}
+
+	if opts.TokenFile == "" {
+		if _, err := os.Stat(filepath.Join(serviceAccountDir, "token")); err == nil {
+			opts.TokenFile = filepath.Join(serviceAccountDir, "token")
+		}
+	}
+
+	if opts.WatchTimeout == 0 {
+		opts.WatchTimeout = 5 * time.Minute
+	}
+
+	if opts.Client == nil {
+		client, err := inClusterClient()
+		if err != nil {
+			return nil, err
+		}
+		opts.Client = client
+	}
+
+	return &Provider{opts: opts}, nil
+}
+
+// inClusterClient returns an HTTP client that trusts the CA of the pod's
+// service account, or the system roots if there is none.
+func inClusterClient() (*http.Client, error) {
+	config := &tls.Config{}
+
+	ca, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err == nil {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("kubernetes: invalid service account CA certificate")
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// lists are bounded by listTimeout instead, as watches stay open
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: config},
+	}, nil
+}
+
+// Hosts lists the endpoints and returns the ready addresses at the port, as
+// host:port, sorted. A watching provider returns the addresses it last saw.
+func (p *Provider) Hosts() ([]string, error) {
+	p.Lock()
+	hosts := p.hosts
+	p.Unlock()
+
+	if hosts != nil {
+		return append([]string(nil), hosts...), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), listTimeout)
+	defer cancel()
+
+	objects, _, err := p.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return objectHosts(objects, p.opts.PortName)
+}
+
+// Watch starts watching the endpoints in the background until Stop is
+// called. Failed requests are retried with backoff, in the meantime Hosts
+// returns the addresses the provider last saw.
+func (p *Provider) Watch() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	go p.watch(ctx, p.done)
+}
+
+// Stop stops watching the endpoints.
+func (p *Provider) Stop() {
+	p.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	p.Lock()
+	p.hosts = nil
+	p.Unlock()
+}
+
+// watch lists the endpoints and then watches them for changes from the
+// version of the list. The endpoints are listed again when a watch fails,
+// e.g. because the version is too old to watch from.
+func (p *Provider) watch(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	var objects map[string]endpointsObject
+	var version string
+	backoff := time.Second
+	for {
+		var err error
+		if objects == nil {
+			var list []endpointsObject
+			listCtx, cancel := context.WithTimeout(ctx, listTimeout)
+			list, version, err = p.list(listCtx)
+			cancel()
+			if err == nil {
+				objects = make(map[string]endpointsObject, len(list))
+				for _, object := range list {
+					objects[object.name()] = object
+				}
+				p.update(objects)
+			}
+		} else {
+			version, err = p.watchFrom(ctx, objects, version)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			objects = nil
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+// update sets the hosts of the watched endpoints. If the hosts cannot be
+// determined, they are cleared so that Hosts lists the endpoints and returns
+// the error.
+func (p *Provider) update(objects map[string]endpointsObject) {
+	list := make([]endpointsObject, 0, len(objects))
+	for _, object := range objects {
+		list = append(list, object)
+	}
+	hosts, err := objectHosts(list, p.opts.PortName)
+	if err != nil {
+		hosts = nil
+	}
+
+	p.Lock()
+	p.hosts = hosts
+	p.Unlock()
+}
+
+// watchFrom applies the changes of the endpoints since the given version to
+// the objects, until the API server closes the watch, and returns the version
+// of the last change.
+func (p *Provider) watchFrom(ctx context.Context, objects map[string]endpointsObject, version string) (string, error) {
+	res, err := p.get(ctx, url.Values{
+		"watch":               {"1"},
+		"resourceVersion":     {version},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(int(p.opts.WatchTimeout.Seconds()))},
+	})
+	if err != nil {
+		return version, err
+	}
+	defer res.Body.Close()
+
+	decoder := json.NewDecoder(res.Body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err == io.EOF {
+			return version, nil
+		} else if err != nil {
+			return version, err
+		}
+
+		switch event.Type {
+		case "ERROR":
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(event.Object, &status)
+			return version, &APIError{StatusCode: status.Code, Message: status.Message}
+
+		case "BOOKMARK":
+			var bookmark struct {
+				objectMeta `json:"metadata"`
+			}
+			if err := json.Unmarshal(event.Object, &bookmark); err != nil {
+				return version, err
+			}
+			version = bookmark.ResourceVersion
+
+		case "ADDED", "MODIFIED", "DELETED":
+			object := p.newObject()
+			if err := json.Unmarshal(event.Object, object); err != nil {
+				return version, err
+			}
+			if event.Type == "DELETED" {
+				delete(objects, object.name())
+			} else {
+				objects[object.name()] = object
+			}
+			version = object.version()
+			p.update(objects)
+		}
+	}
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// list gets the endpoints in the namespace that match the label selector,
+// and the version of the list.
+func (p *Provider) list(ctx context.Context) ([]endpointsObject, string, error) {
+	res, err := p.get(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	var list struct {
+		Metadata objectMeta        `json:"metadata"`
+		Items    []json.RawMessage `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return nil, "", err
+	}
+
+	objects := make([]endpointsObject, len(list.Items))
+	for i, item := range list.Items {
+		objects[i] = p.newObject()
+		if err := json.Unmarshal(item, objects[i]); err != nil {
+			return nil, "", err
+		}
+	}
+	return objects, list.Metadata.ResourceVersion, nil
+}
+
+// get requests the endpoints in the namespace that match the label selector
+// with the given parameters, and returns the response if it is successful.
+func (p *Provider) get(ctx context.Context, params url.Values) (*http.Response, error) {
+	group, resource := "/api/v1", "endpoints"
+	if p.opts.EndpointSlices {
+		group, resource = "/apis/discovery.k8s.io/v1", "endpointslices"
+	}
+
+	if params == nil {
+		params = url.Values{}
+	}
+	if p.opts.LabelSelector != "" {
+		params.Set("labelSelector", p.opts.LabelSelector)
+	}
+
+	u := fmt.Sprintf("%s%s/namespaces/%s/%s",
+		strings.TrimSuffix(p.opts.APIServer, "/"), group, url.PathEscape(p.opts.Namespace), resource)
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	if p.opts.TokenFile != "" {
+		token, err := ioutil.ReadFile(p.opts.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	res, err := p.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		return nil, &APIError{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return res, nil
+}
+
+// APIError is returned when the Kubernetes API server rejects a list or watch
+// request, e.g. because the service account may not list endpoints.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("kubernetes: API server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// PortNotFoundError is returned when endpoints do not have the named port, or
+// have several ports and no port name was given.
+type PortNotFoundError struct {
+	PortName string
+}
+
+func (e *PortNotFoundError) Error() string {
+	if e.PortName == "" {
+		return "kubernetes: endpoints have several ports, set a port name"
+	}
+	return fmt.Sprintf("kubernetes: endpoints have no port named %q", e.PortName)
+}
+
+type endpointPort struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+// findPort returns the port with the given name, or the only port if name is
+// empty.
+func findPort(ports []endpointPort, name string) (int, error) {
+	if name == "" {
+		if len(ports) == 1 {
+			return ports[0].Port, nil
+		}
+		return 0, &PortNotFoundError{}
+	}
+	for _, port := range ports {
+		if port.Name == name {
+			return port.Port, nil
+		}
+	}
+	return 0, &PortNotFoundError{PortName: name}
+}
+
+type objectMeta struct {
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+func (m objectMeta) name() string    { return m.Name }
+func (m objectMeta) version() string { return m.ResourceVersion }
+
+// An endpointsObject is an Endpoints or EndpointSlice object.
+type endpointsObject interface {
+	name() string
+	version() string
+
+	// addHosts adds the ready addresses of the object to the hosts.
+	addHosts(hosts hostSet, portName string) error
+}
+
+// newObject returns an empty object of the resource the provider lists.
+func (p *Provider) newObject() endpointsObject {
+	if p.opts.EndpointSlices {
+		return &endpointSliceV1{}
+	}
+	return &endpointsV1{}
+}
+
+// objectHosts returns the ready addresses of the objects, sorted.
+func objectHosts(objects []endpointsObject, portName string) ([]string, error) {
+	hosts := newHostSet()
+	for _, object := range objects {
+		if err := object.addHosts(hosts, portName); err != nil {
+			return nil, err
+		}
+	}
+	return hosts.sorted(), nil
+}
+
+type endpointsV1 struct {
+	objectMeta `json:"metadata"`
+	Subsets    []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+		Ports []endpointPort `json:"ports"`
+	} `json:"subsets"`
+}
+
+// addHosts adds the ready addresses of the endpoints. Not ready addresses are
+// listed separately by Kubernetes and are left out.
+func (e *endpointsV1) addHosts(hosts hostSet, portName string) error {
+	for _, subset := range e.Subsets {
+		if len(subset.Addresses) == 0 {
+			continue
+		}
+		port, err := findPort(subset.Ports, portName)
+		if err != nil {
+			return err
+		}
+		for _, address := range subset.Addresses {
+			hosts.add(address.IP, port)
+		}
+	}
+	return nil
+}
+
+type endpointSliceV1 struct {
+	objectMeta `json:"metadata"`
+	Endpoints  []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []endpointPort `json:"ports"`
+}
+
+// addHosts adds the addresses of the ready endpoints of the slice. An unknown
+// readiness is treated as ready, as Kubernetes does.
+func (s *endpointSliceV1) addHosts(hosts hostSet, portName string) error {
+	if len(s.Endpoints) == 0 {
+		return nil
+	}
+	port, err := findPort(s.Ports, portName)
+	if err != nil {
+		return err
+	}
+	for _, endpoint := range s.Endpoints {
+		if ready := endpoint.Conditions.Ready; ready != nil && !*ready {
+			continue
+		}
+		for _, address := range endpoint.Addresses {
+			hosts.add(address, port)
+		}
+	}
+	return nil
+}
+
+// hostSet collects addresses without duplicates, since a pod may be listed
+// by several endpoints.
+type hostSet map[string]struct{}
+
+func newHostSet() hostSet {
+	return make(hostSet)
+}
+
+func (s hostSet) add(ip string, port int) {
+	s[net.JoinHostPort(ip, strconv.Itoa(port))] = struct{}{}
+}
+
+func (s hostSet) sorted() []string {
+	hosts := make([]string, 0, len(s))
+	for host := range s {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
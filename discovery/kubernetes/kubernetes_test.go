@@ -0,0 +1,254 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package kubernetes
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const endpoints = `{"items": [{
+	"subsets": [{
+		"addresses": [{"ip": "10.0.0.2"}, {"ip": "10.0.0.1"}],
+		"notReadyAddresses": [{"ip": "10.0.0.3"}],
+		"ports": [{"name": "ringpop", "port": 3000}, {"name": "http", "port": 8080}]
+	}]
+}, {
+	"subsets": [{
+		"addresses": [{"ip": "10.0.0.1"}],
+		"ports": [{"name": "ringpop", "port": 3000}]
+	}]
+}]}`
+
+const endpointSlices = `{"items": [{
+	"endpoints": [
+		{"addresses": ["10.0.0.1"], "conditions": {"ready": true}},
+		{"addresses": ["10.0.0.2"], "conditions": {}},
+		{"addresses": ["10.0.0.3"], "conditions": {"ready": false}}
+	],
+	"ports": [{"name": "ringpop", "port": 3000}]
+}]}`
+
+type apiServer struct {
+	*httptest.Server
+	requests []*http.Request
+}
+
+func newAPIServer(t *testing.T, responses map[string]string) *apiServer {
+	s := &apiServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r)
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	return s
+}
+
+func newProvider(t *testing.T, server *apiServer, opts Options) *Provider {
+	opts.APIServer = server.URL
+	opts.Namespace = "default"
+	opts.Client = server.Client()
+	p, err := New(opts)
+	require.NoError(t, err)
+	return p
+}
+
+func TestEndpoints(t *testing.T) {
+	server := newAPIServer(t, map[string]string{
+		"/api/v1/namespaces/default/endpoints": endpoints,
+	})
+	defer server.Close()
+
+	token, err := ioutil.TempFile("", "token")
+	require.NoError(t, err)
+	defer os.Remove(token.Name())
+	token.WriteString("secret\n")
+	token.Close()
+
+	p := newProvider(t, server, Options{
+		LabelSelector: "app=ringpop",
+		PortName:      "ringpop",
+		TokenFile:     token.Name(),
+	})
+
+	hosts, err := p.Hosts()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:3000", "10.0.0.2:3000"}, hosts,
+		"expected ready addresses without duplicates")
+
+	require.Len(t, server.requests, 1)
+	assert.Equal(t, "app=ringpop", server.requests[0].URL.Query().Get("labelSelector"))
+	assert.Equal(t, "Bearer secret", server.requests[0].Header.Get("Authorization"))
+}
+
+func TestEndpointSlices(t *testing.T) {
+	server := newAPIServer(t, map[string]string{
+		"/apis/discovery.k8s.io/v1/namespaces/default/endpointslices": endpointSlices,
+	})
+	defer server.Close()
+
+	p := newProvider(t, server, Options{
+		LabelSelector:  "kubernetes.io/service-name=ringpop",
+		EndpointSlices: true,
+	})
+
+	hosts, err := p.Hosts()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:3000", "10.0.0.2:3000"}, hosts,
+		"expected endpoints that are not ready to be left out")
+}
+
+func TestPortNotFound(t *testing.T) {
+	server := newAPIServer(t, map[string]string{
+		"/api/v1/namespaces/default/endpoints": endpoints,
+	})
+	defer server.Close()
+
+	_, err := newProvider(t, server, Options{}).Hosts()
+	assert.Equal(t, &PortNotFoundError{}, err)
+
+	_, err = newProvider(t, server, Options{PortName: "tchannel"}).Hosts()
+	assert.Equal(t, &PortNotFoundError{PortName: "tchannel"}, err)
+}
+
+func TestAPIError(t *testing.T) {
+	server := newAPIServer(t, nil)
+	defer server.Close()
+
+	_, err := newProvider(t, server, Options{}).Hosts()
+	assert.Equal(t, &APIError{StatusCode: http.StatusForbidden, Message: "forbidden"}, err)
+}
+
+func TestNotInCluster(t *testing.T) {
+	host := os.Getenv("KUBERNETES_SERVICE_HOST")
+	os.Unsetenv("KUBERNETES_SERVICE_HOST")
+	defer os.Setenv("KUBERNETES_SERVICE_HOST", host)
+
+	_, err := New(Options{})
+	assert.Equal(t, ErrNotInCluster, err)
+}
+
+const watchedEndpoints = `{"metadata": {"resourceVersion": "1"}, "items": [{
+	"metadata": {"name": "ringpop", "resourceVersion": "1"},
+	"subsets": [{
+		"addresses": [{"ip": "10.0.0.1"}, {"ip": "10.0.0.2"}],
+		"ports": [{"name": "ringpop", "port": 3000}]
+	}]
+}]}`
+
+const scaledUp = `{"type": "MODIFIED", "object": {
+	"metadata": {"name": "ringpop", "resourceVersion": "2"},
+	"subsets": [{
+		"addresses": [{"ip": "10.0.0.1"}, {"ip": "10.0.0.2"}, {"ip": "10.0.0.3"}],
+		"ports": [{"name": "ringpop", "port": 3000}]
+	}]
+}}`
+
+func TestWatch(t *testing.T) {
+	scaleUp := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("watch") == "" {
+			w.Write([]byte(watchedEndpoints))
+			return
+		}
+
+		switch query.Get("resourceVersion") {
+		case "1":
+			select {
+			case <-scaleUp:
+			case <-r.Context().Done():
+				return
+			}
+			w.Write([]byte(scaledUp))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	p := newProvider(t, &apiServer{Server: server}, Options{PortName: "ringpop"})
+	p.Watch()
+	defer p.Stop()
+
+	waitForHosts(t, p, []string{"10.0.0.1:3000", "10.0.0.2:3000"})
+	close(scaleUp)
+	waitForHosts(t, p, []string{"10.0.0.1:3000", "10.0.0.2:3000", "10.0.0.3:3000"})
+}
+
+func TestWatchExpired(t *testing.T) {
+	var lists int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "" {
+			atomic.AddInt32(&lists, 1)
+			w.Write([]byte(watchedEndpoints))
+			return
+		}
+		if atomic.LoadInt32(&lists) == 1 {
+			w.Write([]byte(`{"type": "ERROR", "object": {"code": 410, "message": "too old resource version"}}`))
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	p := newProvider(t, &apiServer{Server: server}, Options{PortName: "ringpop"})
+	p.Watch()
+	defer p.Stop()
+
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); {
+		if atomic.LoadInt32(&lists) == 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt32(&lists), "expected endpoints to be listed again when the watch expires")
+	waitForHosts(t, p, []string{"10.0.0.1:3000", "10.0.0.2:3000"})
+}
+
+func waitForHosts(t *testing.T, p *Provider, expected []string) {
+	var hosts []string
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		p.Lock()
+		hosts = p.hosts
+		p.Unlock()
+
+		if assert.ObjectsAreEqual(expected, hosts) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("expected watched hosts %v, got %v", expected, hosts)
+}
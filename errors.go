@@ -7,8 +7,17 @@ var (
 	// be bootstrapped before they can operate correctly.
 	ErrNotBootstrapped = errors.New("ringpop is not bootstrapped")
 
+	// ErrStandby is returned by public methods which require the ring to be
+	// bootstrapped while Ringpop waits for its readiness check to pass before
+	// joining. See JoinWhenReady.
+	ErrStandby = errors.New("ringpop is in standby until it is ready to join")
+
 	// ErrEphemeralIdentity is returned by the identity resolver if TChannel is
 	// using port 0 and is not listening (and thus has not been assigned a port by
 	// the OS).
 	ErrEphemeralIdentity = errors.New("unable to resolve this node's identity from channel that is not yet listening")
+
+	// ErrNoKeys is returned by HandleOrForward when it is called without a key
+	// and the codec of the endpoint did not extract any keys from the request.
+	ErrNoKeys = errors.New("no keys in request")
 )
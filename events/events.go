@@ -20,7 +20,11 @@
 
 package events
 
-import "time"
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
 
 // Event is an empty interface that is type switched when handeled.
 type Event interface{}
@@ -31,10 +35,45 @@ type EventListener interface {
 	HandleEvent(event Event)
 }
 
-// A RingChangedEvent is sent when servers are added and/or removed from the ring
+// A ContextEventListener is an EventListener that receives the context events
+// were emitted in, such as the context of the request a forwarding event is
+// about, so that it can take part in tracing and cancellation. Events that
+// are not tied to a request come with a background context.
+type ContextEventListener interface {
+	EventListener
+	HandleEventContext(ctx context.Context, event Event)
+}
+
+// Notify passes the event to the listener, with the context if the listener is
+// a ContextEventListener.
+func Notify(ctx context.Context, l EventListener, event Event) {
+	if cl, ok := l.(ContextEventListener); ok {
+		cl.HandleEventContext(ctx, event)
+		return
+	}
+	l.HandleEvent(event)
+}
+
+// A RingChangedEvent is sent when servers are added and/or removed from the ring,
+// or when servers on the ring are updated. It lists the servers that changed,
+// so listeners do not have to compare the ring to a previous copy. The lists
+// are empty when the configuration of the whole ring changed.
 type RingChangedEvent struct {
 	ServersAdded   []string
 	ServersRemoved []string
+
+	// ServersUpdated are the servers on the ring whose labels, replica points
+	// or identity changed.
+	ServersUpdated []ServerUpdate
+}
+
+// A ServerUpdate describes a server on the ring that was updated. The labels
+// before and after the update are equal when only the placement of the server
+// changed.
+type ServerUpdate struct {
+	Server       string
+	LabelsBefore map[string]string
+	LabelsAfter  map[string]string
 }
 
 // RingChecksumEvent is sent when a server is removed or added and a new checksum
@@ -49,3 +88,105 @@ type LookupEvent struct {
 	Key      string
 	Duration time.Duration
 }
+
+// A LookupNSpillEvent is sent when LookupN looked up a key whose primary
+// owner gossiped a load above the spillover threshold. Spilled is whether the
+// key is among the fraction of keys whose secondary owner is returned first
+type LookupNSpillEvent struct {
+	Key     string
+	Primary string
+	Load    float64
+	Spilled bool
+}
+
+// A ShadowLookupEvent is sent when the owner of a key on the active ring was
+// compared with its owner on the shadow ring, which is a candidate ring
+// configuration. The owners diverged if they differ.
+type ShadowLookupEvent struct {
+	Key         string
+	Owner       string
+	ShadowOwner string
+}
+
+// A RingCutoverEvent is sent when a Ringpop instance cut its ring over to the
+// configuration of its ring migration, because Member gossiped that it
+// entered the epoch of the migration.
+type RingCutoverEvent struct {
+	Epoch  int
+	Member string
+}
+
+// A LookupOverriddenEvent is sent when a lookup returns the member the key is
+// pinned to by the routing overrides instead of its owner on the ring
+type LookupOverriddenEvent struct {
+	Key    string
+	Member string
+}
+
+// A RoutingOverridesReloadedEvent is sent when the routing overrides file
+// changed and the overrides in it took effect. The counts are zero when the
+// file was removed.
+type RoutingOverridesReloadedEvent struct {
+	Path     string
+	Keys     int
+	Prefixes int
+}
+
+// A RoutingOverridesFailedEvent is sent when the routing overrides file
+// changed but could not be read or parsed. The previous overrides stay in
+// effect.
+type RoutingOverridesFailedEvent struct {
+	Path  string
+	Error string
+}
+
+// A MemberWeightChangedEvent is sent when the weight a member is placed on the
+// ring with changed, because the member changed its weight label. Points is
+// the number of replica points the member has with the new weight.
+type MemberWeightChangedEvent struct {
+	Member    string
+	OldWeight float64
+	Weight    float64
+	Points    int
+}
+
+// A SelfEvictedEvent is sent when this Ringpop instance evicted itself from
+// the cluster before shutting down. Notified is the number of members the
+// leave was sent to right away.
+type SelfEvictedEvent struct {
+	Notified int
+	Duration time.Duration
+}
+
+// A MeshReadinessChangedEvent is sent when the service mesh sidecar became
+// ready or stopped being ready to route traffic.
+type MeshReadinessChangedEvent struct {
+	Ready bool
+}
+
+// A KeyLockLostEvent is sent when a lock on a key is invalidated because
+// ownership of the key moved to another node
+type KeyLockLostEvent struct {
+	Key      string
+	NewOwner string
+}
+
+// A ZoneUnreachableEvent is sent when none of the members of a zone are
+// reachable anymore, which indicates a failure of the zone rather than of
+// individual members
+type ZoneUnreachableEvent struct {
+	Zone    string
+	Members int
+}
+
+// A ZoneReachableEvent is sent when a member of a zone that was unreachable
+// is reachable again
+type ZoneReachableEvent struct {
+	Zone string
+}
+
+// A ReadyToJoinEvent is sent when the readiness check passes and a Ringpop
+// instance that was in standby starts joining the cluster
+type ReadyToJoinEvent struct {
+	Waited time.Duration
+}
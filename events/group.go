@@ -0,0 +1,189 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package events
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// A ListenerPanic describes a panic of a listener in a ListenerGroup that was
+// recovered.
+type ListenerPanic struct {
+	// Listener is the name the listener was registered with.
+	Listener string
+
+	// Event is the event the listener panicked on, and Value the value it
+	// panicked with.
+	Event Event
+	Value interface{}
+
+	// Panics is the number of times the listener panicked so far.
+	Panics int
+
+	// Deregistered is true if the listener was deregistered because it
+	// reached the panic limit of the group.
+	Deregistered bool
+}
+
+// namedListener is a listener registered with a ListenerGroup.
+type namedListener struct {
+	name     string
+	listener EventListener
+	panics   int
+}
+
+// A ListenerGroup is a set of named listeners that are isolated from each
+// other: a panic in the HandleEvent method of a listener is recovered and
+// reported, so that one buggy listener cannot take down the goroutine that
+// emits events or keep the other listeners from receiving them.
+//
+// The zero value is an empty group that never deregisters listeners.
+type ListenerGroup struct {
+	// MaxPanics is the number of panics after which a listener is
+	// deregistered. Zero keeps listeners registered regardless of panics.
+	MaxPanics int
+
+	// OnPanic, when set, is called for every panic that was recovered.
+	OnPanic func(ListenerPanic)
+
+	listeners []*namedListener
+	sync.RWMutex
+}
+
+// ListenerName returns the name a listener registered without a name is
+// known by, which is its type.
+func ListenerName(l EventListener) string {
+	return fmt.Sprintf("%T", l)
+}
+
+// Register adds a listener to the group under the given name. Names need not
+// be unique, but are used to report panics and deregister listeners.
+func (g *ListenerGroup) Register(name string, l EventListener) {
+	g.Lock()
+	g.listeners = append(g.listeners, &namedListener{name: name, listener: l})
+	g.Unlock()
+}
+
+// Deregister removes all listeners registered under the given name from the
+// group and returns whether there were any.
+func (g *ListenerGroup) Deregister(name string) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	return g.removeNoLock(func(l *namedListener) bool {
+		return l.name == name
+	})
+}
+
+// Len returns the number of listeners in the group.
+func (g *ListenerGroup) Len() int {
+	g.RLock()
+	n := len(g.listeners)
+	g.RUnlock()
+	return n
+}
+
+// Emit calls HandleEvent on all listeners of the group synchronously.
+func (g *ListenerGroup) Emit(event Event) {
+	g.EmitContext(context.Background(), event)
+}
+
+// EmitContext passes the event and the context it was emitted in to all
+// listeners of the group synchronously, see Notify.
+func (g *ListenerGroup) EmitContext(ctx context.Context, event Event) {
+	for _, l := range g.snapshot() {
+		g.handle(ctx, l, event)
+	}
+}
+
+// EmitAsync calls HandleEvent on all listeners of the group, each in its own
+// goroutine.
+func (g *ListenerGroup) EmitAsync(event Event) {
+	g.EmitAsyncContext(context.Background(), event)
+}
+
+// EmitAsyncContext passes the event and the context it was emitted in to all
+// listeners of the group, each in its own goroutine, see Notify.
+func (g *ListenerGroup) EmitAsyncContext(ctx context.Context, event Event) {
+	for _, l := range g.snapshot() {
+		go g.handle(ctx, l, event)
+	}
+}
+
+// snapshot returns the listeners currently in the group.
+func (g *ListenerGroup) snapshot() []*namedListener {
+	g.RLock()
+	listeners := g.listeners
+	g.RUnlock()
+	return listeners
+}
+
+// handle passes the event to the listener and recovers a panic.
+func (g *ListenerGroup) handle(ctx context.Context, l *namedListener, event Event) {
+	defer func() {
+		if value := recover(); value != nil {
+			g.recovered(l, event, value)
+		}
+	}()
+
+	Notify(ctx, l.listener, event)
+}
+
+// recovered records a panic of the listener, deregisters the listener when
+// it reached the panic limit and reports the panic.
+func (g *ListenerGroup) recovered(l *namedListener, event Event, value interface{}) {
+	g.Lock()
+	l.panics++
+	p := ListenerPanic{
+		Listener: l.name,
+		Event:    event,
+		Value:    value,
+		Panics:   l.panics,
+	}
+	if g.MaxPanics > 0 && l.panics >= g.MaxPanics {
+		p.Deregistered = g.removeNoLock(func(other *namedListener) bool {
+			return other == l
+		})
+	}
+	onPanic := g.OnPanic
+	g.Unlock()
+
+	if onPanic != nil {
+		onPanic(p)
+	}
+}
+
+// removeNoLock removes the listeners that match. A new slice is allocated so
+// that snapshots taken by concurrent emits are not modified.
+func (g *ListenerGroup) removeNoLock(match func(*namedListener) bool) bool {
+	var kept []*namedListener
+	for _, l := range g.listeners {
+		if !match(l) {
+			kept = append(kept, l)
+		}
+	}
+	removed := len(kept) != len(g.listeners)
+	g.listeners = kept
+	return removed
+}
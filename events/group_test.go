@@ -0,0 +1,126 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package events
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type countingListener struct {
+	events int
+	sync.Mutex
+}
+
+func (l *countingListener) HandleEvent(event Event) {
+	l.Lock()
+	l.events++
+	l.Unlock()
+}
+
+type panickingListener struct{}
+
+func (panickingListener) HandleEvent(event Event) {
+	panic("listener bug")
+}
+
+type contextListener struct {
+	values []interface{}
+}
+
+func (l *contextListener) HandleEvent(event Event) {
+	l.values = append(l.values, nil)
+}
+
+func (l *contextListener) HandleEventContext(ctx context.Context, event Event) {
+	l.values = append(l.values, ctx.Value("key"))
+}
+
+func TestListenerGroupEmitContext(t *testing.T) {
+	var g ListenerGroup
+	listener := &contextListener{}
+	counter := &countingListener{}
+	g.Register("context", listener)
+	g.Register("counter", counter)
+
+	g.EmitContext(context.WithValue(context.Background(), "key", "value"), "event")
+	g.Emit("event")
+
+	assert.Equal(t, []interface{}{"value", nil}, listener.values, "expected context to be passed to the listener")
+	assert.Equal(t, 2, counter.events, "expected plain listener to receive both events")
+}
+
+func TestListenerGroupRecoversPanics(t *testing.T) {
+	var panics []ListenerPanic
+	g := &ListenerGroup{
+		OnPanic: func(p ListenerPanic) { panics = append(panics, p) },
+	}
+	counter := &countingListener{}
+	g.Register("buggy", panickingListener{})
+	g.Register("counter", counter)
+
+	g.Emit("event")
+	g.Emit("event")
+
+	assert.Equal(t, 2, counter.events, "expected listener after the panicking one to be called")
+	assert.Equal(t, []ListenerPanic{
+		{Listener: "buggy", Event: "event", Value: "listener bug", Panics: 1},
+		{Listener: "buggy", Event: "event", Value: "listener bug", Panics: 2},
+	}, panics)
+	assert.Equal(t, 2, g.Len(), "expected listener to stay registered without a limit")
+}
+
+func TestListenerGroupMaxPanics(t *testing.T) {
+	var panics []ListenerPanic
+	g := &ListenerGroup{
+		MaxPanics: 2,
+		OnPanic:   func(p ListenerPanic) { panics = append(panics, p) },
+	}
+	g.Register("buggy", panickingListener{})
+
+	g.Emit("event")
+	assert.Equal(t, 1, g.Len())
+
+	g.Emit("event")
+	assert.Equal(t, 0, g.Len(), "expected listener to be deregistered at the limit")
+	assert.True(t, panics[1].Deregistered)
+
+	g.Emit("event")
+	assert.Len(t, panics, 2)
+}
+
+func TestListenerGroupDeregister(t *testing.T) {
+	var g ListenerGroup
+	counter := &countingListener{}
+	g.Register(ListenerName(counter), counter)
+	g.Register("other", &countingListener{})
+
+	assert.Equal(t, "*events.countingListener", ListenerName(counter))
+	assert.True(t, g.Deregister(ListenerName(counter)))
+	assert.False(t, g.Deregister(ListenerName(counter)))
+
+	g.Emit("event")
+	assert.Equal(t, 0, counter.events)
+	assert.Equal(t, 1, g.Len())
+}
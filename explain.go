@@ -0,0 +1,187 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import "github.com/gl-works/ringpop-go/hashring"
+
+// A SkipReason is why a lookup did not return a member the ring or the
+// routing overrides pointed it to.
+type SkipReason string
+
+const (
+	// SkipSuspect means the owner is suspect and the UnavailablePolicy
+	// skips or rejects suspect owners.
+	SkipSuspect SkipReason = "suspect"
+
+	// SkipOverridden means the key is pinned to another member by the
+	// routing overrides.
+	SkipOverridden SkipReason = "overridden"
+
+	// SkipNotInRing means the key is pinned to a member by the routing
+	// overrides, but the member is not in the ring.
+	SkipNotInRing SkipReason = "not-in-ring"
+)
+
+// A SkippedMember is a member a lookup did not return, and why.
+type SkippedMember struct {
+	Address string     `json:"address"`
+	Reason  SkipReason `json:"reason"`
+}
+
+// A LookupExplanation describes how a lookup determined the owners of a key,
+// for debugging misrouted requests. See ExplainLookup.
+type LookupExplanation struct {
+	Key string `json:"key"`
+
+	// Ring is the trace of the lookup on the ring: the hash of the key, the
+	// checksum of the ring and the tokens visited to find the owners.
+	Ring hashring.LookupTrace `json:"ring"`
+
+	// Policy is the UnavailablePolicy of Lookup, and Order the ReplicaOrder
+	// of LookupN.
+	Policy string `json:"policy,omitempty"`
+	Order  string `json:"order,omitempty"`
+
+	// Override is the member the key is pinned to by the routing overrides,
+	// if any.
+	Override string `json:"override,omitempty"`
+
+	// Skipped are the members the lookup did not return, and why.
+	Skipped []SkippedMember `json:"skipped,omitempty"`
+
+	// Owners are the members the lookup returns, and Error the error it
+	// returns instead, if any.
+	Owners []string `json:"owners"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// skip records that the lookup did not return the member.
+func (e *LookupExplanation) skip(address string, reason SkipReason) {
+	e.Skipped = append(e.Skipped, SkippedMember{Address: address, Reason: reason})
+}
+
+// ExplainLookup looks up the owner of the key like Lookup, and returns the full
+// decision trace instead of just the owner. It does not emit lookup events or
+// stats.
+func (rp *Ringpop) ExplainLookup(key string) (*LookupExplanation, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+
+	policy := rp.config.UnavailableOwner
+	e := &LookupExplanation{
+		Key:    key,
+		Ring:   rp.ring.Trace(key, 1),
+		Policy: policy.String(),
+	}
+
+	if pinned, ok := rp.explainOverride(e); ok {
+		for _, owner := range e.Ring.Owners {
+			if owner != pinned {
+				e.skip(owner, SkipOverridden)
+			}
+		}
+		e.Owners = []string{pinned}
+		return e, nil
+	}
+
+	if len(e.Ring.Owners) == 0 {
+		e.Error = errNoDestination.Error()
+		return e, nil
+	}
+
+	owner := e.Ring.Owners[0]
+	if policy == ReturnUnavailable || !rp.ring.IsSuspectServer(owner) {
+		e.Owners = []string{owner}
+		return e, nil
+	}
+
+	e.skip(owner, SkipSuspect)
+	switch policy {
+	case SkipUnavailable:
+		if standby, ok := rp.ring.LookupStandby(key); ok {
+			e.Owners = []string{standby}
+		} else {
+			e.Error = errNoDestination.Error()
+		}
+	case RejectUnavailable:
+		e.Error = (&OwnerUnavailableError{Key: key, Owner: owner}).Error()
+	}
+	return e, nil
+}
+
+// ExplainLookupN looks up the n owners of the key like LookupN, and returns
+// the full decision trace instead of just the owners. Owners are returned in
+// ring order unless LookupN orders them by latency. It does not emit lookup
+// events or stats.
+func (rp *Ringpop) ExplainLookupN(key string, n int) (*LookupExplanation, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+
+	order := rp.config.LookupNOrder
+	e := &LookupExplanation{
+		Key:   key,
+		Ring:  rp.ring.Trace(key, n),
+		Order: order.String(),
+	}
+
+	servers := append([]string(nil), e.Ring.Owners...)
+	if order == LatencyOrder {
+		rp.orderByLatency(servers)
+	}
+
+	if pinned, ok := rp.explainOverride(e); ok && len(servers) > 0 {
+		// the pinned member takes the place of the last owner when it is
+		// not among the owners
+		dropped := servers[len(servers)-1]
+		for _, server := range servers {
+			if server == pinned {
+				dropped = ""
+				break
+			}
+		}
+		if dropped != "" {
+			e.skip(dropped, SkipOverridden)
+		}
+		servers = pinFirst(servers, pinned)
+	}
+
+	e.Owners = servers
+	return e, nil
+}
+
+// explainOverride returns the member the key is pinned to by the routing
+// overrides, like overrideLookup, and records it in the explanation.
+func (rp *Ringpop) explainOverride(e *LookupExplanation) (string, bool) {
+	pinned, ok := rp.overrides.lookup(e.Key)
+	if !ok {
+		return "", false
+	}
+
+	if !rp.ring.HasServer(pinned) {
+		e.skip(pinned, SkipNotInRing)
+		return "", false
+	}
+
+	e.Override = pinned
+	return pinned, true
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// ErrRingVersionChanged is returned by FenceKey when the ring changed since
+// the version the caller observed.
+var ErrRingVersionChanged = errors.New("ring version changed")
+
+// An OwnershipFence guards an operation on a key that must not complete after
+// ownership of the key moved to another node. Its context is cancelled the
+// moment the ring changes such that this Ringpop instance no longer owns the
+// key.
+type OwnershipFence struct {
+	key    string
+	ctx    context.Context
+	cancel context.CancelFunc
+	rp     *Ringpop
+
+	// lost is closed when ownership of the key is lost.
+	lost chan struct{}
+}
+
+// fences holds the ownership fences registered on a Ringpop instance.
+type fences struct {
+	held map[*OwnershipFence]struct{}
+	sync.Mutex
+}
+
+// RingVersion returns the version of the ring of this Ringpop instance, which
+// is incremented every time membership changes are applied to the ring, see
+// View. It is passed to FenceKey to check ownership of a key at the version a
+// decision was made at.
+func (rp *Ringpop) RingVersion() (uint64, error) {
+	if !rp.Ready() {
+		return 0, rp.errNotReady()
+	}
+
+	rp.view.RLock()
+	defer rp.view.RUnlock()
+	return rp.view.version, nil
+}
+
+// FenceKey checks that this Ringpop instance owns key at ring version
+// version, atomically with respect to changes of the ring and the routing
+// overrides, and registers a fence whose context, derived from ctx, is
+// cancelled as soon as ownership of the key is lost. Ownership is decided as
+// by Lookup. It fails with ErrRingVersionChanged if the ring version is no
+// longer version, and with ErrNotOwner if the key is owned by another node.
+// The fence must be released when the operation completes.
+func (rp *Ringpop) FenceKey(ctx context.Context, key string, version uint64) (*OwnershipFence, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+
+	me, err := rp.identity()
+	if err != nil {
+		return nil, err
+	}
+
+	// the ring cannot change while the view is locked, so the fence is
+	// registered before the next change is revalidated
+	rp.view.RLock()
+	defer rp.view.RUnlock()
+
+	if rp.view.version != version {
+		return nil, ErrRingVersionChanged
+	}
+
+	fence := &OwnershipFence{
+		key:  key,
+		rp:   rp,
+		lost: make(chan struct{}),
+	}
+	fence.ctx, fence.cancel = context.WithCancel(ctx)
+
+	// the routing overrides can change while the view is locked, so the
+	// fence is registered before ownership is checked, and a change of the
+	// overrides after the check revalidates it
+	rp.fences.Lock()
+	if rp.fences.held == nil {
+		rp.fences.held = make(map[*OwnershipFence]struct{})
+	}
+	rp.fences.held[fence] = struct{}{}
+	rp.fences.Unlock()
+
+	if owner, ok := rp.keyOwner(key); !ok || owner != me {
+		fence.Release()
+		return nil, ErrNotOwner
+	}
+
+	return fence, nil
+}
+
+// revalidateFences cancels the fences on all keys that are no longer owned by
+// this Ringpop instance, after the ring or the routing overrides changed.
+func (rp *Ringpop) revalidateFences() {
+	me, err := rp.identity()
+	if err != nil {
+		return
+	}
+
+	var lost []*OwnershipFence
+
+	rp.fences.Lock()
+	for fence := range rp.fences.held {
+		if owner, _ := rp.keyOwner(fence.key); owner != me {
+			delete(rp.fences.held, fence)
+			lost = append(lost, fence)
+		}
+	}
+	rp.fences.Unlock()
+
+	for _, fence := range lost {
+		close(fence.lost)
+		fence.cancel()
+	}
+}
+
+// releaseFences cancels all fences, used when the Ringpop instance is
+// destroyed.
+func (rp *Ringpop) releaseFences() {
+	rp.fences.Lock()
+	held := rp.fences.held
+	rp.fences.held = nil
+	rp.fences.Unlock()
+
+	for fence := range held {
+		fence.cancel()
+	}
+}
+
+// Key returns the key the fence guards.
+func (f *OwnershipFence) Key() string {
+	return f.key
+}
+
+// Context returns the context of the guarded operation, which is cancelled
+// when ownership of the key is lost, the fence is released, or the context
+// the fence was registered with is done.
+func (f *OwnershipFence) Context() context.Context {
+	return f.ctx
+}
+
+// Lost returns a channel that is closed when ownership of the key is lost.
+func (f *OwnershipFence) Lost() <-chan struct{} {
+	return f.lost
+}
+
+// Release unregisters the fence and cancels its context. Releasing a fence
+// more than once has no effect.
+func (f *OwnershipFence) Release() {
+	f.rp.fences.Lock()
+	delete(f.rp.fences.held, f)
+	f.rp.fences.Unlock()
+
+	f.cancel()
+}
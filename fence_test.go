@@ -0,0 +1,157 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gl-works/ringpop-go/events"
+	"golang.org/x/net/context"
+)
+
+func (s *RingpopTestSuite) TestFenceKeyNotReady() {
+	_, err := s.ringpop.RingVersion()
+	s.Equal(ErrNotBootstrapped, err)
+
+	fence, err := s.ringpop.FenceKey(context.Background(), "foo", 0)
+	s.Equal(ErrNotBootstrapped, err)
+	s.Nil(fence)
+}
+
+func (s *RingpopTestSuite) TestFenceKey() {
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+
+	version, err := s.ringpop.RingVersion()
+	s.Require().NoError(err)
+
+	fence, err := s.ringpop.FenceKey(context.Background(), "foo", version)
+	s.Require().NoError(err)
+	s.Equal("foo", fence.Key())
+	s.NoError(fence.Context().Err())
+
+	fence.Release()
+	s.Error(fence.Context().Err(), "expected released fence to be cancelled")
+	select {
+	case <-fence.Lost():
+		s.Fail("expected released fence not to be lost")
+	default:
+	}
+
+	_, err = s.ringpop.FenceKey(context.Background(), "foo", version+1)
+	s.Equal(ErrRingVersionChanged, err)
+}
+
+func (s *RingpopTestSuite) TestFenceKeyLostOnOwnershipChange() {
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+
+	version, err := s.ringpop.RingVersion()
+	s.Require().NoError(err)
+
+	var fences []*OwnershipFence
+	for i := 0; i < 20; i++ {
+		fence, err := s.ringpop.FenceKey(context.Background(), fmt.Sprintf("key%d", i), version)
+		s.Require().NoError(err)
+		fences = append(fences, fence)
+	}
+
+	s.ringpop.ring.AddRemoveServers(genAddresses(1, 2, 11), nil)
+
+	me, _ := s.ringpop.WhoAmI()
+	var lost int
+	for _, fence := range fences {
+		owner, _ := s.ringpop.ring.Lookup(fence.Key())
+		if owner == me {
+			s.NoError(fence.Context().Err(), "expected fence on owned key to hold")
+			fence.Release()
+			continue
+		}
+
+		lost++
+		s.Equal(context.Canceled, fence.Context().Err(), "expected fence on moved key to be cancelled")
+		select {
+		case <-fence.Lost():
+		default:
+			s.Fail("expected lost channel to be closed")
+		}
+	}
+	s.NotZero(lost, "expected ownership of some keys to move")
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if owner, _ := s.ringpop.ring.Lookup(key); owner != me {
+			_, err := s.ringpop.FenceKey(context.Background(), key, version)
+			s.Equal(ErrNotOwner, err)
+			break
+		}
+	}
+}
+
+func (s *RingpopTestSuite) TestFenceKeyReleasedOnDestroy() {
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+
+	version, err := s.ringpop.RingVersion()
+	s.Require().NoError(err)
+	fence, err := s.ringpop.FenceKey(context.Background(), "foo", version)
+	s.Require().NoError(err)
+
+	s.ringpop.Destroy()
+	s.Error(fence.Context().Err())
+}
+
+func (s *RingpopTestSuite) TestFenceKeyRoutingOverrides() {
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+	other := "127.0.0.1:3002"
+	s.ringpop.ring.AddServer(other)
+
+	me, _ := s.ringpop.WhoAmI()
+	var mine, theirs string
+	for i := 0; mine == "" || theirs == ""; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if owner, _ := s.ringpop.ring.Lookup(key); owner == me {
+			mine = key
+		} else {
+			theirs = key
+		}
+	}
+
+	version, err := s.ringpop.RingVersion()
+	s.Require().NoError(err)
+	fence, err := s.ringpop.FenceKey(context.Background(), mine, version)
+	s.Require().NoError(err)
+
+	s.ringpop.overrides.set(RoutingPins{Keys: map[string]string{mine: other, theirs: me}}, time.Unix(1000, 0))
+	s.ringpop.HandleEvent(events.RoutingOverridesReloadedEvent{Keys: 2})
+
+	s.Equal(context.Canceled, fence.Context().Err(), "expected fence on key pinned away to be cancelled")
+	select {
+	case <-fence.Lost():
+	default:
+		s.Fail("expected lost channel to be closed")
+	}
+
+	_, err = s.ringpop.FenceKey(context.Background(), mine, version)
+	s.Equal(ErrNotOwner, err, "expected key pinned to another member not to be owned")
+
+	fence, err = s.ringpop.FenceKey(context.Background(), theirs, version)
+	s.Require().NoError(err, "expected key pinned to this member to be owned")
+	fence.Release()
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"golang.org/x/net/context"
+)
+
+// A BatchItem is a request of a batch, which is routed by its key.
+type BatchItem struct {
+	Key     string
+	Payload []byte
+}
+
+// A BatchResult is the response to the BatchItem at the same index of a
+// batch. Err is set if the item could not be routed or forwarded, or if the
+// handler of the item failed.
+type BatchResult struct {
+	Key         string
+	Destination string
+	Payload     []byte
+	Err         error
+}
+
+// batchRequest is the envelope of the items of a batch forwarded to a single
+// destination.
+type batchRequest struct {
+	Items []batchItem `json:"items"`
+}
+
+type batchItem struct {
+	Key     string `json:"key"`
+	Payload []byte `json:"payload"`
+}
+
+// batchResponse is the envelope of the results of a batchRequest, in the
+// order of its items.
+type batchResponse struct {
+	Results []batchItemResult `json:"results"`
+}
+
+type batchItemResult struct {
+	Payload []byte `json:"payload,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ForwardBatch forwards many small requests with one call per destination.
+// The items are grouped by the owners of their keys and the items of every
+// owner are forwarded to it in a single JSON encoded envelope, which the
+// endpoint unpacks with BatchHandler. The calls to the owners are made
+// concurrently and retried like other forwarded requests. Items owned by the
+// local node are forwarded to it as well.
+//
+// The results are returned in the order of the items. The failure of an item,
+// or of the call that carried it, is reported in the Err of its result.
+func (f *Forwarder) ForwardBatch(ctx context.Context, items []BatchItem, service, endpoint string,
+	opts *Options) []BatchResult {
+
+	results := make([]BatchResult, len(items))
+	groups := make(map[string][]int)
+	for i, item := range items {
+		results[i].Key = item.Key
+
+		dest, err := f.sender.Lookup(item.Key)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Destination = dest
+		groups[dest] = append(groups[dest], i)
+	}
+
+	var wg sync.WaitGroup
+	for dest, indices := range groups {
+		wg.Add(1)
+		go func(dest string, indices []int) {
+			defer wg.Done()
+			f.forwardBatchGroup(ctx, dest, items, indices, results, service, endpoint, opts)
+		}(dest, indices)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// forwardBatchGroup forwards the items at the given indices to the
+// destination and stores their results.
+func (f *Forwarder) forwardBatchGroup(ctx context.Context, dest string, items []BatchItem, indices []int,
+	results []BatchResult, service, endpoint string, opts *Options) {
+
+	fail := func(err error) {
+		for _, i := range indices {
+			results[i].Err = err
+		}
+	}
+
+	req := batchRequest{Items: make([]batchItem, len(indices))}
+	keys := make([]string, len(indices))
+	for j, i := range indices {
+		req.Items[j] = batchItem{Key: items[i].Key, Payload: items[i].Payload}
+		keys[j] = items[i].Key
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	resBody, err := f.ForwardRequestContext(ctx, body, dest, service, endpoint, keys, tchannel.JSON, opts)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	var res batchResponse
+	if err := json.Unmarshal(resBody, &res); err != nil {
+		fail(&MalformedResponseError{Endpoint: endpoint, Err: err})
+		return
+	}
+	if len(res.Results) != len(indices) {
+		fail(&MalformedResponseError{
+			Endpoint: endpoint,
+			Err:      errors.New("number of results does not match the batch"),
+		})
+		return
+	}
+
+	for j, i := range indices {
+		results[i].Payload = res.Results[j].Payload
+		if res.Results[j].Error != "" {
+			results[i].Err = errors.New(res.Results[j].Error)
+		}
+	}
+}
+
+// A BatchItemHandler handles a single item of a batch forwarded with
+// ForwardBatch and returns its response.
+type BatchItemHandler func(ctx context.Context, key string, payload []byte) ([]byte, error)
+
+// BatchHandler returns a raw handler for the endpoint batches are forwarded
+// to with ForwardBatch. It unpacks a batch, passes its items to the handler
+// one by one, and responds with their results. The error of an item is
+// returned as the error of its result, without failing the other items.
+//
+// Example:
+//
+//     ch.Register(raw.Wrap(forward.BatchHandler(handleItem)), "/batch")
+//
+func BatchHandler(handler BatchItemHandler) raw.Handler {
+	return batchHandler(handler)
+}
+
+type batchHandler BatchItemHandler
+
+func (h batchHandler) Handle(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+	var req batchRequest
+	if err := json.Unmarshal(args.Arg3, &req); err != nil {
+		// application errors are JSON encoded like those of JSON handlers,
+		// so that the forwarder can decode them
+		message, _ := json.Marshal(map[string]string{
+			"type":    "error",
+			"message": err.Error(),
+		})
+		return &raw.Res{IsErr: true, Arg3: message}, nil
+	}
+
+	res := batchResponse{Results: make([]batchItemResult, len(req.Items))}
+	for i, item := range req.Items {
+		payload, err := h(ctx, item.Key, item.Payload)
+		if err != nil {
+			res.Results[i].Error = err.Error()
+			continue
+		}
+		res.Results[i].Payload = payload
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return &raw.Res{Arg3: body}, nil
+}
+
+func (h batchHandler) OnError(ctx context.Context, err error) {}
@@ -0,0 +1,151 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrNoCodec is returned by RequestKeys when no codec is registered for the
+// endpoint.
+var ErrNoCodec = errors.New("no codec registered for endpoint")
+
+// A Codec describes the encoding of the payloads forwarded to an endpoint, so
+// the forwarder can look into them instead of treating every payload as
+// opaque bytes. Codecs are registered per endpoint with EndpointOptions.
+// Applications can implement the interface for encodings that are not
+// provided by this package, such as protobuf.
+type Codec interface {
+	// Name identifies the encoding, for example in size metrics.
+	Name() string
+
+	// Keys extracts the keys a request is routed by. Codecs that cannot
+	// extract keys return nil.
+	Keys(request []byte) ([]string, error)
+
+	// Compressible reports whether the payload is worth compressing.
+	Compressible(payload []byte) bool
+}
+
+// RawCodec is the codec for payloads that are opaque bytes.
+type RawCodec struct{}
+
+// Name returns "raw".
+func (RawCodec) Name() string { return "raw" }
+
+// Keys returns no keys, as raw payloads have no structure.
+func (RawCodec) Keys(request []byte) ([]string, error) { return nil, nil }
+
+// Compressible always returns false.
+func (RawCodec) Compressible(payload []byte) bool { return false }
+
+// ThriftCodec is the codec for thrift binary encoded payloads. It does not
+// extract keys, as this requires knowledge of the thrift IDL.
+type ThriftCodec struct {
+	// MinCompressSize is the size in bytes from which payloads are
+	// considered worth compressing. Zero means payloads are never
+	// compressed.
+	MinCompressSize int
+}
+
+// Name returns "thrift".
+func (ThriftCodec) Name() string { return "thrift" }
+
+// Keys returns no keys.
+func (ThriftCodec) Keys(request []byte) ([]string, error) { return nil, nil }
+
+// Compressible returns true for payloads of at least MinCompressSize bytes.
+func (c ThriftCodec) Compressible(payload []byte) bool {
+	return c.MinCompressSize > 0 && len(payload) >= c.MinCompressSize
+}
+
+// JSONCodec is the codec for JSON encoded payloads.
+type JSONCodec struct {
+	// KeyField is the top-level field of a request object that holds its
+	// key, either as a string or as an array of strings. Requests are not
+	// routed by their content if it is empty.
+	KeyField string
+
+	// MinCompressSize is the size in bytes from which payloads are
+	// considered worth compressing. Zero means payloads are never
+	// compressed.
+	MinCompressSize int
+}
+
+// Name returns "json".
+func (JSONCodec) Name() string { return "json" }
+
+// Keys returns the key or keys in the KeyField of the request.
+func (c JSONCodec) Keys(request []byte) ([]string, error) {
+	if c.KeyField == "" {
+		return nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(request, &fields); err != nil {
+		return nil, err
+	}
+
+	raw, ok := fields[c.KeyField]
+	if !ok {
+		return nil, fmt.Errorf("request has no %q field", c.KeyField)
+	}
+
+	var key string
+	if err := json.Unmarshal(raw, &key); err == nil {
+		return []string{key}, nil
+	}
+
+	var keys []string
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, fmt.Errorf("field %q is neither a string nor an array of strings",
+			c.KeyField)
+	}
+	return keys, nil
+}
+
+// Compressible returns true for payloads of at least MinCompressSize bytes.
+func (c JSONCodec) Compressible(payload []byte) bool {
+	return c.MinCompressSize > 0 && len(payload) >= c.MinCompressSize
+}
+
+// codec returns the codec registered for the endpoint, or nil.
+func (f *Forwarder) codec(service, endpoint string) Codec {
+	f.endpoints.RLock()
+	defer f.endpoints.RUnlock()
+
+	if opts, ok := f.endpoints.endpoints[endpointKey(service, endpoint)]; ok {
+		return opts.Codec
+	}
+	return nil
+}
+
+// RequestKeys extracts the routing keys of a request to the endpoint with the
+// codec registered for it. It returns ErrNoCodec if the endpoint has no codec.
+func (f *Forwarder) RequestKeys(request []byte, service, endpoint string) ([]string, error) {
+	codec := f.codec(service, endpoint)
+	if codec == nil {
+		return nil, ErrNoCodec
+	}
+	return codec.Keys(request)
+}
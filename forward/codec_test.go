@@ -0,0 +1,61 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONCodecKeys(t *testing.T) {
+	codec := JSONCodec{KeyField: "key"}
+
+	keys, err := codec.Keys([]byte(`{"key":"a","value":1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+
+	keys, err = codec.Keys([]byte(`{"key":["a","b"]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, keys)
+
+	_, err = codec.Keys([]byte(`{"value":1}`))
+	assert.Error(t, err, "expected error for missing key field")
+
+	_, err = codec.Keys([]byte(`{"key":1}`))
+	assert.Error(t, err, "expected error for key of wrong type")
+
+	_, err = codec.Keys([]byte(`not json`))
+	assert.Error(t, err, "expected error for invalid request")
+
+	keys, err = JSONCodec{}.Keys([]byte(`{"key":"a"}`))
+	assert.NoError(t, err)
+	assert.Nil(t, keys, "expected no keys without a key field")
+}
+
+func TestCodecCompressible(t *testing.T) {
+	payload := make([]byte, 100)
+
+	assert.False(t, RawCodec{}.Compressible(payload))
+	assert.False(t, JSONCodec{}.Compressible(payload), "expected no compression without a minimum size")
+	assert.True(t, JSONCodec{MinCompressSize: 100}.Compressible(payload))
+	assert.False(t, ThriftCodec{MinCompressSize: 101}.Compressible(payload))
+}
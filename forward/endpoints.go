@@ -0,0 +1,135 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// EndpointOptions configure how requests to a registered endpoint are
+// forwarded.
+type EndpointOptions struct {
+	// Timeout, MaxRetries and RetrySchedule override the forwarder defaults
+	// for requests to the endpoint. Options passed to ForwardRequest still
+	// take precedence.
+	Timeout       time.Duration
+	MaxRetries    int
+	RetrySchedule []time.Duration
+
+	// Validator, if set, is called with every request before it is
+	// forwarded to the endpoint. Requests for which it returns an error are
+	// rejected with a ValidationError.
+	Validator func(request []byte) error
+
+	// ContextValidator is like Validator, but is also passed the context
+	// of the request, so that it can take part in tracing and cancellation.
+	// It is called after Validator.
+	ContextValidator func(ctx context.Context, request []byte) error
+
+	// Codec, if set, describes the encoding of the payloads of the
+	// endpoint. It is used to extract the keys of requests that are
+	// forwarded without keys, and to report payload sizes and compression
+	// decisions per encoding.
+	Codec Codec
+}
+
+// endpointRegistry holds the endpoints that are allowed to be forwarded to.
+type endpointRegistry struct {
+	endpoints map[string]*EndpointOptions
+	sync.RWMutex
+}
+
+func endpointKey(service, endpoint string) string {
+	return service + "::" + endpoint
+}
+
+// RegisterEndpoint registers an endpoint of a service as forwardable. Once at
+// least one endpoint is registered, the forwarder only forwards requests to
+// registered endpoints and rejects all others with an
+// UnregisteredEndpointError. When no endpoints are registered every endpoint
+// can be forwarded to. The options may be nil.
+func (f *Forwarder) RegisterEndpoint(service, endpoint string, opts *EndpointOptions) {
+	if opts == nil {
+		opts = &EndpointOptions{}
+	}
+
+	f.endpoints.Lock()
+	if f.endpoints.endpoints == nil {
+		f.endpoints.endpoints = make(map[string]*EndpointOptions)
+	}
+	f.endpoints.endpoints[endpointKey(service, endpoint)] = opts
+	f.endpoints.Unlock()
+}
+
+// checkEndpoint returns the options of the endpoint a request is forwarded to
+// after validating the request against them. The options are nil if the
+// allow-list is not in use.
+func (f *Forwarder) checkEndpoint(ctx context.Context, request []byte, service, endpoint string) (*EndpointOptions, error) {
+	opts, err := f.endpointOptions(service, endpoint)
+	if opts == nil || err != nil {
+		return opts, err
+	}
+
+	if opts.Validator != nil {
+		if err := opts.Validator(request); err != nil {
+			return nil, &ValidationError{
+				Endpoint: endpoint,
+				Err:      err,
+			}
+		}
+	}
+
+	if opts.ContextValidator != nil {
+		if err := opts.ContextValidator(ctx, request); err != nil {
+			return nil, &ValidationError{
+				Endpoint: endpoint,
+				Err:      err,
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// endpointOptions returns the options of the endpoint a request is forwarded
+// to, or an UnregisteredEndpointError if the endpoint is not on the
+// allow-list. The options are nil if the allow-list is not in use.
+func (f *Forwarder) endpointOptions(service, endpoint string) (*EndpointOptions, error) {
+	f.endpoints.RLock()
+	numEndpoints := len(f.endpoints.endpoints)
+	opts, ok := f.endpoints.endpoints[endpointKey(service, endpoint)]
+	f.endpoints.RUnlock()
+
+	if numEndpoints == 0 {
+		return nil, nil
+	}
+
+	if !ok {
+		return nil, &UnregisteredEndpointError{
+			Service:  service,
+			Endpoint: endpoint,
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import "fmt"
+
+// A SizeLimitError is returned when a forwarded request or its response
+// exceeds the size limit configured in the forwarding Options.
+type SizeLimitError struct {
+	// Endpoint is the endpoint the request was forwarded to.
+	Endpoint string
+
+	// Response is true if the response exceeded the limit, false if the
+	// request did.
+	Response bool
+
+	// Size is the size of the payload in bytes. Responses are only read up
+	// to one byte past the limit, so for a response it is the number of
+	// bytes read.
+	Size int
+
+	// Limit is the configured maximum size in bytes.
+	Limit int
+}
+
+func (e *SizeLimitError) Error() string {
+	payload := "request"
+	if e.Response {
+		payload = "response"
+	}
+	return fmt.Sprintf("forwarded %s to %s of %d bytes exceeds limit of %d bytes",
+		payload, e.Endpoint, e.Size, e.Limit)
+}
+
+// An UnregisteredEndpointError is returned when a request is forwarded to an
+// endpoint that was not registered with Forwarder.RegisterEndpoint.
+type UnregisteredEndpointError struct {
+	Service  string
+	Endpoint string
+}
+
+func (e *UnregisteredEndpointError) Error() string {
+	return fmt.Sprintf("endpoint %s of service %s is not registered for forwarding",
+		e.Endpoint, e.Service)
+}
+
+// A ValidationError is returned when the validator registered for an endpoint
+// rejects a request.
+type ValidationError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid request for %s: %v", e.Endpoint, e.Err)
+}
+
+// A MalformedResponseError is returned when the destination of a forwarded
+// request responded with an application error that could not be decoded.
+type MalformedResponseError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *MalformedResponseError) Error() string {
+	return fmt.Sprintf("malformed error response from %s: %v", e.Endpoint, e.Err)
+}
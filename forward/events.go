@@ -1,10 +1,16 @@
 package forward
 
-import "github.com/gl-works/ringpop-go/events"
+import (
+	"time"
+
+	"github.com/gl-works/ringpop-go/events"
+	"golang.org/x/net/context"
+)
 
 // An EventListener handles events given to it by the SWIM node. HandleEvent should be thread safe.
 type eventEmitter interface {
 	emit(events.Event)
+	emitContext(context.Context, events.Event)
 }
 
 // A RequestForwardedEvent is emitted for every forwarded request
@@ -60,3 +66,62 @@ type RerouteEvent struct {
 type RetrySuccessEvent struct {
 	NumRetries int
 }
+
+// A BytesForwardedEvent is emitted after a forwarded request completed
+// successfully and contains the sizes of the request and response. Codec is
+// the name of the codec registered for the endpoint, if any, and Compressible
+// whether that codec considered the request worth compressing
+type BytesForwardedEvent struct {
+	Endpoint      string
+	RequestBytes  int
+	ResponseBytes int
+	Codec         string
+	Compressible  bool
+}
+
+// A PushbackReceivedEvent is emitted when the destination of a forwarded
+// request signaled that it is overloaded and asked to be backed off from
+type PushbackReceivedEvent struct {
+	Destination string
+	RetryAfter  time.Duration
+}
+
+// A ConnectionFailedEvent is emitted when a forwarded request failed because
+// the destination refused or dropped the connection
+type ConnectionFailedEvent struct {
+	Destination string
+}
+
+// A HandlerPanicEvent is emitted when a handler wrapped with WrapHandler
+// panicked and the caller was sent an error with the given correlation ID
+type HandlerPanicEvent struct {
+	Endpoint      string
+	CorrelationID string
+	Value         interface{}
+}
+
+// A ConcurrencyLimitedEvent is emitted when a request is not forwarded because
+// its destination has reached the limit of concurrent requests
+type ConcurrencyLimitedEvent struct {
+	Destination string
+	Queued      bool
+}
+
+// A RingDivergedEvent is emitted when the destination of a forwarded request
+// responded with a ring proof whose checksum differs from the local ring
+type RingDivergedEvent struct {
+	Destination string
+	Checksum    uint32
+	Proof       RingProof
+}
+
+// A DivergedRingDetectedEvent is emitted when a request is not forwarded
+// because it was forwarded back to its origin or would exceed the maximum
+// number of hops, which means the nodes it passed through disagree on the
+// owner of its keys
+type DivergedRingDetectedEvent struct {
+	Origin      string
+	Destination string
+	Hops        int
+	Keys        []string
+}
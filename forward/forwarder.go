@@ -23,6 +23,7 @@ package forward
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/uber-common/bark"
@@ -32,6 +33,7 @@ import (
 	"github.com/gl-works/ringpop-go/util"
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/thrift"
+	"golang.org/x/net/context"
 )
 
 // A Sender is used to route the request to the proper destination,
@@ -44,12 +46,69 @@ type Sender interface {
 	Lookup(string) (string, error)
 }
 
+// An AddressResolver can optionally be implemented by a Sender whose
+// destinations are reachable at other addresses than the ones returned by
+// Lookup. DialAddress returns the address to connect to for a destination.
+type AddressResolver interface {
+	DialAddress(destination string) string
+}
+
+// A MeshRouter can optionally be implemented by a Sender whose destinations
+// are reached through a service mesh. Requests are sent to the sidecar with
+// the headers it routes them by, see shared.Mesh. Mesh returns nil when the
+// sender does not use a mesh.
+type MeshRouter interface {
+	Mesh() *shared.Mesh
+}
+
 // Options for the creation of a forwarder
 type Options struct {
-	MaxRetries     int
+	MaxRetries int
+
+	// RerouteRetries looks up the keys of the request again before every
+	// retry and sends the retry to their current destination.
 	RerouteRetries bool
-	RetrySchedule  []time.Duration
-	Timeout        time.Duration
+
+	// RetryTimedOutOnReroute retries a call that timed out when its keys
+	// moved to another destination during the call, e.g. because the
+	// destination became faulty mid-flight. It requires RerouteRetries. The
+	// timed out call may still have been executed by the old destination,
+	// so the request can be executed twice; only enable it for idempotent
+	// requests.
+	RetryTimedOutOnReroute bool
+
+	RetrySchedule []time.Duration
+	Timeout       time.Duration
+
+	// MaxRequestSize is the maximum size in bytes of a request that will be
+	// forwarded. Larger requests are rejected with a SizeLimitError before
+	// they are sent. Zero means no limit.
+	MaxRequestSize int
+
+	// MaxResponseSize is the maximum size in bytes of a response that will be
+	// returned to the caller. Larger responses are dropped while they are
+	// read and a SizeLimitError is returned instead. Zero means no limit.
+	MaxResponseSize int
+
+	// VerifyRing treats responses whose ring proof differs from the local
+	// ring as failed attempts, which are retried, and returns a
+	// RingDivergedError when the retries are exhausted. Responses without a
+	// ring proof are not verified, see SetRingProofHeaders.
+	VerifyRing bool
+
+	// MaxHops is the maximum number of times a request is forwarded between
+	// nodes. A request that would exceed it, or that is forwarded back to the
+	// node it originated from, is not forwarded and a ForwardingLoopError is
+	// returned instead, so that nodes whose rings disagree do not bounce
+	// requests between them.
+	MaxHops int
+
+	// Headers are application headers the request is forwarded with, e.g.
+	// the headers of the request that is being routed, so that the
+	// destination sees the headers the request was received with. They are
+	// sent in the format of the request along with the headers ringpop
+	// adds, and are not sent with raw requests.
+	Headers map[string]string
 }
 
 func (f *Forwarder) defaultOptions() *Options {
@@ -57,12 +116,21 @@ func (f *Forwarder) defaultOptions() *Options {
 		MaxRetries:    3,
 		RetrySchedule: []time.Duration{3 * time.Second, 6 * time.Second, 12 * time.Second},
 		Timeout:       3 * time.Second,
+		MaxHops:       3,
 	}
 }
 
-func (f *Forwarder) mergeDefaultOptions(opts *Options) *Options {
+func (f *Forwarder) mergeDefaultOptions(opts *Options, endpoint *EndpointOptions) *Options {
 	def := f.defaultOptions()
 
+	if endpoint != nil {
+		def.Timeout = util.SelectDuration(endpoint.Timeout, def.Timeout)
+		def.MaxRetries = util.SelectInt(endpoint.MaxRetries, def.MaxRetries)
+		if endpoint.RetrySchedule != nil {
+			def.RetrySchedule = endpoint.RetrySchedule
+		}
+	}
+
 	if opts == nil {
 		return def
 	}
@@ -71,7 +139,13 @@ func (f *Forwarder) mergeDefaultOptions(opts *Options) *Options {
 
 	merged.MaxRetries = util.SelectInt(opts.MaxRetries, def.MaxRetries)
 	merged.Timeout = util.SelectDuration(opts.Timeout, def.Timeout)
+	merged.MaxHops = util.SelectInt(opts.MaxHops, def.MaxHops)
 	merged.RerouteRetries = opts.RerouteRetries
+	merged.RetryTimedOutOnReroute = opts.RetryTimedOutOnReroute
+	merged.MaxRequestSize = opts.MaxRequestSize
+	merged.MaxResponseSize = opts.MaxResponseSize
+	merged.VerifyRing = opts.VerifyRing
+	merged.Headers = opts.Headers
 
 	merged.RetrySchedule = opts.RetrySchedule
 	if opts.RetrySchedule == nil {
@@ -83,13 +157,30 @@ func (f *Forwarder) mergeDefaultOptions(opts *Options) *Options {
 
 // A Forwarder is used to forward requests to their destinations
 type Forwarder struct {
-	sender  Sender
-	channel shared.SubChannel
-	logger  log.Logger
+	sender    Sender
+	channel   shared.SubChannel
+	transport Transport
+	logger    log.Logger
 
 	inflightLock sync.Mutex
 	inflight     int64
 
+	// backoff contains the destinations that signaled pushback and the time
+	// until which no requests are forwarded to them.
+	backoff struct {
+		until map[string]time.Time
+		sync.Mutex
+	}
+
+	// endpoints is the allow-list of endpoints requests can be forwarded to.
+	endpoints endpointRegistry
+
+	// limiter bounds the number of concurrent requests per destination.
+	limiter destinationLimiter
+
+	// retriesDisabled is 1 while retries are disabled, see SetRetries.
+	retriesDisabled int32
+
 	listeners []events.EventListener
 }
 
@@ -102,15 +193,22 @@ func NewForwarder(s Sender, ch shared.SubChannel) *Forwarder {
 	}
 
 	return &Forwarder{
-		sender:  s,
-		channel: ch,
-		logger:  logger,
+		sender:    s,
+		channel:   ch,
+		transport: &channelTransport{ch, s},
+		logger:    logger,
 	}
 }
 
 func (f *Forwarder) emit(event events.Event) {
+	f.emitContext(context.Background(), event)
+}
+
+// emitContext emits an event about a request with the context of the request,
+// see events.ContextEventListener.
+func (f *Forwarder) emitContext(ctx context.Context, event events.Event) {
 	for _, listener := range f.listeners {
-		go listener.HandleEvent(event)
+		go events.Notify(ctx, listener, event)
 	}
 }
 
@@ -120,6 +218,23 @@ func (f *Forwarder) RegisterListener(l events.EventListener) {
 	f.listeners = append(f.listeners, l)
 }
 
+// SetRetries enables or disables retries of forwarded requests. While retries
+// are disabled, a request fails after its first attempt regardless of
+// MaxRetries, which keeps retries from adding load during an incident.
+func (f *Forwarder) SetRetries(enabled bool) {
+	var disabled int32
+	if !enabled {
+		disabled = 1
+	}
+	atomic.StoreInt32(&f.retriesDisabled, disabled)
+}
+
+// RetriesEnabled returns whether forwarded requests are retried, see
+// SetRetries.
+func (f *Forwarder) RetriesEnabled() bool {
+	return atomic.LoadInt32(&f.retriesDisabled) == 0
+}
+
 func (f *Forwarder) incrementInflight() {
 	f.inflightLock.Lock()
 	f.inflight++
@@ -154,18 +269,92 @@ func (f *Forwarder) decrementInflight() {
 func (f *Forwarder) ForwardRequest(request []byte, destination, service, endpoint string,
 	keys []string, format tchannel.Format, opts *Options) ([]byte, error) {
 
-	f.emit(RequestForwardedEvent{})
+	return f.ForwardRequestContext(context.Background(), request, destination, service, endpoint, keys, format, opts)
+}
+
+// ForwardRequestContext is ForwardRequest for a request made in the given
+// context. The request is abandoned when the context is cancelled, its timeout
+// is capped by the deadline of the context, and the context is passed to the
+// endpoint's ContextValidator and to listeners of the events about the
+// request that implement events.ContextEventListener.
+func (f *Forwarder) ForwardRequestContext(ctx context.Context, request []byte, destination, service, endpoint string,
+	keys []string, format tchannel.Format, opts *Options) ([]byte, error) {
+
+	f.emitContext(ctx, RequestForwardedEvent{})
+
+	endpointOpts, err := f.checkEndpoint(ctx, request, service, endpoint)
+	if err != nil {
+		f.emitContext(ctx, FailedEvent{})
+		return nil, err
+	}
+
+	opts = f.mergeDefaultOptions(opts, endpointOpts)
+	if !f.RetriesEnabled() {
+		opts.MaxRetries = 0
+	}
+	if opts.MaxRequestSize > 0 && len(request) > opts.MaxRequestSize {
+		f.emitContext(ctx, FailedEvent{})
+		return nil, &SizeLimitError{
+			Endpoint: endpoint,
+			Size:     len(request),
+			Limit:    opts.MaxRequestSize,
+		}
+	}
+
+	hops, origin, err := f.checkHops(ctx, destination, keys, opts.MaxHops)
+	if err != nil {
+		f.emitContext(ctx, FailedEvent{})
+		return nil, err
+	}
+
+	if remaining := f.backoffRemaining(destination); remaining > 0 {
+		f.emitContext(ctx, FailedEvent{})
+		return nil, &PushbackError{
+			Destination: destination,
+			RetryAfter:  remaining,
+		}
+	}
+
+	var codec Codec
+	if endpointOpts != nil {
+		codec = endpointOpts.Codec
+	}
+
+	// without keys a retry cannot look up a new destination, so extract them
+	// from the request when the codec is able to
+	if len(keys) == 0 && codec != nil {
+		if codecKeys, err := codec.Keys(request); err == nil {
+			keys = codecKeys
+		}
+	}
 
 	f.incrementInflight()
-	opts = f.mergeDefaultOptions(opts)
 	rs := newRequestSender(f.sender, f, f.channel, request, keys, destination, service, endpoint, format, opts)
+	rs.limiter = &f.limiter
+	rs.transport = f.transport
+	rs.ctx = ctx
+	rs.hops, rs.origin = hops, origin
 	b, err := rs.Send()
 	f.decrementInflight()
 
+	if rs.pushback > 0 {
+		f.recordPushback(rs.destination, rs.pushback)
+	}
+
 	if err != nil {
-		f.emit(FailedEvent{})
+		f.emitContext(ctx, FailedEvent{})
 	} else {
-		f.emit(SuccessEvent{})
+		f.emitContext(ctx, SuccessEvent{})
+		event := BytesForwardedEvent{
+			Endpoint:      endpoint,
+			RequestBytes:  len(request),
+			ResponseBytes: len(b),
+		}
+		if codec != nil {
+			event.Codec = codec.Name()
+			event.Compressible = codec.Compressible(request)
+		}
+		f.emitContext(ctx, event)
 	}
 
 	return b, err
@@ -24,16 +24,23 @@ import (
 	"bytes"
 	json2 "encoding/json"
 	"errors"
+	"io/ioutil"
+	"net"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	athrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/gl-works/ringpop-go/shared"
 	"github.com/gl-works/ringpop-go/test/thrift/pingpong"
 	"github.com/uber/tchannel-go"
 	"github.com/uber/tchannel-go/json"
+	"github.com/uber/tchannel-go/raw"
 	"github.com/uber/tchannel-go/thrift"
 	"golang.org/x/net/context"
 )
@@ -68,6 +75,33 @@ func (s *ForwarderTestSuite) registerPong(address string, channel *tchannel.Chan
 		"/error": func(ctx json.Context, ping *Ping) (*Pong, error) {
 			return nil, errors.New("remote error")
 		},
+		"/mesh": func(ctx json.Context, ping *Ping) (*Pong, error) {
+			call := tchannel.CurrentCall(ctx)
+			return &Pong{call.ShardKey() + " via " + call.RoutingDelegate(), address}, nil
+		},
+		"/overloaded": func(ctx json.Context, ping *Ping) (*Pong, error) {
+			SetPushbackHeaders(ctx, time.Minute, 0.9)
+			return &Pong{"Slow down!", address}, nil
+		},
+		"/echo": func(ctx json.Context, ping *Ping) (*Pong, error) {
+			return &Pong{ping.Message, address}, nil
+		},
+		"/hops": func(ctx json.Context, ping *Ping) (*Pong, error) {
+			headers := ctx.Headers()
+			return &Pong{headers[hopsHeaderName] + " from " + headers[originHeaderName], address}, nil
+		},
+		"/proof": func(ctx json.Context, ping *Ping) (*Pong, error) {
+			SetRingProofHeaders(ctx, RingProof{Checksum: 42, Version: 7})
+			return &Pong{"Hello, world!", address}, nil
+		},
+		"/proof/versioned": func(ctx json.Context, ping *Ping) (*Pong, error) {
+			SetRingProofHeaders(ctx, RingProof{
+				Checksum:  42,
+				Checksums: map[string]uint32{"v1": 42, "v2": 43},
+				Version:   7,
+			})
+			return &Pong{"Hello, world!", address}, nil
+		},
 	}
 	s.Require().NoError(json.Register(channel, hmap, func(ctx context.Context, err error) {}))
 
@@ -85,6 +119,13 @@ func (s *ForwarderTestSuite) registerPong(address string, channel *tchannel.Chan
 		Key: "error",
 	}).Return(nil, &pingpong.PingError{})
 
+	channel.Register(raw.Wrap(BatchHandler(func(ctx context.Context, key string, payload []byte) ([]byte, error) {
+		if key == "fail" {
+			return nil, errors.New("item failed")
+		}
+		return []byte(key + "=" + string(payload)), nil
+	})), "/batch")
+
 	server := thrift.NewServer(channel)
 	server.Register(pingpong.NewTChanPingPongServer(thriftHandler))
 }
@@ -168,6 +209,231 @@ func (s *ForwarderTestSuite) TestForwardJSONInvalidEndpoint() {
 	s.EqualError(err, "max retries exceeded")
 }
 
+func (s *ForwarderTestSuite) TestForwardRequestTooLarge() {
+	var ping Ping
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	request := ping.Bytes()
+	_, err = s.forwarder.ForwardRequest(request, dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, &Options{MaxRequestSize: len(request) - 1})
+
+	s.Require().IsType(&SizeLimitError{}, err, "expected a size limit error")
+	sizeErr := err.(*SizeLimitError)
+	s.False(sizeErr.Response, "expected the request to exceed the limit")
+	s.Equal(len(request), sizeErr.Size)
+	s.Equal("/ping", sizeErr.Endpoint)
+}
+
+func (s *ForwarderTestSuite) TestForwardResponseTooLarge() {
+	var ping Ping
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	_, err = s.forwarder.ForwardRequest(ping.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, &Options{MaxResponseSize: 1})
+
+	s.Require().IsType(&SizeLimitError{}, err, "expected a size limit error")
+	sizeErr := err.(*SizeLimitError)
+	s.True(sizeErr.Response, "expected the response to exceed the limit")
+	s.Equal(2, sizeErr.Size, "expected the response to be read up to one byte past the limit")
+
+	// the connection is still usable after the response is cut off
+	_, err = s.forwarder.ForwardRequest(ping.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.NoError(err)
+}
+
+func (s *ForwarderTestSuite) TestBytesForwardedEvent() {
+	var ping Ping
+
+	events := make(chan BytesForwardedEvent, 1)
+	listener := &EventListener{}
+	listener.On("HandleEvent", mock.AnythingOfTypeArgument("forward.BytesForwardedEvent")).Run(func(args mock.Arguments) {
+		events <- args.Get(0).(BytesForwardedEvent)
+	}).Return()
+	listener.On("HandleEvent", mock.Anything).Return()
+	s.forwarder.RegisterListener(listener)
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	request := ping.Bytes()
+	res, err := s.forwarder.ForwardRequest(request, dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.NoError(err, "expected request to be forwarded")
+
+	event := <-events
+	s.Equal("/ping", event.Endpoint)
+	s.Equal(len(request), event.RequestBytes)
+	s.Equal(len(res), event.ResponseBytes)
+}
+
+func (s *ForwarderTestSuite) TestForwardPushback() {
+	var ping Ping
+
+	// clear the backoff so the peer stays reachable for other tests
+	defer func() { s.forwarder.backoff.until = nil }()
+
+	events := make(chan PushbackReceivedEvent, 1)
+	listener := &EventListener{}
+	listener.On("HandleEvent", mock.AnythingOfTypeArgument("forward.PushbackReceivedEvent")).Run(func(args mock.Arguments) {
+		events <- args.Get(0).(PushbackReceivedEvent)
+	}).Return()
+	listener.On("HandleEvent", mock.Anything).Return()
+	s.forwarder.RegisterListener(listener)
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	_, err = s.forwarder.ForwardRequest(ping.Bytes(), dest, "test", "/overloaded", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.NoError(err, "expected request carrying pushback to succeed")
+
+	event := <-events
+	s.Equal(dest, event.Destination)
+	s.Equal(time.Minute, event.RetryAfter)
+
+	_, err = s.forwarder.ForwardRequest(ping.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.Require().IsType(&PushbackError{}, err, "expected request to backed off destination to fail fast")
+	s.Equal(dest, err.(*PushbackError).Destination)
+}
+
+func (s *ForwarderTestSuite) TestForwardEndpointAllowList() {
+	var ping Ping
+
+	f := NewForwarder(s.sender, s.channel.GetSubChannel("forwarder"))
+	f.RegisterEndpoint("test", "/ping", nil)
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	_, err = f.ForwardRequest(ping.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.NoError(err, "expected request to registered endpoint to be forwarded")
+
+	_, err = f.ForwardRequest(ping.Bytes(), dest, "test", "/error", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.Equal(&UnregisteredEndpointError{Service: "test", Endpoint: "/error"}, err)
+}
+
+func (s *ForwarderTestSuite) TestForwardEndpointValidator() {
+	f := NewForwarder(s.sender, s.channel.GetSubChannel("forwarder"))
+	f.RegisterEndpoint("test", "/ping", &EndpointOptions{
+		Validator: func(request []byte) error {
+			var ping Ping
+			if err := json2.Unmarshal(request, &ping); err != nil {
+				return err
+			}
+			if ping.Message == "" {
+				return errors.New("message is required")
+			}
+			return nil
+		},
+	})
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	_, err = f.ForwardRequest(Ping{}.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.IsType(&ValidationError{}, err, "expected invalid request to be rejected")
+
+	_, err = f.ForwardRequest(Ping{Message: "hi"}.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.NoError(err, "expected valid request to be forwarded")
+}
+
+func (s *ForwarderTestSuite) TestForwardEndpointContextValidator() {
+	f := NewForwarder(s.sender, s.channel.GetSubChannel("forwarder"))
+	f.RegisterEndpoint("test", "/ping", &EndpointOptions{
+		ContextValidator: func(ctx context.Context, request []byte) error {
+			if ctx.Value("caller") == nil {
+				return errors.New("caller is required")
+			}
+			return nil
+		},
+	})
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	_, err = f.ForwardRequest(Ping{}.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.IsType(&ValidationError{}, err, "expected request without caller to be rejected")
+
+	ctx := context.WithValue(context.Background(), "caller", "test")
+	_, err = f.ForwardRequestContext(ctx, Ping{}.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.NoError(err, "expected request with caller to be forwarded")
+}
+
+func (s *ForwarderTestSuite) TestForwardCancelledContext() {
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = s.forwarder.ForwardRequestContext(ctx, Ping{}.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.Equal(context.Canceled, err, "expected cancelled request not to be sent")
+}
+
+func (s *ForwarderTestSuite) TestForwardCodec() {
+	f := NewForwarder(s.sender, s.channel.GetSubChannel("forwarder"))
+	f.RegisterEndpoint("test", "/ping", &EndpointOptions{
+		Codec: JSONCodec{KeyField: "message", MinCompressSize: 1},
+	})
+
+	events := make(chan BytesForwardedEvent, 1)
+	listener := &EventListener{}
+	listener.On("HandleEvent", mock.AnythingOfTypeArgument("forward.BytesForwardedEvent")).Run(func(args mock.Arguments) {
+		events <- args.Get(0).(BytesForwardedEvent)
+	}).Return()
+	listener.On("HandleEvent", mock.Anything).Return()
+	f.RegisterListener(listener)
+
+	request := Ping{Message: "reachable"}.Bytes()
+
+	keys, err := f.RequestKeys(request, "test", "/ping")
+	s.NoError(err)
+	s.Equal([]string{"reachable"}, keys)
+
+	_, err = f.RequestKeys(request, "test", "/error")
+	s.Equal(ErrNoCodec, err)
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	_, err = f.ForwardRequest(request, dest, "test", "/ping", nil, tchannel.JSON, nil)
+	s.NoError(err, "expected request to be forwarded")
+
+	event := <-events
+	s.Equal("json", event.Codec)
+	s.True(event.Compressible)
+}
+
+func (s *ForwarderTestSuite) TestEndpointOptionOverrides() {
+	endpoint := &EndpointOptions{
+		Timeout:       time.Second,
+		MaxRetries:    1,
+		RetrySchedule: []time.Duration{time.Millisecond},
+	}
+
+	opts := s.forwarder.mergeDefaultOptions(nil, endpoint)
+	s.Equal(time.Second, opts.Timeout)
+	s.Equal(1, opts.MaxRetries)
+	s.Equal([]time.Duration{time.Millisecond}, opts.RetrySchedule)
+
+	opts = s.forwarder.mergeDefaultOptions(&Options{Timeout: time.Minute}, endpoint)
+	s.Equal(time.Minute, opts.Timeout, "expected request options to take precedence")
+	s.Equal(1, opts.MaxRetries)
+}
+
 func (s *ForwarderTestSuite) TestForwardThrift() {
 	dest, err := s.sender.Lookup("reachable")
 	s.NoError(err)
@@ -231,6 +497,135 @@ func (s *ForwarderTestSuite) TestMaxRetries() {
 	s.EqualError(err, "max retries exceeded")
 }
 
+func (s *ForwarderTestSuite) TestRetriesDisabled() {
+	var ping Ping
+
+	dest, err := s.sender.Lookup("immediate fail")
+	s.NoError(err)
+
+	s.forwarder.SetRetries(false)
+	defer s.forwarder.SetRetries(true)
+	s.False(s.forwarder.RetriesEnabled())
+
+	// the request would take an hour to fail if it were retried
+	_, err = s.forwarder.ForwardRequest(ping.Bytes(), dest, "test", "/ping", []string{"immediate fail"},
+		tchannel.JSON, &Options{
+			MaxRetries:    1,
+			RetrySchedule: []time.Duration{time.Hour},
+		})
+
+	s.EqualError(err, "max retries exceeded")
+}
+
+func (s *ForwarderTestSuite) TestConnectionFailedEvent() {
+	var ping Ping
+
+	// grab a free port and release it again so connecting to it is refused
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	dest := ln.Addr().String()
+	ln.Close()
+	s.sender.On("Lookup", "refused").Return(dest, nil)
+
+	events := make(chan ConnectionFailedEvent, 1)
+	listener := &EventListener{}
+	listener.On("HandleEvent", mock.AnythingOfTypeArgument("forward.ConnectionFailedEvent")).Run(func(args mock.Arguments) {
+		select {
+		case events <- args.Get(0).(ConnectionFailedEvent):
+		default:
+		}
+	}).Return()
+	listener.On("HandleEvent", mock.Anything).Return()
+	s.forwarder.RegisterListener(listener)
+
+	_, err = s.forwarder.ForwardRequest(ping.Bytes(), dest, "test", "/ping", []string{"refused"},
+		tchannel.JSON, &Options{
+			MaxRetries:    1,
+			RetrySchedule: []time.Duration{time.Millisecond},
+		})
+	s.Error(err)
+
+	event := <-events
+	s.Equal(dest, event.Destination)
+}
+
+// resolvingSender is a Sender that dials all destinations at one address.
+type resolvingSender struct {
+	*MockSender
+	dial string
+}
+
+func (r resolvingSender) DialAddress(destination string) string {
+	return r.dial
+}
+
+func (s *ForwarderTestSuite) TestForwardDialAddress() {
+	var ping Ping
+
+	sender := resolvingSender{s.sender, s.peer.PeerInfo().HostPort}
+	f := NewForwarder(sender, s.channel.GetSubChannel("forwarder"))
+
+	// the destination itself is not reachable, its dial address is
+	dest, err := s.sender.Lookup("unreachable")
+	s.NoError(err)
+
+	res, err := f.ForwardRequest(ping.Bytes(), dest, "test", "/ping", []string{"unreachable"},
+		tchannel.JSON, nil)
+	s.NoError(err, "expected request to be forwarded to the dial address")
+
+	var pong Pong
+	s.NoError(json2.Unmarshal(res, &pong))
+	s.Equal("correct pinging host", pong.From)
+}
+
+// meshSender is a Sender whose destinations are reached through a mesh.
+type meshSender struct {
+	*MockSender
+	mesh *shared.Mesh
+}
+
+func (m meshSender) DialAddress(destination string) string {
+	return m.mesh.DialAddress(destination)
+}
+
+func (m meshSender) Mesh() *shared.Mesh {
+	return m.mesh
+}
+
+func (s *ForwarderTestSuite) TestForwardThroughMesh() {
+	var ping Ping
+
+	// the peer acts as the sidecar, which routes by the identity header
+	sender := meshSender{s.sender, &shared.Mesh{
+		Sidecar:         s.peer.PeerInfo().HostPort,
+		RoutingDelegate: "mesh",
+		Identity: func(address string) string {
+			return "spiffe://cluster/" + address
+		},
+	}}
+	f := NewForwarder(sender, s.channel.GetSubChannel("forwarder"))
+
+	dest, err := s.sender.Lookup("unreachable")
+	s.NoError(err)
+
+	res, err := f.ForwardRequest(ping.Bytes(), dest, "test", "/mesh", []string{"unreachable"},
+		tchannel.JSON, nil)
+	s.NoError(err, "expected request to be forwarded through the sidecar")
+
+	var pong Pong
+	s.NoError(json2.Unmarshal(res, &pong))
+	s.Equal("spiffe://cluster/"+dest+" via mesh", pong.Message)
+}
+
+func TestIsConnectionFailure(t *testing.T) {
+	assert.False(t, isConnectionFailure(nil))
+	assert.False(t, isConnectionFailure(errors.New("application error")))
+	assert.False(t, isConnectionFailure(tchannel.ErrTimeout))
+	assert.True(t, isConnectionFailure(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.True(t, isConnectionFailure(tchannel.ErrConnectionClosed))
+	assert.True(t, isConnectionFailure(tchannel.NewSystemError(tchannel.ErrCodeNetwork, "connection reset")))
+}
+
 func (s *ForwarderTestSuite) TestLookupErrorInRetry() {
 	var ping Ping
 
@@ -294,6 +689,70 @@ func (s *ForwarderTestSuite) TestRequestRerouted() {
 	s.Equal("Hello, world!", pong.Message)
 }
 
+func (s *ForwarderTestSuite) TestRequestTimesOutRerouted() {
+	var ping Ping
+	var pong Pong
+
+	// the destination is unreachable while the keys already moved to a
+	// reachable destination, as if the destination became faulty
+	dest, err := s.sender.Lookup("unreachable")
+	s.NoError(err)
+	reachable, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	// wait for the request to be rerouted
+	var wg sync.WaitGroup
+	wg.Add(1)
+	listener := &EventListener{}
+	listener.On("HandleEvent", RerouteEvent{dest, reachable}).Run(func(args mock.Arguments) {
+		wg.Done()
+	}).Return()
+	listener.On("HandleEvent", mock.Anything).Return()
+	s.forwarder.RegisterListener(listener)
+
+	res, err := s.forwarder.ForwardRequest(ping.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, &Options{
+			Timeout:                100 * time.Millisecond,
+			MaxRetries:             1,
+			RerouteRetries:         true,
+			RetryTimedOutOnReroute: true,
+			RetrySchedule:          []time.Duration{time.Millisecond},
+		})
+	s.NoError(err, "expected timed out request to be rerouted")
+
+	s.NoError(json2.Unmarshal(res, &pong))
+	s.Equal("correct pinging host", pong.From)
+	wg.Wait()
+}
+
+func (s *ForwarderTestSuite) TestRequestTimesOutNotRerouted() {
+	var ping Ping
+
+	dest, err := s.sender.Lookup("unreachable")
+	s.NoError(err)
+
+	// the keys did not move, so the timed out request is not retried
+	_, err = s.forwarder.ForwardRequest(ping.Bytes(), dest, "test", "/ping", []string{"unreachable"},
+		tchannel.JSON, &Options{
+			Timeout:                time.Millisecond,
+			MaxRetries:             1,
+			RerouteRetries:         true,
+			RetryTimedOutOnReroute: true,
+			RetrySchedule:          []time.Duration{time.Millisecond},
+		})
+	s.EqualError(err, "request timed out")
+
+	// timed out requests are not retried unless the caller opted in
+	_, err = s.forwarder.ForwardRequest(ping.Bytes(), dest, "test", "/ping", []string{"reachable"},
+		tchannel.JSON, &Options{
+			Timeout:        time.Millisecond,
+			MaxRetries:     1,
+			RerouteRetries: true,
+			RetrySchedule:  []time.Duration{time.Millisecond},
+		})
+	s.EqualError(err, "request timed out")
+}
+
 func (s *ForwarderTestSuite) TestRequestNoReroutes() {
 	var ping Ping
 
@@ -426,6 +885,374 @@ func TestHasForwardedHeader(t *testing.T) {
 	}
 }
 
+// checksumSender is a Sender that verifies ring proofs against a checksum.
+type checksumSender struct {
+	*MockSender
+	checksum uint32
+}
+
+func (c checksumSender) Checksum() (uint32, error) {
+	return c.checksum, nil
+}
+
+// versionedChecksumSender is a Sender that verifies ring proofs against
+// several versions of the checksum.
+type versionedChecksumSender struct {
+	checksumSender
+	checksums map[string]uint32
+}
+
+func (c versionedChecksumSender) Checksums() (map[string]uint32, error) {
+	return c.checksums, nil
+}
+
+func (s *ForwarderTestSuite) TestForwardRingProof() {
+	var ping Ping
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	f := NewForwarder(checksumSender{s.sender, 42}, s.channel.GetSubChannel("forwarder"))
+	_, err = f.ForwardRequest(ping.Bytes(), dest, "test", "/proof", []string{"reachable"},
+		tchannel.JSON, &Options{VerifyRing: true})
+	s.NoError(err, "expected response with a matching ring proof to succeed")
+}
+
+func (s *ForwarderTestSuite) TestForwardRingDiverged() {
+	var ping Ping
+
+	f := NewForwarder(checksumSender{s.sender, 1}, s.channel.GetSubChannel("forwarder"))
+	events := make(chan RingDivergedEvent, 2)
+	listener := &EventListener{}
+	listener.On("HandleEvent", mock.AnythingOfTypeArgument("forward.RingDivergedEvent")).Run(func(args mock.Arguments) {
+		events <- args.Get(0).(RingDivergedEvent)
+	}).Return()
+	listener.On("HandleEvent", mock.Anything).Return()
+	f.RegisterListener(listener)
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	_, err = f.ForwardRequest(ping.Bytes(), dest, "test", "/proof", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.NoError(err, "expected divergence to only be reported without VerifyRing")
+
+	event := <-events
+	s.Equal(RingDivergedEvent{
+		Destination: dest,
+		Checksum:    1,
+		Proof:       RingProof{Checksum: 42, Version: 7},
+	}, event)
+
+	_, err = f.ForwardRequest(ping.Bytes(), dest, "test", "/proof", []string{"reachable"},
+		tchannel.JSON, &Options{
+			VerifyRing:    true,
+			MaxRetries:    1,
+			RetrySchedule: []time.Duration{time.Millisecond},
+		})
+	s.Require().IsType(&RingDivergedError{}, err, "expected divergent response to fail after retries")
+	s.Equal(RingProof{Checksum: 42, Version: 7}, err.(*RingDivergedError).Proof)
+}
+
+func (s *ForwarderTestSuite) TestForwardStream() {
+	var pong Pong
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	// larger than the stream buffers and a TChannel frame
+	ping := Ping{Message: strings.Repeat("a", 3*streamBufferSize)}
+	var res bytes.Buffer
+	err = s.forwarder.ForwardStream(context.Background(), bytes.NewReader(ping.Bytes()), &res,
+		dest, "test", "/echo", []string{"reachable"}, tchannel.JSON, nil)
+	s.Require().NoError(err, "expected request to be streamed")
+	s.NoError(json2.Unmarshal(res.Bytes(), &pong))
+	s.Equal(ping.Message, pong.Message)
+
+	err = s.forwarder.ForwardStream(context.Background(), bytes.NewReader(ping.Bytes()), ioutil.Discard,
+		dest, "test", "/echo", []string{"reachable"}, tchannel.JSON, &Options{MaxRequestSize: streamBufferSize})
+	s.Require().IsType(&SizeLimitError{}, err, "expected request exceeding the limit to fail")
+	s.False(err.(*SizeLimitError).Response)
+
+	err = s.forwarder.ForwardStream(context.Background(), bytes.NewReader(ping.Bytes()), ioutil.Discard,
+		dest, "test", "/echo", []string{"reachable"}, tchannel.JSON, &Options{MaxResponseSize: streamBufferSize})
+	s.Require().IsType(&SizeLimitError{}, err, "expected response exceeding the limit to fail")
+	s.True(err.(*SizeLimitError).Response)
+
+	err = s.forwarder.ForwardStream(context.Background(), bytes.NewReader(ping.Bytes()), ioutil.Discard,
+		dest, "test", "/error", []string{"reachable"}, tchannel.JSON, nil)
+	s.EqualError(err, "remote error", "expected application error to be decoded")
+}
+
+func (s *ForwarderTestSuite) TestForwardBatch() {
+	peer := s.peer.PeerInfo().HostPort
+	sender := &MockSender{}
+	sender.On("WhoAmI").Return("192.0.2.1:1", nil)
+	sender.On("Lookup", "a").Return(peer, nil)
+	sender.On("Lookup", "b").Return(peer, nil)
+	sender.On("Lookup", "fail").Return(peer, nil)
+	sender.On("Lookup", "down").Return("127.0.0.1:0", nil)
+	sender.On("Lookup", "error").Return("", errors.New("lookup error"))
+	f := NewForwarder(sender, s.channel.GetSubChannel("forwarder"))
+
+	forwarded := make(chan struct{}, 5)
+	listener := &EventListener{}
+	listener.On("HandleEvent", RequestForwardedEvent{}).Run(func(args mock.Arguments) {
+		forwarded <- struct{}{}
+	}).Return()
+	listener.On("HandleEvent", mock.Anything).Return()
+	f.RegisterListener(listener)
+
+	results := f.ForwardBatch(context.Background(), []BatchItem{
+		{Key: "a", Payload: []byte("1")},
+		{Key: "down", Payload: []byte("2")},
+		{Key: "b", Payload: []byte("3")},
+		{Key: "error", Payload: []byte("4")},
+		{Key: "fail", Payload: []byte("5")},
+	}, "test", "/batch", &Options{
+		MaxRetries:    1,
+		RetrySchedule: []time.Duration{time.Millisecond},
+	})
+	s.Require().Len(results, 5)
+
+	s.Equal(BatchResult{Key: "a", Destination: peer, Payload: []byte("a=1")}, results[0])
+	s.Equal(BatchResult{Key: "b", Destination: peer, Payload: []byte("b=3")}, results[2])
+	s.Error(results[1].Err, "expected items of an unreachable destination to fail")
+	s.EqualError(results[3].Err, "lookup error")
+	s.EqualError(results[4].Err, "item failed", "expected item error to be returned")
+
+	// the items of a destination are forwarded in a single call
+	<-forwarded
+	<-forwarded
+	time.Sleep(10 * time.Millisecond)
+	s.Len(forwarded, 0, "expected one call per destination")
+}
+
+func (s *ForwarderTestSuite) TestForwardHops() {
+	var ping Ping
+	var pong Pong
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	res, err := s.forwarder.ForwardRequest(ping.Bytes(), dest, "test", "/hops", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.Require().NoError(err)
+	s.NoError(json2.Unmarshal(res, &pong))
+	s.Equal("1 from 192.0.2.1:1", pong.Message, "expected the local node to be the origin")
+
+	ctx := json.WithHeaders(context.Background(), map[string]string{
+		hopsHeaderName:   "2",
+		originHeaderName: "192.0.2.2:1",
+	})
+	res, err = s.forwarder.ForwardRequestContext(ctx, ping.Bytes(), dest, "test", "/hops", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.Require().NoError(err)
+	s.NoError(json2.Unmarshal(res, &pong))
+	s.Equal("3 from 192.0.2.2:1", pong.Message, "expected the hops and origin to be passed on")
+}
+
+func (s *ForwarderTestSuite) TestForwardingLoop() {
+	var ping Ping
+
+	f := NewForwarder(s.sender, s.channel.GetSubChannel("forwarder"))
+	events := make(chan DivergedRingDetectedEvent, 2)
+	listener := &EventListener{}
+	listener.On("HandleEvent", mock.AnythingOfTypeArgument("forward.DivergedRingDetectedEvent")).Run(func(args mock.Arguments) {
+		events <- args.Get(0).(DivergedRingDetectedEvent)
+	}).Return()
+	listener.On("HandleEvent", mock.Anything).Return()
+	f.RegisterListener(listener)
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	// the request was forwarded back to the local node
+	ctx := json.WithHeaders(context.Background(), map[string]string{
+		hopsHeaderName:   "1",
+		originHeaderName: "192.0.2.1:1",
+	})
+	_, err = f.ForwardRequestContext(ctx, ping.Bytes(), dest, "test", "/hops", []string{"reachable"},
+		tchannel.JSON, nil)
+	s.Equal(&ForwardingLoopError{
+		Origin:      "192.0.2.1:1",
+		Destination: dest,
+		Hops:        2,
+		MaxHops:     3,
+	}, err, "expected a request forwarded back to its origin to fail")
+	s.Equal(DivergedRingDetectedEvent{
+		Origin:      "192.0.2.1:1",
+		Destination: dest,
+		Hops:        2,
+		Keys:        []string{"reachable"},
+	}, <-events)
+
+	// the request would exceed the maximum number of hops
+	ctx = json.WithHeaders(context.Background(), map[string]string{
+		hopsHeaderName:   "2",
+		originHeaderName: "192.0.2.2:1",
+	})
+	_, err = f.ForwardRequestContext(ctx, ping.Bytes(), dest, "test", "/hops", []string{"reachable"},
+		tchannel.JSON, &Options{MaxHops: 2})
+	s.IsType(&ForwardingLoopError{}, err, "expected a request exceeding the maximum hops to fail")
+	s.Equal(3, (<-events).Hops)
+}
+
+// TestForwardRingProofVersioned tests that ring proofs are verified by the
+// versions of the checksum both members compute.
+func (s *ForwarderTestSuite) TestForwardRingProofVersioned() {
+	var ping Ping
+
+	dest, err := s.sender.Lookup("reachable")
+	s.NoError(err)
+
+	// the checksum of the ring is a version the destination does not use as
+	// its checksum, but the versions both compute match
+	f := NewForwarder(versionedChecksumSender{checksumSender{s.sender, 43}, map[string]uint32{"v2": 43, "v3": 1}},
+		s.channel.GetSubChannel("forwarder"))
+	_, err = f.ForwardRequest(ping.Bytes(), dest, "test", "/proof/versioned", []string{"reachable"},
+		tchannel.JSON, &Options{VerifyRing: true})
+	s.NoError(err, "expected response with a matching common version to succeed")
+
+	f = NewForwarder(versionedChecksumSender{checksumSender{s.sender, 42}, map[string]uint32{"v1": 42, "v2": 1}},
+		s.channel.GetSubChannel("forwarder"))
+	_, err = f.ForwardRequest(ping.Bytes(), dest, "test", "/proof/versioned", []string{"reachable"},
+		tchannel.JSON, &Options{
+			VerifyRing:    true,
+			MaxRetries:    1,
+			RetrySchedule: []time.Duration{time.Millisecond},
+		})
+	s.IsType(&RingDivergedError{}, err, "expected a differing common version to diverge")
+}
+
+func TestRingProofFromHeaders(t *testing.T) {
+	jsonHeaders, _ := json2.Marshal(map[string]string{
+		"ringpop-ring-checksum": "42",
+		"ringpop-ring-version":  "7",
+	})
+	proof, ok := ringProofFromHeaders(tchannel.JSON, jsonHeaders)
+	assert.True(t, ok)
+	assert.Equal(t, RingProof{Checksum: 42, Version: 7}, proof)
+
+	var thriftHeaders bytes.Buffer
+	thrift.WriteHeaders(&thriftHeaders, map[string]string{"ringpop-ring-checksum": "4294967295"})
+	proof, ok = ringProofFromHeaders(tchannel.Thrift, thriftHeaders.Bytes())
+	assert.True(t, ok, "expected proof without a version to be valid")
+	assert.Equal(t, RingProof{Checksum: 4294967295}, proof)
+
+	_, ok = ringProofFromHeaders(tchannel.JSON, []byte("{}"))
+	assert.False(t, ok, "expected no proof without the checksum header")
+	_, ok = ringProofFromHeaders(tchannel.JSON, []byte(`{"ringpop-ring-checksum":"4294967296"}`))
+	assert.False(t, ok, "expected no proof for a checksum that overflows")
+
+	jsonHeaders, _ = json2.Marshal(map[string]string{
+		"ringpop-ring-checksum":  "42",
+		"ringpop-ring-checksums": formatChecksums(map[string]uint32{"v2": 43, "v1": 42}),
+	})
+	proof, ok = ringProofFromHeaders(tchannel.JSON, jsonHeaders)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]uint32{"v1": 42, "v2": 43}, proof.Checksums)
+	assert.Equal(t, map[string]uint32{"v1": 42}, parseChecksums("v1=42,=1,v2=x,v3"),
+		"expected malformed checksums to be skipped")
+}
+
+// transportFunc is a Transport that forwards requests with a function.
+type transportFunc func(req *TransportRequest) (*TransportResponse, error)
+
+func (f transportFunc) ForwardRequest(ctx context.Context, req *TransportRequest) (*TransportResponse, error) {
+	return f(req)
+}
+
+func TestSetTransport(t *testing.T) {
+	sender := &MockSender{}
+	sender.On("WhoAmI").Return("192.0.2.1:1", nil)
+	f := NewForwarder(sender, nil)
+
+	var forwarded *TransportRequest
+	f.SetTransport(transportFunc(func(req *TransportRequest) (*TransportResponse, error) {
+		forwarded = req
+		return &TransportResponse{Body: []byte("pong")}, nil
+	}))
+
+	res, err := f.ForwardRequest([]byte("ping"), "192.0.2.2:1", "test", "/ping", nil, tchannel.Raw, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("pong"), res)
+	assert.Equal(t, &TransportRequest{
+		Destination: "192.0.2.2:1",
+		Service:     "test",
+		Endpoint:    "/ping",
+		Format:      tchannel.Raw,
+		Body:        []byte("ping"),
+	}, forwarded, "expected request to be forwarded over the transport")
+
+	f.SetTransport(transportFunc(func(req *TransportRequest) (*TransportResponse, error) {
+		return &TransportResponse{
+			Body:             []byte(`{"type":"error","message":"remote error"}`),
+			ApplicationError: true,
+		}, nil
+	}))
+	_, err = f.ForwardRequest([]byte("ping"), "192.0.2.2:1", "test", "/ping", nil, tchannel.JSON, nil)
+	assert.EqualError(t, err, "remote error", "expected application error to be decoded")
+}
+
+func TestForwardStreamUnsupported(t *testing.T) {
+	sender := &MockSender{}
+	sender.On("WhoAmI").Return("192.0.2.1:1", nil)
+	f := NewForwarder(sender, nil)
+	f.SetTransport(transportFunc(func(req *TransportRequest) (*TransportResponse, error) {
+		return &TransportResponse{}, nil
+	}))
+
+	err := f.ForwardStream(context.Background(), strings.NewReader("ping"), ioutil.Discard,
+		"192.0.2.2:1", "test", "/ping", nil, tchannel.Raw, nil)
+	assert.Equal(t, ErrStreamingUnsupported, err)
+}
+
+func TestRequestHeaders(t *testing.T) {
+	for _, format := range []tchannel.Format{tchannel.JSON, tchannel.Thrift} {
+		headers := responseHeaders(format, requestHeaders(format, nil, 2, "192.0.2.1:1"))
+		assert.Equal(t, map[string]string{
+			hopsHeaderName:   "2",
+			originHeaderName: "192.0.2.1:1",
+		}, headers, "expected %s headers to be read back", format)
+
+		headers = responseHeaders(format, requestHeaders(format, map[string]string{
+			"user":         "a",
+			hopsHeaderName: "7",
+		}, 2, "192.0.2.1:1"))
+		assert.Equal(t, map[string]string{
+			"user":           "a",
+			hopsHeaderName:   "2",
+			originHeaderName: "192.0.2.1:1",
+		}, headers, "expected %s application headers to be sent along", format)
+	}
+	assert.Nil(t, requestHeaders(tchannel.Raw, nil, 2, "192.0.2.1:1"), "expected no headers for raw requests")
+}
+
+func TestForwardingLoopError(t *testing.T) {
+	err := &ForwardingLoopError{Origin: "192.0.2.1:1", Destination: "192.0.2.2:1", Hops: 3, MaxHops: 3}
+	assert.Equal(t, "request from 192.0.2.1:1 was forwarded back to its origin after 2 hops", err.Error())
+
+	err.Hops = 4
+	assert.Equal(t, "request from 192.0.2.1:1 exceeds the maximum of 3 hops when forwarded to 192.0.2.2:1", err.Error())
+}
+
+func TestPushbackFromHeaders(t *testing.T) {
+	jsonHeaders, _ := json2.Marshal(map[string]string{"ringpop-retry-after": "1500"})
+	assert.Equal(t, 1500*time.Millisecond, pushbackFromHeaders(tchannel.JSON, jsonHeaders),
+		"expected retry after from json headers")
+
+	var thriftHeaders bytes.Buffer
+	thrift.WriteHeaders(&thriftHeaders, map[string]string{"ringpop-retry-after": "200"})
+	assert.Equal(t, 200*time.Millisecond, pushbackFromHeaders(tchannel.Thrift, thriftHeaders.Bytes()),
+		"expected retry after from thrift headers")
+
+	assert.Equal(t, time.Duration(0), pushbackFromHeaders(tchannel.JSON, []byte("{}")),
+		"expected no pushback without the retry after header")
+	assert.Equal(t, time.Duration(0), pushbackFromHeaders(tchannel.JSON, []byte("invalid")),
+		"expected no pushback for malformed headers")
+}
+
 // SerializeThrift takes a thrift struct and returns the serialized bytes
 // of that struct using the thrift binary protocol. This is a temporary
 // measure before frames can be forwarded directly past the endpoint to the proper
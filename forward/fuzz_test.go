@@ -0,0 +1,66 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/tchannel-go"
+)
+
+func FuzzPushbackFromHeaders(f *testing.F) {
+	f.Add([]byte(`{"ringpop-retry-after":"1500"}`), true)
+	f.Add([]byte{0, 1, 0, 19, 'r', 'i', 'n', 'g', 'p', 'o', 'p', '-', 'r', 'e', 't', 'r', 'y', '-', 'a', 'f', 't', 'e', 'r', 0, 3, '2', '0', '0'}, false)
+	f.Fuzz(func(t *testing.T, arg2 []byte, json bool) {
+		format := tchannel.Thrift
+		if json {
+			format = tchannel.JSON
+		}
+
+		if d := pushbackFromHeaders(format, arg2); d < 0 {
+			t.Fatalf("expected a non-negative retry after, got %v", d)
+		}
+	})
+}
+
+func FuzzDecodeApplicationError(f *testing.F) {
+	f.Add([]byte(`{"type":"error","message":"key not found"}`))
+	f.Add([]byte(`not json`))
+	f.Fuzz(func(t *testing.T, arg3 []byte) {
+		if err := decodeApplicationError("/endpoint", arg3); err == nil {
+			t.Fatal("expected an application error")
+		}
+	})
+}
+
+func TestDecodeApplicationError(t *testing.T) {
+	err := decodeApplicationError("/endpoint", []byte(`{"type":"error","message":"key not found"}`))
+	assert.EqualError(t, err, "key not found")
+
+	err = decodeApplicationError("/endpoint", []byte(`not json`))
+	assert.IsType(t, &MalformedResponseError{}, err)
+}
+
+func TestPushbackFromHeadersOverflow(t *testing.T) {
+	d := pushbackFromHeaders(tchannel.JSON, []byte(`{"ringpop-retry-after":"9223372036854775"}`))
+	assert.Equal(t, int64(0), int64(d), "expected overflowing retry after to be ignored")
+}
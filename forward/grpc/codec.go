@@ -0,0 +1,74 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package grpc runs ringpop over gRPC instead of TChannel. Its Transport
+// carries the messages of the membership protocol and forwarded requests
+// between members, and its interceptors forward gRPC calls to the member of
+// the ring that owns their key, like the forward package forwards TChannel
+// requests.
+package grpc
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// A Frame is a message whose encoding is passed through as is by Codec, e.g.
+// a call that is forwarded without being decoded.
+type Frame struct {
+	Payload []byte
+}
+
+// Codec is the codec of the gRPC servers and connections of this package,
+// which needs to be set on servers with grpc.CustomCodec. It passes Frames
+// through as is and encodes other messages as protocol buffers, so that
+// servers that use it keep serving generated services like with the default
+// codec.
+type Codec struct{}
+
+// Marshal returns the encoding of v.
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case *Frame:
+		return v.Payload, nil
+	case proto.Message:
+		return proto.Marshal(v)
+	}
+	return nil, fmt.Errorf("cannot marshal message of type %T", v)
+}
+
+// Unmarshal decodes data into v.
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	switch v := v.(type) {
+	case *Frame:
+		v.Payload = append([]byte(nil), data...)
+		return nil
+	case proto.Message:
+		return proto.Unmarshal(data, v)
+	}
+	return fmt.Errorf("cannot unmarshal message of type %T", v)
+}
+
+// String returns the name of the codec, which is the one of the default codec
+// so that Codec interoperates with the clients of generated services.
+func (Codec) String() string {
+	return "proto"
+}
@@ -0,0 +1,274 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpc
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/forward"
+	"github.com/gl-works/ringpop-go/logging"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// KeyMetadata is the metadata key calls are routed by, unless
+	// Options.Key is set.
+	KeyMetadata = "ringpop-key"
+
+	// ForwardedMetadata is set on calls that were forwarded to their owner.
+	ForwardedMetadata = "ringpop-forwarded"
+)
+
+var (
+	// ErrNoKey is the error calls without a key are rejected with, with
+	// codes.InvalidArgument.
+	ErrNoKey = errors.New("no key in call")
+
+	// ErrNoTarget is returned by NewForwarder if Options.Target is not set.
+	ErrNoTarget = errors.New("target must be set")
+)
+
+// Options configure a Forwarder created with NewForwarder.
+type Options struct {
+	// Key is the metadata key calls are routed by. Calls are routed by
+	// KeyMetadata if it is empty.
+	Key string
+
+	// Target returns the address of the gRPC server of the owner of a call.
+	// It must be set.
+	Target TargetFunc
+
+	// DialOptions are the options the connections to the owners of calls
+	// are dialed with, e.g. grpc.WithInsecure or transport credentials.
+	DialOptions []grpc.DialOption
+
+	// Logger is used to log calls that failed to be forwarded.
+	Logger log.Logger
+}
+
+// A Forwarder provides the interceptors of a gRPC server that look up the
+// owner of the key in the metadata of a call and forward the call to the
+// gRPC server of the owner, unless the sender owns the key. The server must
+// use Codec, so that the responses of owners are passed through without
+// being decoded. Calls that were already forwarded are always handled
+// locally, so that calls do not bounce between members whose rings disagree,
+// and so are the calls of Transports.
+//
+// Calls without a key are rejected with codes.InvalidArgument, calls whose
+// owner cannot be looked up with codes.Unavailable, and calls that fail to
+// be forwarded with the error of the owner.
+type Forwarder struct {
+	sender forward.Sender
+	key    string
+	target TargetFunc
+	conns  *conns
+	logger log.Logger
+}
+
+// NewForwarder returns a Forwarder that looks up the owners of calls with
+// sender, e.g. a Ringpop instance.
+func NewForwarder(sender forward.Sender, opts *Options) (*Forwarder, error) {
+	if opts == nil || opts.Target == nil {
+		return nil, ErrNoTarget
+	}
+
+	f := &Forwarder{
+		sender: sender,
+		key:    strings.ToLower(opts.Key),
+		target: opts.Target,
+		conns:  newConns(opts.DialOptions),
+		logger: opts.Logger,
+	}
+	if f.key == "" {
+		f.key = KeyMetadata
+	}
+	if f.logger == nil {
+		f.logger = logging.Logger("grpc")
+	}
+	return f, nil
+}
+
+// UnaryServerInterceptor returns the interceptor of unary calls, which is
+// set on servers with grpc.UnaryInterceptor.
+func (f *Forwarder) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		conn, fctx, err := f.route(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		if conn == nil {
+			return handler(ctx, req)
+		}
+
+		var header, trailer metadata.MD
+		res := &Frame{}
+		err = grpc.Invoke(fctx, info.FullMethod, req, res, conn, grpc.Header(&header), grpc.Trailer(&trailer))
+		f.copyMetadata(ctx, header, trailer)
+		if err != nil {
+			f.logger.WithField("error", err).Warn("failed to forward gRPC call")
+			return nil, err
+		}
+		return res, nil
+	}
+}
+
+// StreamServerInterceptor returns the interceptor of streaming calls, which
+// is set on servers with grpc.StreamInterceptor. The messages of forwarded
+// streams are copied in both directions until either side ends the stream.
+func (f *Forwarder) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		conn, fctx, err := f.route(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		if conn == nil {
+			return handler(srv, ss)
+		}
+
+		if err := f.forwardStream(fctx, ss, conn, info.FullMethod); err != nil {
+			f.logger.WithField("error", err).Warn("failed to forward gRPC stream")
+			return err
+		}
+		return nil
+	}
+}
+
+// Close closes the connections to the owners of forwarded calls.
+func (f *Forwarder) Close() error {
+	return f.conns.close()
+}
+
+// route returns the connection to the owner of a call and the context the
+// call is forwarded with, or no connection if the call is handled locally.
+func (f *Forwarder) route(ctx context.Context, method string) (*grpc.ClientConn, context.Context, error) {
+	if strings.HasPrefix(method, "/"+serviceName+"/") {
+		return nil, nil, nil
+	}
+
+	md, _ := metadata.FromContext(ctx)
+	if first(md, ForwardedMetadata) != "" {
+		return nil, nil, nil
+	}
+
+	key := first(md, f.key)
+	if key == "" {
+		return nil, nil, grpc.Errorf(codes.InvalidArgument, "%v", ErrNoKey)
+	}
+
+	dest, err := f.sender.Lookup(key)
+	if err != nil {
+		return nil, nil, grpc.Errorf(codes.Unavailable, "%v", err)
+	}
+
+	identity, err := f.sender.WhoAmI()
+	if err != nil {
+		return nil, nil, grpc.Errorf(codes.Unavailable, "%v", err)
+	}
+
+	if dest == identity {
+		return nil, nil, nil
+	}
+
+	target, err := f.target(dest)
+	if err != nil {
+		return nil, nil, grpc.Errorf(codes.Unavailable, "%v", err)
+	}
+	conn, err := f.conns.get(target)
+	if err != nil {
+		return nil, nil, grpc.Errorf(codes.Unavailable, "%v", err)
+	}
+
+	md = metadata.Join(md, metadata.Pairs(ForwardedMetadata, "true"))
+	return conn, metadata.NewContext(ctx, md), nil
+}
+
+// forwardStream copies the messages of a stream to the owner of its key and
+// the messages of the owner back.
+func (f *Forwarder) forwardStream(ctx context.Context, ss grpc.ServerStream, conn *grpc.ClientConn, method string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	desc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+	cs, err := grpc.NewClientStream(ctx, desc, conn, method)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			msg := &Frame{}
+			if err := ss.RecvMsg(msg); err != nil {
+				if err == io.EOF {
+					cs.CloseSend()
+				} else {
+					cancel()
+				}
+				return
+			}
+			if err := cs.SendMsg(msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	header, err := cs.Header()
+	if err != nil {
+		return err
+	}
+	if header.Len() > 0 {
+		if err := ss.SendHeader(header); err != nil {
+			return err
+		}
+	}
+
+	for {
+		msg := &Frame{}
+		if err := cs.RecvMsg(msg); err != nil {
+			ss.SetTrailer(cs.Trailer())
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := ss.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// copyMetadata sets the header and trailer the owner of a unary call
+// responded with on the response to the caller.
+func (f *Forwarder) copyMetadata(ctx context.Context, header, trailer metadata.MD) {
+	if header.Len() > 0 {
+		if err := grpc.SendHeader(ctx, header); err != nil {
+			f.logger.WithField("error", err).Warn("failed to copy header of forwarded gRPC call")
+		}
+	}
+	if trailer.Len() > 0 {
+		grpc.SetTrailer(ctx, trailer)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpc
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// staticSender looks up keys in a fixed map of owners.
+type staticSender struct {
+	identity string
+	owners   map[string]string
+}
+
+func (s *staticSender) WhoAmI() (string, error) {
+	return s.identity, nil
+}
+
+func (s *staticSender) Lookup(key string) (string, error) {
+	owner, ok := s.owners[key]
+	if !ok {
+		return "", errors.New("not ready")
+	}
+	return owner, nil
+}
+
+// echo is the message of the echo service.
+type echo struct {
+	Value string `protobuf:"bytes,1,opt,name=value"`
+}
+
+func (m *echo) Reset()         { *m = echo{} }
+func (m *echo) String() string { return proto.CompactTextString(m) }
+func (*echo) ProtoMessage()    {}
+
+// echoServer responds to messages with their value and the name of the
+// server, like a generated service would.
+type echoServer struct {
+	name string
+}
+
+var echoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := &echo{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				grpc.SendHeader(ctx, metadata.Pairs("x-echo", srv.(*echoServer).name))
+				return &echo{Value: req.(*echo).Value + " from " + srv.(*echoServer).name}, nil
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/test.Echo/Echo"}, handler)
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Stream",
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			for {
+				req := &echo{}
+				if err := stream.RecvMsg(req); err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return err
+				}
+				if err := stream.SendMsg(&echo{Value: req.Value + " from " + srv.(*echoServer).name}); err != nil {
+					return err
+				}
+			}
+		},
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+}
+
+// newEchoServers starts an echo server with a Forwarder for each of the
+// senders and returns the addresses of the servers by identity.
+func newEchoServers(t *testing.T, senders ...*staticSender) map[string]string {
+	targets := make(map[string]string)
+	target := func(address string) (string, error) {
+		target, ok := targets[address]
+		if !ok {
+			return "", errors.New("unknown member")
+		}
+		return target, nil
+	}
+
+	for _, sender := range senders {
+		f, err := NewForwarder(sender, &Options{
+			Target:      target,
+			DialOptions: []grpc.DialOption{grpc.WithInsecure()},
+		})
+		require.NoError(t, err)
+
+		s := grpc.NewServer(
+			grpc.CustomCodec(Codec{}),
+			grpc.UnaryInterceptor(f.UnaryServerInterceptor()),
+			grpc.StreamInterceptor(f.StreamServerInterceptor()),
+		)
+		s.RegisterService(&echoServiceDesc, &echoServer{name: sender.identity})
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go s.Serve(lis)
+
+		targets[sender.identity] = lis.Addr().String()
+	}
+	return targets
+}
+
+func newEchoSenders() []*staticSender {
+	owners := map[string]string{
+		"a-key": "a:1",
+		"b-key": "b:1",
+	}
+	return []*staticSender{
+		{identity: "a:1", owners: owners},
+		{identity: "b:1", owners: owners},
+	}
+}
+
+func dialEcho(t *testing.T, target string) *grpc.ClientConn {
+	conn, err := grpc.Dial(target, grpc.WithInsecure())
+	require.NoError(t, err)
+	return conn
+}
+
+func callEcho(conn *grpc.ClientConn, md metadata.MD) (*echo, metadata.MD, error) {
+	var header metadata.MD
+	res := &echo{}
+	ctx := metadata.NewContext(context.Background(), md)
+	err := grpc.Invoke(ctx, "/test.Echo/Echo", &echo{Value: "hello"}, res, conn, grpc.Header(&header))
+	return res, header, err
+}
+
+func TestNewForwarderWithoutTarget(t *testing.T) {
+	_, err := NewForwarder(&staticSender{}, nil)
+	assert.Equal(t, ErrNoTarget, err)
+
+	_, err = NewForwarder(&staticSender{}, &Options{})
+	assert.Equal(t, ErrNoTarget, err)
+}
+
+func TestForwardUnary(t *testing.T) {
+	targets := newEchoServers(t, newEchoSenders()...)
+	conn := dialEcho(t, targets["a:1"])
+	defer conn.Close()
+
+	res, header, err := callEcho(conn, metadata.Pairs(KeyMetadata, "b-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello from b:1", res.Value, "expected call to be forwarded to the owner")
+	assert.Equal(t, []string{"b:1"}, header["x-echo"], "expected header of the owner")
+
+	res, _, err = callEcho(conn, metadata.Pairs(KeyMetadata, "a-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello from a:1", res.Value, "expected call to be handled locally")
+
+	res, _, err = callEcho(conn, metadata.Pairs(KeyMetadata, "b-key", ForwardedMetadata, "true"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello from a:1", res.Value, "expected forwarded call to be handled locally")
+}
+
+func TestForwardUnaryErrors(t *testing.T) {
+	targets := newEchoServers(t, newEchoSenders()...)
+	conn := dialEcho(t, targets["a:1"])
+	defer conn.Close()
+
+	_, _, err := callEcho(conn, metadata.MD{})
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	assert.Equal(t, ErrNoKey.Error(), grpc.ErrorDesc(err))
+
+	_, _, err = callEcho(conn, metadata.Pairs(KeyMetadata, "c-key"))
+	assert.Equal(t, codes.Unavailable, grpc.Code(err), "expected failed lookup to be unavailable")
+}
+
+func TestForwardUnaryCustomKey(t *testing.T) {
+	sender := &staticSender{identity: "a:1", owners: map[string]string{"a-key": "a:1"}}
+	f, err := NewForwarder(sender, &Options{
+		Key:    "User-ID",
+		Target: PortTarget(1),
+	})
+	require.NoError(t, err)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "handled", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Echo/Echo"}
+	ctx := metadata.NewContext(context.Background(), metadata.Pairs("user-id", "a-key"))
+
+	res, err := f.UnaryServerInterceptor()(ctx, nil, info, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "handled", res)
+}
+
+func TestForwardStream(t *testing.T) {
+	targets := newEchoServers(t, newEchoSenders()...)
+	conn := dialEcho(t, targets["a:1"])
+	defer conn.Close()
+
+	for key, owner := range map[string]string{"b-key": "b:1", "a-key": "a:1"} {
+		ctx := metadata.NewContext(context.Background(), metadata.Pairs(KeyMetadata, key))
+		desc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+		stream, err := grpc.NewClientStream(ctx, desc, conn, "/test.Echo/Stream")
+		require.NoError(t, err)
+
+		for _, value := range []string{"one", "two"} {
+			require.NoError(t, stream.SendMsg(&echo{Value: value}))
+
+			res := &echo{}
+			require.NoError(t, stream.RecvMsg(res))
+			assert.Equal(t, value+" from "+owner, res.Value)
+		}
+
+		require.NoError(t, stream.CloseSend())
+		assert.Equal(t, io.EOF, stream.RecvMsg(&echo{}), "expected stream to end")
+	}
+}
+
+func TestPortTarget(t *testing.T) {
+	target, err := PortTarget(4000)("10.0.0.1:3000")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:4000", target)
+
+	_, err = PortTarget(4000)("10.0.0.1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,327 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpc
+
+import (
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/gl-works/ringpop-go/forward"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// serviceName is the name of the gRPC service that receives the messages of
+// a Transport.
+const serviceName = "ringpop.Transport"
+
+// The metadata forwarded requests and their responses are sent with.
+const (
+	serviceMetadata          = "ringpop-service"
+	endpointMetadata         = "ringpop-endpoint"
+	formatMetadata           = "ringpop-format"
+	headersMetadata          = "ringpop-headers-bin"
+	applicationErrorMetadata = "ringpop-application-error"
+)
+
+// A TargetFunc returns the address of the gRPC server of the member with the
+// given address in the ring. Members are known in the ring by the address of
+// their TChannel channel, which still serves the endpoints of Ringpop itself,
+// so their gRPC servers listen on other addresses.
+type TargetFunc func(address string) (string, error)
+
+// PortTarget returns a TargetFunc for members whose gRPC servers listen on
+// the given port of the host of their address in the ring.
+func PortTarget(port int) TargetFunc {
+	return func(address string) (string, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return "", err
+		}
+		return net.JoinHostPort(host, strconv.Itoa(port)), nil
+	}
+}
+
+// Transport sends the messages of the membership protocol and forwarded
+// requests to other members as gRPC calls, so that it can be passed to the
+// Transport option of ringpop. Members receive them with a server on which
+// RegisterTransport registered the service of the transport.
+type Transport struct {
+	target TargetFunc
+	conns  *conns
+}
+
+// NewTransport returns a Transport that sends messages to the gRPC servers
+// target returns for the addresses of their members. Connections are dialed
+// with opts, e.g. with grpc.WithInsecure or transport credentials, and kept
+// open until the transport is closed.
+func NewTransport(target TargetFunc, opts ...grpc.DialOption) *Transport {
+	return &Transport{
+		target: target,
+		conns:  newConns(opts),
+	}
+}
+
+// SendPing sends a ping to the member at address.
+func (t *Transport) SendPing(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return t.call(ctx, address, "Ping", req)
+}
+
+// SendPingReq sends a ping request to the member at address.
+func (t *Transport) SendPingReq(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return t.call(ctx, address, "PingReq", req)
+}
+
+// SendJoin sends a join request to the member at address.
+func (t *Transport) SendJoin(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return t.call(ctx, address, "Join", req)
+}
+
+// SendSync sends a sync message to the member at address.
+func (t *Transport) SendSync(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return t.call(ctx, address, "Sync", req)
+}
+
+// ForwardRequest forwards a request to its destination. The service,
+// endpoint, format and headers of the request are sent as metadata of the
+// call, and its body as the message.
+func (t *Transport) ForwardRequest(ctx context.Context, req *forward.TransportRequest) (*forward.TransportResponse, error) {
+	ctx = metadata.NewContext(ctx, metadata.Pairs(
+		serviceMetadata, req.Service,
+		endpointMetadata, req.Endpoint,
+		formatMetadata, string(req.Format),
+		headersMetadata, string(req.Headers),
+	))
+
+	var header metadata.MD
+	body, err := t.call(ctx, req.Destination, "Forward", req.Body, grpc.Header(&header))
+	if err != nil {
+		return nil, err
+	}
+
+	return &forward.TransportResponse{
+		Headers:          []byte(first(header, headersMetadata)),
+		Body:             body,
+		ApplicationError: first(header, applicationErrorMetadata) != "",
+	}, nil
+}
+
+// Close closes the connections of the transport.
+func (t *Transport) Close() error {
+	return t.conns.close()
+}
+
+func (t *Transport) call(ctx context.Context, address, method string, req []byte, opts ...grpc.CallOption) ([]byte, error) {
+	target, err := t.target(address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := t.conns.get(target)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Frame{}
+	if err := grpc.Invoke(ctx, "/"+serviceName+"/"+method, &Frame{Payload: req}, res, conn, opts...); err != nil {
+		return nil, err
+	}
+	return res.Payload, nil
+}
+
+// A RequestHandler handles the requests forwarded to this member over a
+// Transport, e.g. by passing them to the handlers of their endpoints.
+type RequestHandler interface {
+	HandleRequest(ctx context.Context, req *forward.TransportRequest) (*forward.TransportResponse, error)
+}
+
+// A RequestHandlerFunc is a RequestHandler function.
+type RequestHandlerFunc func(ctx context.Context, req *forward.TransportRequest) (*forward.TransportResponse, error)
+
+// HandleRequest calls f(ctx, req).
+func (f RequestHandlerFunc) HandleRequest(ctx context.Context, req *forward.TransportRequest) (*forward.TransportResponse, error) {
+	return f(ctx, req)
+}
+
+// RegisterTransport registers the service that receives the messages of
+// Transports on a gRPC server, which must use Codec. The messages of the
+// membership protocol are passed to protocol, e.g. the Protocol of a Ringpop
+// instance, and forwarded requests to handler. Forwarded requests are
+// rejected as unimplemented if handler is nil.
+func RegisterTransport(s *grpc.Server, protocol swim.ProtocolHandler, handler RequestHandler) {
+	s.RegisterService(&transportServiceDesc, &transportService{
+		protocol: protocol,
+		handler:  handler,
+	})
+}
+
+// transportServer is the interface of the handler of the service of
+// Transports.
+type transportServer interface {
+	handle(ctx context.Context, method string, req []byte) ([]byte, error)
+}
+
+// transportService receives the messages of Transports.
+type transportService struct {
+	protocol swim.ProtocolHandler
+	handler  RequestHandler
+}
+
+func (s *transportService) handle(ctx context.Context, method string, req []byte) ([]byte, error) {
+	switch method {
+	case "Ping":
+		return s.protocol.HandlePing(ctx, req)
+	case "PingReq":
+		return s.protocol.HandlePingReq(ctx, req)
+	case "Join":
+		return s.protocol.HandleJoin(ctx, req)
+	case "Sync":
+		return s.protocol.HandleSync(ctx, req)
+	}
+	return s.forward(ctx, req)
+}
+
+// forward passes a forwarded request to the handler of the service.
+func (s *transportService) forward(ctx context.Context, body []byte) ([]byte, error) {
+	if s.handler == nil {
+		return nil, grpc.Errorf(codes.Unimplemented, "forwarded requests are not handled")
+	}
+
+	md, _ := metadata.FromContext(ctx)
+	res, err := s.handler.HandleRequest(ctx, &forward.TransportRequest{
+		Service:  first(md, serviceMetadata),
+		Endpoint: first(md, endpointMetadata),
+		Format:   tchannel.Format(first(md, formatMetadata)),
+		Headers:  []byte(first(md, headersMetadata)),
+		Body:     body,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	header := metadata.Pairs(headersMetadata, string(res.Headers))
+	if res.ApplicationError {
+		header = metadata.Join(header, metadata.Pairs(applicationErrorMetadata, "true"))
+	}
+	if err := grpc.SetHeader(ctx, header); err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+var transportServiceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*transportServer)(nil),
+	Methods: []grpc.MethodDesc{
+		transportMethod("Ping"),
+		transportMethod("PingReq"),
+		transportMethod("Join"),
+		transportMethod("Sync"),
+		transportMethod("Forward"),
+	},
+}
+
+// transportMethod returns the description of a method of the service of
+// Transports, whose messages are Frames.
+func transportMethod(name string) grpc.MethodDesc {
+	fullMethod := "/" + serviceName + "/" + name
+
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := &Frame{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				res, err := srv.(transportServer).handle(ctx, name, req.(*Frame).Payload)
+				if err != nil {
+					return nil, err
+				}
+				return &Frame{Payload: res}, nil
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, handler)
+		},
+	}
+}
+
+// first returns the first value of key in md.
+func first(md metadata.MD, key string) string {
+	if values := md[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// conns holds the connections to the gRPC servers of other members.
+type conns struct {
+	opts  []grpc.DialOption
+	conns map[string]*grpc.ClientConn
+	sync.Mutex
+}
+
+func newConns(opts []grpc.DialOption) *conns {
+	return &conns{
+		opts:  append(append([]grpc.DialOption(nil), opts...), grpc.WithCodec(Codec{})),
+		conns: make(map[string]*grpc.ClientConn),
+	}
+}
+
+// get returns the connection to target, dialing it if there is none.
+// Connections are dialed in the background, so that calls on them fail with
+// codes.Unavailable while the server of the target is unreachable.
+func (c *conns) get(target string) (*grpc.ClientConn, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if conn, ok := c.conns[target]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(target, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[target] = conn
+	return conn, nil
+}
+
+// close closes all connections and returns the first error.
+func (c *conns) close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	var err error
+	for target, conn := range c.conns {
+		if cerr := conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(c.conns, target)
+	}
+	return err
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpc
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/gl-works/ringpop-go/discovery"
+	"github.com/gl-works/ringpop-go/forward"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// echoProtocol responds to the messages of the protocol with their endpoint
+// and body.
+type echoProtocol struct{}
+
+func (echoProtocol) HandlePing(ctx context.Context, req []byte) ([]byte, error) {
+	return append([]byte("ping "), req...), nil
+}
+
+func (echoProtocol) HandlePingReq(ctx context.Context, req []byte) ([]byte, error) {
+	return append([]byte("ping-req "), req...), nil
+}
+
+func (echoProtocol) HandleJoin(ctx context.Context, req []byte) ([]byte, error) {
+	return nil, errors.New("join rejected")
+}
+
+func (echoProtocol) HandleSync(ctx context.Context, req []byte) ([]byte, error) {
+	return append([]byte("sync "), req...), nil
+}
+
+// newTransportServer starts a server that receives the messages of
+// Transports and returns its address.
+func newTransportServer(t *testing.T, protocol swim.ProtocolHandler, handler RequestHandler) string {
+	s := grpc.NewServer(grpc.CustomCodec(Codec{}))
+	RegisterTransport(s, protocol, handler)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(lis)
+	return lis.Addr().String()
+}
+
+// fixedTarget returns a TargetFunc that returns target for every member.
+func fixedTarget(target string) TargetFunc {
+	return func(address string) (string, error) {
+		return target, nil
+	}
+}
+
+func TestTransportProtocol(t *testing.T) {
+	target := newTransportServer(t, echoProtocol{}, nil)
+	transport := NewTransport(fixedTarget(target), grpc.WithInsecure())
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	res, err := transport.SendPing(ctx, "a:1", []byte("{}"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ping {}", string(res))
+
+	res, err = transport.SendPingReq(ctx, "a:1", []byte("{}"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ping-req {}", string(res))
+
+	res, err = transport.SendSync(ctx, "a:1", []byte("{}"))
+	assert.NoError(t, err)
+	assert.Equal(t, "sync {}", string(res))
+
+	_, err = transport.SendJoin(ctx, "a:1", []byte("{}"))
+	assert.Equal(t, "join rejected", grpc.ErrorDesc(err))
+}
+
+func TestTransportForwardRequest(t *testing.T) {
+	var received *forward.TransportRequest
+	target := newTransportServer(t, echoProtocol{}, RequestHandlerFunc(func(ctx context.Context, req *forward.TransportRequest) (*forward.TransportResponse, error) {
+		received = req
+		return &forward.TransportResponse{
+			Headers:          []byte{0, 1, 2},
+			Body:             []byte("failed"),
+			ApplicationError: true,
+		}, nil
+	}))
+	transport := NewTransport(fixedTarget(target), grpc.WithInsecure())
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	res, err := transport.ForwardRequest(ctx, &forward.TransportRequest{
+		Destination: "a:1",
+		Service:     "service",
+		Endpoint:    "/endpoint",
+		Format:      tchannel.JSON,
+		Headers:     []byte(`{"a":"b,c"}`),
+		Body:        []byte("body"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &forward.TransportResponse{
+		Headers:          []byte{0, 1, 2},
+		Body:             []byte("failed"),
+		ApplicationError: true,
+	}, res)
+
+	assert.Equal(t, &forward.TransportRequest{
+		Service:  "service",
+		Endpoint: "/endpoint",
+		Format:   tchannel.JSON,
+		Headers:  []byte(`{"a":"b,c"}`),
+		Body:     []byte("body"),
+	}, received)
+}
+
+func TestTransportForwardRequestUnhandled(t *testing.T) {
+	target := newTransportServer(t, echoProtocol{}, nil)
+	transport := NewTransport(fixedTarget(target), grpc.WithInsecure())
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := transport.ForwardRequest(ctx, &forward.TransportRequest{Destination: "a:1"})
+	assert.Equal(t, codes.Unimplemented, grpc.Code(err))
+}
+
+func TestTransportUnreachable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	target := lis.Addr().String()
+	lis.Close()
+
+	transport := NewTransport(fixedTarget(target), grpc.WithInsecure())
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = transport.SendPing(ctx, "a:1", []byte("{}"))
+	assert.Error(t, err)
+}
+
+func TestTransportNotRoutedByForwarder(t *testing.T) {
+	f, err := NewForwarder(&staticSender{}, &Options{Target: PortTarget(1)})
+	require.NoError(t, err)
+
+	s := grpc.NewServer(grpc.CustomCodec(Codec{}), grpc.UnaryInterceptor(f.UnaryServerInterceptor()))
+	RegisterTransport(s, echoProtocol{}, nil)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(lis)
+
+	transport := NewTransport(fixedTarget(lis.Addr().String()), grpc.WithInsecure())
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	res, err := transport.SendPing(ctx, "a:1", []byte("{}"))
+	assert.NoError(t, err, "expected message without a key to be handled")
+	assert.Equal(t, "ping {}", string(res))
+}
+
+func TestTransportMembership(t *testing.T) {
+	targets := make(map[string]string)
+	transport := NewTransport(func(address string) (string, error) {
+		return targets[address], nil
+	}, grpc.WithInsecure())
+	defer transport.Close()
+
+	var nodes []*swim.Node
+	for _, address := range []string{"192.0.2.1:1", "192.0.2.2:1"} {
+		node := swim.NewNode("test", address, nil, &swim.Options{
+			Clock:     clock.NewMock(),
+			Transport: transport,
+		})
+		defer node.Destroy()
+		nodes = append(nodes, node)
+
+		targets[address] = newTransportServer(t, node, nil)
+	}
+
+	for _, node := range nodes {
+		_, err := node.Bootstrap(&swim.BootstrapOptions{
+			DiscoverProvider: discovery.Static("192.0.2.1:1"),
+			Stopped:          true,
+		})
+		require.NoError(t, err, "expected node to join over gRPC")
+	}
+	assert.Len(t, nodes[1].GetReachableMembers(), 2, "expected joined node to learn about the seed")
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+	"golang.org/x/net/context"
+)
+
+var (
+	hopsHeaderName   = "ringpop-forward-hops"
+	originHeaderName = "ringpop-forward-origin"
+)
+
+// A ForwardingLoopError is returned when a request is not forwarded because
+// it either made its way back to the node it originated from, or forwarding
+// it would exceed Options.MaxHops. Both happen when the nodes the request
+// passed through disagree on who owns its keys.
+type ForwardingLoopError struct {
+	Origin      string
+	Destination string
+	Hops        int
+	MaxHops     int
+}
+
+func (e *ForwardingLoopError) Error() string {
+	if e.Hops <= e.MaxHops {
+		return fmt.Sprintf("request from %s was forwarded back to its origin "+
+			"after %d hops", e.Origin, e.Hops-1)
+	}
+	return fmt.Sprintf("request from %s exceeds the maximum of %d hops when "+
+		"forwarded to %s", e.Origin, e.MaxHops, e.Destination)
+}
+
+// forwardedHops returns the number of times the request handled in ctx has
+// already been forwarded and the node it originated from, or zero and an
+// empty origin if the request was not forwarded by ringpop.
+func forwardedHops(ctx context.Context) (int, string) {
+	hctx, ok := ctx.(tchannel.ContextWithHeaders)
+	if !ok {
+		return 0, ""
+	}
+
+	headers := hctx.Headers()
+	hops, err := strconv.Atoi(headers[hopsHeaderName])
+	if err != nil || hops < 0 {
+		return 0, ""
+	}
+	return hops, headers[originHeaderName]
+}
+
+// checkHops returns the hop count and origin to forward the request handled
+// in ctx with, or a ForwardingLoopError when it should not be forwarded to
+// the destination.
+func (f *Forwarder) checkHops(ctx context.Context, destination string, keys []string, maxHops int) (int, string, error) {
+	local, _ := f.sender.WhoAmI()
+
+	hops, origin := forwardedHops(ctx)
+	hops++
+	if origin == "" {
+		origin = local
+	}
+
+	if hops <= maxHops && (hops == 1 || origin != local) {
+		return hops, origin, nil
+	}
+
+	f.emitContext(ctx, DivergedRingDetectedEvent{
+		Origin:      origin,
+		Destination: destination,
+		Hops:        hops,
+		Keys:        keys,
+	})
+	return 0, "", &ForwardingLoopError{
+		Origin:      origin,
+		Destination: destination,
+		Hops:        hops,
+		MaxHops:     maxHops,
+	}
+}
+
+// requestHeaders returns the raw request headers of a forwarded call, which
+// carry the application headers along with the hop count and origin of the
+// request.
+func requestHeaders(format tchannel.Format, appHeaders map[string]string, hops int, origin string) []byte {
+	headers := make(map[string]string, len(appHeaders)+2)
+	for key, value := range appHeaders {
+		headers[key] = value
+	}
+	headers[hopsHeaderName] = strconv.Itoa(hops)
+	headers[originHeaderName] = origin
+
+	switch format {
+	case tchannel.Thrift:
+		var buf bytes.Buffer
+		if err := thrift.WriteHeaders(&buf, headers); err != nil {
+			return []byte{0, 0}
+		}
+		return buf.Bytes()
+	case tchannel.JSON:
+		arg2, err := json.Marshal(headers)
+		if err != nil {
+			return nil
+		}
+		return arg2
+	default:
+		return nil
+	}
+}
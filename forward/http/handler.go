@@ -0,0 +1,253 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package http forwards HTTP requests to the member of the ring that owns
+// their key, like the forward package forwards TChannel requests.
+package http
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/forward"
+	"github.com/gl-works/ringpop-go/logging"
+	"golang.org/x/net/context"
+)
+
+const (
+	// KeyHeader is the header requests are routed by, unless Options.Key is
+	// set.
+	KeyHeader = "Ringpop-Key"
+
+	// ForwardedHeader is set on requests that were forwarded to their owner.
+	ForwardedHeader = "Ringpop-Forwarded"
+)
+
+var (
+	// ErrNoKey is the error requests without a key are rejected with.
+	ErrNoKey = errors.New("no key in request")
+
+	// ErrNoTarget is returned by NewHandler if Options.Target is not set.
+	ErrNoTarget = errors.New("target must be set")
+)
+
+// A KeyFunc extracts the key an HTTP request is routed by. The request has no
+// key if it returns an empty string.
+type KeyFunc func(r *http.Request) string
+
+// HeaderKey returns a KeyFunc that routes requests by the value of the header
+// with the given name.
+func HeaderKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// QueryKey returns a KeyFunc that routes requests by the value of the query
+// parameter with the given name.
+func QueryKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.URL.Query().Get(name)
+	}
+}
+
+// PathKey returns a KeyFunc that routes requests by the path segment that
+// follows the given prefix, e.g. PathKey("/users/") routes a request for
+// /users/42/profile by 42. Requests whose path does not start with the prefix
+// have no key.
+func PathKey(prefix string) KeyFunc {
+	return func(r *http.Request) string {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			return ""
+		}
+		key := r.URL.Path[len(prefix):]
+		if i := strings.IndexByte(key, '/'); i >= 0 {
+			key = key[:i]
+		}
+		return key
+	}
+}
+
+// A TargetFunc returns the URL of the HTTP server of the member with the
+// given address in the ring. Members are known in the ring by the address of
+// their TChannel channel, so their HTTP servers listen on other addresses.
+type TargetFunc func(address string) (*url.URL, error)
+
+// PortTarget returns a TargetFunc for members whose HTTP servers listen on the
+// given port of the host of their address in the ring.
+func PortTarget(port int) TargetFunc {
+	return func(address string) (*url.URL, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: "http", Host: net.JoinHostPort(host, strconv.Itoa(port))}, nil
+	}
+}
+
+// Options configure a handler created with NewHandler.
+type Options struct {
+	// Key extracts the key requests are routed by. Requests are routed by the
+	// KeyHeader header if it is nil.
+	Key KeyFunc
+
+	// Target returns the URL of the HTTP server of the owner of a request. It
+	// must be set.
+	Target TargetFunc
+
+	// Transport forwards requests to their owner. http.DefaultTransport is
+	// used if it is nil.
+	Transport http.RoundTripper
+
+	// FlushInterval is the interval at which the response of the owner is
+	// flushed to the client while it is copied. Responses are only flushed
+	// once they are complete if it is zero, so streaming responses should set
+	// it.
+	FlushInterval time.Duration
+
+	// Logger is used to log requests that failed to be forwarded.
+	Logger log.Logger
+}
+
+// NewHandler returns an http.Handler that looks up the owner of the key of a
+// request and passes the request to handler if the sender owns the key.
+// Otherwise it proxies the request to the HTTP server of the owner, keeping
+// its headers and streaming its body, and returns the response of the owner
+// with its status code. Requests that were already forwarded are always
+// handled locally, so that requests do not bounce between members whose rings
+// disagree.
+//
+// It responds with 400 Bad Request to requests without a key, with 503
+// Service Unavailable if the sender fails to look up the owner and with 502
+// Bad Gateway if the owner cannot be reached.
+func NewHandler(sender forward.Sender, handler http.Handler, opts *Options) (http.Handler, error) {
+	if opts == nil || opts.Target == nil {
+		return nil, ErrNoTarget
+	}
+
+	h := &forwardingHandler{
+		sender:  sender,
+		handler: handler,
+		key:     opts.Key,
+		target:  opts.Target,
+		logger:  opts.Logger,
+	}
+	if h.key == nil {
+		h.key = HeaderKey(KeyHeader)
+	}
+	if h.logger == nil {
+		h.logger = logging.Logger("http")
+	}
+
+	h.proxy = &httputil.ReverseProxy{
+		Director:      h.direct,
+		Transport:     opts.Transport,
+		FlushInterval: opts.FlushInterval,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			h.logger.WithField("error", err).Warn("failed to forward HTTP request")
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		},
+	}
+	return h, nil
+}
+
+// Middleware returns a function that wraps handlers with NewHandler. It
+// returns ErrNoTarget if Options.Target is not set.
+func Middleware(sender forward.Sender, opts *Options) (func(http.Handler) http.Handler, error) {
+	if opts == nil || opts.Target == nil {
+		return nil, ErrNoTarget
+	}
+	return func(handler http.Handler) http.Handler {
+		h, _ := NewHandler(sender, handler, opts)
+		return h
+	}, nil
+}
+
+// forwardingHandler is a handler created with NewHandler.
+type forwardingHandler struct {
+	sender  forward.Sender
+	handler http.Handler
+	key     KeyFunc
+	target  TargetFunc
+	proxy   *httputil.ReverseProxy
+	logger  log.Logger
+}
+
+// targetKey is the key of the context value that holds the URL of the owner
+// of a request that is being forwarded.
+type targetKey struct{}
+
+func (h *forwardingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(ForwardedHeader) != "" {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	key := h.key(r)
+	if key == "" {
+		http.Error(w, ErrNoKey.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dest, err := h.sender.Lookup(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	identity, err := h.sender.WhoAmI()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if dest == identity {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	target, err := h.target(dest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), targetKey{}, target))
+	h.proxy.ServeHTTP(w, r)
+}
+
+// direct points a request that is being forwarded at its owner.
+func (h *forwardingHandler) direct(r *http.Request) {
+	target := r.Context().Value(targetKey{}).(*url.URL)
+
+	r.URL.Scheme = target.Scheme
+	r.URL.Host = target.Host
+	if target.Path != "" {
+		r.URL.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(r.URL.Path, "/")
+	}
+	r.Header.Set(ForwardedHeader, "true")
+}
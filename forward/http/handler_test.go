@@ -0,0 +1,187 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticSender looks up keys in a fixed map of owners.
+type staticSender struct {
+	identity string
+	owners   map[string]string
+}
+
+func (s *staticSender) WhoAmI() (string, error) {
+	return s.identity, nil
+}
+
+func (s *staticSender) Lookup(key string) (string, error) {
+	owner, ok := s.owners[key]
+	if !ok {
+		return "", errors.New("not ready")
+	}
+	return owner, nil
+}
+
+// echoHandler responds with the given name, the path and the body of the
+// request and a header and status code taken from the request.
+func echoHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Echo", r.Header.Get("X-Echo"))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s %s", name, r.URL.Path, body)
+	})
+}
+
+func TestPathKey(t *testing.T) {
+	key := PathKey("/users/")
+	for path, expected := range map[string]string{
+		"/users/42/profile": "42",
+		"/users/42":         "42",
+		"/users/":           "",
+		"/groups/42":        "",
+	} {
+		r := httptest.NewRequest("GET", path, nil)
+		assert.Equal(t, expected, key(r), "unexpected key for %s", path)
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	remote := httptest.NewServer(nil)
+	defer remote.Close()
+	remoteURL, err := url.Parse(remote.URL)
+	require.NoError(t, err)
+
+	sender := &staticSender{
+		identity: "127.0.0.1:3001",
+		owners:   map[string]string{"local": "127.0.0.1:3001", "remote": "127.0.0.1:3002"},
+	}
+	remote.Config.Handler, err = NewHandler(sender, echoHandler("remote"), &Options{Target: PortTarget(0)})
+	require.NoError(t, err)
+
+	handler, err := NewHandler(sender, echoHandler("local"), &Options{
+		Key: PathKey("/keys/"),
+		Target: func(address string) (*url.URL, error) {
+			return remoteURL, nil
+		},
+	})
+	require.NoError(t, err)
+	local := httptest.NewServer(handler)
+	defer local.Close()
+
+	post := func(path string) (*http.Response, string) {
+		req, err := http.NewRequest("POST", local.URL+path, strings.NewReader("body"))
+		require.NoError(t, err)
+		req.Header.Set("X-Echo", "header")
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, string(body)
+	}
+
+	res, body := post("/keys/local")
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "local /keys/local body", body, "expected request to be handled locally")
+
+	res, body = post("/keys/remote")
+	assert.Equal(t, http.StatusCreated, res.StatusCode, "expected status code of the owner")
+	assert.Equal(t, "header", res.Header.Get("X-Echo"), "expected headers to be forwarded")
+	assert.Equal(t, "remote /keys/remote body", body, "expected request to be forwarded to the owner")
+
+	res, _ = post("/")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expected request without key to be rejected")
+
+	res, _ = post("/keys/unknown")
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode, "expected failed lookup to be rejected")
+}
+
+func TestNewHandlerForwarded(t *testing.T) {
+	sender := &staticSender{
+		identity: "127.0.0.1:3001",
+		owners:   map[string]string{"remote": "127.0.0.1:3002"},
+	}
+	handler, err := NewHandler(sender, echoHandler("local"), &Options{Target: PortTarget(3002)})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(KeyHeader, "remote")
+	req.Header.Set(ForwardedHeader, "true")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, "local / ", recorder.Body.String(), "expected forwarded request to be handled locally")
+}
+
+func TestNewHandlerUnreachable(t *testing.T) {
+	sender := &staticSender{
+		identity: "127.0.0.1:3001",
+		owners:   map[string]string{"remote": "127.0.0.1:0"},
+	}
+	handler, err := NewHandler(sender, echoHandler("local"), &Options{Target: PortTarget(0)})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(KeyHeader, "remote")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadGateway, recorder.Code, "expected unreachable owner to be reported")
+
+	handler, err = NewHandler(sender, echoHandler("local"), &Options{
+		Target: func(address string) (*url.URL, error) {
+			return nil, errors.New("no target")
+		},
+	})
+	require.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadGateway, recorder.Code, "expected unknown target to be reported")
+}
+
+func TestNewHandlerWithoutTarget(t *testing.T) {
+	_, err := NewHandler(&staticSender{}, echoHandler("local"), nil)
+	assert.Equal(t, ErrNoTarget, err)
+
+	_, err = Middleware(&staticSender{}, &Options{Key: QueryKey("key")})
+	assert.Equal(t, ErrNoTarget, err)
+}
+
+func TestPortTarget(t *testing.T) {
+	target, err := PortTarget(8080)("10.0.0.1:3000")
+	require.NoError(t, err)
+	assert.Equal(t, "http://10.0.0.1:8080", target.String())
+
+	_, err = PortTarget(8080)("10.0.0.1")
+	assert.Error(t, err, "expected address without port to be rejected")
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+)
+
+// A KeyExtractor extracts the keys a request is routed by from its headers
+// and body. It is used to route requests declaratively, instead of computing
+// the keys at every call site.
+type KeyExtractor func(headers map[string]string, request []byte) ([]string, error)
+
+// A KeyNotFoundError is returned by a KeyExtractor when the request does not
+// contain the key it extracts.
+type KeyNotFoundError struct {
+	// Source describes where the key was expected, e.g. header "user-id".
+	Source string
+}
+
+func (e *KeyNotFoundError) Error() string {
+	return fmt.Sprintf("no key in %s", e.Source)
+}
+
+// HeaderKey returns a KeyExtractor that routes requests by the value of the
+// header with the given name.
+func HeaderKey(name string) KeyExtractor {
+	return func(headers map[string]string, request []byte) ([]string, error) {
+		key, ok := headers[name]
+		if !ok || key == "" {
+			return nil, &KeyNotFoundError{Source: fmt.Sprintf("header %q", name)}
+		}
+		return []string{key}, nil
+	}
+}
+
+// JSONKey returns a KeyExtractor that routes JSON encoded requests by the
+// field at the given path. The path is a dot separated list of object fields,
+// e.g. "user.id". The field can hold a string, a number or an array of
+// strings, in which case the request is routed by all of them.
+func JSONKey(path string) KeyExtractor {
+	fields := strings.Split(path, ".")
+	source := fmt.Sprintf("JSON field %q", path)
+
+	return func(headers map[string]string, request []byte) ([]string, error) {
+		decoder := json.NewDecoder(bytes.NewReader(request))
+		decoder.UseNumber()
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		for _, field := range fields {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, &KeyNotFoundError{Source: source}
+			}
+			if value, ok = object[field]; !ok {
+				return nil, &KeyNotFoundError{Source: source}
+			}
+		}
+
+		switch value := value.(type) {
+		case string:
+			return []string{value}, nil
+		case json.Number:
+			return []string{value.String()}, nil
+		case []interface{}:
+			keys := make([]string, 0, len(value))
+			for _, v := range value {
+				key, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s holds a non-string key", source)
+				}
+				keys = append(keys, key)
+			}
+			return keys, nil
+		}
+
+		return nil, fmt.Errorf("%s is not a string, number or array of strings", source)
+	}
+}
+
+// ThriftKey returns a KeyExtractor that routes Thrift binary encoded requests
+// by the field at the given path of field ids. The request is the encoded
+// arguments struct of the method, so the path of a field of a struct argument
+// starts with the id of the argument, e.g. ThriftKey(1, 2) for field 2 of
+// argument 1. The field can hold a string, an i16, an i32 or an i64.
+func ThriftKey(path ...int16) KeyExtractor {
+	source := fmt.Sprintf("Thrift field %v", path)
+
+	return func(headers map[string]string, request []byte) ([]string, error) {
+		buffer := athrift.NewTMemoryBufferLen(len(request))
+		buffer.Write(request)
+		protocol := athrift.NewTBinaryProtocolTransport(buffer)
+
+		for depth, id := range path {
+			fieldType, err := findThriftField(protocol, id)
+			if err != nil {
+				return nil, err
+			}
+			if fieldType == athrift.STOP {
+				return nil, &KeyNotFoundError{Source: source}
+			}
+
+			if depth < len(path)-1 {
+				if fieldType != athrift.STRUCT {
+					return nil, &KeyNotFoundError{Source: source}
+				}
+				if _, err := protocol.ReadStructBegin(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			return readThriftKey(protocol, fieldType, source)
+		}
+
+		return nil, &KeyNotFoundError{Source: source}
+	}
+}
+
+// findThriftField skips the fields of the current struct up to the field with
+// the given id and returns its type, or STOP if the struct has no such field.
+func findThriftField(protocol athrift.TProtocol, id int16) (athrift.TType, error) {
+	for {
+		_, fieldType, fieldID, err := protocol.ReadFieldBegin()
+		if err != nil {
+			return athrift.STOP, err
+		}
+		if fieldType == athrift.STOP || fieldID == id {
+			return fieldType, nil
+		}
+		if err := athrift.SkipDefaultDepth(protocol, fieldType); err != nil {
+			return athrift.STOP, err
+		}
+	}
+}
+
+func readThriftKey(protocol athrift.TProtocol, fieldType athrift.TType, source string) ([]string, error) {
+	switch fieldType {
+	case athrift.STRING:
+		key, err := protocol.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		return []string{key}, nil
+	case athrift.I16:
+		key, err := protocol.ReadI16()
+		if err != nil {
+			return nil, err
+		}
+		return []string{strconv.FormatInt(int64(key), 10)}, nil
+	case athrift.I32:
+		key, err := protocol.ReadI32()
+		if err != nil {
+			return nil, err
+		}
+		return []string{strconv.FormatInt(int64(key), 10)}, nil
+	case athrift.I64:
+		key, err := protocol.ReadI64()
+		if err != nil {
+			return nil, err
+		}
+		return []string{strconv.FormatInt(key, 10)}, nil
+	}
+
+	return nil, fmt.Errorf("%s is not a string or integer", source)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"testing"
+
+	"github.com/gl-works/ringpop-go/test/thrift/pingpong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderKey(t *testing.T) {
+	extract := HeaderKey("user-id")
+
+	keys, err := extract(map[string]string{"user-id": "a"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+
+	_, err = extract(map[string]string{}, nil)
+	assert.IsType(t, &KeyNotFoundError{}, err)
+}
+
+func TestJSONKey(t *testing.T) {
+	keys, err := JSONKey("user_id")(nil, []byte(`{"user_id":"a"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+
+	keys, err = JSONKey("user.id")(nil, []byte(`{"user":{"id":12345678901234567890}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"12345678901234567890"}, keys, "expected number to keep its precision")
+
+	keys, err = JSONKey("ids")(nil, []byte(`{"ids":["a","b"]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, keys)
+
+	_, err = JSONKey("user.id")(nil, []byte(`{"user":"a"}`))
+	assert.IsType(t, &KeyNotFoundError{}, err)
+
+	_, err = JSONKey("user_id")(nil, []byte(`{"user_id":true}`))
+	assert.Error(t, err, "expected error for key of wrong type")
+
+	_, err = JSONKey("user_id")(nil, []byte(`not json`))
+	assert.Error(t, err, "expected error for invalid request")
+}
+
+func TestThriftKey(t *testing.T) {
+	request, err := SerializeThrift(&pingpong.PingPongPingArgs{
+		Request: &pingpong.Ping{Key: "success"},
+	})
+	require.NoError(t, err)
+
+	keys, err := ThriftKey(1, 1)(nil, request)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"success"}, keys)
+
+	_, err = ThriftKey(1, 2)(nil, request)
+	assert.IsType(t, &KeyNotFoundError{}, err)
+
+	_, err = ThriftKey(1)(nil, request)
+	assert.Error(t, err, "expected error for struct field")
+
+	_, err = ThriftKey(1, 1)(nil, request[:len(request)-3])
+	assert.Error(t, err, "expected error for truncated request")
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A DestinationLimit bounds the number of requests that are forwarded to a
+// single destination concurrently, so that one slow destination cannot take
+// up all the goroutines and connections of the forwarder and starve requests
+// to healthy destinations.
+type DestinationLimit struct {
+	// MaxInflight is the maximum number of requests in flight to a single
+	// destination. A request that timed out or was canceled is in flight
+	// until its call returns. Zero means no limit.
+	MaxInflight int
+
+	// MaxQueued is the maximum number of requests waiting for a destination
+	// that is at its limit. Requests beyond it are rejected right away.
+	MaxQueued int
+
+	// QueueTimeout is how long a request waits for a destination that is at
+	// its limit before it is rejected. It defaults to the timeout of the
+	// request.
+	QueueTimeout time.Duration
+}
+
+// A ConcurrencyLimitError is returned when a request is not forwarded because
+// its destination has reached the limit of concurrent requests.
+type ConcurrencyLimitError struct {
+	Destination string
+
+	// Limit is the maximum number of requests in flight to the destination.
+	Limit int
+
+	// Queued is true if the request waited in the queue and timed out, false
+	// if it was rejected because the queue was full.
+	Queued bool
+}
+
+func (e *ConcurrencyLimitError) Error() string {
+	if e.Queued {
+		return fmt.Sprintf("timed out waiting for one of %d concurrent requests to %s to complete",
+			e.Limit, e.Destination)
+	}
+	return fmt.Sprintf("destination %s has reached its limit of %d concurrent requests",
+		e.Destination, e.Limit)
+}
+
+// destinationSlots are the slots for requests in flight to a destination and
+// the number of requests waiting for one.
+type destinationSlots struct {
+	inflight chan struct{}
+	waiting  int
+}
+
+// destinationLimiter enforces a DestinationLimit per destination.
+type destinationLimiter struct {
+	limit        DestinationLimit
+	destinations map[string]*destinationSlots
+	sync.Mutex
+}
+
+// SetDestinationLimit bounds the number of requests forwarded to a single
+// destination concurrently. The limit applies to requests forwarded after it
+// is set.
+func (f *Forwarder) SetDestinationLimit(limit DestinationLimit) {
+	f.limiter.Lock()
+	f.limiter.limit = limit
+	f.limiter.destinations = nil
+	f.limiter.Unlock()
+}
+
+// acquire takes a slot for a request to the destination, waiting up to
+// timeout for one if the destination is at its limit. On success it returns a
+// function that releases the slot.
+func (l *destinationLimiter) acquire(destination string, timeout time.Duration) (func(), error) {
+	l.Lock()
+	limit := l.limit
+	if limit.MaxInflight <= 0 {
+		l.Unlock()
+		return func() {}, nil
+	}
+
+	if l.destinations == nil {
+		l.destinations = make(map[string]*destinationSlots)
+	}
+	slots, ok := l.destinations[destination]
+	if !ok {
+		slots = &destinationSlots{inflight: make(chan struct{}, limit.MaxInflight)}
+		l.destinations[destination] = slots
+	}
+
+	release := func() {
+		l.Lock()
+		<-slots.inflight
+		if len(slots.inflight) == 0 && slots.waiting == 0 && l.destinations[destination] == slots {
+			delete(l.destinations, destination)
+		}
+		l.Unlock()
+	}
+
+	select {
+	case slots.inflight <- struct{}{}:
+		l.Unlock()
+		return release, nil
+	default:
+	}
+
+	if slots.waiting >= limit.MaxQueued {
+		l.Unlock()
+		return nil, &ConcurrencyLimitError{
+			Destination: destination,
+			Limit:       limit.MaxInflight,
+		}
+	}
+	slots.waiting++
+	l.Unlock()
+
+	if limit.QueueTimeout > 0 {
+		timeout = limit.QueueTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case slots.inflight <- struct{}{}:
+	case <-timer.C:
+		err = &ConcurrencyLimitError{
+			Destination: destination,
+			Limit:       limit.MaxInflight,
+			Queued:      true,
+		}
+	}
+
+	l.Lock()
+	slots.waiting--
+	l.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return release, nil
+}
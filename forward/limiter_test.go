@@ -0,0 +1,109 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestinationLimiterUnlimited(t *testing.T) {
+	var l destinationLimiter
+	for i := 0; i < 10; i++ {
+		_, err := l.acquire("a", time.Millisecond)
+		require.NoError(t, err)
+	}
+	assert.Empty(t, l.destinations)
+}
+
+func TestDestinationLimiterRejectsWhenQueueFull(t *testing.T) {
+	var l destinationLimiter
+	l.limit = DestinationLimit{MaxInflight: 2}
+
+	release, err := l.acquire("a", time.Second)
+	require.NoError(t, err)
+	_, err = l.acquire("a", time.Second)
+	require.NoError(t, err)
+
+	_, err = l.acquire("a", time.Second)
+	assert.Equal(t, &ConcurrencyLimitError{Destination: "a", Limit: 2}, err)
+
+	_, err = l.acquire("b", time.Second)
+	assert.NoError(t, err, "expected other destinations to be unaffected")
+
+	release()
+	_, err = l.acquire("a", time.Second)
+	assert.NoError(t, err, "expected a released slot to be reused")
+}
+
+func TestDestinationLimiterQueueTimeout(t *testing.T) {
+	var l destinationLimiter
+	l.limit = DestinationLimit{MaxInflight: 1, MaxQueued: 1, QueueTimeout: 10 * time.Millisecond}
+
+	_, err := l.acquire("a", time.Second)
+	require.NoError(t, err)
+
+	_, err = l.acquire("a", time.Second)
+	assert.Equal(t, &ConcurrencyLimitError{Destination: "a", Limit: 1, Queued: true}, err)
+	assert.Equal(t, 0, l.destinations["a"].waiting)
+}
+
+func TestDestinationLimiterQueued(t *testing.T) {
+	var l destinationLimiter
+	l.limit = DestinationLimit{MaxInflight: 1, MaxQueued: 1}
+
+	release, err := l.acquire("a", time.Second)
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		release, err := l.acquire("a", time.Second)
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+
+	// wait for the request to be queued
+	for {
+		l.Lock()
+		waiting := l.destinations["a"].waiting
+		l.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err = l.acquire("a", time.Second)
+	assert.Equal(t, &ConcurrencyLimitError{Destination: "a", Limit: 1}, err,
+		"expected request beyond the queue to be rejected")
+
+	release()
+	assert.NoError(t, <-done, "expected queued request to acquire the released slot")
+
+	l.Lock()
+	assert.Empty(t, l.destinations, "expected idle destination to be removed")
+	l.Unlock()
+}
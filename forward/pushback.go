@@ -0,0 +1,152 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gl-works/ringpop-go/util"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+var (
+	retryAfterHeaderName = "ringpop-retry-after"
+	loadHeaderName       = "ringpop-load"
+)
+
+// A PushbackError is returned when a request is not forwarded because the
+// destination signaled that it is overloaded and asked to be backed off from.
+type PushbackError struct {
+	Destination string
+	RetryAfter  time.Duration
+}
+
+func (e *PushbackError) Error() string {
+	return fmt.Sprintf("destination %s signaled pushback, retry after %v",
+		e.Destination, e.RetryAfter)
+}
+
+// SetPushbackHeaders adds headers to the response of the call associated with
+// ctx that signal the forwarding node to not forward requests to this node for
+// the duration of retryAfter. Load is an indication of how overloaded the node
+// is, ranging from 0 (idle) to 1 (saturated).
+//
+// Example:
+//
+//     func (h *handler) Get(ctx json.Context, req *Request) (*Response, error) {
+//         if h.overloaded() {
+//             forward.SetPushbackHeaders(ctx, time.Second, 0.95)
+//         }
+//         ...
+//     }
+//
+func SetPushbackHeaders(ctx tchannel.ContextWithHeaders, retryAfter time.Duration, load float64) {
+	headers := make(map[string]string)
+	for k, v := range ctx.ResponseHeaders() {
+		headers[k] = v
+	}
+
+	headers[retryAfterHeaderName] = strconv.FormatInt(util.MS(retryAfter), 10)
+	headers[loadHeaderName] = strconv.FormatFloat(load, 'f', -1, 64)
+
+	ctx.SetResponseHeaders(headers)
+}
+
+// pushbackFromHeaders parses the raw response headers of a forwarded call and
+// returns the retry-after duration the destination asked for, or zero if the
+// destination did not signal pushback.
+func pushbackFromHeaders(format tchannel.Format, arg2 []byte) time.Duration {
+	headers := responseHeaders(format, arg2)
+
+	ms, err := strconv.ParseInt(headers[retryAfterHeaderName], 10, 64)
+	// values that would overflow a time.Duration are malformed as well
+	if err != nil || ms <= 0 || ms > int64(math.MaxInt64/time.Millisecond) {
+		return 0
+	}
+
+	return time.Duration(ms) * time.Millisecond
+}
+
+// responseHeaders decodes the raw response headers of a forwarded call. Headers
+// that cannot be decoded are treated as absent.
+func responseHeaders(format tchannel.Format, arg2 []byte) map[string]string {
+	if len(arg2) == 0 {
+		return nil
+	}
+
+	var headers map[string]string
+	var err error
+
+	switch format {
+	case tchannel.Thrift:
+		headers, err = thrift.ReadHeaders(bytes.NewReader(arg2))
+	case tchannel.JSON:
+		err = json.Unmarshal(arg2, &headers)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return nil
+	}
+	return headers
+}
+
+// recordPushback backs off from the destination for the given duration.
+func (f *Forwarder) recordPushback(destination string, retryAfter time.Duration) {
+	f.backoff.Lock()
+	if f.backoff.until == nil {
+		f.backoff.until = make(map[string]time.Time)
+	}
+	f.backoff.until[destination] = time.Now().Add(retryAfter)
+	f.backoff.Unlock()
+
+	f.emit(PushbackReceivedEvent{
+		Destination: destination,
+		RetryAfter:  retryAfter,
+	})
+}
+
+// backoffRemaining returns how long requests to the destination are still
+// being held back, or zero if the destination can be forwarded to.
+func (f *Forwarder) backoffRemaining(destination string) time.Duration {
+	f.backoff.Lock()
+	defer f.backoff.Unlock()
+
+	until, ok := f.backoff.until[destination]
+	if !ok {
+		return 0
+	}
+
+	remaining := until.Sub(time.Now())
+	if remaining <= 0 {
+		delete(f.backoff.until, destination)
+		return 0
+	}
+
+	return remaining
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"runtime/debug"
+
+	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/logging"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"golang.org/x/net/context"
+)
+
+// An ErrorMapping maps an error returned by a handler to the TChannel error
+// code it is reported to the caller with. It returns false for errors it does
+// not map.
+type ErrorMapping func(err error) (tchannel.SystemErrCode, bool)
+
+// ErrorValue returns an ErrorMapping that maps errors equal to target, such as
+// sentinel errors created with errors.New, to code.
+func ErrorValue(target error, code tchannel.SystemErrCode) ErrorMapping {
+	return func(err error) (tchannel.SystemErrCode, bool) {
+		return code, err == target
+	}
+}
+
+// ErrorType returns an ErrorMapping that maps errors of the same type as
+// example to code.
+func ErrorType(example error, code tchannel.SystemErrCode) ErrorMapping {
+	typ := reflect.TypeOf(example)
+	return func(err error) (tchannel.SystemErrCode, bool) {
+		return code, reflect.TypeOf(err) == typ
+	}
+}
+
+// HandlerOptions configure a handler wrapped with WrapHandler.
+type HandlerOptions struct {
+	// ErrorMappings map the errors returned by the handler to TChannel error
+	// codes. The first mapping that matches an error is used; unmapped
+	// errors are reported as unexpected errors, as they are by TChannel.
+	ErrorMappings []ErrorMapping
+
+	// Listener, if set, is notified of the HandlerPanicEvents of the
+	// handler.
+	Listener events.EventListener
+
+	// Logger is used to log the panics of the handler. It defaults to the
+	// forwarder logger.
+	Logger log.Logger
+
+	// RingProof, if set, returns the ring proof that is attached to the
+	// successful responses of the handler, see SetRingProofHeaders. No proof
+	// is attached when it returns an error.
+	RingProof func() (RingProof, error)
+}
+
+// WrapHandler wraps a raw handler of requests, which may have been forwarded
+// by other members, so that a panic in the handler is recovered and turned
+// into an unexpected error response carrying a correlation ID, rather than
+// tearing down the connection the request arrived on. The panic is logged
+// with its stack trace and the correlation ID, so that the error seen by the
+// caller can be traced back to it. Errors returned by the handler are mapped
+// to TChannel error codes by the ErrorMappings of the options, which may be
+// nil.
+func WrapHandler(handler raw.Handler, opts *HandlerOptions) raw.Handler {
+	if opts == nil {
+		opts = &HandlerOptions{}
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = logging.Logger("forwarder")
+	}
+
+	return &recoveringHandler{
+		handler:   handler,
+		mappings:  opts.ErrorMappings,
+		listener:  opts.Listener,
+		logger:    logger,
+		ringProof: opts.RingProof,
+	}
+}
+
+// recoveringHandler is a raw handler wrapped with WrapHandler.
+type recoveringHandler struct {
+	handler   raw.Handler
+	mappings  []ErrorMapping
+	listener  events.EventListener
+	logger    log.Logger
+	ringProof func() (RingProof, error)
+}
+
+func (h *recoveringHandler) Handle(ctx context.Context, args *raw.Args) (res *raw.Res, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			id := correlationID()
+			h.logger.WithFields(log.Fields{
+				"endpoint":      args.Method,
+				"caller":        args.Caller,
+				"correlationID": id,
+				"panic":         value,
+				"stack":         string(debug.Stack()),
+			}).Error("handler panicked")
+
+			if h.listener != nil {
+				events.Notify(ctx, h.listener, HandlerPanicEvent{
+					Endpoint:      args.Method,
+					CorrelationID: id,
+					Value:         value,
+				})
+			}
+
+			res = nil
+			err = tchannel.NewSystemError(tchannel.ErrCodeUnexpected,
+				"handler for %s panicked, correlation id %s", args.Method, id)
+		}
+	}()
+
+	res, err = h.handler.Handle(ctx, args)
+	if err != nil {
+		return res, h.mapError(err)
+	}
+	h.attachRingProof(args.Format, res)
+	return res, nil
+}
+
+// attachRingProof adds the ring proof to the headers of a successful
+// response.
+func (h *recoveringHandler) attachRingProof(format tchannel.Format, res *raw.Res) {
+	if h.ringProof == nil || res == nil || res.IsErr || res.SystemErr != nil {
+		return
+	}
+
+	proof, err := h.ringProof()
+	if err != nil {
+		return
+	}
+	if arg2, ok := ringProofArg2(format, res.Arg2, proof); ok {
+		res.Arg2 = arg2
+	}
+}
+
+func (h *recoveringHandler) OnError(ctx context.Context, err error) {
+	h.handler.OnError(ctx, err)
+}
+
+// mapError returns the error as a TChannel system error with the code of the
+// first mapping that matches it. Errors that are already system errors, or
+// that no mapping matches, are returned as is.
+func (h *recoveringHandler) mapError(err error) error {
+	if _, ok := err.(tchannel.SystemError); ok {
+		return err
+	}
+
+	for _, mapping := range h.mappings {
+		if code, ok := mapping(err); ok {
+			return tchannel.NewSystemError(code, "%v", err)
+		}
+	}
+	return err
+}
+
+// correlationID returns a random identifier that ties an error response to
+// the log entry of its cause.
+func correlationID() string {
+	var id [8]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
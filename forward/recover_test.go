@@ -0,0 +1,152 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"golang.org/x/net/context"
+)
+
+type handlerFunc func(ctx context.Context, args *raw.Args) (*raw.Res, error)
+
+func (f handlerFunc) Handle(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+	return f(ctx, args)
+}
+
+func (f handlerFunc) OnError(ctx context.Context, err error) {}
+
+type eventRecorder struct {
+	events []events.Event
+}
+
+func (r *eventRecorder) HandleEvent(event events.Event) {
+	r.events = append(r.events, event)
+}
+
+type notFoundError struct {
+	key string
+}
+
+func (e *notFoundError) Error() string {
+	return "not found: " + e.key
+}
+
+func TestWrapHandlerRecoversPanics(t *testing.T) {
+	recorder := &eventRecorder{}
+	handler := WrapHandler(handlerFunc(func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		panic("boom")
+	}), &HandlerOptions{Listener: recorder})
+
+	res, err := handler.Handle(context.Background(), &raw.Args{Method: "/get"})
+	assert.Nil(t, res)
+	assert.Equal(t, tchannel.ErrCodeUnexpected, tchannel.GetSystemErrorCode(err))
+
+	if assert.Len(t, recorder.events, 1) {
+		event := recorder.events[0].(HandlerPanicEvent)
+		assert.Equal(t, "/get", event.Endpoint)
+		assert.Equal(t, "boom", event.Value)
+		assert.Len(t, event.CorrelationID, 16)
+		assert.True(t, strings.Contains(err.Error(), event.CorrelationID),
+			"expected error to carry the correlation id")
+	}
+}
+
+func TestWrapHandlerMapsErrors(t *testing.T) {
+	errInvalid := errors.New("invalid key")
+	handler := WrapHandler(handlerFunc(func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		switch string(args.Arg3) {
+		case "invalid":
+			return nil, errInvalid
+		case "missing":
+			return nil, &notFoundError{"missing"}
+		case "busy":
+			return nil, tchannel.ErrServerBusy
+		case "other":
+			return nil, errors.New("other")
+		}
+		return &raw.Res{Arg3: args.Arg3}, nil
+	}), &HandlerOptions{ErrorMappings: []ErrorMapping{
+		ErrorValue(errInvalid, tchannel.ErrCodeBadRequest),
+		ErrorType(&notFoundError{}, tchannel.ErrCodeDeclined),
+		ErrorValue(tchannel.ErrServerBusy, tchannel.ErrCodeBadRequest),
+	}})
+
+	handle := func(arg3 string) error {
+		_, err := handler.Handle(context.Background(), &raw.Args{Arg3: []byte(arg3)})
+		return err
+	}
+
+	err := handle("invalid")
+	assert.Equal(t, tchannel.ErrCodeBadRequest, tchannel.GetSystemErrorCode(err))
+	assert.True(t, strings.Contains(err.Error(), "invalid key"), "expected message to be kept")
+
+	assert.Equal(t, tchannel.ErrCodeDeclined, tchannel.GetSystemErrorCode(handle("missing")))
+	assert.Equal(t, tchannel.ErrCodeBusy, tchannel.GetSystemErrorCode(handle("busy")),
+		"expected system errors to keep their code")
+	assert.Equal(t, tchannel.ErrCodeUnexpected, tchannel.GetSystemErrorCode(handle("other")),
+		"expected unmapped errors to be unexpected")
+
+	res, err := handler.Handle(context.Background(), &raw.Args{Arg3: []byte("ok")})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ok"), res.Arg3)
+}
+
+func TestWrapHandlerRingProof(t *testing.T) {
+	proof := RingProof{Checksum: 42, Version: 3}
+	handler := WrapHandler(handlerFunc(func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		if string(args.Arg3) == "fail" {
+			return &raw.Res{IsErr: true, Arg2: args.Arg2}, nil
+		}
+		return &raw.Res{Arg2: args.Arg2}, nil
+	}), &HandlerOptions{RingProof: func() (RingProof, error) { return proof, nil }})
+
+	res, err := handler.Handle(context.Background(), &raw.Args{
+		Format: tchannel.JSON,
+		Arg2:   []byte(`{"app":"header"}`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"app":                   "header",
+		"ringpop-ring-checksum": "42",
+		"ringpop-ring-version":  "3",
+	}, responseHeaders(tchannel.JSON, res.Arg2), "expected the proof to be added to the headers")
+
+	got, ok := ringProofFromHeaders(tchannel.Thrift, mustHandle(t, handler, tchannel.Thrift).Arg2)
+	assert.True(t, ok, "expected thrift responses to carry the proof")
+	assert.Equal(t, proof, got)
+
+	res, err = handler.Handle(context.Background(), &raw.Args{Format: tchannel.JSON, Arg3: []byte("fail")})
+	assert.NoError(t, err)
+	assert.Empty(t, res.Arg2, "expected application errors to carry no proof")
+}
+
+func mustHandle(t *testing.T, handler raw.Handler, format tchannel.Format) *raw.Res {
+	res, err := handler.Handle(context.Background(), &raw.Args{Format: format})
+	assert.NoError(t, err)
+	return res
+}
@@ -23,15 +23,16 @@ package forward
 import (
 	"encoding/json"
 	"errors"
+	"net"
 	"time"
 
 	"golang.org/x/net/context"
 
 	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/events"
 	"github.com/gl-works/ringpop-go/logging"
 	"github.com/gl-works/ringpop-go/shared"
 	"github.com/uber/tchannel-go"
-	"github.com/uber/tchannel-go/raw"
 )
 
 // errDestinationsDiverged is an error that is returned from AttemptRetry
@@ -43,7 +44,13 @@ var errDestinationsDiverged = errors.New("key destinations have diverged")
 type requestSender struct {
 	sender  Sender
 	emitter eventEmitter
-	channel shared.SubChannel
+	limiter *destinationLimiter
+
+	// transport sends the request to its destination.
+	transport Transport
+
+	// ctx is the context the request was made in.
+	ctx context.Context
 
 	request           []byte
 	destination       string
@@ -57,6 +64,21 @@ type requestSender struct {
 	retries, maxRetries int
 	retrySchedule       []time.Duration
 	rerouteRetries      bool
+	retryTimedOut       bool
+	maxResponseSize     int
+	verifyRing          bool
+
+	// hops and origin are sent along with the request to detect forwarding
+	// loops, see Options.MaxHops.
+	hops   int
+	origin string
+
+	// headers are the application headers sent along with the request.
+	headers map[string]string
+
+	// pushback is the retry-after duration the destination asked for in the
+	// response headers of the last completed call.
+	pushback time.Duration
 
 	startTime, retryStartTime time.Time
 
@@ -73,39 +95,95 @@ func newRequestSender(sender Sender, emitter eventEmitter, channel shared.SubCha
 	}
 
 	return &requestSender{
-		sender:         sender,
-		emitter:        emitter,
-		channel:        channel,
-		request:        request,
-		keys:           keys,
-		destination:    destination,
-		service:        service,
-		endpoint:       endpoint,
-		format:         format,
-		timeout:        opts.Timeout,
-		maxRetries:     opts.MaxRetries,
-		retrySchedule:  opts.RetrySchedule,
-		rerouteRetries: opts.RerouteRetries,
-		logger:         logger,
+		sender:          sender,
+		emitter:         emitter,
+		ctx:             context.Background(),
+		transport:       &channelTransport{channel, sender},
+		request:         request,
+		keys:            keys,
+		destination:     destination,
+		service:         service,
+		endpoint:        endpoint,
+		format:          format,
+		timeout:         opts.Timeout,
+		maxRetries:      opts.MaxRetries,
+		retrySchedule:   opts.RetrySchedule,
+		rerouteRetries:  opts.RerouteRetries,
+		retryTimedOut:   opts.RetryTimedOutOnReroute,
+		maxResponseSize: opts.MaxResponseSize,
+		verifyRing:      opts.VerifyRing,
+		headers:         opts.Headers,
+		logger:          logger,
 	}
 }
 
+// emit emits an event about the request with the context of the request.
+func (s *requestSender) emit(event events.Event) {
+	s.emitter.emitContext(s.ctx, event)
+}
+
+// callTimeout returns the timeout of a call, which is capped by the deadline
+// of the context the request was made in.
+func (s *requestSender) callTimeout() time.Duration {
+	timeout := s.timeout
+	if deadline, ok := s.ctx.Deadline(); ok {
+		if remaining := deadline.Sub(time.Now()); remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return timeout
+}
+
 func (s *requestSender) Send() (res []byte, err error) {
-	ctx, cancel := shared.NewTChannelContext(s.timeout)
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.mesh().NewContext(s.callTimeout(), s.destination)
 	defer cancel()
 
 	var forwardError, applicationError error
+	var pushback time.Duration
+
+	release := func() {}
+	if s.limiter != nil {
+		release, err = s.limiter.acquire(s.destination, s.timeout)
+		if err != nil {
+			s.emit(ConcurrencyLimitedEvent{
+				Destination: s.destination,
+				Queued:      err.(*ConcurrencyLimitError).Queued,
+			})
+			return nil, err
+		}
+	}
+
+	// the call holds its slot of the limiter until it actually returns, even
+	// when the request is given up on before
+	call := s.MakeCall(ctx, &res, &pushback, &forwardError, &applicationError)
+	releaseOnReturn := func() {
+		go func() {
+			<-call
+			release()
+		}()
+	}
 
 	select {
-	case <-s.MakeCall(ctx, &res, &forwardError, &applicationError):
+	case <-call:
+		release()
+		s.pushback = pushback
+
 		if applicationError != nil {
 			return nil, applicationError
 		}
 
+		if isConnectionFailure(forwardError) {
+			s.emit(ConnectionFailedEvent{Destination: s.destination})
+		}
+
 		if forwardError == nil {
 			if s.retries > 0 {
 				// forwarding succeeded after retries
-				s.emitter.emit(RetrySuccessEvent{s.retries})
+				s.emit(RetrySuccessEvent{s.retries})
 			}
 			return res, nil
 		}
@@ -123,10 +201,26 @@ func (s *requestSender) Send() (res []byte, err error) {
 			"endpoint":    s.endpoint,
 		}).Warn("max retries exceeded for request")
 
-		s.emitter.emit(MaxRetriesEvent{s.maxRetries})
+		s.emit(MaxRetriesEvent{s.maxRetries})
 
+		if diverged, ok := forwardError.(*RingDivergedError); ok {
+			return nil, diverged
+		}
 		return nil, errors.New("max retries exceeded")
+	case <-s.ctx.Done(): // request was cancelled by the caller
+		releaseOnReturn()
+		return nil, s.ctx.Err()
+
 	case <-ctx.Done(): // request timed out
+		releaseOnReturn()
+
+		// the keys of the request moved to another destination during the
+		// call, most likely because the destination became faulty, so the
+		// request is retried on the new destination if the caller accepts
+		// that it may be executed twice
+		if s.rerouteRetries && s.retryTimedOut && s.retries < s.maxRetries && s.destinationChanged() {
+			return s.ScheduleRetry()
+		}
 
 		identity, _ := s.sender.WhoAmI()
 
@@ -141,50 +235,99 @@ func (s *requestSender) Send() (res []byte, err error) {
 	}
 }
 
+// decodeApplicationError decodes the JSON encoded application level error a
+// destination responded with. A body that cannot be decoded results in a
+// MalformedResponseError instead of the raw body being handed to the caller as
+// a successful response.
+func decodeApplicationError(endpoint string, arg3 []byte) error {
+	errResp := struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}{}
+
+	if err := json.Unmarshal(arg3, &errResp); err != nil {
+		return &MalformedResponseError{Endpoint: endpoint, Err: err}
+	}
+	return errors.New(errResp.Message)
+}
+
+// mesh returns the service mesh requests are routed through, or nil.
+func (s *requestSender) mesh() *shared.Mesh {
+	if router, ok := s.sender.(MeshRouter); ok {
+		return router.Mesh()
+	}
+	return nil
+}
+
+// isConnectionFailure returns whether err indicates that the destination could
+// not be connected to or dropped the connection, as opposed to the call
+// failing at the application level or timing out.
+func isConnectionFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if _, ok := err.(*net.OpError); ok {
+		return true
+	}
+
+	return err == tchannel.ErrConnectionClosed ||
+		tchannel.GetSystemErrorCode(err) == tchannel.ErrCodeNetwork
+}
+
 // calls remote service and writes response to s.response
-func (s *requestSender) MakeCall(ctx context.Context, res *[]byte, fwdError *error, appError *error) <-chan bool {
+func (s *requestSender) MakeCall(ctx context.Context, res *[]byte, pushback *time.Duration, fwdError *error, appError *error) <-chan bool {
 	done := make(chan bool, 1)
 	go func() {
 		defer close(done)
 
-		peer := s.channel.Peers().GetOrAdd(s.destination)
+		response, err := s.transport.ForwardRequest(ctx, &TransportRequest{
+			Destination: s.destination,
+			Service:     s.service,
+			Endpoint:    s.endpoint,
+			Format:      s.format,
+			Headers:     requestHeaders(s.format, s.headers, s.hops, s.origin),
+			Body:        s.request,
 
-		call, err := peer.BeginCall(ctx, s.service, s.endpoint, &tchannel.CallOptions{
-			Format: s.format,
+			MaxResponseSize: s.maxResponseSize,
 		})
+
+		var arg2, arg3 []byte
+		if response != nil {
+			arg2, arg3 = response.Headers, response.Body
+		}
+
+		*pushback = pushbackFromHeaders(s.format, arg2)
+
+		// check if the response is an application level error
+		if err == nil && response.ApplicationError {
+			*appError = decodeApplicationError(s.endpoint, arg3)
+			done <- true
+			return
+		}
 		if err != nil {
 			*fwdError = err
 			done <- true
 			return
 		}
 
-		var arg3 []byte
-		if s.format == tchannel.Thrift {
-			_, arg3, _, err = raw.WriteArgs(call, []byte{0, 0}, s.request)
-		} else {
-			var resp *tchannel.OutboundCallResponse
-			_, arg3, resp, err = raw.WriteArgs(call, nil, s.request)
-
-			// check if the response is an application level error
-			if err == nil && resp.ApplicationError() {
-				// parse the json from the application level error
-				errResp := struct {
-					Type    string `json:"type"`
-					Message string `json:"message"`
-				}{}
-
-				err = json.Unmarshal(arg3, &errResp)
-
-				// if parsing succeeded return the error as an application error
-				if err == nil {
-					*appError = errors.New(errResp.Message)
-					done <- true
-					return
-				}
+		// responses exceeding the limit are not retried, as the response
+		// would not change on a different attempt
+		if s.maxResponseSize > 0 && len(arg3) > s.maxResponseSize {
+			*appError = &SizeLimitError{
+				Endpoint: s.endpoint,
+				Response: true,
+				Size:     len(arg3),
+				Limit:    s.maxResponseSize,
 			}
+			done <- true
+			return
 		}
-		if err != nil {
-			*fwdError = err
+
+		// a destination with a divergent ring may not own the keys of the
+		// request, so the attempt is retried when the ring is verified
+		if diverged := s.verifyRingProof(arg2); diverged != nil && s.verifyRing {
+			*fwdError = diverged
 			done <- true
 			return
 		}
@@ -201,7 +344,14 @@ func (s *requestSender) ScheduleRetry() ([]byte, error) {
 		s.retryStartTime = time.Now()
 	}
 
-	time.Sleep(s.retrySchedule[s.retries])
+	timer := time.NewTimer(s.retrySchedule[s.retries])
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
 
 	return s.AttemptRetry()
 }
@@ -215,11 +365,11 @@ func (s *requestSender) ScheduleRetry() ([]byte, error) {
 func (s *requestSender) AttemptRetry() ([]byte, error) {
 	s.retries++
 
-	s.emitter.emit(RetryAttemptEvent{})
+	s.emit(RetryAttemptEvent{})
 
 	dests := s.LookupKeys(s.keys)
 	if len(dests) != 1 {
-		s.emitter.emit(RetryAbortEvent{errDestinationsDiverged.Error()})
+		s.emit(RetryAbortEvent{errDestinationsDiverged.Error()})
 		return nil, errDestinationsDiverged
 	}
 
@@ -236,7 +386,7 @@ func (s *requestSender) AttemptRetry() ([]byte, error) {
 }
 
 func (s *requestSender) RerouteRetry(destination string) ([]byte, error) {
-	s.emitter.emit(RerouteEvent{
+	s.emit(RerouteEvent{
 		s.destination,
 		destination,
 	})
@@ -246,6 +396,13 @@ func (s *requestSender) RerouteRetry(destination string) ([]byte, error) {
 	return s.Send()
 }
 
+// destinationChanged returns whether the keys of the request are looked up to
+// a single destination other than the one the request was sent to.
+func (s *requestSender) destinationChanged() bool {
+	dests := s.LookupKeys(s.keys)
+	return len(dests) == 1 && dests[0] != s.destination
+}
+
 // LookupKeys looks up the destinations of the keys provided. Returns a slice
 // of destinations. If multiple keys hash to the same destination, they will
 // be deduped.
@@ -21,11 +21,14 @@
 package forward
 
 import (
+	"testing"
+	"time"
+
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"github.com/gl-works/ringpop-go/shared"
 	"github.com/uber/tchannel-go"
-	"testing"
+	"golang.org/x/net/context"
 )
 
 type requestSenderTestSuite struct {
@@ -109,6 +112,53 @@ func (s *requestSenderTestSuite) TestLookupKeysDedupes() {
 	s.Len(dests, 2, "dedupes multiple destinations for multiple keys")
 }
 
+// blockingTransport signals calls on started and blocks them until unblock
+// is closed.
+type blockingTransport struct {
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (t *blockingTransport) ForwardRequest(ctx context.Context, req *TransportRequest) (*TransportResponse, error) {
+	t.started <- struct{}{}
+	<-t.unblock
+	return &TransportResponse{}, nil
+}
+
+func (s *requestSenderTestSuite) TestLimiterSlotHeldUntilCallReturns() {
+	transport := &blockingTransport{started: make(chan struct{}), unblock: make(chan struct{})}
+	limiter := &destinationLimiter{limit: DestinationLimit{MaxInflight: 1}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.requestSender.transport = transport
+	s.requestSender.limiter = limiter
+	s.requestSender.timeout = time.Second
+	s.requestSender.ctx = ctx
+
+	done := make(chan error)
+	go func() {
+		_, err := s.requestSender.Send()
+		done <- err
+	}()
+	<-transport.started
+	cancel()
+	s.Equal(context.Canceled, <-done)
+
+	_, err := limiter.acquire("dummydest", time.Second)
+	s.Error(err, "expected the slot to be held while the call is in flight")
+
+	close(transport.unblock)
+	for i := 0; i < 100; i++ {
+		var release func()
+		if release, err = limiter.acquire("dummydest", time.Second); err == nil {
+			release()
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.NoError(err, "expected the slot to be released once the call returned")
+}
+
 func TestRequestSenderTestSuite(t *testing.T) {
 	suite.Run(t, new(requestSenderTestSuite))
 }
@@ -0,0 +1,246 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/thrift"
+)
+
+var (
+	ringChecksumHeaderName  = "ringpop-ring-checksum"
+	ringChecksumsHeaderName = "ringpop-ring-checksums"
+	ringVersionHeaderName   = "ringpop-ring-version"
+)
+
+// A RingProof is the checksum and version of the ring of the node that served
+// a request. A caller whose ring has a different checksum was served by a node
+// that may not own the keys of the request. Checksums optionally holds every
+// version of the checksum the node computes, by the name of the version, so
+// that nodes that changed the version of their checksum can still compare a
+// version they have in common.
+type RingProof struct {
+	Checksum  uint32            `json:"checksum"`
+	Checksums map[string]uint32 `json:"checksums,omitempty"`
+	Version   uint64            `json:"version"`
+}
+
+// A RingChecksummer can optionally be implemented by a Sender to verify the
+// ring proofs that destinations attach to their responses. Checksum returns
+// the checksum of the local ring.
+type RingChecksummer interface {
+	Checksum() (uint32, error)
+}
+
+// A VersionedRingChecksummer is a RingChecksummer that computes several
+// versions of the checksum of the local ring. Ring proofs are verified by the
+// versions both nodes compute, and by the checksum of the ring only if they
+// compute no common version.
+type VersionedRingChecksummer interface {
+	RingChecksummer
+	Checksums() (map[string]uint32, error)
+}
+
+// A RingDivergedError is returned when the destination of a forwarded request
+// kept responding with a ring proof whose checksum differs from the local
+// ring, see Options.VerifyRing.
+type RingDivergedError struct {
+	Destination string
+	Checksum    uint32
+	Proof       RingProof
+}
+
+func (e *RingDivergedError) Error() string {
+	return fmt.Sprintf("destination %s served the request with ring checksum %d "+
+		"(version %d), while the local ring checksum is %d", e.Destination,
+		e.Proof.Checksum, e.Proof.Version, e.Checksum)
+}
+
+// SetRingProofHeaders adds headers to the response of the call associated
+// with ctx that carry the ring proof of the responding node, so that the
+// forwarding node can detect that it was served by a node with a divergent
+// ring.
+//
+// Example:
+//
+//     func (h *handler) Get(ctx json.Context, req *Request) (*Response, error) {
+//         checksum, _ := h.ringpop.Checksum()
+//         forward.SetRingProofHeaders(ctx, forward.RingProof{Checksum: checksum})
+//         ...
+//     }
+//
+func SetRingProofHeaders(ctx tchannel.ContextWithHeaders, proof RingProof) {
+	ctx.SetResponseHeaders(proof.headers(ctx.ResponseHeaders()))
+}
+
+// headers returns a copy of the headers with the ring proof added.
+func (proof RingProof) headers(existing map[string]string) map[string]string {
+	headers := make(map[string]string, len(existing)+3)
+	for k, v := range existing {
+		headers[k] = v
+	}
+
+	headers[ringChecksumHeaderName] = strconv.FormatUint(uint64(proof.Checksum), 10)
+	headers[ringVersionHeaderName] = strconv.FormatUint(proof.Version, 10)
+	if len(proof.Checksums) > 0 {
+		headers[ringChecksumsHeaderName] = formatChecksums(proof.Checksums)
+	}
+	return headers
+}
+
+// ringProofArg2 returns the raw response headers of a call with the given
+// format with the ring proof added. Ok is false for formats without headers,
+// or if the headers cannot be encoded.
+func ringProofArg2(format tchannel.Format, arg2 []byte, proof RingProof) (res []byte, ok bool) {
+	headers := proof.headers(responseHeaders(format, arg2))
+
+	switch format {
+	case tchannel.Thrift:
+		var buf bytes.Buffer
+		if err := thrift.WriteHeaders(&buf, headers); err != nil {
+			return nil, false
+		}
+		return buf.Bytes(), true
+	case tchannel.JSON:
+		res, err := json.Marshal(headers)
+		return res, err == nil
+	default:
+		return nil, false
+	}
+}
+
+// ringProofFromHeaders parses the raw response headers of a forwarded call and
+// returns the ring proof of the destination, or false if the destination did
+// not attach one.
+func ringProofFromHeaders(format tchannel.Format, arg2 []byte) (RingProof, bool) {
+	headers := responseHeaders(format, arg2)
+
+	checksum, err := strconv.ParseUint(headers[ringChecksumHeaderName], 10, 32)
+	if err != nil {
+		return RingProof{}, false
+	}
+	// the version is informational, so a missing version does not invalidate
+	// the proof
+	version, _ := strconv.ParseUint(headers[ringVersionHeaderName], 10, 64)
+
+	return RingProof{
+		Checksum:  uint32(checksum),
+		Checksums: parseChecksums(headers[ringChecksumsHeaderName]),
+		Version:   version,
+	}, true
+}
+
+// formatChecksums encodes versioned checksums as a header value, e.g.
+// "v1=42,v2=7".
+func formatChecksums(checksums map[string]uint32) string {
+	parts := make([]string, 0, len(checksums))
+	for name, checksum := range checksums {
+		parts = append(parts, name+"="+strconv.FormatUint(uint64(checksum), 10))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// parseChecksums decodes the versioned checksums of a header value. Malformed
+// checksums are skipped, and nil is returned if there are none.
+func parseChecksums(value string) map[string]uint32 {
+	var checksums map[string]uint32
+	for _, part := range strings.Split(value, ",") {
+		i := strings.Index(part, "=")
+		if i <= 0 {
+			continue
+		}
+		checksum, err := strconv.ParseUint(part[i+1:], 10, 32)
+		if err != nil {
+			continue
+		}
+		if checksums == nil {
+			checksums = make(map[string]uint32)
+		}
+		checksums[part[:i]] = uint32(checksum)
+	}
+	return checksums
+}
+
+// divergedChecksums returns whether the local checksums differ from the
+// checksums of the proof in a version both compute. Compared is false if they
+// compute no common version.
+func divergedChecksums(local, proof map[string]uint32) (diverged, compared bool) {
+	for name, checksum := range local {
+		if other, ok := proof[name]; ok {
+			compared = true
+			if other != checksum {
+				return true, true
+			}
+		}
+	}
+	return false, compared
+}
+
+// verifyRingProof compares the ring proof the destination attached to its
+// response with the local ring. It returns a RingDivergedError if the
+// checksums differ, and nil if they match or cannot be compared.
+func (s *requestSender) verifyRingProof(arg2 []byte) *RingDivergedError {
+	checksummer, ok := s.sender.(RingChecksummer)
+	if !ok {
+		return nil
+	}
+
+	proof, ok := ringProofFromHeaders(s.format, arg2)
+	if !ok {
+		return nil
+	}
+
+	checksum, err := checksummer.Checksum()
+	if err != nil {
+		return nil
+	}
+
+	diverged := checksum != proof.Checksum
+	if versioned, ok := checksummer.(VersionedRingChecksummer); ok && len(proof.Checksums) > 0 {
+		if checksums, err := versioned.Checksums(); err == nil {
+			if d, compared := divergedChecksums(checksums, proof.Checksums); compared {
+				diverged = d
+			}
+		}
+	}
+	if !diverged {
+		return nil
+	}
+
+	s.emit(RingDivergedEvent{
+		Destination: s.destination,
+		Checksum:    checksum,
+		Proof:       proof,
+	})
+	return &RingDivergedError{
+		Destination: s.destination,
+		Checksum:    checksum,
+		Proof:       proof,
+	}
+}
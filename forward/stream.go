@@ -0,0 +1,276 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/gl-works/ringpop-go/shared"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+const (
+	// streamBufferSize is the size of the buffers the bodies of streamed
+	// requests and responses are copied through, which matches the payload
+	// size of a TChannel frame.
+	streamBufferSize = 64 * 1024
+
+	// maxStreamErrorSize bounds the application error a destination can
+	// respond to a streamed request with.
+	maxStreamErrorSize = 64 * 1024
+)
+
+// ErrStreamingUnsupported is returned when a request is streamed over a
+// transport that does not implement StreamTransport.
+var ErrStreamingUnsupported = errors.New("transport does not support streaming")
+
+// A StreamTransport is a Transport that can stream the bodies of forwarded
+// requests and their responses. ForwardStream sends the request with the body
+// read from body, ignoring req.Body, and writes the body of the response to
+// response, unless the destination responded with an application error, which
+// is returned in the body of the TransportResponse instead.
+type StreamTransport interface {
+	Transport
+	ForwardStream(ctx context.Context, req *TransportRequest, body io.Reader, response io.Writer) (*TransportResponse, error)
+}
+
+var streamBuffers = sync.Pool{
+	New: func() interface{} {
+		return make([]byte, streamBufferSize)
+	},
+}
+
+// ForwardStream is ForwardRequestContext for requests and responses that are
+// too large to be held in memory. The body of the request is read from
+// request and the body of the response written to response as they are
+// transferred, through buffers of a fixed size.
+//
+// As a streamed request cannot be read again it is not retried, the
+// validators of the endpoint are not called and ring proofs are not verified.
+// MaxRequestSize and MaxResponseSize are enforced while the bodies are
+// copied, so part of a body that exceeds them has already been sent or
+// written when the SizeLimitError is returned.
+func (f *Forwarder) ForwardStream(ctx context.Context, request io.Reader, response io.Writer,
+	destination, service, endpoint string, keys []string, format tchannel.Format, opts *Options) error {
+
+	f.emitContext(ctx, RequestForwardedEvent{})
+
+	err := f.forwardStream(ctx, request, response, destination, service, endpoint, keys, format, opts)
+	if err != nil {
+		f.emitContext(ctx, FailedEvent{})
+		return err
+	}
+
+	f.emitContext(ctx, SuccessEvent{})
+	return nil
+}
+
+func (f *Forwarder) forwardStream(ctx context.Context, request io.Reader, response io.Writer,
+	destination, service, endpoint string, keys []string, format tchannel.Format, opts *Options) error {
+
+	streamer, ok := f.transport.(StreamTransport)
+	if !ok {
+		return ErrStreamingUnsupported
+	}
+
+	endpointOpts, err := f.endpointOptions(service, endpoint)
+	if err != nil {
+		return err
+	}
+	opts = f.mergeDefaultOptions(opts, endpointOpts)
+
+	hops, origin, err := f.checkHops(ctx, destination, keys, opts.MaxHops)
+	if err != nil {
+		return err
+	}
+
+	if remaining := f.backoffRemaining(destination); remaining > 0 {
+		return &PushbackError{
+			Destination: destination,
+			RetryAfter:  remaining,
+		}
+	}
+
+	release, err := f.limiter.acquire(destination, opts.Timeout)
+	if err != nil {
+		f.emitContext(ctx, ConcurrencyLimitedEvent{
+			Destination: destination,
+			Queued:      err.(*ConcurrencyLimitError).Queued,
+		})
+		return err
+	}
+	defer release()
+
+	f.incrementInflight()
+	defer f.decrementInflight()
+
+	timeout := opts.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := deadline.Sub(time.Now()); remaining < timeout {
+			timeout = remaining
+		}
+	}
+
+	var mesh *shared.Mesh
+	if router, ok := f.sender.(MeshRouter); ok {
+		mesh = router.Mesh()
+	}
+	callCtx, cancel := mesh.NewContext(timeout, destination)
+	defer cancel()
+
+	// the call is abandoned when the request is cancelled by the caller
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-callCtx.Done():
+		}
+	}()
+
+	body := &limitedReader{r: request, limit: opts.MaxRequestSize}
+	out := &limitedWriter{w: response, limit: opts.MaxResponseSize}
+	res, err := streamer.ForwardStream(callCtx, &TransportRequest{
+		Destination: destination,
+		Service:     service,
+		Endpoint:    endpoint,
+		Format:      format,
+		Headers:     requestHeaders(format, opts.Headers, hops, origin),
+	}, body, out)
+
+	if res != nil {
+		if pushback := pushbackFromHeaders(format, res.Headers); pushback > 0 {
+			f.recordPushback(destination, pushback)
+		}
+	}
+
+	switch {
+	case body.exceeded:
+		return &SizeLimitError{Endpoint: endpoint, Size: body.n, Limit: body.limit}
+	case out.exceeded:
+		return &SizeLimitError{Endpoint: endpoint, Response: true, Size: out.n, Limit: out.limit}
+	case err != nil:
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if isConnectionFailure(err) {
+			f.emitContext(ctx, ConnectionFailedEvent{Destination: destination})
+		}
+		return err
+	case res.ApplicationError:
+		return decodeApplicationError(endpoint, res.Body)
+	}
+
+	f.emitContext(ctx, BytesForwardedEvent{
+		Endpoint:      endpoint,
+		RequestBytes:  body.n,
+		ResponseBytes: out.n,
+	})
+	return nil
+}
+
+// limitedReader counts the bytes read from r and fails once more than limit
+// bytes have been read. Zero means no limit.
+type limitedReader struct {
+	r        io.Reader
+	n, limit int
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += n
+	if l.limit > 0 && l.n > l.limit {
+		l.exceeded = true
+		return n, errors.New("request size limit exceeded")
+	}
+	return n, err
+}
+
+// limitedWriter counts the bytes written to w and fails once more than limit
+// bytes would have been written. Zero means no limit.
+type limitedWriter struct {
+	w        io.Writer
+	n, limit int
+	exceeded bool
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.limit > 0 && l.n+len(p) > l.limit {
+		l.exceeded = true
+		l.n += len(p)
+		return 0, errors.New("response size limit exceeded")
+	}
+	n, err := l.w.Write(p)
+	l.n += n
+	return n, err
+}
+
+func (t *channelTransport) ForwardStream(ctx context.Context, req *TransportRequest, body io.Reader, response io.Writer) (*TransportResponse, error) {
+	call, err := t.beginCall(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tchannel.NewArgWriter(call.Arg2Writer()).Write(req.Headers); err != nil {
+		return nil, err
+	}
+
+	buf := streamBuffers.Get().([]byte)
+	defer streamBuffers.Put(buf)
+
+	arg3, err := call.Arg3Writer()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyBuffer(arg3, body, buf); err != nil {
+		return nil, err
+	}
+	if err := arg3.Close(); err != nil {
+		return nil, err
+	}
+
+	resp := call.Response()
+	res := &TransportResponse{}
+	if err := tchannel.NewArgReader(resp.Arg2Reader()).Read(&res.Headers); err != nil {
+		return nil, err
+	}
+
+	arg3Reader, err := resp.Arg3Reader()
+	if err != nil {
+		return res, err
+	}
+	// thrift calls report application errors in the body instead
+	if req.Format != tchannel.Thrift && resp.ApplicationError() {
+		res.ApplicationError = true
+		res.Body, err = ioutil.ReadAll(io.LimitReader(arg3Reader, maxStreamErrorSize))
+	} else {
+		_, err = io.CopyBuffer(response, arg3Reader, buf)
+	}
+	if err != nil {
+		return res, err
+	}
+	return res, arg3Reader.Close()
+}
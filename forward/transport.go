@@ -0,0 +1,142 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package forward
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/gl-works/ringpop-go/shared"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+// A Transport sends forwarded requests to their destination. Forwarders send
+// requests as TChannel calls on their channel unless another transport is set
+// with SetTransport.
+type Transport interface {
+	ForwardRequest(ctx context.Context, req *TransportRequest) (*TransportResponse, error)
+}
+
+// A TransportRequest is a request forwarded over a Transport. Headers are
+// encoded in the format of the request, see the TChannel arg2 encodings.
+type TransportRequest struct {
+	Destination string
+	Service     string
+	Endpoint    string
+	Format      tchannel.Format
+	Headers     []byte
+	Body        []byte
+
+	// MaxResponseSize is the size limit of the response body, or zero if
+	// there is none. A transport may stop reading a body that exceeds it
+	// once it has read one byte more than the limit.
+	MaxResponseSize int
+}
+
+// A TransportResponse is the response to a TransportRequest. ApplicationError
+// is set if the destination responded with an application level error, which
+// is encoded in the body.
+type TransportResponse struct {
+	Headers          []byte
+	Body             []byte
+	ApplicationError bool
+}
+
+// SetTransport replaces the transport requests are forwarded over. It must be
+// set before requests are forwarded.
+func (f *Forwarder) SetTransport(transport Transport) {
+	f.transport = transport
+}
+
+// channelTransport forwards requests as TChannel calls on a channel.
+type channelTransport struct {
+	channel shared.SubChannel
+	sender  Sender
+}
+
+func (t *channelTransport) ForwardRequest(ctx context.Context, req *TransportRequest) (*TransportResponse, error) {
+	call, err := t.beginCall(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tchannel.NewArgWriter(call.Arg2Writer()).Write(req.Headers); err != nil {
+		return nil, err
+	}
+	if err := tchannel.NewArgWriter(call.Arg3Writer()).Write(req.Body); err != nil {
+		return nil, err
+	}
+
+	resp := call.Response()
+	res := &TransportResponse{}
+	if err := tchannel.NewArgReader(resp.Arg2Reader()).Read(&res.Headers); err != nil {
+		return nil, err
+	}
+	// thrift calls report application errors in the body instead
+	if req.Format != tchannel.Thrift {
+		res.ApplicationError = resp.ApplicationError()
+	}
+
+	res.Body, err = readBody(resp, req.MaxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// readBody reads the body of a response. A body that exceeds the limit is
+// only read up to one byte past it, which is enough to tell it does, and the
+// rest of it is discarded instead of held in memory.
+func readBody(resp *tchannel.OutboundCallResponse, limit int) ([]byte, error) {
+	reader, err := resp.Arg3Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if limit > 0 {
+		body, err = ioutil.ReadAll(io.LimitReader(reader, int64(limit)+1))
+		if err == nil && len(body) > limit {
+			// the call only completes once the whole body is read
+			_, err = io.Copy(ioutil.Discard, reader)
+		}
+	} else {
+		body, err = ioutil.ReadAll(reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return body, reader.Close()
+}
+
+// beginCall begins the call of the request on a peer of the channel.
+func (t *channelTransport) beginCall(ctx context.Context, req *TransportRequest) (*tchannel.OutboundCall, error) {
+	dialAddress := req.Destination
+	if resolver, ok := t.sender.(AddressResolver); ok {
+		dialAddress = resolver.DialAddress(req.Destination)
+	}
+	peer := t.channel.Peers().GetOrAdd(dialAddress)
+
+	return peer.BeginCall(ctx, req.Service, req.Endpoint, &tchannel.CallOptions{
+		Format: req.Format,
+	})
+}
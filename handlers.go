@@ -21,6 +21,11 @@
 package ringpop
 
 import (
+	"time"
+
+	"github.com/gl-works/ringpop-go/hashring"
+	"github.com/gl-works/ringpop-go/shared"
+	"github.com/gl-works/ringpop-go/swim"
 	"github.com/uber/tchannel-go/json"
 	"golang.org/x/net/context"
 )
@@ -35,6 +40,25 @@ func (rp *Ringpop) registerHandlers() error {
 		"/health":       rp.health,
 		"/admin/stats":  rp.adminStatsHandler,
 		"/admin/lookup": rp.adminLookupHandler,
+
+		"/admin/stats/member":   rp.adminMemberStatsHandler,
+		"/admin/stats/cluster":  rp.adminClusterStatsHandler,
+		"/admin/ring/simulate":  rp.adminSimulateHandler,
+		"/admin/timeline":       rp.adminTimelineHandler,
+		"/admin/advise":         rp.adminAdviseHandler,
+		"/admin/zones":          rp.adminZonesHandler,
+		"/admin/overrides":      rp.adminOverridesHandler,
+		"/admin/lookup/explain": rp.adminLookupExplainHandler,
+
+		"/admin/subsystems":         rp.adminSubsystemsHandler,
+		"/admin/subsystems/disable": rp.adminSubsystemDisableHandler,
+		"/admin/subsystems/enable":  rp.adminSubsystemEnableHandler,
+
+		"/admin/members/update": rp.adminMembersUpdateHandler,
+	}
+
+	if onReply := rp.adminReply(); onReply != nil {
+		handlers = shared.OnReply(handlers, "/admin/", onReply)
 	}
 
 	return json.Register(rp.subChannel, handlers, func(ctx context.Context, err error) {
@@ -42,14 +66,55 @@ func (rp *Ringpop) registerHandlers() error {
 	})
 }
 
+// authorizeAdmin checks that the caller of the admin endpoint has the required
+// role, see AdminAuth.
+func (rp *Ringpop) authorizeAdmin(ctx json.Context, endpoint string, required swim.AdminRole) error {
+	if rp.node == nil {
+		return nil
+	}
+	return rp.node.AuthorizeAdmin(ctx, endpoint, required)
+}
+
 func (rp *Ringpop) health(ctx json.Context, req *Arg) (*Arg, error) {
 	return nil, nil
 }
 
 func (rp *Ringpop) adminStatsHandler(ctx json.Context, req *Arg) (map[string]interface{}, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/stats", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
 	return handleStats(rp), nil
 }
 
+func (rp *Ringpop) adminMemberStatsHandler(ctx json.Context, req *Arg) (*MemberReport, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/stats/member", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+	report := rp.memberReport()
+	return &report, nil
+}
+
+type clusterStatsRequest struct {
+	Timeout int64 `json:"timeout"` // in milliseconds
+}
+
+func (rp *Ringpop) adminClusterStatsHandler(ctx json.Context, req *clusterStatsRequest) (*ClusterReport, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/stats/cluster", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(req.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultClusterStatsTimeout
+	}
+	return rp.ClusterStats(timeout)
+}
+
 type lookupRequest struct {
 	Key string `json:"key"`
 }
@@ -59,6 +124,10 @@ type lookupResponse struct {
 }
 
 func (rp *Ringpop) adminLookupHandler(ctx json.Context, req *lookupRequest) (*lookupResponse, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/lookup", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
 	dest, err := rp.Lookup(req.Key)
 	if err != nil {
 		return nil, err
@@ -66,6 +135,156 @@ func (rp *Ringpop) adminLookupHandler(ctx json.Context, req *lookupRequest) (*lo
 	return &lookupResponse{Dest: dest}, nil
 }
 
+type lookupExplainRequest struct {
+	Key string `json:"key"`
+	N   int    `json:"n"`
+}
+
+func (rp *Ringpop) adminLookupExplainHandler(ctx json.Context, req *lookupExplainRequest) (*LookupExplanation, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/lookup/explain", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
+	if req.N > 0 {
+		return rp.ExplainLookupN(req.Key, req.N)
+	}
+	return rp.ExplainLookup(req.Key)
+}
+
+type simulateRequest struct {
+	Add    []string       `json:"add"`
+	Remove []string       `json:"remove"`
+	Points map[string]int `json:"points"`
+	Keys   []string       `json:"keys"`
+}
+
+func (rp *Ringpop) adminSimulateHandler(ctx json.Context, req *simulateRequest) (*hashring.SimulationResult, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/ring/simulate", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
+	res, err := rp.SimulateRingChange(hashring.Simulation{
+		Add:    req.Add,
+		Remove: req.Remove,
+		Points: req.Points,
+		Keys:   req.Keys,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+type timelineRequest struct {
+	Window int64 `json:"window"` // in milliseconds
+}
+
+type timelineResponse struct {
+	Entries []TimelineEntry `json:"entries"`
+}
+
+func (rp *Ringpop) adminTimelineHandler(ctx json.Context, req *timelineRequest) (*timelineResponse, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/timeline", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
+	window := time.Duration(req.Window) * time.Millisecond
+	return &timelineResponse{Entries: rp.Timeline(window)}, nil
+}
+
+func (rp *Ringpop) adminAdviseHandler(ctx json.Context, req *Arg) (*Advice, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/advise", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
+	return rp.Advise()
+}
+
+type zonesResponse struct {
+	Zones []Zone `json:"zones"`
+}
+
+func (rp *Ringpop) adminZonesHandler(ctx json.Context, req *Arg) (*zonesResponse, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/zones", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
+	zones, err := rp.Zones()
+	if err != nil {
+		return nil, err
+	}
+	return &zonesResponse{Zones: zones}, nil
+}
+
+func (rp *Ringpop) adminOverridesHandler(ctx json.Context, req *Arg) (*RoutingOverridesStatus, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/overrides", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
+	status := rp.RoutingOverrides()
+	return &status, nil
+}
+
+type subsystemsResponse struct {
+	Subsystems map[swim.Subsystem]bool `json:"subsystems"`
+}
+
+// subsystemRequest is the request of the /admin/subsystems/disable and
+// /admin/subsystems/enable endpoints.
+type subsystemRequest struct {
+	Subsystem swim.Subsystem `json:"subsystem"`
+}
+
+func (rp *Ringpop) adminSubsystemsHandler(ctx json.Context, req *Arg) (*subsystemsResponse, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/subsystems", swim.AdminRead); err != nil {
+		return nil, err
+	}
+
+	subsystems, err := rp.Subsystems()
+	if err != nil {
+		return nil, err
+	}
+	return &subsystemsResponse{Subsystems: subsystems}, nil
+}
+
+func (rp *Ringpop) adminSubsystemDisableHandler(ctx json.Context, req *subsystemRequest) (*subsystemsResponse, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/subsystems/disable", swim.AdminWrite); err != nil {
+		return nil, err
+	}
+
+	if err := rp.DisableSubsystem(req.Subsystem); err != nil {
+		return nil, err
+	}
+	return rp.adminSubsystemsHandler(ctx, &Arg{})
+}
+
+func (rp *Ringpop) adminSubsystemEnableHandler(ctx json.Context, req *subsystemRequest) (*subsystemsResponse, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/subsystems/enable", swim.AdminWrite); err != nil {
+		return nil, err
+	}
+
+	if err := rp.EnableSubsystem(req.Subsystem); err != nil {
+		return nil, err
+	}
+	return rp.adminSubsystemsHandler(ctx, &Arg{})
+}
+
+// membersUpdateRequest is the request of the /admin/members/update endpoint.
+type membersUpdateRequest struct {
+	Updates []swim.MemberUpdate `json:"updates"`
+}
+
+func (rp *Ringpop) adminMembersUpdateHandler(ctx json.Context, req *membersUpdateRequest) (*Arg, error) {
+	if err := rp.authorizeAdmin(ctx, "/admin/members/update", swim.AdminWrite); err != nil {
+		return nil, err
+	}
+
+	if err := rp.BulkUpdate(req.Updates); err != nil {
+		return nil, err
+	}
+	return &Arg{}, nil
+}
+
 func (rp *Ringpop) adminReloadHandler(ctx json.Context, req *Arg) (*Arg, error) {
 	return nil, nil
 }
@@ -0,0 +1,291 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gl-works/ringpop-go/events"
+)
+
+// A Checksummer computes one version of the checksum of a HashRing. Rings
+// compare checksums to detect that they diverged, so a change of the ring that
+// is not covered by the checksum goes unnoticed. A ring can compute several
+// versions at once, see Checksummers, which allows a cluster to move to a new
+// version while members still compare the old one.
+type Checksummer interface {
+	// Name identifies the version of the checksum, e.g. "v2".
+	Name() string
+
+	// Checksum computes the checksum of the state of the ring with the hash
+	// function of the ring.
+	Checksum(state ChecksumState, hash func([]byte) uint32) uint32
+}
+
+// ChecksumState is the state of a HashRing a Checksummer computes the
+// checksum over.
+type ChecksumState struct {
+	// Placement describes how keys are placed: "consistent", "rendezvous"
+	// or the size of a maglev table, e.g. "maglev-65537".
+	Placement string
+
+	// ReplicaPoints is the number of replica points of the ring.
+	ReplicaPoints int
+
+	// Servers are the servers on the ring, ordered by address.
+	Servers []ChecksumServer
+}
+
+// A ChecksumServer is the state of a server a Checksummer computes the
+// checksum over.
+type ChecksumServer struct {
+	Address  string
+	Identity string
+	Points   int
+	Labels   map[string]string
+}
+
+var (
+	// ChecksumV1 is the checksum of the addresses of the servers. The
+	// identity and replica points of a server are only covered when they
+	// were changed, and the placement of keys only when it is not consistent
+	// hashing. It is the checksum of a ring unless Checksummers is set.
+	ChecksumV1 Checksummer = checksumV1{}
+
+	// ChecksumV2 covers the placement of keys, and the address, identity,
+	// replica points and labels of every server, see SetServerLabels.
+	ChecksumV2 Checksummer = checksumV2{}
+)
+
+type checksumV1 struct{}
+
+func (checksumV1) Name() string { return "v1" }
+
+func (checksumV1) Checksum(state ChecksumState, hash func([]byte) uint32) uint32 {
+	names := make([]string, len(state.Servers))
+	for i, server := range state.Servers {
+		name := server.Address
+		if server.Identity != server.Address {
+			name = fmt.Sprintf("%s@%s", name, server.Identity)
+		}
+		if server.Points != state.ReplicaPoints {
+			name = fmt.Sprintf("%s#%d", name, server.Points)
+		}
+		names[i] = name
+	}
+	sort.Strings(names)
+	// rings that place keys differently must not have the same checksum
+	if state.Placement != "consistent" {
+		names = append([]string{state.Placement}, names...)
+	}
+	return hash([]byte(strings.Join(names, ";")))
+}
+
+type checksumV2 struct{}
+
+func (checksumV2) Name() string { return "v2" }
+
+// Checksum writes every field length-prefixed, so that no address, identity
+// or label can be crafted to read as other fields, e.g. the labels
+// {"a": "1,b=2"} and {"a": "1", "b": "2"} have different checksums.
+func (checksumV2) Checksum(state ChecksumState, hash func([]byte) uint32) uint32 {
+	var buf bytes.Buffer
+	write := func(field string) {
+		fmt.Fprintf(&buf, "%d:%s", len(field), field)
+	}
+
+	write(state.Placement)
+	for _, server := range state.Servers {
+		write(server.Address)
+		write(server.Identity)
+		write(strconv.Itoa(server.Points))
+
+		keys := make([]string, 0, len(server.Labels))
+		for key := range server.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		write(strconv.Itoa(len(keys)))
+		for _, key := range keys {
+			write(key)
+			write(server.Labels[key])
+		}
+	}
+	return hash(buf.Bytes())
+}
+
+// Checksummers sets the versions of the checksum the HashRing computes. The
+// first is the checksum of the ring returned by Checksum, the others are also
+// computed and returned by Checksums. To change the version of the checksum
+// of a cluster, members first compute the new version along with the old one,
+// and then make it the first once all members compute it.
+func Checksummers(checksummers ...Checksummer) Option {
+	return func(r *HashRing) error {
+		if len(checksummers) == 0 {
+			return errors.New("at least one checksummer is required")
+		}
+		names := make(map[string]bool, len(checksummers))
+		for _, checksummer := range checksummers {
+			if names[checksummer.Name()] {
+				return fmt.Errorf("checksummer %s is set more than once", checksummer.Name())
+			}
+			names[checksummer.Name()] = true
+		}
+		r.checksummers = checksummers
+		return nil
+	}
+}
+
+// Checksums returns the checksum of every version the HashRing computes, by
+// the name of the version.
+func (r *HashRing) Checksums() map[string]uint32 {
+	r.RLock()
+	defer r.RUnlock()
+
+	checksums := make(map[string]uint32, len(r.checksums))
+	for name, checksum := range r.checksums {
+		checksums[name] = checksum
+	}
+	return checksums
+}
+
+// SetServerLabels sets the labels of a server, which are covered by
+// ChecksumV2. The labels do not change where keys are placed. Returns whether
+// the labels changed.
+func (r *HashRing) SetServerLabels(address string, labels map[string]string) bool {
+	r.Lock()
+	before := r.labels[address]
+	ok := r.setServerLabelsNoLock(address, labels)
+	var checksumEvent events.RingChecksumEvent
+	var update events.ServerUpdate
+	if ok {
+		checksumEvent = r.computeChecksumNoLock()
+		update = events.ServerUpdate{
+			Server:       address,
+			LabelsBefore: copyLabels(before),
+			LabelsAfter:  copyLabels(r.labels[address]),
+		}
+	}
+	r.Unlock()
+	r.fillMaglev()
+
+	if ok && checksumEvent.OldChecksum != checksumEvent.NewChecksum {
+		r.emit(checksumEvent)
+	}
+	if ok {
+		r.emit(events.RingChangedEvent{ServersUpdated: []events.ServerUpdate{update}})
+	}
+	return ok
+}
+
+// serverUpdateNoLock describes an update of the server that leaves its labels
+// unchanged. This function isn't thread-safe, only call it when the HashRing
+// is locked.
+func (r *HashRing) serverUpdateNoLock(address string) events.ServerUpdate {
+	return events.ServerUpdate{
+		Server:       address,
+		LabelsBefore: copyLabels(r.labels[address]),
+		LabelsAfter:  copyLabels(r.labels[address]),
+	}
+}
+
+// ServerLabels returns the labels of a server set with SetServerLabels.
+func (r *HashRing) ServerLabels(address string) map[string]string {
+	r.RLock()
+	defer r.RUnlock()
+	return copyLabels(r.labels[address])
+}
+
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) setServerLabelsNoLock(address string, labels map[string]string) bool {
+	if equalLabels(r.labels[address], labels) {
+		return false
+	}
+
+	if len(labels) == 0 {
+		delete(r.labels, address)
+	} else {
+		if r.labels == nil {
+			r.labels = make(map[string]map[string]string)
+		}
+		r.labels[address] = copyLabels(labels)
+	}
+
+	_, onRing := r.serverSet[address]
+	return onRing
+}
+
+// checksumStateNoLock returns the state of the ring the checksums are
+// computed over.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) checksumStateNoLock() ChecksumState {
+	state := ChecksumState{
+		Placement:     "consistent",
+		ReplicaPoints: r.replicaPoints,
+	}
+	switch {
+	case r.rendezvous:
+		state.Placement = "rendezvous"
+	case r.maglev != nil:
+		state.Placement = fmt.Sprintf("maglev-%d", r.maglev.size)
+	}
+
+	servers := r.copyServersNoLock()
+	sort.Strings(servers)
+	state.Servers = make([]ChecksumServer, len(servers))
+	for i, server := range servers {
+		state.Servers[i] = ChecksumServer{
+			Address:  server,
+			Identity: r.identityNoLock(server),
+			Points:   r.pointsNoLock(server),
+			Labels:   r.labels[server],
+		}
+	}
+	return state
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	c := make(map[string]string, len(labels))
+	for key, value := range labels {
+		c[key] = value
+	}
+	return c
+}
+
+func equalLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
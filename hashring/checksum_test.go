@@ -0,0 +1,100 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"testing"
+
+	"github.com/dgryski/go-farm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumV1(t *testing.T) {
+	ring, err := NewHashRing(ReplicaPoints(10), Servers("127.0.0.1:3001", "127.0.0.1:3000"))
+	require.NoError(t, err)
+	assert.Equal(t, farm.Fingerprint32([]byte("127.0.0.1:3000;127.0.0.1:3001")), ring.Checksum(),
+		"expected v1 to be the checksum of the addresses")
+	assert.Equal(t, map[string]uint32{"v1": ring.Checksum()}, ring.Checksums())
+
+	ring.SetServerPoints("127.0.0.1:3000", 5)
+	ring.SetServerIdentity("127.0.0.1:3001", "node-1")
+	assert.Equal(t, farm.Fingerprint32([]byte("127.0.0.1:3000#5;127.0.0.1:3001@node-1")), ring.Checksum())
+
+	checksum := ring.Checksum()
+	assert.True(t, ring.SetServerLabels("127.0.0.1:3000", map[string]string{"rack": "a"}))
+	assert.Equal(t, checksum, ring.Checksum(), "expected v1 not to cover labels")
+}
+
+func TestChecksumV2(t *testing.T) {
+	ring, err := NewHashRing(ReplicaPoints(10), Checksummers(ChecksumV2, ChecksumV1),
+		Servers("127.0.0.1:3000", "127.0.0.1:3001"))
+	require.NoError(t, err)
+
+	checksums := ring.Checksums()
+	assert.Len(t, checksums, 2)
+	assert.Equal(t, checksums["v2"], ring.Checksum(), "expected the first version to be the checksum of the ring")
+
+	assert.True(t, ring.SetServerLabels("127.0.0.1:3000", map[string]string{"rack": "a"}))
+	assert.False(t, ring.SetServerLabels("127.0.0.1:3000", map[string]string{"rack": "a"}),
+		"expected unchanged labels not to change the ring")
+	assert.Equal(t, map[string]string{"rack": "a"}, ring.ServerLabels("127.0.0.1:3000"))
+
+	labeled := ring.Checksums()
+	assert.NotEqual(t, checksums["v2"], labeled["v2"], "expected v2 to cover labels")
+	assert.Equal(t, checksums["v1"], labeled["v1"])
+
+	c := ring.Copy()
+	assert.Equal(t, labeled, c.Checksums())
+	assert.Equal(t, map[string]string{"rack": "a"}, c.ServerLabels("127.0.0.1:3000"))
+
+	ring.RemoveServer("127.0.0.1:3000")
+	assert.Nil(t, ring.ServerLabels("127.0.0.1:3000"), "expected labels to be removed with the server")
+	ring.AddServer("127.0.0.1:3000")
+	assert.Equal(t, checksums, ring.Checksums())
+}
+
+func TestChecksumV2LabelEncoding(t *testing.T) {
+	ring, err := NewHashRing(Checksummers(ChecksumV2), Servers("127.0.0.1:3000"))
+	require.NoError(t, err)
+
+	ring.SetServerLabels("127.0.0.1:3000", map[string]string{"a": "1,b=2"})
+	joined := ring.Checksum()
+	ring.SetServerLabels("127.0.0.1:3000", map[string]string{"a": "1", "b": "2"})
+	assert.NotEqual(t, joined, ring.Checksum(), "expected labels not to be read as other labels")
+}
+
+func TestChecksumV2Placement(t *testing.T) {
+	consistent, err := NewHashRing(Checksummers(ChecksumV2), Servers(genServers(3)...))
+	require.NoError(t, err)
+	rendezvous, err := NewHashRing(Checksummers(ChecksumV2), Rendezvous(), Servers(genServers(3)...))
+	require.NoError(t, err)
+	assert.NotEqual(t, consistent.Checksum(), rendezvous.Checksum(),
+		"expected rings that place keys differently to have different checksums")
+}
+
+func TestChecksummersInvalid(t *testing.T) {
+	_, err := NewHashRing(Checksummers())
+	assert.Error(t, err, "expected a checksummer to be required")
+
+	_, err = NewHashRing(Checksummers(ChecksumV1, ChecksumV1))
+	assert.Error(t, err, "expected versions to be unique")
+}
@@ -23,14 +23,10 @@
 package hashring
 
 import (
-	"fmt"
-	"sort"
-	"strings"
+	"errors"
 	"sync"
 
 	"github.com/gl-works/ringpop-go/events"
-
-	"github.com/dgryski/go-farm"
 )
 
 // Configuration is a configuration struct that can be passed to the
@@ -41,6 +37,32 @@ type Configuration struct {
 	// more computation when building or traversing the ring (typically on
 	// lookups or membership changes).
 	ReplicaPoints int
+
+	// HashFunc is the hash function that places keys and replica points on
+	// the ring. It defaults to farmhash32.
+	HashFunc func([]byte) uint32
+
+	// HashFunc64 is a 64-bit hash function that places keys and replica
+	// points on the ring. It takes precedence over HashFunc.
+	HashFunc64 func([]byte) uint64
+
+	// Rendezvous places keys by rendezvous hashing instead of on replica
+	// points, see func Rendezvous.
+	Rendezvous bool
+
+	// Maglev looks up the owners of keys in a maglev table, see func Maglev.
+	// The table has DefaultMaglevTableSize entries, and is filled again in
+	// full on every change of the servers.
+	Maglev bool
+
+	// Checksummers are the versions of the checksum the ring computes, see
+	// func Checksummers. The ring computes ChecksumV1 if it is empty.
+	Checksummers []Checksummer
+
+	// HashName names the hash function in the ring configuration that is
+	// compared when joining a cluster. When it is empty, custom hash functions
+	// are identified by the hash of a fixed input.
+	HashName string
 }
 
 // HashRing stores strings on a consistent hash ring. HashRing internally uses
@@ -51,22 +73,74 @@ type HashRing struct {
 	hashfunc      func(string) int
 	replicaPoints int
 
+	// keyspace is the number of distinct hashes of the hash function, which
+	// is 2^32 or 2^64.
+	keyspace float64
+
+	// checksumfunc computes the checksum of the ring with the hash function
+	// of the ring, so rings that hash differently have different checksums.
+	checksumfunc func([]byte) uint32
+
+	// checksummers compute the versions of the checksum in checksums, the
+	// first of which is the checksum of the ring, see Checksummers.
+	checksummers []Checksummer
+	checksums    map[string]uint32
+
+	// rendezvous is true if keys are placed by rendezvous hashing, in which
+	// case the trees contain no replica points, see Rendezvous.
+	rendezvous bool
+
+	// maglev is the lookup table of the owners of keys if the ring is a
+	// maglev ring, see Maglev, and nil otherwise.
+	maglev *maglevRing
+
 	serverSet map[string]struct{}
 	tree      *redBlackTree
 	checksum  uint32
 
-	listeners []events.EventListener
+	// bootstrapServers are the servers provided via the Servers option. They
+	// are added to the ring once all options have been applied.
+	bootstrapServers []string
+
+	// standby is the failover ring that excludes suspect servers.
+	standby standbyRing
+
+	// points contains the servers that have a different number of replica
+	// points than replicaPoints, see SetServerPoints.
+	points map[string]int
+
+	// identities contains the servers that are placed on the ring by an
+	// identity other than their address, see SetServerIdentity.
+	identities map[string]string
+
+	// labels contains the labels of servers, see SetServerLabels.
+	labels map[string]map[string]string
+
+	listeners struct {
+		list []events.EventListener
+		sync.RWMutex
+	}
 }
 
+// emit calls HandleEvent on all registered listeners. It must not be called
+// while the HashRing is locked, so listeners are free to call back into the
+// HashRing.
 func (r *HashRing) emit(event interface{}) {
-	for _, listener := range r.listeners {
+	r.listeners.RLock()
+	listeners := r.listeners.list
+	r.listeners.RUnlock()
+
+	for _, listener := range listeners {
 		listener.HandleEvent(event)
 	}
 }
 
-// RegisterListener adds a listener that will listen for hashring events.
+// RegisterListener adds a listener that will listen for hashring events. It is
+// safe to call RegisterListener concurrently with other HashRing methods.
 func (r *HashRing) RegisterListener(l events.EventListener) {
-	r.listeners = append(r.listeners, l)
+	r.listeners.Lock()
+	r.listeners.list = append(r.listeners.list, l)
+	r.listeners.Unlock()
 }
 
 // New instantiates and returns a new HashRing.
@@ -76,6 +150,9 @@ func New(hashfunc func([]byte) uint32, replicaPoints int) *HashRing {
 		hashfunc: func(str string) int {
 			return int(hashfunc([]byte(str)))
 		},
+		checksumfunc: hashfunc,
+		checksummers: []Checksummer{ChecksumV1},
+		keyspace:     keyspace32,
 	}
 
 	r.serverSet = make(map[string]struct{})
@@ -83,6 +160,100 @@ func New(hashfunc func([]byte) uint32, replicaPoints int) *HashRing {
 	return r
 }
 
+// NewHashRing instantiates and returns a new HashRing configured with the
+// given options. Options that are not provided fall back to their defaults,
+// see defaultOptions. An error is returned if any of the options are invalid.
+//
+// Example:
+//
+//     ring, err := hashring.NewHashRing(
+//         hashring.ReplicaPoints(100),
+//         hashring.Servers("10.0.0.1:3000", "10.0.0.2:3000"),
+//     )
+//
+func NewHashRing(opts ...Option) (*HashRing, error) {
+	r := &HashRing{
+		serverSet: make(map[string]struct{}),
+		tree:      &redBlackTree{},
+	}
+
+	if err := applyOptions(r, defaultOptions); err != nil {
+		return nil, err
+	}
+
+	if err := applyOptions(r, opts); err != nil {
+		return nil, err
+	}
+
+	if r.rendezvous && r.maglev != nil {
+		return nil, errors.New("a ring cannot be both a rendezvous and a maglev ring")
+	}
+
+	if len(r.bootstrapServers) > 0 {
+		r.AddRemoveServers(r.bootstrapServers, nil)
+		r.bootstrapServers = nil
+	}
+
+	return r, nil
+}
+
+// Copy returns an independent copy of the HashRing with the same servers,
+// replica points and suspects. Listeners are not copied. Copying takes time
+// linear in the number of replica points on the ring.
+func (r *HashRing) Copy() *HashRing {
+	r.RLock()
+	defer r.RUnlock()
+
+	c := &HashRing{
+		hashfunc:      r.hashfunc,
+		keyspace:      r.keyspace,
+		checksumfunc:  r.checksumfunc,
+		checksummers:  r.checksummers,
+		checksums:     make(map[string]uint32, len(r.checksums)),
+		rendezvous:    r.rendezvous,
+		maglev:        r.maglev.copy(),
+		replicaPoints: r.replicaPoints,
+		serverSet:     make(map[string]struct{}, len(r.serverSet)),
+		tree:          r.tree.copy(),
+		checksum:      r.checksum,
+	}
+	for server := range r.serverSet {
+		c.serverSet[server] = struct{}{}
+	}
+	for name, checksum := range r.checksums {
+		c.checksums[name] = checksum
+	}
+	if r.points != nil {
+		c.points = make(map[string]int, len(r.points))
+		for server, points := range r.points {
+			c.points[server] = points
+		}
+	}
+	if r.identities != nil {
+		c.identities = make(map[string]string, len(r.identities))
+		for server, identity := range r.identities {
+			c.identities[server] = identity
+		}
+	}
+	if r.labels != nil {
+		c.labels = make(map[string]map[string]string, len(r.labels))
+		for server, labels := range r.labels {
+			c.labels[server] = labels
+		}
+	}
+	if r.standby.suspects != nil {
+		c.standby.suspects = make(map[string]struct{}, len(r.standby.suspects))
+		for server := range r.standby.suspects {
+			c.standby.suspects[server] = struct{}{}
+		}
+	}
+	c.standby.tree = r.standby.tree.copy()
+
+	// the copy fills the table of a change whose table is not filled yet
+	c.fillMaglev()
+	return c
+}
+
 // Checksum returns the checksum of all stored servers in the HashRing
 // Use this value to find out if the HashRing is mutated.
 func (r *HashRing) Checksum() uint32 {
@@ -92,30 +263,45 @@ func (r *HashRing) Checksum() uint32 {
 	return checksum
 }
 
-// computeChecksum computes checksum of all servers in the ring.
+// computeChecksum computes the checksums of the ring and returns the
+// RingChecksumEvent that describes the change of the checksum of the ring.
 // This function isn't thread-safe, only call it when the HashRing is locked.
-func (r *HashRing) computeChecksumNoLock() {
-	addresses := r.copyServersNoLock()
-	sort.Strings(addresses)
-	bytes := []byte(strings.Join(addresses, ";"))
+func (r *HashRing) computeChecksumNoLock() events.RingChecksumEvent {
+	state := r.checksumStateNoLock()
+	r.checksums = make(map[string]uint32, len(r.checksummers))
+	for _, checksummer := range r.checksummers {
+		r.checksums[checksummer.Name()] = checksummer.Checksum(state, r.checksumfunc)
+	}
 	old := r.checksum
-	r.checksum = farm.Fingerprint32(bytes)
+	r.checksum = r.checksums[r.checksummers[0].Name()]
 
-	r.emit(events.RingChecksumEvent{
+	if r.maglev != nil {
+		// the checksum is computed after every change of the servers, so the
+		// table is filled again along with it
+		r.rebuildMaglevNoLock()
+	}
+
+	return events.RingChecksumEvent{
 		OldChecksum: old,
 		NewChecksum: r.checksum,
-	})
+	}
 }
 
 // AddServer adds a server and its replicas onto the HashRing.
 func (r *HashRing) AddServer(address string) bool {
 	r.Lock()
 	ok := r.addServerNoLock(address)
+	var checksumEvent events.RingChecksumEvent
 	if ok {
-		r.computeChecksumNoLock()
-		r.emit(events.RingChangedEvent{[]string{address}, nil})
+		checksumEvent = r.computeChecksumNoLock()
 	}
 	r.Unlock()
+	r.fillMaglev()
+
+	if ok {
+		r.emit(checksumEvent)
+		r.emit(events.RingChangedEvent{ServersAdded: []string{address}})
+	}
 	return ok
 }
 
@@ -132,9 +318,9 @@ func (r *HashRing) addServerNoLock(address string) bool {
 // This function isn't thread-safe, only call it when the HashRing is locked.
 func (r *HashRing) addReplicasNoLock(server string) {
 	r.serverSet[server] = struct{}{}
-	for i := 0; i < r.replicaPoints; i++ {
-		address := fmt.Sprintf("%s%v", server, i)
-		r.tree.Insert(r.hashfunc(address), server)
+	r.insertReplicasNoLock(r.tree, server)
+	if r.standby.tree != nil {
+		r.insertReplicasNoLock(r.standby.tree, server)
 	}
 }
 
@@ -142,11 +328,17 @@ func (r *HashRing) addReplicasNoLock(server string) {
 func (r *HashRing) RemoveServer(address string) bool {
 	r.Lock()
 	ok := r.removeServerNoLock(address)
+	var checksumEvent events.RingChecksumEvent
 	if ok {
-		r.computeChecksumNoLock()
-		r.emit(events.RingChangedEvent{nil, []string{address}})
+		checksumEvent = r.computeChecksumNoLock()
 	}
 	r.Unlock()
+	r.fillMaglev()
+
+	if ok {
+		r.emit(checksumEvent)
+		r.emit(events.RingChangedEvent{ServersRemoved: []string{address}})
+	}
 	return ok
 }
 
@@ -156,49 +348,72 @@ func (r *HashRing) removeServerNoLock(address string) bool {
 		return false
 	}
 
+	if r.swapToStandbyNoLock(address) {
+		delete(r.points, address)
+		delete(r.identities, address)
+		delete(r.labels, address)
+		return true
+	}
+
 	r.removeReplicasNoLock(address)
+	delete(r.points, address)
+	delete(r.identities, address)
+	delete(r.labels, address)
 	return true
 }
 
 // This function isn't thread-safe, only call it when the HashRing is locked.
 func (r *HashRing) removeReplicasNoLock(server string) {
 	delete(r.serverSet, server)
-	for i := 0; i < r.replicaPoints; i++ {
-		address := fmt.Sprintf("%s%v", server, i)
-		r.tree.Delete(r.hashfunc(address))
+	r.deleteReplicasNoLock(r.tree, server)
+
+	if _, suspect := r.standby.suspects[server]; suspect {
+		delete(r.standby.suspects, server)
+		if len(r.standby.suspects) == 0 {
+			r.standby.tree = nil
+		}
+	} else if r.standby.tree != nil {
+		r.deleteReplicasNoLock(r.standby.tree, server)
 	}
 }
 
 // AddRemoveServers adds and removes servers and all replicas associated to those
 // servers to and from the HashRing. Returns whether the HashRing has changed.
+// The RingChangedEvent lists only the servers that were added or removed.
 func (r *HashRing) AddRemoveServers(add []string, remove []string) bool {
 	r.Lock()
-	result := r.addRemoveServersNoLock(add, remove)
+	added, removed := r.addRemoveServersNoLock(add, remove)
+	changed := len(added) > 0 || len(removed) > 0
+	var checksumEvent events.RingChecksumEvent
+	if changed {
+		checksumEvent = r.computeChecksumNoLock()
+	}
 	r.Unlock()
-	return result
+	r.fillMaglev()
+
+	if changed {
+		r.emit(checksumEvent)
+		r.emit(events.RingChangedEvent{ServersAdded: added, ServersRemoved: removed})
+	}
+	return changed
 }
 
+// addRemoveServersNoLock returns the servers that were added and removed.
 // This function isn't thread-safe, only call it when the HashRing is locked.
-func (r *HashRing) addRemoveServersNoLock(add []string, remove []string) bool {
-	changed := false
-
+func (r *HashRing) addRemoveServersNoLock(add []string, remove []string) (added, removed []string) {
 	for _, server := range add {
 		if r.addServerNoLock(server) {
-			changed = true
+			added = append(added, server)
 		}
 	}
 
 	for _, server := range remove {
 		if r.removeServerNoLock(server) {
-			changed = true
+			removed = append(removed, server)
 		}
 	}
 
-	if changed {
-		r.computeChecksumNoLock()
-		r.emit(events.RingChangedEvent{add, remove})
-	}
-	return changed
+	return added, removed
 }
 
 // HasServer returns whether the HashRing contains the given server.
@@ -237,6 +452,15 @@ func (r *HashRing) ServerCount() int {
 // Lookup returns the owner of the given key and whether the HashRing contains
 // the key at all.
 func (r *HashRing) Lookup(key string) (string, bool) {
+	if r.maglev != nil {
+		return r.lookupMaglev(key)
+	}
+	if r.rendezvous {
+		r.RLock()
+		defer r.RUnlock()
+		return r.rendezvousOwnerNoLock(key, nil)
+	}
+
 	strs := r.LookupN(key, 1)
 	if len(strs) == 0 {
 		return "", false
@@ -244,9 +468,13 @@ func (r *HashRing) Lookup(key string) (string, bool) {
 	return strs[0], true
 }
 
-// LookupN returns the N servers that own the given key. Duplicates in the form
-// of virtual nodes are skipped to maintain a list of unique servers. If there
-// are less servers than N, we simply return all existing servers.
+// LookupN returns the N servers that own the given key, in the order they are
+// found walking the ring clockwise from the hash of the key, or in order of
+// their score on a rendezvous ring, so the first server is the owner returned
+// by Lookup. On a maglev ring, the owner is followed by the servers found
+// walking the ring. Duplicates in the form of virtual nodes are skipped to
+// maintain a list of unique servers. If there are less servers than N, all
+// servers are returned in ring order.
 func (r *HashRing) LookupN(key string, n int) []string {
 	r.RLock()
 	servers := r.lookupNNoLock(key, n)
@@ -256,25 +484,36 @@ func (r *HashRing) LookupN(key string, n int) []string {
 
 // This function isn't thread-safe, only call it when the HashRing is locked.
 func (r *HashRing) lookupNNoLock(key string, n int) []string {
-	if n >= len(r.serverSet) {
-		return r.copyServersNoLock()
+	if n > len(r.serverSet) {
+		n = len(r.serverSet)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	if r.rendezvous {
+		return r.rendezvousNoLock(key, n, nil)
 	}
 
 	hash := r.hashfunc(key)
-	unique := make(map[string]struct{})
+	unique := newUniqueStrings(n)
+
+	// the owner of the key on a maglev ring is in the table, and the other
+	// servers are found on the replica points
+	if r.maglev != nil {
+		if owner, ok := r.maglev.table.Load().(*maglevTable).owner(hash); ok {
+			unique.add(owner)
+		}
+	}
 
 	// lookup N unique servers from the red-black tree. If we have not
 	// collected all the servers we want, we have reached the
 	// end of the red-black tree and we need to loop around and inspect the
-	// tree starting at 0.
+	// tree starting at its smallest value.
 	r.tree.LookupNUniqueAt(n, hash, unique)
-	if len(unique) < n {
-		r.tree.LookupNUniqueAt(n, 0, unique)
+	if unique.len() < n {
+		r.tree.LookupNUniqueAt(n, minValue, unique)
 	}
 
-	var servers []string
-	for server := range unique {
-		servers = append(servers, server)
-	}
-	return servers
+	return unique.list
 }
@@ -52,6 +52,26 @@ func (d *dummyListener) HandleEvent(event events.Event) {
 	d.l.Unlock()
 }
 
+// changeListener records the RingChangedEvents of a ring
+type changeListener struct {
+	l      sync.Mutex
+	events []events.RingChangedEvent
+}
+
+func (c *changeListener) HandleEvent(event events.Event) {
+	if event, ok := event.(events.RingChangedEvent); ok {
+		c.l.Lock()
+		c.events = append(c.events, event)
+		c.l.Unlock()
+	}
+}
+
+func (c *changeListener) last() events.RingChangedEvent {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.events[len(c.events)-1]
+}
+
 func TestAddServer(t *testing.T) {
 	ring := New(farm.Fingerprint32, 10)
 	l := &dummyListener{}
@@ -69,6 +89,37 @@ func TestAddServer(t *testing.T) {
 	assert.True(t, ring.HasServer("server1"), "expected server to be in ring")
 }
 
+func TestCopy(t *testing.T) {
+	ring := New(farm.Fingerprint32, 10)
+	ring.AddRemoveServers([]string{"server1", "server2", "server3"}, nil)
+	ring.SetServerPoints("server3", 5)
+	ring.SuspectServer("server2")
+
+	c := ring.Copy()
+	assert.Equal(t, ring.Checksum(), c.Checksum())
+	assert.Equal(t, 5, c.ServerPoints("server3"))
+	assert.True(t, c.IsSuspectServer("server2"))
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		expected, _ := ring.Lookup(key)
+		owner, _ := c.Lookup(key)
+		assert.Equal(t, expected, owner)
+		expectedN, ownersN := ring.LookupN(key, 2), c.LookupN(key, 2)
+		sort.Strings(expectedN)
+		sort.Strings(ownersN)
+		assert.Equal(t, expectedN, ownersN)
+	}
+
+	l := &dummyListener{}
+	c.RegisterListener(l)
+	ring.RemoveServer("server2")
+	assert.True(t, c.HasServer("server2"), "expected copy to be independent of the ring")
+	assert.Equal(t, 0, l.EventCount(), "expected no events on the copy")
+
+	c.AddServer("server4")
+	assert.False(t, ring.HasServer("server4"), "expected ring to be independent of the copy")
+}
+
 func TestRemoveServer(t *testing.T) {
 	ring := New(farm.Fingerprint32, 10)
 	l := &dummyListener{}
@@ -160,6 +211,44 @@ func TestAddRemoveServers(t *testing.T) {
 	assert.NotEqual(t, oldChecksum, ring.Checksum(), "expected checksum to change")
 }
 
+func TestRingChangedEventDiff(t *testing.T) {
+	ring := New(farm.Fingerprint32, 10)
+	l := &changeListener{}
+	ring.RegisterListener(l)
+
+	ring.AddRemoveServers([]string{"server1", "server2"}, nil)
+	ring.AddRemoveServers([]string{"server2", "server3"}, []string{"server1", "server4"})
+	assert.Equal(t, events.RingChangedEvent{
+		ServersAdded:   []string{"server3"},
+		ServersRemoved: []string{"server1"},
+	}, l.last(), "expected only servers that changed to be listed")
+
+	ring.SetServerLabels("server2", map[string]string{"zone": "a"})
+	ring.SetServerLabels("server2", map[string]string{"zone": "b"})
+	assert.Equal(t, events.RingChangedEvent{
+		ServersUpdated: []events.ServerUpdate{{
+			Server:       "server2",
+			LabelsBefore: map[string]string{"zone": "a"},
+			LabelsAfter:  map[string]string{"zone": "b"},
+		}},
+	}, l.last())
+
+	ring.SetServerPoints("server2", 5)
+	assert.Equal(t, events.RingChangedEvent{
+		ServersUpdated: []events.ServerUpdate{{
+			Server:       "server2",
+			LabelsBefore: map[string]string{"zone": "b"},
+			LabelsAfter:  map[string]string{"zone": "b"},
+		}},
+	}, l.last(), "expected labels of a server with changed points to be unchanged")
+
+	ring.SetServerIdentity("server3", "node-3")
+	assert.Equal(t, events.RingChangedEvent{
+		ServersUpdated: []events.ServerUpdate{{Server: "server3"}},
+	}, l.last())
+	assert.Len(t, l.events, 6)
+}
+
 func TestLookup(t *testing.T) {
 	ring := New(farm.Fingerprint32, 10)
 	ring.AddServer("server1")
@@ -268,13 +357,9 @@ func TestLookupNLoopAround(t *testing.T) {
 	addresses := genAddresses(1, 1, 10)
 	ring.AddRemoveServers(addresses, nil)
 
-	unique := make(map[string]struct{})
+	unique := newUniqueStrings(1)
 	ring.tree.LookupNUniqueAt(1, 0, unique)
-	var firstInTree string
-	for server := range unique {
-		firstInTree = server
-		break
-	}
+	firstInTree := unique.list[0]
 
 	firstResult, ok := ring.Lookup("a random key")
 	assert.True(t, ok, "expected to obtain server that owns key")
@@ -352,3 +437,81 @@ func BenchmarkHashRingLookupN(b *testing.B) {
 		}
 	}
 }
+
+// reentrantListener calls back into the ring while handling an event.
+type reentrantListener struct {
+	ring      *HashRing
+	checksums []uint32
+}
+
+func (l *reentrantListener) HandleEvent(event events.Event) {
+	l.checksums = append(l.checksums, l.ring.Checksum())
+}
+
+func TestListenerCanCallIntoRing(t *testing.T) {
+	ring := New(farm.Fingerprint32, 10)
+	l := &reentrantListener{ring: ring}
+	ring.RegisterListener(l)
+
+	ring.AddServer("server1")
+	ring.AddRemoveServers([]string{"server2"}, nil)
+	ring.RemoveServer("server1")
+
+	assert.Len(t, l.checksums, 6, "expected listener to be called for every event")
+	assert.Equal(t, ring.Checksum(), l.checksums[5], "expected listener to observe the new checksum")
+}
+
+func TestLookupNNonPositive(t *testing.T) {
+	ring := New(farm.Fingerprint32, 10)
+	ring.AddRemoveServers(genAddresses(1, 1, 3), nil)
+
+	assert.Empty(t, ring.LookupN("key", 0), "expected no servers for n == 0")
+	assert.Empty(t, ring.LookupN("key", -1), "expected no servers for n < 0")
+}
+
+// TestLookupNRingOrder tests that LookupN returns the owners in the order they
+// are found walking the ring clockwise from the hash of the key, which Trace
+// reconstructs from the sorted replica points.
+func TestLookupNRingOrder(t *testing.T) {
+	ring := New(farm.Fingerprint32, 10)
+	ring.AddRemoveServers(genAddresses(1, 1, 10), nil)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		for _, n := range []int{1, 3, 10} {
+			assert.Equal(t, ring.Trace(key, n).Owners, ring.LookupN(key, n),
+				"expected owners of %s in ring order", key)
+		}
+		owner, _ := ring.Lookup(key)
+		assert.Equal(t, owner, ring.LookupN(key, 3)[0], "expected owner to come first")
+	}
+}
+
+// TestLookupNMoreThanServers tests that asking for more owners than there are
+// servers returns every server once, starting with the owner of the key.
+func TestLookupNMoreThanServers(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	addresses := genAddresses(1, 1, 3)
+	ring.AddRemoveServers(addresses, nil)
+
+	servers := ring.LookupN("key", 10)
+	assert.Equal(t, ring.Trace("key", 3).Owners, servers)
+
+	sorted := append([]string(nil), servers...)
+	sort.Strings(sorted)
+	sort.Strings(addresses)
+	assert.Equal(t, addresses, sorted, "expected every server once")
+}
+
+// TestLookupNDeduplicatesReplicaPoints tests that servers whose replica points
+// follow each other on the ring are returned only once.
+func TestLookupNDeduplicatesReplicaPoints(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genAddresses(1, 1, 2), nil)
+
+	for i := 0; i < 100; i++ {
+		servers := ring.LookupN(fmt.Sprintf("key%d", i), 2)
+		assert.Len(t, servers, 2)
+		assert.NotEqual(t, servers[0], servers[1], "expected distinct owners")
+	}
+}
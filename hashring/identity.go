@@ -0,0 +1,118 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import "github.com/gl-works/ringpop-go/events"
+
+// SetServerIdentity sets the identity a server is placed on the ring by,
+// instead of its address. The replica points of a server are hashes of its
+// identity, so a server that keeps its identity when its address changes
+// owns the same part of the keyspace. Identities must be unique among the
+// servers on the ring. An empty identity, or the address itself, places the
+// server by its address. Returns whether the ring changed.
+func (r *HashRing) SetServerIdentity(address, identity string) bool {
+	r.Lock()
+	ok := r.setServerIdentityNoLock(address, identity)
+	var checksumEvent events.RingChecksumEvent
+	var update events.ServerUpdate
+	if ok {
+		checksumEvent = r.computeChecksumNoLock()
+		update = r.serverUpdateNoLock(address)
+	}
+	r.Unlock()
+	r.fillMaglev()
+
+	if ok {
+		r.emit(checksumEvent)
+		r.emit(events.RingChangedEvent{ServersUpdated: []events.ServerUpdate{update}})
+	}
+	return ok
+}
+
+// ServerIdentity returns the identity a server is placed on the ring by,
+// which is its address unless it was changed with SetServerIdentity.
+func (r *HashRing) ServerIdentity(address string) string {
+	r.RLock()
+	defer r.RUnlock()
+	return r.identityNoLock(address)
+}
+
+// IdentityServer returns the server on the ring that is placed by the given
+// identity. Ok is false if no server has the identity.
+func (r *HashRing) IdentityServer(identity string) (server string, ok bool) {
+	r.RLock()
+	defer r.RUnlock()
+
+	for server, id := range r.identities {
+		if _, onRing := r.serverSet[server]; onRing && id == identity {
+			return server, true
+		}
+	}
+	return "", false
+}
+
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) setServerIdentityNoLock(address, identity string) bool {
+	if identity == "" {
+		identity = address
+	}
+
+	if r.identityNoLock(address) == identity {
+		return false
+	}
+
+	_, onRing := r.serverSet[address]
+	_, suspect := r.standby.suspects[address]
+
+	if onRing {
+		r.deleteReplicasNoLock(r.tree, address)
+		if r.standby.tree != nil && !suspect {
+			r.deleteReplicasNoLock(r.standby.tree, address)
+		}
+	}
+
+	if identity == address {
+		delete(r.identities, address)
+	} else {
+		if r.identities == nil {
+			r.identities = make(map[string]string)
+		}
+		r.identities[address] = identity
+	}
+
+	if onRing {
+		r.insertReplicasNoLock(r.tree, address)
+		if r.standby.tree != nil && !suspect {
+			r.insertReplicasNoLock(r.standby.tree, address)
+		}
+	}
+
+	return onRing
+}
+
+// identityNoLock returns the identity of a server.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) identityNoLock(server string) string {
+	if identity, ok := r.identities[server]; ok {
+		return identity
+	}
+	return server
+}
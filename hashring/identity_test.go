@@ -0,0 +1,81 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dgryski/go-farm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetServerIdentity(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genServers(4), nil)
+	checksum := ring.Checksum()
+
+	assert.True(t, ring.SetServerIdentity("127.0.0.1:3000", "node-0"))
+	assert.Equal(t, "node-0", ring.ServerIdentity("127.0.0.1:3000"))
+	assert.Equal(t, "127.0.0.1:3001", ring.ServerIdentity("127.0.0.1:3001"))
+	assert.NotEqual(t, checksum, ring.Checksum(), "expected identity to be part of the checksum")
+	assert.False(t, ring.SetServerIdentity("127.0.0.1:3000", "node-0"), "expected no change for the same identity")
+
+	server, ok := ring.IdentityServer("node-0")
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.1:3000", server)
+
+	assert.True(t, ring.SetServerIdentity("127.0.0.1:3000", ""))
+	assert.Equal(t, checksum, ring.Checksum())
+	_, ok = ring.IdentityServer("node-0")
+	assert.False(t, ok)
+
+	expected := New(farm.Fingerprint32, 100)
+	expected.AddRemoveServers(genServers(4), nil)
+	assertSameOwners(t, expected, ring)
+}
+
+func TestServerIdentityKeepsKeysAcrossAddresses(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genServers(3), nil)
+	ring.SetServerIdentity("127.0.0.1:3000", "node-0")
+	ring.SetServerIdentity("127.0.0.1:3001", "node-1")
+	ring.SetServerIdentity("127.0.0.1:3002", "node-2")
+
+	owners := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owners[key], _ = ring.Lookup(key)
+	}
+
+	// node-0 comes back on another address
+	ring.RemoveServer("127.0.0.1:3000")
+	assert.False(t, ring.SetServerIdentity("10.0.0.1:3000", "node-0"), "expected no change for a server not on the ring")
+	ring.AddServer("10.0.0.1:3000")
+
+	for key, owner := range owners {
+		actual, _ := ring.Lookup(key)
+		if owner == "127.0.0.1:3000" {
+			owner = "10.0.0.1:3000"
+		}
+		assert.Equal(t, owner, actual, "expected key %s to keep its owner", key)
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaglevTableSize is the size of the lookup table of a maglev ring if
+// none is given. The servers of a ring own shares of the keyspace that differ
+// by about 1/size.
+const DefaultMaglevTableSize = 65537
+
+// Maglev makes the HashRing look up the owner of a key in a table that maps
+// the hash of the key to a server, as in Google's Maglev load balancer, which
+// takes constant time and no lock instead of a search of the replica points
+// under the read lock of the ring. The table has the given number of entries,
+// which must be prime, or DefaultMaglevTableSize if it is zero. The servers
+// fill the table in turns, each in the order of its own permutation of the
+// entries, so the table is balanced and a change of the servers moves few
+// entries. The replica points of a server, see SetServerPoints, weight how
+// often it takes a turn. The permutation of a server is computed once, but
+// every change of the servers or of their replica points fills the whole
+// table again, as the turns of all servers shift: with 100 servers and the
+// default size, that takes about 4ms and allocates a new table of 256KiB. The
+// table is filled after the ring is unlocked and lookups use the previous
+// table meanwhile, so rapid membership changes cost CPU but do not hold up
+// lookups.
+//
+// Only the owner of a key is looked up in the table. The other servers
+// returned by LookupN, and the standby owner of a key whose owner is suspect,
+// are found on the replica points.
+func Maglev(size int) Option {
+	return func(r *HashRing) error {
+		if size == 0 {
+			size = DefaultMaglevTableSize
+		}
+		if size < 2 || !big.NewInt(int64(size)).ProbablyPrime(0) {
+			return errors.New("maglev table size must be prime")
+		}
+		r.maglev = &maglevRing{
+			size:         size,
+			permutations: make(map[string]maglevPermutation),
+		}
+		r.maglev.table.Store(&maglevTable{})
+		return nil
+	}
+}
+
+// IsMaglev returns whether the HashRing looks up the owners of keys in a
+// maglev table.
+func (r *HashRing) IsMaglev() bool {
+	return r.maglev != nil
+}
+
+// maglevRing is the lookup table of a maglev ring and the permutations of its
+// servers.
+type maglevRing struct {
+	size int
+
+	// table holds the current *maglevTable. Tables are never changed once
+	// they are stored, so lookups read them without locking the ring.
+	table atomic.Value
+
+	// permutations contains the permutation of every server identity on the
+	// ring. It is only accessed while the ring is locked.
+	permutations map[string]maglevPermutation
+
+	// fill holds the latest table to fill, pending, and the generations of
+	// the latest table and of the table stored last, so that a table filled
+	// concurrently with a later one never replaces it.
+	fill struct {
+		sync.Mutex
+		generation uint64
+		filled     uint64
+		pending    *maglevBuild
+	}
+}
+
+// maglevBuild is what a table is filled from: the servers in the order they
+// take turns, with their permutations and weights.
+type maglevBuild struct {
+	generation   uint64
+	size         int
+	servers      []string
+	permutations []maglevPermutation
+	weights      []float64
+	maxWeight    float64
+}
+
+// maglevTable maps entries to the index of the server that owns them.
+type maglevTable struct {
+	servers []string
+	entries []int32
+}
+
+// owner returns the server that owns the entry of the hash.
+func (t *maglevTable) owner(hash int) (string, bool) {
+	if len(t.entries) == 0 {
+		return "", false
+	}
+	return t.servers[t.entries[uint64(uint32(hash))%uint64(len(t.entries))]], true
+}
+
+// maglevPermutation is the order in which a server prefers the entries of the
+// table: entry (offset + i*skip) % size is its i-th preference.
+type maglevPermutation struct {
+	offset uint64
+	skip   uint64
+}
+
+// copy returns a copy of the maglev ring that shares its immutable table.
+func (m *maglevRing) copy() *maglevRing {
+	if m == nil {
+		return nil
+	}
+	c := &maglevRing{
+		size:         m.size,
+		permutations: make(map[string]maglevPermutation, len(m.permutations)),
+	}
+	for identity, permutation := range m.permutations {
+		c.permutations[identity] = permutation
+	}
+	c.table.Store(m.table.Load())
+
+	m.fill.Lock()
+	c.fill.generation = m.fill.generation
+	c.fill.filled = m.fill.filled
+	c.fill.pending = m.fill.pending
+	m.fill.Unlock()
+	return c
+}
+
+// lookupMaglev returns the owner of the key from the maglev table.
+func (r *HashRing) lookupMaglev(key string) (string, bool) {
+	return r.maglev.table.Load().(*maglevTable).owner(r.hashfunc(key))
+}
+
+// permutationNoLock returns the permutation of the server with the given
+// identity, computing it if the identity is new.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) permutationNoLock(identity string) maglevPermutation {
+	permutation, ok := r.maglev.permutations[identity]
+	if !ok {
+		size := uint64(r.maglev.size)
+		permutation = maglevPermutation{
+			offset: uint64(uint32(r.hashfunc(identity))) % size,
+			skip:   uint64(uint32(r.hashfunc(identity+"#skip")))%(size-1) + 1,
+		}
+		r.maglev.permutations[identity] = permutation
+	}
+	return permutation
+}
+
+// rebuildMaglevNoLock prepares a new table with the servers of the ring, which
+// fillMaglev fills and stores once the ring is unlocked. Servers take turns
+// in order of their address, a server with fewer replica points than the
+// others skips some of its turns, and in its turn a server takes the first
+// entry in its permutation that is still free.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) rebuildMaglevNoLock() {
+	servers := r.copyServersNoLock()
+	sort.Strings(servers)
+
+	identities := make(map[string]struct{}, len(servers))
+	permutations := make([]maglevPermutation, len(servers))
+	weights := make([]float64, len(servers))
+	maxWeight := 0.0
+	for i, server := range servers {
+		identity := r.identityNoLock(server)
+		identities[identity] = struct{}{}
+		permutations[i] = r.permutationNoLock(identity)
+		weights[i] = float64(r.pointsNoLock(server))
+		if weights[i] > maxWeight {
+			maxWeight = weights[i]
+		}
+	}
+	for identity := range r.maglev.permutations {
+		if _, ok := identities[identity]; !ok {
+			delete(r.maglev.permutations, identity)
+		}
+	}
+
+	r.maglev.fill.Lock()
+	r.maglev.fill.generation++
+	r.maglev.fill.pending = &maglevBuild{
+		generation:   r.maglev.fill.generation,
+		size:         r.maglev.size,
+		servers:      servers,
+		permutations: permutations,
+		weights:      weights,
+		maxWeight:    maxWeight,
+	}
+	r.maglev.fill.Unlock()
+}
+
+// fillMaglev fills the table prepared by rebuildMaglevNoLock, if any, and
+// replaces the current table with it. It is called after every change of the
+// servers once the ring is unlocked.
+func (r *HashRing) fillMaglev() {
+	if r.maglev == nil {
+		return
+	}
+
+	r.maglev.fill.Lock()
+	build := r.maglev.fill.pending
+	r.maglev.fill.pending = nil
+	r.maglev.fill.Unlock()
+
+	if build == nil {
+		return
+	}
+
+	table := &maglevTable{servers: build.servers}
+	if len(build.servers) > 0 {
+		table.entries = fillMaglevTable(build.size, build.permutations, build.weights, build.maxWeight)
+	}
+
+	r.maglev.fill.Lock()
+	if build.generation > r.maglev.fill.filled {
+		r.maglev.fill.filled = build.generation
+		r.maglev.table.Store(table)
+	}
+	r.maglev.fill.Unlock()
+}
+
+// fillMaglevTable returns a table of the given size in which every entry is
+// taken by one of the servers with the permutations and weights.
+func fillMaglevTable(size int, permutations []maglevPermutation, weights []float64, maxWeight float64) []int32 {
+	entries := make([]int32, size)
+	for i := range entries {
+		entries[i] = -1
+	}
+
+	next := make([]uint64, len(permutations))
+	credits := make([]float64, len(permutations))
+	for filled := 0; ; {
+		for i, permutation := range permutations {
+			credits[i] += weights[i] / maxWeight
+			if credits[i] < 1 {
+				continue
+			}
+			credits[i]--
+
+			entry := (permutation.offset + next[i]*permutation.skip) % uint64(size)
+			for entries[entry] >= 0 {
+				next[i]++
+				entry = (permutation.offset + next[i]*permutation.skip) % uint64(size)
+			}
+			entries[entry] = int32(i)
+			next[i]++
+
+			filled++
+			if filled == size {
+				return entries
+			}
+		}
+	}
+}
+
+// simulateMaglev computes the result of Simulate for a maglev ring by
+// comparing the entries of the table, or the owners of the keys of the
+// sample if keys are given, on the ring and on a changed copy.
+func (r *HashRing) simulateMaglev(sim Simulation) SimulationResult {
+	simulated := r.simulated(sim)
+
+	result := SimulationResult{
+		Gained: make(map[string]float64),
+		Lost:   make(map[string]float64),
+	}
+
+	current := r.maglev.table.Load().(*maglevTable)
+	changed := simulated.maglev.table.Load().(*maglevTable)
+	if len(current.entries) == 0 || len(changed.entries) == 0 {
+		// keys are not owned by anyone on an empty ring, so nothing moves
+		return result
+	}
+
+	if sim.Keys != nil {
+		result.MovedKeys = make(map[string]string)
+		for _, key := range sim.Keys {
+			from, _ := r.Lookup(key)
+			to, _ := simulated.Lookup(key)
+			if from != to {
+				result.MovedKeys[key] = to
+				result.record(from, to, 1/float64(len(sim.Keys)))
+			}
+		}
+		return result
+	}
+
+	share := 1 / float64(len(current.entries))
+	for i := range current.entries {
+		from := current.servers[current.entries[i]]
+		to := changed.servers[changed.entries[i]]
+		if from != to {
+			result.record(from, to, share)
+		}
+	}
+	return result
+}
+
+// maglevOwnership returns the fraction of the entries of the maglev table
+// owned by each server.
+func (r *HashRing) maglevOwnership() map[string]float64 {
+	ownership := make(map[string]float64)
+	table := r.maglev.table.Load().(*maglevTable)
+	share := 1 / float64(len(table.entries))
+	for _, index := range table.entries {
+		ownership[table.servers[index]] += share
+	}
+	return ownership
+}
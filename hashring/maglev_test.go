@@ -0,0 +1,204 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMaglevRing(t *testing.T, servers ...string) *HashRing {
+	ring, err := NewHashRing(Maglev(1009), ReplicaPoints(10), Servers(servers...))
+	require.NoError(t, err)
+	return ring
+}
+
+func TestMaglevLookup(t *testing.T) {
+	ring := newMaglevRing(t, genServers(5)...)
+	assert.True(t, ring.IsMaglev())
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner, ok := ring.Lookup(key)
+		require.True(t, ok)
+
+		servers := ring.LookupN(key, 10)
+		assert.Len(t, servers, 5, "expected every server once")
+		assert.Equal(t, owner, servers[0], "expected owner to be looked up in the table")
+		assert.Equal(t, servers, ring.Trace(key, 5).Owners)
+	}
+
+	empty := newMaglevRing(t)
+	_, ok := empty.Lookup("key")
+	assert.False(t, ok)
+}
+
+func TestMaglevConcurrentChanges(t *testing.T) {
+	ring := newMaglevRing(t)
+
+	var wg sync.WaitGroup
+	for _, server := range genServers(20) {
+		wg.Add(1)
+		go func(server string) {
+			defer wg.Done()
+			ring.AddServer(server)
+			ring.Lookup(server)
+		}(server)
+	}
+	wg.Wait()
+
+	servers := ring.Servers()
+	sort.Strings(servers)
+	table := ring.maglev.table.Load().(*maglevTable)
+	assert.Equal(t, servers, table.servers, "expected the table of the last change to be stored")
+}
+
+func TestMaglevTableSize(t *testing.T) {
+	_, err := NewHashRing(Maglev(1000))
+	assert.Error(t, err, "expected table size that is not prime to be rejected")
+
+	ring, err := NewHashRing(Maglev(0), Servers(genServers(2)...))
+	require.NoError(t, err)
+	assert.Len(t, ring.maglev.table.Load().(*maglevTable).entries, DefaultMaglevTableSize)
+
+	_, err = NewHashRing(Maglev(0), Rendezvous())
+	assert.Error(t, err, "expected rendezvous and maglev to be exclusive")
+}
+
+func TestMaglevBalance(t *testing.T) {
+	ring := newMaglevRing(t, genServers(4)...)
+	for server, share := range ring.Ownership() {
+		assert.InDelta(t, 0.25, share, 0.01, "expected %s to own a quarter of the table", server)
+	}
+
+	ring.SetServerPoints("127.0.0.1:3000", 20)
+	ownership := ring.Ownership()
+	assert.InDelta(t, 0.4, ownership["127.0.0.1:3000"], 0.01, "expected a server with twice the points to own twice the table")
+	assert.InDelta(t, 0.2, ownership["127.0.0.1:3001"], 0.01)
+}
+
+// TestMaglevMovement tests that adding a server moves about the share of the
+// keys it takes, and removing it again restores the owners.
+func TestMaglevMovement(t *testing.T) {
+	ring := newMaglevRing(t, genServers(4)...)
+	owners := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owners[key], _ = ring.Lookup(key)
+	}
+
+	ring.AddServer("127.0.0.1:3004")
+	moved := 0
+	for key, owner := range owners {
+		if newOwner, _ := ring.Lookup(key); newOwner != owner {
+			moved++
+		}
+	}
+	assert.InDelta(t, 200, moved, 100, "expected about a fifth of the keys to move")
+
+	ring.RemoveServer("127.0.0.1:3004")
+	for key, owner := range owners {
+		newOwner, _ := ring.Lookup(key)
+		assert.Equal(t, owner, newOwner, "expected key %s to move back", key)
+	}
+	assert.Len(t, ring.maglev.permutations, 4, "expected permutation of removed server to be dropped")
+}
+
+func TestMaglevStandby(t *testing.T) {
+	ring := newMaglevRing(t, genServers(3)...)
+	owner, _ := ring.Lookup("key")
+
+	standby, _ := ring.LookupStandby("key")
+	assert.Equal(t, owner, standby, "expected the owner without suspects")
+
+	ring.SuspectServer(owner)
+	standby, ok := ring.LookupStandby("key")
+	assert.True(t, ok)
+	assert.NotEqual(t, owner, standby, "expected another server to take over")
+}
+
+func TestMaglevChecksumAndCopy(t *testing.T) {
+	ring := newMaglevRing(t, genServers(3)...)
+	consistent, err := NewHashRing(ReplicaPoints(10), Servers(genServers(3)...))
+	require.NoError(t, err)
+	assert.NotEqual(t, consistent.Checksum(), ring.Checksum(),
+		"expected rings that place keys differently to have different checksums")
+
+	c := ring.Copy()
+	assert.Equal(t, ring.Checksum(), c.Checksum())
+	assert.True(t, c.IsMaglev())
+
+	c.RemoveServer("127.0.0.1:3000")
+	assert.Equal(t, 3, len(ring.Ownership()), "expected the copy to change independently")
+	assert.Equal(t, 2, len(c.Ownership()))
+}
+
+func TestMaglevSimulate(t *testing.T) {
+	ring := newMaglevRing(t, genServers(4)...)
+
+	result := ring.Simulate(Simulation{Add: []string{"127.0.0.1:3004"}})
+	assert.InDelta(t, 0.2, result.Moved, 0.1)
+	assert.InDelta(t, 0.2, result.Gained["127.0.0.1:3004"], 0.01)
+	assert.Nil(t, result.MovedKeys)
+	assert.Equal(t, 4, ring.ServerCount(), "expected the ring to be unchanged")
+
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	result = ring.Simulate(Simulation{Remove: []string{"127.0.0.1:3000"}, Keys: keys})
+	for key := range result.MovedKeys {
+		from, _ := ring.Lookup(key)
+		assert.Equal(t, "127.0.0.1:3000", from)
+	}
+	assert.InDelta(t, float64(len(result.MovedKeys))/float64(len(keys)), result.Moved, 1e-9)
+}
+
+func BenchmarkMaglevLookup(b *testing.B) {
+	ring, _ := NewHashRing(Maglev(0), Servers(genServers(100)...))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ring.Lookup("key")
+	}
+}
+
+func BenchmarkMaglevRebuild(b *testing.B) {
+	ring, _ := NewHashRing(Maglev(0), Servers(genServers(100)...))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ring.AddServer("127.0.0.1:4000")
+		ring.RemoveServer("127.0.0.1:4000")
+	}
+}
+
+func TestMaglevSimulatePoints(t *testing.T) {
+	ring := newMaglevRing(t, genServers(4)...)
+	server := "127.0.0.1:3000"
+	before := ring.Ownership()
+
+	result := ring.Simulate(Simulation{Points: map[string]int{server: 200}})
+	assert.True(t, result.Gained[server] > 0.1, "expected a server with more points to gain entries")
+
+	ring.SetServerPoints(server, 200)
+	assert.InDelta(t, ring.Ownership()[server]-before[server], result.Gained[server]-result.Lost[server], 1e-9)
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"errors"
+
+	"github.com/dgryski/go-farm"
+)
+
+// An Option is a modifier function that configures a HashRing created with
+// NewHashRing.
+type Option func(*HashRing) error
+
+// applyOptions applies the configuration options to the specified HashRing.
+func applyOptions(r *HashRing, opts []Option) error {
+	for _, option := range opts {
+		if err := option(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HashFunc sets the function that is used to hash both keys and the replica
+// points of servers onto the ring.
+func HashFunc(hashfunc func([]byte) uint32) Option {
+	return func(r *HashRing) error {
+		if hashfunc == nil {
+			return errors.New("hash function is required")
+		}
+		r.hashfunc = func(str string) int {
+			return int(hashfunc([]byte(str)))
+		}
+		r.keyspace = keyspace32
+		r.checksumfunc = hashfunc
+		return nil
+	}
+}
+
+// HashFunc64 sets a 64-bit function that is used to hash both keys and the
+// replica points of servers onto the ring. The checksum of the ring is the
+// hash folded to 32 bits.
+func HashFunc64(hashfunc func([]byte) uint64) Option {
+	return func(r *HashRing) error {
+		if hashfunc == nil {
+			return errors.New("hash function is required")
+		}
+		r.hashfunc = func(str string) int {
+			return int(hashfunc([]byte(str)))
+		}
+		r.keyspace = keyspace64
+		r.checksumfunc = func(b []byte) uint32 {
+			return fold64(hashfunc(b))
+		}
+		return nil
+	}
+}
+
+// fold64 folds a 64-bit hash to 32 bits.
+func fold64(hash uint64) uint32 {
+	return uint32(hash) ^ uint32(hash>>32)
+}
+
+// ReplicaPoints sets the number of positions a server is assigned on the ring.
+// The number of replica points must be positive.
+func ReplicaPoints(n int) Option {
+	return func(r *HashRing) error {
+		if n <= 0 {
+			return errors.New("replica points must be greater than zero")
+		}
+		r.replicaPoints = n
+		return nil
+	}
+}
+
+// Servers sets the servers that the ring initially contains. The servers are
+// added after all other options have been applied.
+func Servers(servers ...string) Option {
+	return func(r *HashRing) error {
+		r.bootstrapServers = append(r.bootstrapServers, servers...)
+		return nil
+	}
+}
+
+// defaultReplicaPoints is the number of replica points a HashRing created by
+// NewHashRing uses when the ReplicaPoints option is not provided.
+const defaultReplicaPoints = 100
+
+func defaultHashFunc(r *HashRing) error {
+	return HashFunc(farm.Fingerprint32)(r)
+}
+
+func defaultReplicaPointsOption(r *HashRing) error {
+	return ReplicaPoints(defaultReplicaPoints)(r)
+}
+
+func defaultChecksummers(r *HashRing) error {
+	return Checksummers(ChecksumV1)(r)
+}
+
+// defaultOptions are the options applied to every HashRing created by
+// NewHashRing before the user provided options.
+var defaultOptions = []Option{
+	defaultHashFunc,
+	defaultReplicaPointsOption,
+	defaultChecksummers,
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"hash/crc32"
+	"testing"
+
+	"github.com/dgryski/go-farm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHashRingDefaults(t *testing.T) {
+	ring, err := NewHashRing()
+	require.NoError(t, err)
+
+	assert.Equal(t, defaultReplicaPoints, ring.replicaPoints)
+	assert.Equal(t, int(farm.Fingerprint32([]byte("key"))), ring.hashfunc("key"))
+	assert.Equal(t, 0, ring.ServerCount(), "expected an empty ring")
+}
+
+func TestNewHashRingOptions(t *testing.T) {
+	ring, err := NewHashRing(
+		HashFunc(crc32.ChecksumIEEE),
+		ReplicaPoints(5),
+		Servers("server1", "server2"),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, ring.replicaPoints)
+	assert.Equal(t, int(crc32.ChecksumIEEE([]byte("key"))), ring.hashfunc("key"))
+	assert.Equal(t, 2, ring.ServerCount(), "expected initial servers in ring")
+	assert.Equal(t, 10, ring.tree.Size(), "expected replica points for every initial server")
+	assert.NotEqual(t, uint32(0), ring.Checksum(), "expected checksum to be computed")
+}
+
+func TestNewHashRingHashFunc64(t *testing.T) {
+	ring, err := NewHashRing(
+		HashFunc64(farm.Fingerprint64),
+		Servers("server1", "server2"),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, int(farm.Fingerprint64([]byte("key"))), ring.hashfunc("key"))
+	assert.Equal(t, fold64(farm.Fingerprint64([]byte("server1;server2"))), ring.Checksum(),
+		"expected checksum to be computed with the hash function")
+	assert.Equal(t, ring.Checksum(), ring.Copy().Checksum())
+
+	other, err := NewHashRing(Servers("server1", "server2"))
+	require.NoError(t, err)
+	assert.NotEqual(t, other.Checksum(), ring.Checksum(),
+		"expected rings that hash differently to have different checksums")
+}
+
+func TestNewHashRingServersAfterReplicaPoints(t *testing.T) {
+	ring, err := NewHashRing(Servers("server1"), ReplicaPoints(3))
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, ring.tree.Size(), "expected servers to be added after all options")
+}
+
+func TestNewHashRingInvalidOptions(t *testing.T) {
+	_, err := NewHashRing(HashFunc(nil))
+	assert.Error(t, err, "expected error for nil hash function")
+
+	_, err = NewHashRing(HashFunc64(nil))
+	assert.Error(t, err, "expected error for nil 64-bit hash function")
+
+	_, err = NewHashRing(ReplicaPoints(0))
+	assert.Error(t, err, "expected error for zero replica points")
+
+	_, err = NewHashRing(ReplicaPoints(-1))
+	assert.Error(t, err, "expected error for negative replica points")
+}
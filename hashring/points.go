@@ -0,0 +1,106 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import "github.com/gl-works/ringpop-go/events"
+
+// SetServerPoints changes the number of replica points of a server on the
+// ring. A server with fewer points owns a smaller part of the keyspace, which
+// allows a new server to be ramped in gradually. Zero, or a negative number,
+// resets the server to the replica points of the ring. Returns whether the
+// ring changed.
+func (r *HashRing) SetServerPoints(address string, points int) bool {
+	r.Lock()
+	ok := r.setServerPointsNoLock(address, points)
+	var checksumEvent events.RingChecksumEvent
+	var update events.ServerUpdate
+	if ok {
+		checksumEvent = r.computeChecksumNoLock()
+		update = r.serverUpdateNoLock(address)
+	}
+	r.Unlock()
+	r.fillMaglev()
+
+	if ok {
+		r.emit(checksumEvent)
+		r.emit(events.RingChangedEvent{ServersUpdated: []events.ServerUpdate{update}})
+	}
+	return ok
+}
+
+// ServerPoints returns the number of replica points of a server on the ring.
+func (r *HashRing) ServerPoints(address string) int {
+	r.RLock()
+	defer r.RUnlock()
+
+	if _, ok := r.serverSet[address]; !ok {
+		return 0
+	}
+	return r.pointsNoLock(address)
+}
+
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) setServerPointsNoLock(address string, points int) bool {
+	if points <= 0 || points == r.replicaPoints {
+		points = r.replicaPoints
+	}
+
+	if r.pointsNoLock(address) == points {
+		return false
+	}
+
+	_, onRing := r.serverSet[address]
+	_, suspect := r.standby.suspects[address]
+
+	if onRing {
+		r.deleteReplicasNoLock(r.tree, address)
+		if r.standby.tree != nil && !suspect {
+			r.deleteReplicasNoLock(r.standby.tree, address)
+		}
+	}
+
+	if points == r.replicaPoints {
+		delete(r.points, address)
+	} else {
+		if r.points == nil {
+			r.points = make(map[string]int)
+		}
+		r.points[address] = points
+	}
+
+	if onRing {
+		r.insertReplicasNoLock(r.tree, address)
+		if r.standby.tree != nil && !suspect {
+			r.insertReplicasNoLock(r.standby.tree, address)
+		}
+	}
+
+	return onRing
+}
+
+// pointsNoLock returns the number of replica points of a server.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) pointsNoLock(server string) int {
+	if points, ok := r.points[server]; ok {
+		return points
+	}
+	return r.replicaPoints
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dgryski/go-farm"
+	"github.com/stretchr/testify/assert"
+)
+
+// countOwned returns how many of 1000 keys the server owns.
+func countOwned(ring *HashRing, server string) int {
+	owned := 0
+	for i := 0; i < 1000; i++ {
+		if owner, _ := ring.Lookup(fmt.Sprintf("key%d", i)); owner == server {
+			owned++
+		}
+	}
+	return owned
+}
+
+func TestSetServerPoints(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genServers(4), nil)
+	checksum := ring.Checksum()
+	full := countOwned(ring, "127.0.0.1:3000")
+
+	assert.True(t, ring.SetServerPoints("127.0.0.1:3000", 10))
+	assert.Equal(t, 10, ring.ServerPoints("127.0.0.1:3000"))
+	assert.NotEqual(t, checksum, ring.Checksum(), "expected points to be part of the checksum")
+	assert.True(t, countOwned(ring, "127.0.0.1:3000") < full, "expected server with fewer points to own fewer keys")
+
+	assert.False(t, ring.SetServerPoints("127.0.0.1:3000", 10), "expected no change for the same points")
+
+	assert.True(t, ring.SetServerPoints("127.0.0.1:3000", 0))
+	assert.Equal(t, 100, ring.ServerPoints("127.0.0.1:3000"))
+	assert.Equal(t, checksum, ring.Checksum())
+
+	expected := New(farm.Fingerprint32, 100)
+	expected.AddRemoveServers(genServers(4), nil)
+	assertSameOwners(t, expected, ring)
+}
+
+func TestSetServerPointsBeforeAdd(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genServers(3), nil)
+
+	assert.False(t, ring.SetServerPoints("127.0.0.1:4000", 10), "expected ring not to change for unknown server")
+	ring.AddServer("127.0.0.1:4000")
+	assert.Equal(t, 10, ring.ServerPoints("127.0.0.1:4000"), "expected points to be used when server is added")
+
+	ring.RemoveServer("127.0.0.1:4000")
+	ring.AddServer("127.0.0.1:4000")
+	assert.Equal(t, 100, ring.ServerPoints("127.0.0.1:4000"), "expected removal to reset points")
+}
+
+func TestSetServerPointsStandby(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genServers(5), nil)
+
+	ring.SuspectServer("127.0.0.1:3001")
+	ring.SetServerPoints("127.0.0.1:3000", 10)
+	ring.RemoveServer("127.0.0.1:3001")
+
+	expected := New(farm.Fingerprint32, 100)
+	expected.AddRemoveServers(genServers(5), []string{"127.0.0.1:3001"})
+	expected.SetServerPoints("127.0.0.1:3000", 10)
+	assertSameOwners(t, expected, ring)
+}
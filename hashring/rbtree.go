@@ -20,12 +20,25 @@
 
 package hashring
 
+// minValue is the smallest value in a redBlackTree. Values of 64-bit hashes
+// can be negative, so lookups that wrap around the end of the tree start here
+// rather than at 0.
+const minValue = -int(^uint(0)>>1) - 1
+
 // redBlackTree is an implemantation of a Red Black Tree
 type redBlackTree struct {
 	root *redBlackNode
 	size int
 }
 
+// copy returns a deep copy of the redBlackTree. A nil tree copies to nil.
+func (t *redBlackTree) copy() *redBlackTree {
+	if t == nil {
+		return nil
+	}
+	return &redBlackTree{root: t.root.copy(), size: t.size}
+}
+
 // redBlackNode is a node of the redBlackTree
 type redBlackNode struct {
 	val   int
@@ -35,6 +48,20 @@ type redBlackNode struct {
 	red   bool
 }
 
+// copy returns a deep copy of the subtree rooted at the node.
+func (n *redBlackNode) copy() *redBlackNode {
+	if n == nil {
+		return nil
+	}
+	return &redBlackNode{
+		val:   n.val,
+		str:   n.str,
+		left:  n.left.copy(),
+		right: n.right.copy(),
+		red:   n.red,
+	}
+}
+
 // Size returns the number of nodes in the redBlackTree
 func (t *redBlackTree) Size() int {
 	return t.size
@@ -119,15 +146,15 @@ func (t *redBlackTree) Insert(val int, str string) (ret bool) {
 				}
 			}
 
-			cmp := node.val - val
-
 			// stop if found
-			if cmp == 0 {
+			if node.val == val {
 				break
 			}
 
+			// values are compared rather than subtracted, which overflows
+			// for 64-bit hashes
 			last = dir
-			dir = cmp < 0
+			dir = node.val < val
 
 			// update helpers
 			if gparent != nil {
@@ -177,12 +204,10 @@ func (t *redBlackTree) Delete(val int) bool {
 		parent = node
 		node = node.Child(dir)
 
-		cmp := node.val - val
-
-		dir = cmp < 0
+		dir = node.val < val
 
 		// save node if found
-		if cmp == 0 {
+		if node.val == val {
 			found = node
 		}
 
@@ -256,17 +281,42 @@ func (t *redBlackTree) Search(val int) (string, bool) {
 	return t.root.search(val)
 }
 
-// LookupNAt iterates through the tree from the node with value val, and
-// returns the next n unique strings. This function is not guaranteed to
-// return n strings.
-func (t *redBlackTree) LookupNUniqueAt(n int, val int, result map[string]struct{}) {
+// uniqueStrings collects unique strings in the order they were first added.
+type uniqueStrings struct {
+	set  map[string]struct{}
+	list []string
+}
+
+func newUniqueStrings(n int) *uniqueStrings {
+	return &uniqueStrings{
+		set:  make(map[string]struct{}, n),
+		list: make([]string, 0, n),
+	}
+}
+
+func (u *uniqueStrings) add(str string) {
+	if _, ok := u.set[str]; ok {
+		return
+	}
+	u.set[str] = struct{}{}
+	u.list = append(u.list, str)
+}
+
+func (u *uniqueStrings) len() int {
+	return len(u.list)
+}
+
+// LookupNUniqueAt iterates through the tree from the node with value val, and
+// adds the next n unique strings to result in ascending order of their values.
+// This function is not guaranteed to find n strings.
+func (t *redBlackTree) LookupNUniqueAt(n int, val int, result *uniqueStrings) {
 	findNUniqueAbove(t.root, n, val, result)
 }
 
-// findNUniqueAbove is a recursive search that finds n unique strings
+// findNUniqueAbove is a recursive in-order search that finds n unique strings
 // with a value bigger or equal than val
-func findNUniqueAbove(node *redBlackNode, n int, val int, result map[string]struct{}) {
-	if len(result) >= n || node == nil {
+func findNUniqueAbove(node *redBlackNode, n int, val int, result *uniqueStrings) {
+	if result.len() >= n || node == nil {
 		return
 	}
 
@@ -276,12 +326,12 @@ func findNUniqueAbove(node *redBlackNode, n int, val int, result map[string]stru
 	}
 
 	// Make sure to stop when we have n unique strings
-	if len(result) >= n {
+	if result.len() >= n {
 		return
 	}
 
 	if node.val >= val {
-		result[node.str] = struct{}{}
+		result.add(node.str)
 	}
 
 	findNUniqueAbove(node.right, n, val, result)
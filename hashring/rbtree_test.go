@@ -631,6 +631,32 @@ func TestBig(t *testing.T) {
 	assert.NoError(t, err, "expected tree to be a valid red black tree")
 }
 
+func TestBigNegative(t *testing.T) {
+	tree := redBlackTree{}
+	random := rand.New(rand.NewSource(1337))
+
+	// values of 64-bit hashes span the full range of int
+	var values []int
+	for i := 0; i < 2000; i++ {
+		n := int(random.Uint64())
+		values = append(values, n)
+		tree.Insert(n, strconv.Itoa(n))
+	}
+	tree.Insert(minValue, "min")
+
+	_, err := validateRedBlackTree(tree.root)
+	assert.NoError(t, err, "expected tree to be a valid red black tree")
+
+	unique := newUniqueStrings(1)
+	tree.LookupNUniqueAt(1, minValue, unique)
+	assert.Equal(t, []string{"min"}, unique.list, "expected lookup to start at the smallest value")
+
+	for _, n := range values {
+		assert.True(t, tree.Delete(n), "expected value to be deleted")
+	}
+	assert.Equal(t, 1, tree.Size())
+}
+
 //= = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = =
 //
 // BENCHMARKS
@@ -0,0 +1,93 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"errors"
+
+	"github.com/gl-works/ringpop-go/events"
+)
+
+// Reconfigure changes the configuration of the HashRing, such as its hash
+// function or replica points, in place and replaces the replica points of
+// all servers at once, so that lookups see either the old or the new
+// configuration. Options that are not provided fall back to their defaults,
+// like for NewHashRing. Servers with a changed number of replica points keep
+// their share of the replica points of the ring. Suspects, identities and
+// labels are kept, and listeners are notified of the changed checksum.
+//
+// Whether a ring is a maglev ring cannot be changed, because its lookups do
+// not lock the ring.
+func (r *HashRing) Reconfigure(opts ...Option) error {
+	c, err := NewHashRing(opts...)
+	if err != nil {
+		return err
+	}
+	if len(c.serverSet) > 0 {
+		return errors.New("servers cannot be reconfigured")
+	}
+
+	r.Lock()
+	if (c.maglev == nil) != (r.maglev == nil) {
+		r.Unlock()
+		return errors.New("a ring cannot be reconfigured to or from a maglev ring")
+	}
+
+	for server, points := range r.points {
+		scaled := points * c.replicaPoints / r.replicaPoints
+		if scaled < 1 {
+			scaled = 1
+		}
+		r.points[server] = scaled
+		if scaled == c.replicaPoints {
+			delete(r.points, server)
+		}
+	}
+
+	r.hashfunc = c.hashfunc
+	r.keyspace = c.keyspace
+	r.checksumfunc = c.checksumfunc
+	r.checksummers = c.checksummers
+	r.replicaPoints = c.replicaPoints
+	r.rendezvous = c.rendezvous
+	if c.maglev != nil {
+		// the table of the ring is filled again with the checksum, and its
+		// permutations depend on the hash function
+		r.maglev.size = c.maglev.size
+		r.maglev.permutations = c.maglev.permutations
+	}
+
+	r.tree = &redBlackTree{}
+	for server := range r.serverSet {
+		r.insertReplicasNoLock(r.tree, server)
+	}
+	if len(r.standby.suspects) > 0 {
+		r.standby.tree = r.buildStandbyNoLock()
+	}
+
+	checksumEvent := r.computeChecksumNoLock()
+	r.Unlock()
+	r.fillMaglev()
+
+	r.emit(checksumEvent)
+	r.emit(events.RingChangedEvent{})
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dgryski/go-farm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconfigure(t *testing.T) {
+	servers := genServers(5)
+	ring, err := NewHashRing(ReplicaPoints(10), Servers(servers...))
+	require.NoError(t, err)
+	ring.SetServerPoints(servers[0], 20)
+	l := &dummyListener{}
+	ring.RegisterListener(l)
+
+	salted := func(b []byte) uint32 {
+		return farm.Fingerprint32(append([]byte("salt"), b...))
+	}
+	opts := []Option{HashFunc(salted), ReplicaPoints(20)}
+	require.NoError(t, ring.Reconfigure(opts...))
+	assert.Equal(t, 2, l.EventCount(), "expected checksum and ring changed events")
+	assert.Equal(t, 40, ring.ServerPoints(servers[0]), "expected custom points to be scaled")
+	assert.Equal(t, 20, ring.ServerPoints(servers[1]))
+
+	expected, err := NewHashRing(append(opts, Servers(servers...))...)
+	require.NoError(t, err)
+	expected.SetServerPoints(servers[0], 40)
+	assert.Equal(t, expected.Checksum(), ring.Checksum())
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		assert.Equal(t, expected.LookupN(key, 3), ring.LookupN(key, 3))
+	}
+}
+
+func TestReconfigureRejected(t *testing.T) {
+	ring, err := NewHashRing(ReplicaPoints(10), Servers(genServers(2)...))
+	require.NoError(t, err)
+	checksum := ring.Checksum()
+
+	assert.Error(t, ring.Reconfigure(Maglev(1009)), "expected a change to a maglev ring to be rejected")
+	assert.Error(t, ring.Reconfigure(Servers("server9")), "expected servers to be rejected")
+	assert.Equal(t, checksum, ring.Checksum(), "expected ring not to change")
+}
+
+func TestReconfigureHashFunc64(t *testing.T) {
+	ring, err := NewHashRing(ReplicaPoints(100), Servers(genServers(4)...))
+	require.NoError(t, err)
+
+	require.NoError(t, ring.Reconfigure(HashFunc64(farm.Fingerprint64), ReplicaPoints(100)))
+	assert.InDelta(t, 1, sumShares(ring.Ownership()), 1e-9,
+		"expected ownership to be measured over the keyspace of the new hash function")
+}
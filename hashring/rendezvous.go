@@ -0,0 +1,200 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// rendezvousSamples is the number of keys the ownership of the keyspace is
+// estimated with on a rendezvous ring, which has no replica points that divide
+// the keyspace into segments.
+const rendezvousSamples = 10000
+
+// Rendezvous makes the HashRing place keys by rendezvous, or highest random
+// weight, hashing instead of on replica points. Every server scores every key
+// by the hash of its identity and the key, and the servers with the highest
+// scores own the key. Adding or removing a server only moves the keys that
+// server gains or loses, evenly spread over the other servers, and no
+// replica points need to be tuned for an even distribution, at the cost of
+// lookups that take time linear in the number of servers. The replica points
+// of a server, see SetServerPoints, weight its score, so a server with twice
+// the points owns twice the keys.
+func Rendezvous() Option {
+	return func(r *HashRing) error {
+		r.rendezvous = true
+		return nil
+	}
+}
+
+// IsRendezvous returns whether the HashRing places keys by rendezvous hashing.
+func (r *HashRing) IsRendezvous() bool {
+	return r.rendezvous
+}
+
+// rendezvousScore is the score of a server for a key.
+type rendezvousScore struct {
+	server string
+	score  float64
+}
+
+// byScore sorts scores from highest to lowest, and servers with equal scores
+// by address.
+type byScore []rendezvousScore
+
+func (s byScore) Len() int      { return len(s) }
+func (s byScore) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byScore) Less(i, j int) bool {
+	if s[i].score != s[j].score {
+		return s[i].score > s[j].score
+	}
+	return s[i].server < s[j].server
+}
+
+// scoreNoLock returns the score of a server for a key. The hash is mapped to
+// the open interval (0, 1) and weighted by the replica points of the server
+// relative to the replica points of the ring, so that the probability that a
+// server has the highest score is proportional to its weight.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) scoreNoLock(server, key string) float64 {
+	hash := uint32(r.hashfunc(r.identityNoLock(server) + "/" + key))
+	u := (float64(hash) + 0.5) / keyspace32
+	weight := float64(r.pointsNoLock(server)) / float64(r.replicaPoints)
+	return -weight / math.Log(u)
+}
+
+// rendezvousOwnerNoLock returns the server that is not excluded with the
+// highest score for the key. It takes a single pass over the servers, without
+// collecting and sorting their scores.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) rendezvousOwnerNoLock(key string, excluded map[string]struct{}) (owner string, ok bool) {
+	var best float64
+	for server := range r.serverSet {
+		if _, skip := excluded[server]; skip {
+			continue
+		}
+		score := r.scoreNoLock(server, key)
+		if !ok || score > best || score == best && server < owner {
+			owner, best, ok = server, score, true
+		}
+	}
+	return owner, ok
+}
+
+// rendezvousNoLock returns the n servers that are not excluded with the
+// highest scores for the key, highest first.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) rendezvousNoLock(key string, n int, excluded map[string]struct{}) []string {
+	if n == 1 {
+		if owner, ok := r.rendezvousOwnerNoLock(key, excluded); ok {
+			return []string{owner}
+		}
+		return nil
+	}
+
+	scores := make([]rendezvousScore, 0, len(r.serverSet))
+	for server := range r.serverSet {
+		if _, ok := excluded[server]; ok {
+			continue
+		}
+		scores = append(scores, rendezvousScore{server, r.scoreNoLock(server, key)})
+	}
+
+	sort.Sort(byScore(scores))
+
+	if n > len(scores) {
+		n = len(scores)
+	}
+	servers := make([]string, n)
+	for i := range servers {
+		servers[i] = scores[i].server
+	}
+	return servers
+}
+
+// sampleKeys returns the keys the keyspace of a rendezvous ring is estimated
+// with.
+func sampleKeys() []string {
+	keys := make([]string, rendezvousSamples)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	return keys
+}
+
+// simulateRendezvous computes the result of Simulate for a rendezvous ring by
+// comparing the owners of the keys of the sample, or of a sample of the
+// keyspace if no keys are given, on the ring and on a changed copy.
+func (r *HashRing) simulateRendezvous(sim Simulation) SimulationResult {
+	simulated := r.simulated(sim)
+
+	result := SimulationResult{
+		Gained: make(map[string]float64),
+		Lost:   make(map[string]float64),
+	}
+
+	if r.ServerCount() == 0 || simulated.ServerCount() == 0 {
+		// keys are not owned by anyone on an empty ring, so nothing moves
+		return result
+	}
+
+	keys := sim.Keys
+	if keys == nil {
+		keys = sampleKeys()
+	} else {
+		result.MovedKeys = make(map[string]string)
+		if len(keys) == 0 {
+			return result
+		}
+	}
+
+	share := 1 / float64(len(keys))
+	for _, key := range keys {
+		from, _ := r.Lookup(key)
+		to, _ := simulated.Lookup(key)
+		if from != to {
+			if result.MovedKeys != nil {
+				result.MovedKeys[key] = to
+			}
+			result.record(from, to, share)
+		}
+	}
+	return result
+}
+
+// rendezvousOwnership estimates the fraction of the keyspace owned by each
+// server on a rendezvous ring from the owners of a sample of the keyspace.
+func (r *HashRing) rendezvousOwnership() map[string]float64 {
+	ownership := make(map[string]float64)
+	if r.ServerCount() == 0 {
+		return ownership
+	}
+
+	keys := sampleKeys()
+	share := 1 / float64(len(keys))
+	for _, key := range keys {
+		owner, _ := r.Lookup(key)
+		ownership[owner] += share
+	}
+	return ownership
+}
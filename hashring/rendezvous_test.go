@@ -0,0 +1,190 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRendezvousRing(t *testing.T, servers ...string) *HashRing {
+	ring, err := NewHashRing(Rendezvous(), ReplicaPoints(10), Servers(servers...))
+	require.NoError(t, err)
+	return ring
+}
+
+func TestRendezvousLookup(t *testing.T) {
+	ring := newRendezvousRing(t, genServers(5)...)
+	assert.True(t, ring.IsRendezvous())
+	assert.Equal(t, 0, ring.tree.Size(), "expected no replica points")
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner, ok := ring.Lookup(key)
+		require.True(t, ok)
+
+		servers := ring.LookupN(key, 10)
+		assert.Len(t, servers, 5, "expected every server once")
+		assert.Equal(t, owner, servers[0], "expected owner to have the highest score")
+		assert.Equal(t, []string{owner}, ring.LookupN(key, 1))
+		for j := 1; j < len(servers); j++ {
+			assert.True(t, ring.scoreNoLock(servers[j-1], key) >= ring.scoreNoLock(servers[j], key),
+				"expected servers in order of their score")
+		}
+		assert.Equal(t, servers, ring.Trace(key, 5).Owners)
+	}
+
+	empty := newRendezvousRing(t)
+	_, ok := empty.Lookup("key")
+	assert.False(t, ok)
+}
+
+func BenchmarkRendezvousLookup(b *testing.B) {
+	ring, _ := NewHashRing(Rendezvous(), Servers(genServers(100)...))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ring.Lookup("key")
+	}
+}
+
+// TestRendezvousMinimalMovement tests that adding a server only moves keys to
+// that server, and removing it only moves its keys.
+func TestRendezvousMinimalMovement(t *testing.T) {
+	ring := newRendezvousRing(t, genServers(4)...)
+	owners := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owners[key], _ = ring.Lookup(key)
+	}
+
+	ring.AddServer("127.0.0.1:3004")
+	moved := 0
+	for key, owner := range owners {
+		newOwner, _ := ring.Lookup(key)
+		if newOwner != owner {
+			assert.Equal(t, "127.0.0.1:3004", newOwner, "expected key %s to only move to the new server", key)
+			moved++
+		}
+	}
+	assert.InDelta(t, 200, moved, 60, "expected the new server to take about a fifth of the keys")
+
+	ring.RemoveServer("127.0.0.1:3004")
+	for key, owner := range owners {
+		newOwner, _ := ring.Lookup(key)
+		assert.Equal(t, owner, newOwner, "expected key %s to move back", key)
+	}
+}
+
+func TestRendezvousWeights(t *testing.T) {
+	ring := newRendezvousRing(t, genServers(3)...)
+	ring.SetServerPoints("127.0.0.1:3000", 20)
+
+	ownership := ring.Ownership()
+	assert.InDelta(t, 0.5, ownership["127.0.0.1:3000"], 0.03, "expected a server with twice the points to own twice the keys")
+	assert.InDelta(t, 0.25, ownership["127.0.0.1:3001"], 0.03)
+
+	total := 0.0
+	for _, share := range ownership {
+		total += share
+	}
+	assert.InDelta(t, 1, total, 1e-9)
+}
+
+func TestRendezvousIdentity(t *testing.T) {
+	ring := newRendezvousRing(t, genServers(3)...)
+	ring.SetServerIdentity("127.0.0.1:3000", "node-0")
+
+	var keys []string
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if owner, _ := ring.Lookup(key); owner == "127.0.0.1:3000" {
+			keys = append(keys, key)
+		}
+	}
+	require.NotEmpty(t, keys)
+
+	ring.AddRemoveServers([]string{"127.0.0.1:3005"}, []string{"127.0.0.1:3000"})
+	ring.SetServerIdentity("127.0.0.1:3005", "node-0")
+	for _, key := range keys {
+		owner, _ := ring.Lookup(key)
+		assert.Equal(t, "127.0.0.1:3005", owner, "expected key %s to move with the identity", key)
+	}
+}
+
+func TestRendezvousStandby(t *testing.T) {
+	ring := newRendezvousRing(t, genServers(3)...)
+	owner, _ := ring.Lookup("key")
+	ring.SuspectServer(owner)
+
+	standby, ok := ring.LookupStandby("key")
+	assert.True(t, ok)
+	assert.Equal(t, ring.LookupN("key", 2)[1], standby, "expected the next server to take over")
+
+	ring.RemoveServer(owner)
+	newOwner, _ := ring.Lookup("key")
+	assert.Equal(t, standby, newOwner)
+}
+
+func TestRendezvousChecksum(t *testing.T) {
+	ring := newRendezvousRing(t, genServers(3)...)
+	consistent, err := NewHashRing(ReplicaPoints(10), Servers(genServers(3)...))
+	require.NoError(t, err)
+
+	assert.NotEqual(t, consistent.Checksum(), ring.Checksum(),
+		"expected rings that place keys differently to have different checksums")
+	assert.Equal(t, ring.Checksum(), ring.Copy().Checksum())
+	assert.True(t, ring.Copy().IsRendezvous())
+}
+
+func TestRendezvousSimulate(t *testing.T) {
+	ring := newRendezvousRing(t, genServers(4)...)
+
+	result := ring.Simulate(Simulation{Add: []string{"127.0.0.1:3004"}})
+	assert.InDelta(t, 0.2, result.Moved, 0.02)
+	assert.InDelta(t, result.Moved, result.Gained["127.0.0.1:3004"], 1e-9,
+		"expected keys to only move to the added server")
+	assert.Nil(t, result.MovedKeys)
+	assert.Equal(t, 4, ring.ServerCount(), "expected the ring to be unchanged")
+
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	result = ring.Simulate(Simulation{Remove: []string{"127.0.0.1:3000"}, Keys: keys})
+	for key, to := range result.MovedKeys {
+		from, _ := ring.Lookup(key)
+		assert.Equal(t, "127.0.0.1:3000", from)
+		assert.NotEqual(t, "127.0.0.1:3000", to)
+	}
+	assert.InDelta(t, float64(len(result.MovedKeys))/float64(len(keys)), result.Moved, 1e-9)
+}
+
+func TestRendezvousSimulatePoints(t *testing.T) {
+	ring := newRendezvousRing(t, genServers(4)...)
+	server := "127.0.0.1:3000"
+	before := ring.Ownership()
+
+	result := ring.Simulate(Simulation{Points: map[string]int{server: 200}})
+	assert.True(t, result.Gained[server] > 0.1, "expected a server with more points to gain keys")
+
+	ring.SetServerPoints(server, 200)
+	assert.InDelta(t, ring.Ownership()[server]-before[server], result.Gained[server]-result.Lost[server], 1e-9)
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"math"
+	"sort"
+)
+
+// The number of distinct hashes of 32-bit and 64-bit hash functions.
+const (
+	keyspace32 = math.MaxUint32 + 1
+	keyspace64 = 2 * (math.MaxInt64 + 1.0)
+)
+
+// span returns the size of the segment of the keyspace from hash a up to hash
+// b. Hashes of 64-bit hash functions span the whole range of int, so the
+// difference is taken modulo 2^64 rather than as an int, which overflows.
+func span(a, b int) float64 {
+	return float64(uint64(b - a))
+}
+
+// A Simulation is a hypothetical change of the servers on a HashRing.
+type Simulation struct {
+	Add    []string
+	Remove []string
+
+	// Points changes the replica points, and so the weight, of servers as
+	// SetServerPoints would, including servers that are added.
+	Points map[string]int
+
+	// Keys is an optional sample of keys. If set, the impact of the change
+	// is measured over the sample instead of over the whole keyspace.
+	Keys []string
+}
+
+// A SimulationResult describes how a Simulation would change the ownership
+// of keys.
+type SimulationResult struct {
+	// Moved is the fraction, between 0 and 1, of the keyspace or of the key
+	// sample that would change owners.
+	Moved float64 `json:"moved"`
+
+	// Gained and Lost contain, per server, the fraction of the keyspace or
+	// of the key sample the server would gain or lose.
+	Gained map[string]float64 `json:"gained"`
+	Lost   map[string]float64 `json:"lost"`
+
+	// MovedKeys maps the keys of the sample that would change owners to
+	// their new owner. It is nil if no sample was given.
+	MovedKeys map[string]string `json:"movedKeys,omitempty"`
+}
+
+// ringPoints are the replica points of a ring in ascending order.
+type ringPoints struct {
+	vals    []int
+	servers []string
+}
+
+// owner returns the server owning the given hash.
+func (p ringPoints) owner(hash int) string {
+	return p.servers[p.index(hash)]
+}
+
+// index returns the index of the point owning the given hash, the first point
+// at or after it, wrapping around the end of the ring.
+func (p ringPoints) index(hash int) int {
+	i := sort.SearchInts(p.vals, hash)
+	if i == len(p.vals) {
+		i = 0
+	}
+	return i
+}
+
+func pointsOf(tree *redBlackTree) ringPoints {
+	var points ringPoints
+	var walk func(node *redBlackNode)
+	walk = func(node *redBlackNode) {
+		if node == nil {
+			return
+		}
+		walk(node.left)
+		points.vals = append(points.vals, node.val)
+		points.servers = append(points.servers, node.str)
+		walk(node.right)
+	}
+	walk(tree.root)
+	return points
+}
+
+// Simulate computes how the ownership of keys would change if the servers in
+// the Simulation were added to and removed from the ring and had their replica
+// points changed, without changing the ring itself. It allows predicting the
+// impact of scaling a cluster before doing so. On a rendezvous ring without a sample of keys, the impact
+// is estimated over a sample of the keyspace, and on a maglev ring it is
+// measured over the entries of the table.
+func (r *HashRing) Simulate(sim Simulation) SimulationResult {
+	if r.rendezvous {
+		return r.simulateRendezvous(sim)
+	}
+	if r.maglev != nil {
+		return r.simulateMaglev(sim)
+	}
+
+	r.RLock()
+	tree := &redBlackTree{}
+	current := pointsOf(r.tree)
+	for i, val := range current.vals {
+		tree.Insert(val, current.servers[i])
+	}
+	removed := make(map[string]struct{}, len(sim.Remove))
+	for _, server := range sim.Remove {
+		if _, ok := r.serverSet[server]; ok {
+			r.deleteReplicasNoLock(tree, server)
+			removed[server] = struct{}{}
+		}
+	}
+	for server := range sim.Points {
+		_, onRing := r.serverSet[server]
+		if _, ok := removed[server]; onRing && !ok {
+			r.deletePointsNoLock(tree, server, r.pointsNoLock(server))
+			r.insertPointsNoLock(tree, server, r.simulatedPointsNoLock(sim, server))
+		}
+	}
+	for _, server := range sim.Add {
+		if _, ok := r.serverSet[server]; !ok {
+			r.insertPointsNoLock(tree, server, r.simulatedPointsNoLock(sim, server))
+		}
+	}
+	r.RUnlock()
+
+	simulated := pointsOf(tree)
+
+	result := SimulationResult{
+		Gained: make(map[string]float64),
+		Lost:   make(map[string]float64),
+	}
+
+	if len(current.vals) == 0 || len(simulated.vals) == 0 {
+		// keys are not owned by anyone on an empty ring, so nothing moves
+		return result
+	}
+
+	if sim.Keys != nil {
+		result.MovedKeys = make(map[string]string)
+		if len(sim.Keys) == 0 {
+			return result
+		}
+
+		share := 1 / float64(len(sim.Keys))
+		for _, key := range sim.Keys {
+			hash := r.hashfunc(key)
+			from, to := current.owner(hash), simulated.owner(hash)
+			if from != to {
+				result.MovedKeys[key] = to
+				result.record(from, to, share)
+			}
+		}
+		return result
+	}
+
+	// every segment between two adjacent points of either ring is owned by a
+	// single server in both rings, so comparing the owners per segment gives
+	// the exact fraction of the keyspace that moves
+	bounds := append(append([]int{}, current.vals...), simulated.vals...)
+	sort.Ints(bounds)
+
+	// the segment wrapping around the end of the keyspace
+	first, last := bounds[0], bounds[len(bounds)-1]
+	result.compare(current, simulated, first, (r.keyspace-span(first, last))/r.keyspace)
+
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] != bounds[i-1] {
+			result.compare(current, simulated, bounds[i], span(bounds[i-1], bounds[i])/r.keyspace)
+		}
+	}
+
+	return result
+}
+
+// simulatedPointsNoLock returns the replica points of a server after the
+// change of the Simulation.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) simulatedPointsNoLock(sim Simulation, server string) int {
+	points, ok := sim.Points[server]
+	if !ok {
+		return r.pointsNoLock(server)
+	}
+	if points <= 0 {
+		return r.replicaPoints
+	}
+	return points
+}
+
+// simulated returns a copy of the ring with the change of the Simulation
+// applied, for rings that look up keys by more than their replica points.
+func (r *HashRing) simulated(sim Simulation) *HashRing {
+	c := r.Copy()
+	c.Lock()
+	for server, points := range sim.Points {
+		c.setServerPointsNoLock(server, points)
+	}
+	c.addRemoveServersNoLock(sim.Add, sim.Remove)
+	c.computeChecksumNoLock()
+	c.Unlock()
+	c.fillMaglev()
+	return c
+}
+
+// compare records a move if the segment with the given share of the keyspace
+// ending at the bound changes owners.
+func (s *SimulationResult) compare(current, simulated ringPoints, bound int, share float64) {
+	from, to := current.owner(bound), simulated.owner(bound)
+	if from != to {
+		s.record(from, to, share)
+	}
+}
+
+func (s *SimulationResult) record(from, to string, share float64) {
+	s.Moved += share
+	s.Lost[from] += share
+	s.Gained[to] += share
+}
+
+// Ownership returns the fraction, between 0 and 1, of the keyspace owned by
+// each server on the ring. On a rendezvous ring, the ownership is estimated
+// over a sample of the keyspace, and on a maglev ring it is the share of the
+// entries of the table.
+func (r *HashRing) Ownership() map[string]float64 {
+	if r.rendezvous {
+		return r.rendezvousOwnership()
+	}
+	if r.maglev != nil {
+		return r.maglevOwnership()
+	}
+
+	r.RLock()
+	points := pointsOf(r.tree)
+	r.RUnlock()
+
+	ownership := make(map[string]float64)
+	if len(points.vals) == 0 {
+		return ownership
+	}
+
+	// a point owns the segment of the keyspace since the previous point, and
+	// the first point the segment wrapping around the end of the keyspace
+	first, last := points.vals[0], points.vals[len(points.vals)-1]
+	ownership[points.servers[0]] += (r.keyspace - span(first, last)) / r.keyspace
+	for i := 1; i < len(points.vals); i++ {
+		ownership[points.servers[i]] += span(points.vals[i-1], points.vals[i]) / r.keyspace
+	}
+	return ownership
+}
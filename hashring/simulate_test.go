@@ -0,0 +1,166 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dgryski/go-farm"
+	"github.com/stretchr/testify/assert"
+)
+
+func sumShares(shares map[string]float64) float64 {
+	var sum float64
+	for _, share := range shares {
+		sum += share
+	}
+	return sum
+}
+
+func TestSimulateAdd(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genServers(3), nil)
+	checksum := ring.Checksum()
+
+	result := ring.Simulate(Simulation{Add: []string{"127.0.0.1:4000"}})
+	assert.InDelta(t, 0.25, result.Moved, 0.1, "expected new server to take about a quarter of the keyspace")
+	assert.Equal(t, map[string]float64{"127.0.0.1:4000": result.Moved}, result.Gained)
+	assert.InDelta(t, result.Moved, sumShares(result.Lost), 1e-9)
+	assert.Nil(t, result.MovedKeys)
+
+	assert.Equal(t, checksum, ring.Checksum(), "expected simulation not to change the ring")
+}
+
+func TestSimulateRemove(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genServers(4), nil)
+
+	result := ring.Simulate(Simulation{Remove: []string{"127.0.0.1:3000"}})
+	assert.InDelta(t, 0.25, result.Moved, 0.1)
+	assert.Equal(t, map[string]float64{"127.0.0.1:3000": result.Moved}, result.Lost)
+	assert.InDelta(t, result.Moved, sumShares(result.Gained), 1e-9)
+}
+
+func TestSimulateNoChange(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genServers(3), nil)
+
+	result := ring.Simulate(Simulation{Add: []string{"127.0.0.1:3000"}, Remove: []string{"127.0.0.1:4000"}})
+	assert.Equal(t, 0.0, result.Moved, "expected adding existing and removing unknown servers to move nothing")
+
+	empty := New(farm.Fingerprint32, 100)
+	result = empty.Simulate(Simulation{Add: []string{"127.0.0.1:3000"}})
+	assert.Equal(t, 0.0, result.Moved)
+}
+
+func TestSimulateKeySample(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genServers(3), nil)
+
+	var keys []string
+	owners := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		keys = append(keys, key)
+		owners[key], _ = ring.Lookup(key)
+	}
+
+	result := ring.Simulate(Simulation{
+		Add:    []string{"127.0.0.1:4000"},
+		Remove: []string{"127.0.0.1:3000"},
+		Keys:   keys,
+	})
+
+	ring.AddRemoveServers([]string{"127.0.0.1:4000"}, []string{"127.0.0.1:3000"})
+
+	moved := 0
+	for _, key := range keys {
+		owner, _ := ring.Lookup(key)
+		if owner != owners[key] {
+			moved++
+			assert.Equal(t, owner, result.MovedKeys[key], "expected simulation to predict new owner of %s", key)
+		}
+	}
+	assert.Len(t, result.MovedKeys, moved)
+	assert.InDelta(t, float64(moved)/1000, result.Moved, 1e-9)
+}
+
+func TestOwnership(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	assert.Empty(t, ring.Ownership())
+
+	ring.AddRemoveServers(genServers(4), nil)
+	ownership := ring.Ownership()
+	assert.Len(t, ownership, 4)
+	assert.InDelta(t, 1, sumShares(ownership), 1e-9)
+	for server, share := range ownership {
+		assert.InDelta(t, 0.25, share, 0.1, "expected %s to own about a quarter of the keyspace", server)
+	}
+
+	ring.SetServerPoints("127.0.0.1:3000", 10)
+	assert.True(t, ring.Ownership()["127.0.0.1:3000"] < ownership["127.0.0.1:3000"],
+		"expected server with fewer points to own less")
+}
+
+func TestOwnershipHashFunc64(t *testing.T) {
+	ring, err := NewHashRing(HashFunc64(farm.Fingerprint64), ReplicaPoints(100))
+	assert.NoError(t, err)
+	ring.AddRemoveServers(genServers(4), nil)
+
+	ownership := ring.Ownership()
+	assert.Len(t, ownership, 4)
+	assert.InDelta(t, 1, sumShares(ownership), 1e-9)
+	for server, share := range ownership {
+		assert.InDelta(t, 0.25, share, 0.1, "expected %s to own about a quarter of the keyspace", server)
+	}
+}
+
+func TestSimulateHashFunc64(t *testing.T) {
+	ring, err := NewHashRing(HashFunc64(farm.Fingerprint64), ReplicaPoints(100))
+	assert.NoError(t, err)
+	ring.AddRemoveServers(genServers(3), nil)
+
+	result := ring.Simulate(Simulation{Add: []string{"127.0.0.1:4000"}})
+	assert.InDelta(t, 0.25, result.Moved, 0.1, "expected new server to take about a quarter of the keyspace")
+	assert.InDelta(t, result.Moved, sumShares(result.Lost), 1e-9)
+}
+
+func TestSimulatePoints(t *testing.T) {
+	ring := New(farm.Fingerprint32, 100)
+	ring.AddRemoveServers(genServers(4), nil)
+	server := "127.0.0.1:3000"
+	before := ring.Ownership()
+
+	result := ring.Simulate(Simulation{Points: map[string]int{server: 50}})
+	assert.Empty(t, result.Gained[server], "expected a server with fewer points not to gain keys")
+
+	ring.SetServerPoints(server, 50)
+	assert.InDelta(t, before[server]-ring.Ownership()[server], result.Lost[server], 1e-9,
+		"expected the simulation to predict the share the server loses")
+
+	added := "127.0.0.1:4000"
+	result = ring.Simulate(Simulation{Add: []string{added}, Points: map[string]int{added: 200}})
+	ring.AddServer(added)
+	ring.SetServerPoints(added, 200)
+	assert.InDelta(t, ring.Ownership()[added], result.Gained[added], 1e-9,
+		"expected an added server to take its simulated points")
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import "fmt"
+
+// standbyRing is a precomputed failover ring that contains all servers of
+// the HashRing except for the ones that are suspected to have failed. When a
+// suspect server is removed, the standby ring becomes the active ring with a
+// pointer swap instead of deleting all replicas of the server from the tree.
+type standbyRing struct {
+	suspects map[string]struct{}
+	tree     *redBlackTree
+}
+
+// SuspectServer marks a server as suspected to have failed. The server stays
+// on the ring, but is excluded from the standby ring so that its removal,
+// once it is declared faulty, is instantaneous. Returns whether the server
+// was marked as suspect.
+func (r *HashRing) SuspectServer(address string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, ok := r.serverSet[address]; !ok {
+		return false
+	}
+
+	if _, ok := r.standby.suspects[address]; ok {
+		return false
+	}
+
+	if r.standby.suspects == nil {
+		r.standby.suspects = make(map[string]struct{})
+	}
+	r.standby.suspects[address] = struct{}{}
+
+	if r.standby.tree == nil {
+		r.standby.tree = r.buildStandbyNoLock()
+	} else {
+		r.deleteReplicasNoLock(r.standby.tree, address)
+	}
+
+	return true
+}
+
+// ClearSuspectServer clears the suspicion of a server, adding it back to the
+// standby ring. Returns whether the server was suspect.
+func (r *HashRing) ClearSuspectServer(address string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, ok := r.standby.suspects[address]; !ok {
+		return false
+	}
+
+	delete(r.standby.suspects, address)
+
+	if len(r.standby.suspects) == 0 {
+		// without suspects the standby ring is identical to the active ring
+		r.standby.tree = nil
+	} else if r.standby.tree != nil {
+		r.insertReplicasNoLock(r.standby.tree, address)
+	}
+
+	return true
+}
+
+// IsSuspectServer returns whether the server is on the ring but suspected to
+// have failed.
+func (r *HashRing) IsSuspectServer(address string) bool {
+	r.RLock()
+	_, suspect := r.standby.suspects[address]
+	r.RUnlock()
+	return suspect
+}
+
+// LookupStandby returns the server that owns the key on the ring without the
+// suspect servers, which is the server that takes over the key if its owner
+// is declared faulty. Without suspects it is the same as Lookup.
+func (r *HashRing) LookupStandby(key string) (string, bool) {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.rendezvous {
+		return r.rendezvousOwnerNoLock(key, r.standby.suspects)
+	}
+
+	if r.maglev != nil {
+		owner, ok := r.lookupMaglev(key)
+		if _, suspect := r.standby.suspects[owner]; ok && !suspect {
+			return owner, true
+		}
+	}
+
+	tree := r.standby.tree
+	if tree == nil {
+		tree = r.tree
+	}
+
+	hash := r.hashfunc(key)
+	unique := newUniqueStrings(1)
+	tree.LookupNUniqueAt(1, hash, unique)
+	if unique.len() == 0 {
+		tree.LookupNUniqueAt(1, minValue, unique)
+	}
+
+	if unique.len() == 0 {
+		return "", false
+	}
+	return unique.list[0], true
+}
+
+// buildStandbyNoLock builds a tree of all servers on the ring that are not
+// suspect.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) buildStandbyNoLock() *redBlackTree {
+	tree := &redBlackTree{}
+	for server := range r.serverSet {
+		if _, suspect := r.standby.suspects[server]; !suspect {
+			r.insertReplicasNoLock(tree, server)
+		}
+	}
+	return tree
+}
+
+// swapToStandbyNoLock removes a suspect server from the ring by making the
+// standby ring the active ring. Returns false if the server is not suspect,
+// in which case it needs to be removed from the ring replica by replica.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) swapToStandbyNoLock(server string) bool {
+	if _, suspect := r.standby.suspects[server]; !suspect || r.standby.tree == nil {
+		return false
+	}
+
+	delete(r.serverSet, server)
+	delete(r.standby.suspects, server)
+
+	r.tree = r.standby.tree
+	r.standby.tree = nil
+
+	if len(r.standby.suspects) > 0 {
+		// the standby ring excluded the other suspects as well; they are
+		// still members of the ring until they are removed themselves
+		r.standby.tree = r.buildStandbyNoLock()
+		for suspect := range r.standby.suspects {
+			r.insertReplicasNoLock(r.tree, suspect)
+		}
+	}
+
+	return true
+}
+
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) insertReplicasNoLock(tree *redBlackTree, server string) {
+	// servers on a rendezvous ring have no replica points
+	if r.rendezvous {
+		return
+	}
+
+	r.insertPointsNoLock(tree, server, r.pointsNoLock(server))
+}
+
+// insertPointsNoLock inserts the given number of replica points of a server.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) insertPointsNoLock(tree *redBlackTree, server string, points int) {
+	identity := r.identityNoLock(server)
+	for i := 0; i < points; i++ {
+		address := fmt.Sprintf("%s%v", identity, i)
+		tree.Insert(r.hashfunc(address), server)
+	}
+}
+
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) deleteReplicasNoLock(tree *redBlackTree, server string) {
+	if r.rendezvous {
+		return
+	}
+
+	r.deletePointsNoLock(tree, server, r.pointsNoLock(server))
+}
+
+// deletePointsNoLock deletes the given number of replica points of a server.
+// This function isn't thread-safe, only call it when the HashRing is locked.
+func (r *HashRing) deletePointsNoLock(tree *redBlackTree, server string, points int) {
+	identity := r.identityNoLock(server)
+	for i := 0; i < points; i++ {
+		address := fmt.Sprintf("%s%v", identity, i)
+		tree.Delete(r.hashfunc(address))
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/dgryski/go-farm"
+	"github.com/stretchr/testify/assert"
+)
+
+func genServers(n int) []string {
+	var servers []string
+	for i := 0; i < n; i++ {
+		servers = append(servers, fmt.Sprintf("127.0.0.1:%d", 3000+i))
+	}
+	return servers
+}
+
+// assertSameOwners asserts that both rings assign the same owners to keys.
+func assertSameOwners(t *testing.T, expected, actual *HashRing) {
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		expectedOwner, _ := expected.Lookup(key)
+		actualOwner, _ := actual.Lookup(key)
+		assert.Equal(t, expectedOwner, actualOwner, "expected same owner for %s", key)
+
+		expectedOwners, actualOwners := expected.LookupN(key, 3), actual.LookupN(key, 3)
+		sort.Strings(expectedOwners)
+		sort.Strings(actualOwners)
+		assert.Equal(t, expectedOwners, actualOwners, "expected same owners for %s", key)
+	}
+}
+
+func TestSuspectServer(t *testing.T) {
+	ring := New(farm.Fingerprint32, 10)
+	ring.AddRemoveServers(genServers(5), nil)
+
+	assert.False(t, ring.SuspectServer("127.0.0.1:4000"), "expected unknown server not to be suspected")
+	assert.True(t, ring.SuspectServer("127.0.0.1:3001"))
+	assert.False(t, ring.SuspectServer("127.0.0.1:3001"), "expected redundant suspect to be ignored")
+	assert.NotNil(t, ring.standby.tree, "expected standby ring to be built")
+	assert.True(t, ring.HasServer("127.0.0.1:3001"), "expected suspect to stay on the ring")
+	assert.Equal(t, 4*10, ring.standby.tree.Size())
+
+	assert.True(t, ring.ClearSuspectServer("127.0.0.1:3001"))
+	assert.False(t, ring.ClearSuspectServer("127.0.0.1:3001"))
+	assert.Nil(t, ring.standby.tree, "expected standby ring to be dropped without suspects")
+}
+
+func TestRemoveSuspectSwapsToStandby(t *testing.T) {
+	ring := New(farm.Fingerprint32, 10)
+	ring.AddRemoveServers(genServers(5), nil)
+
+	ring.SuspectServer("127.0.0.1:3001")
+	standby := ring.standby.tree
+
+	assert.True(t, ring.RemoveServer("127.0.0.1:3001"))
+	assert.True(t, standby == ring.tree, "expected the standby ring to become the active ring")
+	assert.False(t, ring.HasServer("127.0.0.1:3001"))
+	assert.Empty(t, ring.standby.suspects)
+
+	expected := New(farm.Fingerprint32, 10)
+	expected.AddRemoveServers(genServers(5), []string{"127.0.0.1:3001"})
+	assertSameOwners(t, expected, ring)
+	assert.Equal(t, expected.Checksum(), ring.Checksum())
+}
+
+func TestRemoveSuspectWithOtherSuspects(t *testing.T) {
+	ring := New(farm.Fingerprint32, 10)
+	ring.AddRemoveServers(genServers(5), nil)
+
+	ring.SuspectServer("127.0.0.1:3001")
+	ring.SuspectServer("127.0.0.1:3002")
+
+	// membership changes while suspects are pending are applied to the
+	// standby ring as well
+	ring.AddServer("127.0.0.1:3005")
+	ring.RemoveServer("127.0.0.1:3004")
+
+	ring.RemoveServer("127.0.0.1:3001")
+	assert.True(t, ring.HasServer("127.0.0.1:3002"), "expected other suspect to stay on the ring")
+
+	expected := New(farm.Fingerprint32, 10)
+	expected.AddRemoveServers([]string{"127.0.0.1:3000", "127.0.0.1:3002", "127.0.0.1:3003", "127.0.0.1:3005"}, nil)
+	assertSameOwners(t, expected, ring)
+
+	ring.RemoveServer("127.0.0.1:3002")
+	expected.RemoveServer("127.0.0.1:3002")
+	assertSameOwners(t, expected, ring)
+	assert.Nil(t, ring.standby.tree, "expected standby ring to be dropped without suspects")
+}
+
+func TestLookupStandby(t *testing.T) {
+	ring := New(farm.Fingerprint32, 10)
+	ring.AddRemoveServers(genServers(5), nil)
+
+	assert.False(t, ring.IsSuspectServer("127.0.0.1:3001"))
+
+	// without suspects the standby owner is the owner
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner, _ := ring.Lookup(key)
+		standby, ok := ring.LookupStandby(key)
+		assert.True(t, ok)
+		assert.Equal(t, owner, standby)
+	}
+
+	ring.SuspectServer("127.0.0.1:3001")
+	assert.True(t, ring.IsSuspectServer("127.0.0.1:3001"))
+
+	expected := New(farm.Fingerprint32, 10)
+	expected.AddRemoveServers(genServers(5), []string{"127.0.0.1:3001"})
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner, _ := expected.Lookup(key)
+		standby, _ := ring.LookupStandby(key)
+		assert.Equal(t, owner, standby, "expected standby owner of %s to take over from the suspect", key)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+// A Token is a replica point of a server on the ring.
+type Token struct {
+	Hash   int    `json:"hash"`
+	Server string `json:"server"`
+}
+
+// A LookupTrace describes how the owners of a key are found on the ring.
+type LookupTrace struct {
+	// Hash is the hash of the key and Checksum the checksum of the ring the
+	// key was looked up on.
+	Hash     int    `json:"hash"`
+	Checksum uint32 `json:"checksum"`
+
+	// Tokens are the replica points visited clockwise from the hash of the
+	// key, including those of servers that were already found, until the
+	// requested number of servers was found or the ring was exhausted. A
+	// rendezvous ring has no replica points, and a maglev ring finds owners in
+	// its table, so their traces have no tokens.
+	Tokens []Token `json:"tokens"`
+
+	// Owners are the servers that own the key, in the order their tokens
+	// were visited.
+	Owners []string `json:"owners"`
+}
+
+// Trace looks up the n servers that own the key like LookupN, and returns the
+// tokens that were visited to find them.
+func (r *HashRing) Trace(key string, n int) LookupTrace {
+	r.RLock()
+	points := pointsOf(r.tree)
+	trace := LookupTrace{
+		Hash:     r.hashfunc(key),
+		Checksum: r.checksum,
+	}
+	servers := len(r.serverSet)
+	tokenless := r.rendezvous || r.maglev != nil
+	if tokenless {
+		trace.Owners = r.lookupNNoLock(key, n)
+	}
+	r.RUnlock()
+
+	if tokenless {
+		return trace
+	}
+
+	if n > servers {
+		n = servers
+	}
+	if n <= 0 || len(points.vals) == 0 {
+		return trace
+	}
+
+	found := make(map[string]bool, n)
+	start := points.index(trace.Hash)
+	for i := 0; i < len(points.vals) && len(trace.Owners) < n; i++ {
+		j := (start + i) % len(points.vals)
+		token := Token{Hash: points.vals[j], Server: points.servers[j]}
+		trace.Tokens = append(trace.Tokens, token)
+		if !found[token.Server] {
+			found[token.Server] = true
+			trace.Owners = append(trace.Owners, token.Server)
+		}
+	}
+	return trace
+}
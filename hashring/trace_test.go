@@ -0,0 +1,56 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/dgryski/go-farm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	ring := New(farm.Fingerprint32, 10)
+	assert.Empty(t, ring.Trace("key", 1).Owners)
+
+	ring.AddRemoveServers(genServers(4), nil)
+
+	trace := ring.Trace("key", 1)
+	owner, _ := ring.Lookup("key")
+	assert.Equal(t, []string{owner}, trace.Owners)
+	assert.Equal(t, ring.Checksum(), trace.Checksum)
+	assert.Equal(t, ring.hashfunc("key"), trace.Hash)
+	if assert.Len(t, trace.Tokens, 1) {
+		assert.Equal(t, owner, trace.Tokens[0].Server)
+	}
+
+	trace = ring.Trace("key", 3)
+	owners := ring.LookupN("key", 3)
+	sort.Strings(owners)
+	traced := append([]string(nil), trace.Owners...)
+	sort.Strings(traced)
+	assert.Equal(t, owners, traced)
+	assert.Equal(t, owner, trace.Owners[0], "expected owners in ring order")
+	assert.True(t, len(trace.Tokens) >= 3)
+
+	assert.Len(t, ring.Trace("key", 10).Owners, 4, "expected at most all servers")
+}
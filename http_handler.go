@@ -0,0 +1,139 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	fwdhttp "github.com/gl-works/ringpop-go/forward/http"
+)
+
+const (
+	// HTTPPortLabel is the label members gossip the port of their HTTP server
+	// under, e.g. rp.SetLabel(ringpop.HTTPPortLabel, "8080"). See HTTPHandler.
+	HTTPPortLabel = "http-port"
+
+	// HTTPKeyHeader is the header HTTPHandler routes requests by, unless
+	// HTTPOptions.Key is set.
+	HTTPKeyHeader = fwdhttp.KeyHeader
+
+	// HTTPForwardedHeader is set on requests HTTPHandler forwarded.
+	HTTPForwardedHeader = fwdhttp.ForwardedHeader
+)
+
+// An HTTPKeyFunc extracts the key an HTTP request is routed by, see
+// fwdhttp.KeyFunc.
+type HTTPKeyFunc = fwdhttp.KeyFunc
+
+// HTTPHeaderKey returns an HTTPKeyFunc that routes requests by the value of
+// the header with the given name, see fwdhttp.HeaderKey.
+func HTTPHeaderKey(name string) HTTPKeyFunc {
+	return fwdhttp.HeaderKey(name)
+}
+
+// HTTPQueryKey returns an HTTPKeyFunc that routes requests by the value of
+// the query parameter with the given name, see fwdhttp.QueryKey.
+func HTTPQueryKey(name string) HTTPKeyFunc {
+	return fwdhttp.QueryKey(name)
+}
+
+// HTTPOptions configures the routing of HTTPHandler.
+type HTTPOptions struct {
+	// Key extracts the key requests are routed by. Requests are routed by the
+	// HTTPKeyHeader header if it is nil.
+	Key HTTPKeyFunc
+
+	// PortLabel is the label members gossip the port of their HTTP server
+	// under. HTTPPortLabel is used if it is empty.
+	PortLabel string
+
+	// Transport forwards requests to their owner. http.DefaultTransport is
+	// used if it is nil.
+	Transport http.RoundTripper
+
+	// FlushInterval is the interval at which responses of other members are
+	// flushed to the client, see fwdhttp.Options.
+	FlushInterval time.Duration
+}
+
+// An HTTPPortUnknownError is returned when HTTPHandler forwards a request to a
+// member that does not gossip the port of its HTTP server.
+type HTTPPortUnknownError struct {
+	Member string
+	Label  string
+}
+
+func (e *HTTPPortUnknownError) Error() string {
+	return fmt.Sprintf("member %s has no %q label with its HTTP port", e.Member, e.Label)
+}
+
+// HTTPHandler returns an http.Handler that routes requests like
+// HandleOrForward routes TChannel requests, using the forward/http package. It
+// looks up the owner of the key of a request and passes the request to handler
+// if this Ringpop instance owns the key. Otherwise it proxies the request to
+// the HTTP server of the owner, at the port the owner gossips in its
+// HTTPOptions.PortLabel label. Requests that were already forwarded are always
+// handled locally, so that requests do not bounce between members whose rings
+// disagree.
+//
+// It responds with 400 Bad Request to requests without a key, with 503
+// Service Unavailable while this Ringpop instance is not ready and with 502
+// Bad Gateway if the owner does not gossip its port or cannot be reached.
+func (rp *Ringpop) HTTPHandler(handler http.Handler, opts *HTTPOptions) http.Handler {
+	if opts == nil {
+		opts = &HTTPOptions{}
+	}
+	label := opts.PortLabel
+	if label == "" {
+		label = HTTPPortLabel
+	}
+
+	h, _ := fwdhttp.NewHandler(rp, handler, &fwdhttp.Options{
+		Key: opts.Key,
+		Target: func(address string) (*url.URL, error) {
+			return rp.httpTarget(address, label)
+		},
+		Transport:     opts.Transport,
+		FlushInterval: opts.FlushInterval,
+		Logger:        rp.logger,
+	})
+	return h
+}
+
+// httpTarget returns the URL of the HTTP server of the member with the given
+// address, at the port it gossips in the label.
+func (rp *Ringpop) httpTarget(address, label string) (*url.URL, error) {
+	labels, _ := rp.Labels(address)
+	port, ok := labels[label]
+	if !ok {
+		return nil, &HTTPPortUnknownError{Member: address, Label: label}
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "http", Host: net.JoinHostPort(host, port)}, nil
+}
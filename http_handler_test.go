@@ -0,0 +1,129 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHTTPServer returns an HTTP server that responds with the given name to
+// the requests routed by rp, and gossips its port.
+func newHTTPServer(t *testing.T, rp *Ringpop, name string) *httptest.Server {
+	server := httptest.NewServer(rp.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, name)
+	}), &HTTPOptions{Key: HTTPQueryKey("key")}))
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, rp.SetLabel(HTTPPortLabel, port))
+	return server
+}
+
+func httpGet(t *testing.T, url string) (int, string) {
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, string(body)
+}
+
+func TestHTTPHandler(t *testing.T) {
+	rp1, ch1 := newListeningRingpop(t)
+	defer ch1.Close()
+	defer rp1.Destroy()
+
+	rp2, ch2 := newListeningRingpop(t)
+	defer ch2.Close()
+	defer rp2.Destroy()
+
+	address1 := ch1.PeerInfo().HostPort
+	_, err := rp1.Bootstrap(&swim.BootstrapOptions{Hosts: []string{address1}})
+	require.NoError(t, err)
+	server1 := newHTTPServer(t, rp1, "rp1")
+	defer server1.Close()
+
+	// the second member learns about the port of the first when it joins
+	_, err = rp2.Bootstrap(&swim.BootstrapOptions{Hosts: []string{address1}})
+	require.NoError(t, err)
+	server2 := newHTTPServer(t, rp2, "rp2")
+	defer server2.Close()
+
+	owners := map[string]string{address1: "rp1", ch2.PeerInfo().HostPort: "rp2"}
+	served := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner, err := rp2.Lookup(key)
+		require.NoError(t, err)
+
+		status, body := httpGet(t, server2.URL+"/?key="+key)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, owners[owner], body, "expected request to be served by the owner of %s", key)
+		served[body] = true
+	}
+	assert.Len(t, served, 2, "expected requests to be served by both members")
+
+	status, _ := httpGet(t, server2.URL)
+	assert.Equal(t, http.StatusBadRequest, status, "expected request without key to be rejected")
+}
+
+func TestHTTPHandlerForwarded(t *testing.T) {
+	rp, ch := newListeningRingpop(t)
+	defer ch.Close()
+	defer rp.Destroy()
+
+	handler := rp.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "local")
+	}), nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(HTTPKeyHeader, "key")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code, "expected requests to be rejected before bootstrap")
+
+	req.Header.Set(HTTPForwardedHeader, "true")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, "local", recorder.Body.String(), "expected forwarded request to be handled locally")
+}
+
+func TestHTTPHandlerPortUnknown(t *testing.T) {
+	rp, ch := newListeningRingpop(t)
+	defer ch.Close()
+	defer rp.Destroy()
+
+	require.NoError(t, createSingleNodeCluster(rp))
+	rp.handleChanges([]swim.Change{{Address: "127.0.0.1:3002", Status: swim.Alive}})
+
+	_, err := rp.httpTarget("127.0.0.1:3002", HTTPPortLabel)
+	assert.Equal(t, &HTTPPortUnknownError{Member: "127.0.0.1:3002", Label: HTTPPortLabel}, err)
+}
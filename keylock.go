@@ -0,0 +1,211 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gl-works/ringpop-go/events"
+)
+
+var (
+	// ErrNotOwner is returned by LockKey and FenceKey when the key is not
+	// owned by this Ringpop instance.
+	ErrNotOwner = errors.New("key is not owned by this node")
+
+	// ErrLockTimeout is returned by LockKey when the lock on the key could
+	// not be acquired before the timeout expired.
+	ErrLockTimeout = errors.New("timed out waiting for key lock")
+)
+
+// A KeyLock is an advisory lock on a key. The lock is only valid while the
+// Ringpop instance that acquired it owns the key. When ownership of the key
+// moves to another node the lock is invalidated and a KeyLockLostEvent is
+// emitted.
+type KeyLock struct {
+	key  string
+	rp   *Ringpop
+	once sync.Once
+
+	// done is closed when the lock is released or invalidated.
+	done chan struct{}
+	// lost is closed when the lock is invalidated.
+	lost chan struct{}
+}
+
+// keyLocks holds the key locks acquired on a Ringpop instance.
+type keyLocks struct {
+	held map[string]*KeyLock
+	sync.Mutex
+}
+
+// LockKey acquires an advisory lock on key. If the key is already locked,
+// LockKey waits for up to timeout for the lock to be released. It fails with
+// ErrNotOwner if this Ringpop instance does not own the key, either when the
+// call is made or after waiting for the lock. Ownership is decided as by
+// Lookup, so keys pinned by the routing overrides are owned by the member they
+// are pinned to.
+//
+// The lock does not prevent other nodes from operating on the key; it only
+// serializes the callers of LockKey on the owning node. Callers should watch
+// Lost to find out when ownership of the key moves away.
+func (rp *Ringpop) LockKey(key string, timeout time.Duration) (*KeyLock, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+
+	deadline := time.After(timeout)
+
+	for {
+		owned, err := rp.ownsKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if !owned {
+			return nil, ErrNotOwner
+		}
+
+		rp.keyLocks.Lock()
+		held, ok := rp.keyLocks.held[key]
+		if !ok {
+			lock := &KeyLock{
+				key:  key,
+				rp:   rp,
+				done: make(chan struct{}),
+				lost: make(chan struct{}),
+			}
+			if rp.keyLocks.held == nil {
+				rp.keyLocks.held = make(map[string]*KeyLock)
+			}
+			rp.keyLocks.held[key] = lock
+			rp.keyLocks.Unlock()
+			return lock, nil
+		}
+		rp.keyLocks.Unlock()
+
+		select {
+		case <-held.done:
+		case <-deadline:
+			return nil, ErrLockTimeout
+		}
+	}
+}
+
+// ownsKey returns whether the key is owned by this Ringpop instance, see
+// keyOwner.
+func (rp *Ringpop) ownsKey(key string) (bool, error) {
+	me, err := rp.WhoAmI()
+	if err != nil {
+		return false, err
+	}
+
+	owner, ok := rp.keyOwner(key)
+	return ok && owner == me, nil
+}
+
+// revalidateKeyLocks invalidates the locks on all keys that are no longer
+// owned by this Ringpop instance.
+func (rp *Ringpop) revalidateKeyLocks() {
+	me, err := rp.identity()
+	if err != nil {
+		return
+	}
+
+	var lost []*KeyLock
+	var owners []string
+
+	rp.keyLocks.Lock()
+	for key, lock := range rp.keyLocks.held {
+		owner, _ := rp.keyOwner(key)
+		if owner != me {
+			delete(rp.keyLocks.held, key)
+			lost = append(lost, lock)
+			owners = append(owners, owner)
+		}
+	}
+	rp.keyLocks.Unlock()
+
+	for i, lock := range lost {
+		lock.invalidate()
+		rp.HandleEvent(events.KeyLockLostEvent{
+			Key:      lock.key,
+			NewOwner: owners[i],
+		})
+	}
+}
+
+// releaseKeyLocks invalidates all key locks, used when the Ringpop instance
+// is destroyed.
+func (rp *Ringpop) releaseKeyLocks() {
+	rp.keyLocks.Lock()
+	held := rp.keyLocks.held
+	rp.keyLocks.held = nil
+	rp.keyLocks.Unlock()
+
+	for _, lock := range held {
+		lock.invalidate()
+	}
+}
+
+// Key returns the key the lock is held on.
+func (l *KeyLock) Key() string {
+	return l.key
+}
+
+// Lost returns a channel that is closed when the lock is invalidated because
+// ownership of the key moved to another node.
+func (l *KeyLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Valid returns whether the lock is still held.
+func (l *KeyLock) Valid() bool {
+	select {
+	case <-l.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Unlock releases the lock. Unlocking a lock that was already released or
+// invalidated has no effect.
+func (l *KeyLock) Unlock() {
+	l.rp.keyLocks.Lock()
+	if l.rp.keyLocks.held[l.key] == l {
+		delete(l.rp.keyLocks.held, l.key)
+	}
+	l.rp.keyLocks.Unlock()
+
+	l.once.Do(func() {
+		close(l.done)
+	})
+}
+
+// invalidate marks the lock as lost and releases it.
+func (l *KeyLock) invalidate() {
+	l.once.Do(func() {
+		close(l.lost)
+		close(l.done)
+	})
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gl-works/ringpop-go/events"
+)
+
+func (s *RingpopTestSuite) TestLockKeyNotReady() {
+	lock, err := s.ringpop.LockKey("foo", 0)
+	s.Equal(ErrNotBootstrapped, err)
+	s.Nil(lock)
+}
+
+func (s *RingpopTestSuite) TestLockKey() {
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+
+	lock, err := s.ringpop.LockKey("foo", 0)
+	s.Require().NoError(err)
+	s.Equal("foo", lock.Key())
+	s.True(lock.Valid())
+
+	_, err = s.ringpop.LockKey("foo", time.Millisecond)
+	s.Equal(ErrLockTimeout, err, "expected locked key to time out")
+
+	other, err := s.ringpop.LockKey("bar", 0)
+	s.NoError(err, "expected other keys to be lockable")
+	other.Unlock()
+
+	lock.Unlock()
+	s.False(lock.Valid())
+
+	lock, err = s.ringpop.LockKey("foo", 0)
+	s.NoError(err, "expected released key to be lockable")
+	lock.Unlock()
+}
+
+func (s *RingpopTestSuite) TestLockKeyWaitsForUnlock() {
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+
+	lock, err := s.ringpop.LockKey("foo", 0)
+	s.Require().NoError(err)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lock.Unlock()
+	}()
+
+	next, err := s.ringpop.LockKey("foo", time.Second)
+	s.Require().NoError(err, "expected lock to be acquired once released")
+	next.Unlock()
+}
+
+func (s *RingpopTestSuite) TestLockKeyLostOnOwnershipChange() {
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+
+	listener := &dummyListener{}
+	s.ringpop.RegisterListener(listener)
+
+	var locks []*KeyLock
+	for i := 0; i < 20; i++ {
+		lock, err := s.ringpop.LockKey(fmt.Sprintf("key%d", i), 0)
+		s.Require().NoError(err)
+		locks = append(locks, lock)
+	}
+
+	s.ringpop.ring.AddRemoveServers(genAddresses(1, 2, 11), nil)
+
+	me, _ := s.ringpop.WhoAmI()
+	var lost int
+	for _, lock := range locks {
+		owner, _ := s.ringpop.ring.Lookup(lock.Key())
+		if owner == me {
+			s.True(lock.Valid(), "expected lock on owned key to be valid")
+			continue
+		}
+
+		lost++
+		s.False(lock.Valid(), "expected lock on moved key to be invalidated")
+		select {
+		case <-lock.Lost():
+		default:
+			s.Fail("expected lost channel to be closed")
+		}
+	}
+	s.NotZero(lost, "expected ownership of some keys to move")
+
+	_, err := s.ringpop.LockKey("key0", 0)
+	if owner, _ := s.ringpop.ring.Lookup("key0"); owner != me {
+		s.Equal(ErrNotOwner, err)
+	}
+}
+
+func (s *RingpopTestSuite) TestDestroyInvalidatesKeyLocks() {
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+
+	lock, err := s.ringpop.LockKey("foo", 0)
+	s.Require().NoError(err)
+
+	s.ringpop.Destroy()
+	s.False(lock.Valid(), "expected lock to be invalidated on destroy")
+}
+
+func (s *RingpopTestSuite) TestLockKeyRoutingOverrides() {
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+	other := "127.0.0.1:3002"
+	s.ringpop.ring.AddServer(other)
+
+	me, _ := s.ringpop.WhoAmI()
+	var mine, theirs string
+	for i := 0; mine == "" || theirs == ""; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if owner, _ := s.ringpop.ring.Lookup(key); owner == me {
+			mine = key
+		} else {
+			theirs = key
+		}
+	}
+
+	lock, err := s.ringpop.LockKey(mine, 0)
+	s.Require().NoError(err)
+
+	s.ringpop.overrides.set(RoutingPins{Keys: map[string]string{mine: other, theirs: me}}, time.Unix(1000, 0))
+	s.ringpop.HandleEvent(events.RoutingOverridesReloadedEvent{Keys: 2})
+	s.False(lock.Valid(), "expected lock on key pinned away to be invalidated")
+
+	_, err = s.ringpop.LockKey(mine, 0)
+	s.Equal(ErrNotOwner, err, "expected key pinned to another member not to be owned")
+
+	lock, err = s.ringpop.LockKey(theirs, 0)
+	s.Require().NoError(err, "expected key pinned to this member to be owned")
+	lock.Unlock()
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"math"
+
+	"github.com/dgryski/go-farm"
+	"github.com/gl-works/ringpop-go/events"
+)
+
+// recentLoad returns the load the member at address gossiped within the load
+// window of the rebalance advisor.
+func (rp *Ringpop) recentLoad(address string) (float64, bool) {
+	cutoff := rp.clock.Now().Add(-rp.config.Advisor.LoadWindow)
+
+	rp.loads.Lock()
+	sample, ok := rp.loads.samples[address]
+	rp.loads.Unlock()
+
+	if !ok || sample.received.Before(cutoff) {
+		return 0, false
+	}
+	return sample.load, true
+}
+
+// spillFraction returns the position of the key in [0, 1), derived from its
+// hash, so that the same keys are spilled on every lookup and on every member.
+func spillFraction(key string) float64 {
+	return float64(farm.Fingerprint32([]byte(key))) / (math.MaxUint32 + 1)
+}
+
+// spillOverloaded returns the servers with the secondary owner of the key
+// first and its primary owner second, when the primary owner gossiped a load
+// above the spillover threshold and the key is among the spilled fraction of
+// keys. Otherwise the servers are returned unchanged. See func LoadSpillover.
+func (rp *Ringpop) spillOverloaded(key string, servers []string) []string {
+	threshold := rp.config.SpillThreshold
+	if threshold <= 0 || len(servers) == 0 {
+		return servers
+	}
+
+	primary, ok := rp.ring.Lookup(key)
+	if !ok {
+		return servers
+	}
+	load, ok := rp.recentLoad(primary)
+	if !ok || load <= threshold {
+		return servers
+	}
+
+	spilled := spillFraction(key) < rp.config.SpillFraction
+	rp.HandleEvent(events.LookupNSpillEvent{
+		Key:     key,
+		Primary: primary,
+		Load:    load,
+		Spilled: spilled,
+	})
+	if !spilled {
+		return servers
+	}
+
+	// the second owner as returned by LookupN, like LookupSpill
+	secondary := ""
+	for _, owner := range rp.ring.LookupN(key, 2) {
+		if owner != primary {
+			secondary = owner
+			break
+		}
+	}
+	if secondary == "" {
+		return servers
+	}
+
+	spill := make([]string, 0, len(servers)+1)
+	spill = append(spill, secondary, primary)
+	for _, server := range servers {
+		if server != primary && server != secondary {
+			spill = append(spill, server)
+		}
+	}
+	return spill[:len(servers)]
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+func TestLoadSpillover(t *testing.T) {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()),
+		LoadSpillover(0.8, 0.5))
+	require.NoError(t, err)
+	require.NoError(t, createSingleNodeCluster(rp))
+	defer rp.Destroy()
+	rp.ring.AddServer("127.0.0.1:3002")
+
+	// keys owned by the other member, which becomes overloaded
+	primary := "127.0.0.1:3002"
+	var keys []string
+	for i := 0; len(keys) < 200; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if owner, _ := rp.ring.Lookup(key); owner == primary {
+			keys = append(keys, key)
+		}
+	}
+
+	lookup := func(key string, n int) string {
+		servers, err := rp.LookupN(key, n)
+		require.NoError(t, err)
+		require.Len(t, servers, n)
+		return servers[0]
+	}
+
+	rp.recordLoad(primary, 0.5)
+	for _, key := range keys {
+		assert.Equal(t, primary, lookup(key, 1), "expected no spill below the threshold")
+	}
+
+	rp.recordLoad(primary, 0.9)
+	spilled := 0
+	for _, key := range keys {
+		first := lookup(key, 1)
+		if first == primary {
+			assert.True(t, spillFraction(key) >= 0.5, "expected key %s to be spilled", key)
+			continue
+		}
+
+		spilled++
+		assert.True(t, spillFraction(key) < 0.5, "expected key %s not to be spilled", key)
+		assert.Equal(t, first, lookup(key, 2), "expected the secondary owner first")
+	}
+	assert.True(t, spilled > 50 && spilled < 150,
+		"expected about half of the keys to be spilled, got %d", spilled)
+
+	// order the keys are looked up in does not matter
+	servers, err := rp.LookupNWithOrder(keys[0], 2, LatencyOrder)
+	require.NoError(t, err)
+	assert.Len(t, servers, 2)
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A ReplicaOrder controls the order of the members returned by LookupN.
+type ReplicaOrder int
+
+const (
+	// RingOrder returns the members in the order they own the key on the
+	// ring. This is the default.
+	RingOrder ReplicaOrder = iota
+
+	// LatencyOrder returns the same members, ordered so that callers that
+	// try replicas in order try the fastest healthy replica first: healthy
+	// members before suspect or degraded ones, and then by the round trip
+	// time observed in pings, lowest first. The local member has no round
+	// trip time and comes first among the healthy members; members without
+	// an observed round trip time follow the others. Ties keep ring order.
+	LatencyOrder
+)
+
+func (o ReplicaOrder) String() string {
+	switch o {
+	case RingOrder:
+		return "ring"
+	case LatencyOrder:
+		return "latency"
+	}
+	return fmt.Sprintf("order(%d)", int(o))
+}
+
+// LookupNWithOrder returns the addresses of the servers in the ring that are
+// responsible for the key like LookupN, but in the given order instead of the
+// default set with the LookupNOrder option.
+func (rp *Ringpop) LookupNWithOrder(key string, n int, order ReplicaOrder) ([]string, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+
+	servers := rp.ring.LookupN(key, n)
+	if order == RingOrder {
+		servers = rp.spillOverloaded(key, servers)
+	}
+	if order == LatencyOrder {
+		rp.orderByLatency(servers)
+	}
+	if pinned, ok := rp.overrideLookup(key); ok {
+		servers = pinFirst(servers, pinned)
+	}
+	return servers, nil
+}
+
+// pinFirst moves the pinned member to the front of the servers, or puts it in
+// place of the last one if it is not among them.
+func pinFirst(servers []string, pinned string) []string {
+	if len(servers) == 0 {
+		return servers
+	}
+
+	pinnedAt := len(servers) - 1
+	for i, server := range servers {
+		if server == pinned {
+			pinnedAt = i
+			break
+		}
+	}
+	copy(servers[1:pinnedAt+1], servers[:pinnedAt])
+	servers[0] = pinned
+	return servers
+}
+
+// replicaRank is the sort key of a member in LatencyOrder.
+type replicaRank struct {
+	unhealthy bool
+	unknown   bool
+	rtt       time.Duration
+}
+
+// less returns whether a member with rank r is tried before one with rank o.
+func (r replicaRank) less(o replicaRank) bool {
+	if r.unhealthy != o.unhealthy {
+		return !r.unhealthy
+	}
+	if r.unknown != o.unknown {
+		return !r.unknown
+	}
+	return r.rtt < o.rtt
+}
+
+// orderByLatency sorts the servers in LatencyOrder.
+func (rp *Ringpop) orderByLatency(servers []string) {
+	local, _ := rp.identity()
+
+	ranked := serversByRank{
+		servers: servers,
+		ranks:   make([]replicaRank, len(servers)),
+	}
+	for i, server := range servers {
+		ranked.ranks[i].unhealthy = rp.ring.IsSuspectServer(server) || rp.Degraded(server)
+		if server != local {
+			skew, ok := rp.node.ClockSkew(server)
+			ranked.ranks[i].unknown = !ok
+			ranked.ranks[i].rtt = skew.RTT
+		}
+	}
+
+	sort.Stable(ranked)
+}
+
+// serversByRank sorts servers by their rank in LatencyOrder.
+type serversByRank struct {
+	servers []string
+	ranks   []replicaRank
+}
+
+func (s serversByRank) Len() int           { return len(s.servers) }
+func (s serversByRank) Less(i, j int) bool { return s.ranks[i].less(s.ranks[j]) }
+func (s serversByRank) Swap(i, j int) {
+	s.servers[i], s.servers[j] = s.servers[j], s.servers[i]
+	s.ranks[i], s.ranks[j] = s.ranks[j], s.ranks[i]
+}
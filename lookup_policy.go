@@ -0,0 +1,142 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gl-works/ringpop-go/events"
+)
+
+// An UnavailablePolicy controls what a lookup returns when the owner of the
+// key is suspected to have failed, but has not been removed from the ring.
+type UnavailablePolicy int
+
+const (
+	// ReturnUnavailable returns the owner regardless. This is the default.
+	ReturnUnavailable UnavailablePolicy = iota
+
+	// SkipUnavailable returns the member that takes over the key once the
+	// owner is removed from the ring.
+	SkipUnavailable
+
+	// RejectUnavailable returns an OwnerUnavailableError.
+	RejectUnavailable
+)
+
+func (p UnavailablePolicy) String() string {
+	switch p {
+	case ReturnUnavailable:
+		return "return"
+	case SkipUnavailable:
+		return "skip"
+	case RejectUnavailable:
+		return "reject"
+	}
+	return fmt.Sprintf("policy(%d)", int(p))
+}
+
+// An OwnerUnavailableError is returned by a lookup with the RejectUnavailable
+// policy when the owner of the key is suspected to have failed.
+type OwnerUnavailableError struct {
+	Key   string
+	Owner string
+}
+
+func (e *OwnerUnavailableError) Error() string {
+	return fmt.Sprintf("owner %s of key %q is unavailable", e.Owner, e.Key)
+}
+
+// LookupWithPolicy returns the address of the server in the ring that is
+// responsible for the key like Lookup, but applies the given policy instead of
+// the default set with the UnavailableOwner option when that server is
+// suspected to have failed.
+func (rp *Ringpop) LookupWithPolicy(key string, policy UnavailablePolicy) (string, error) {
+	if !rp.Ready() {
+		return "", rp.errNotReady()
+	}
+
+	startTime := time.Now()
+
+	if dest, ok := rp.overrideLookup(key); ok {
+		rp.emit(events.LookupEvent{Key: key, Duration: time.Now().Sub(startTime)})
+		return dest, nil
+	}
+
+	dest, owner, err := rp.ringOwner(key, policy)
+	if owner != "" {
+		rp.compareLookup(key, owner)
+	}
+
+	rp.emit(events.LookupEvent{Key: key, Duration: time.Now().Sub(startTime)})
+
+	if err == errNoDestination {
+		rp.logger.WithField("key", key).Warn(err)
+	}
+	return dest, err
+}
+
+// errNoDestination is returned by lookups when the ring is empty.
+var errNoDestination = errors.New("could not find destination for key")
+
+// ringOwner returns the destination of the key on the ring with the policy,
+// and the owner of the key on the ring before the policy was applied.
+func (rp *Ringpop) ringOwner(key string, policy UnavailablePolicy) (dest, owner string, err error) {
+	owner, ok := rp.ring.Lookup(key)
+	if !ok {
+		return "", "", errNoDestination
+	}
+	if policy == ReturnUnavailable || !rp.ring.IsSuspectServer(owner) {
+		return owner, owner, nil
+	}
+
+	switch policy {
+	case SkipUnavailable:
+		if dest, ok = rp.ring.LookupStandby(key); !ok {
+			return "", owner, errNoDestination
+		}
+		return dest, owner, nil
+	default:
+		return "", owner, &OwnerUnavailableError{Key: key, Owner: owner}
+	}
+}
+
+// keyOwner returns the member that owns the key the way requests are routed
+// by Lookup: the member the key is pinned to by the routing overrides, or else
+// the owner on the ring, or its standby if the owner is suspect and
+// unavailable owners are skipped. Unlike Lookup, it emits no events. Key locks
+// and ownership fences check ownership with it, so that they agree with the
+// routing of requests.
+func (rp *Ringpop) keyOwner(key string) (string, bool) {
+	if member, ok := rp.overrides.lookup(key); ok && rp.ring.HasServer(member) {
+		return member, true
+	}
+
+	// a suspect owner that rejects requests still owns its keys
+	policy := ReturnUnavailable
+	if rp.config.UnavailableOwner == SkipUnavailable {
+		policy = SkipUnavailable
+	}
+	dest, _, err := rp.ringOwner(key, policy)
+	return dest, err == nil
+}
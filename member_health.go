@@ -0,0 +1,257 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/gl-works/ringpop-go/swim"
+)
+
+// memberHealth tracks which members gossiped that they are degraded.
+type memberHealth struct {
+	degraded map[string]bool
+	sync.RWMutex
+}
+
+// The reasons this Ringpop instance is degraded for.
+const (
+	degradedByUser     = "user"
+	degradedByPushback = "pushback"
+	degradedByMesh     = "mesh"
+)
+
+// degradation tracks the reasons this Ringpop instance is degraded for, so
+// that clearing one reason keeps the instance degraded for the others. The
+// pushback timer clears the pushback signal when its window expires.
+type degradation struct {
+	reasons  map[string]bool
+	pushback *clock.Timer
+	sync.Mutex
+}
+
+// SetDegraded marks this Ringpop instance as degraded, or healthy again. The
+// health is gossiped to all members, where LookupSpill routes part of the
+// traffic for keys owned by a degraded member to the next owner. SetPushback
+// and an unready service mesh sidecar mark the instance as degraded as well;
+// the instance stays degraded until none of them holds.
+func (rp *Ringpop) SetDegraded(degraded bool) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	return rp.setDegradedFor(degradedByUser, degraded)
+}
+
+// setDegradedFor marks this Ringpop instance as degraded for the reason, or
+// clears the reason, and gossips it as degraded while any reason holds.
+func (rp *Ringpop) setDegradedFor(reason string, degraded bool) error {
+	rp.degradation.Lock()
+	defer rp.degradation.Unlock()
+
+	return rp.setDegradedForNoLock(reason, degraded)
+}
+
+// setDegradedForNoLock is setDegradedFor for callers that hold the lock of the
+// degradation.
+func (rp *Ringpop) setDegradedForNoLock(reason string, degraded bool) error {
+	if degraded {
+		if rp.degradation.reasons == nil {
+			rp.degradation.reasons = make(map[string]bool)
+		}
+		rp.degradation.reasons[reason] = true
+	} else {
+		delete(rp.degradation.reasons, reason)
+	}
+	return rp.node.SetDegraded(len(rp.degradation.reasons) > 0)
+}
+
+// setPushback signals pushback for retryAfter and marks this Ringpop instance
+// as degraded for as long, replacing the previous signal. A retryAfter of zero
+// clears the signal.
+func (rp *Ringpop) setPushback(retryAfter time.Duration, load float64) error {
+	rp.degradation.Lock()
+	defer rp.degradation.Unlock()
+
+	rp.stopPushbackNoLock()
+	rp.node.SetPushback(retryAfter, load)
+	if retryAfter > 0 {
+		var timer *clock.Timer
+		timer = rp.clock.AfterFunc(retryAfter, func() {
+			rp.expirePushback(timer)
+		})
+		rp.degradation.pushback = timer
+	}
+	return rp.setDegradedForNoLock(degradedByPushback, retryAfter > 0)
+}
+
+// expirePushback clears the pushback signal once its window expired, unless
+// the signal was replaced in the meantime.
+func (rp *Ringpop) expirePushback(timer *clock.Timer) {
+	rp.degradation.Lock()
+	defer rp.degradation.Unlock()
+
+	if rp.degradation.pushback != timer || rp.destroyed() {
+		return
+	}
+	rp.degradation.pushback = nil
+	rp.node.SetPushback(0, 0)
+	if err := rp.setDegradedForNoLock(degradedByPushback, false); err != nil {
+		rp.logger.WithField("error", err).Warn("unable to clear expired pushback")
+	}
+}
+
+// stopPushback stops the timer of the pushback signal.
+func (rp *Ringpop) stopPushback() {
+	rp.degradation.Lock()
+	rp.stopPushbackNoLock()
+	rp.degradation.Unlock()
+}
+
+func (rp *Ringpop) stopPushbackNoLock() {
+	if rp.degradation.pushback != nil {
+		rp.degradation.pushback.Stop()
+		rp.degradation.pushback = nil
+	}
+}
+
+// Annotate changes the annotations of this Ringpop instance, such as
+// "maintenance": "until 5pm by alice". Annotations are gossiped to all members
+// and are visible in their membership, see View and Annotations. Annotations
+// with an empty value are removed. They can also be changed by operators
+// through the /admin/member/annotate endpoint.
+func (rp *Ringpop) Annotate(annotations map[string]string) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	return rp.node.Annotate(annotations)
+}
+
+// Annotations returns the annotations the member with the given address
+// gossiped. Ok is false if the member is not known.
+func (rp *Ringpop) Annotations(address string) (annotations map[string]string, ok bool) {
+	if !rp.Ready() {
+		return nil, false
+	}
+	return rp.node.Annotations(address)
+}
+
+// SetLabel sets the label of this Ringpop instance with the given key, such as
+// "canary": "true". Labels are gossiped to all members, are visible in their
+// membership and are covered by swim.ChecksumV3, see Labels.
+func (rp *Ringpop) SetLabel(key, value string) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	return rp.node.Labels().Set(key, value)
+}
+
+// DeleteLabel removes the label of this Ringpop instance with the given key.
+func (rp *Ringpop) DeleteLabel(key string) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	return rp.node.Labels().Delete(key)
+}
+
+// Labels returns the labels the member with the given address gossiped. Ok is
+// false if the member is not known.
+func (rp *Ringpop) Labels(address string) (labels map[string]string, ok bool) {
+	if !rp.Ready() {
+		return nil, false
+	}
+	return rp.node.MemberLabels(address)
+}
+
+// SetMaintenance declares a maintenance window of this Ringpop instance that
+// ends at until, during which other members suspect it for longer before
+// declaring it faulty, see MaintenanceWindows. The window is gossiped as the
+// swim.MaintenanceAnnotation annotation. A zero until ends the window.
+func (rp *Ringpop) SetMaintenance(until time.Time) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	return rp.node.SetMaintenance(until)
+}
+
+// InMaintenance returns whether the member with the given address is in a
+// maintenance window it declared.
+func (rp *Ringpop) InMaintenance(address string) bool {
+	if !rp.Ready() {
+		return false
+	}
+	return rp.node.InMaintenance(address)
+}
+
+// Degraded returns whether the member with the given address gossiped that it
+// is degraded.
+func (rp *Ringpop) Degraded(address string) bool {
+	rp.memberHealth.RLock()
+	degraded := rp.memberHealth.degraded[address]
+	rp.memberHealth.RUnlock()
+	return degraded
+}
+
+// LookupSpill returns the owner of the key like Lookup, except when the owner
+// is degraded. In that case a fraction of the lookups, between 0 and 1,
+// returns the second owner of the key (as returned by LookupN) instead, to
+// spill traffic away from the degraded member until it recovers.
+func (rp *Ringpop) LookupSpill(key string, fraction float64) (string, error) {
+	if !rp.Ready() {
+		return "", rp.errNotReady()
+	}
+
+	owners := rp.ring.LookupN(key, 2)
+	if len(owners) == 0 {
+		return rp.Lookup(key)
+	}
+
+	primary, _ := rp.ring.Lookup(key)
+	if !rp.Degraded(primary) || rand.Float64() >= fraction {
+		return primary, nil
+	}
+
+	for _, owner := range owners {
+		if owner != primary {
+			return owner, nil
+		}
+	}
+
+	return primary, nil
+}
+
+// trackHealth records the health of the members in the changes.
+func (rp *Ringpop) trackHealth(changes []swim.Change) {
+	rp.memberHealth.Lock()
+	for _, change := range changes {
+		if change.Status == swim.Alive && change.Health == swim.Degraded {
+			if rp.memberHealth.degraded == nil {
+				rp.memberHealth.degraded = make(map[string]bool)
+			}
+			rp.memberHealth.degraded[change.Address] = true
+		} else {
+			delete(rp.memberHealth.degraded, change.Address)
+		}
+	}
+	rp.memberHealth.Unlock()
+}
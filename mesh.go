@@ -0,0 +1,92 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/shared"
+)
+
+// meshState tracks the readiness of the service mesh sidecar.
+type meshState struct {
+	// unready is whether the sidecar reported not to be ready, and degraded
+	// whether the local member was marked degraded because of it.
+	unready  bool
+	degraded bool
+	sync.Mutex
+}
+
+// Mesh returns the service mesh this Ringpop instance routes gossip and
+// forwarded requests through, or nil. See func ServiceMesh.
+func (rp *Ringpop) Mesh() *shared.Mesh {
+	return rp.config.Mesh
+}
+
+// SidecarReadiness returns a ReadinessCheck that passes when the HTTP endpoint
+// at url, such as the readiness endpoint of a service mesh sidecar, responds
+// with a 2xx status within timeout.
+func SidecarReadiness(url string, timeout time.Duration) ReadinessCheck {
+	client := &http.Client{Timeout: timeout}
+	return func() bool {
+		res, err := client.Get(url)
+		if err != nil {
+			return false
+		}
+		res.Body.Close()
+		return res.StatusCode >= 200 && res.StatusCode < 300
+	}
+}
+
+// checkMeshReadiness checks whether the sidecar is ready and marks the local
+// member degraded while it is not, so that other members route traffic away
+// from it. Once the sidecar recovers, the degradation it caused is cleared,
+// and the local member stays degraded only for other reasons.
+func (rp *Ringpop) checkMeshReadiness() {
+	ready := rp.config.MeshReadiness()
+
+	rp.mesh.Lock()
+	changed := rp.mesh.unready == ready
+	rp.mesh.unready = !ready
+
+	if rp.Ready() && rp.mesh.degraded == ready {
+		if err := rp.setDegradedFor(degradedByMesh, !ready); err != nil {
+			rp.logger.WithField("error", err).Warn("failed to change health for sidecar readiness")
+		} else {
+			rp.mesh.degraded = !ready
+		}
+	}
+	rp.mesh.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if ready {
+		rp.logger.Info("service mesh sidecar is ready")
+	} else {
+		rp.logger.Warn("service mesh sidecar is not ready")
+	}
+	rp.HandleEvent(events.MeshReadinessChangedEvent{Ready: ready})
+}
@@ -22,13 +22,18 @@ package ringpop
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"strconv"
 	"time"
 
 	"github.com/benbjohnson/clock"
 	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/forward"
 	"github.com/gl-works/ringpop-go/hashring"
 	"github.com/gl-works/ringpop-go/logging"
 	"github.com/gl-works/ringpop-go/shared"
+	"github.com/gl-works/ringpop-go/swim"
 )
 
 type configuration struct {
@@ -42,6 +47,242 @@ type configuration struct {
 	// "ring.checksum-periodic". See func RingChecksumStatPeriod for
 	// specifics.
 	RingChecksumStatPeriod time.Duration
+
+	// ProtocolCapture records the gossip protocol messages of the SWIM node
+	// when set.
+	ProtocolCapture swim.Capturer
+
+	// ForwardEndpoints are the endpoints registered as forwardable with the
+	// forwarder.
+	ForwardEndpoints []forwardEndpoint
+
+	// Advisor configures the rebalance advisor. See func RebalanceAdvisor.
+	Advisor AdvisorOptions
+
+	// ZoneAnnotation is the annotation members are grouped into zones by.
+	// See func ZoneAnnotation.
+	ZoneAnnotation string
+
+	// WeightLabel is the label members declare their weight on the ring
+	// with. See func WeightLabel.
+	WeightLabel string
+
+	// ForwardErrorMappings map errors of wrapped handlers to TChannel error
+	// codes. See func ForwardErrorMapping.
+	ForwardErrorMappings []forward.ErrorMapping
+
+	// WatchdogPeriods and Watchdog configure the watchdog of the SWIM node.
+	// See func Watchdog.
+	WatchdogPeriods int
+	Watchdog        swim.WatchdogFunc
+
+	// PingHook is called on each ping the SWIM node receives. See func
+	// PingHook.
+	PingHook swim.PingHook
+
+	// AdminAuthenticator authenticates the callers of the admin endpoints,
+	// and AdminToken is the token this instance presents to the admin
+	// endpoints of other members. See func AdminAuth.
+	AdminAuthenticator swim.AdminAuthenticator
+	AdminToken         string
+
+	// MaintenanceTimeoutFactor and MaintenanceConfirmations configure how
+	// members in a maintenance window are suspected. See func
+	// MaintenanceWindows.
+	MaintenanceTimeoutFactor int
+	MaintenanceConfirmations int
+
+	// FailureDetector probes members in each protocol period. See func
+	// FailureDetector.
+	FailureDetector swim.FailureDetector
+
+	// GossipTargetSelector selects the member probed in each protocol
+	// period. See func GossipTargetSelector.
+	GossipTargetSelector swim.TargetSelector
+
+	// Transport carries the membership protocol and forwarded requests. See
+	// func Transport.
+	Transport Transporter
+
+	// Keyring seals the messages of the membership protocol. See func
+	// GossipEncryption.
+	Keyring *swim.Keyring
+
+	// LocalHealthMax is the maximum local health score of the SWIM node.
+	// See func LocalHealth.
+	LocalHealthMax int
+
+	// SuspicionTimeout computes the suspicion timeout of the SWIM node from
+	// the cluster size. See func SuspicionTimeout.
+	SuspicionTimeout swim.SuspicionTimeoutFunc
+
+	// SuspicionConfirmations and SuspicionMaxTimeoutFactor configure the
+	// suspicion timeout reduction of the SWIM node. See func
+	// SuspicionConfirmations.
+	SuspicionConfirmations    int
+	SuspicionMaxTimeoutFactor int
+
+	// DuplicateThreshold, DuplicateWindow and DuplicateCooldown configure the
+	// detection of addresses claimed by two processes. See func
+	// DuplicateAddresses.
+	DuplicateThreshold int
+	DuplicateWindow    time.Duration
+	DuplicateCooldown  time.Duration
+
+	// Remediation is the policy for persistent membership checksum
+	// mismatches with a peer. See func ChecksumRemediation.
+	Remediation swim.RemediationPolicy
+
+	// FaultyTTL and TombstoneTTL configure reaping faulty members. See func
+	// FaultyMemberReaping.
+	FaultyTTL    time.Duration
+	TombstoneTTL time.Duration
+
+	// SyncInterval and SyncJitter configure anti-entropy syncs. See func
+	// AntiEntropy.
+	SyncInterval time.Duration
+	SyncJitter   time.Duration
+
+	// LabelStore and PersistedLabels persist labels of the local member
+	// across restarts. See func LabelPersistence.
+	LabelStore      swim.LabelStore
+	PersistedLabels []string
+
+	// PartitionHealPeriod and PartitionHealRateLimit configure partition
+	// healing. See func PartitionHealing.
+	PartitionHealPeriod    time.Duration
+	PartitionHealRateLimit time.Duration
+
+	// SpillThreshold and SpillFraction configure spilling keys of overloaded
+	// members. See func LoadSpillover.
+	SpillThreshold float64
+	SpillFraction  float64
+
+	// ShadowRing is the candidate ring configuration lookups are compared
+	// against. See func ShadowRing.
+	ShadowRing *ShadowRingConfiguration
+
+	// SelfEvictPingRatio is the fraction of the pingable members that are
+	// notified right away when this instance evicts itself. See func
+	// SelfEviction.
+	SelfEvictPingRatio float64
+
+	// JoinStaggerWindow and JoinAdmissionLimit protect against join storms.
+	// See func JoinStormProtection.
+	JoinStaggerWindow  time.Duration
+	JoinAdmissionLimit int
+
+	// RingIdentity is the logical identity this instance is placed on the
+	// ring by. See func RingIdentity.
+	RingIdentity string
+
+	// RingProofs attaches the ring proof to the replies of admin endpoints
+	// and of handlers wrapped with WrapHandler.
+	// See func RingProofs.
+	RingProofs bool
+
+	// RingMigration is the hash ring configuration this instance migrates to
+	// when the cluster enters RingMigrationEpoch. See func RingMigration.
+	RingMigration      *hashring.Configuration
+	RingMigrationEpoch int
+
+	// OverridesPath is the routing overrides file, checked for changes every
+	// OverridesInterval. See func RoutingOverrides.
+	OverridesPath     string
+	OverridesInterval time.Duration
+
+	// Arbiter and ArbiterFilter decide whether suspect members are declared
+	// faulty. See func FaultyArbiter.
+	Arbiter       swim.Arbiter
+	ArbiterFilter swim.ArbiterFilter
+
+	// RampPeriod is the period over which this instance ramps in its
+	// ownership of the keyspace after bootstrapping. See func RampIn.
+	RampPeriod time.Duration
+
+	// BindAddress is the address the channel of this instance listens on
+	// and AdvertiseAddress the address the instance is known by in the
+	// cluster. See func BindAddress.
+	BindAddress      string
+	AdvertiseAddress string
+
+	// AdvertiseAddresses and AddressSelector configure the addresses this
+	// instance advertises and how the addresses advertised by others are
+	// dialed. See func AdvertiseAddresses.
+	AdvertiseAddresses []string
+	AddressSelector    swim.AddressSelector
+
+	// Features are the application defined features this instance
+	// advertises to its peers. See func Features.
+	Features swim.Features
+
+	// FalsePositiveThreshold is the false positive rate of the failure
+	// detector above which an alert is raised. See func
+	// FalsePositiveThreshold.
+	FalsePositiveThreshold float64
+
+	// ClockSkewThreshold is the estimated clock offset of a member above
+	// which a warning is raised. See func ClockSkewThreshold.
+	ClockSkewThreshold time.Duration
+
+	// ChecksumVersion is the version of the membership checksum. See func
+	// MembershipChecksumVersion.
+	ChecksumVersion swim.ChecksumVersion
+
+	// MaxBacklog and BacklogStore bound the dissemination backlog of the
+	// SWIM node. See func DisseminationBacklog.
+	MaxBacklog   int
+	BacklogStore swim.BacklogStore
+
+	// FullSyncBudget bounds the size of a full sync sent in one protocol
+	// round. See func FullSyncBudget.
+	FullSyncBudget int
+
+	// UnavailableOwner is the default policy of lookups for keys owned by a
+	// suspect member. See func UnavailableOwner.
+	UnavailableOwner UnavailablePolicy
+
+	// LookupNOrder is the default order of the members returned by LookupN.
+	// See func LookupNOrder.
+	LookupNOrder ReplicaOrder
+
+	// ForwardLimit bounds the number of requests forwarded concurrently to
+	// a single member. See func ForwardConcurrencyLimit.
+	ForwardLimit forward.DestinationLimit
+
+	// ReadinessCheck and ReadinessInterval keep Bootstrap in standby until
+	// the application is ready. See func JoinWhenReady.
+	ReadinessCheck    ReadinessCheck
+	ReadinessInterval time.Duration
+
+	// ListenerPanicLimit is the number of panics after which an event
+	// listener is deregistered. See func ListenerPanicLimit.
+	ListenerPanicLimit int
+
+	// DebugSampling logs the protocol messages of a fraction of the protocol
+	// periods. See func ProtocolDebugSampling.
+	DebugSampling swim.DebugSampling
+
+	// TimelineSize is the number of entries the event timeline keeps. See
+	// func EventTimeline.
+	TimelineSize int
+
+	// Mesh routes gossip and forwarded requests through a service mesh
+	// sidecar, whose readiness is checked every MeshReadinessInterval. See
+	// func ServiceMesh.
+	Mesh                  *shared.Mesh
+	MeshReadiness         ReadinessCheck
+	MeshReadinessInterval time.Duration
+
+	// ConvergenceWindow is how long the membership must be stable for
+	// WaitForConvergence to return. See func ConvergenceWindow.
+	ConvergenceWindow time.Duration
+}
+
+// forwardEndpoint is an endpoint registered with the ForwardEndpoint option.
+type forwardEndpoint struct {
+	service, endpoint string
+	opts              *forward.EndpointOptions
 }
 
 // An Option is a modifier functions that configure/modify a real Ringpop
@@ -126,7 +367,14 @@ func Channel(ch shared.TChannel) Option {
 //     )
 //
 // See documentation on the `HashRingConfiguration` struct for more information
-// about what options are available.
+// about what options are available. Rendezvous hashing can be selected instead
+// of consistent hashing, and compared with it on live traffic first with the
+// ShadowRing option. Large clusters can look up owners in constant time in a
+// maglev table instead. The hash function of the ring can be replaced by any
+// 32-bit or 64-bit hash function. The ring checksum is computed with the same
+// function, and the function is part of the ring configuration that is
+// compared when joining, so nodes configured with different functions do not
+// join each other's clusters.
 func HashRingConfig(c *hashring.Configuration) Option {
 	return func(r *Ringpop) error {
 		r.configHashRing = c
@@ -197,6 +445,58 @@ func IdentityResolverFunc(resolver IdentityResolver) Option {
 	}
 }
 
+// BindAddress separates the address the TChannel of this Ringpop instance
+// listens on from the address the instance is known by in the cluster, for
+// containerized and NATed deployments where the two differ. bind is the local
+// address passed to ListenAndServe and may use an unspecified host, such as
+// 0.0.0.0, to listen on all interfaces. advertise is the routable address
+// peers dial, and becomes the identity of the instance; an advertised port of
+// 0 is replaced with the port the channel listens on.
+//
+// Example:
+//
+//     ch.ListenAndServe("0.0.0.0:21130")
+//     ringpop.New("my-app",
+//         ringpop.Channel(ch),
+//         ringpop.BindAddress("0.0.0.0:21130", "10.32.12.2:21130"),
+//     )
+//
+// BindAddress replaces the identity resolver, so it should not be combined
+// with Identity or IdentityResolverFunc.
+func BindAddress(bind, advertise string) Option {
+	return func(r *Ringpop) error {
+		if _, _, err := splitBindAddress(bind); err != nil {
+			return fmt.Errorf("invalid bind address %q: %v", bind, err)
+		}
+		host, _, err := splitBindAddress(advertise)
+		if err != nil {
+			return fmt.Errorf("invalid advertised address %q: %v", advertise, err)
+		}
+		if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+			return fmt.Errorf("advertised address %q is not routable", advertise)
+		}
+
+		r.config.BindAddress = bind
+		r.config.AdvertiseAddress = advertise
+		r.identityResolver = r.advertisedIdentityResolver
+		return nil
+	}
+}
+
+// splitBindAddress splits a host:port address and checks that the port is a
+// valid port number.
+func splitBindAddress(address string) (host string, port int, err error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err = strconv.Atoi(portString)
+	if err != nil || port < 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port %q", portString)
+	}
+	return host, port, nil
+}
+
 // RingChecksumStatPeriodNever defines a "period" which disables
 // ring.checksum-periodic stat emission.
 const RingChecksumStatPeriodNever = time.Duration(-1)
@@ -222,6 +522,928 @@ func RingChecksumStatPeriod(period time.Duration) Option {
 	}
 }
 
+// ProtocolCapture enables capturing of all gossip protocol messages sent and
+// received by this Ringpop instance. Captures can be fed into a node with
+// swim.Replay to reproduce membership issues offline. See
+// swim.NewCaptureBuffer and swim.NewCaptureWriter.
+func ProtocolCapture(c swim.Capturer) Option {
+	return func(r *Ringpop) error {
+		r.config.ProtocolCapture = c
+		return nil
+	}
+}
+
+// ForwardEndpoint registers an endpoint of a service as forwardable. Once at
+// least one endpoint is registered, HandleOrForward and Forward only forward
+// requests to registered endpoints and fail with a
+// forward.UnregisteredEndpointError for others. The options may be nil; see
+// forward.EndpointOptions for per-endpoint overrides and request validation.
+func ForwardEndpoint(service, endpoint string, opts *forward.EndpointOptions) Option {
+	return func(r *Ringpop) error {
+		r.config.ForwardEndpoints = append(r.config.ForwardEndpoints,
+			forwardEndpoint{service, endpoint, opts})
+		return nil
+	}
+}
+
+// ForwardErrorMapping registers mappings from the errors returned by handlers
+// wrapped with WrapHandler to the TChannel error codes they are reported to
+// callers with, so that callers can tell, for example, a bad request from an
+// unexpected failure. Mappings are tried in the order they are registered.
+func ForwardErrorMapping(mappings ...forward.ErrorMapping) Option {
+	return func(r *Ringpop) error {
+		for _, mapping := range mappings {
+			if mapping == nil {
+				return errors.New("forward error mapping is nil")
+			}
+		}
+		r.config.ForwardErrorMappings = append(r.config.ForwardErrorMappings, mappings...)
+		return nil
+	}
+}
+
+// ZoneAnnotation sets the annotation, "zone" by default, that members declare
+// their zone, such as an availability zone, with. See Annotate. Members are
+// grouped into zones by it in Zones and in the advice of Advise, and events
+// are emitted when all members of a zone become unreachable.
+func ZoneAnnotation(key string) Option {
+	return func(r *Ringpop) error {
+		if key == "" {
+			return errors.New("zone annotation must not be empty")
+		}
+		r.config.ZoneAnnotation = key
+		return nil
+	}
+}
+
+// RebalanceAdvisor configures the thresholds of the rebalance advisor, see
+// Advise. Fields of the options left zero keep their default values from
+// DefaultAdvisorOptions.
+func RebalanceAdvisor(opts AdvisorOptions) Option {
+	return func(r *Ringpop) error {
+		if opts.ImbalanceThreshold == 0 {
+			opts.ImbalanceThreshold = DefaultAdvisorOptions.ImbalanceThreshold
+		}
+		if opts.HighLoad == 0 {
+			opts.HighLoad = DefaultAdvisorOptions.HighLoad
+		}
+		if opts.LoadWindow == 0 {
+			opts.LoadWindow = DefaultAdvisorOptions.LoadWindow
+		}
+
+		if opts.ImbalanceThreshold <= 1 {
+			return fmt.Errorf("imbalance threshold must be greater than 1, got %v", opts.ImbalanceThreshold)
+		}
+		if opts.HighLoad < 0 || opts.HighLoad > 1 {
+			return fmt.Errorf("high load must be between 0 and 1, got %v", opts.HighLoad)
+		}
+		if opts.LoadWindow < 0 {
+			return errors.New("load window must not be negative")
+		}
+
+		r.config.Advisor = opts
+		return nil
+	}
+}
+
+// RampIn makes this Ringpop instance take its share of the keyspace gradually
+// over the given period after it bootstraps, instead of all at once, so cold
+// caches and empty stores are not hit with their full load immediately. The
+// instance gossips the share of its replica points it has in ten steps, and
+// all members place it on their rings with that share. A period of zero
+// disables ramping in.
+func RampIn(period time.Duration) Option {
+	return func(r *Ringpop) error {
+		if period < 0 {
+			return errors.New("ramp in period must not be negative")
+		}
+		r.config.RampPeriod = period
+		return nil
+	}
+}
+
+// Watchdog enables a watchdog that detects when the gossip protocol loop of
+// this Ringpop instance has not run for the given number of protocol periods,
+// for example because of starvation or a deadlock. A stall is logged and
+// counted in the "watchdog.stalled" stat, after which the callback is called
+// if it is not nil. The callback can, for example, panic or destroy the
+// instance so that a wedged instance is removed from the rings of its peers
+// instead of silently remaining a member.
+func Watchdog(periods int, callback swim.WatchdogFunc) Option {
+	return func(r *Ringpop) error {
+		if periods <= 0 {
+			return errors.New("watchdog periods must be positive")
+		}
+		r.config.WatchdogPeriods = periods
+		r.config.Watchdog = callback
+		return nil
+	}
+}
+
+// PingHook registers a hook that is called on each ping this Ringpop instance
+// receives, with the sender and a summary of the changes piggybacked on the
+// ping. When the hook returns an error, the ping is not acknowledged, so peers
+// treat the instance as unresponsive and eventually mark it suspect and
+// faulty. Applications can use this to take the instance out of the rings of
+// its peers while the process is in a bad state. Vetoed pings are counted in
+// the "ping.vetoed" stat.
+func PingHook(hook swim.PingHook) Option {
+	return func(r *Ringpop) error {
+		if hook == nil {
+			return errors.New("ping hook must not be nil")
+		}
+		r.config.PingHook = hook
+		return nil
+	}
+}
+
+// AdminAuth requires callers of the admin endpoints of this Ringpop instance to
+// be authenticated by the authenticator, such as a swim.TokenAuthenticator.
+// Read-only introspection endpoints, such as /admin/stats and /admin/lookup,
+// require the swim.AdminRead role, and endpoints that change the state of the
+// instance or the cluster, such as /admin/member/leave and /admin/partition,
+// require swim.AdminWrite. The token is sent in the swim.AdminTokenHeader when
+// this instance calls the admin endpoints of other members, for example to
+// collect cluster stats. Denied calls are counted in the "admin.denied" stat.
+// /health is not authenticated.
+func AdminAuth(authenticator swim.AdminAuthenticator, token string) Option {
+	return func(r *Ringpop) error {
+		if authenticator == nil {
+			return errors.New("admin authenticator must not be nil")
+		}
+		r.config.AdminAuthenticator = authenticator
+		r.config.AdminToken = token
+		return nil
+	}
+}
+
+// FaultyArbiter plugs in an external arbiter, such as a quorum service or the
+// API of an orchestrator, that is consulted before a suspect member matching
+// the filter is declared faulty. A nil filter matches all members. When the
+// arbiter vetoes, the member stays suspect, and so on the ring, and the arbiter
+// is consulted again after another suspect period. Use this for members that
+// must never be evicted on gossip evidence alone, such as stateful primaries.
+// Vetoes are counted in the "faulty.vetoed" stat.
+func FaultyArbiter(arbiter swim.Arbiter, filter swim.ArbiterFilter) Option {
+	return func(r *Ringpop) error {
+		if arbiter == nil {
+			return errors.New("arbiter must not be nil")
+		}
+		r.config.Arbiter = arbiter
+		r.config.ArbiterFilter = filter
+		return nil
+	}
+}
+
+// MaintenanceWindows configures how members in a maintenance window, declared
+// with SetMaintenance or the /admin/member/maintenance endpoint, are treated
+// by the failure detector: their suspect period is timeoutFactor times the
+// suspicion timeout, and they are only declared faulty after confirmations
+// additional suspect periods. This reduces false evictions during planned
+// events such as long garbage collections or compactions. The defaults are a
+// factor of 4 and 2 confirmations. Deferred faulty declarations are counted in
+// the "maintenance.deferred" stat.
+func MaintenanceWindows(timeoutFactor, confirmations int) Option {
+	return func(r *Ringpop) error {
+		if timeoutFactor < 1 {
+			return fmt.Errorf("maintenance timeout factor must be at least 1, got %d", timeoutFactor)
+		}
+		if confirmations < 1 {
+			return fmt.Errorf("maintenance confirmations must be at least 1, got %d", confirmations)
+		}
+		r.config.MaintenanceTimeoutFactor = timeoutFactor
+		r.config.MaintenanceConfirmations = confirmations
+		return nil
+	}
+}
+
+// FailureDetector replaces the failure detector of the SWIM protocol, the
+// direct and indirect pings of the member selected in each protocol period,
+// with an alternative such as a phi-accrual detector. Gossip, dissemination
+// and the suspicion protocol are unchanged; members the detector finds
+// unreachable are declared suspect. See swim.FailureDetector.
+func FailureDetector(detector swim.FailureDetector) Option {
+	return func(r *Ringpop) error {
+		if detector == nil {
+			return errors.New("failure detector must not be nil")
+		}
+		r.config.FailureDetector = detector
+		return nil
+	}
+}
+
+// GossipTargetSelector replaces the round-robin selection of the member the
+// SWIM node probes, and exchanges state with, in each protocol period. A
+// swim.WeightedTargetSelector favors the members the node has exchanged state
+// with least recently. See swim.TargetSelector.
+func GossipTargetSelector(selector swim.TargetSelector) Option {
+	return func(r *Ringpop) error {
+		if selector == nil {
+			return errors.New("gossip target selector must not be nil")
+		}
+		r.config.GossipTargetSelector = selector
+		return nil
+	}
+}
+
+// A Transporter carries both the messages of the membership protocol and the
+// requests forwarded to the owners of their keys, see swim.Transport and
+// forward.Transport.
+type Transporter interface {
+	swim.Transport
+	forward.Transport
+}
+
+// Transport replaces TChannel as the transport of the membership protocol and
+// of forwarded requests, e.g. to migrate to another RPC system or to run
+// members in memory in tests. Messages a member receives over the transport
+// are passed to the handler returned by Protocol, and requests to the
+// handlers of the forwarded endpoints. The forward/grpc package provides a
+// Transport over gRPC. The channel is still used for
+// the endpoints of Ringpop itself, such as the admin endpoints.
+func Transport(transport Transporter) Option {
+	return func(r *Ringpop) error {
+		if transport == nil {
+			return errors.New("transport must not be nil")
+		}
+		r.config.Transport = transport
+		return nil
+	}
+}
+
+// GossipEncryption encrypts and authenticates the messages of the membership
+// protocol, i.e. pings, ping requests, joins and syncs, with the shared keys
+// of the keyring, so that members can gossip across networks that are not
+// trusted. Messages from members that do not share a key are rejected and
+// counted in the "protocol.unauthenticated" stat. Keys are rotated through
+// the keyring at runtime, see swim.Keyring. Forwarded requests are not
+// sealed and need to be protected by the transport, e.g. with TLS.
+func GossipEncryption(keyring *swim.Keyring) Option {
+	return func(r *Ringpop) error {
+		if keyring == nil {
+			return errors.New("keyring must not be nil")
+		}
+		if keyring.PrimaryKey() == nil {
+			return errors.New("keyring must have a primary key")
+		}
+		r.config.Keyring = keyring
+		return nil
+	}
+}
+
+// RoutingOverrides makes this Ringpop instance read operator-managed routing
+// overrides from the JSON file at path, which pin keys and key prefixes to
+// members ahead of the ring, for steering traffic during incidents. See type
+// RoutingPins for the format. The file is optional and checked for
+// changes every interval, 5 seconds if zero; removing it clears the
+// overrides, and a file that cannot be parsed leaves the previous overrides in
+// effect. Pins to members that are not in the ring are ignored. Overridden
+// lookups are logged and counted in the "lookup.overridden" stat, and the
+// overrides in effect are returned by the /admin/overrides endpoint.
+func RoutingOverrides(path string, interval time.Duration) Option {
+	return func(r *Ringpop) error {
+		if path == "" {
+			return errors.New("routing overrides path must not be empty")
+		}
+		if interval < 0 {
+			return errors.New("routing overrides interval must not be negative")
+		}
+		if interval == 0 {
+			interval = defaultOverridesInterval
+		}
+		r.config.OverridesPath = path
+		r.config.OverridesInterval = interval
+		return nil
+	}
+}
+
+// SuspicionConfirmations enables the suspicion timeout reduction of the
+// Lifeguard extensions to SWIM: suspect periods start at maxTimeoutFactor
+// times the suspicion timeout, and shrink to the suspicion timeout as the
+// given number of other members independently confirm the suspicion. Members
+// that are really down are still declared faulty quickly, while a member that
+// a single slow member suspects gets more time to refute. Lifeguard suggests
+// 3 confirmations and a factor of 6. Shortened suspect periods are counted in
+// the "suspicion.confirmed" stat.
+func SuspicionConfirmations(confirmations, maxTimeoutFactor int) Option {
+	return func(r *Ringpop) error {
+		if confirmations < 1 {
+			return fmt.Errorf("suspicion confirmations must be at least 1, got %d", confirmations)
+		}
+		if maxTimeoutFactor < 1 {
+			return fmt.Errorf("suspicion max timeout factor must be at least 1, got %d", maxTimeoutFactor)
+		}
+		r.config.SuspicionConfirmations = confirmations
+		r.config.SuspicionMaxTimeoutFactor = maxTimeoutFactor
+		return nil
+	}
+}
+
+// DuplicateAddresses makes this Ringpop instance detect members whose address
+// is claimed by two processes, such as an old and a new pod that overlap,
+// instead of letting the ring flap between them every gossip round. The
+// processes are told apart by the join times they gossip. When a member
+// switches between join times threshold times within window, every node keeps
+// the newest process and ignores the older ones until they stopped claiming
+// the address for cooldown. A process that finds another one claiming its own
+// address reports it. Both are logged as errors, recorded in the timeline and
+// counted in the "duplicate-address" and "duplicate-identity" stats.
+func DuplicateAddresses(threshold int, window, cooldown time.Duration) Option {
+	return func(r *Ringpop) error {
+		if threshold < 1 {
+			return fmt.Errorf("duplicate address threshold must be at least 1, got %d", threshold)
+		}
+		if window <= 0 || cooldown <= 0 {
+			return errors.New("duplicate address window and cooldown must be positive")
+		}
+		r.config.DuplicateThreshold = threshold
+		r.config.DuplicateWindow = window
+		r.config.DuplicateCooldown = cooldown
+		return nil
+	}
+}
+
+// ChecksumRemediation makes this Ringpop instance remediate persistent
+// mismatches of its membership checksum with a peer, which full syncs alone
+// did not resolve, by climbing the ladder of steps of the policy: sending the
+// peer a full sync right away, merging memberships with it, raising an alert
+// and quarantining it. See swim.DefaultRemediationPolicy for a starting
+// point. Every step is logged, recorded in the timeline and counted in the
+// "remediation.<action>" stats, and peers that converge after steps were
+// taken are counted in the "remediation.converged" stat.
+func ChecksumRemediation(policy swim.RemediationPolicy) Option {
+	return func(r *Ringpop) error {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+		r.config.Remediation = policy
+		return nil
+	}
+}
+
+// FaultyMemberReaping makes this Ringpop instance remove members that have
+// been faulty for longer than faultyTTL from its membership, so that they do
+// not bloat the gossip and the checksum forever. Such members are first
+// turned into tombstones, which are disseminated to all members, and removed
+// after tombstoneTTL, which must leave enough time for the tombstones to
+// reach all members. Members are only turned into tombstones while all
+// reachable members support them. Members turned into tombstones and removed
+// are counted in the "reaper.tombstoned" and "reaper.reaped" stats.
+func FaultyMemberReaping(faultyTTL, tombstoneTTL time.Duration) Option {
+	return func(r *Ringpop) error {
+		if faultyTTL <= 0 || tombstoneTTL <= 0 {
+			return errors.New("faulty and tombstone TTLs must be positive")
+		}
+		r.config.FaultyTTL = faultyTTL
+		r.config.TombstoneTTL = tombstoneTTL
+		return nil
+	}
+}
+
+// RingMigration migrates this Ringpop instance to a new hash ring
+// configuration, such as a different hash function or number of replica
+// points, without restarting the cluster. Until the cutover, lookups use the
+// ring configured with HashRingConfig, while the new ring is computed
+// side-by-side and compared on live traffic like a shadow ring, see
+// ShadowRing. Ringpop.CutoverRing on any member starts the cutover: the member
+// gossips the epoch with the RingEpochAnnotation annotation, and every member
+// that migrates to the epoch switches its ring over when it learns about it.
+// The epoch must be greater than the epochs of previous migrations of the
+// cluster. Members that join after the cutover must be configured with the new
+// configuration. Cutovers are counted in the "ring.cutover" stat.
+func RingMigration(config *hashring.Configuration, epoch int) Option {
+	return func(r *Ringpop) error {
+		if config == nil {
+			return errors.New("ring migration requires a hash ring configuration")
+		}
+		if epoch <= 0 {
+			return errors.New("ring migration epoch must be positive")
+		}
+		r.config.RingMigration = config
+		r.config.RingMigrationEpoch = epoch
+		return nil
+	}
+}
+
+// RingProofs attaches the ring checksum and version of this Ringpop instance
+// as response headers to the replies of its admin endpoints and the SWIM admin
+// endpoints, and to the responses of handlers wrapped with
+// Ringpop.WrapHandler, so that callers can detect they were served by a
+// member with a divergent ring and ask another member instead. Other handlers
+// of forwarded requests attach the proof with Ringpop.SetRingProofHeaders. Forwarded requests whose response carries
+// a proof that differs from the local ring are counted in the
+// "requestProxy.ring.diverged" stat, and retried with the forward.Options
+// VerifyRing option.
+func RingProofs() Option {
+	return func(r *Ringpop) error {
+		r.config.RingProofs = true
+		return nil
+	}
+}
+
+// WeightLabel sets the label members declare their weight with, such as
+// "weight": "2", so that heterogeneous hosts own a share of the keyspace
+// proportional to their capacity. A member with weight w is placed on the
+// ring with w times the replica points; weights must be positive and at most
+// 100, other values are ignored. The points are recomputed when a member
+// changes the label, see SetLabel, and an events.MemberWeightChangedEvent is
+// emitted. Weight changes are counted in the "member.weight-changed" stat.
+func WeightLabel(key string) Option {
+	return func(r *Ringpop) error {
+		if key == "" {
+			return errors.New("weight label must not be empty")
+		}
+		r.config.WeightLabel = key
+		return nil
+	}
+}
+
+// RingIdentity declares a stable logical identity of this Ringpop instance,
+// such as "node-17", that is distinct from its address. The identity is
+// gossiped to all members as the swim.IdentityAnnotation annotation, and the
+// instance is placed on the ring by its identity instead of its address, so
+// that it keeps owning the same keys when it comes back with a different
+// address, e.g. after its pod was rescheduled. Identities must be unique in
+// the cluster. Lookups still return the address of the owner.
+func RingIdentity(identity string) Option {
+	return func(r *Ringpop) error {
+		if identity == "" {
+			return errors.New("ring identity must not be empty")
+		}
+		if len(identity) > swim.MaxAnnotationSize {
+			return fmt.Errorf("ring identity exceeds %d bytes", swim.MaxAnnotationSize)
+		}
+		r.config.RingIdentity = identity
+		return nil
+	}
+}
+
+// JoinStormProtection protects the cluster against storms of joins, e.g. when
+// all members start at once on a cold start. This Ringpop instance delays its
+// first join requests by a hash of its identity, between 0 and staggerWindow,
+// so that the joins of instances that start together are spread over the
+// window, and admits at most admissionLimit joins per second, a limit that
+// doubles every second in which joins were throttled. Throttled joiners retry
+// with their usual backoff. Either protection is disabled by passing zero.
+// Detected storms and throttled joins are counted in the "join.storm" and
+// "join.throttled" stats, and join delays timed in the "join.staggered" stat.
+func JoinStormProtection(staggerWindow time.Duration, admissionLimit int) Option {
+	return func(r *Ringpop) error {
+		if staggerWindow < 0 || admissionLimit < 0 {
+			return errors.New("join stagger window and admission limit must not be negative")
+		}
+		r.config.JoinStaggerWindow = staggerWindow
+		r.config.JoinAdmissionLimit = admissionLimit
+		return nil
+	}
+}
+
+// SelfEviction sets the fraction of the pingable members, between 0 and 1,
+// that SelfEvict sends the leave of this instance to right away, instead of
+// letting it spread by gossip only. Notifying more members shortens the time
+// other members keep routing to the instance while it shuts down, e.g. during
+// rolling restarts, at the cost of more pings. The fraction defaults to 0.4.
+// Evictions are counted in the "self-evicted" stat and timed in the
+// "self-evict" stat.
+func SelfEviction(pingRatio float64) Option {
+	return func(r *Ringpop) error {
+		if pingRatio <= 0 || pingRatio > 1 {
+			return errors.New("self-evict ping ratio must be between 0 and 1")
+		}
+		r.config.SelfEvictPingRatio = pingRatio
+		return nil
+	}
+}
+
+// LoadSpillover makes LookupN return the secondary owner of a key before its
+// primary owner when the primary owner gossiped a load above threshold, see
+// SetPushback, for a fraction of the keys, between 0 and 1. The keys that are
+// spilled are chosen by their hash, so a key is either always spilled or never
+// while the owner is overloaded, and all members spill the same keys. Loads
+// are used for the load window of the rebalance advisor. Spillover only
+// applies to lookups in RingOrder. Lookups of keys whose owner is overloaded
+// are counted in the "lookupN.overloaded" stat and spilled keys in the
+// "lookupN.spilled" stat, whose ratio is the spillover rate.
+func LoadSpillover(threshold, fraction float64) Option {
+	return func(r *Ringpop) error {
+		if threshold <= 0 {
+			return errors.New("spillover load threshold must be positive")
+		}
+		if fraction <= 0 || fraction > 1 {
+			return errors.New("spillover fraction must be between 0 and 1")
+		}
+		r.config.SpillThreshold = threshold
+		r.config.SpillFraction = fraction
+		return nil
+	}
+}
+
+// ShadowRing computes a ring with a candidate configuration, such as a
+// different hash function or number of replica points, side-by-side with the
+// active ring, and compares the owners both rings assign to the keys of a
+// sample of the lookups. Lookups keep using the active ring. Compared lookups
+// are counted in the "shadow.lookup.compared" stat and those whose owners
+// differ in the "shadow.lookup.diverged" stat, which allows validating a ring
+// configuration change on live traffic before cutting over to it. The shadow
+// ring does not take the ramp of members into account. See also
+// Ringpop.ShadowStats.
+func ShadowRing(config ShadowRingConfiguration) Option {
+	return func(r *Ringpop) error {
+		if config.ReplicaPoints < 0 {
+			return errors.New("shadow ring replica points must not be negative")
+		}
+		if config.SampleRate < 0 || config.SampleRate > 1 {
+			return errors.New("shadow ring sample rate must be between 0 and 1")
+		}
+		r.config.ShadowRing = &config
+		return nil
+	}
+}
+
+// PartitionHealing makes this Ringpop instance detect and heal partitions of
+// the cluster into live sub-clusters that consider each other's members
+// faulty. Every period, it probes a random faulty member or bootstrap host
+// that is not a reachable member with a join request, and when the membership
+// of the probed node disagrees about which members are reachable, it merges
+// the partitions. Heals are attempted at most once per rateLimit. Detected
+// and healed partitions are counted in the "partition.detected" and
+// "partition.healed" stats.
+func PartitionHealing(period, rateLimit time.Duration) Option {
+	return func(r *Ringpop) error {
+		if period <= 0 || rateLimit <= 0 {
+			return errors.New("partition heal period and rate limit must be positive")
+		}
+		r.config.PartitionHealPeriod = period
+		r.config.PartitionHealRateLimit = rateLimit
+		return nil
+	}
+}
+
+// LabelPersistence persists the labels of the local member with the given
+// keys, or all labels if no keys are given, to the store whenever they change,
+// and restores them before this Ringpop instance bootstraps, so that it
+// rejoins the cluster with the labels it had before a restart, such as an
+// assigned token set or a drain state. Bootstrap fails if the persisted
+// labels cannot be restored. Use swim.NewFileLabelStore to persist them to a
+// file. Restored labels are counted in the "labels.restored" stat.
+func LabelPersistence(store swim.LabelStore, keys ...string) Option {
+	return func(r *Ringpop) error {
+		if store == nil {
+			return errors.New("label store must not be nil")
+		}
+		r.config.LabelStore = store
+		r.config.PersistedLabels = keys
+		return nil
+	}
+}
+
+// AntiEntropy makes this Ringpop instance sync its membership with a random
+// member every interval, plus a random jitter of up to jitter, so that
+// memberships that diverged during a long partition or because gossip was
+// dropped converge. The nodes compare their membership checksums and, when
+// they mismatch, exchange their full memberships in both directions and
+// merge them. Syncs are counted in the "anti-entropy.sync" stat, those that
+// merged divergent memberships in the "anti-entropy.mismatch" stat and failed
+// syncs in the "anti-entropy.failed" stat.
+func AntiEntropy(interval, jitter time.Duration) Option {
+	return func(r *Ringpop) error {
+		if interval <= 0 || jitter < 0 {
+			return errors.New("anti-entropy interval must be positive and jitter not negative")
+		}
+		r.config.SyncInterval = interval
+		r.config.SyncJitter = jitter
+		return nil
+	}
+}
+
+// ServiceMesh routes the gossip and the forwarded requests of this Ringpop
+// instance through a service mesh sidecar, for deployments where all traffic
+// must traverse the mesh. Members are dialed at the sidecar, which routes
+// calls by the mesh identity of the member; see shared.Mesh. When ready is not
+// nil, such as a SidecarReadiness check, it is polled every interval, or every
+// second when the interval is zero, and the local member is marked degraded
+// while the sidecar is not ready. The readiness is reported in the
+// "mesh.ready" stat.
+func ServiceMesh(mesh shared.Mesh, ready ReadinessCheck, interval time.Duration) Option {
+	return func(r *Ringpop) error {
+		if mesh.Sidecar == "" {
+			return errors.New("service mesh sidecar must not be empty")
+		}
+		if interval < 0 {
+			return errors.New("service mesh readiness interval must not be negative")
+		}
+		if interval == 0 {
+			interval = defaultReadinessInterval
+		}
+		r.config.Mesh = &mesh
+		r.config.MeshReadiness = ready
+		r.config.MeshReadinessInterval = interval
+		return nil
+	}
+}
+
+// SuspicionTimeout makes the suspicion timeout of this Ringpop instance a
+// function of the number of reachable members instead of a flat duration, so
+// that a timeout tuned for small deployments does not make large clusters
+// flap. See swim.LogScaledSuspicionTimeout for a timeout that grows with the
+// logarithm of the cluster size.
+func SuspicionTimeout(timeout swim.SuspicionTimeoutFunc) Option {
+	return func(r *Ringpop) error {
+		if timeout == nil {
+			return errors.New("suspicion timeout func must not be nil")
+		}
+		r.config.SuspicionTimeout = timeout
+		return nil
+	}
+}
+
+// LocalHealth enables the local health multiplier of the Lifeguard extensions
+// to SWIM. An instance that is slow itself, for example because of garbage
+// collection pauses or CPU starvation, notices through failed probes, refuted
+// suspicions of itself and delayed protocol periods, and multiplies its ping
+// and suspicion timeouts by up to max+1 until it recovers, instead of
+// declaring healthy members faulty. The score is reported in the
+// "local-health" stat. Lifeguard suggests a max of 8.
+func LocalHealth(max int) Option {
+	return func(r *Ringpop) error {
+		if max < 1 {
+			return fmt.Errorf("local health max must be at least 1, got %d", max)
+		}
+		r.config.LocalHealthMax = max
+		return nil
+	}
+}
+
+// AdvertiseAddresses makes this Ringpop instance gossip additional addresses
+// it is reachable at, such as its external IP or hostname, in order of
+// preference. The identity of the instance is unchanged; the addresses allow
+// peers on networks that cannot reach the identity to dial the instance, for
+// clusters that span peered networks with asymmetric reachability. Peers pick
+// an address with their AddressSelector.
+func AdvertiseAddresses(addresses ...string) Option {
+	return func(r *Ringpop) error {
+		for _, address := range addresses {
+			if _, _, err := net.SplitHostPort(address); err != nil {
+				return fmt.Errorf("invalid advertised address %q: %v", address, err)
+			}
+		}
+		r.config.AdvertiseAddresses = addresses
+		return nil
+	}
+}
+
+// AddressSelector sets the policy this Ringpop instance uses to select the
+// address to dial members that advertise additional addresses at, for both
+// gossip and forwarded requests. See swim.PreferNetworks for a selector that
+// prefers addresses in specific networks. Without a selector, members are
+// dialed at their identity.
+func AddressSelector(selector swim.AddressSelector) Option {
+	return func(r *Ringpop) error {
+		r.config.AddressSelector = selector
+		return nil
+	}
+}
+
+// Features makes this Ringpop instance advertise application defined feature
+// flags, created with swim.ApplicationFeature, to its peers in addition to the
+// features of the gossip protocol. The features both sides of a pair of peers
+// support can be queried with PeerFeatures, so that new capabilities can be
+// enabled per peer in clusters running mixed versions.
+func Features(features swim.Features) Option {
+	return func(r *Ringpop) error {
+		r.config.Features = features
+		return nil
+	}
+}
+
+// FalsePositiveThreshold raises an alert when the fraction of suspicions of
+// members that turned out to be false, because the suspected member refuted
+// them, exceeds the given rate. The alert is logged, emitted as a
+// swim.FalsePositiveRateExceededEvent and counted in the
+// "detector.false-positive-rate.exceeded" stat, and indicates that the
+// suspicion and ping timeouts should be tuned. The rate must be in (0, 1].
+func FalsePositiveThreshold(rate float64) Option {
+	return func(r *Ringpop) error {
+		if rate <= 0 || rate > 1 {
+			return fmt.Errorf("false positive threshold must be in (0, 1], got %v", rate)
+		}
+		r.config.FalsePositiveThreshold = rate
+		return nil
+	}
+}
+
+// ClockSkewThreshold raises a warning when the clock of a member is estimated
+// to be more than the given duration ahead of or behind the local clock. The
+// clock offset of every member is estimated from the time it reports in ping
+// responses, see ClockSkew. The warning is logged, emitted as a
+// swim.ClockSkewExceededEvent and counted in the "clock-skew.exceeded" stat.
+func ClockSkewThreshold(threshold time.Duration) Option {
+	return func(r *Ringpop) error {
+		if threshold <= 0 {
+			return errors.New("clock skew threshold must be positive")
+		}
+		r.config.ClockSkewThreshold = threshold
+		return nil
+	}
+}
+
+// MembershipChecksumVersion selects the version of the membership checksum this
+// Ringpop instance computes when it bootstraps a cluster. swim.ChecksumV2 also
+// covers the health, ramp and advertised addresses of members, so that changes
+// in how keys are routed to a member are detected as divergence and repaired.
+// swim.ChecksumV3 additionally covers the labels of members. An instance that
+// joins an existing cluster computes the version of the cluster, and gossips
+// the checksum of the selected version along with it. Two instances that both
+// compute the selected version compare it, and otherwise the version of the
+// cluster, so the version can be rolled out to a running cluster one
+// instance at a time.
+func MembershipChecksumVersion(version swim.ChecksumVersion) Option {
+	return func(r *Ringpop) error {
+		if !version.Valid() {
+			return fmt.Errorf("unknown membership checksum version %d", version)
+		}
+		r.config.ChecksumVersion = version
+		return nil
+	}
+}
+
+// DisseminationBacklog bounds the number of membership changes this Ringpop
+// instance disseminates at a time to max. During extreme churn, the oldest
+// alive changes beyond it are spilled to the store instead of being
+// piggybacked on every protocol message, and they are disseminated again once
+// the backlog shrinks. A nil store keeps spilled changes in memory; use
+// swim.NewFileBacklogStore to spill them to disk.
+func DisseminationBacklog(max int, store swim.BacklogStore) Option {
+	return func(r *Ringpop) error {
+		if max <= 0 {
+			return errors.New("dissemination backlog must be positive")
+		}
+		r.config.MaxBacklog = max
+		r.config.BacklogStore = store
+		return nil
+	}
+}
+
+// FullSyncBudget bounds the size in bytes of the membership this Ringpop
+// instance sends in one protocol round when a member with a different
+// membership checksum needs a full sync. Larger memberships are sent in
+// chunks over multiple rounds, each resuming where the previous one ended, so
+// syncing thousands of members does not cause a latency spike on either side.
+// Chunks are counted in the "full-sync.chunk" stat. By default the full
+// membership is sent at once.
+func FullSyncBudget(bytes int) Option {
+	return func(r *Ringpop) error {
+		if bytes <= 0 {
+			return errors.New("full sync budget must be positive")
+		}
+		r.config.FullSyncBudget = bytes
+		return nil
+	}
+}
+
+// UnavailableOwner sets what Lookup returns when the owner of a key is
+// suspected to have failed but is still on the ring: the owner regardless
+// (ReturnUnavailable, the default), the member that takes over the key once
+// the owner is removed (SkipUnavailable), or an OwnerUnavailableError
+// (RejectUnavailable). The policy can be overridden per call with
+// LookupWithPolicy.
+func UnavailableOwner(policy UnavailablePolicy) Option {
+	return func(r *Ringpop) error {
+		switch policy {
+		case ReturnUnavailable, SkipUnavailable, RejectUnavailable:
+		default:
+			return fmt.Errorf("invalid unavailable owner policy %d", policy)
+		}
+		r.config.UnavailableOwner = policy
+		return nil
+	}
+}
+
+// LookupNOrder sets the order of the members LookupN returns: the order in
+// which they own the key on the ring (RingOrder, the default), or fastest
+// healthy member first (LatencyOrder), so that callers trying replicas in order
+// try the fastest healthy replica first. The set of members is the same in
+// both orders. The order can be overridden per call with LookupNWithOrder.
+func LookupNOrder(order ReplicaOrder) Option {
+	return func(r *Ringpop) error {
+		switch order {
+		case RingOrder, LatencyOrder:
+		default:
+			return fmt.Errorf("invalid replica order %d", order)
+		}
+		r.config.LookupNOrder = order
+		return nil
+	}
+}
+
+// ForwardConcurrencyLimit bounds the number of requests that are forwarded
+// concurrently to a single member, so a slow member cannot tie up all
+// forwarding capacity. Requests beyond maxInflight wait for up to
+// queueTimeout, or the request timeout when it is zero, with at most
+// maxQueued waiting per member; the others fail with a
+// forward.ConcurrencyLimitError. A maxInflight of zero disables the limit.
+func ForwardConcurrencyLimit(maxInflight, maxQueued int, queueTimeout time.Duration) Option {
+	return func(r *Ringpop) error {
+		if maxInflight < 0 || maxQueued < 0 {
+			return errors.New("forward concurrency limits must not be negative")
+		}
+		if queueTimeout < 0 {
+			return errors.New("forward queue timeout must not be negative")
+		}
+		r.config.ForwardLimit = forward.DestinationLimit{
+			MaxInflight:  maxInflight,
+			MaxQueued:    maxQueued,
+			QueueTimeout: queueTimeout,
+		}
+		return nil
+	}
+}
+
+// JoinWhenReady makes Bootstrap wait in standby, without joining the cluster,
+// until the readiness check passes, e.g. once the local storage of a stateful
+// service has recovered. The check is polled every interval, or every second
+// when the interval is zero. While in standby, Standby returns true and the
+// methods that require a bootstrapped ring fail with ErrStandby.
+func JoinWhenReady(check ReadinessCheck, interval time.Duration) Option {
+	return func(r *Ringpop) error {
+		if check == nil {
+			return errors.New("readiness check must not be nil")
+		}
+		if interval < 0 {
+			return errors.New("readiness interval must not be negative")
+		}
+		if interval == 0 {
+			interval = defaultReadinessInterval
+		}
+		r.config.ReadinessCheck = check
+		r.config.ReadinessInterval = interval
+		return nil
+	}
+}
+
+// ListenerPanicLimit deregisters an event listener once it panicked the given
+// number of times, so that a buggy listener does not flood the logs. Panics of
+// listeners are always recovered; without a limit, or with a limit of zero,
+// listeners stay registered.
+func ListenerPanicLimit(panics int) Option {
+	return func(r *Ringpop) error {
+		if panics < 0 {
+			return errors.New("listener panic limit must not be negative")
+		}
+		r.config.ListenerPanicLimit = panics
+		return nil
+	}
+}
+
+// EventTimeline sets the number of significant events, such as state changes,
+// bootstrap, membership and ring changes, joins and partitions, this Ringpop
+// instance keeps in its event timeline. The timeline answers what happened on
+// the instance recently without access to its logs, through Timeline and the
+// /admin/timeline endpoint. It defaults to DefaultTimelineSize entries; a size
+// of zero disables the timeline.
+func EventTimeline(size int) Option {
+	return func(r *Ringpop) error {
+		if size < 0 {
+			return errors.New("event timeline size must not be negative")
+		}
+		r.config.TimelineSize = size
+		return nil
+	}
+}
+
+// DefaultConvergenceWindow is the default window of the ConvergenceWindow
+// option.
+const DefaultConvergenceWindow = 2 * time.Second
+
+// ConvergenceWindow configures how long the membership checksum of this
+// Ringpop instance must stay the same, with no membership changes left to
+// gossip, before WaitForConvergence considers the membership converged. The
+// window should span several gossip protocol periods; it defaults to
+// DefaultConvergenceWindow.
+func ConvergenceWindow(window time.Duration) Option {
+	return func(r *Ringpop) error {
+		if window <= 0 {
+			return errors.New("convergence window must be positive")
+		}
+		r.config.ConvergenceWindow = window
+		return nil
+	}
+}
+
+// ProtocolDebugSampling logs the full gossip protocol messages for the given
+// fraction of protocol periods, between 0 and 1, and only those exchanged with
+// peer when it is not empty. The sampling can be changed at runtime through
+// the /admin/debugSet and /admin/debugClear endpoints.
+func ProtocolDebugSampling(rate float64, peer string) Option {
+	return func(r *Ringpop) error {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("debug sampling rate must be between 0 and 1, got %v", rate)
+		}
+		r.config.DebugSampling = swim.DebugSampling{Rate: rate, Peer: peer}
+		return nil
+	}
+}
+
 // Default options
 
 // defaultClock sets the ringpop clock interface to use the system clock
@@ -256,6 +1478,22 @@ func defaultHashRingOptions(r *Ringpop) error {
 	return HashRingConfig(defaultHashRingConfiguration)(r)
 }
 
+func defaultZoneAnnotation(r *Ringpop) error {
+	return ZoneAnnotation(DefaultZoneAnnotation)(r)
+}
+
+func defaultRebalanceAdvisor(r *Ringpop) error {
+	return RebalanceAdvisor(DefaultAdvisorOptions)(r)
+}
+
+func defaultEventTimeline(r *Ringpop) error {
+	return EventTimeline(DefaultTimelineSize)(r)
+}
+
+func defaultConvergenceWindow(r *Ringpop) error {
+	return ConvergenceWindow(DefaultConvergenceWindow)(r)
+}
+
 func defaultRingChecksumStatPeriod(r *Ringpop) error {
 	return RingChecksumStatPeriod(RingChecksumStatPeriodDefault)(r)
 }
@@ -269,6 +1507,10 @@ var defaultOptions = []Option{
 	defaultStatter,
 	defaultRingChecksumStatPeriod,
 	defaultHashRingOptions,
+	defaultEventTimeline,
+	defaultRebalanceAdvisor,
+	defaultZoneAnnotation,
+	defaultConvergenceWindow,
 }
 
 var defaultHashRingConfiguration = &hashring.Configuration{
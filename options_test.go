@@ -21,15 +21,21 @@
 package ringpop
 
 import (
+	"errors"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"github.com/gl-works/ringpop-go/forward"
 	"github.com/gl-works/ringpop-go/hashring"
+	"github.com/gl-works/ringpop-go/swim"
 	"github.com/gl-works/ringpop-go/logging"
+	"github.com/gl-works/ringpop-go/shared"
 	"github.com/gl-works/ringpop-go/test/mocks"
 	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
 )
 
 type RingpopOptionsTestSuite struct {
@@ -180,6 +186,46 @@ func (s *RingpopOptionsTestSuite) TestMissingIdentityResolver() {
 	s.Error(err)
 }
 
+// TestBindAddress tests that the identity is derived from the advertised
+// address while the channel listens on the bind address.
+func (s *RingpopOptionsTestSuite) TestBindAddress() {
+	rp, err := New("test", Channel(s.channel), BindAddress("0.0.0.0:0", "10.0.0.1:0"))
+	s.Require().NoError(err)
+
+	_, err = rp.identity()
+	s.Equal(ErrEphemeralIdentity, err, "expected error before the channel listens")
+
+	s.Require().NoError(s.channel.ListenAndServe("0.0.0.0:0"))
+	_, port, err := net.SplitHostPort(s.channel.PeerInfo().HostPort)
+	s.Require().NoError(err)
+
+	identity, err := rp.identity()
+	s.NoError(err)
+	s.Equal("10.0.0.1:"+port, identity, "expected advertised host with the bound port")
+
+	rp, err = New("test", Channel(s.channel), BindAddress("0.0.0.0:1", "10.0.0.1:3000"))
+	s.Require().NoError(err)
+	_, err = rp.identity()
+	s.Error(err, "expected error when the channel does not listen on the bind port")
+}
+
+// TestInvalidBindAddress tests that unusable bind and advertised addresses
+// are rejected.
+func (s *RingpopOptionsTestSuite) TestInvalidBindAddress() {
+	for _, addresses := range [][2]string{
+		{"0.0.0.0", "10.0.0.1:3000"},
+		{"0.0.0.0:3000", "10.0.0.1"},
+		{"0.0.0.0:3000", "10.0.0.1:http"},
+		{"0.0.0.0:3000", "10.0.0.1:70000"},
+		{"0.0.0.0:3000", "0.0.0.0:3000"},
+		{"0.0.0.0:3000", ":3000"},
+	} {
+		rp, err := New("test", Channel(s.channel), BindAddress(addresses[0], addresses[1]))
+		s.Nil(rp)
+		s.Error(err, "expected error for %v", addresses)
+	}
+}
+
 // TestClockNil confirms that nil clock option returns an error.
 func (s *RingpopOptionsTestSuite) TestClockNil() {
 	rp, err := New("test", Clock(nil))
@@ -218,6 +264,582 @@ func (s *RingpopOptionsTestSuite) TestSpecifiedRingChecksumStatPeriod() {
 	s.Equal(rp.config.RingChecksumStatPeriod, time.Duration(42*time.Second))
 }
 
+func (s *RingpopOptionsTestSuite) TestConvergenceWindow() {
+	rp, err := New("test", Channel(s.channel))
+	s.NoError(err)
+	s.Equal(DefaultConvergenceWindow, rp.config.ConvergenceWindow)
+
+	rp, err = New("test", Channel(s.channel), ConvergenceWindow(5*time.Second))
+	s.NoError(err)
+	s.Equal(5*time.Second, rp.config.ConvergenceWindow)
+
+	rp, err = New("test", Channel(s.channel), ConvergenceWindow(0))
+	s.EqualError(err, "convergence window must be positive")
+	s.Nil(rp)
+}
+
+func (s *RingpopOptionsTestSuite) TestProtocolCapture() {
+	capture := swim.NewCaptureBuffer(10)
+	rp, err := New("test", Channel(s.channel), ProtocolCapture(capture))
+	s.NoError(err)
+	s.Equal(capture, rp.config.ProtocolCapture)
+}
+
+func (s *RingpopOptionsTestSuite) TestForwardEndpoint() {
+	opts := &forward.EndpointOptions{MaxRetries: 1}
+	rp, err := New("test", Channel(s.channel),
+		ForwardEndpoint("service", "/a", opts),
+		ForwardEndpoint("service", "/b", nil))
+	s.NoError(err)
+	s.Equal([]forwardEndpoint{
+		{"service", "/a", opts},
+		{"service", "/b", nil},
+	}, rp.config.ForwardEndpoints)
+}
+
+func (s *RingpopOptionsTestSuite) TestRampIn() {
+	rp, err := New("test", Channel(s.channel), RampIn(time.Minute))
+	s.NoError(err)
+	s.Equal(time.Minute, rp.config.RampPeriod)
+
+	rp, err = New("test", Channel(s.channel), RampIn(-time.Minute))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestWatchdog() {
+	rp, err := New("test", Channel(s.channel), Watchdog(5, nil))
+	s.NoError(err)
+	s.Equal(5, rp.config.WatchdogPeriods)
+
+	rp, err = New("test", Channel(s.channel), Watchdog(0, nil))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestAdvertiseAddresses() {
+	rp, err := New("test", Channel(s.channel), AdvertiseAddresses("10.0.0.1:3000", "host.example.com:3000"))
+	s.NoError(err)
+	s.Equal([]string{"10.0.0.1:3000", "host.example.com:3000"}, rp.config.AdvertiseAddresses)
+
+	rp, err = New("test", Channel(s.channel), AdvertiseAddresses("10.0.0.1"))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestFeatures() {
+	rp, err := New("test", Channel(s.channel), Features(swim.ApplicationFeature(1)))
+	s.NoError(err)
+	s.Equal(swim.ApplicationFeature(1), rp.config.Features)
+}
+
+func (s *RingpopOptionsTestSuite) TestFalsePositiveThreshold() {
+	rp, err := New("test", Channel(s.channel), FalsePositiveThreshold(0.2))
+	s.NoError(err)
+	s.Equal(0.2, rp.config.FalsePositiveThreshold)
+
+	rp, err = New("test", Channel(s.channel), FalsePositiveThreshold(1.5))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestDisseminationBacklog() {
+	store := swim.NewMemoryBacklogStore()
+	rp, err := New("test", Channel(s.channel), DisseminationBacklog(100, store))
+	s.NoError(err)
+	s.Equal(100, rp.config.MaxBacklog)
+	s.Equal(store, rp.config.BacklogStore)
+
+	rp, err = New("test", Channel(s.channel), DisseminationBacklog(0, nil))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestUnavailableOwner() {
+	rp, err := New("test", Channel(s.channel), UnavailableOwner(SkipUnavailable))
+	s.NoError(err)
+	s.Equal(SkipUnavailable, rp.config.UnavailableOwner)
+
+	rp, err = New("test", Channel(s.channel), UnavailableOwner(UnavailablePolicy(42)))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestAdminAuth() {
+	auth := swim.TokenAuthenticator{"token": swim.AdminWrite}
+	rp, err := New("test", Channel(s.channel), AdminAuth(auth, "token"))
+	s.NoError(err)
+	s.Equal(auth, rp.config.AdminAuthenticator)
+	s.Equal("token", rp.config.AdminToken)
+
+	rp, err = New("test", Channel(s.channel), AdminAuth(nil, ""))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestLookupNOrder() {
+	rp, err := New("test", Channel(s.channel), LookupNOrder(LatencyOrder))
+	s.NoError(err)
+	s.Equal(LatencyOrder, rp.config.LookupNOrder)
+
+	rp, err = New("test", Channel(s.channel), LookupNOrder(ReplicaOrder(42)))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestForwardConcurrencyLimit() {
+	rp, err := New("test", Channel(s.channel), ForwardConcurrencyLimit(4, 8, time.Second))
+	s.NoError(err)
+	s.Equal(forward.DestinationLimit{
+		MaxInflight:  4,
+		MaxQueued:    8,
+		QueueTimeout: time.Second,
+	}, rp.config.ForwardLimit)
+
+	rp, err = New("test", Channel(s.channel), ForwardConcurrencyLimit(-1, 0, 0))
+	s.Nil(rp)
+	s.Error(err)
+
+	rp, err = New("test", Channel(s.channel), ForwardConcurrencyLimit(1, 0, -time.Second))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestJoinWhenReady() {
+	rp, err := New("test", Channel(s.channel), JoinWhenReady(func() bool { return true }, 0))
+	s.NoError(err)
+	s.NotNil(rp.config.ReadinessCheck)
+	s.Equal(defaultReadinessInterval, rp.config.ReadinessInterval)
+
+	rp, err = New("test", Channel(s.channel), JoinWhenReady(nil, time.Second))
+	s.Nil(rp)
+	s.Error(err)
+
+	rp, err = New("test", Channel(s.channel), JoinWhenReady(func() bool { return true }, -time.Second))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestClockSkewThreshold() {
+	rp, err := New("test", Channel(s.channel), ClockSkewThreshold(time.Second))
+	s.NoError(err)
+	s.Equal(time.Second, rp.config.ClockSkewThreshold)
+
+	rp, err = New("test", Channel(s.channel), ClockSkewThreshold(0))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestListenerPanicLimit() {
+	rp, err := New("test", Channel(s.channel), ListenerPanicLimit(3))
+	s.NoError(err)
+	s.Equal(3, rp.listeners.MaxPanics)
+
+	rp, err = New("test", Channel(s.channel), ListenerPanicLimit(-1))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestProtocolDebugSampling() {
+	rp, err := New("test", Channel(s.channel), ProtocolDebugSampling(0.1, "127.0.0.1:3002"))
+	s.NoError(err)
+	s.Equal(swim.DebugSampling{Rate: 0.1, Peer: "127.0.0.1:3002"}, rp.config.DebugSampling)
+
+	rp, err = New("test", Channel(s.channel), ProtocolDebugSampling(1.5, ""))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestForwardErrorMapping() {
+	mapping := forward.ErrorValue(ErrNoKeys, tchannel.ErrCodeBadRequest)
+	rp, err := New("test", Channel(s.channel), ForwardErrorMapping(mapping))
+	s.NoError(err)
+	s.Len(rp.config.ForwardErrorMappings, 1)
+
+	rp, err = New("test", Channel(s.channel), ForwardErrorMapping(nil))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestFullSyncBudget() {
+	rp, err := New("test", Channel(s.channel), FullSyncBudget(64*1024))
+	s.NoError(err)
+	s.Equal(64*1024, rp.config.FullSyncBudget)
+
+	rp, err = New("test", Channel(s.channel), FullSyncBudget(0))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestMaintenanceWindows() {
+	rp, err := New("test", Channel(s.channel), MaintenanceWindows(3, 1))
+	s.NoError(err)
+	s.Equal(3, rp.config.MaintenanceTimeoutFactor)
+	s.Equal(1, rp.config.MaintenanceConfirmations)
+
+	rp, err = New("test", Channel(s.channel), MaintenanceWindows(0, 1))
+	s.Nil(rp)
+	s.Error(err)
+
+	rp, err = New("test", Channel(s.channel), MaintenanceWindows(2, 0))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestFailureDetector() {
+	detector := swim.FailureDetectorFunc(func(swim.Prober, string) swim.Verdict {
+		return swim.Reachable
+	})
+	rp, err := New("test", Channel(s.channel), FailureDetector(detector))
+	s.NoError(err)
+	s.NotNil(rp.config.FailureDetector)
+
+	rp, err = New("test", Channel(s.channel), FailureDetector(nil))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestGossipTargetSelector() {
+	rp, err := New("test", Channel(s.channel), GossipTargetSelector(&swim.WeightedTargetSelector{}))
+	s.NoError(err)
+	s.NotNil(rp.config.GossipTargetSelector)
+
+	rp, err = New("test", Channel(s.channel), GossipTargetSelector(nil))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+// nopTransport is a Transporter that fails every message.
+type nopTransport struct{}
+
+func (nopTransport) SendPing(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (nopTransport) SendPingReq(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (nopTransport) SendJoin(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (nopTransport) SendSync(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (nopTransport) ForwardRequest(ctx context.Context, req *forward.TransportRequest) (*forward.TransportResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *RingpopOptionsTestSuite) TestTransport() {
+	rp, err := New("test", Channel(s.channel), Transport(nopTransport{}))
+	s.NoError(err)
+	s.Equal(nopTransport{}, rp.config.Transport)
+
+	rp, err = New("test", Channel(s.channel), Transport(nil))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestGossipEncryption() {
+	keyring, err := swim.NewKeyring(make([]byte, swim.KeySize))
+	s.Require().NoError(err)
+
+	rp, err := New("test", Channel(s.channel), GossipEncryption(keyring))
+	s.NoError(err)
+	s.Equal(keyring, rp.config.Keyring)
+
+	rp, err = New("test", Channel(s.channel), GossipEncryption(nil))
+	s.Nil(rp)
+	s.Error(err)
+
+	rp, err = New("test", Channel(s.channel), GossipEncryption(&swim.Keyring{}))
+	s.Nil(rp)
+	s.EqualError(err, "keyring must have a primary key")
+
+	// a keyring with keys to accept messages with still needs one to seal
+	// them with
+	keyring = &swim.Keyring{}
+	s.Require().NoError(keyring.AddKey(make([]byte, swim.KeySize)))
+	rp, err = New("test", Channel(s.channel), GossipEncryption(keyring))
+	s.Nil(rp)
+	s.EqualError(err, "keyring must have a primary key")
+}
+
+func (s *RingpopOptionsTestSuite) TestRingMigration() {
+	config := &hashring.Configuration{ReplicaPoints: 200}
+	rp, err := New("test", Channel(s.channel), RingMigration(config, 1))
+	s.NoError(err)
+	s.Equal(config, rp.config.RingMigration)
+	s.Equal(1, rp.config.RingMigrationEpoch)
+
+	_, err = New("test", Channel(s.channel), RingMigration(nil, 1))
+	s.Error(err)
+	_, err = New("test", Channel(s.channel), RingMigration(config, 0))
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestRingProofs() {
+	rp, err := New("test", Channel(s.channel))
+	s.NoError(err)
+	s.False(rp.config.RingProofs)
+
+	rp, err = New("test", Channel(s.channel), RingProofs())
+	s.NoError(err)
+	s.True(rp.config.RingProofs)
+}
+
+func (s *RingpopOptionsTestSuite) TestWeightLabel() {
+	rp, err := New("test", Channel(s.channel), WeightLabel("weight"))
+	s.NoError(err)
+	s.Equal("weight", rp.config.WeightLabel)
+
+	rp, err = New("test", Channel(s.channel), WeightLabel(""))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestRingIdentity() {
+	rp, err := New("test", Channel(s.channel), RingIdentity("node-17"))
+	s.NoError(err)
+	s.Equal("node-17", rp.config.RingIdentity)
+
+	rp, err = New("test", Channel(s.channel), RingIdentity(""))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestJoinStormProtection() {
+	rp, err := New("test", Channel(s.channel), JoinStormProtection(10*time.Second, 5))
+	s.NoError(err)
+	s.Equal(10*time.Second, rp.config.JoinStaggerWindow)
+	s.Equal(5, rp.config.JoinAdmissionLimit)
+
+	rp, err = New("test", Channel(s.channel), JoinStormProtection(time.Second, -1))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestSelfEviction() {
+	rp, err := New("test", Channel(s.channel), SelfEviction(0.5))
+	s.NoError(err)
+	s.Equal(0.5, rp.config.SelfEvictPingRatio)
+
+	rp, err = New("test", Channel(s.channel), SelfEviction(1.5))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestLoadSpillover() {
+	rp, err := New("test", Channel(s.channel), LoadSpillover(0.8, 0.25))
+	s.NoError(err)
+	s.Equal(0.8, rp.config.SpillThreshold)
+	s.Equal(0.25, rp.config.SpillFraction)
+
+	rp, err = New("test", Channel(s.channel), LoadSpillover(0.8, 0))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestShadowRing() {
+	rp, err := New("test", Channel(s.channel), ShadowRing(ShadowRingConfiguration{ReplicaPoints: 200}))
+	s.NoError(err)
+	s.Equal(200, rp.config.ShadowRing.ReplicaPoints)
+
+	rp, err = New("test", Channel(s.channel), ShadowRing(ShadowRingConfiguration{SampleRate: 2}))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestPartitionHealing() {
+	rp, err := New("test", Channel(s.channel), PartitionHealing(time.Second, time.Minute))
+	s.NoError(err)
+	s.Equal(time.Second, rp.config.PartitionHealPeriod)
+	s.Equal(time.Minute, rp.config.PartitionHealRateLimit)
+
+	rp, err = New("test", Channel(s.channel), PartitionHealing(time.Second, 0))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestLabelPersistence() {
+	store := swim.NewFileLabelStore("/tmp/labels.json")
+	rp, err := New("test", Channel(s.channel), LabelPersistence(store, "tokens", "drain"))
+	s.NoError(err)
+	s.Equal(store, rp.config.LabelStore)
+	s.Equal([]string{"tokens", "drain"}, rp.config.PersistedLabels)
+
+	rp, err = New("test", Channel(s.channel), LabelPersistence(nil))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestAntiEntropy() {
+	rp, err := New("test", Channel(s.channel), AntiEntropy(time.Minute, 10*time.Second))
+	s.NoError(err)
+	s.Equal(time.Minute, rp.config.SyncInterval)
+	s.Equal(10*time.Second, rp.config.SyncJitter)
+
+	rp, err = New("test", Channel(s.channel), AntiEntropy(0, 0))
+	s.Nil(rp)
+	s.Error(err, "expected zero interval to be rejected")
+}
+
+func (s *RingpopOptionsTestSuite) TestFaultyMemberReaping() {
+	rp, err := New("test", Channel(s.channel), FaultyMemberReaping(time.Hour, time.Minute))
+	s.NoError(err)
+	s.Equal(time.Hour, rp.config.FaultyTTL)
+	s.Equal(time.Minute, rp.config.TombstoneTTL)
+
+	rp, err = New("test", Channel(s.channel), FaultyMemberReaping(time.Hour, 0))
+	s.Nil(rp)
+	s.Error(err, "expected zero tombstone TTL to be rejected")
+}
+
+func (s *RingpopOptionsTestSuite) TestChecksumRemediation() {
+	rp, err := New("test", Channel(s.channel), ChecksumRemediation(swim.DefaultRemediationPolicy))
+	s.NoError(err)
+	s.Equal(swim.DefaultRemediationPolicy, rp.config.Remediation)
+
+	rp, err = New("test", Channel(s.channel), ChecksumRemediation(swim.RemediationPolicy{
+		Steps: []swim.RemediationStep{{Mismatches: 5, Action: swim.RemediateQuarantine}},
+	}))
+	s.Nil(rp)
+	s.Error(err, "expected quarantine without a duration to be rejected")
+}
+
+func (s *RingpopOptionsTestSuite) TestServiceMesh() {
+	mesh := shared.Mesh{Sidecar: "127.0.0.1:15001", RoutingDelegate: "mesh"}
+	rp, err := New("test", Channel(s.channel), ServiceMesh(mesh, nil, 0))
+	s.NoError(err)
+	s.Equal(&mesh, rp.Mesh())
+	s.Equal("127.0.0.1:15001", rp.Mesh().DialAddress("127.0.0.1:3001"))
+	s.Equal(defaultReadinessInterval, rp.config.MeshReadinessInterval)
+
+	rp, err = New("test", Channel(s.channel), ServiceMesh(shared.Mesh{}, nil, 0))
+	s.Nil(rp)
+	s.Error(err)
+
+	rp, err = New("test", Channel(s.channel), ServiceMesh(mesh, nil, -time.Second))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestSuspicionConfirmations() {
+	rp, err := New("test", Channel(s.channel), SuspicionConfirmations(3, 6))
+	s.NoError(err)
+	s.Equal(3, rp.config.SuspicionConfirmations)
+	s.Equal(6, rp.config.SuspicionMaxTimeoutFactor)
+
+	rp, err = New("test", Channel(s.channel), SuspicionConfirmations(0, 6))
+	s.Nil(rp)
+	s.Error(err)
+
+	rp, err = New("test", Channel(s.channel), SuspicionConfirmations(3, 0))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestDuplicateAddresses() {
+	rp, err := New("test", Channel(s.channel), DuplicateAddresses(3, time.Minute, time.Hour))
+	s.NoError(err)
+	s.Equal(3, rp.config.DuplicateThreshold)
+	s.Equal(time.Minute, rp.config.DuplicateWindow)
+	s.Equal(time.Hour, rp.config.DuplicateCooldown)
+
+	rp, err = New("test", Channel(s.channel), DuplicateAddresses(0, time.Minute, time.Hour))
+	s.Nil(rp)
+	s.Error(err)
+
+	rp, err = New("test", Channel(s.channel), DuplicateAddresses(3, 0, time.Hour))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestSuspicionTimeout() {
+	rp, err := New("test", Channel(s.channel), SuspicionTimeout(swim.LogScaledSuspicionTimeout(time.Second)))
+	s.NoError(err)
+	s.Equal(2*time.Second, rp.config.SuspicionTimeout(100))
+
+	rp, err = New("test", Channel(s.channel), SuspicionTimeout(nil))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestRoutingOverrides() {
+	rp, err := New("test", Channel(s.channel), RoutingOverrides("/etc/overrides.json", 0))
+	s.NoError(err)
+	s.Equal("/etc/overrides.json", rp.config.OverridesPath)
+	s.Equal(defaultOverridesInterval, rp.config.OverridesInterval)
+
+	rp, err = New("test", Channel(s.channel), RoutingOverrides("", time.Second))
+	s.Nil(rp)
+	s.Error(err)
+
+	rp, err = New("test", Channel(s.channel), RoutingOverrides("/etc/overrides.json", -time.Second))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestLocalHealth() {
+	rp, err := New("test", Channel(s.channel), LocalHealth(8))
+	s.NoError(err)
+	s.Equal(8, rp.config.LocalHealthMax)
+
+	rp, err = New("test", Channel(s.channel), LocalHealth(0))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestZoneAnnotation() {
+	rp, err := New("test", Channel(s.channel))
+	s.NoError(err)
+	s.Equal(DefaultZoneAnnotation, rp.config.ZoneAnnotation)
+
+	rp, err = New("test", Channel(s.channel), ZoneAnnotation("availability-zone"))
+	s.NoError(err)
+	s.Equal("availability-zone", rp.config.ZoneAnnotation)
+
+	rp, err = New("test", Channel(s.channel), ZoneAnnotation(""))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestRebalanceAdvisor() {
+	rp, err := New("test", Channel(s.channel))
+	s.NoError(err)
+	s.Equal(DefaultAdvisorOptions, rp.config.Advisor)
+
+	rp, err = New("test", Channel(s.channel), RebalanceAdvisor(AdvisorOptions{HighLoad: 0.5}))
+	s.NoError(err)
+	s.Equal(0.5, rp.config.Advisor.HighLoad)
+	s.Equal(DefaultAdvisorOptions.ImbalanceThreshold, rp.config.Advisor.ImbalanceThreshold)
+
+	rp, err = New("test", Channel(s.channel), RebalanceAdvisor(AdvisorOptions{ImbalanceThreshold: 0.5}))
+	s.Nil(rp)
+	s.Error(err)
+
+	rp, err = New("test", Channel(s.channel), RebalanceAdvisor(AdvisorOptions{HighLoad: 2}))
+	s.Nil(rp)
+	s.Error(err)
+}
+
+func (s *RingpopOptionsTestSuite) TestEventTimeline() {
+	rp, err := New("test", Channel(s.channel))
+	s.NoError(err)
+	s.Equal(DefaultTimelineSize, rp.config.TimelineSize)
+	s.Len(rp.timeline.entries, DefaultTimelineSize)
+
+	rp, err = New("test", Channel(s.channel), EventTimeline(0))
+	s.NoError(err)
+	s.Empty(rp.timeline.entries)
+
+	rp, err = New("test", Channel(s.channel), EventTimeline(-1))
+	s.Nil(rp)
+	s.Error(err)
+}
+
 // TestTooSmallRingChecksumStatPeriod confirms that insane periods return error.
 func (s *RingpopOptionsTestSuite) TestTooSmallRingChecksumStatPeriod() {
 	rp, err := New("test", Channel(s.channel), RingChecksumStatPeriod(1*time.Nanosecond))
@@ -0,0 +1,160 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"errors"
+	"strconv"
+
+	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/hashring"
+	"github.com/gl-works/ringpop-go/swim"
+)
+
+// RingEpochAnnotation is the annotation members gossip the epoch of the last
+// ring migration they cut over to with, see func RingMigration.
+const RingEpochAnnotation = "ring-epoch"
+
+// errNoRingMigration is returned by CutoverRing when no ring migration is
+// configured.
+var errNoRingMigration = errors.New("no ring migration is configured")
+
+// ringMigration is the migration of the ring to a new configuration. It is
+// only accessed while the view is locked.
+type ringMigration struct {
+	config *hashring.Configuration
+	epoch  int
+	done   bool
+}
+
+// startRingMigration computes the ring of the migration side-by-side with the
+// active ring, so that lookups are compared against it until the cutover.
+func (rp *Ringpop) startRingMigration() error {
+	config := rp.config.RingMigration
+	if rp.config.ShadowRing != nil {
+		return errors.New("a ring migration cannot be combined with a shadow ring")
+	}
+	if config.Maglev != rp.configHashRing.Maglev {
+		return errors.New("a ring migration cannot change whether the ring is a maglev ring")
+	}
+
+	ring, err := newHashRing(config)
+	if err != nil {
+		return err
+	}
+
+	rp.shadow = &shadowRing{ring: ring, sampleRate: 1}
+	rp.migration = &ringMigration{
+		config: config,
+		epoch:  rp.config.RingMigrationEpoch,
+	}
+	return nil
+}
+
+// CutoverRing cuts the cluster over to the ring configuration of the ring
+// migration, see func RingMigration. This instance cuts over immediately and
+// gossips the epoch of the migration, upon which the other members cut over.
+func (rp *Ringpop) CutoverRing() error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	if rp.migration == nil {
+		return errNoRingMigration
+	}
+
+	address, err := rp.identity()
+	if err != nil {
+		return err
+	}
+
+	err = rp.node.Annotate(map[string]string{
+		RingEpochAnnotation: strconv.Itoa(rp.migration.epoch),
+	})
+	if err != nil {
+		return err
+	}
+
+	// the annotation cuts over this instance when it is applied to the
+	// membership, unless it was annotated before
+	rp.view.Lock()
+	rp.cutoverRingNoLock(address, address)
+	rp.view.Unlock()
+	return nil
+}
+
+// RingMigrated returns whether this instance cut over to the ring
+// configuration of its ring migration.
+func (rp *Ringpop) RingMigrated() bool {
+	rp.view.RLock()
+	defer rp.view.RUnlock()
+	return rp.migration != nil && rp.migration.done
+}
+
+// reachedRingEpochNoLock returns whether the change is of a member that cut
+// over to the epoch of the pending ring migration, or a later one. The view
+// must be locked.
+func (rp *Ringpop) reachedRingEpochNoLock(change swim.Change) bool {
+	if rp.migration == nil || rp.migration.done {
+		return false
+	}
+	epoch, err := strconv.Atoi(change.Annotations[RingEpochAnnotation])
+	return err == nil && epoch >= rp.migration.epoch
+}
+
+// cutoverRingNoLock reconfigures the ring with the configuration of the ring
+// migration because member cut over to its epoch. Members that cut over
+// because of another member gossip the epoch themselves, so that it is not
+// lost when that member leaves. The view must be locked.
+func (rp *Ringpop) cutoverRingNoLock(member, address string) {
+	m := rp.migration
+	if m.done {
+		return
+	}
+
+	if err := rp.ring.Reconfigure(hashRingOptions(m.config)...); err != nil {
+		rp.logger.WithField("error", err).Error("ring cutover failed")
+		return
+	}
+	m.done = true
+	rp.configHashRing = m.config
+	rp.node.SetRingFingerprint(rp.ringFingerprint())
+	rp.view.version++
+
+	rp.logger.WithFields(log.Fields{
+		"epoch":  m.epoch,
+		"member": member,
+	}).Info("ring cut over to new configuration")
+	rp.HandleEvent(events.RingCutoverEvent{Epoch: m.epoch, Member: member})
+
+	if member != address {
+		// annotating the local member applies a change to the membership,
+		// which locks the view
+		go func() {
+			err := rp.node.Annotate(map[string]string{
+				RingEpochAnnotation: strconv.Itoa(m.epoch),
+			})
+			if err != nil {
+				rp.logger.WithField("error", err).Warn("failed to gossip ring epoch")
+			}
+		}()
+	}
+}
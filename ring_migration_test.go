@@ -0,0 +1,168 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/hashring"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+type cutoverListener struct {
+	events []events.RingCutoverEvent
+	sync.Mutex
+}
+
+func (l *cutoverListener) HandleEvent(event events.Event) {
+	if event, ok := event.(events.RingCutoverEvent); ok {
+		l.Lock()
+		l.events = append(l.events, event)
+		l.Unlock()
+	}
+}
+
+// wait waits for a bit until an event is recorded, and returns the events.
+func (l *cutoverListener) wait() []events.RingCutoverEvent {
+	for i := 0; i < 100; i++ {
+		l.Lock()
+		recorded := l.events
+		l.Unlock()
+		if len(recorded) > 0 {
+			return recorded
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	return l.events
+}
+
+// waitRingEpoch waits for a bit until the member gossips a ring epoch, and
+// returns it.
+func waitRingEpoch(rp *Ringpop, address string) string {
+	for i := 0; i < 100; i++ {
+		if annotations, _ := rp.Annotations(address); annotations[RingEpochAnnotation] != "" {
+			return annotations[RingEpochAnnotation]
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return ""
+}
+
+func newMigratingRingpop(t *testing.T, config *hashring.Configuration) *Ringpop {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()),
+		RingMigration(config, 2))
+	require.NoError(t, err)
+	require.NoError(t, createSingleNodeCluster(rp))
+
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive},
+		{Address: "127.0.0.1:3003", Status: swim.Alive},
+	})
+	return rp
+}
+
+func TestRingMigrationNotConfigured(t *testing.T) {
+	rp, ch := newListeningRingpop(t)
+	defer ch.Close()
+	assert.Equal(t, ErrNotBootstrapped, rp.CutoverRing())
+	rp.Destroy()
+
+	rp = newShadowRingpop(t, ShadowRingConfiguration{})
+	defer rp.Destroy()
+	assert.Equal(t, errNoRingMigration, rp.CutoverRing())
+	assert.False(t, rp.RingMigrated())
+}
+
+func TestCutoverRing(t *testing.T) {
+	config := &hashring.Configuration{ReplicaPoints: 10, Rendezvous: true}
+	rp := newMigratingRingpop(t, config)
+	defer rp.Destroy()
+
+	listener := &cutoverListener{}
+	rp.RegisterListener(listener)
+	fingerprint := rp.ringFingerprint()
+	assert.Equal(t, 3, rp.shadow.ring.ServerCount(), "expected the new ring to track the members")
+
+	for i := 0; i < 100; i++ {
+		_, err := rp.Lookup(fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(100), rp.ShadowStats().Compared)
+
+	require.NoError(t, rp.CutoverRing())
+	assert.True(t, rp.RingMigrated())
+	assert.True(t, rp.ring.IsRendezvous(), "expected the ring to be reconfigured")
+	assert.NotEqual(t, fingerprint, rp.ringFingerprint())
+	assert.Equal(t, rp.ringFingerprint(), rp.node.(*swim.Node).RingFingerprint(),
+		"expected joins to be validated against the new configuration")
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owner, _ := rp.shadow.ring.Lookup(key)
+		dest, err := rp.Lookup(key)
+		require.NoError(t, err)
+		assert.Equal(t, owner, dest, "expected lookups to use the new configuration")
+	}
+
+	assert.Equal(t, "2", waitRingEpoch(rp, "127.0.0.1:3001"))
+	assert.Equal(t, []events.RingCutoverEvent{{Epoch: 2, Member: "127.0.0.1:3001"}}, listener.wait())
+
+	version := rp.view.version
+	require.NoError(t, rp.CutoverRing())
+	assert.Equal(t, version, rp.view.version, "expected a second cutover to be a no-op")
+}
+
+func TestCutoverRingGossiped(t *testing.T) {
+	rp := newMigratingRingpop(t, &hashring.Configuration{ReplicaPoints: 10})
+	defer rp.Destroy()
+
+	// members of an older epoch do not cut over
+	rp.handleChanges([]swim.Change{{
+		Address:     "127.0.0.1:3002",
+		Status:      swim.Alive,
+		Annotations: map[string]string{RingEpochAnnotation: "1"},
+	}})
+	assert.False(t, rp.RingMigrated())
+
+	rp.handleChanges([]swim.Change{{
+		Address:     "127.0.0.1:3003",
+		Status:      swim.Alive,
+		Annotations: map[string]string{RingEpochAnnotation: "2"},
+	}})
+	assert.True(t, rp.RingMigrated())
+	assert.Equal(t, 10, rp.ring.ServerPoints("127.0.0.1:3003"))
+	assert.Equal(t, "2", waitRingEpoch(rp, "127.0.0.1:3001"),
+		"expected the epoch to be gossiped on by members that cut over")
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"github.com/gl-works/ringpop-go/forward"
+	"github.com/uber/tchannel-go"
+)
+
+// RingProof returns the checksums and version of the ring of this Ringpop
+// instance, which callers compare with their own ring to detect that they
+// were served by a member with a divergent ring.
+func (rp *Ringpop) RingProof() (forward.RingProof, error) {
+	if !rp.Ready() {
+		return forward.RingProof{}, rp.errNotReady()
+	}
+
+	rp.view.RLock()
+	defer rp.view.RUnlock()
+
+	return forward.RingProof{
+		Checksum:  rp.ring.Checksum(),
+		Checksums: rp.ring.Checksums(),
+		Version:   rp.view.version,
+	}, nil
+}
+
+// SetRingProofHeaders attaches the ring proof of this Ringpop instance to the
+// response of the call associated with ctx. Handlers of forwarded requests
+// call it so that forwarding members with the forward.Options VerifyRing
+// option retry requests that were served by a member with a divergent ring.
+func (rp *Ringpop) SetRingProofHeaders(ctx tchannel.ContextWithHeaders) error {
+	proof, err := rp.RingProof()
+	if err != nil {
+		return err
+	}
+	forward.SetRingProofHeaders(ctx, proof)
+	return nil
+}
+
+// adminReply returns the function that attaches the ring proof to the
+// successful replies of the admin endpoints of Ringpop and SWIM, or nil if the
+// RingProofs option is not set. Replies of an instance that is not ready
+// carry no proof.
+func (rp *Ringpop) adminReply() func(ctx tchannel.ContextWithHeaders) {
+	if !rp.config.RingProofs {
+		return nil
+	}
+	return func(ctx tchannel.ContextWithHeaders) {
+		rp.SetRingProofHeaders(ctx)
+	}
+}
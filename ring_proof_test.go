@@ -0,0 +1,117 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/hashring"
+	"github.com/gl-works/ringpop-go/shared"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/json"
+)
+
+func TestRingProof(t *testing.T) {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()), RingProofs())
+	require.NoError(t, err)
+	defer rp.Destroy()
+
+	_, err = rp.RingProof()
+	assert.Error(t, err, "expected no proof before bootstrap")
+
+	require.NoError(t, createSingleNodeCluster(rp))
+	proof, err := rp.RingProof()
+	require.NoError(t, err)
+
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive},
+	})
+	changed, err := rp.RingProof()
+	require.NoError(t, err)
+	assert.NotEqual(t, proof.Checksum, changed.Checksum)
+	assert.True(t, changed.Version > proof.Version, "expected version to increase with the changes")
+	assert.Equal(t, rp.ring.Checksum(), changed.Checksum)
+
+	ctx, cancel := json.NewContext(time.Second)
+	defer cancel()
+	handlers := shared.OnReply(map[string]interface{}{
+		"/admin/lookup": rp.adminLookupHandler,
+	}, "/admin/", rp.adminReply())
+	lookup := handlers["/admin/lookup"].(func(json.Context, *lookupRequest) (*lookupResponse, error))
+	_, err = lookup(ctx, &lookupRequest{Key: "key"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"ringpop-ring-checksum":  fmt.Sprint(changed.Checksum),
+		"ringpop-ring-checksums": fmt.Sprintf("v1=%d", changed.Checksum),
+		"ringpop-ring-version":   fmt.Sprint(changed.Version),
+	}, ctx.ResponseHeaders(), "expected admin reply to carry the ring proof")
+}
+
+// TestRingChecksumsTransition tests that a member that moves to a new version
+// of the ring checksum computes both versions, and that the new version covers
+// the labels of members.
+func TestRingChecksumsTransition(t *testing.T) {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()),
+		HashRingConfig(&hashring.Configuration{
+			ReplicaPoints: 100,
+			Checksummers:  []hashring.Checksummer{hashring.ChecksumV1, hashring.ChecksumV2},
+		}))
+	require.NoError(t, err)
+	defer rp.Destroy()
+
+	require.NoError(t, createSingleNodeCluster(rp))
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive, Labels: map[string]string{"rack": "a"}},
+	})
+	before, err := rp.Checksums()
+	require.NoError(t, err)
+	assert.Len(t, before, 2)
+
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive, Incarnation: 1, Labels: map[string]string{"rack": "b"}},
+	})
+	after, err := rp.Checksums()
+	require.NoError(t, err)
+	assert.Equal(t, before["v1"], after["v1"], "expected v1 not to cover labels")
+	assert.NotEqual(t, before["v2"], after["v2"], "expected v2 to cover labels")
+
+	checksum, err := rp.Checksum()
+	require.NoError(t, err)
+	assert.Equal(t, after["v1"], checksum, "expected the first version to be the checksum of the ring")
+
+	proof, err := rp.RingProof()
+	require.NoError(t, err)
+	assert.Equal(t, after, proof.Checksums)
+}
@@ -27,6 +27,9 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -42,6 +45,8 @@ import (
 	"github.com/gl-works/ringpop-go/swim"
 	"github.com/gl-works/ringpop-go/util"
 	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"golang.org/x/net/context"
 )
 
 // Interface specifies the public facing methods a user of ringpop is able to
@@ -82,7 +87,33 @@ type Ringpop struct {
 	ring       *hashring.HashRing
 	forwarder  *forward.Forwarder
 
-	listeners []events.EventListener
+	// shadow is the shadow ring lookups are compared against, see func
+	// ShadowRing.
+	shadow *shadowRing
+
+	// migration is the pending migration of the ring, see func
+	// RingMigration.
+	migration *ringMigration
+
+	keyLocks     keyLocks
+	fences       fences
+	memberHealth memberHealth
+	degradation  degradation
+	loads        memberLoads
+
+	// view is locked while membership changes are applied to the ring, see
+	// View.
+	view    viewState
+	zones   zoneState
+	weights weightState
+
+	overrides overrideState
+	mesh      meshState
+	selfEvict selfEvictState
+
+	listeners events.ListenerGroup
+
+	timeline timeline
 
 	statter log.StatsReporter
 	stats   struct {
@@ -111,6 +142,9 @@ const (
 	// node, stats and hashring have been instantiated onto the Ringpop
 	// instance.
 	initialized
+	// standby means Bootstrap has been called but is waiting for the
+	// readiness check of the JoinWhenReady option to pass before joining.
+	standby
 	// ready means Bootstrap has been called, the ring has successfully
 	// bootstrapped and is now ready to receive requests.
 	ready
@@ -119,6 +153,23 @@ const (
 	destroyed
 )
 
+func (s state) String() string {
+	switch s {
+	case created:
+		return "created"
+	case initialized:
+		return "initialized"
+	case standby:
+		return "standby"
+	case ready:
+		return "ready"
+	case destroyed:
+		return "destroyed"
+	default:
+		return fmt.Sprintf("state(%d)", uint(s))
+	}
+}
+
 // New returns a new Ringpop instance.
 func New(app string, opts ...Option) (*Ringpop, error) {
 	var err error
@@ -145,6 +196,10 @@ func New(app string, opts ...Option) (*Ringpop, error) {
 		return nil, fmt.Errorf("%v", errs)
 	}
 
+	ringpop.listeners.MaxPanics = ringpop.config.ListenerPanicLimit
+	ringpop.listeners.OnPanic = ringpop.handleListenerPanic
+	ringpop.timeline.entries = make([]TimelineEntry, ringpop.config.TimelineSize)
+
 	ringpop.setState(created)
 
 	return ringpop, nil
@@ -161,23 +216,106 @@ func (rp *Ringpop) init() error {
 		return err
 	}
 
+	rp.ring, err = newHashRing(rp.configHashRing)
+	if err != nil {
+		return err
+	}
+	rp.ring.RegisterListener(rp)
+
+	if rp.config.RingMigration != nil {
+		if err := rp.startRingMigration(); err != nil {
+			return err
+		}
+	}
+
+	if rp.config.ShadowRing != nil {
+		rp.shadow, err = newShadowRing(rp.config.ShadowRing, rp.configHashRing)
+		if err != nil {
+			return err
+		}
+	}
+
 	rp.subChannel = rp.channel.GetSubChannel("ringpop", tchannel.Isolated)
 	rp.registerHandlers()
 
 	rp.node = swim.NewNode(rp.config.App, address, rp.subChannel, &swim.Options{
-		Clock: rp.clock,
+		Clock:           rp.clock,
+		RingFingerprint: rp.ringFingerprint(),
+		Capture:         rp.config.ProtocolCapture,
+		RampPeriod:      rp.config.RampPeriod,
+		WatchdogPeriods: rp.config.WatchdogPeriods,
+		Watchdog:        rp.config.Watchdog,
+		PingHook:        rp.config.PingHook,
+		Arbiter:         rp.config.Arbiter,
+
+		MaintenanceTimeoutFactor: rp.config.MaintenanceTimeoutFactor,
+		MaintenanceConfirmations: rp.config.MaintenanceConfirmations,
+
+		AdminAuthenticator: rp.config.AdminAuthenticator,
+		AdminReply:         rp.adminReply(),
+		ArbiterFilter:      rp.config.ArbiterFilter,
+
+		AdvertiseAddresses: rp.config.AdvertiseAddresses,
+		AddressSelector:    rp.config.AddressSelector,
+		Mesh:               rp.config.Mesh,
+		Features:           rp.config.Features,
+
+		FalsePositiveThreshold: rp.config.FalsePositiveThreshold,
+		ClockSkewThreshold:     rp.config.ClockSkewThreshold,
+		DebugSampling:          rp.config.DebugSampling,
+		ChecksumVersion:        rp.config.ChecksumVersion,
+
+		MaxBacklog:     rp.config.MaxBacklog,
+		BacklogStore:   rp.config.BacklogStore,
+		FullSyncBudget: rp.config.FullSyncBudget,
+
+		FailureDetector: rp.config.FailureDetector,
+		TargetSelector:  rp.config.GossipTargetSelector,
+		Transport:       rp.config.Transport,
+		Keyring:         rp.config.Keyring,
+		LocalHealthMax:  rp.config.LocalHealthMax,
+
+		SuspicionTimeoutFunc: rp.config.SuspicionTimeout,
+
+		SuspicionConfirmations:    rp.config.SuspicionConfirmations,
+		SuspicionMaxTimeoutFactor: rp.config.SuspicionMaxTimeoutFactor,
+
+		DuplicateThreshold: rp.config.DuplicateThreshold,
+		DuplicateWindow:    rp.config.DuplicateWindow,
+		DuplicateCooldown:  rp.config.DuplicateCooldown,
+
+		Remediation: rp.config.Remediation,
+
+		FaultyTTL:    rp.config.FaultyTTL,
+		TombstoneTTL: rp.config.TombstoneTTL,
+
+		SyncInterval: rp.config.SyncInterval,
+		SyncJitter:   rp.config.SyncJitter,
+
+		LabelStore:      rp.config.LabelStore,
+		PersistedLabels: rp.config.PersistedLabels,
+
+		PartitionHealPeriod:    rp.config.PartitionHealPeriod,
+		PartitionHealRateLimit: rp.config.PartitionHealRateLimit,
+		JoinStaggerWindow:      rp.config.JoinStaggerWindow,
+		JoinAdmissionLimit:     rp.config.JoinAdmissionLimit,
+		Identity:               rp.config.RingIdentity,
 	})
 	rp.node.RegisterListener(rp)
 
-	rp.ring = hashring.New(farm.Fingerprint32, rp.configHashRing.ReplicaPoints)
-	rp.ring.RegisterListener(rp)
-
 	rp.stats.hostport = genStatsHostport(address)
 	rp.stats.prefix = fmt.Sprintf("ringpop.%s", rp.stats.hostport)
 	rp.stats.keys = make(map[string]string)
 
 	rp.forwarder = forward.NewForwarder(rp, rp.subChannel)
 	rp.forwarder.RegisterListener(rp)
+	rp.forwarder.SetDestinationLimit(rp.config.ForwardLimit)
+	if rp.config.Transport != nil {
+		rp.forwarder.SetTransport(rp.config.Transport)
+	}
+	for _, e := range rp.config.ForwardEndpoints {
+		rp.forwarder.RegisterEndpoint(e.service, e.endpoint, e.opts)
+	}
 
 	rp.startTimers()
 	rp.setState(initialized)
@@ -185,13 +323,78 @@ func (rp *Ringpop) init() error {
 	return nil
 }
 
+// ringFingerprint returns a description of the hash ring configuration. Nodes
+// with a different fingerprint place keys differently, so the fingerprint is
+// validated when joining a cluster.
+func (rp *Ringpop) ringFingerprint() string {
+	fingerprint := fmt.Sprintf("hash=%s;replicaPoints=%d", hashName(rp.configHashRing),
+		rp.configHashRing.ReplicaPoints)
+	if rp.configHashRing.Rendezvous {
+		fingerprint += ";rendezvous"
+	}
+	if rp.configHashRing.Maglev {
+		fingerprint += ";maglev"
+	}
+	return fingerprint
+}
+
+// hashProbe is the input that identifies custom hash functions that are not
+// named in the hash ring configuration.
+var hashProbe = []byte("ringpop")
+
+// hashName returns the name of the hash function of the hash ring
+// configuration. Custom hash functions without a name are named after the
+// hash of hashProbe, so that nodes configured with different functions have
+// different ring fingerprints.
+func hashName(config *hashring.Configuration) string {
+	switch {
+	case config.HashName != "":
+		return config.HashName
+	case config.HashFunc64 != nil:
+		return fmt.Sprintf("custom64-%016x", config.HashFunc64(hashProbe))
+	case config.HashFunc != nil:
+		return fmt.Sprintf("custom32-%08x", config.HashFunc(hashProbe))
+	}
+	return "farmhash32"
+}
+
+// newHashRing returns a hash ring with the replica points, hash function and
+// placement of the configuration. The ring hashes with farmhash32 unless the
+// configuration sets another hash function.
+func newHashRing(config *hashring.Configuration) (*hashring.HashRing, error) {
+	return hashring.NewHashRing(hashRingOptions(config)...)
+}
+
+// hashRingOptions returns the hashring options of the configuration.
+func hashRingOptions(config *hashring.Configuration) []hashring.Option {
+	hash := hashring.HashFunc(farm.Fingerprint32)
+	switch {
+	case config.HashFunc64 != nil:
+		hash = hashring.HashFunc64(config.HashFunc64)
+	case config.HashFunc != nil:
+		hash = hashring.HashFunc(config.HashFunc)
+	}
+	opts := []hashring.Option{hash, hashring.ReplicaPoints(config.ReplicaPoints)}
+	if config.Rendezvous {
+		opts = append(opts, hashring.Rendezvous())
+	}
+	if config.Maglev {
+		opts = append(opts, hashring.Maglev(0))
+	}
+	if len(config.Checksummers) > 0 {
+		opts = append(opts, hashring.Checksummers(config.Checksummers...))
+	}
+	return opts
+}
+
 // Starts periodic timers in a single goroutine. Can be turned back off via
-// stopTimers. At present, only 1 timer exists, to emit ring.checksum-periodic.
+// stopTimers. At present, 2 timers exist, to emit ring.checksum-periodic and
+// to reload the routing overrides.
 func (rp *Ringpop) startTimers() {
 	if rp.tickers != nil {
 		return
 	}
-	rp.tickers = make(chan *clock.Ticker, 1) // 1 == max number of tickers
+	rp.tickers = make(chan *clock.Ticker, 3) // 3 == max number of tickers
 
 	if rp.config.RingChecksumStatPeriod != RingChecksumStatPeriodNever {
 		ticker := rp.clock.Ticker(rp.config.RingChecksumStatPeriod)
@@ -205,6 +408,27 @@ func (rp *Ringpop) startTimers() {
 			}
 		}()
 	}
+
+	if rp.config.OverridesPath != "" {
+		rp.reloadOverrides()
+		ticker := rp.clock.Ticker(rp.config.OverridesInterval)
+		rp.tickers <- ticker
+		go func() {
+			for _ = range ticker.C {
+				rp.reloadOverrides()
+			}
+		}()
+	}
+
+	if rp.config.MeshReadiness != nil {
+		ticker := rp.clock.Ticker(rp.config.MeshReadinessInterval)
+		rp.tickers <- ticker
+		go func() {
+			for _ = range ticker.C {
+				rp.checkMeshReadiness()
+			}
+		}()
+	}
 }
 
 func (rp *Ringpop) stopTimers() {
@@ -238,6 +462,32 @@ func (rp *Ringpop) channelIdentityResolver() (string, error) {
 	return peerInfo.HostPort, nil
 }
 
+// advertisedIdentityResolver resolves the identity from the advertised
+// address set with BindAddress, after checking that TChannel listens on the
+// bind address.
+func (rp *Ringpop) advertisedIdentityResolver() (string, error) {
+	peerInfo := rp.channel.PeerInfo()
+	if peerInfo.IsEphemeralHostPort() {
+		return "", ErrEphemeralIdentity
+	}
+
+	_, listenPort, err := splitBindAddress(peerInfo.HostPort)
+	if err != nil {
+		return "", err
+	}
+	_, bindPort, _ := splitBindAddress(rp.config.BindAddress)
+	if bindPort != 0 && bindPort != listenPort {
+		return "", fmt.Errorf("channel listens on %s instead of bind address %s",
+			peerInfo.HostPort, rp.config.BindAddress)
+	}
+
+	host, port, _ := splitBindAddress(rp.config.AdvertiseAddress)
+	if port == 0 {
+		port = listenPort
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
 // Destroy stops all communication. Note that this does not close the TChannel
 // instance that was passed to Ringpop in the constructor. Once an instance is
 // destroyed, it cannot be restarted.
@@ -247,10 +497,34 @@ func (rp *Ringpop) Destroy() {
 	}
 
 	rp.stopTimers()
+	rp.stopPushback()
+	rp.releaseKeyLocks()
+	rp.releaseFences()
 
 	rp.setState(destroyed)
 }
 
+// Leave gossips that this Ringpop instance voluntarily leaves the cluster.
+// Call it before Destroy on a clean shutdown: other members remove the
+// instance from their rings right away, instead of suspecting it and waiting
+// for it to be declared faulty, which also avoids the churn of the suspect
+// period. The instance is removed from its own ring as well; Rejoin brings it
+// back.
+func (rp *Ringpop) Leave() error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	return rp.node.Leave()
+}
+
+// Rejoin makes this Ringpop instance alive again after it left the cluster.
+func (rp *Ringpop) Rejoin() error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	return rp.node.Rejoin()
+}
+
 // destroyed returns
 func (rp *Ringpop) destroyed() bool {
 	return rp.getState() == destroyed
@@ -267,7 +541,7 @@ func (rp *Ringpop) App() string {
 // error if Ringpop is not yet initialized/bootstrapped.
 func (rp *Ringpop) WhoAmI() (string, error) {
 	if !rp.Ready() {
-		return "", ErrNotBootstrapped
+		return "", rp.errNotReady()
 	}
 	return rp.identity()
 }
@@ -276,21 +550,65 @@ func (rp *Ringpop) WhoAmI() (string, error) {
 // bootstrapped for.
 func (rp *Ringpop) Uptime() (time.Duration, error) {
 	if !rp.Ready() {
-		return 0, ErrNotBootstrapped
+		return 0, rp.errNotReady()
 	}
 	return time.Now().Sub(rp.startTime), nil
 }
 
-func (rp *Ringpop) emit(event interface{}) {
-	for _, listener := range rp.listeners {
-		go listener.HandleEvent(event)
+// SetPushback makes this Ringpop instance ask other members to back off from
+// it for the duration of retryAfter, for example when it is overloaded. The
+// signal is sent along with responses to gossip protocol requests until
+// retryAfter elapsed or it is replaced by another call. A retryAfter of zero
+// clears the signal. While pushback is signaled, the instance is gossiped as
+// degraded, see SetDegraded. Handlers of forwarded
+// requests can signal pushback on a per-request basis with
+// forward.SetPushbackHeaders.
+func (rp *Ringpop) SetPushback(retryAfter time.Duration, load float64) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
 	}
+	return rp.setPushback(retryAfter, load)
+}
+
+func (rp *Ringpop) emit(event interface{}) {
+	rp.listeners.EmitAsync(event)
 }
 
 // RegisterListener adds a listener to the ringpop. The listener's HandleEvent method
-// should be thread safe.
+// should be thread safe. The listener is named after its type, see
+// RegisterNamedListener.
 func (rp *Ringpop) RegisterListener(l events.EventListener) {
-	rp.listeners = append(rp.listeners, l)
+	rp.listeners.Register(events.ListenerName(l), l)
+}
+
+// RegisterNamedListener adds a listener to the ringpop under the given name.
+// A panic in the listener's HandleEvent method is recovered, logged with the
+// name and counted in the "listener.panic" stat. With the ListenerPanicLimit
+// option, a listener that keeps panicking is deregistered.
+func (rp *Ringpop) RegisterNamedListener(name string, l events.EventListener) {
+	rp.listeners.Register(name, l)
+}
+
+// DeregisterListener removes the listeners registered under the given name
+// and returns whether there were any.
+func (rp *Ringpop) DeregisterListener(name string) bool {
+	return rp.listeners.Deregister(name)
+}
+
+// handleListenerPanic reports a panic of a listener that was recovered.
+func (rp *Ringpop) handleListenerPanic(p events.ListenerPanic) {
+	rp.logger.WithFields(log.Fields{
+		"listener": p.Listener,
+		"event":    fmt.Sprintf("%T", p.Event),
+		"panic":    p.Value,
+		"panics":   p.Panics,
+	}).Error("event listener panicked")
+	rp.statter.IncCounter(rp.getStatKey("listener.panic"), nil, 1)
+
+	if p.Deregistered {
+		rp.logger.WithField("listener", p.Listener).Warn("event listener deregistered after repeated panics")
+		rp.statter.IncCounter(rp.getStatKey("listener.deregistered"), nil, 1)
+	}
 }
 
 // getState gets the state of the current Ringpop instance.
@@ -304,8 +622,13 @@ func (rp *Ringpop) getState() state {
 // setState sets the state of the current Ringpop instance.
 func (rp *Ringpop) setState(s state) {
 	rp.stateMutex.Lock()
+	changed := rp.state != s
 	rp.state = s
 	rp.stateMutex.Unlock()
+
+	if changed {
+		rp.recordTimeline("state", map[string]string{"state": s.String()})
+	}
 }
 
 //= = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = =
@@ -320,7 +643,9 @@ func (rp *Ringpop) setState(s state) {
 // other instances from a seed list provided either in the BootstrapOptions or
 // as a JSON file.
 //
-// If no seed hosts are provided, a single-node cluster will be created.
+// If no seed hosts are provided, a single-node cluster will be created. When
+// the cluster cannot be joined, the error is a *swim.JoinError describing why
+// each seed could not be joined.
 func (rp *Ringpop) Bootstrap(userBootstrapOpts *swim.BootstrapOptions) ([]string, error) {
 	if rp.getState() < initialized {
 		err := rp.init()
@@ -334,6 +659,10 @@ func (rp *Ringpop) Bootstrap(userBootstrapOpts *swim.BootstrapOptions) ([]string
 		return nil, err
 	}
 
+	if err := rp.waitUntilReady(); err != nil {
+		return nil, err
+	}
+
 	// If the user has provided a list of hosts (and not a bootstrap file),
 	// check we're in the bootstrap host list and add ourselves if we're not
 	// there. If the host list is empty, this will create a single-node
@@ -351,11 +680,55 @@ func (rp *Ringpop) Bootstrap(userBootstrapOpts *swim.BootstrapOptions) ([]string
 	}
 
 	rp.setState(ready)
+	rp.recordTimeline("bootstrap", map[string]interface{}{"joined": joined})
 
 	rp.logger.WithField("joined", joined).Info("bootstrap complete")
 	return joined, nil
 }
 
+// WaitForConvergence blocks until the membership of this Ringpop instance has
+// converged with the cluster, i.e. its membership checksum stayed the same,
+// and it had no membership changes left to gossip, for the ConvergenceWindow.
+// Waiting after Bootstrap keeps requests from being routed on a ring that is
+// still forming. It returns the error of ctx when ctx is done first.
+func (rp *Ringpop) WaitForConvergence(ctx context.Context) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+
+	window := rp.config.ConvergenceWindow
+	interval := window / 10
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+
+	ticker := rp.clock.Ticker(interval)
+	defer ticker.Stop()
+
+	// Changes are only left to gossip while there are members to gossip
+	// them to.
+	gossiping := func() bool {
+		return rp.node.HasChanges() && rp.node.CountReachableMembers() > 1
+	}
+
+	checksum := rp.node.Checksum()
+	stableSince := rp.clock.Now()
+	for {
+		if current := rp.node.Checksum(); current != checksum || gossiping() {
+			checksum = current
+			stableSince = rp.clock.Now()
+		} else if rp.clock.Now().Sub(stableSince) >= window {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 // Ready returns whether or not ringpop is bootstrapped and ready to receive
 // requests.
 func (rp *Ringpop) Ready() bool {
@@ -373,7 +746,15 @@ func (rp *Ringpop) Ready() bool {
 
 // HandleEvent is used to satisfy the swim.EventListener interface. No touchy.
 func (rp *Ringpop) HandleEvent(event events.Event) {
-	rp.emit(event)
+	rp.HandleEventContext(context.Background(), event)
+}
+
+// HandleEventContext is used to satisfy the events.ContextEventListener
+// interface, so that the context of forwarded requests reaches the listeners
+// registered with Ringpop.
+func (rp *Ringpop) HandleEventContext(ctx context.Context, event events.Event) {
+	rp.listeners.EmitAsyncContext(ctx, event)
+	rp.recordTimelineEvent(event)
 
 	switch event := event.(type) {
 	case swim.MemberlistChangesReceivedEvent:
@@ -394,6 +775,12 @@ func (rp *Ringpop) HandleEvent(event events.Event) {
 				status = "unknown"
 			}
 			rp.statter.IncCounter(rp.getStatKey("membership-set."+status), nil, 1)
+
+			// the age of members that leave shows churn patterns
+			if (status == swim.Faulty || status == swim.Leave) && change.JoinedAt != 0 {
+				joinedAt := time.Unix(0, change.JoinedAt*int64(time.Millisecond))
+				rp.statter.RecordTimer(rp.getStatKey("membership.member-age"), nil, rp.clock.Now().Sub(joinedAt))
+			}
 		}
 		mc, err := rp.CountReachableMembers()
 		if err != nil {
@@ -406,6 +793,10 @@ func (rp *Ringpop) HandleEvent(event events.Event) {
 	case swim.FullSyncEvent:
 		rp.statter.IncCounter(rp.getStatKey("full-sync"), nil, 1)
 
+	case swim.FullSyncChunkEvent:
+		rp.statter.IncCounter(rp.getStatKey("full-sync.chunk"), nil, 1)
+		rp.statter.IncCounter(rp.getStatKey("full-sync.chunk.bytes"), nil, int64(event.Bytes))
+
 	case swim.MaxPAdjustedEvent:
 		rp.statter.UpdateGauge(rp.getStatKey("max-piggyback"), nil, int64(event.NewPCount))
 
@@ -462,6 +853,9 @@ func (rp *Ringpop) HandleEvent(event events.Event) {
 	case swim.JoinTriesUpdateEvent:
 		rp.statter.UpdateGauge(rp.getStatKey("join.retries"), nil, int64(event.Retries))
 
+	case swim.JoinRetriedEvent:
+		rp.statter.RecordTimer(rp.getStatKey("join.retried"), nil, event.Delay)
+
 	case events.LookupEvent:
 		rp.statter.RecordTimer(rp.getStatKey("lookup"), nil, event.Duration)
 
@@ -474,6 +868,149 @@ func (rp *Ringpop) HandleEvent(event events.Event) {
 	case swim.RefuteUpdateEvent:
 		rp.statter.IncCounter(rp.getStatKey("refuted-update"), nil, 1)
 
+	case swim.ProtocolStalledEvent:
+		rp.statter.IncCounter(rp.getStatKey("watchdog.stalled"), nil, 1)
+
+	case swim.SuspicionConfirmedEvent:
+		rp.statter.IncCounter(rp.getStatKey("suspicion.confirmed"), nil, 1)
+
+	case swim.DuplicateAddressEvent:
+		rp.statter.IncCounter(rp.getStatKey("duplicate-address"), nil, 1)
+
+	case swim.DuplicateIdentityEvent:
+		rp.statter.IncCounter(rp.getStatKey("duplicate-identity"), nil, 1)
+
+	case swim.ChecksumRemediationEvent:
+		rp.statter.IncCounter(rp.getStatKey("remediation."+string(event.Action)), nil, 1)
+
+	case swim.ChecksumConvergedEvent:
+		rp.statter.IncCounter(rp.getStatKey("remediation.converged"), nil, 1)
+
+	case swim.MemberTombstonedEvent:
+		rp.statter.IncCounter(rp.getStatKey("reaper.tombstoned"), nil, 1)
+
+	case swim.MemberReapedEvent:
+		rp.statter.IncCounter(rp.getStatKey("reaper.reaped"), nil, 1)
+
+	case swim.AntiEntropySyncEvent:
+		rp.statter.IncCounter(rp.getStatKey("anti-entropy.sync"), nil, 1)
+		rp.statter.RecordTimer(rp.getStatKey("anti-entropy.duration"), nil, event.Duration)
+		if event.Pulled > 0 || event.Pushed > 0 {
+			rp.statter.IncCounter(rp.getStatKey("anti-entropy.mismatch"), nil, 1)
+		}
+
+	case swim.AntiEntropySyncFailedEvent:
+		rp.statter.IncCounter(rp.getStatKey("anti-entropy.failed"), nil, 1)
+
+	case swim.LocalHealthChangedEvent:
+		rp.statter.UpdateGauge(rp.getStatKey("local-health"), nil, int64(event.Score))
+
+	case swim.RampChangedEvent:
+		rp.statter.UpdateGauge(rp.getStatKey("ramp"), nil, int64(event.Ramp))
+
+	case swim.SuspectTTLExpiredEvent:
+		rp.statter.IncCounter(rp.getStatKey("suspect-ttl-expired"), nil, 1)
+
+	case swim.PushbackReceivedEvent:
+		rp.statter.IncCounter(rp.getStatKey("pushback.recv"), nil, 1)
+		rp.recordLoad(event.Remote, event.Load)
+
+	case swim.TransportFailureReportedEvent:
+		rp.statter.IncCounter(rp.getStatKey("transport-failure.reported"), nil, 1)
+
+	case swim.MessageAuthenticationFailedEvent:
+		rp.statter.IncCounter(rp.getStatKey("protocol.unauthenticated"), nil, 1)
+
+	case swim.SuspicionResolvedEvent:
+		if event.Refuted {
+			rp.statter.IncCounter(rp.getStatKey("detector.refuted"), nil, 1)
+		} else {
+			rp.statter.IncCounter(rp.getStatKey("detector.confirmed"), nil, 1)
+		}
+
+	case swim.FalsePositiveRateExceededEvent:
+		rp.statter.IncCounter(rp.getStatKey("detector.false-positive-rate.exceeded"), nil, 1)
+
+	case swim.ClockSkewEstimatedEvent:
+		rp.statter.UpdateGauge(rp.getStatKey("clock-skew."+genStatsHostport(event.Remote)), nil, int64(event.Offset/time.Millisecond))
+
+	case swim.ClockSkewExceededEvent:
+		rp.statter.IncCounter(rp.getStatKey("clock-skew.exceeded"), nil, 1)
+
+	case swim.PartitionStartedEvent:
+		rp.statter.IncCounter(rp.getStatKey("partition.started"), nil, 1)
+
+	case swim.PartitionEndedEvent:
+		rp.statter.IncCounter(rp.getStatKey("partition.ended"), nil, 1)
+
+	case swim.PartitionDetectedEvent:
+		rp.statter.IncCounter(rp.getStatKey("partition.detected"), nil, 1)
+
+	case swim.PartitionHealedEvent:
+		rp.statter.IncCounter(rp.getStatKey("partition.healed"), nil, 1)
+		rp.statter.RecordTimer(rp.getStatKey("partition.heal-duration"), nil, event.Duration)
+
+	case swim.MemberBlacklistedEvent:
+		rp.statter.IncCounter(rp.getStatKey("blacklist.added"), nil, 1)
+		rp.excludeBlacklisted(event.Member)
+
+	case swim.MemberUnblacklistedEvent:
+		rp.statter.IncCounter(rp.getStatKey("blacklist.removed"), nil, 1)
+		rp.restoreUnblacklisted(event.Member)
+
+	case swim.BulkUpdateEvent:
+		rp.statter.IncCounter(rp.getStatKey("membership.bulk-update"), nil, 1)
+
+	case swim.SubsystemToggledEvent:
+		rp.statter.IncCounter(rp.getStatKey("subsystem.toggled"), nil, 1)
+		enabled := int64(0)
+		if event.Enabled {
+			enabled = 1
+		}
+		rp.statter.UpdateGauge(rp.getStatKey("subsystem."+string(event.Subsystem)+".enabled"), nil, enabled)
+
+	case swim.JoinStaggeredEvent:
+		rp.statter.RecordTimer(rp.getStatKey("join.staggered"), nil, event.Delay)
+
+	case swim.JoinStormDetectedEvent:
+		rp.statter.IncCounter(rp.getStatKey("join.storm"), nil, 1)
+
+	case swim.JoinThrottledEvent:
+		rp.statter.IncCounter(rp.getStatKey("join.throttled"), nil, 1)
+
+	case swim.AdminDeniedEvent:
+		rp.statter.IncCounter(rp.getStatKey("admin.denied"), nil, 1)
+
+	case swim.FaultyVetoedEvent:
+		rp.statter.IncCounter(rp.getStatKey("faulty.vetoed"), nil, 1)
+
+	case swim.MaintenanceDeferredEvent:
+		rp.statter.IncCounter(rp.getStatKey("maintenance.deferred"), nil, 1)
+
+	case swim.ManifestImportedEvent:
+		rp.statter.UpdateGauge(rp.getStatKey("bootstrap.manifest-members"), nil, int64(event.Members))
+
+	case swim.AnnotationsChangedEvent:
+		rp.statter.IncCounter(rp.getStatKey("annotations.changed"), nil, 1)
+
+	case swim.LabelsChangedEvent:
+		rp.statter.IncCounter(rp.getStatKey("labels.changed"), nil, 1)
+
+	case swim.LabelsRestoredEvent:
+		rp.statter.IncCounter(rp.getStatKey("labels.restored"), nil, 1)
+
+	case swim.PingVetoedEvent:
+		rp.statter.IncCounter(rp.getStatKey("ping.vetoed"), nil, 1)
+
+	case swim.ChecksumVersionChangedEvent:
+		rp.statter.UpdateGauge(rp.getStatKey("membership.checksum-version"), nil, int64(event.NewVersion))
+
+	case swim.ChangesSpilledEvent:
+		rp.statter.IncCounter(rp.getStatKey("dissemination.spilled"), nil, int64(event.Count))
+
+	case swim.ChangesRestoredEvent:
+		rp.statter.IncCounter(rp.getStatKey("dissemination.restored"), nil, int64(event.Count))
+
 	case events.RingChecksumEvent:
 		rp.statter.IncCounter(rp.getStatKey("ring.checksum-computed"), nil, 1)
 		rp.statter.UpdateGauge(rp.getStatKey("ring.checksum"), nil, int64((event.NewChecksum)))
@@ -481,9 +1018,68 @@ func (rp *Ringpop) HandleEvent(event events.Event) {
 	case events.RingChangedEvent:
 		added := int64(len(event.ServersAdded))
 		removed := int64(len(event.ServersRemoved))
+		updated := int64(len(event.ServersUpdated))
 		rp.statter.IncCounter(rp.getStatKey("ring.server-added"), nil, added)
 		rp.statter.IncCounter(rp.getStatKey("ring.server-removed"), nil, removed)
+		rp.statter.IncCounter(rp.getStatKey("ring.server-updated"), nil, updated)
 		rp.statter.IncCounter(rp.getStatKey("ring.changed"), nil, 1)
+		rp.revalidateKeyLocks()
+		rp.revalidateFences()
+
+	case events.KeyLockLostEvent:
+		rp.statter.IncCounter(rp.getStatKey("keylock.lost"), nil, 1)
+
+	case events.ReadyToJoinEvent:
+		rp.statter.RecordTimer(rp.getStatKey("standby"), nil, event.Waited)
+
+	case events.ZoneUnreachableEvent:
+		rp.statter.IncCounter(rp.getStatKey("zone.unreachable"), nil, 1)
+
+	case events.ZoneReachableEvent:
+		rp.statter.IncCounter(rp.getStatKey("zone.reachable"), nil, 1)
+
+	case events.LookupOverriddenEvent:
+		rp.statter.IncCounter(rp.getStatKey("lookup.overridden"), nil, 1)
+
+	case events.MemberWeightChangedEvent:
+		rp.statter.IncCounter(rp.getStatKey("member.weight-changed"), nil, 1)
+
+	case events.SelfEvictedEvent:
+		rp.statter.IncCounter(rp.getStatKey("self-evicted"), nil, 1)
+		rp.statter.RecordTimer(rp.getStatKey("self-evict"), nil, event.Duration)
+
+	case events.LookupNSpillEvent:
+		rp.statter.IncCounter(rp.getStatKey("lookupN.overloaded"), nil, 1)
+		if event.Spilled {
+			rp.statter.IncCounter(rp.getStatKey("lookupN.spilled"), nil, 1)
+		}
+
+	case events.ShadowLookupEvent:
+		rp.statter.IncCounter(rp.getStatKey("shadow.lookup.compared"), nil, 1)
+		if event.ShadowOwner != event.Owner {
+			rp.statter.IncCounter(rp.getStatKey("shadow.lookup.diverged"), nil, 1)
+		}
+
+	case events.RingCutoverEvent:
+		rp.statter.IncCounter(rp.getStatKey("ring.cutover"), nil, 1)
+		rp.revalidateKeyLocks()
+		rp.revalidateFences()
+
+	case events.RoutingOverridesReloadedEvent:
+		rp.statter.IncCounter(rp.getStatKey("overrides.reloaded"), nil, 1)
+		rp.statter.UpdateGauge(rp.getStatKey("overrides.pins"), nil, int64(event.Keys+event.Prefixes))
+		rp.revalidateKeyLocks()
+		rp.revalidateFences()
+
+	case events.RoutingOverridesFailedEvent:
+		rp.statter.IncCounter(rp.getStatKey("overrides.failed"), nil, 1)
+
+	case events.MeshReadinessChangedEvent:
+		ready := int64(0)
+		if event.Ready {
+			ready = 1
+		}
+		rp.statter.UpdateGauge(rp.getStatKey("mesh.ready"), nil, ready)
 
 	case forward.RequestForwardedEvent:
 		rp.statter.IncCounter(rp.getStatKey("requestProxy.egress"), nil, 1)
@@ -519,22 +1115,128 @@ func (rp *Ringpop) HandleEvent(event events.Event) {
 
 	case forward.RetrySuccessEvent:
 		rp.statter.IncCounter(rp.getStatKey("requestProxy.retry.succeeded"), nil, 1)
+
+	case forward.BytesForwardedEvent:
+		endpoint := genStatsEndpoint(event.Endpoint)
+		rp.statter.IncCounter(rp.getStatKey("requestProxy.egress.bytes."+endpoint), nil, int64(event.RequestBytes))
+		rp.statter.IncCounter(rp.getStatKey("requestProxy.ingress.bytes."+endpoint), nil, int64(event.ResponseBytes))
+		if event.Codec != "" {
+			rp.statter.IncCounter(rp.getStatKey("requestProxy.codec."+event.Codec+".egress.bytes"), nil, int64(event.RequestBytes))
+			rp.statter.IncCounter(rp.getStatKey("requestProxy.codec."+event.Codec+".ingress.bytes"), nil, int64(event.ResponseBytes))
+			if event.Compressible {
+				rp.statter.IncCounter(rp.getStatKey("requestProxy.codec."+event.Codec+".compressible"), nil, 1)
+			}
+		}
+
+	case forward.PushbackReceivedEvent:
+		rp.statter.IncCounter(rp.getStatKey("requestProxy.pushback.received"), nil, 1)
+
+	case forward.ConnectionFailedEvent:
+		rp.statter.IncCounter(rp.getStatKey("requestProxy.connection.failed"), nil, 1)
+		// a destination that cannot be connected to is probed by the failure
+		// detector on the next protocol period
+		rp.node.ReportTransportFailure(event.Destination)
+
+	case forward.ConcurrencyLimitedEvent:
+		rp.statter.IncCounter(rp.getStatKey("requestProxy.limit.rejected"), nil, 1)
+
+	case forward.RingDivergedEvent:
+		rp.statter.IncCounter(rp.getStatKey("requestProxy.ring.diverged"), nil, 1)
+
+	case forward.DivergedRingDetectedEvent:
+		rp.statter.IncCounter(rp.getStatKey("requestProxy.ring.loop"), nil, 1)
+
+	case forward.HandlerPanicEvent:
+		// wrapped handlers can panic before the stat keys are set up
+		if rp.getState() != created {
+			rp.statter.IncCounter(rp.getStatKey("requestProxy.handler.panic"), nil, 1)
+		}
 	}
 }
 
 func (rp *Ringpop) handleChanges(changes []swim.Change) {
 	var serversToAdd, serversToRemove []string
+	var cutover string
+
+	rp.trackHealth(changes)
+
+	rp.view.Lock()
+	defer rp.view.Unlock()
+	rp.updateViewNoLock(changes)
+	rp.trackZonesNoLock()
 
 	for _, change := range changes {
+		if rp.reachedRingEpochNoLock(change) {
+			cutover = change.Address
+		}
+
 		switch change.Status {
 		case swim.Alive:
+			// blacklisted members are excluded from lookups until they are
+			// removed from the blacklist
+			if rp.node.Blacklisted(change.Address) {
+				serversToRemove = append(serversToRemove, change.Address)
+				continue
+			}
+			rp.placeServerNoLock(change)
 			serversToAdd = append(serversToAdd, change.Address)
-		case swim.Faulty, swim.Leave:
+			rp.ring.ClearSuspectServer(change.Address)
+		case swim.Suspect:
+			// keep a failover ring without the suspect ready, so that the
+			// switchover is immediate if it is declared faulty
+			rp.ring.SuspectServer(change.Address)
+		case swim.Faulty, swim.Leave, swim.Tombstone:
 			serversToRemove = append(serversToRemove, change.Address)
+			delete(rp.weights.weights, change.Address)
 		}
 	}
 
 	rp.ring.AddRemoveServers(serversToAdd, serversToRemove)
+	if rp.shadow != nil {
+		rp.shadow.ring.AddRemoveServers(serversToAdd, serversToRemove)
+	}
+
+	if cutover != "" {
+		address, _ := rp.identity()
+		rp.cutoverRingNoLock(cutover, address)
+	}
+}
+
+// placeServerNoLock sets the replica points and identity a member is placed
+// on the ring with before it is added. The view must be locked.
+func (rp *Ringpop) placeServerNoLock(change swim.Change) {
+	// set the points of a ramping or weighted member before it is added, so
+	// it never owns more than its share of the keyspace
+	weight := rp.labelWeight(change.Address, change.Labels)
+	points := rp.memberPoints(change.Ramp, weight)
+	rp.ring.SetServerPoints(change.Address, points)
+	rp.updateWeightNoLock(change.Address, weight, points)
+
+	// members that declared an identity are placed by it, so their keys stay
+	// with them when their address changes
+	rp.placeByIdentity(change.Address, change.Annotations[swim.IdentityAnnotation])
+
+	// labels do not place the member, but are covered by hashring.ChecksumV2
+	rp.ring.SetServerLabels(change.Address, change.Labels)
+}
+
+// placeByIdentity sets the identity a member is placed on the ring by. A
+// member that comes back with a new address takes over the replica points of
+// its identity, so its previous address is removed from the ring first.
+func (rp *Ringpop) placeByIdentity(address, identity string) {
+	rings := []*hashring.HashRing{rp.ring}
+	if rp.shadow != nil {
+		rings = append(rings, rp.shadow.ring)
+	}
+
+	for _, ring := range rings {
+		if identity != "" {
+			if previous, ok := ring.IdentityServer(identity); ok && previous != address {
+				ring.RemoveServer(previous)
+			}
+		}
+		ring.SetServerIdentity(address, identity)
+	}
 }
 
 //= = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = =
@@ -546,53 +1248,109 @@ func (rp *Ringpop) handleChanges(changes []swim.Change) {
 // Checksum returns the current checksum of this Ringpop instance's hashring.
 func (rp *Ringpop) Checksum() (uint32, error) {
 	if !rp.Ready() {
-		return 0, ErrNotBootstrapped
+		return 0, rp.errNotReady()
 	}
 	return rp.ring.Checksum(), nil
 }
 
-// Lookup returns the address of the server in the ring that is responsible
-// for the specified key. It returns an error if the Ringpop instance is not
-// yet initialized/bootstrapped.
-func (rp *Ringpop) Lookup(key string) (string, error) {
+// Checksums returns every version of the checksum of this Ringpop instance's
+// hashring, by the name of the version. See hashring.Checksummers.
+func (rp *Ringpop) Checksums() (map[string]uint32, error) {
 	if !rp.Ready() {
-		return "", ErrNotBootstrapped
+		return nil, rp.errNotReady()
 	}
+	return rp.ring.Checksums(), nil
+}
 
-	startTime := time.Now()
+// Lookup returns the address of the server in the ring that is responsible
+// for the specified key. It returns an error if the Ringpop instance is not
+// yet initialized/bootstrapped. When the server is suspected to have failed,
+// the policy set with the UnavailableOwner option applies, see
+// LookupWithPolicy.
+func (rp *Ringpop) Lookup(key string) (string, error) {
+	return rp.LookupWithPolicy(key, rp.config.UnavailableOwner)
+}
 
-	dest, success := rp.ring.Lookup(key)
+// LookupN returns the addresses of all the servers in the ring that are
+// responsible for the specified key, in the order set with the LookupNOrder
+// option. It returns an error if the Ringpop instance is not yet
+// initialized/bootstrapped.
+func (rp *Ringpop) LookupN(key string, n int) ([]string, error) {
+	return rp.LookupNWithOrder(key, n, rp.config.LookupNOrder)
+}
 
-	rp.emit(events.LookupEvent{key, time.Now().Sub(startTime)})
+// DialAddress returns the address to connect to the member at address at,
+// which differs from address when the member advertises additional addresses
+// and the AddressSelector picks one of them. It is used by the forwarder and
+// can be used by applications that contact members directly.
+func (rp *Ringpop) DialAddress(address string) string {
+	if !rp.Ready() {
+		return address
+	}
+	return rp.node.DialAddress(address)
+}
 
-	if !success {
-		err := errors.New("could not find destination for key")
-		rp.logger.WithField("key", key).Warn(err)
-		return "", err
+// PeerFeatures returns the features negotiated with the member at address,
+// the protocol and application features both instances support. Ok is false
+// when this instance has not exchanged a join or ping with the member yet.
+func (rp *Ringpop) PeerFeatures(address string) (features swim.Features, ok bool) {
+	if !rp.Ready() {
+		return 0, false
 	}
+	return rp.node.PeerFeatures(address)
+}
 
-	return dest, nil
+// ClockSkew returns the estimated offset of the clock of the member at address
+// from the local clock, estimated from the time the member reports in ping
+// responses. Ok is false when this instance has not completed a ping with the
+// member yet.
+func (rp *Ringpop) ClockSkew(address string) (skew swim.ClockSkew, ok bool) {
+	if !rp.Ready() {
+		return swim.ClockSkew{}, false
+	}
+	return rp.node.ClockSkew(address)
 }
 
-// LookupN returns the addresses of all the servers in the ring that are
-// responsible for the specified key. It returns an error if the Ringpop
-// instance is not yet initialized/bootstrapped.
-func (rp *Ringpop) LookupN(key string, n int) ([]string, error) {
+// SimulateRingChange computes which part of the keyspace, or of the keys in
+// the simulation, would change owners if the servers in the simulation were
+// added to or removed from the ring, or weighted differently. The ring itself
+// is not changed.
+func (rp *Ringpop) SimulateRingChange(sim hashring.Simulation) (hashring.SimulationResult, error) {
 	if !rp.Ready() {
-		return nil, ErrNotBootstrapped
+		return hashring.SimulationResult{}, rp.errNotReady()
 	}
-	return rp.ring.LookupN(key, n), nil
+	return rp.ring.Simulate(sim), nil
 }
 
 func (rp *Ringpop) ringEvent(e interface{}) {
 	rp.HandleEvent(e)
 }
 
+// Snapshot exports the gossip state of this Ringpop instance. It can be
+// restored into an instance of another cluster with Restore, to migrate a
+// cluster to a new identity while keeping its membership view.
+func (rp *Ringpop) Snapshot() (*swim.Snapshot, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+	return rp.node.Snapshot(), nil
+}
+
+// Restore imports the members of a snapshot taken with Snapshot, possibly on
+// a cluster with a different app name. The ring is updated with the restored
+// members.
+func (rp *Ringpop) Restore(snapshot *swim.Snapshot) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+	return rp.node.Restore(snapshot)
+}
+
 // GetReachableMembers returns a slice of members currently in this instance's
 // membership list that aren't faulty.
 func (rp *Ringpop) GetReachableMembers() ([]string, error) {
 	if !rp.Ready() {
-		return nil, ErrNotBootstrapped
+		return nil, rp.errNotReady()
 	}
 	return rp.node.GetReachableMembers(), nil
 }
@@ -601,7 +1359,7 @@ func (rp *Ringpop) GetReachableMembers() ([]string, error) {
 // instance's membership list that aren't faulty.
 func (rp *Ringpop) CountReachableMembers() (int, error) {
 	if !rp.Ready() {
-		return 0, ErrNotBootstrapped
+		return 0, rp.errNotReady()
 	}
 	return rp.node.CountReachableMembers(), nil
 }
@@ -633,15 +1391,51 @@ func (rp *Ringpop) getStatKey(key string) string {
 // HandleOrForward returns true if the request should be handled locally, or false
 // if it should be forwarded to a different node. If false is returned, forwarding
 // is taken care of internally by the method, and, if no error has occured, the
-// response is written in the provided response field.
+// response is written in the provided response field. If the key is empty and a
+// forward.Codec is registered for the endpoint, the keys are extracted from the
+// request with it, and the request is routed by the first of them.
 func (rp *Ringpop) HandleOrForward(key string, request []byte, response *[]byte, service, endpoint string,
 	format tchannel.Format, opts *forward.Options) (bool, error) {
 
+	return rp.HandleOrForwardContext(context.Background(), key, request, response, service, endpoint, format, opts)
+}
+
+// HandleOrForwardContext is HandleOrForward for a request made in the given
+// context. A forwarded request is abandoned when the context is cancelled,
+// and the context is passed on as described for ForwardContext.
+func (rp *Ringpop) HandleOrForwardContext(ctx context.Context, key string, request []byte, response *[]byte,
+	service, endpoint string, format tchannel.Format, opts *forward.Options) (bool, error) {
+
 	if !rp.Ready() {
-		return false, ErrNotBootstrapped
+		return false, rp.errNotReady()
+	}
+
+	keys := []string{key}
+	if key == "" {
+		codecKeys, err := rp.forwarder.RequestKeys(request, service, endpoint)
+		switch err {
+		case nil:
+			keys = codecKeys
+		case forward.ErrNoCodec:
+			// without a codec the empty key is looked up like any other
+		default:
+			return false, err
+		}
+	}
+
+	return rp.handleOrForwardKeys(ctx, keys, request, response, service, endpoint, format, opts)
+}
+
+// handleOrForwardKeys is HandleOrForward for a request that is routed by the
+// first of the given keys.
+func (rp *Ringpop) handleOrForwardKeys(ctx context.Context, keys []string, request []byte, response *[]byte, service, endpoint string,
+	format tchannel.Format, opts *forward.Options) (bool, error) {
+
+	if len(keys) == 0 {
+		return false, ErrNoKeys
 	}
 
-	dest, err := rp.Lookup(key)
+	dest, err := rp.Lookup(keys[0])
 	if err != nil {
 		return false, err
 	}
@@ -655,7 +1449,7 @@ func (rp *Ringpop) HandleOrForward(key string, request []byte, response *[]byte,
 		return true, nil
 	}
 
-	res, err := rp.Forward(dest, []string{key}, request, service, endpoint, format, opts)
+	res, err := rp.ForwardContext(ctx, dest, keys, request, service, endpoint, format, opts)
 	*response = res
 
 	return false, err
@@ -665,7 +1459,59 @@ func (rp *Ringpop) HandleOrForward(key string, request []byte, response *[]byte,
 func (rp *Ringpop) Forward(dest string, keys []string, request []byte, service, endpoint string,
 	format tchannel.Format, opts *forward.Options) ([]byte, error) {
 
-	return rp.forwarder.ForwardRequest(request, dest, service, endpoint, keys, format, opts)
+	return rp.ForwardContext(context.Background(), dest, keys, request, service, endpoint, format, opts)
+}
+
+// ForwardContext is Forward for a request made in the given context. The
+// request is abandoned when the context is cancelled, its timeout is capped by
+// the deadline of the context, and the context is passed to the
+// forward.EndpointOptions ContextValidator of the endpoint and to the
+// registered listeners that implement events.ContextEventListener.
+func (rp *Ringpop) ForwardContext(ctx context.Context, dest string, keys []string, request []byte,
+	service, endpoint string, format tchannel.Format, opts *forward.Options) ([]byte, error) {
+
+	return rp.forwarder.ForwardRequestContext(ctx, request, dest, service, endpoint, keys, format, opts)
+}
+
+// ForwardStream is ForwardContext for requests and responses too large to be
+// held in memory. The body of the request is read from request and the body
+// of the response is written to response while they are transferred. Streamed
+// requests are not retried, see forward.Forwarder.ForwardStream.
+func (rp *Ringpop) ForwardStream(ctx context.Context, dest string, keys []string, request io.Reader, response io.Writer,
+	service, endpoint string, format tchannel.Format, opts *forward.Options) error {
+
+	return rp.forwarder.ForwardStream(ctx, request, response, dest, service, endpoint, keys, format, opts)
+}
+
+// ForwardBatch forwards many small requests, each routed by its key, with a
+// single call to every owner of their keys. The endpoint unpacks the batches
+// with forward.BatchHandler. See forward.Forwarder.ForwardBatch.
+func (rp *Ringpop) ForwardBatch(ctx context.Context, items []forward.BatchItem, service, endpoint string,
+	opts *forward.Options) []forward.BatchResult {
+
+	return rp.forwarder.ForwardBatch(ctx, items, service, endpoint, opts)
+}
+
+// WrapHandler wraps a raw handler of requests that may be forwarded by other
+// members so that its panics are recovered and reported to the caller as
+// errors with a correlation ID, and the errors it returns are mapped to
+// TChannel error codes with the mappings registered with ForwardErrorMapping.
+// With the RingProofs option, its successful responses carry the ring proof.
+// See forward.WrapHandler. The handler can be wrapped before bootstrapping.
+//
+// Example:
+//
+//     ch.Register(raw.Wrap(rp.WrapHandler(handler)), "get")
+func (rp *Ringpop) WrapHandler(handler raw.Handler) raw.Handler {
+	opts := &forward.HandlerOptions{
+		ErrorMappings: rp.config.ForwardErrorMappings,
+		Listener:      rp,
+		Logger:        rp.logger,
+	}
+	if rp.config.RingProofs {
+		opts.RingProof = rp.RingProof
+	}
+	return forward.WrapHandler(handler, opts)
 }
 
 // SerializeThrift takes a thrift struct and returns the serialized bytes
@@ -21,17 +21,27 @@
 package ringpop
 
 import (
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/dgryski/go-farm"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"github.com/gl-works/ringpop-go/events"
 	"github.com/gl-works/ringpop-go/forward"
+	"github.com/gl-works/ringpop-go/hashring"
 	"github.com/gl-works/ringpop-go/swim"
 	"github.com/gl-works/ringpop-go/test/mocks"
 	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
 )
 
 type RingpopTestSuite struct {
@@ -127,6 +137,125 @@ func (s *RingpopTestSuite) TestHandleEvents() {
 	listener := &dummyListener{}
 	s.ringpop.RegisterListener(listener)
 
+	s.ringpop.HandleEvent(events.MemberWeightChangedEvent{Member: "127.0.0.1:3002", OldWeight: 1, Weight: 2, Points: 200})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.member.weight-changed"], "missing member.weight-changed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.SelfEvictedEvent{Notified: 2, Duration: time.Second})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.self-evicted"], "missing self-evicted stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.LookupNSpillEvent{Key: "key", Primary: "127.0.0.1:3002", Load: 0.9, Spilled: true})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.lookupN.spilled"], "missing lookupN.spilled stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.ShadowLookupEvent{Key: "key", Owner: "127.0.0.1:3001", ShadowOwner: "127.0.0.1:3002"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.shadow.lookup.diverged"], "missing shadow.lookup.diverged stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.RingCutoverEvent{Epoch: 1, Member: "127.0.0.1:3002"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.ring.cutover"], "missing ring.cutover stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.LookupOverriddenEvent{Key: "key", Member: "127.0.0.1:3002"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.lookup.overridden"], "missing lookup.overridden stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.RoutingOverridesReloadedEvent{Keys: 2, Prefixes: 1})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.overrides.reloaded"], "missing overrides.reloaded stat")
+	s.Equal(int64(3), stats.vals["ringpop.127_0_0_1_3001.overrides.pins"], "missing overrides.pins stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.RoutingOverridesFailedEvent{Error: "invalid"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.overrides.failed"], "missing overrides.failed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.SuspicionConfirmedEvent{Address: "127.0.0.1:3002", Confirmations: 2})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.suspicion.confirmed"], "missing suspicion.confirmed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.DuplicateAddressEvent{Address: "127.0.0.1:3002", Switches: 3})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.duplicate-address"], "missing duplicate-address stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.DuplicateIdentityEvent{Address: "127.0.0.1:3001"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.duplicate-identity"], "missing duplicate-identity stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.MemberBlacklistedEvent{Member: "127.0.0.1:3002", TTL: time.Minute})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.blacklist.added"], "missing blacklist.added stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.MemberUnblacklistedEvent{Member: "127.0.0.1:3002", Expired: true})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.blacklist.removed"], "missing blacklist.removed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.BulkUpdateEvent{
+		Evicted: []string{"127.0.0.1:3002"},
+	})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.membership.bulk-update"], "missing membership.bulk-update stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.SubsystemToggledEvent{Subsystem: swim.SubsystemSuspicion})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.subsystem.toggled"], "missing subsystem.toggled stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.JoinThrottledEvent{Source: "127.0.0.1:3002", Limit: 2})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.join.throttled"], "missing join.throttled stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.JoinStormDetectedEvent{Limit: 2})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.join.storm"], "missing join.storm stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.PartitionDetectedEvent{Target: "127.0.0.1:3002", LocalMembers: 2, RemoteMembers: 2})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.partition.detected"], "missing partition.detected stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.PartitionHealedEvent{Target: "127.0.0.1:3002", Duration: time.Second})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.partition.healed"], "missing partition.healed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.LabelsRestoredEvent{Labels: map[string]string{"drain": "true"}})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.labels.restored"], "missing labels.restored stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.AntiEntropySyncEvent{Peer: "127.0.0.1:3002", Pulled: 2, Pushed: 3})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.anti-entropy.mismatch"], "missing anti-entropy.mismatch stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.AntiEntropySyncFailedEvent{Peer: "127.0.0.1:3002", Error: "timeout"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.anti-entropy.failed"], "missing anti-entropy.failed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.MemberTombstonedEvent{Address: "127.0.0.1:3002", Incarnation: 1})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.reaper.tombstoned"], "missing reaper.tombstoned stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.MemberReapedEvent{Address: "127.0.0.1:3002", Incarnation: 1})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.reaper.reaped"], "missing reaper.reaped stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.ChecksumRemediationEvent{Peer: "127.0.0.1:3002", Action: swim.RemediateMerge})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.remediation.merge"], "missing remediation.merge stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.ChecksumConvergedEvent{Peer: "127.0.0.1:3002", Mismatches: 6})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.remediation.converged"], "missing remediation.converged stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.MeshReadinessChangedEvent{Ready: true})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.mesh.ready"], "missing mesh.ready stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.LabelsChangedEvent{Labels: map[string]string{"canary": "true"}})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.labels.changed"], "missing labels.changed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.LocalHealthChangedEvent{Score: 3})
+	s.Equal(int64(3), stats.vals["ringpop.127_0_0_1_3001.local-health"], "missing local-health stat")
+	// expected listener to record 1 event
+
 	s.ringpop.HandleEvent(swim.MemberlistChangesAppliedEvent{
 		Changes: genChanges(genAddresses(1, 1, 10), swim.Alive),
 	})
@@ -211,6 +340,10 @@ func (s *RingpopTestSuite) TestHandleEvents() {
 	s.Equal(int64(2), stats.vals["ringpop.127_0_0_1_3001.join.retries"], "join tries didn't update")
 	// expected listener to record 1 event
 
+	s.ringpop.HandleEvent(swim.JoinRetriedEvent{Attempt: 2, Delay: time.Second})
+	s.Equal(int64(1000), stats.vals["ringpop.127_0_0_1_3001.join.retried"], "missing stats for join retries")
+	// expected listener to record 1 event
+
 	s.ringpop.HandleEvent(events.LookupEvent{
 		Key:      "hello",
 		Duration: time.Second,
@@ -256,14 +389,17 @@ func (s *RingpopTestSuite) TestHandleEvents() {
 
 	// double check the counts before the event
 	s.Equal(int64(10), stats.vals["ringpop.127_0_0_1_3001.ring.server-added"], "incorrect count for ring.server-added before RingChangedEvent")
-	s.Equal(int64(2), stats.vals["ringpop.127_0_0_1_3001.ring.server-removed"], "incorrect count for ring.server-removed before RingChangedEvent")
+	// the faulty and leave changes of the same member remove it only once
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.ring.server-removed"], "incorrect count for ring.server-removed before RingChangedEvent")
 	s.Equal(int64(2), stats.vals["ringpop.127_0_0_1_3001.ring.changed"], "incorrect count for ring.changed before RingChangedEvent")
 	s.ringpop.HandleEvent(events.RingChangedEvent{
 		ServersAdded:   genAddresses(1, 2, 5),
 		ServersRemoved: genAddresses(1, 6, 8),
+		ServersUpdated: []events.ServerUpdate{{Server: "127.0.0.1:3002"}},
 	})
 	s.Equal(int64(14), stats.vals["ringpop.127_0_0_1_3001.ring.server-added"], "missing ring.server-added stat")
-	s.Equal(int64(5), stats.vals["ringpop.127_0_0_1_3001.ring.server-removed"], "missing ring.server-removed stat")
+	s.Equal(int64(4), stats.vals["ringpop.127_0_0_1_3001.ring.server-removed"], "missing ring.server-removed stat")
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.ring.server-updated"], "missing ring.server-updated stat")
 	s.Equal(int64(3), stats.vals["ringpop.127_0_0_1_3001.ring.changed"], "missing ring.changed stat")
 
 	s.ringpop.HandleEvent(forward.RequestForwardedEvent{})
@@ -321,8 +457,159 @@ func (s *RingpopTestSuite) TestHandleEvents() {
 	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.requestProxy.retry.succeeded"], "missing requestProxy.retry.reroute.remote stat")
 	// expected listener to record 1 event
 
-	time.Sleep(time.Millisecond) // sleep for a bit so that events can be recorded
-	s.Equal(42, listener.EventCount(), "incorrect count for emitted events")
+	s.ringpop.HandleEvent(forward.BytesForwardedEvent{Endpoint: "/ping", RequestBytes: 10, ResponseBytes: 20})
+	s.Equal(int64(10), stats.vals["ringpop.127_0_0_1_3001.requestProxy.egress.bytes.ping"], "missing requestProxy.egress.bytes stat")
+	s.Equal(int64(20), stats.vals["ringpop.127_0_0_1_3001.requestProxy.ingress.bytes.ping"], "missing requestProxy.ingress.bytes stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(forward.BytesForwardedEvent{Endpoint: "KeyValue::get.v2", RequestBytes: 10})
+	s.Equal(int64(10), stats.vals["ringpop.127_0_0_1_3001.requestProxy.egress.bytes.KeyValue__get_v2"], "expected endpoint to be sanitized")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.PushbackReceivedEvent{Remote: "127.0.0.1:3002", RetryAfter: time.Second})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.pushback.recv"], "missing pushback.recv stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(forward.PushbackReceivedEvent{Destination: "127.0.0.1:3002", RetryAfter: time.Second})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.requestProxy.pushback.received"], "missing requestProxy.pushback.received stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.KeyLockLostEvent{Key: "key", NewOwner: "127.0.0.1:3002"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.keylock.lost"], "missing keylock.lost stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.SuspectTTLExpiredEvent{Address: "127.0.0.1:3002", Duration: time.Minute})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.suspect-ttl-expired"], "missing suspect-ttl-expired stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(forward.BytesForwardedEvent{Endpoint: "/codec", RequestBytes: 10, ResponseBytes: 20, Codec: "json", Compressible: true})
+	s.Equal(int64(10), stats.vals["ringpop.127_0_0_1_3001.requestProxy.codec.json.egress.bytes"], "missing requestProxy.codec.egress.bytes stat")
+	s.Equal(int64(20), stats.vals["ringpop.127_0_0_1_3001.requestProxy.codec.json.ingress.bytes"], "missing requestProxy.codec.ingress.bytes stat")
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.requestProxy.codec.json.compressible"], "missing requestProxy.codec.compressible stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.RampChangedEvent{Ramp: 30})
+	s.Equal(int64(30), stats.vals["ringpop.127_0_0_1_3001.ramp"], "missing ramp stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.ProtocolStalledEvent{Duration: time.Second})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.watchdog.stalled"], "missing watchdog.stalled stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.TransportFailureReportedEvent{Remote: "127.0.0.1:3002"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.transport-failure.reported"], "missing transport-failure.reported stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.MessageAuthenticationFailedEvent{Endpoint: "/protocol/ping"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.protocol.unauthenticated"], "missing protocol.unauthenticated stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(forward.ConnectionFailedEvent{Destination: "127.0.0.1:3002"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.requestProxy.connection.failed"], "missing requestProxy.connection.failed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.SuspicionResolvedEvent{Address: "127.0.0.1:3002", Refuted: true})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.detector.refuted"], "missing detector.refuted stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.SuspicionResolvedEvent{Address: "127.0.0.1:3002"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.detector.confirmed"], "missing detector.confirmed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.FalsePositiveRateExceededEvent{Rate: 0.5, Threshold: 0.2})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.detector.false-positive-rate.exceeded"], "missing detector.false-positive-rate.exceeded stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.ChangesSpilledEvent{Count: 3})
+	s.Equal(int64(3), stats.vals["ringpop.127_0_0_1_3001.dissemination.spilled"], "missing dissemination.spilled stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.ChangesRestoredEvent{Count: 2})
+	s.Equal(int64(2), stats.vals["ringpop.127_0_0_1_3001.dissemination.restored"], "missing dissemination.restored stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(forward.HandlerPanicEvent{Endpoint: "/get", CorrelationID: "01"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.requestProxy.handler.panic"], "missing requestProxy.handler.panic stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(forward.ConcurrencyLimitedEvent{Destination: "127.0.0.1:3002"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.requestProxy.limit.rejected"], "missing requestProxy.limit.rejected stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(forward.RingDivergedEvent{Destination: "127.0.0.1:3002", Checksum: 1, Proof: forward.RingProof{Checksum: 2}})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.requestProxy.ring.diverged"], "missing requestProxy.ring.diverged stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(forward.DivergedRingDetectedEvent{Origin: "127.0.0.1:3002", Destination: "127.0.0.1:3002", Hops: 2})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.requestProxy.ring.loop"], "missing requestProxy.ring.loop stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.ReadyToJoinEvent{Waited: time.Second})
+	s.Equal(int64(1000), stats.vals["ringpop.127_0_0_1_3001.standby"], "missing standby stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.ClockSkewEstimatedEvent{Remote: "127.0.0.1:3002", Offset: -1500 * time.Millisecond})
+	s.Equal(int64(-1500), stats.vals["ringpop.127_0_0_1_3001.clock-skew.127_0_0_1_3002"], "missing clock-skew stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.ClockSkewExceededEvent{Remote: "127.0.0.1:3002"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.clock-skew.exceeded"], "missing clock-skew.exceeded stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.PartitionStartedEvent{Peers: []string{"127.0.0.1:3002"}, Duration: time.Minute})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.partition.started"], "missing partition.started stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.PartitionEndedEvent{})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.partition.ended"], "missing partition.ended stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.AdminDeniedEvent{Endpoint: "/admin/member/leave", Reason: "forbidden"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.admin.denied"], "missing admin.denied stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.ZoneUnreachableEvent{Zone: "us-east-1a", Members: 3})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.zone.unreachable"], "missing zone.unreachable stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(events.ZoneReachableEvent{Zone: "us-east-1a"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.zone.reachable"], "missing zone.reachable stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.FullSyncChunkEvent{Remote: "127.0.0.1:3002", Members: 10, Bytes: 1500})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.full-sync.chunk"], "missing full-sync.chunk stat")
+	s.Equal(int64(1500), stats.vals["ringpop.127_0_0_1_3001.full-sync.chunk.bytes"], "missing full-sync.chunk.bytes stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.MaintenanceDeferredEvent{Address: "127.0.0.1:3002", Confirmations: 1})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.maintenance.deferred"], "missing maintenance.deferred stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.FaultyVetoedEvent{Address: "127.0.0.1:3002", Reason: "primary"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.faulty.vetoed"], "missing faulty.vetoed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.ManifestImportedEvent{Members: 3})
+	s.Equal(int64(3), stats.vals["ringpop.127_0_0_1_3001.bootstrap.manifest-members"], "missing bootstrap.manifest-members stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.AnnotationsChangedEvent{Annotations: map[string]string{"maintenance": "until 5pm"}})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.annotations.changed"], "missing annotations.changed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.PingVetoedEvent{Source: "127.0.0.1:3002", Reason: "unhealthy"})
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.ping.vetoed"], "missing ping.vetoed stat")
+	// expected listener to record 1 event
+
+	s.ringpop.HandleEvent(swim.ChecksumVersionChangedEvent{OldVersion: swim.ChecksumV1, NewVersion: swim.ChecksumV2})
+	s.Equal(int64(2), stats.vals["ringpop.127_0_0_1_3001.membership.checksum-version"], "missing membership.checksum-version stat")
+	// expected listener to record 1 event
+
+	// events are emitted asynchronously, wait for a bit so they can be recorded
+	for i := 0; i < 100 && listener.EventCount() < 108; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	s.Equal(108, listener.EventCount(), "incorrect count for emitted events")
 }
 
 func (s *RingpopTestSuite) TestRingpopReady() {
@@ -339,6 +626,77 @@ func (s *RingpopTestSuite) TestRingpopNotReady() {
 	s.False(s.ringpop.Ready())
 }
 
+// TestJoinWhenReady tests that Ringpop waits in standby until the readiness
+// check passes and only then joins.
+func (s *RingpopTestSuite) TestJoinWhenReady() {
+	ch, _ := tchannel.NewChannel("test2", nil)
+	ch.ListenAndServe("127.0.0.1:0")
+	defer ch.Close()
+
+	var storageRecovered int32
+	rp, err := New("test2", Channel(ch), Clock(s.mockClock),
+		JoinWhenReady(func() bool {
+			return atomic.LoadInt32(&storageRecovered) == 1
+		}, time.Second))
+	s.NoError(err)
+	defer rp.Destroy()
+
+	done := make(chan error)
+	go func() {
+		_, err := rp.Bootstrap(&swim.BootstrapOptions{})
+		done <- err
+	}()
+
+	for !rp.Standby() {
+		time.Sleep(time.Millisecond)
+	}
+	s.False(rp.Ready())
+	_, err = rp.Checksum()
+	s.Equal(ErrStandby, err)
+
+	atomic.StoreInt32(&storageRecovered, 1)
+	for {
+		s.mockClock.Add(time.Second)
+		select {
+		case err := <-done:
+			s.NoError(err)
+			s.True(rp.Ready())
+			s.False(rp.Standby())
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// TestListenerPanic tests that a panicking listener is isolated from the other
+// listeners and deregistered at the panic limit.
+func (s *RingpopTestSuite) TestListenerPanic() {
+	rp, err := New("test", Identity("127.0.0.1:3001"), Channel(s.channel), ListenerPanicLimit(2))
+	s.Require().NoError(err)
+	rp.init()
+	defer rp.Destroy()
+
+	stats := newDummyStats()
+	rp.statter = stats
+
+	rp.RegisterNamedListener("buggy", swim.ListenerFunc(func(event events.Event) {
+		panic("listener bug")
+	}))
+	listener := &dummyListener{}
+	rp.RegisterListener(listener)
+
+	// emit synchronously to observe the panics deterministically
+	rp.listeners.Emit(events.KeyLockLostEvent{Key: "key"})
+	rp.listeners.Emit(events.KeyLockLostEvent{Key: "key"})
+
+	s.Equal(2, listener.EventCount())
+	s.Equal(int64(2), stats.vals["ringpop.127_0_0_1_3001.listener.panic"])
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.listener.deregistered"])
+
+	s.False(rp.DeregisterListener("buggy"), "expected listener to be deregistered already")
+	s.True(rp.DeregisterListener("*ringpop.dummyListener"))
+}
+
 // TestStateCreated tests that Ringpop is in a created state just after
 // instantiating.
 func (s *RingpopTestSuite) TestStateCreated() {
@@ -489,6 +847,793 @@ func (s *RingpopTestSuite) TestLookupNNotReady() {
 	s.Nil(result)
 }
 
+// TestSnapshotRestoreNotReady tests that Snapshot and Restore fail when
+// Ringpop is not ready.
+func (s *RingpopTestSuite) TestSnapshotRestoreNotReady() {
+	snapshot, err := s.ringpop.Snapshot()
+	s.Equal(ErrNotBootstrapped, err)
+	s.Nil(snapshot)
+
+	s.Equal(ErrNotBootstrapped, s.ringpop.Restore(&swim.Snapshot{}))
+}
+
+// TestSnapshotRestore tests that a snapshot can be restored into a ring.
+func (s *RingpopTestSuite) TestSnapshotRestore() {
+	createSingleNodeCluster(s.ringpop)
+
+	snapshot, err := s.ringpop.Snapshot()
+	s.Require().NoError(err)
+	snapshot.Members = append(snapshot.Members, swim.Change{
+		Address:     "127.0.0.1:3002",
+		Status:      swim.Alive,
+		Incarnation: 1,
+	})
+
+	s.NoError(s.ringpop.Restore(snapshot))
+	s.True(s.ringpop.ring.HasServer("127.0.0.1:3002"), "expected restored member to be added to the ring")
+}
+
+// TestLeaveRejoin tests that an instance that leaves is removed from its own
+// ring and added back when it rejoins.
+func (s *RingpopTestSuite) TestLeaveRejoin() {
+	s.Equal(ErrNotBootstrapped, s.ringpop.Leave())
+	s.Equal(ErrNotBootstrapped, s.ringpop.Rejoin())
+
+	createSingleNodeCluster(s.ringpop)
+	address, _ := s.ringpop.WhoAmI()
+	s.True(s.ringpop.ring.HasServer(address))
+
+	s.NoError(s.ringpop.Leave())
+	s.False(s.ringpop.ring.HasServer(address), "expected instance that left to be removed from the ring")
+
+	s.NoError(s.ringpop.Rejoin())
+	s.True(s.ringpop.ring.HasServer(address), "expected instance that rejoined to be in the ring")
+}
+
+func (s *RingpopTestSuite) TestSetDegradedNotReady() {
+	s.Equal(ErrNotBootstrapped, s.ringpop.SetDegraded(true))
+}
+
+// TestLookupSpill tests that lookups spill to the second owner of a key only
+// when its primary owner is degraded.
+func (s *RingpopTestSuite) TestLookupSpill() {
+	createSingleNodeCluster(s.ringpop)
+	s.ringpop.ring.AddServer("127.0.0.1:3002")
+
+	primary, err := s.ringpop.Lookup("key")
+	s.Require().NoError(err)
+
+	owner, err := s.ringpop.LookupSpill("key", 1)
+	s.NoError(err)
+	s.Equal(primary, owner, "expected healthy owner to keep its keys")
+
+	s.ringpop.trackHealth([]swim.Change{
+		{Address: primary, Status: swim.Alive, Health: swim.Degraded},
+	})
+	s.True(s.ringpop.Degraded(primary))
+
+	owner, err = s.ringpop.LookupSpill("key", 1)
+	s.NoError(err)
+	s.NotEqual(primary, owner, "expected lookup to spill to the second owner")
+
+	owner, err = s.ringpop.LookupSpill("key", 0)
+	s.NoError(err)
+	s.Equal(primary, owner, "expected no spill with a zero fraction")
+
+	s.ringpop.trackHealth([]swim.Change{
+		{Address: primary, Status: swim.Alive},
+	})
+	s.False(s.ringpop.Degraded(primary))
+}
+
+func (s *RingpopTestSuite) TestLookupWithPolicy() {
+	createSingleNodeCluster(s.ringpop)
+	s.ringpop.ring.AddServer("127.0.0.1:3002")
+
+	owner, err := s.ringpop.Lookup("key")
+	s.Require().NoError(err)
+
+	for _, policy := range []UnavailablePolicy{ReturnUnavailable, SkipUnavailable, RejectUnavailable} {
+		dest, err := s.ringpop.LookupWithPolicy("key", policy)
+		s.NoError(err)
+		s.Equal(owner, dest, "expected available owner to be returned")
+	}
+
+	s.ringpop.ring.SuspectServer(owner)
+
+	dest, err := s.ringpop.LookupWithPolicy("key", ReturnUnavailable)
+	s.NoError(err)
+	s.Equal(owner, dest)
+
+	dest, err = s.ringpop.LookupWithPolicy("key", SkipUnavailable)
+	s.NoError(err)
+	s.NotEqual(owner, dest, "expected lookup to skip to the next owner")
+
+	_, err = s.ringpop.LookupWithPolicy("key", RejectUnavailable)
+	s.Equal(&OwnerUnavailableError{Key: "key", Owner: owner}, err)
+
+	// Lookup uses the default policy
+	s.ringpop.config.UnavailableOwner = SkipUnavailable
+	dest, err = s.ringpop.Lookup("key")
+	s.NoError(err)
+	s.NotEqual(owner, dest)
+}
+
+// TestLookupNWithOrder tests that LatencyOrder returns the same members as
+// LookupN, with the local member first and unhealthy members last.
+func (s *RingpopTestSuite) TestLookupNWithOrder() {
+	createSingleNodeCluster(s.ringpop)
+	s.ringpop.ring.AddServer("127.0.0.1:3002")
+	s.ringpop.ring.AddServer("127.0.0.1:3003")
+
+	local, err := s.ringpop.identity()
+	s.Require().NoError(err)
+
+	ringOrder, err := s.ringpop.LookupN("key", 3)
+	s.Require().NoError(err)
+
+	ordered, err := s.ringpop.LookupNWithOrder("key", 3, LatencyOrder)
+	s.NoError(err)
+	s.Len(ordered, 3)
+	s.Equal(local, ordered[0], "expected local member to be tried first")
+	sorted := append([]string(nil), ordered...)
+	sort.Strings(sorted)
+	sort.Strings(ringOrder)
+	s.Equal(ringOrder, sorted, "expected the same members")
+
+	s.ringpop.trackHealth([]swim.Change{
+		{Address: local, Status: swim.Alive, Health: swim.Degraded},
+	})
+	ordered, err = s.ringpop.LookupNWithOrder("key", 3, LatencyOrder)
+	s.NoError(err)
+	s.Equal(local, ordered[2], "expected degraded member to be tried last")
+
+	// members that rank the same keep the order they are looked up in
+	servers := []string{"127.0.0.1:3003", local, "127.0.0.1:3002"}
+	s.ringpop.orderByLatency(servers)
+	s.Equal([]string{"127.0.0.1:3003", "127.0.0.1:3002", local}, servers)
+
+	servers = []string{"127.0.0.1:3002", local, "127.0.0.1:3003"}
+	s.ringpop.orderByLatency(servers)
+	s.Equal([]string{"127.0.0.1:3002", "127.0.0.1:3003", local}, servers)
+
+	// LookupN uses the default order
+	s.ringpop.config.LookupNOrder = LatencyOrder
+	dest, err := s.ringpop.LookupN("key", 3)
+	s.NoError(err)
+	s.Len(dest, 3)
+	s.Equal(local, dest[2], "expected degraded member to be tried last")
+}
+
+// TestLookupNRingOrder tests that LookupN returns the owners of a key in the
+// order they own it on the ring, starting with the owner returned by Lookup.
+func (s *RingpopTestSuite) TestLookupNRingOrder() {
+	createSingleNodeCluster(s.ringpop)
+	s.ringpop.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive},
+		{Address: "127.0.0.1:3003", Status: swim.Alive},
+		{Address: "127.0.0.1:3004", Status: swim.Alive},
+	})
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner, err := s.ringpop.Lookup(key)
+		s.Require().NoError(err)
+
+		servers, err := s.ringpop.LookupN(key, 10)
+		s.Require().NoError(err)
+		s.Equal(s.ringpop.ring.Trace(key, 4).Owners, servers)
+		s.Equal(owner, servers[0], "expected owner of %s first", key)
+	}
+}
+
+// localHealth returns the health the local member gossips.
+func (s *RingpopTestSuite) localHealth() string {
+	snapshot, err := s.ringpop.Snapshot()
+	s.Require().NoError(err)
+	for _, member := range snapshot.Members {
+		if member.Address == snapshot.Source {
+			return member.Health
+		}
+	}
+	return ""
+}
+
+// TestMeshReadiness tests that the local member is degraded while the service
+// mesh sidecar is not ready.
+func (s *RingpopTestSuite) TestMeshReadiness() {
+	var ready int32 = 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&ready) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	s.ringpop.config.MeshReadiness = SidecarReadiness(server.URL, time.Second)
+	createSingleNodeCluster(s.ringpop)
+
+	stats := newDummyStats()
+	s.ringpop.statter = stats
+
+	s.ringpop.checkMeshReadiness()
+	s.Equal("", s.localHealth())
+	_, changed := stats.vals["ringpop.127_0_0_1_3001.mesh.ready"]
+	s.False(changed, "expected no readiness change")
+
+	atomic.StoreInt32(&ready, 0)
+	s.ringpop.checkMeshReadiness()
+	s.Equal(swim.Degraded, s.localHealth(), "expected local member to be degraded")
+	s.Equal(int64(0), stats.vals["ringpop.127_0_0_1_3001.mesh.ready"])
+
+	atomic.StoreInt32(&ready, 1)
+	s.ringpop.checkMeshReadiness()
+	s.Equal("", s.localHealth(), "expected local member to recover")
+	s.Equal(int64(1), stats.vals["ringpop.127_0_0_1_3001.mesh.ready"])
+
+	// recovering does not clear a degradation the sidecar did not cause
+	s.Require().NoError(s.ringpop.SetDegraded(true))
+	atomic.StoreInt32(&ready, 0)
+	s.ringpop.checkMeshReadiness()
+	atomic.StoreInt32(&ready, 1)
+	s.ringpop.checkMeshReadiness()
+	s.Equal(swim.Degraded, s.localHealth(), "expected local member to stay degraded")
+	s.Require().NoError(s.ringpop.SetDegraded(false))
+	s.Equal("", s.localHealth())
+
+	server.Close()
+	s.False(SidecarReadiness(server.URL, time.Second)(), "expected unreachable sidecar not to be ready")
+}
+
+func (s *RingpopTestSuite) TestPushbackExpires() {
+	// keep gossip stopped so that only the pushback timer runs on the clock
+	_, err := s.ringpop.Bootstrap(&swim.BootstrapOptions{
+		Hosts:   []string{"127.0.0.1:3001"},
+		Stopped: true,
+	})
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.ringpop.SetPushback(time.Second, 0.9))
+	s.Equal(swim.Degraded, s.localHealth(), "expected pushback to degrade the local member")
+
+	// a new signal replaces the window of the previous one
+	s.mockClock.Add(500 * time.Millisecond)
+	s.Require().NoError(s.ringpop.SetPushback(time.Second, 0.9))
+	s.mockClock.Add(600 * time.Millisecond)
+	s.Equal(swim.Degraded, s.localHealth(), "expected replaced window not to expire")
+
+	s.mockClock.Add(400 * time.Millisecond)
+	s.Equal("", s.localHealth(), "expected local member to recover once the window expired")
+
+	// an expired signal does not clear a degradation it did not cause
+	s.Require().NoError(s.ringpop.SetDegraded(true))
+	s.Require().NoError(s.ringpop.SetPushback(time.Second, 0.9))
+	s.mockClock.Add(time.Second)
+	s.Equal(swim.Degraded, s.localHealth(), "expected local member to stay degraded")
+}
+
+func (s *RingpopTestSuite) TestExplainLookup() {
+	_, err := s.ringpop.ExplainLookup("key")
+	s.Equal(ErrNotBootstrapped, err)
+
+	createSingleNodeCluster(s.ringpop)
+	s.ringpop.ring.AddServer("127.0.0.1:3002")
+	s.ringpop.ring.AddServer("127.0.0.1:3003")
+
+	owner, _ := s.ringpop.Lookup("key")
+	e, err := s.ringpop.ExplainLookup("key")
+	s.Require().NoError(err)
+	s.Equal([]string{owner}, e.Owners)
+	s.Equal(s.ringpop.ring.Checksum(), e.Ring.Checksum)
+	s.Equal("return", e.Policy)
+	s.Empty(e.Skipped)
+
+	s.ringpop.config.UnavailableOwner = SkipUnavailable
+	s.ringpop.ring.SuspectServer(owner)
+	standby, _ := s.ringpop.Lookup("key")
+	e, _ = s.ringpop.ExplainLookup("key")
+	s.Equal([]string{standby}, e.Owners)
+	s.Equal([]SkippedMember{{Address: owner, Reason: SkipSuspect}}, e.Skipped)
+
+	// like Lookup, the explanation fails when no standby is available
+	for _, server := range []string{"127.0.0.1:3001", "127.0.0.1:3002", "127.0.0.1:3003"} {
+		s.ringpop.ring.SuspectServer(server)
+	}
+	_, err = s.ringpop.Lookup("key")
+	e, _ = s.ringpop.ExplainLookup("key")
+	s.Empty(e.Owners)
+	s.Equal(err.Error(), e.Error)
+	for _, server := range []string{"127.0.0.1:3001", "127.0.0.1:3002", "127.0.0.1:3003"} {
+		if server != owner {
+			s.ringpop.ring.ClearSuspectServer(server)
+		}
+	}
+
+	s.ringpop.overrides.set(RoutingPins{Keys: map[string]string{"key": owner}}, time.Now())
+	e, _ = s.ringpop.ExplainLookupN("key", 2)
+	s.Equal("ring", e.Order)
+	s.Equal(owner, e.Override)
+	s.Equal(owner, e.Owners[0], "expected pinned member first")
+	s.Len(e.Owners, 2)
+
+	s.ringpop.overrides.set(RoutingPins{Keys: map[string]string{"key": "127.0.0.1:3009"}}, time.Now())
+	e, _ = s.ringpop.ExplainLookup("key")
+	s.Equal([]SkippedMember{
+		{Address: "127.0.0.1:3009", Reason: SkipNotInRing},
+		{Address: owner, Reason: SkipSuspect},
+	}, e.Skipped)
+}
+
+// TestMembersByAge tests that members are ordered oldest first, with members
+// of unknown age last.
+func (s *RingpopTestSuite) TestMembersByAge() {
+	_, err := s.ringpop.MembersByAge()
+	s.Equal(ErrNotBootstrapped, err)
+	_, ok := s.ringpop.MemberUptime("127.0.0.1:3001")
+	s.False(ok)
+
+	createSingleNodeCluster(s.ringpop)
+	s.ringpop.node = s.mockSwimNode
+	s.mockSwimNode.On("Ready").Return(true)
+
+	now := time.Now()
+	s.mockSwimNode.On("GetReachableMembers").Return([]string{
+		"127.0.0.1:3001", "127.0.0.1:3002", "127.0.0.1:3003", "127.0.0.1:3004",
+	})
+	s.mockSwimNode.On("JoinedAt", "127.0.0.1:3001").Return(now, true)
+	s.mockSwimNode.On("JoinedAt", "127.0.0.1:3002").Return(time.Time{}, false)
+	s.mockSwimNode.On("JoinedAt", "127.0.0.1:3003").Return(now.Add(-time.Hour), true)
+	s.mockSwimNode.On("JoinedAt", "127.0.0.1:3004").Return(now, true)
+	s.mockSwimNode.On("MemberUptime", "127.0.0.1:3003").Return(time.Hour, true)
+
+	members, err := s.ringpop.MembersByAge()
+	s.NoError(err)
+	s.Equal([]string{"127.0.0.1:3003", "127.0.0.1:3001", "127.0.0.1:3004", "127.0.0.1:3002"}, members)
+
+	uptime, ok := s.ringpop.MemberUptime("127.0.0.1:3003")
+	s.True(ok)
+	s.Equal(time.Hour, uptime)
+}
+
+// TestAdvise tests that the advisor recommends adding capacity to overloaded
+// zones, adjusting the weight of overloaded members and increasing the
+// replica points of an imbalanced ring.
+func (s *RingpopTestSuite) TestAdvise() {
+	_, err := s.ringpop.Advise()
+	s.Equal(ErrNotBootstrapped, err)
+
+	createSingleNodeCluster(s.ringpop)
+	s.ringpop.node = s.mockSwimNode
+	s.mockSwimNode.On("Ready").Return(true)
+	s.ringpop.config.Advisor.ImbalanceThreshold = 10
+
+	zones := map[string]string{
+		"127.0.0.1:3001": "a",
+		"127.0.0.1:3002": "a",
+		"127.0.0.1:3003": "b",
+		"127.0.0.1:3004": "b",
+	}
+	for server, zone := range zones {
+		s.ringpop.ring.AddServer(server)
+		s.mockSwimNode.On("Annotations", server).Return(map[string]string{"zone": zone}, true)
+	}
+
+	s.ringpop.HandleEvent(swim.PushbackReceivedEvent{Remote: "127.0.0.1:3002", Load: 0.95})
+	s.ringpop.HandleEvent(swim.PushbackReceivedEvent{Remote: "127.0.0.1:3003", Load: 0.9})
+	s.ringpop.HandleEvent(swim.PushbackReceivedEvent{Remote: "127.0.0.1:3004", Load: 0.9})
+
+	advice, err := s.ringpop.Advise()
+	s.Require().NoError(err)
+	s.Len(advice.Ownership, 4)
+	s.Len(advice.Loads, 3)
+	s.True(advice.Imbalance >= 1, "expected some member to own at least its fair share")
+
+	if s.Len(advice.Recommendations, 2) {
+		s.Equal(AdjustWeight, advice.Recommendations[0].Kind)
+		s.Equal("127.0.0.1:3002", advice.Recommendations[0].Member)
+		s.Equal(84, advice.Recommendations[0].Value)
+		s.Equal(AddCapacity, advice.Recommendations[1].Kind)
+		s.Equal("b", advice.Recommendations[1].Zone)
+	}
+
+	// load samples expire, and a strict threshold flags the ring itself
+	s.mockClock.Add(2 * time.Minute)
+	s.ringpop.config.Advisor.ImbalanceThreshold = 1.01
+
+	advice, err = s.ringpop.Advise()
+	s.Require().NoError(err)
+	s.Empty(advice.Loads)
+	if s.Len(advice.Recommendations, 1) {
+		s.Equal(IncreaseReplicaPoints, advice.Recommendations[0].Kind)
+		s.Equal(90000, advice.Recommendations[0].Value)
+	}
+}
+
+// TestZones tests that members are grouped by zone and that an event is
+// emitted when all members of a zone become unreachable.
+func (s *RingpopTestSuite) TestZones() {
+	_, err := s.ringpop.Zones()
+	s.Equal(ErrNotBootstrapped, err)
+
+	createSingleNodeCluster(s.ringpop)
+	listener := &zoneListener{}
+	s.ringpop.RegisterListener(listener)
+
+	member := func(address, zone, status string) swim.Change {
+		return swim.Change{
+			Address:     address,
+			Status:      status,
+			Annotations: map[string]string{"zone": zone},
+		}
+	}
+	s.ringpop.handleChanges([]swim.Change{
+		member("127.0.0.1:3002", "a", swim.Alive),
+		member("127.0.0.1:3003", "a", swim.Suspect),
+		member("127.0.0.1:3004", "b", swim.Alive),
+	})
+
+	zones, err := s.ringpop.Zones()
+	s.Require().NoError(err)
+	s.Require().Len(zones, 3)
+	s.Equal("", zones[0].Name, "expected member without zone in the unnamed zone")
+	s.Equal("a", zones[1].Name)
+	s.Equal(map[string]int{swim.Alive: 1, swim.Suspect: 1}, zones[1].Statuses)
+	s.Equal(2, zones[1].Reachable)
+	s.Equal(1.0, zones[1].ReachableFraction)
+	s.Equal("127.0.0.1:3002", zones[1].Members[0].Address)
+
+	s.ringpop.handleChanges([]swim.Change{member("127.0.0.1:3002", "a", swim.Faulty)})
+	zones, _ = s.ringpop.Zones()
+	s.Equal(0.5, zones[1].ReachableFraction)
+
+	s.ringpop.handleChanges([]swim.Change{member("127.0.0.1:3004", "b", swim.Faulty)})
+	s.ringpop.handleChanges([]swim.Change{member("127.0.0.1:3004", "b", swim.Alive)})
+	s.ringpop.handleChanges([]swim.Change{member("127.0.0.1:3004", "b", swim.Leave)})
+
+	recorded := listener.wait(2)
+	s.Len(recorded, 2, "expected a zone that was left not to be unreachable")
+	s.Contains(recorded, events.ZoneUnreachableEvent{Zone: "b", Members: 1})
+	s.Contains(recorded, events.ZoneReachableEvent{Zone: "b"})
+}
+
+// zoneListener records the zone events emitted by Ringpop.
+type zoneListener struct {
+	events []events.Event
+	sync.Mutex
+}
+
+func (l *zoneListener) HandleEvent(event events.Event) {
+	switch event.(type) {
+	case events.ZoneUnreachableEvent, events.ZoneReachableEvent:
+		l.Lock()
+		l.events = append(l.events, event)
+		l.Unlock()
+	}
+}
+
+// wait waits for a bit until n events are recorded, and returns the events.
+func (l *zoneListener) wait(n int) []events.Event {
+	for i := 0; i < 100; i++ {
+		l.Lock()
+		recorded := l.events
+		l.Unlock()
+		if len(recorded) >= n {
+			return recorded
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	return l.events
+}
+
+// TestHandleOrForwardCodecKeys tests that HandleOrForward routes requests
+// without a key by the keys the codec of the endpoint extracts.
+func (s *RingpopTestSuite) TestHandleOrForwardCodecKeys() {
+	createSingleNodeCluster(s.ringpop)
+	s.ringpop.forwarder.RegisterEndpoint("test", "/json", &forward.EndpointOptions{
+		Codec: forward.JSONCodec{KeyField: "key"},
+	})
+
+	var res []byte
+	handle, err := s.ringpop.HandleOrForward("", []byte(`{"key":"a"}`), &res, "test", "/json", tchannel.JSON, nil)
+	s.NoError(err)
+	s.True(handle, "expected request to be handled locally")
+
+	_, err = s.ringpop.HandleOrForward("", []byte(`{"key":[]}`), &res, "test", "/json", tchannel.JSON, nil)
+	s.Equal(ErrNoKeys, err)
+
+	handle, err = s.ringpop.HandleOrForward("", []byte(`{}`), &res, "test", "/raw", tchannel.JSON, nil)
+	s.NoError(err, "expected the empty key to be looked up without a codec")
+	s.True(handle, "expected request to be handled locally")
+}
+
+func (s *RingpopTestSuite) TestSimulateRingChange() {
+	_, err := s.ringpop.SimulateRingChange(hashring.Simulation{})
+	s.Equal(ErrNotBootstrapped, err)
+
+	createSingleNodeCluster(s.ringpop)
+
+	result, err := s.ringpop.SimulateRingChange(hashring.Simulation{
+		Add: []string{"127.0.0.1:3002"},
+	})
+	s.NoError(err)
+	s.True(result.Moved > 0, "expected new server to take over keys")
+	s.False(s.ringpop.ring.HasServer("127.0.0.1:3002"), "expected simulation not to change the ring")
+}
+
+// TestRampingMember tests that members are placed on the ring with the share
+// of their replica points they gossip while ramping in.
+func (s *RingpopTestSuite) TestRampingMember() {
+	createSingleNodeCluster(s.ringpop)
+	points := s.ringpop.configHashRing.ReplicaPoints
+
+	s.ringpop.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive, Ramp: 20},
+	})
+	s.Equal(points/5, s.ringpop.ring.ServerPoints("127.0.0.1:3002"))
+
+	s.ringpop.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive},
+	})
+	s.Equal(points, s.ringpop.ring.ServerPoints("127.0.0.1:3002"), "expected full points after ramping in")
+
+	s.Equal(1, s.ringpop.memberPoints(1, 1), "expected a ramping member to have at least one point")
+}
+
+// TestMemberIdentity tests that members are placed on the ring by the identity
+// they gossip, and keep their keys when they come back with a new address.
+func (s *RingpopTestSuite) TestMemberIdentity() {
+	createSingleNodeCluster(s.ringpop)
+	identity := map[string]string{swim.IdentityAnnotation: "node-2"}
+
+	s.ringpop.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive, Annotations: identity},
+	})
+	s.Equal("node-2", s.ringpop.ring.ServerIdentity("127.0.0.1:3002"))
+
+	var keys []string
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if owner, _ := s.ringpop.Lookup(key); owner == "127.0.0.1:3002" {
+			keys = append(keys, key)
+		}
+	}
+	s.NotEmpty(keys)
+
+	// the member is rescheduled to a new address before its old address is
+	// declared faulty
+	s.ringpop.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3003", Status: swim.Alive, Annotations: identity},
+	})
+	s.False(s.ringpop.ring.HasServer("127.0.0.1:3002"), "expected the old address to be replaced")
+	for _, key := range keys {
+		owner, _ := s.ringpop.Lookup(key)
+		s.Equal("127.0.0.1:3003", owner, "expected key %s to move with the identity", key)
+	}
+
+	s.ringpop.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Faulty, Annotations: identity},
+	})
+	s.True(s.ringpop.ring.HasServer("127.0.0.1:3003"))
+	s.Equal("node-2", s.ringpop.ring.ServerIdentity("127.0.0.1:3003"))
+}
+
+// TestHashFunc64 tests that the ring hashes keys and computes its checksum with
+// the configured hash function.
+func (s *RingpopTestSuite) TestHashFunc64() {
+	ch, err := tchannel.NewChannel("test", nil)
+	s.Require().NoError(err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()),
+		HashRingConfig(&hashring.Configuration{
+			ReplicaPoints: 10,
+			HashFunc64:    farm.Fingerprint64,
+		}))
+	s.Require().NoError(err)
+	defer rp.Destroy()
+	s.Require().NoError(createSingleNodeCluster(rp))
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive},
+	})
+
+	expected, err := hashring.NewHashRing(
+		hashring.HashFunc64(farm.Fingerprint64),
+		hashring.ReplicaPoints(10),
+		hashring.Servers("127.0.0.1:3001", "127.0.0.1:3002"),
+	)
+	s.Require().NoError(err)
+	s.Equal(expected.Checksum(), rp.ring.Checksum())
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner, _ := expected.Lookup(key)
+		dest, err := rp.Lookup(key)
+		s.NoError(err)
+		s.Equal(owner, dest)
+	}
+
+	farmhash, err := hashring.NewHashRing(
+		hashring.ReplicaPoints(10),
+		hashring.Servers("127.0.0.1:3001", "127.0.0.1:3002"),
+	)
+	s.Require().NoError(err)
+	s.NotEqual(farmhash.Checksum(), rp.ring.Checksum(), "expected checksum to depend on the hash function")
+}
+
+func (s *RingpopTestSuite) TestWaitForConvergence() {
+	ch, err := tchannel.NewChannel("test", nil)
+	s.Require().NoError(err)
+	defer ch.Close()
+
+	mockClock := clock.NewMock()
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(mockClock),
+		ConvergenceWindow(time.Second))
+	s.Require().NoError(err)
+	defer rp.Destroy()
+
+	s.Equal(ErrNotBootstrapped, rp.WaitForConvergence(context.Background()))
+	s.Require().NoError(createSingleNodeCluster(rp))
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- rp.WaitForConvergence(context.Background())
+	}()
+
+	for {
+		select {
+		case err := <-errC:
+			s.NoError(err)
+			s.True(mockClock.Now().Sub(time.Unix(0, 0)) >= time.Second,
+				"expected the membership to be stable for the window")
+			return
+		case <-time.After(time.Millisecond):
+			mockClock.Add(100 * time.Millisecond)
+		}
+	}
+}
+
+func (s *RingpopTestSuite) TestWaitForConvergenceCanceled() {
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Equal(context.Canceled, s.ringpop.WaitForConvergence(ctx))
+}
+
+func (s *RingpopTestSuite) TestProtocol() {
+	_, err := s.ringpop.Protocol().HandlePing(context.Background(), []byte("{}"))
+	s.Equal(ErrNotBootstrapped, err)
+
+	s.Require().NoError(createSingleNodeCluster(s.ringpop))
+
+	_, err = s.ringpop.Protocol().HandleSync(context.Background(), []byte("{"))
+	s.IsType(&swim.MalformedMessageError{}, err, "expected message to be passed to the node")
+}
+
+// TestRendezvousHashing tests that a Ringpop instance configured with
+// rendezvous hashing places keys by it and advertises it in its fingerprint.
+func (s *RingpopTestSuite) TestRendezvousHashing() {
+	ch, err := tchannel.NewChannel("test", nil)
+	s.Require().NoError(err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()),
+		HashRingConfig(&hashring.Configuration{
+			ReplicaPoints: 100,
+			Rendezvous:    true,
+		}))
+	s.Require().NoError(err)
+	defer rp.Destroy()
+	s.Equal("hash=farmhash32;replicaPoints=100;rendezvous", rp.ringFingerprint())
+
+	s.Require().NoError(createSingleNodeCluster(rp))
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive},
+		{Address: "127.0.0.1:3003", Status: swim.Alive},
+	})
+	s.True(rp.ring.IsRendezvous())
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner, err := rp.Lookup(key)
+		s.Require().NoError(err)
+		s.Equal(rp.ring.LookupN(key, 3)[0], owner)
+	}
+}
+
+func (s *RingpopTestSuite) TestMaglevHashing() {
+	ch, err := tchannel.NewChannel("test", nil)
+	s.Require().NoError(err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()),
+		HashRingConfig(&hashring.Configuration{
+			ReplicaPoints: 100,
+			Maglev:        true,
+		}))
+	s.Require().NoError(err)
+	defer rp.Destroy()
+	s.Equal("hash=farmhash32;replicaPoints=100;maglev", rp.ringFingerprint())
+
+	s.Require().NoError(createSingleNodeCluster(rp))
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive},
+		{Address: "127.0.0.1:3003", Status: swim.Alive},
+	})
+	s.True(rp.ring.IsMaglev())
+
+	for server, share := range rp.ring.Ownership() {
+		s.InDelta(1.0/3, share, 0.01, "expected %s to own a third of the table", server)
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner, err := rp.Lookup(key)
+		s.Require().NoError(err)
+		s.Equal(rp.ring.LookupN(key, 3)[0], owner)
+	}
+}
+
+// TestRingFingerprintHash tests that nodes configured with different hash
+// functions advertise different ring fingerprints.
+func (s *RingpopTestSuite) TestRingFingerprintHash() {
+	s.Equal("hash=farmhash32;replicaPoints=100", s.ringpop.ringFingerprint())
+
+	s.Equal("farmhash32", hashName(&hashring.Configuration{}))
+	s.Equal("xxhash", hashName(&hashring.Configuration{
+		HashFunc64: farm.Fingerprint64,
+		HashName:   "xxhash",
+	}))
+	s.NotEqual(
+		hashName(&hashring.Configuration{HashFunc: crc32.ChecksumIEEE}),
+		hashName(&hashring.Configuration{HashFunc: farm.Fingerprint32}))
+	s.NotEqual(
+		hashName(&hashring.Configuration{HashFunc64: farm.Fingerprint64}),
+		hashName(&hashring.Configuration{HashFunc64: func(b []byte) uint64 {
+			return farm.Fingerprint64(append([]byte("salt"), b...))
+		}}))
+}
+
+// TestView tests that the view contains the membership as applied to the ring
+// and is not affected by later changes.
+func (s *RingpopTestSuite) TestView() {
+	_, err := s.ringpop.View()
+	s.Equal(ErrNotBootstrapped, err)
+
+	createSingleNodeCluster(s.ringpop)
+	s.ringpop.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3003", Status: swim.Alive, Incarnation: 1},
+		{Address: "127.0.0.1:3002", Status: swim.Suspect, Incarnation: 2},
+	})
+
+	view, err := s.ringpop.View()
+	s.Require().NoError(err)
+
+	member, ok := view.Member("127.0.0.1:3002")
+	s.True(ok)
+	s.Equal(swim.Change{Address: "127.0.0.1:3002", Status: swim.Suspect, Incarnation: 2}, member)
+	_, ok = view.Member("127.0.0.1:3004")
+	s.False(ok)
+	s.Equal("127.0.0.1:3001", view.Members[0].Address, "expected members sorted by address")
+	s.True(view.Ring.HasServer("127.0.0.1:3003"))
+
+	s.ringpop.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3003", Status: swim.Faulty, Incarnation: 1},
+	})
+
+	member, _ = view.Member("127.0.0.1:3003")
+	s.Equal(swim.Alive, member.Status, "expected view not to change")
+	s.True(view.Ring.HasServer("127.0.0.1:3003"), "expected ring of view not to change")
+
+	next, err := s.ringpop.View()
+	s.Require().NoError(err)
+	s.Equal(view.Version+1, next.Version)
+	member, _ = next.Member("127.0.0.1:3003")
+	s.Equal(swim.Faulty, member.Status)
+	s.False(next.Ring.HasServer("127.0.0.1:3003"))
+}
+
 // TestGetReachableMembersNotReady tests that GetReachableMembers fails when
 // Ringpop is not ready.
 func (s *RingpopTestSuite) TestGetReachableMembersNotReady() {
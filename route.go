@@ -0,0 +1,68 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"github.com/gl-works/ringpop-go/forward"
+	"github.com/uber/tchannel-go"
+	"golang.org/x/net/context"
+)
+
+// A Handler handles a request to an endpoint and returns the response.
+type Handler func(headers map[string]string, request []byte) ([]byte, error)
+
+// RouteBy wraps a handler of an endpoint so that requests are only handled
+// locally when this instance owns their key, and are forwarded to the owner
+// otherwise. The key of every request is extracted with the KeyExtractor, so a
+// handler can be routed declaratively, for example by
+// forward.JSONKey("user_id"), instead of every call site computing its keys
+// and calling HandleOrForward. Requests are forwarded with their headers, so
+// that the owner extracts the same keys, e.g. with forward.HeaderKey.
+func (rp *Ringpop) RouteBy(extract forward.KeyExtractor, service, endpoint string,
+	format tchannel.Format, opts *forward.Options, handler Handler) Handler {
+
+	return func(headers map[string]string, request []byte) ([]byte, error) {
+		if !rp.Ready() {
+			return nil, rp.errNotReady()
+		}
+
+		keys, err := extract(headers, request)
+		if err != nil {
+			return nil, err
+		}
+
+		forwardOpts := &forward.Options{}
+		if opts != nil {
+			*forwardOpts = *opts
+		}
+		forwardOpts.Headers = headers
+
+		var response []byte
+		handle, err := rp.handleOrForwardKeys(context.Background(), keys, request, &response, service, endpoint, format, forwardOpts)
+		if err != nil {
+			return nil, err
+		}
+		if handle {
+			return handler(headers, request)
+		}
+		return response, nil
+	}
+}
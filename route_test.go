@@ -0,0 +1,136 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gl-works/ringpop-go/forward"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
+	"golang.org/x/net/context"
+)
+
+// TestRouteByLocal tests that a routed handler is called for requests owned by
+// this instance.
+func (s *RingpopTestSuite) TestRouteByLocal() {
+	handler := s.ringpop.RouteBy(forward.JSONKey("user_id"), "test", "/user", tchannel.JSON, nil,
+		func(headers map[string]string, request []byte) ([]byte, error) {
+			return []byte("local"), nil
+		})
+
+	_, err := handler(nil, []byte(`{"user_id":"a"}`))
+	s.Equal(ErrNotBootstrapped, err)
+
+	createSingleNodeCluster(s.ringpop)
+
+	res, err := handler(nil, []byte(`{"user_id":"a"}`))
+	s.NoError(err)
+	s.Equal([]byte("local"), res)
+
+	_, err = handler(nil, []byte(`{"user_id":[]}`))
+	s.Equal(ErrNoKeys, err)
+
+	_, err = handler(nil, []byte(`{}`))
+	s.IsType(&forward.KeyNotFoundError{}, err)
+}
+
+// routedRawHandler serves a routed handler as a TChannel endpoint of JSON
+// requests.
+type routedRawHandler struct {
+	handler Handler
+}
+
+func (h routedRawHandler) Handle(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+	var headers map[string]string
+	if err := json.Unmarshal(args.Arg2, &headers); err != nil {
+		return nil, err
+	}
+
+	res, err := h.handler(headers, args.Arg3)
+	if err != nil {
+		body, _ := json.Marshal(map[string]string{"message": err.Error()})
+		return &raw.Res{IsErr: true, Arg3: body}, nil
+	}
+	return &raw.Res{Arg3: res}, nil
+}
+
+func (routedRawHandler) OnError(ctx context.Context, err error) {}
+
+// TestRouteByHeaderKeyForwarded tests that requests routed by a header are
+// forwarded with their headers, so that the owner routes them to itself.
+func TestRouteByHeaderKeyForwarded(t *testing.T) {
+	rp1, ch1 := newListeningRingpop(t)
+	defer ch1.Close()
+	defer rp1.Destroy()
+
+	rp2, ch2 := newListeningRingpop(t)
+	defer ch2.Close()
+	defer rp2.Destroy()
+
+	address1 := ch1.PeerInfo().HostPort
+	address2 := ch2.PeerInfo().HostPort
+	_, err := rp1.Bootstrap(&swim.BootstrapOptions{Hosts: []string{address1}})
+	require.NoError(t, err)
+	_, err = rp2.Bootstrap(&swim.BootstrapOptions{Hosts: []string{address1}})
+	require.NoError(t, err)
+
+	handlers := make(map[string]Handler)
+	for _, member := range []struct {
+		rp      *Ringpop
+		ch      *tchannel.Channel
+		address string
+	}{{rp1, ch1, address1}, {rp2, ch2, address2}} {
+		address := member.address
+		handler := member.rp.RouteBy(forward.HeaderKey("user"), "test", "/user", tchannel.JSON, nil,
+			func(headers map[string]string, request []byte) ([]byte, error) {
+				return []byte(address), nil
+			})
+		member.ch.Register(raw.Wrap(routedRawHandler{handler}), "/user")
+		handlers[address] = handler
+	}
+
+	// the first member learns about the second through gossip
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if rp1.ring.ServerCount() == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, 2, rp1.ring.ServerCount(), "expected members to converge")
+
+	var key string
+	for i := 0; key == ""; i++ {
+		if owner, _ := rp1.Lookup(strconv.Itoa(i)); owner == address2 {
+			key = strconv.Itoa(i)
+		}
+	}
+
+	res, err := handlers[address1](map[string]string{"user": key}, []byte("{}"))
+	require.NoError(t, err)
+	assert.Equal(t, address2, string(res), "expected request to be handled by the owner")
+}
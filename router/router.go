@@ -78,7 +78,7 @@ func (r *router) HandleEvent(event events.Event) {
 
 func (r *router) handleChange(change swim.Change) {
 	switch change.Status {
-	case swim.Faulty, swim.Leave:
+	case swim.Faulty, swim.Leave, swim.Tombstone:
 		r.removeClient(change.Address)
 	}
 }
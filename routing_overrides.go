@@ -0,0 +1,260 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/events"
+)
+
+// defaultOverridesInterval is how often the routing overrides file is checked
+// for changes when no interval is given.
+const defaultOverridesInterval = 5 * time.Second
+
+// RoutingPins pin keys and key prefixes to members, ahead of the ring.
+// They are read from the JSON file set with the RoutingOverrides option, for
+// example:
+//
+//	{
+//	    "keys": {"user-42": "10.0.0.1:3000"},
+//	    "prefixes": {"tenant-7/": "10.0.0.2:3000"}
+//	}
+//
+// A key pin takes precedence over prefix pins, and the longest matching
+// prefix takes precedence over shorter ones.
+type RoutingPins struct {
+	Keys     map[string]string `json:"keys"`
+	Prefixes map[string]string `json:"prefixes"`
+}
+
+// RoutingOverridesStatus is the state of the routing overrides of a Ringpop
+// instance, as returned by the /admin/overrides endpoint.
+type RoutingOverridesStatus struct {
+	// Path is the overrides file and Loaded the modification time of the
+	// version of it in effect. Loaded is zero when no overrides are in
+	// effect.
+	Path   string    `json:"path"`
+	Loaded time.Time `json:"loaded"`
+
+	RoutingPins
+}
+
+// overrideState contains the routing overrides in effect.
+type overrideState struct {
+	sync.RWMutex
+
+	loaded    time.Time
+	overrides RoutingPins
+
+	// prefixes are the pinned prefixes, longest first
+	prefixes []string
+
+	// failed is the modification time of the version of the file that
+	// failed to load, which is not read again until it changes, and warned
+	// the members not in the ring whose pins were warned about since the
+	// overrides were loaded
+	failed time.Time
+	warned map[string]bool
+}
+
+// set replaces the overrides in effect.
+func (s *overrideState) set(overrides RoutingPins, loaded time.Time) {
+	prefixes := make([]string, 0, len(overrides.Prefixes))
+	for prefix := range overrides.Prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Sort(byLengthDesc(prefixes))
+
+	s.Lock()
+	s.overrides = overrides
+	s.prefixes = prefixes
+	s.loaded = loaded
+	s.failed = time.Time{}
+	s.warned = nil
+	s.Unlock()
+}
+
+// unchanged returns whether the version of the file with the modification
+// time is in effect or failed to load.
+func (s *overrideState) unchanged(modTime time.Time) bool {
+	s.RLock()
+	defer s.RUnlock()
+	return modTime.Equal(s.loaded) || modTime.Equal(s.failed)
+}
+
+// fail records the modification time of the version of the file that failed
+// to load.
+func (s *overrideState) fail(modTime time.Time) {
+	s.Lock()
+	s.failed = modTime
+	s.Unlock()
+}
+
+// warnMissing returns whether a pin to a member that is not in the ring is
+// to be warned about, which is once per member until the overrides are
+// loaded again.
+func (s *overrideState) warnMissing(member string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.warned[member] {
+		return false
+	}
+	if s.warned == nil {
+		s.warned = make(map[string]bool)
+	}
+	s.warned[member] = true
+	return true
+}
+
+// lookup returns the member the key is pinned to, if any.
+func (s *overrideState) lookup(key string) (string, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if member, ok := s.overrides.Keys[key]; ok {
+		return member, true
+	}
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return s.overrides.Prefixes[prefix], true
+		}
+	}
+	return "", false
+}
+
+// byLengthDesc sorts strings by length, longest first, and then
+// alphabetically.
+type byLengthDesc []string
+
+func (s byLengthDesc) Len() int      { return len(s) }
+func (s byLengthDesc) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byLengthDesc) Less(i, j int) bool {
+	if len(s[i]) != len(s[j]) {
+		return len(s[i]) > len(s[j])
+	}
+	return s[i] < s[j]
+}
+
+// RoutingOverrides returns the routing overrides in effect, see the
+// RoutingOverrides option.
+func (rp *Ringpop) RoutingOverrides() RoutingOverridesStatus {
+	rp.overrides.RLock()
+	defer rp.overrides.RUnlock()
+
+	return RoutingOverridesStatus{
+		Path:        rp.config.OverridesPath,
+		Loaded:      rp.overrides.loaded,
+		RoutingPins: rp.overrides.overrides,
+	}
+}
+
+// reloadOverrides reads the routing overrides file if it changed since it was
+// last read. The overrides are cleared when the file is removed, and kept
+// when it cannot be read or parsed; a version of the file that failed to load
+// is not read again until it changes.
+func (rp *Ringpop) reloadOverrides() {
+	path := rp.config.OverridesPath
+	if path == "" {
+		return
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if rp.RoutingOverrides().Loaded.IsZero() {
+			return
+		}
+		rp.overrides.set(RoutingPins{}, time.Time{})
+		rp.logger.WithField("path", path).Warn("routing overrides file removed, cleared overrides")
+		rp.HandleEvent(events.RoutingOverridesReloadedEvent{Path: path})
+		return
+	}
+	if err == nil && rp.overrides.unchanged(info.ModTime()) {
+		return
+	}
+
+	var overrides RoutingPins
+	if err == nil {
+		var data []byte
+		data, err = ioutil.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &overrides)
+		}
+	}
+	if err != nil {
+		if info != nil {
+			rp.overrides.fail(info.ModTime())
+		}
+		rp.logger.WithFields(log.Fields{
+			"path":  path,
+			"error": err,
+		}).Error("failed to reload routing overrides, keeping previous overrides")
+		rp.HandleEvent(events.RoutingOverridesFailedEvent{Path: path, Error: err.Error()})
+		return
+	}
+
+	rp.overrides.set(overrides, info.ModTime())
+	rp.logger.WithFields(log.Fields{
+		"path":     path,
+		"keys":     len(overrides.Keys),
+		"prefixes": len(overrides.Prefixes),
+	}).Warn("reloaded routing overrides")
+	rp.HandleEvent(events.RoutingOverridesReloadedEvent{
+		Path:     path,
+		Keys:     len(overrides.Keys),
+		Prefixes: len(overrides.Prefixes),
+	})
+}
+
+// overrideLookup returns the member the key is pinned to by the routing
+// overrides. Pins to members that are not in the ring are ignored, and warned
+// about once per member until the overrides are reloaded.
+func (rp *Ringpop) overrideLookup(key string) (string, bool) {
+	member, ok := rp.overrides.lookup(key)
+	if !ok {
+		return "", false
+	}
+
+	if !rp.ring.HasServer(member) {
+		if rp.overrides.warnMissing(member) {
+			rp.logger.WithFields(log.Fields{
+				"key":    key,
+				"member": member,
+			}).Warn("ignoring routing override to member that is not in the ring")
+		}
+		return "", false
+	}
+
+	rp.logger.WithFields(log.Fields{
+		"key":    key,
+		"member": member,
+	}).Debug("lookup overridden")
+	rp.HandleEvent(events.LookupOverriddenEvent{Key: key, Member: member})
+	return member, true
+}
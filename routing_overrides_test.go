@@ -0,0 +1,122 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+func TestOverrideStatePrecedence(t *testing.T) {
+	s := &overrideState{}
+	s.set(RoutingPins{
+		Keys:     map[string]string{"tenant-7/admin": "127.0.0.1:3001"},
+		Prefixes: map[string]string{"tenant-": "127.0.0.1:3002", "tenant-7/": "127.0.0.1:3003"},
+	}, time.Now())
+
+	member, ok := s.lookup("tenant-7/admin")
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.1:3001", member, "expected key pin to take precedence")
+
+	member, _ = s.lookup("tenant-7/user")
+	assert.Equal(t, "127.0.0.1:3003", member, "expected longest prefix to take precedence")
+
+	member, _ = s.lookup("tenant-8/user")
+	assert.Equal(t, "127.0.0.1:3002", member)
+
+	_, ok = s.lookup("other")
+	assert.False(t, ok)
+}
+
+func TestPinFirst(t *testing.T) {
+	assert.Equal(t, []string{"b", "a", "c"}, pinFirst([]string{"a", "b", "c"}, "b"))
+	assert.Equal(t, []string{"d", "a", "b"}, pinFirst([]string{"a", "b", "c"}, "d"))
+	assert.Empty(t, pinFirst(nil, "d"))
+}
+
+func TestRoutingOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ringpop-overrides")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "overrides.json")
+
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()),
+		RoutingOverrides(path, time.Second))
+	require.NoError(t, err)
+	require.NoError(t, createSingleNodeCluster(rp))
+	defer rp.Destroy()
+	rp.ring.AddServer("127.0.0.1:3002")
+
+	write := func(data string, modTime time.Time) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(data), 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+		rp.reloadOverrides()
+	}
+
+	owner, _ := rp.ring.Lookup("key")
+	other := "127.0.0.1:3001"
+	if owner == other {
+		other = "127.0.0.1:3002"
+	}
+
+	loaded := time.Unix(1000, 0)
+	write(`{"keys": {"key": "`+other+`", "gone": "127.0.0.1:3009"}}`, loaded)
+	assert.True(t, rp.RoutingOverrides().Loaded.Equal(loaded))
+
+	dest, err := rp.Lookup("key")
+	assert.NoError(t, err)
+	assert.Equal(t, other, dest, "expected pinned member")
+
+	servers, err := rp.LookupN("key", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, other, servers[0], "expected pinned member first")
+
+	gone, _ := rp.ring.Lookup("gone")
+	dest, _ = rp.Lookup("gone")
+	assert.Equal(t, gone, dest, "expected pin to member not in the ring to be ignored")
+	assert.False(t, rp.overrides.warnMissing("127.0.0.1:3009"), "expected the ignored pin to be warned about once")
+
+	write(`{"keys":`, loaded.Add(time.Second))
+	dest, _ = rp.Lookup("key")
+	assert.Equal(t, other, dest, "expected previous overrides to be kept")
+
+	write(`{}`, loaded.Add(time.Second))
+	dest, _ = rp.Lookup("key")
+	assert.Equal(t, other, dest, "expected the failed file not to be read again until it changes")
+
+	require.NoError(t, os.Remove(path))
+	rp.reloadOverrides()
+	dest, _ = rp.Lookup("key")
+	assert.Equal(t, owner, dest, "expected overrides to be cleared")
+	assert.True(t, rp.RoutingOverrides().Loaded.IsZero())
+}
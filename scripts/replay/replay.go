@@ -0,0 +1,86 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// replay feeds a protocol capture recorded with swim.CaptureWriter into a
+// fresh SWIM node and prints the resulting membership, to reproduce
+// convergence issues offline.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/uber/tchannel-go"
+)
+
+var (
+	capturePath = flag.String("capture", "./capture.json", "path to the capture file")
+	address     = flag.String("address", "", "address of the replaying node, defaults to the address of the capturing node")
+)
+
+func main() {
+	flag.Parse()
+
+	f, err := os.Open(*capturePath)
+	if err != nil {
+		log.Fatalf("could not open capture: %v", err)
+	}
+
+	messages, err := swim.ReadCapture(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("could not read capture: %v", err)
+	}
+	if len(messages) == 0 {
+		log.Fatalf("capture %s contains no messages", *capturePath)
+	}
+
+	if *address == "" {
+		*address = messages[0].Local
+	}
+
+	ch, err := tchannel.NewChannel("ringpop", nil)
+	if err != nil {
+		log.Fatalf("could not create channel: %v", err)
+	}
+
+	node := swim.NewNode("ringpop", *address, ch.GetSubChannel("ringpop"), nil)
+	_, err = node.Bootstrap(&swim.BootstrapOptions{
+		Hosts:   []string{*address},
+		Stopped: true,
+	})
+	if err != nil {
+		log.Fatalf("could not bootstrap node: %v", err)
+	}
+
+	if err := swim.Replay(node, messages); err != nil {
+		log.Fatalf("replay failed: %v", err)
+	}
+
+	stats := node.MemberStats()
+	fmt.Printf("replayed %d messages, checksum %d\n", len(messages), stats.Checksum)
+	for i := range stats.Members {
+		member := &stats.Members[i]
+		fmt.Printf("%s\t%s\t%d\n", member.Address, member.Status, member.Incarnation)
+	}
+}
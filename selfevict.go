@@ -0,0 +1,150 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gl-works/ringpop-go/events"
+	log "github.com/uber-common/bark"
+)
+
+// defaultSelfEvictPingRatio is the fraction of the pingable members that are
+// notified right away when this instance evicts itself, see SelfEviction.
+const defaultSelfEvictPingRatio = 0.4
+
+// ErrSelfEvicted is returned by SelfEvict when this instance already evicted
+// itself.
+var ErrSelfEvicted = errors.New("ringpop already evicted itself")
+
+// ErrSelfEvicting is returned by SelfEvict when this instance is evicting
+// itself in another call.
+var ErrSelfEvicting = errors.New("ringpop is already evicting itself")
+
+// A SelfEvictHook runs around the eviction of this Ringpop instance from the
+// cluster, see SelfEvict. PreEvict is called before the other members are
+// notified, e.g. to stop accepting new work, and PostEvict after, e.g. to
+// drain requests that are still being forwarded. Hooks are identified by their
+// name.
+type SelfEvictHook interface {
+	Name() string
+	PreEvict()
+	PostEvict()
+}
+
+// selfEvictState holds the registered hooks and how far the instance got in
+// evicting itself. PreEvicted is set once the PreEvict hooks ran, so that they
+// do not run again when the eviction is retried after the leave failed.
+type selfEvictState struct {
+	hooks      []SelfEvictHook
+	evicting   bool
+	preEvicted bool
+	evicted    bool
+	sync.Mutex
+}
+
+// RegisterSelfEvictHook registers a hook that runs when this instance evicts
+// itself. Hooks run in the order they were registered. It returns an error if
+// a hook with the same name was registered before.
+func (rp *Ringpop) RegisterSelfEvictHook(hook SelfEvictHook) error {
+	rp.selfEvict.Lock()
+	defer rp.selfEvict.Unlock()
+
+	for _, registered := range rp.selfEvict.hooks {
+		if registered.Name() == hook.Name() {
+			return fmt.Errorf("self-evict hook %q is already registered", hook.Name())
+		}
+	}
+	rp.selfEvict.hooks = append(rp.selfEvict.hooks, hook)
+	return nil
+}
+
+// SelfEvict evicts this Ringpop instance from the cluster before the process
+// shuts down. It runs the PreEvict hooks, leaves the cluster and sends the
+// leave right away to a fraction of the pingable members, see SelfEviction, so
+// that they stop routing to this instance without waiting for it to be
+// suspected and declared faulty, and then runs the PostEvict hooks. The
+// instance can only evict itself once; when leaving fails, SelfEvict can be
+// called again and does not rerun the PreEvict hooks. Hooks run without
+// holding any lock of the instance, so they may call its methods.
+func (rp *Ringpop) SelfEvict() error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+
+	rp.selfEvict.Lock()
+	if rp.selfEvict.evicted {
+		rp.selfEvict.Unlock()
+		return ErrSelfEvicted
+	}
+	if rp.selfEvict.evicting {
+		rp.selfEvict.Unlock()
+		return ErrSelfEvicting
+	}
+	rp.selfEvict.evicting = true
+	preEvicted := rp.selfEvict.preEvicted
+	hooks := append([]SelfEvictHook(nil), rp.selfEvict.hooks...)
+	rp.selfEvict.Unlock()
+
+	start := rp.clock.Now()
+
+	if !preEvicted {
+		for _, hook := range hooks {
+			rp.logger.WithField("hook", hook.Name()).Debug("running pre-evict hook")
+			hook.PreEvict()
+		}
+	}
+
+	ratio := rp.config.SelfEvictPingRatio
+	if ratio == 0 {
+		ratio = defaultSelfEvictPingRatio
+	}
+	notified, err := rp.node.LeaveNotify(ratio)
+
+	rp.selfEvict.Lock()
+	rp.selfEvict.evicting = false
+	rp.selfEvict.preEvicted = true
+	rp.selfEvict.evicted = err == nil
+	rp.selfEvict.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		rp.logger.WithField("hook", hook.Name()).Debug("running post-evict hook")
+		hook.PostEvict()
+	}
+
+	duration := rp.clock.Now().Sub(start)
+	rp.logger.WithFields(log.Fields{
+		"notified": notified,
+		"duration": duration,
+	}).Info("evicted from the cluster")
+
+	rp.HandleEvent(events.SelfEvictedEvent{
+		Notified: notified,
+		Duration: duration,
+	})
+	return nil
+}
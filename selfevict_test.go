@@ -0,0 +1,95 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+type recordingHook struct {
+	name  string
+	calls *[]string
+}
+
+func (h recordingHook) Name() string { return h.name }
+func (h recordingHook) PreEvict()    { *h.calls = append(*h.calls, "pre-"+h.name) }
+func (h recordingHook) PostEvict()   { *h.calls = append(*h.calls, "post-"+h.name) }
+
+func TestSelfEvict(t *testing.T) {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()),
+		SelfEviction(0.5))
+	require.NoError(t, err)
+	defer rp.Destroy()
+
+	assert.Equal(t, ErrNotBootstrapped, rp.SelfEvict())
+
+	var calls []string
+	require.NoError(t, rp.RegisterSelfEvictHook(recordingHook{"a", &calls}))
+	require.NoError(t, rp.RegisterSelfEvictHook(recordingHook{"b", &calls}))
+	assert.Error(t, rp.RegisterSelfEvictHook(recordingHook{"a", &calls}),
+		"expected hooks with the same name to be rejected")
+
+	require.NoError(t, createSingleNodeCluster(rp))
+
+	require.NoError(t, rp.SelfEvict())
+	assert.Equal(t, []string{"pre-a", "pre-b", "post-a", "post-b"}, calls)
+	assert.True(t, rp.node.Left(), "expected self-evict to leave the cluster")
+	assert.False(t, rp.ring.HasServer("127.0.0.1:3001"), "expected to be removed from the ring")
+
+	assert.Equal(t, ErrSelfEvicted, rp.SelfEvict())
+	assert.Len(t, calls, 4, "expected hooks to run once")
+}
+
+type reentrantHook struct {
+	rp  *Ringpop
+	err error
+}
+
+func (h *reentrantHook) Name() string { return "reentrant" }
+func (h *reentrantHook) PreEvict()    { h.err = h.rp.SelfEvict() }
+func (h *reentrantHook) PostEvict()   {}
+
+func TestSelfEvictHooksRunUnlocked(t *testing.T) {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()))
+	require.NoError(t, err)
+	defer rp.Destroy()
+
+	hook := &reentrantHook{rp: rp}
+	require.NoError(t, rp.RegisterSelfEvictHook(hook))
+	require.NoError(t, createSingleNodeCluster(rp))
+
+	require.NoError(t, rp.SelfEvict())
+	assert.Equal(t, ErrSelfEvicting, hook.err, "expected a concurrent eviction to be rejected")
+	assert.Equal(t, ErrSelfEvicted, rp.SelfEvict())
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/hashring"
+)
+
+// ShadowRingConfiguration is a candidate hash ring configuration that is
+// computed side-by-side with the active one, so that the owners it assigns to
+// keys can be compared with the active owners on live traffic before cutting
+// over to it. See func ShadowRing.
+type ShadowRingConfiguration struct {
+	// HashFunc is the hash function of the shadow ring. It defaults to the
+	// hash function of the active ring.
+	HashFunc func([]byte) uint32
+
+	// HashFunc64 is a 64-bit hash function of the shadow ring. It takes
+	// precedence over HashFunc.
+	HashFunc64 func([]byte) uint64
+
+	// Rendezvous makes the shadow ring place keys by rendezvous hashing,
+	// which allows comparing it with a consistent hashing ring, or the other
+	// way around. See hashring.Rendezvous.
+	Rendezvous bool
+
+	// Maglev makes the shadow ring look up the owners of keys in a maglev
+	// table. See hashring.Maglev.
+	Maglev bool
+
+	// ReplicaPoints is the number of replica points of the members on the
+	// shadow ring. It defaults to the replica points of the active ring.
+	ReplicaPoints int
+
+	// SampleRate is the fraction of the lookups that are compared, which
+	// defaults to all of them.
+	SampleRate float64
+}
+
+// ShadowStats are the number of lookups compared against the shadow ring, and
+// the number of them whose owner on the shadow ring differed from the owner
+// on the active ring.
+type ShadowStats struct {
+	Compared int64
+	Diverged int64
+}
+
+// DivergenceRate returns the fraction of the compared lookups whose owners
+// diverged, or 0 if no lookups were compared.
+func (s ShadowStats) DivergenceRate() float64 {
+	if s.Compared == 0 {
+		return 0
+	}
+	return float64(s.Diverged) / float64(s.Compared)
+}
+
+// shadowRing is the shadow ring and the lookups compared against it.
+type shadowRing struct {
+	ring       *hashring.HashRing
+	sampleRate float64
+
+	compared int64
+	diverged int64
+}
+
+// newShadowRing returns a shadow ring with the configuration, using the hash
+// function and replica points of the active ring if it does not set them. The
+// shadow ring places keys by rendezvous hashing, or in a maglev table, only if
+// its configuration sets Rendezvous or Maglev.
+func newShadowRing(config *ShadowRingConfiguration, active *hashring.Configuration) (*shadowRing, error) {
+	ringConfig := *active
+	if config.HashFunc != nil || config.HashFunc64 != nil {
+		ringConfig.HashFunc = config.HashFunc
+		ringConfig.HashFunc64 = config.HashFunc64
+	}
+	if config.ReplicaPoints > 0 {
+		ringConfig.ReplicaPoints = config.ReplicaPoints
+	}
+	ringConfig.Rendezvous = config.Rendezvous
+	ringConfig.Maglev = config.Maglev
+	sampleRate := config.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 1
+	}
+
+	ring, err := newHashRing(&ringConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shadowRing{
+		ring:       ring,
+		sampleRate: sampleRate,
+	}, nil
+}
+
+// compareLookup looks the key up on the shadow ring for a sample of the
+// lookups, and reports whether its owner there differs from the owner on the
+// active ring.
+func (rp *Ringpop) compareLookup(key, owner string) {
+	s := rp.shadow
+	if s == nil || s.sampleRate < 1 && rand.Float64() >= s.sampleRate {
+		return
+	}
+
+	shadowOwner, _ := s.ring.Lookup(key)
+	atomic.AddInt64(&s.compared, 1)
+	if shadowOwner != owner {
+		atomic.AddInt64(&s.diverged, 1)
+	}
+
+	rp.HandleEvent(events.ShadowLookupEvent{
+		Key:         key,
+		Owner:       owner,
+		ShadowOwner: shadowOwner,
+	})
+}
+
+// ShadowStats returns the number of lookups compared against the shadow ring
+// and how many of them diverged. It returns zero stats if no shadow ring is
+// configured.
+func (rp *Ringpop) ShadowStats() ShadowStats {
+	s := rp.shadow
+	if s == nil {
+		return ShadowStats{}
+	}
+	return ShadowStats{
+		Compared: atomic.LoadInt64(&s.compared),
+		Diverged: atomic.LoadInt64(&s.diverged),
+	}
+}
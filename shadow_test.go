@@ -0,0 +1,125 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/dgryski/go-farm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/uber/tchannel-go"
+)
+
+func newShadowRingpop(t *testing.T, config ShadowRingConfiguration) *Ringpop {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()),
+		ShadowRing(config))
+	require.NoError(t, err)
+	require.NoError(t, createSingleNodeCluster(rp))
+
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive},
+		{Address: "127.0.0.1:3003", Status: swim.Alive},
+	})
+	return rp
+}
+
+func TestShadowRingSameConfiguration(t *testing.T) {
+	rp := newShadowRingpop(t, ShadowRingConfiguration{})
+	defer rp.Destroy()
+
+	assert.Equal(t, 3, rp.shadow.ring.ServerCount())
+	for _, server := range rp.ring.Servers() {
+		assert.True(t, rp.shadow.ring.HasServer(server),
+			"expected shadow ring to track the members of the active ring")
+	}
+
+	for i := 0; i < 100; i++ {
+		_, err := rp.Lookup(fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+	}
+
+	stats := rp.ShadowStats()
+	assert.Equal(t, ShadowStats{Compared: 100}, stats)
+	assert.Equal(t, 0.0, stats.DivergenceRate())
+}
+
+func TestShadowRingDivergence(t *testing.T) {
+	rp := newShadowRingpop(t, ShadowRingConfiguration{
+		HashFunc: func(b []byte) uint32 {
+			return farm.Fingerprint32(append([]byte("salt"), b...))
+		},
+		ReplicaPoints: 10,
+	})
+	defer rp.Destroy()
+
+	for i := 0; i < 100; i++ {
+		dest, err := rp.Lookup(fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+
+		owner, _ := rp.ring.Lookup(fmt.Sprintf("key-%d", i))
+		assert.Equal(t, owner, dest, "expected lookups to keep using the active ring")
+	}
+
+	stats := rp.ShadowStats()
+	assert.Equal(t, int64(100), stats.Compared)
+	assert.NotZero(t, stats.Diverged, "expected owners on a differently hashed ring to diverge")
+	assert.True(t, stats.DivergenceRate() < 1)
+}
+
+func TestShadowRingRendezvous(t *testing.T) {
+	rp := newShadowRingpop(t, ShadowRingConfiguration{Rendezvous: true})
+	defer rp.Destroy()
+
+	s := rp.shadow
+	assert.True(t, s.ring.IsRendezvous())
+	assert.False(t, rp.ring.IsRendezvous(), "expected the active ring to keep consistent hashing")
+	assert.Equal(t, rp.ring.ServerCount(), s.ring.ServerCount())
+
+	for i := 0; i < 100; i++ {
+		_, err := rp.Lookup(fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+	}
+
+	stats := rp.ShadowStats()
+	assert.Equal(t, int64(100), stats.Compared)
+	assert.NotZero(t, stats.Diverged, "expected owners of a rendezvous ring to diverge")
+}
+
+func TestShadowRingMaglev(t *testing.T) {
+	rp := newShadowRingpop(t, ShadowRingConfiguration{Maglev: true})
+	defer rp.Destroy()
+
+	assert.True(t, rp.shadow.ring.IsMaglev())
+	assert.False(t, rp.ring.IsMaglev(), "expected the active ring to keep consistent hashing")
+	assert.Equal(t, rp.ring.ServerCount(), rp.shadow.ring.ServerCount())
+}
+
+func TestShadowRingNotConfigured(t *testing.T) {
+	rp := &Ringpop{}
+	assert.Equal(t, ShadowStats{}, rp.ShadowStats())
+}
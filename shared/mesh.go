@@ -0,0 +1,64 @@
+package shared
+
+import (
+	"time"
+
+	"github.com/uber/tchannel-go"
+
+	"golang.org/x/net/context"
+)
+
+// A Mesh routes calls to members through the sidecar of a service mesh
+// instead of connecting to their hostports directly. Every call is sent to
+// the sidecar, which routes it by the mesh identity of the member. TChannel
+// only carries a fixed set of transport headers, so the identity is sent in
+// the shard key header ("sk") and the routing delegate header ("rd") names the
+// service of the mesh that routes the call.
+type Mesh struct {
+	// Sidecar is the hostport of the local sidecar.
+	Sidecar string
+
+	// RoutingDelegate is the service that routes calls in the mesh, if the
+	// sidecar requires one.
+	RoutingDelegate string
+
+	// Identity returns the mesh identity of the member at address. Without
+	// it, members are identified by their address.
+	Identity func(address string) string
+}
+
+// IdentityOf returns the mesh identity of the member at address.
+func (m *Mesh) IdentityOf(address string) string {
+	if m.Identity == nil {
+		return address
+	}
+	return m.Identity(address)
+}
+
+// DialAddress returns the address to connect to for the member at address,
+// which is the sidecar. A nil mesh dials the address itself.
+func (m *Mesh) DialAddress(address string) string {
+	if m == nil {
+		return address
+	}
+	return m.Sidecar
+}
+
+// NewContext returns the context for a call to the member at address, which
+// carries the headers the sidecar routes the call by. A nil mesh returns a
+// plain context as NewTChannelContext does.
+func (m *Mesh) NewContext(timeout time.Duration, address string) (tchannel.ContextWithHeaders, context.CancelFunc) {
+	if m == nil {
+		return NewTChannelContext(timeout)
+	}
+
+	builder := tchannel.NewContextBuilder(timeout).
+		DisableTracing().
+		SetRetryOptions(retryOptions).
+		SetShardKey(m.IdentityOf(address))
+	if m.RoutingDelegate != "" {
+		builder.SetRoutingDelegate(m.RoutingDelegate)
+	}
+
+	return builder.Build()
+}
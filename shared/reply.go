@@ -0,0 +1,34 @@
+package shared
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/uber/tchannel-go"
+)
+
+// OnReply wraps the JSON handlers of the endpoints that start with prefix, as
+// passed to json.Register, so that onReply is called with the context of every
+// call the handler replied to without an error, e.g. to add response headers
+// to the reply. The other handlers are returned as is.
+func OnReply(handlers map[string]interface{}, prefix string,
+	onReply func(ctx tchannel.ContextWithHeaders)) map[string]interface{} {
+
+	wrapped := make(map[string]interface{}, len(handlers))
+	for endpoint, handler := range handlers {
+		if !strings.HasPrefix(endpoint, prefix) {
+			wrapped[endpoint] = handler
+			continue
+		}
+
+		fn := reflect.ValueOf(handler)
+		wrapped[endpoint] = reflect.MakeFunc(fn.Type(), func(args []reflect.Value) []reflect.Value {
+			results := fn.Call(args)
+			if results[1].IsNil() {
+				onReply(args[0].Interface().(tchannel.ContextWithHeaders))
+			}
+			return results
+		}).Interface()
+	}
+	return wrapped
+}
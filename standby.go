@@ -0,0 +1,84 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gl-works/ringpop-go/events"
+)
+
+// defaultReadinessInterval is how often the readiness check of JoinWhenReady
+// is polled when no interval is given.
+const defaultReadinessInterval = time.Second
+
+// errDestroyedInStandby is returned by Bootstrap when the instance is
+// destroyed while it waits to be ready to join.
+var errDestroyedInStandby = errors.New("ringpop was destroyed before it was ready to join")
+
+// A ReadinessCheck reports whether the application is ready for this Ringpop
+// instance to join the cluster, e.g. once its local storage has recovered.
+type ReadinessCheck func() bool
+
+// waitUntilReady keeps this Ringpop instance in standby until the readiness
+// check passes. It returns an error when the instance is destroyed before
+// that.
+func (rp *Ringpop) waitUntilReady() error {
+	check := rp.config.ReadinessCheck
+	if check == nil {
+		return nil
+	}
+
+	start := rp.clock.Now()
+	ticker := rp.clock.Ticker(rp.config.ReadinessInterval)
+	defer ticker.Stop()
+
+	rp.setState(standby)
+
+	for !check() {
+		<-ticker.C
+		if rp.destroyed() {
+			return errDestroyedInStandby
+		}
+	}
+
+	rp.HandleEvent(events.ReadyToJoinEvent{
+		Waited: rp.clock.Now().Sub(start),
+	})
+	return nil
+}
+
+// errNotReady returns the error public methods fail with when this Ringpop
+// instance is not ready: ErrStandby while it waits for its readiness check,
+// ErrNotBootstrapped otherwise.
+func (rp *Ringpop) errNotReady() error {
+	if rp.getState() == standby {
+		return ErrStandby
+	}
+	return ErrNotBootstrapped
+}
+
+// Standby returns whether this Ringpop instance is waiting for the readiness
+// check of JoinWhenReady to pass before it joins the cluster.
+func (rp *Ringpop) Standby() bool {
+	return rp.getState() == standby
+}
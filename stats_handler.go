@@ -40,6 +40,8 @@ func handleStats(rp *Ringpop) map[string]interface{} {
 	uptime, _ := rp.Uptime()
 
 	return stats{
+		"clockSkew":  rp.node.ClockSkews(),
+		"detector":   rp.node.DetectorStats(),
 		"hooks":      nil,
 		"membership": rp.node.MemberStats(),
 		"process": stats{
@@ -50,7 +52,8 @@ func handleStats(rp *Ringpop) map[string]interface{} {
 			},
 			"pid": os.Getpid(),
 		},
-		"protocol": rp.node.ProtocolStats(),
+		"protocol":   rp.node.ProtocolStats(),
+		"subsystems": rp.subsystems(),
 		"ring": stats{
 			"servers":  servers,
 			"checksum": rp.ring.Checksum(),
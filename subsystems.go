@@ -0,0 +1,85 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import "github.com/gl-works/ringpop-go/swim"
+
+// SubsystemForwardingRetries is the subsystem that retries forwarded requests
+// that failed. While it is disabled, forwarded requests fail after their first
+// attempt.
+const SubsystemForwardingRetries swim.Subsystem = "forwarding-retries"
+
+// DisableSubsystem disables a subsystem of this Ringpop instance at runtime,
+// for surgical mitigation during incidents: SubsystemForwardingRetries or one
+// of the subsystems of the swim node, such as swim.SubsystemSuspicion, the
+// dissemination of changes with a status, see swim.DisseminationSubsystem, or
+// swim.SubsystemPartitionHealing. The subsystem stays disabled until it is
+// enabled again with EnableSubsystem. Disabled subsystems are visible in the
+// /admin/stats and /admin/subsystems endpoints.
+func (rp *Ringpop) DisableSubsystem(subsystem swim.Subsystem) error {
+	return rp.setSubsystem(subsystem, false)
+}
+
+// EnableSubsystem enables a subsystem that was disabled with
+// DisableSubsystem.
+func (rp *Ringpop) EnableSubsystem(subsystem swim.Subsystem) error {
+	return rp.setSubsystem(subsystem, true)
+}
+
+// Subsystems returns whether each of the subsystems that can be disabled is
+// enabled.
+func (rp *Ringpop) Subsystems() (map[swim.Subsystem]bool, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+	return rp.subsystems(), nil
+}
+
+// subsystems returns whether each subsystem is enabled.
+func (rp *Ringpop) subsystems() map[swim.Subsystem]bool {
+	enabled := rp.node.Subsystems()
+	enabled[SubsystemForwardingRetries] = rp.forwarder.RetriesEnabled()
+	return enabled
+}
+
+// setSubsystem enables or disables a subsystem.
+func (rp *Ringpop) setSubsystem(subsystem swim.Subsystem, enabled bool) error {
+	if !rp.Ready() {
+		return rp.errNotReady()
+	}
+
+	if subsystem != SubsystemForwardingRetries {
+		if enabled {
+			return rp.node.EnableSubsystem(subsystem)
+		}
+		return rp.node.DisableSubsystem(subsystem)
+	}
+
+	if rp.forwarder.RetriesEnabled() == enabled {
+		return nil
+	}
+	rp.forwarder.SetRetries(enabled)
+	rp.HandleEvent(swim.SubsystemToggledEvent{
+		Subsystem: subsystem,
+		Enabled:   enabled,
+	})
+	return nil
+}
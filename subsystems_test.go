@@ -0,0 +1,69 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+func TestSubsystems(t *testing.T) {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()))
+	require.NoError(t, err)
+	defer rp.Destroy()
+
+	assert.Equal(t, ErrNotBootstrapped, rp.DisableSubsystem(SubsystemForwardingRetries))
+	_, err = rp.Subsystems()
+	assert.Equal(t, ErrNotBootstrapped, err)
+
+	require.NoError(t, createSingleNodeCluster(rp))
+
+	subsystems, err := rp.Subsystems()
+	require.NoError(t, err)
+	assert.True(t, subsystems[SubsystemForwardingRetries])
+	assert.True(t, subsystems[swim.SubsystemPartitionHealing])
+
+	require.NoError(t, rp.DisableSubsystem(SubsystemForwardingRetries))
+	require.NoError(t, rp.DisableSubsystem(swim.SubsystemPartitionHealing))
+	assert.False(t, rp.forwarder.RetriesEnabled())
+
+	subsystems, _ = rp.Subsystems()
+	assert.False(t, subsystems[SubsystemForwardingRetries])
+	assert.False(t, subsystems[swim.SubsystemPartitionHealing])
+	assert.Equal(t, subsystems, handleStats(rp)["subsystems"], "expected subsystems in the stats")
+
+	require.NoError(t, rp.EnableSubsystem(SubsystemForwardingRetries))
+	require.NoError(t, rp.EnableSubsystem(swim.SubsystemPartitionHealing))
+	subsystems, _ = rp.Subsystems()
+	assert.True(t, subsystems[SubsystemForwardingRetries])
+	assert.True(t, subsystems[swim.SubsystemPartitionHealing])
+
+	assert.Error(t, rp.DisableSubsystem("unknown"))
+}
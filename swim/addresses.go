@@ -0,0 +1,89 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import "net"
+
+// An AddressSelector selects the address to dial a member at. Address is the
+// identity of the member, which is always reachable by members that share its
+// network, and addresses the additional addresses the member advertises, in
+// its order of preference. The selector returns the address to dial, which
+// does not have to be one of the given ones.
+type AddressSelector func(address string, addresses []string) string
+
+// PreferNetworks returns an AddressSelector that dials the first address of a
+// member, its identity included, whose IP is part of one of the networks. The
+// networks are tried in order, so that e.g. an internal network can be
+// preferred over an external one. Members without an address in any of the
+// networks are dialed at their identity.
+func PreferNetworks(networks ...*net.IPNet) AddressSelector {
+	return func(address string, addresses []string) string {
+		candidates := append([]string{address}, addresses...)
+
+		for _, network := range networks {
+			for _, candidate := range candidates {
+				host, _, err := net.SplitHostPort(candidate)
+				if err != nil {
+					continue
+				}
+
+				if ip := net.ParseIP(host); ip != nil && network.Contains(ip) {
+					return candidate
+				}
+			}
+		}
+
+		return address
+	}
+}
+
+// DialAddress returns the address to dial the member at address at, as
+// selected by the AddressSelector from the addresses the member advertises.
+// Without a selector, or for members that do not advertise additional
+// addresses, that is the address itself. With a Mesh, all members are dialed
+// at the sidecar.
+func (n *Node) DialAddress(address string) string {
+	if n.mesh != nil {
+		return n.mesh.DialAddress(address)
+	}
+
+	if n.addressSelector == nil {
+		return address
+	}
+
+	member, ok := n.memberlist.Member(address)
+	if !ok {
+		return address
+	}
+
+	member.RLock()
+	addresses := member.Addresses
+	member.RUnlock()
+
+	if len(addresses) == 0 {
+		return address
+	}
+
+	if dial := n.addressSelector(address, addresses); dial != "" {
+		return dial
+	}
+	return address
+}
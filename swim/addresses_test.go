@@ -0,0 +1,95 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/shared"
+)
+
+func mustParseCIDR(t *testing.T, cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	require.NoError(t, err)
+	return network
+}
+
+func TestPreferNetworks(t *testing.T) {
+	selector := PreferNetworks(
+		mustParseCIDR(t, "10.0.0.0/8"),
+		mustParseCIDR(t, "192.0.2.0/24"),
+	)
+
+	addresses := []string{"host.example.com:3000", "192.0.2.10:3000", "10.1.2.3:3000"}
+	assert.Equal(t, "10.1.2.3:3000", selector("172.16.0.1:3000", addresses),
+		"expected the first network to be preferred")
+	assert.Equal(t, "10.0.0.1:3000", selector("10.0.0.1:3000", addresses),
+		"expected the identity to be a candidate")
+	assert.Equal(t, "192.0.2.10:3000", selector("172.16.0.1:3000", addresses[:2]))
+	assert.Equal(t, "172.16.0.1:3000", selector("172.16.0.1:3000", addresses[:1]),
+		"expected the identity without a matching address")
+}
+
+func TestAdvertisedAddressesAreGossiped(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	tnode.node.advertiseAddresses = []string{"host.example.com:3000", "192.0.2.10:3000"}
+	tpeer.node.addressSelector = PreferNetworks(mustParseCIDR(t, "192.0.2.0/24"))
+
+	// members are dialed at their identity until they are known
+	assert.Equal(t, tnode.node.Address(), tpeer.node.DialAddress(tnode.node.Address()))
+
+	bootstrapNodes(t, tpeer, tnode)
+
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	member, ok := tpeer.node.memberlist.Member(tnode.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, tnode.node.advertiseAddresses, member.Addresses)
+	assert.Equal(t, "192.0.2.10:3000", tpeer.node.DialAddress(tnode.node.Address()))
+
+	// without a selector members are dialed at their identity
+	assert.Equal(t, tpeer.node.Address(), tnode.node.DialAddress(tpeer.node.Address()))
+}
+
+func TestMeshDialAddress(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	// the peer acts as the sidecar that all members are dialed at
+	tnode.node.addressSelector = PreferNetworks(mustParseCIDR(t, "192.0.2.0/24"))
+	tnode.node.mesh = &shared.Mesh{Sidecar: tpeer.node.Address()}
+	assert.Equal(t, tpeer.node.Address(), tnode.node.DialAddress("192.0.2.10:3000"),
+		"expected the sidecar to take precedence over the selector")
+
+	bootstrapNodes(t, tpeer, tnode)
+
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err, "expected ping through the sidecar to succeed")
+}
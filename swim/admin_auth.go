@@ -0,0 +1,133 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/uber-common/bark"
+	"github.com/uber/tchannel-go/json"
+)
+
+// AdminTokenHeader is the application header that carries the token of the
+// caller of an admin endpoint, see TokenAuthenticator.
+const AdminTokenHeader = "ringpop-admin-token"
+
+// An AdminRole is the set of admin operations a caller may perform. Roles are
+// ordered; a role includes the operations of the roles before it.
+type AdminRole int
+
+const (
+	// AdminNone allows no admin operations.
+	AdminNone AdminRole = iota
+
+	// AdminRead allows read-only introspection, such as stats and lookups.
+	AdminRead
+
+	// AdminWrite additionally allows operations that change the state of the
+	// member or the cluster, such as leaving, rejoining, stopping gossip or
+	// simulating partitions.
+	AdminWrite
+)
+
+func (r AdminRole) String() string {
+	switch r {
+	case AdminNone:
+		return "none"
+	case AdminRead:
+		return "read"
+	case AdminWrite:
+		return "write"
+	default:
+		return fmt.Sprintf("AdminRole(%d)", int(r))
+	}
+}
+
+var (
+	// ErrAdminUnauthenticated is returned by admin endpoints when the caller
+	// could not be authenticated.
+	ErrAdminUnauthenticated = errors.New("admin caller is not authenticated")
+
+	// ErrAdminForbidden is returned by admin endpoints when the role of the
+	// caller does not allow the operation.
+	ErrAdminForbidden = errors.New("admin operation is not allowed for the caller")
+)
+
+// An AdminAuthenticator returns the role of the caller of an admin endpoint
+// from the application headers of the call. TChannel does not support TLS, so
+// authentication is based on headers: a token, or a header set by an
+// authenticating proxy in front of the admin endpoints.
+type AdminAuthenticator interface {
+	Authenticate(headers map[string]string) (AdminRole, error)
+}
+
+// A TokenAuthenticator authenticates callers by the token in the
+// AdminTokenHeader, and maps tokens to roles.
+type TokenAuthenticator map[string]AdminRole
+
+// Authenticate returns the role of the token in the headers.
+func (a TokenAuthenticator) Authenticate(headers map[string]string) (AdminRole, error) {
+	token, ok := headers[AdminTokenHeader]
+	if !ok || token == "" {
+		return AdminNone, ErrAdminUnauthenticated
+	}
+
+	role, ok := a[token]
+	if !ok {
+		return AdminNone, ErrAdminUnauthenticated
+	}
+	return role, nil
+}
+
+// AuthorizeAdmin checks that the caller of the admin endpoint has the required
+// role. All callers are authorized when the node has no AdminAuthenticator.
+func (n *Node) AuthorizeAdmin(ctx json.Context, endpoint string, required AdminRole) error {
+	if n.adminAuth == nil {
+		return nil
+	}
+
+	var headers map[string]string
+	if ctx != nil {
+		headers = ctx.Headers()
+	}
+
+	role, err := n.adminAuth.Authenticate(headers)
+	if err == nil && role < required {
+		err = ErrAdminForbidden
+	}
+	if err == nil {
+		return nil
+	}
+
+	n.logger.WithFields(log.Fields{
+		"endpoint": endpoint,
+		"role":     role.String(),
+		"required": required.String(),
+		"error":    err,
+	}).Warn("admin request denied")
+	n.emit(AdminDeniedEvent{
+		Endpoint: endpoint,
+		Reason:   err.Error(),
+	})
+
+	return err
+}
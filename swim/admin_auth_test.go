@@ -0,0 +1,72 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/tchannel-go/json"
+	"golang.org/x/net/context"
+)
+
+func adminContext(token string) json.Context {
+	return json.WithHeaders(context.Background(), map[string]string{AdminTokenHeader: token})
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	auth := TokenAuthenticator{"reader": AdminRead, "writer": AdminWrite}
+
+	role, err := auth.Authenticate(map[string]string{AdminTokenHeader: "writer"})
+	assert.NoError(t, err)
+	assert.Equal(t, AdminWrite, role)
+
+	_, err = auth.Authenticate(map[string]string{AdminTokenHeader: "unknown"})
+	assert.Equal(t, ErrAdminUnauthenticated, err)
+
+	_, err = auth.Authenticate(nil)
+	assert.Equal(t, ErrAdminUnauthenticated, err)
+}
+
+func TestAdminEndpointsRequireRole(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		AdminAuthenticator: TokenAuthenticator{"reader": AdminRead, "writer": AdminWrite},
+	})
+	defer node.Destroy()
+
+	_, err := node.gossipHandlerStop(adminContext(""), &emptyArg{})
+	assert.Equal(t, ErrAdminUnauthenticated, err)
+
+	_, err = node.gossipHandlerStop(adminContext("reader"), &emptyArg{})
+	assert.Equal(t, ErrAdminForbidden, err, "expected read-only caller not to stop gossip")
+
+	_, err = node.gossipHandlerStop(adminContext("writer"), &emptyArg{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, node.AuthorizeAdmin(adminContext("reader"), "/admin/stats", AdminRead))
+}
+
+func TestAdminEndpointsOpenWithoutAuthenticator(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, nil)
+	defer node.Destroy()
+
+	assert.NoError(t, node.AuthorizeAdmin(nil, "/admin/member/leave", AdminWrite))
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxAnnotations is the maximum number of annotations of a member.
+	MaxAnnotations = 16
+
+	// MaxAnnotationSize is the maximum length of the key and of the value
+	// of an annotation.
+	MaxAnnotationSize = 256
+)
+
+// Annotate changes the annotations of the local member, such as "maintenance":
+// "until 5pm by alice". Annotations are free-form operational notes that are
+// gossiped to all members along with the status of the member, so they are
+// part of the membership everywhere and survive changes to the member's
+// status. Annotations with an empty value are removed, other annotations of
+// the member are kept.
+func (n *Node) Annotate(annotations map[string]string) error {
+	if !n.Ready() {
+		return ErrNodeNotReady
+	}
+	if _, ok := annotations[IdentityAnnotation]; ok {
+		return errIdentityAnnotation
+	}
+
+	merged := make(map[string]string)
+	for key, value := range n.memberlist.LocalAnnotations() {
+		merged[key] = value
+	}
+	for key, value := range annotations {
+		if value == "" {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+	}
+
+	if err := validateAnnotations(merged); err != nil {
+		return err
+	}
+	if len(merged) == 0 {
+		merged = nil
+	}
+
+	if n.memberlist.SetAnnotations(merged) != nil {
+		n.logger.WithField("annotations", merged).Info("changed local member annotations")
+		n.emit(AnnotationsChangedEvent{Annotations: merged})
+	}
+
+	return nil
+}
+
+// Annotations returns the annotations of the member at address. Ok is false if
+// the member is not known.
+func (n *Node) Annotations(address string) (annotations map[string]string, ok bool) {
+	member, ok := n.memberlist.Member(address)
+	if !ok {
+		return nil, false
+	}
+
+	member.RLock()
+	defer member.RUnlock()
+
+	annotations = make(map[string]string, len(member.Annotations))
+	for key, value := range member.Annotations {
+		annotations[key] = value
+	}
+	return annotations, true
+}
+
+// validateAnnotations checks that the annotations are within the limits.
+func validateAnnotations(annotations map[string]string) error {
+	if len(annotations) > MaxAnnotations {
+		return fmt.Errorf("a member can have at most %d annotations, got %d",
+			MaxAnnotations, len(annotations))
+	}
+
+	for key, value := range annotations {
+		if key == "" {
+			return errors.New("annotation key must not be empty")
+		}
+		if len(key) > MaxAnnotationSize || len(value) > MaxAnnotationSize {
+			return fmt.Errorf("annotation %q exceeds %d bytes", key, MaxAnnotationSize)
+		}
+	}
+
+	return nil
+}
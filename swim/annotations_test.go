@@ -0,0 +1,89 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotateNotReady(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	assert.Equal(t, ErrNodeNotReady, tnode.node.Annotate(map[string]string{"a": "b"}))
+}
+
+func TestAnnotateIsGossiped(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+	tclock := tnode.node.clock.(*clock.Mock)
+	tclock.Add(time.Second)
+
+	require.NoError(t, tnode.node.Annotate(map[string]string{
+		"maintenance": "until 5pm by alice",
+		"ticket":      "OPS-1",
+	}))
+
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	annotations, ok := tpeer.node.Annotations(tnode.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"maintenance": "until 5pm by alice",
+		"ticket":      "OPS-1",
+	}, annotations, "expected annotations to be gossiped")
+
+	// refuting a suspicion keeps the annotations of the local member
+	tclock.Add(time.Second)
+	tnode.node.memberlist.MakeSuspect(tnode.node.Address(), tnode.node.Incarnation())
+	annotations, _ = tnode.node.Annotations(tnode.node.Address())
+	assert.Len(t, annotations, 2)
+
+	tclock.Add(time.Second)
+	require.NoError(t, tnode.node.Annotate(map[string]string{"ticket": ""}))
+	annotations, _ = tnode.node.Annotations(tnode.node.Address())
+	assert.Equal(t, map[string]string{"maintenance": "until 5pm by alice"}, annotations,
+		"expected annotation with empty value to be removed")
+}
+
+func TestAnnotateLimits(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+	bootstrapNodes(t, tnode)
+
+	assert.Error(t, tnode.node.Annotate(map[string]string{"": "value"}))
+	assert.Error(t, tnode.node.Annotate(map[string]string{
+		"key": strings.Repeat("a", MaxAnnotationSize+1),
+	}))
+
+	_, ok := tnode.node.Annotations("127.0.0.1:1")
+	assert.False(t, ok, "expected unknown member to have no annotations")
+}
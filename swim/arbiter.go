@@ -0,0 +1,78 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import log "github.com/uber-common/bark"
+
+// An Arbiter is consulted before a suspect member is declared faulty, for
+// clusters where some members, such as stateful primaries, must never be
+// evicted on gossip evidence alone. It is typically backed by an external
+// system such as a quorum service or the API of an orchestrator.
+type Arbiter interface {
+	// ConfirmFaulty returns whether the suspect member may be declared
+	// faulty. An error vetoes the decision, the same as false. It is called
+	// from the timers of the suspicion protocol and should not block for
+	// longer than the suspicion timeout.
+	ConfirmFaulty(address string, incarnation int64) (bool, error)
+}
+
+// The ArbiterFunc type is an adapter to allow the use of ordinary functions as
+// Arbiters.
+type ArbiterFunc func(address string, incarnation int64) (bool, error)
+
+// ConfirmFaulty calls f(address, incarnation).
+func (f ArbiterFunc) ConfirmFaulty(address string, incarnation int64) (bool, error) {
+	return f(address, incarnation)
+}
+
+// An ArbiterFilter selects the members the Arbiter decides on. Other members
+// are declared faulty by the suspicion protocol alone.
+type ArbiterFilter func(address string) bool
+
+// confirmFaulty returns whether the suspect member may be declared faulty,
+// consulting the arbiter if the member matches the arbiter filter.
+func (n *Node) confirmFaulty(address string, incarnation int64) bool {
+	if n.arbiter == nil || (n.arbiterFilter != nil && !n.arbiterFilter(address)) {
+		return true
+	}
+
+	confirmed, err := n.arbiter.ConfirmFaulty(address, incarnation)
+	if confirmed && err == nil {
+		return true
+	}
+
+	reason := "rejected by arbiter"
+	if err != nil {
+		reason = err.Error()
+	}
+
+	n.logger.WithFields(log.Fields{
+		"suspect": address,
+		"reason":  reason,
+	}).Warn("arbiter vetoed declaring member faulty")
+	n.emit(FaultyVetoedEvent{
+		Address:     address,
+		Incarnation: incarnation,
+		Reason:      reason,
+	})
+
+	return false
+}
@@ -0,0 +1,319 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	log "github.com/uber-common/bark"
+)
+
+// A BacklogStore holds changes the disseminator spilled from memory because
+// its backlog grew beyond Options.MaxBacklog, until the backlog shrinks and
+// they are disseminated again. Changes are popped in the order they were
+// pushed. Implementations must be safe for concurrent use.
+type BacklogStore interface {
+	// Push appends changes to the store.
+	Push(changes []Change) error
+
+	// Pop removes and returns up to n of the oldest changes in the store.
+	Pop(n int) ([]Change, error)
+
+	// Len returns the number of changes in the store.
+	Len() int
+}
+
+// MemoryBacklogStore is a BacklogStore that keeps spilled changes in memory.
+// It is the default store, which bounds the changes piggybacked on protocol
+// messages without bounding the memory used.
+type MemoryBacklogStore struct {
+	changes []Change
+	sync.Mutex
+}
+
+// NewMemoryBacklogStore returns an empty MemoryBacklogStore.
+func NewMemoryBacklogStore() *MemoryBacklogStore {
+	return &MemoryBacklogStore{}
+}
+
+// Push appends changes to the store.
+func (s *MemoryBacklogStore) Push(changes []Change) error {
+	s.Lock()
+	s.changes = append(s.changes, changes...)
+	s.Unlock()
+	return nil
+}
+
+// Pop removes and returns up to n of the oldest changes in the store.
+func (s *MemoryBacklogStore) Pop(n int) ([]Change, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if n > len(s.changes) {
+		n = len(s.changes)
+	}
+
+	changes := make([]Change, n)
+	copy(changes, s.changes)
+	s.changes = s.changes[n:]
+
+	return changes, nil
+}
+
+// Len returns the number of changes in the store.
+func (s *MemoryBacklogStore) Len() int {
+	s.Lock()
+	n := len(s.changes)
+	s.Unlock()
+	return n
+}
+
+// FileBacklogStore is a BacklogStore that queues spilled changes in a file, one
+// JSON encoded change per line. The file is truncated whenever the queue
+// becomes empty, so it only grows while the backlog is under pressure.
+type FileBacklogStore struct {
+	file   *os.File
+	offset int64
+	count  int
+	sync.Mutex
+}
+
+// NewFileBacklogStore creates a FileBacklogStore that queues changes in the
+// file at path. An existing file is truncated, as changes from a previous run
+// are stale.
+func NewFileBacklogStore(path string) (*FileBacklogStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileBacklogStore{file: file}, nil
+}
+
+// Push appends changes to the file.
+func (s *FileBacklogStore) Push(changes []Change) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, err := s.file.Seek(0, os.SEEK_END); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(s.file)
+	encoder := json.NewEncoder(w)
+	for i := range changes {
+		// Timestamp only implements json.Marshaler on its pointer
+		if err := encoder.Encode(&changes[i]); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	s.count += len(changes)
+	return nil
+}
+
+// Pop reads and removes up to n of the oldest changes from the file.
+func (s *FileBacklogStore) Pop(n int) ([]Change, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, err := s.file.Seek(s.offset, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	r := bufio.NewReader(s.file)
+	for len(changes) < n && s.count > 0 {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return changes, err
+		}
+
+		s.offset += int64(len(line))
+		s.count--
+
+		var change Change
+		if err := json.Unmarshal(line, &change); err != nil {
+			return changes, err
+		}
+		changes = append(changes, change)
+	}
+
+	if s.count == 0 {
+		s.offset = 0
+		if err := s.file.Truncate(0); err != nil {
+			return changes, err
+		}
+	}
+
+	return changes, nil
+}
+
+// Len returns the number of changes in the file.
+func (s *FileBacklogStore) Len() int {
+	s.Lock()
+	n := s.count
+	s.Unlock()
+	return n
+}
+
+// Close closes the file. The file is not removed.
+func (s *FileBacklogStore) Close() error {
+	return s.file.Close()
+}
+
+// lowPriority returns whether a change can be held back from dissemination
+// before others. Alive changes about remote members are the least urgent;
+// suspicions, faulty and leave changes, as well as changes about the local
+// member, are spilled last.
+func (d *disseminator) lowPriority(change Change) bool {
+	return change.Status == Alive && change.Address != d.node.Address()
+}
+
+// spillNoLock moves the oldest changes beyond the backlog limit to the store,
+// low priority changes first. Changes about the local member are never
+// spilled. It returns the number of spilled changes.
+func (d *disseminator) spillNoLock() int {
+	excess := len(d.changes) - d.maxBacklog
+	if d.maxBacklog <= 0 || excess <= 0 {
+		return 0
+	}
+
+	candidates := make([]*pChange, 0, len(d.changes))
+	for _, change := range d.changes {
+		if change.Address != d.node.Address() {
+			candidates = append(candidates, change)
+		}
+	}
+
+	sort.Sort(spillOrder{candidates, d.lowPriority})
+
+	if excess > len(candidates) {
+		excess = len(candidates)
+	}
+
+	spilled := make([]Change, 0, excess)
+	for _, change := range candidates[:excess] {
+		spilled = append(spilled, change.Change)
+	}
+
+	if err := d.backlog.Push(spilled); err != nil {
+		// keep the changes in memory rather than losing them
+		d.logger.WithField("error", err).Warn("could not spill changes to backlog store")
+		return 0
+	}
+
+	for _, change := range spilled {
+		delete(d.changes, change.Address)
+	}
+
+	return len(spilled)
+}
+
+// restoreNoLock moves changes from the store back into memory while there is
+// room in the backlog. Spilled changes that have been superseded, by a newer
+// change in memory or a newer state of the member, are discarded. It returns
+// the number of restored changes.
+func (d *disseminator) restoreNoLock() int {
+	if d.maxBacklog <= 0 || d.backlog.Len() == 0 {
+		return 0
+	}
+
+	room := d.maxBacklog - len(d.changes)
+	if room <= 0 {
+		return 0
+	}
+
+	changes, err := d.backlog.Pop(room)
+	if err != nil {
+		d.logger.WithField("error", err).Warn("could not restore changes from backlog store")
+	}
+
+	restored := 0
+	for _, change := range changes {
+		if _, ok := d.changes[change.Address]; ok {
+			continue
+		}
+
+		member, ok := d.node.memberlist.Member(change.Address)
+		if ok && member.Incarnation > change.Incarnation {
+			continue
+		}
+
+		d.changes[change.Address] = &pChange{Change: change, seq: d.nextSeqNoLock()}
+		restored++
+	}
+
+	return restored
+}
+
+// nextSeqNoLock returns an increasing number that orders changes by the time
+// they were recorded.
+func (d *disseminator) nextSeqNoLock() uint64 {
+	d.seq++
+	return d.seq
+}
+
+// emitBacklog emits the events for spilled and restored changes and logs them.
+func (d *disseminator) emitBacklog(spilled, restored int) {
+	if spilled > 0 {
+		d.node.emit(ChangesSpilledEvent{Count: spilled})
+		d.logger.WithFields(log.Fields{
+			"spilled": spilled,
+			"backlog": d.backlog.Len(),
+		}).Debug("spilled changes to backlog store")
+	}
+
+	if restored > 0 {
+		d.node.emit(ChangesRestoredEvent{Count: restored})
+		d.logger.WithFields(log.Fields{
+			"restored": restored,
+			"backlog":  d.backlog.Len(),
+		}).Debug("restored changes from backlog store")
+	}
+}
+
+// spillOrder sorts changes in the order they are spilled: low priority
+// changes first, oldest first.
+type spillOrder struct {
+	changes     []*pChange
+	lowPriority func(Change) bool
+}
+
+func (s spillOrder) Len() int      { return len(s.changes) }
+func (s spillOrder) Swap(i, j int) { s.changes[i], s.changes[j] = s.changes[j], s.changes[i] }
+func (s spillOrder) Less(i, j int) bool {
+	li, lj := s.lowPriority(s.changes[i].Change), s.lowPriority(s.changes[j].Change)
+	if li != lj {
+		return li
+	}
+	return s.changes[i].seq < s.changes[j].seq
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/util"
+)
+
+func testBacklogStore(t *testing.T, store BacklogStore) {
+	// timestamps are encoded with a precision of seconds
+	timestamp := util.Timestamp(time.Unix(1000, 0))
+	changes := []Change{
+		{Address: "127.0.0.1:3001", Status: Alive, Incarnation: 1, Timestamp: timestamp},
+		{Address: "127.0.0.1:3002", Status: Suspect, Incarnation: 2, Timestamp: timestamp},
+		{Address: "127.0.0.1:3003", Status: Faulty, Incarnation: 3, Timestamp: timestamp},
+	}
+
+	require.NoError(t, store.Push(changes[:2]))
+	require.NoError(t, store.Push(changes[2:]))
+	assert.Equal(t, 3, store.Len())
+
+	popped, err := store.Pop(2)
+	require.NoError(t, err)
+	assert.Equal(t, changes[:2], popped, "expected the oldest changes first")
+	assert.Equal(t, 1, store.Len())
+
+	popped, err = store.Pop(5)
+	require.NoError(t, err)
+	assert.Equal(t, changes[2:], popped)
+	assert.Equal(t, 0, store.Len())
+
+	// the store is reusable after it was emptied
+	require.NoError(t, store.Push(changes[:1]))
+	popped, err = store.Pop(1)
+	require.NoError(t, err)
+	assert.Equal(t, changes[:1], popped)
+}
+
+func TestMemoryBacklogStore(t *testing.T) {
+	testBacklogStore(t, NewMemoryBacklogStore())
+}
+
+func TestFileBacklogStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backlog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileBacklogStore(filepath.Join(dir, "backlog"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	testBacklogStore(t, store)
+
+	info, err := os.Stat(filepath.Join(dir, "backlog"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size(), "expected the file to be truncated when empty")
+}
+
+func TestDisseminatorSpillsAndRestores(t *testing.T) {
+	store := NewMemoryBacklogStore()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		MaxBacklog:   3,
+		BacklogStore: store,
+	})
+	defer node.Destroy()
+
+	incarnation := util.TimeNowMS()
+	node.memberlist.MakeAlive(node.Address(), incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3002", incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3003", incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3004", incarnation)
+
+	assert.Equal(t, 3, node.disseminator.ChangesCount())
+	assert.Equal(t, 1, store.Len(), "expected the oldest change to be spilled")
+	_, ok := node.disseminator.ChangesByAddress("127.0.0.1:3002")
+	assert.False(t, ok)
+
+	// suspicions are kept over older alive changes
+	node.memberlist.MakeSuspect("127.0.0.1:3003", incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3005", incarnation)
+
+	assert.Equal(t, 2, store.Len())
+	_, ok = node.disseminator.ChangesByAddress("127.0.0.1:3003")
+	assert.True(t, ok, "expected the suspicion not to be spilled")
+	_, ok = node.disseminator.ChangesByAddress("127.0.0.1:3004")
+	assert.False(t, ok, "expected the oldest alive change to be spilled")
+	_, ok = node.disseminator.ChangesByAddress(node.Address())
+	assert.True(t, ok, "expected the change of the local member not to be spilled")
+
+	// once the backlog has been disseminated the spilled changes return
+	for i := 0; i < node.disseminator.maxP; i++ {
+		changes, bump := node.disseminator.IssueAsSender()
+		require.NotEmpty(t, changes)
+		bump()
+		if store.Len() == 0 {
+			break
+		}
+	}
+
+	assert.Equal(t, 0, store.Len())
+	_, ok = node.disseminator.ChangesByAddress("127.0.0.1:3002")
+	assert.True(t, ok, "expected spilled change to be restored")
+	_, ok = node.disseminator.ChangesByAddress("127.0.0.1:3004")
+	assert.True(t, ok, "expected spilled change to be restored")
+}
+
+func TestDisseminatorDiscardsSupersededSpills(t *testing.T) {
+	store := NewMemoryBacklogStore()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		MaxBacklog:   2,
+		BacklogStore: store,
+	})
+	defer node.Destroy()
+
+	incarnation := util.TimeNowMS()
+	node.memberlist.MakeAlive(node.Address(), incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3002", incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3003", incarnation)
+	require.Equal(t, 1, store.Len())
+
+	// a newer change of the spilled member is recorded in the meantime
+	node.memberlist.MakeAlive("127.0.0.1:3002", incarnation+1)
+
+	node.disseminator.ClearChanges()
+	node.disseminator.bumpPiggybackCounters(nil)
+
+	assert.Equal(t, 0, store.Len())
+	_, ok := node.disseminator.ChangesByAddress("127.0.0.1:3002")
+	assert.False(t, ok, "expected the superseded change to be discarded")
+}
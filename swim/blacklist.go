@@ -0,0 +1,147 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	log "github.com/uber-common/bark"
+)
+
+// MaxBlacklistTTL is the longest a member can be blacklisted, so that it is
+// not cut off from the node indefinitely by mistake.
+const MaxBlacklistTTL = 24 * time.Hour
+
+// ErrBlacklisted is returned for protocol messages from or to a blacklisted
+// member.
+var ErrBlacklisted = errors.New("member is blacklisted")
+
+// blacklistState contains the blacklisted members and the timers that expire
+// them. The generation of a member counts its blacklistings, so that the timer
+// of a replaced blacklisting does not expire its successor.
+type blacklistState struct {
+	members map[string]blacklistEntry
+	sync.RWMutex
+}
+
+// blacklistEntry is a blacklisted member.
+type blacklistEntry struct {
+	until      time.Time
+	timer      *clock.Timer
+	generation uint64
+}
+
+// Blacklist cuts communication with a member for ttl, e.g. to isolate a member
+// that corrupts the membership or attacks the cluster while a fix is
+// prepared. The node does not ping the member or send it protocol messages,
+// drops protocol messages from it and ignores gossip that originates from it.
+// Blacklisting a member again replaces its ttl. The ttl must be positive and
+// at most MaxBlacklistTTL.
+func (n *Node) Blacklist(address string, ttl time.Duration) error {
+	if ttl <= 0 || ttl > MaxBlacklistTTL {
+		return fmt.Errorf("blacklist ttl must be between 0 and %v, got %v", MaxBlacklistTTL, ttl)
+	}
+	if address == n.address {
+		return errors.New("the local member cannot be blacklisted")
+	}
+
+	n.blacklist.Lock()
+	entry := n.blacklist.members[address]
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+	entry.generation++
+	generation := entry.generation
+	entry.until = n.clock.Now().Add(ttl)
+	entry.timer = n.clock.AfterFunc(ttl, func() {
+		n.unblacklist(address, generation)
+	})
+	if n.blacklist.members == nil {
+		n.blacklist.members = make(map[string]blacklistEntry)
+	}
+	n.blacklist.members[address] = entry
+	n.blacklist.Unlock()
+
+	n.logger.WithFields(log.Fields{
+		"member": address,
+		"ttl":    ttl,
+	}).Warn("blacklisted member")
+	n.emit(MemberBlacklistedEvent{
+		Member: address,
+		TTL:    ttl,
+	})
+	return nil
+}
+
+// Unblacklist restores communication with a blacklisted member before its ttl
+// expires. It returns false if the member is not blacklisted.
+func (n *Node) Unblacklist(address string) bool {
+	return n.unblacklist(address, 0)
+}
+
+// unblacklist removes a member from the blacklist. When generation is not
+// zero, the member is only removed if it is still that generation, which
+// means its ttl expired.
+func (n *Node) unblacklist(address string, generation uint64) bool {
+	n.blacklist.Lock()
+	entry, ok := n.blacklist.members[address]
+	if !ok || (generation != 0 && generation != entry.generation) {
+		n.blacklist.Unlock()
+		return false
+	}
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+	delete(n.blacklist.members, address)
+	n.blacklist.Unlock()
+
+	n.logger.WithField("member", address).Warn("removed member from blacklist")
+	n.emit(MemberUnblacklistedEvent{
+		Member:  address,
+		Expired: generation != 0,
+	})
+	return true
+}
+
+// Blacklisted returns whether the member at address is blacklisted.
+func (n *Node) Blacklisted(address string) bool {
+	n.blacklist.RLock()
+	_, ok := n.blacklist.members[address]
+	n.blacklist.RUnlock()
+	return ok
+}
+
+// BlacklistedMembers returns the blacklisted members and when their ttl
+// expires.
+func (n *Node) BlacklistedMembers() map[string]time.Time {
+	n.blacklist.RLock()
+	defer n.blacklist.RUnlock()
+
+	members := make(map[string]time.Time, len(n.blacklist.members))
+	for address, entry := range n.blacklist.members {
+		members[address] = entry.until
+	}
+	return members
+}
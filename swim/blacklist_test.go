@@ -0,0 +1,98 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events"
+)
+
+func TestBlacklist(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+	bootstrapNodes(t, tnode, tpeer)
+
+	var unblacklisted []MemberUnblacklistedEvent
+	tnode.node.RegisterListener(ListenerFunc(func(event events.Event) {
+		if event, ok := event.(MemberUnblacklistedEvent); ok {
+			unblacklisted = append(unblacklisted, event)
+		}
+	}))
+
+	peer := tpeer.node.Address()
+	mockClock := tnode.node.clock.(*clock.Mock)
+	require.NoError(t, tnode.node.Blacklist(peer, time.Minute))
+	assert.True(t, tnode.node.Blacklisted(peer))
+	assert.Equal(t, map[string]time.Time{peer: mockClock.Now().Add(time.Minute)}, tnode.node.BlacklistedMembers())
+	assert.Equal(t, 0, tnode.node.memberlist.NumPingableMembers(), "expected blacklisted member not to be pinged")
+
+	_, err := sendPing(tnode.node, peer, time.Second)
+	assert.Equal(t, ErrBlacklisted, err, "expected pings to the member to fail")
+	_, err = sendPing(tpeer.node, tnode.node.Address(), time.Second)
+	assert.Error(t, err, "expected pings from the member to be dropped")
+
+	// gossip that originates from the member is ignored
+	applied := tnode.node.memberlist.Update([]Change{{
+		Source:      peer,
+		Address:     "127.0.0.1:3005",
+		Incarnation: 1,
+		Status:      Alive,
+	}})
+	assert.Empty(t, applied)
+
+	mockClock.Add(time.Minute)
+	assert.False(t, tnode.node.Blacklisted(peer), "expected blacklist to expire after its ttl")
+	assert.Equal(t, []MemberUnblacklistedEvent{{Member: peer, Expired: true}}, unblacklisted)
+
+	_, err = sendPing(tnode.node, peer, time.Second)
+	assert.NoError(t, err)
+}
+
+func TestBlacklistReplaced(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{Clock: clock.NewMock()})
+	mockClock := node.clock.(*clock.Mock)
+
+	require.NoError(t, node.Blacklist("127.0.0.1:3002", time.Minute))
+	mockClock.Add(30 * time.Second)
+	require.NoError(t, node.Blacklist("127.0.0.1:3002", time.Minute))
+
+	mockClock.Add(30 * time.Second)
+	assert.True(t, node.Blacklisted("127.0.0.1:3002"), "expected timer of the replaced ttl not to expire it")
+
+	assert.True(t, node.Unblacklist("127.0.0.1:3002"))
+	assert.False(t, node.Unblacklist("127.0.0.1:3002"))
+	assert.NoError(t, node.checkPeer("127.0.0.1:3002"))
+}
+
+func TestBlacklistInvalid(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, nil)
+
+	assert.Error(t, node.Blacklist("127.0.0.1:3002", 0))
+	assert.Error(t, node.Blacklist("127.0.0.1:3002", 2*MaxBlacklistTTL))
+	assert.Error(t, node.Blacklist("127.0.0.1:3001", time.Minute))
+	assert.Empty(t, node.BlacklistedMembers())
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/util"
+)
+
+// A MemberUpdate is an administrative change of a remote member that is part
+// of a bulk update, see Node.BulkUpdate. It either evicts the member, or
+// replaces its labels with Labels.
+type MemberUpdate struct {
+	Address string            `json:"address"`
+	Evict   bool              `json:"evict,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// BulkUpdate applies administrative changes to several remote members at
+// once, for orchestrated operations such as draining a rack: evicting its
+// members and relabeling the members that take over. All updates are
+// validated before any is applied, and none is applied if one is invalid.
+// The changes are applied to the membership as a single update, so the
+// membership checksum and the ring are recomputed once and listeners see a
+// single MemberlistChangesAppliedEvent, followed by a BulkUpdateEvent.
+//
+// Evicted members are declared faulty. Like any suspicion, the eviction is
+// refuted by a member that is still running, so members are evicted after
+// they were shut down. Only alive members can be relabeled; they adopt the
+// labels with a new incarnation number, but do not persist them.
+func (n *Node) BulkUpdate(updates []MemberUpdate) ([]Change, error) {
+	if !n.Ready() {
+		return nil, ErrNodeNotReady
+	}
+
+	changes, err := n.bulkChanges(updates)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := n.memberlist.Update(changes)
+
+	event := BulkUpdateEvent{}
+	for _, change := range applied {
+		if change.Status == Faulty {
+			event.Evicted = append(event.Evicted, change.Address)
+		} else {
+			event.Relabeled = append(event.Relabeled, change.Address)
+		}
+	}
+	if len(applied) > 0 {
+		n.logger.WithFields(log.Fields{
+			"evicted":   event.Evicted,
+			"relabeled": event.Relabeled,
+		}).Info("applied bulk membership update")
+		n.emit(event)
+	}
+
+	return applied, nil
+}
+
+// bulkChanges validates the updates and returns the changes that apply them.
+func (n *Node) bulkChanges(updates []MemberUpdate) ([]Change, error) {
+	if len(updates) == 0 {
+		return nil, errors.New("bulk update has no member updates")
+	}
+
+	seen := make(map[string]bool, len(updates))
+	changes := make([]Change, 0, len(updates))
+	for _, update := range updates {
+		if update.Address == n.address {
+			return nil, errors.New("the local member cannot be changed in a bulk update")
+		}
+		if seen[update.Address] {
+			return nil, fmt.Errorf("member %s is updated more than once", update.Address)
+		}
+		seen[update.Address] = true
+
+		change, err := n.bulkChange(update)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// bulkChange returns the change that applies the update to its member.
+func (n *Node) bulkChange(update MemberUpdate) (Change, error) {
+	if update.Evict == (update.Labels != nil) {
+		return Change{}, fmt.Errorf("update of member %s must either evict or relabel it",
+			update.Address)
+	}
+
+	member, ok := n.memberlist.Member(update.Address)
+	if !ok {
+		return Change{}, fmt.Errorf("member %s is not known", update.Address)
+	}
+
+	member.RLock()
+	change := Change{
+		Source:            n.address,
+		SourceIncarnation: n.Incarnation(),
+		Address:           member.Address,
+		Incarnation:       member.Incarnation,
+		Status:            member.Status,
+		Health:            member.Health,
+		Ramp:              member.Ramp,
+		Addresses:         member.Addresses,
+		Annotations:       member.Annotations,
+		Labels:            member.Labels,
+		JoinedAt:          member.JoinedAt,
+		Timestamp:         util.Timestamp(time.Now()),
+	}
+	member.RUnlock()
+
+	if update.Evict {
+		if change.Status != Alive && change.Status != Suspect {
+			return Change{}, fmt.Errorf("member %s is not reachable", update.Address)
+		}
+		change.Status = Faulty
+		return change, nil
+	}
+
+	if change.Status != Alive {
+		return Change{}, fmt.Errorf("member %s is not alive", update.Address)
+	}
+	if err := validateLabels(update.Labels); err != nil {
+		return Change{}, err
+	}
+
+	// a higher incarnation number makes the members, including the relabeled
+	// one, take the new labels
+	change.Incarnation++
+	change.Labels = copyLabels(update.Labels)
+	if len(change.Labels) == 0 {
+		change.Labels = nil
+	}
+	return change, nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events"
+)
+
+func newBulkUpdateNode(t *testing.T) *testNode {
+	tnode := newChannelNode(t)
+	bootstrapNodes(t, tnode)
+	tnode.node.memberlist.Update([]Change{
+		{Address: "127.0.0.1:3002", Incarnation: 1, Status: Alive},
+		{Address: "127.0.0.1:3003", Incarnation: 1, Status: Alive},
+		{Address: "127.0.0.1:3004", Incarnation: 1, Status: Faulty},
+	})
+	return tnode
+}
+
+func TestBulkUpdate(t *testing.T) {
+	tnode := newBulkUpdateNode(t)
+	defer tnode.Destroy()
+
+	var applied []MemberlistChangesAppliedEvent
+	var bulk []BulkUpdateEvent
+	tnode.node.RegisterListener(ListenerFunc(func(event events.Event) {
+		switch event := event.(type) {
+		case MemberlistChangesAppliedEvent:
+			applied = append(applied, event)
+		case BulkUpdateEvent:
+			bulk = append(bulk, event)
+		}
+	}))
+
+	changes, err := tnode.node.BulkUpdate([]MemberUpdate{
+		{Address: "127.0.0.1:3002", Evict: true},
+		{Address: "127.0.0.1:3003", Labels: map[string]string{"rack": "b"}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, changes, 2)
+
+	assert.Len(t, applied, 1, "expected the changes to be applied in a single update")
+	assert.Len(t, applied[0].Changes, 2)
+	assert.Equal(t, []BulkUpdateEvent{{
+		Evicted:   []string{"127.0.0.1:3002"},
+		Relabeled: []string{"127.0.0.1:3003"},
+	}}, bulk)
+
+	evicted, _ := tnode.node.memberlist.Member("127.0.0.1:3002")
+	assert.Equal(t, Faulty, evicted.Status)
+
+	relabeled, _ := tnode.node.memberlist.Member("127.0.0.1:3003")
+	assert.Equal(t, int64(2), relabeled.Incarnation)
+	assert.Equal(t, map[string]string{"rack": "b"}, relabeled.Labels)
+}
+
+func TestBulkUpdateInvalid(t *testing.T) {
+	tnode := newBulkUpdateNode(t)
+	defer tnode.Destroy()
+
+	tests := []struct {
+		name    string
+		updates []MemberUpdate
+	}{
+		{"empty", nil},
+		{"local member", []MemberUpdate{{Address: tnode.node.Address(), Evict: true}}},
+		{"unknown member", []MemberUpdate{{Address: "127.0.0.1:3009", Evict: true}}},
+		{"duplicate", []MemberUpdate{
+			{Address: "127.0.0.1:3002", Evict: true},
+			{Address: "127.0.0.1:3002", Evict: true},
+		}},
+		{"evict and relabel", []MemberUpdate{
+			{Address: "127.0.0.1:3002", Evict: true, Labels: map[string]string{"rack": "b"}},
+		}},
+		{"nothing to do", []MemberUpdate{{Address: "127.0.0.1:3002"}}},
+		{"faulty member", []MemberUpdate{{Address: "127.0.0.1:3004", Evict: true}}},
+		{"invalid update after valid one", []MemberUpdate{
+			{Address: "127.0.0.1:3002", Evict: true},
+			{Address: "127.0.0.1:3004", Labels: map[string]string{"rack": "b"}},
+		}},
+	}
+
+	checksum := tnode.node.memberlist.Checksum()
+	for _, test := range tests {
+		_, err := tnode.node.BulkUpdate(test.updates)
+		assert.Error(t, err, test.name)
+	}
+	assert.Equal(t, checksum, tnode.node.memberlist.Checksum(), "expected no update to be applied")
+}
+
+func TestBulkUpdateNotReady(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, nil)
+	_, err := node.BulkUpdate([]MemberUpdate{{Address: "127.0.0.1:3002", Evict: true}})
+	assert.Equal(t, ErrNodeNotReady, err)
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+)
+
+// Direction of a captured protocol message, as seen from the capturing node.
+const (
+	// Inbound messages are received by the capturing node.
+	Inbound = "inbound"
+
+	// Outbound messages are sent by the capturing node.
+	Outbound = "outbound"
+)
+
+// A CapturedMessage is a protocol message recorded by a Capturer. Captured
+// messages are serialized as JSON, one message per line, and can be fed back
+// into a node with Replay.
+type CapturedMessage struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Local     string          `json:"local"`
+	Remote    string          `json:"remote"`
+	Direction string          `json:"direction"`
+	Endpoint  string          `json:"endpoint"`
+	Response  bool            `json:"response"`
+	Size      int             `json:"size"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// A Capturer records protocol messages sent and received by a node. Capture
+// is called from the protocol goroutines and must be thread safe.
+type Capturer interface {
+	Capture(msg CapturedMessage)
+}
+
+// capture records a protocol message if capturing is enabled on the node, and
+// logs it if the current protocol period is sampled, see DebugSampling.
+func (n *Node) capture(direction, remote, endpoint string, response bool, body interface{}) {
+	sampled := n.debugSampled(remote)
+	if n.capturer == nil && !sampled {
+		return
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		n.logger.WithField("error", err).Warn("unable to capture protocol message")
+		return
+	}
+
+	if sampled {
+		n.logger.WithFields(log.Fields{
+			"remote":    remote,
+			"direction": direction,
+			"endpoint":  endpoint,
+			"response":  response,
+			"body":      string(raw),
+		}).Info("sampled protocol message")
+	}
+
+	if n.capturer == nil {
+		return
+	}
+
+	n.capturer.Capture(CapturedMessage{
+		Timestamp: n.clock.Now(),
+		Local:     n.address,
+		Remote:    remote,
+		Direction: direction,
+		Endpoint:  endpoint,
+		Response:  response,
+		Size:      len(raw),
+		Body:      raw,
+	})
+}
+
+// A CaptureBuffer is a Capturer that keeps the most recent messages in memory.
+type CaptureBuffer struct {
+	messages []CapturedMessage
+	next     int
+	full     bool
+	sync.Mutex
+}
+
+// NewCaptureBuffer returns a CaptureBuffer that holds up to size messages.
+func NewCaptureBuffer(size int) *CaptureBuffer {
+	if size < 1 {
+		size = 1
+	}
+
+	return &CaptureBuffer{
+		messages: make([]CapturedMessage, size),
+	}
+}
+
+// Capture adds the message to the buffer, overwriting the oldest message if
+// the buffer is full.
+func (b *CaptureBuffer) Capture(msg CapturedMessage) {
+	b.Lock()
+	b.messages[b.next] = msg
+	b.next = (b.next + 1) % len(b.messages)
+	if b.next == 0 {
+		b.full = true
+	}
+	b.Unlock()
+}
+
+// Messages returns the buffered messages, oldest first.
+func (b *CaptureBuffer) Messages() []CapturedMessage {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.full {
+		return append([]CapturedMessage(nil), b.messages[:b.next]...)
+	}
+
+	messages := make([]CapturedMessage, 0, len(b.messages))
+	messages = append(messages, b.messages[b.next:]...)
+	return append(messages, b.messages[:b.next]...)
+}
+
+// WriteTo writes the buffered messages to w in the capture file format.
+func (b *CaptureBuffer) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	encoder := json.NewEncoder(cw)
+
+	for _, msg := range b.Messages() {
+		if err := encoder.Encode(msg); err != nil {
+			return cw.n, err
+		}
+	}
+
+	return cw.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// A CaptureWriter is a Capturer that streams messages to a writer, typically
+// a file, as they are captured.
+type CaptureWriter struct {
+	encoder *json.Encoder
+	err     error
+	sync.Mutex
+}
+
+// NewCaptureWriter returns a CaptureWriter that writes to w.
+func NewCaptureWriter(w io.Writer) *CaptureWriter {
+	return &CaptureWriter{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Capture writes the message. After the first write error all subsequent
+// messages are dropped; the error is available through Err.
+func (c *CaptureWriter) Capture(msg CapturedMessage) {
+	c.Lock()
+	if c.err == nil {
+		c.err = c.encoder.Encode(msg)
+	}
+	c.Unlock()
+}
+
+// Err returns the first error encountered while writing messages.
+func (c *CaptureWriter) Err() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.err
+}
+
+// ReadCapture reads messages in the capture file format from r.
+func ReadCapture(r io.Reader) ([]CapturedMessage, error) {
+	var messages []CapturedMessage
+
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var msg CapturedMessage
+		err := decoder.Decode(&msg)
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, msg)
+	}
+}
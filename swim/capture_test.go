@@ -0,0 +1,160 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureBufferWrapsAround(t *testing.T) {
+	b := NewCaptureBuffer(3)
+	assert.Empty(t, b.Messages())
+
+	for _, endpoint := range []string{"a", "b"} {
+		b.Capture(CapturedMessage{Endpoint: endpoint})
+	}
+	assert.Len(t, b.Messages(), 2)
+
+	for _, endpoint := range []string{"c", "d", "e"} {
+		b.Capture(CapturedMessage{Endpoint: endpoint})
+	}
+
+	var endpoints []string
+	for _, msg := range b.Messages() {
+		endpoints = append(endpoints, msg.Endpoint)
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, endpoints, "expected oldest messages to be overwritten")
+}
+
+func TestCaptureWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCaptureWriter(&buf)
+
+	w.Capture(CapturedMessage{Endpoint: "/protocol/ping", Direction: Inbound, Size: 2, Body: []byte("{}")})
+	w.Capture(CapturedMessage{Endpoint: "/protocol/ping", Direction: Outbound, Response: true, Size: 2, Body: []byte("{}")})
+	require.NoError(t, w.Err())
+
+	messages, err := ReadCapture(&buf)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, Inbound, messages[0].Direction)
+	assert.True(t, messages[1].Response)
+	assert.Equal(t, 2, messages[1].Size)
+}
+
+func TestReadCaptureInvalid(t *testing.T) {
+	_, err := ReadCapture(bytes.NewBufferString("not json"))
+	assert.Error(t, err)
+}
+
+func TestCaptureAndReplay(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	capture := NewCaptureBuffer(100)
+	tnode.node.capturer = capture
+
+	bootstrapNodes(t, tnode, tpeer)
+
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err, "expected ping to succeed")
+
+	messages := capture.Messages()
+	require.NotEmpty(t, messages)
+
+	var pingSent, pingResponse bool
+	for _, msg := range messages {
+		assert.Equal(t, tnode.node.Address(), msg.Local)
+		assert.Equal(t, len(msg.Body), msg.Size)
+		if msg.Endpoint == "/protocol/ping" {
+			pingSent = pingSent || msg.Direction == Outbound && !msg.Response
+			pingResponse = pingResponse || msg.Direction == Inbound && msg.Response
+		}
+	}
+	assert.True(t, pingSent, "expected outbound ping to be captured")
+	assert.True(t, pingResponse, "expected ping response to be captured")
+
+	replay := newChannelNodeWithHostPort(t, tnode.node.Address())
+	defer replay.Destroy()
+	_, err = replay.node.Bootstrap(&BootstrapOptions{
+		DiscoverProvider: &StaticHostList{[]string{replay.node.Address()}},
+		Stopped:          true,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, Replay(replay.node, messages))
+
+	member, ok := replay.node.memberlist.Member(tpeer.node.Address())
+	require.True(t, ok, "expected peer to be replayed into the memberlist")
+	assert.Equal(t, Alive, member.Status)
+}
+
+func TestReplaySync(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	capture := NewCaptureBuffer(100)
+	tnode.node.capturer = capture
+
+	bootstrapNodes(t, tpeer, tnode)
+	tnode.node.recordPeerFeatures(tpeer.node.Address(), protocolFeatures)
+	tpeer.node.memberlist.Update([]Change{{Address: "127.0.0.1:2", Status: Alive, Incarnation: 1}})
+	tpeer.node.disseminator.ClearChanges()
+
+	require.NoError(t, tnode.node.antiEntropySync())
+
+	var syncs []CapturedMessage
+	for _, msg := range capture.Messages() {
+		if msg.Endpoint == "/protocol/sync" {
+			syncs = append(syncs, msg)
+		}
+	}
+	require.NotEmpty(t, syncs, "expected sync messages to be captured")
+
+	replay := newChannelNodeWithHostPort(t, tnode.node.Address())
+	defer replay.Destroy()
+	_, err := replay.node.Bootstrap(&BootstrapOptions{
+		DiscoverProvider: &StaticHostList{[]string{replay.node.Address()}},
+		Stopped:          true,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, Replay(replay.node, syncs))
+
+	member, ok := replay.node.memberlist.Member("127.0.0.1:2")
+	require.True(t, ok, "expected the synced membership to be replayed")
+	assert.Equal(t, Alive, member.Status)
+}
+
+func TestReplayUnknownEndpoint(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	err := Replay(tnode.node, []CapturedMessage{{Direction: Inbound, Endpoint: "/unknown"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/uber-common/bark"
+)
+
+// A ChecksumVersion selects what the membership checksum is computed over.
+// Nodes compare membership checksums to detect diverged membership, so a
+// change to a member that is not covered by the checksum goes unnoticed
+// until the member changes otherwise.
+type ChecksumVersion int
+
+const (
+	// ChecksumV1 computes the checksum over the address, status and
+	// incarnation number of members.
+	ChecksumV1 ChecksumVersion = 1
+
+	// ChecksumV2 additionally covers the routing relevant state of members:
+	// their health, ramp and advertised addresses, so that changes in how
+	// keys are routed to a member are detected as divergence.
+	ChecksumV2 ChecksumVersion = 2
+
+	// ChecksumV3 additionally covers the labels of members, so that members
+	// that route work by label detect diverged labels.
+	ChecksumV3 ChecksumVersion = 3
+)
+
+// checksumAlgorithms are the algorithms identifying checksum versions. The
+// algorithm is advertised during join so that nodes computing checksums
+// differently do not full sync forever.
+var checksumAlgorithms = map[ChecksumVersion]string{
+	ChecksumV1: "farmhash32",
+	ChecksumV2: "farmhash32-v2",
+	ChecksumV3: "farmhash32-v3",
+}
+
+// algorithm returns the algorithm identifying the checksum version.
+func (v ChecksumVersion) algorithm() string {
+	return checksumAlgorithms[v]
+}
+
+// Valid returns whether v is a known checksum version.
+func (v ChecksumVersion) Valid() bool {
+	_, ok := checksumAlgorithms[v]
+	return ok
+}
+
+// checksumVersionOf returns the checksum version an algorithm identifies. Ok is
+// false for unknown algorithms.
+func checksumVersionOf(algorithm string) (version ChecksumVersion, ok bool) {
+	for v, a := range checksumAlgorithms {
+		if a == algorithm {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// memberString returns the string the checksum covers for the member.
+func (v ChecksumVersion) memberString(member *Member) string {
+	if v != ChecksumV2 && v != ChecksumV3 {
+		return fmt.Sprintf("%s%s%v", member.Address, member.Status, member.Incarnation)
+	}
+
+	addresses := append([]string(nil), member.Addresses...)
+	sort.Strings(addresses)
+
+	s := fmt.Sprintf("%s|%s|%d|%s|%d|%s", member.Address, member.Status,
+		member.Incarnation, member.Health, member.Ramp, strings.Join(addresses, ","))
+	if v == ChecksumV2 {
+		return s
+	}
+
+	labels := make([]string, 0, len(member.Labels))
+	for key, value := range member.Labels {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+
+	return s + "|" + strings.Join(labels, ",")
+}
+
+// ChecksumVersion returns the version of the membership checksum the node
+// computes.
+func (n *Node) ChecksumVersion() ChecksumVersion {
+	return n.memberlist.ChecksumVersion()
+}
+
+// adoptChecksumAlgorithm switches the node to the checksum version of the
+// cluster it joins, identified by the algorithm the remote node advertised. The
+// version of the cluster wins so that nodes configured with a different
+// version can still join it, while the configured version is still computed
+// for nodes that share it, see memberlist.ChecksumMatches. Unknown algorithms
+// are an error.
+func (n *Node) adoptChecksumAlgorithm(remote, algorithm string) error {
+	version, ok := checksumVersionOf(algorithm)
+	if !ok {
+		return fmt.Errorf("cluster member %s computes membership checksums "+
+			"with %q, while the local node uses %q", remote,
+			algorithm, n.ChecksumVersion().algorithm())
+	}
+
+	old := n.memberlist.SetChecksumVersion(version)
+	if old == version {
+		return nil
+	}
+
+	n.logger.WithFields(log.Fields{
+		"remote": remote,
+		"old":    old,
+		"new":    version,
+	}).Info("adopted membership checksum version of the cluster")
+	n.emit(ChecksumVersionChangedEvent{
+		Remote:     remote,
+		OldVersion: old,
+		NewVersion: version,
+	})
+
+	return nil
+}
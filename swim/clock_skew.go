@@ -0,0 +1,135 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+)
+
+// skewSmoothing is the weight of a new sample in the estimated clock offset
+// and round trip time of a peer.
+const skewSmoothing = 0.25
+
+// ClockSkew is the estimated offset of the clock of a peer from the local
+// clock, and the round trip time of the pings it was estimated from. The
+// estimate is accurate to within half the round trip time.
+type ClockSkew struct {
+	// Offset is the time of the peer minus the local time. It is positive
+	// when the clock of the peer is ahead.
+	Offset time.Duration `json:"offset"`
+
+	RTT time.Duration `json:"rtt"`
+
+	// Updated is the local time of the last sample.
+	Updated time.Time `json:"updated"`
+}
+
+// skewState contains the clock skew estimated for each peer the node pinged.
+type skewState struct {
+	threshold time.Duration
+	peers     map[string]ClockSkew
+	exceeded  map[string]bool
+	sync.RWMutex
+}
+
+// ClockSkew returns the estimated clock skew of the peer at address. Ok is
+// false if the node has not completed a ping with the peer yet, or the peer
+// runs a version that does not send its time in ping responses.
+func (n *Node) ClockSkew(address string) (skew ClockSkew, ok bool) {
+	n.skew.RLock()
+	skew, ok = n.skew.peers[address]
+	n.skew.RUnlock()
+	return skew, ok
+}
+
+// ClockSkews returns the estimated clock skew of all peers the node completed
+// a ping with.
+func (n *Node) ClockSkews() map[string]ClockSkew {
+	n.skew.RLock()
+	skews := make(map[string]ClockSkew, len(n.skew.peers))
+	for address, skew := range n.skew.peers {
+		skews[address] = skew
+	}
+	n.skew.RUnlock()
+	return skews
+}
+
+// recordClockSkew updates the estimated clock skew of a peer from a ping that
+// was sent at local time sent, answered at the peer's time remote, and whose
+// response was received at local time received. It emits a
+// ClockSkewEstimatedEvent, and a ClockSkewExceededEvent when the offset rises
+// above the threshold.
+func (n *Node) recordClockSkew(address string, sent, remote, received time.Time) {
+	if address == "" || address == n.address || remote.IsZero() {
+		return
+	}
+
+	rtt := received.Sub(sent)
+	if rtt < 0 {
+		return
+	}
+	// assume the peer answered halfway through the round trip
+	offset := remote.Sub(sent.Add(rtt / 2))
+
+	n.skew.Lock()
+	if n.skew.peers == nil {
+		n.skew.peers = make(map[string]ClockSkew)
+		n.skew.exceeded = make(map[string]bool)
+	}
+	if previous, ok := n.skew.peers[address]; ok {
+		offset = previous.Offset + time.Duration(skewSmoothing*float64(offset-previous.Offset))
+		rtt = previous.RTT + time.Duration(skewSmoothing*float64(rtt-previous.RTT))
+	}
+	n.skew.peers[address] = ClockSkew{
+		Offset:  offset,
+		RTT:     rtt,
+		Updated: received,
+	}
+
+	threshold := n.skew.threshold
+	over := threshold > 0 && (offset > threshold || offset < -threshold)
+	exceeded := over && !n.skew.exceeded[address]
+	n.skew.exceeded[address] = over
+	n.skew.Unlock()
+
+	n.emit(ClockSkewEstimatedEvent{
+		Remote: address,
+		Offset: offset,
+		RTT:    rtt,
+	})
+
+	if exceeded {
+		n.emit(ClockSkewExceededEvent{
+			Remote:    address,
+			Offset:    offset,
+			Threshold: threshold,
+		})
+
+		n.logger.WithFields(log.Fields{
+			"remote":    address,
+			"offset":    offset,
+			"threshold": threshold,
+		}).Warn("clock skew of peer exceeded threshold")
+	}
+}
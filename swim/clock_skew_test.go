@@ -0,0 +1,103 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events/test/mocks"
+)
+
+func TestClockSkewEstimated(t *testing.T) {
+	local := clock.NewMock()
+	local.Set(time.Unix(1000, 0))
+	remote := clock.NewMock()
+	remote.Set(time.Unix(1005, 0))
+
+	tnode := newChannelNodeWithClock(t, local)
+	tpeer := newChannelNodeWithClock(t, remote)
+	defer destroyNodes(tnode, tpeer)
+	bootstrapNodes(t, tnode, tpeer)
+
+	_, ok := tnode.node.ClockSkew(tpeer.node.Address())
+	assert.False(t, ok, "expected no estimate before a ping")
+
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	// the mock clocks stand still, so the round trip takes no time
+	skew, ok := tnode.node.ClockSkew(tpeer.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, 5*time.Second, skew.Offset)
+	assert.Equal(t, time.Duration(0), skew.RTT)
+	assert.Equal(t, map[string]ClockSkew{tpeer.node.Address(): skew}, tnode.node.ClockSkews())
+}
+
+func TestClockSkewSmoothed(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, nil)
+	sent := time.Unix(1000, 0)
+
+	node.recordClockSkew("127.0.0.1:3002", sent, sent.Add(time.Second), sent.Add(2*time.Second))
+	skew, _ := node.ClockSkew("127.0.0.1:3002")
+	assert.Equal(t, ClockSkew{Offset: 0, RTT: 2 * time.Second, Updated: sent.Add(2 * time.Second)}, skew,
+		"expected the peer to answer halfway through the round trip")
+
+	node.recordClockSkew("127.0.0.1:3002", sent, sent.Add(4*time.Second), sent)
+	skew, _ = node.ClockSkew("127.0.0.1:3002")
+	assert.Equal(t, time.Second, skew.Offset, "expected new samples to be smoothed")
+	assert.Equal(t, 1500*time.Millisecond, skew.RTT)
+
+	node.recordClockSkew("127.0.0.1:3003", sent, time.Time{}, sent)
+	_, ok := node.ClockSkew("127.0.0.1:3003")
+	assert.False(t, ok, "expected peers that do not report their time to be ignored")
+}
+
+func TestClockSkewExceeded(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		ClockSkewThreshold: time.Second,
+	})
+	listener := &mocks.EventListener{}
+	listener.On("HandleEvent", mock.Anything).Return()
+	node.RegisterListener(listener)
+
+	sent := time.Unix(1000, 0)
+	exceeded := ClockSkewExceededEvent{
+		Remote:    "127.0.0.1:3002",
+		Offset:    -2 * time.Second,
+		Threshold: time.Second,
+	}
+
+	node.recordClockSkew("127.0.0.1:3002", sent, sent.Add(-2*time.Second), sent)
+	node.recordClockSkew("127.0.0.1:3002", sent, sent.Add(-2*time.Second), sent)
+
+	// the threshold is exceeded once, not for every sample
+	listener.AssertCalled(t, "HandleEvent", ClockSkewEstimatedEvent{
+		Remote: "127.0.0.1:3002",
+		Offset: -2 * time.Second,
+	})
+	listener.AssertNumberOfCalls(t, "HandleEvent", 3)
+	listener.AssertCalled(t, "HandleEvent", exceeded)
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// confirmationTimeout returns the timeout of a suspect period that was
+// confirmed by the given number of members other than the first suspector. As
+// in Lifeguard, it shrinks logarithmically from max without confirmations to
+// min once the expected number of confirmations arrived.
+func confirmationTimeout(min, max time.Duration, confirmations, expected int) time.Duration {
+	if expected <= 0 || confirmations <= 0 {
+		return max
+	}
+
+	shrink := math.Log(float64(confirmations+1)) / math.Log(float64(expected+1))
+	timeout := max - time.Duration(shrink*float64(max-min))
+	if timeout < min {
+		timeout = min
+	}
+	return timeout
+}
+
+// suspectorsOf returns the members that suspect the member according to the
+// change: its source and the confirmers it carries.
+func suspectorsOf(suspect suspect) []string {
+	change, ok := suspect.(Change)
+	if !ok {
+		return nil
+	}
+
+	var suspectors []string
+	for _, address := range append([]string{change.Source}, change.Confirmers...) {
+		if address != "" && address != change.Address {
+			suspectors = append(suspectors, address)
+		}
+	}
+	return suspectors
+}
+
+// startConfirmedTimer starts a suspect period that can be shortened by
+// independent confirmations of the suspicion, see Confirm. It should be
+// called while holding the lock.
+func (s *suspicion) startConfirmedTimer(suspect suspect, min time.Duration) {
+	max := min * time.Duration(s.maxTimeoutFactor)
+	t := s.startTimer(suspect, max)
+	t.started = s.node.clock.Now()
+	t.min, t.max = min, max
+	t.suspectors = make(map[string]bool)
+	for _, address := range suspectorsOf(suspect) {
+		t.suspectors[address] = true
+	}
+	s.accelerateNoLock(t)
+}
+
+// Confirm records the members that suspect the member according to a suspect
+// change with the same incarnation number as the running suspect period, and
+// shortens the period accordingly. It returns all members known to suspect
+// the member, sorted, if the change added any, so that the confirmation can
+// be disseminated, or nil otherwise.
+func (s *suspicion) Confirm(change Change) []string {
+	s.Lock()
+	defer s.Unlock()
+
+	t, ok := s.timers[change.Address]
+	if !ok || t.suspectors == nil || t.suspect.incarnation() != change.Incarnation {
+		return nil
+	}
+
+	added := false
+	for _, address := range suspectorsOf(change) {
+		if !t.suspectors[address] {
+			t.suspectors[address] = true
+			added = true
+		}
+	}
+	if !added {
+		return nil
+	}
+
+	s.accelerateNoLock(t)
+
+	suspectors := make([]string, 0, len(t.suspectors))
+	for address := range t.suspectors {
+		suspectors = append(suspectors, address)
+	}
+	sort.Strings(suspectors)
+	return suspectors
+}
+
+// confirmSuspicion records the members that suspect the member of a suspect
+// change, and disseminates the change with all known suspectors if it added
+// any.
+func (n *Node) confirmSuspicion(change Change) {
+	suspectors := n.suspicion.Confirm(change)
+	if suspectors == nil {
+		return
+	}
+
+	change.Confirmers = nil
+	for _, address := range suspectors {
+		if address != change.Source {
+			change.Confirmers = append(change.Confirmers, address)
+		}
+	}
+	n.disseminator.RecordChange(change)
+}
+
+// accelerateNoLock moves the deadline of the suspect period forward according
+// to the number of members that confirmed the suspicion. It should be called
+// while holding the lock.
+func (s *suspicion) accelerateNoLock(t *suspectTimer) {
+	confirmations := len(t.suspectors) - 1
+	timeout := confirmationTimeout(t.min, t.max, confirmations, s.expectedConfirmations)
+	deadline := t.started.Add(timeout)
+	if !deadline.Before(t.deadline) || !t.timer.Stop() {
+		return
+	}
+
+	remaining := deadline.Sub(s.node.clock.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	t.timer.Reset(remaining)
+	t.deadline = deadline
+
+	s.node.emit(SuspicionConfirmedEvent{
+		Address:       t.suspect.address(),
+		Confirmations: confirmations,
+		Timeout:       timeout,
+	})
+}
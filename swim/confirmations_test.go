@@ -0,0 +1,80 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/gl-works/ringpop-go/util"
+)
+
+func TestConfirmationTimeout(t *testing.T) {
+	assert.Equal(t, 6*time.Second, confirmationTimeout(time.Second, 6*time.Second, 0, 3))
+	assert.Equal(t, 3500*time.Millisecond, confirmationTimeout(time.Second, 6*time.Second, 1, 3))
+	assert.Equal(t, time.Second, confirmationTimeout(time.Second, 6*time.Second, 3, 3))
+	assert.Equal(t, time.Second, confirmationTimeout(time.Second, 6*time.Second, 5, 3))
+}
+
+func TestSuspicionConfirmations(t *testing.T) {
+	mockClock := clock.NewMock()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		SuspicionTimeout:          time.Minute,
+		SuspicionConfirmations:    3,
+		SuspicionMaxTimeoutFactor: 6,
+		Clock:                     mockClock,
+	})
+	defer node.Destroy()
+
+	incarnation := util.TimeNowMS()
+	address := "127.0.0.1:3002"
+	node.memberlist.MakeAlive(node.Address(), incarnation)
+	node.memberlist.MakeAlive(address, incarnation)
+	member, _ := node.memberlist.Member(address)
+
+	suspect := func(source string) {
+		node.memberlist.Update([]Change{{
+			Source:      source,
+			Address:     address,
+			Incarnation: incarnation,
+			Status:      Suspect,
+		}})
+	}
+
+	suspect("127.0.0.1:3003")
+	mockClock.Add(3 * time.Minute)
+	assert.Equal(t, Suspect, member.Status, "expected longer suspect period without confirmations")
+
+	suspect("127.0.0.1:3004")
+	change, _ := node.disseminator.ChangesByAddress(address)
+	assert.Equal(t, "127.0.0.1:3004", change.Source)
+	assert.Equal(t, []string{"127.0.0.1:3003"}, change.Confirmers, "expected confirmation to be disseminated")
+
+	// a repeated confirmation does not shorten the period again
+	suspect("127.0.0.1:3004")
+	assert.Equal(t, Suspect, member.Status)
+
+	// one of three expected confirmations shortens the period to 3.5 minutes
+	mockClock.Add(30 * time.Second)
+	assert.Equal(t, Faulty, member.Status, "expected shortened suspect period to expire")
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// DebugSampling configures the logging of full protocol messages for a
+// fraction of protocol periods, to get insight into the protocol during an
+// incident without logging every message.
+type DebugSampling struct {
+	// Rate is the fraction of protocol periods, between 0 and 1, in which
+	// protocol messages are logged. Zero disables the logging.
+	Rate float64 `json:"rate"`
+
+	// Peer, when set, restricts the logging to messages exchanged with the
+	// member at this address.
+	Peer string `json:"peer,omitempty"`
+}
+
+// debugState contains the debug sampling of the node and whether the current
+// protocol period is sampled.
+type debugState struct {
+	sampling DebugSampling
+	sampled  bool
+	sync.RWMutex
+}
+
+// SetDebugSampling changes the debug sampling of the node, see DebugSampling.
+// It takes effect from the next protocol period.
+func (n *Node) SetDebugSampling(sampling DebugSampling) error {
+	if sampling.Rate < 0 || sampling.Rate > 1 {
+		return fmt.Errorf("debug sampling rate must be between 0 and 1, got %v", sampling.Rate)
+	}
+
+	n.debug.Lock()
+	n.debug.sampling = sampling
+	n.debug.sampled = false
+	n.debug.Unlock()
+
+	n.logger.WithField("sampling", sampling).Info("debug sampling changed")
+	return nil
+}
+
+// DebugSampling returns the debug sampling of the node.
+func (n *Node) DebugSampling() DebugSampling {
+	n.debug.RLock()
+	sampling := n.debug.sampling
+	n.debug.RUnlock()
+	return sampling
+}
+
+// sampleProtocolPeriod decides whether the protocol period that starts is
+// sampled.
+func (n *Node) sampleProtocolPeriod() {
+	n.debug.Lock()
+	rate := n.debug.sampling.Rate
+	n.debug.sampled = rate > 0 && rand.Float64() < rate
+	n.debug.Unlock()
+}
+
+// debugSampled returns whether messages exchanged with the remote member are
+// logged in the current protocol period.
+func (n *Node) debugSampled(remote string) bool {
+	n.debug.RLock()
+	sampled := n.debug.sampled &&
+		(n.debug.sampling.Peer == "" || n.debug.sampling.Peer == remote)
+	n.debug.RUnlock()
+	return sampled
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugSampling(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, nil)
+
+	node.sampleProtocolPeriod()
+	assert.False(t, node.debugSampled("127.0.0.1:3002"), "expected no sampling by default")
+
+	assert.NoError(t, node.SetDebugSampling(DebugSampling{Rate: 1}))
+	assert.False(t, node.debugSampled("127.0.0.1:3002"), "expected sampling to start with the next protocol period")
+
+	node.sampleProtocolPeriod()
+	assert.True(t, node.debugSampled("127.0.0.1:3002"))
+
+	assert.NoError(t, node.SetDebugSampling(DebugSampling{Rate: 1, Peer: "127.0.0.1:3003"}))
+	node.sampleProtocolPeriod()
+	assert.False(t, node.debugSampled("127.0.0.1:3002"), "expected other peers not to be sampled")
+	assert.True(t, node.debugSampled("127.0.0.1:3003"))
+
+	assert.Error(t, node.SetDebugSampling(DebugSampling{Rate: -0.1}))
+	assert.Equal(t, DebugSampling{Rate: 1, Peer: "127.0.0.1:3003"}, node.DebugSampling(),
+		"expected invalid sampling to be rejected")
+}
+
+func TestDebugSamplingOption(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		DebugSampling: DebugSampling{Rate: 1},
+	})
+
+	node.sampleProtocolPeriod()
+	assert.True(t, node.debugSampled("127.0.0.1:3002"))
+}
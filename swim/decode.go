@@ -0,0 +1,205 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// A MalformedMessageError is returned when a protocol message received from a
+// peer cannot be decoded or does not have the shape the protocol requires.
+// Malformed messages are rejected before any of their changes are applied.
+type MalformedMessageError struct {
+	// Endpoint is the protocol endpoint the message was sent to.
+	Endpoint string
+
+	// Response is true if the message is a response, false if it is a
+	// request.
+	Response bool
+
+	Err error
+}
+
+func (e *MalformedMessageError) Error() string {
+	kind := "request"
+	if e.Response {
+		kind = "response"
+	}
+	return fmt.Sprintf("malformed %s %s: %v", e.Endpoint, kind, e.Err)
+}
+
+// validateChanges checks that every change names a member and a known status,
+// as applying a change with an unknown status would panic.
+func validateChanges(changes []Change) error {
+	for i, change := range changes {
+		if change.Address == "" {
+			return fmt.Errorf("change %d has no address", i)
+		}
+
+		switch change.Status {
+		case Alive, Suspect, Faulty, Leave, Tombstone:
+		default:
+			return fmt.Errorf("change %d for %s has invalid status %q", i,
+				change.Address, change.Status)
+		}
+
+		if change.Ramp < 0 || change.Ramp > 99 {
+			return fmt.Errorf("change %d for %s has invalid ramp %d", i,
+				change.Address, change.Ramp)
+		}
+
+		for _, address := range change.Addresses {
+			if address == "" {
+				return fmt.Errorf("change %d for %s has an empty advertised address",
+					i, change.Address)
+			}
+		}
+
+		if err := validateAnnotations(change.Annotations); err != nil {
+			return fmt.Errorf("change %d for %s has invalid annotations: %v",
+				i, change.Address, err)
+		}
+
+		if err := validateLabels(change.Labels); err != nil {
+			return fmt.Errorf("change %d for %s has invalid labels: %v",
+				i, change.Address, err)
+		}
+	}
+	return nil
+}
+
+func (r *joinRequest) validate() error {
+	if r.Source == "" {
+		return errors.New("no source")
+	}
+	return nil
+}
+
+func (r *joinResponse) validate() error {
+	if r.Coordinator == "" {
+		return errors.New("no coordinator")
+	}
+	return validateChanges(r.Membership)
+}
+
+func (p *ping) validate() error {
+	if p.Source == "" {
+		return errors.New("no source")
+	}
+	return validateChanges(p.Changes)
+}
+
+func (r *pingRequest) validate() error {
+	if r.Source == "" {
+		return errors.New("no source")
+	}
+	if r.Target == "" {
+		return errors.New("no target")
+	}
+	return validateChanges(r.Changes)
+}
+
+func (r *pingResponse) validate() error {
+	if r.Target == "" {
+		return errors.New("no target")
+	}
+	return validateChanges(r.Changes)
+}
+
+// A message is a protocol message that can be validated after decoding.
+type message interface {
+	validate() error
+}
+
+// validateMessage validates a decoded message and wraps the failure in a
+// MalformedMessageError.
+func validateMessage(endpoint string, response bool, msg message) error {
+	if err := msg.validate(); err != nil {
+		return &MalformedMessageError{
+			Endpoint: endpoint,
+			Response: response,
+			Err:      err,
+		}
+	}
+	return nil
+}
+
+// decodeMessage decodes and validates the JSON encoded body of a message.
+func decodeMessage(endpoint string, response bool, body []byte, msg message) error {
+	if err := json.Unmarshal(body, msg); err != nil {
+		return &MalformedMessageError{
+			Endpoint: endpoint,
+			Response: response,
+			Err:      err,
+		}
+	}
+	return validateMessage(endpoint, response, msg)
+}
+
+func decodeJoinRequest(body []byte) (*joinRequest, error) {
+	var req joinRequest
+	if err := decodeMessage("/protocol/join", false, body, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func decodeJoinResponse(body []byte) (*joinResponse, error) {
+	var res joinResponse
+	if err := decodeMessage("/protocol/join", true, body, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func decodePing(body []byte, response bool) (*ping, error) {
+	var p ping
+	if err := decodeMessage("/protocol/ping", response, body, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func decodePingRequest(body []byte) (*pingRequest, error) {
+	var req pingRequest
+	if err := decodeMessage("/protocol/ping-req", false, body, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func decodePingResponse(body []byte) (*pingResponse, error) {
+	var res pingResponse
+	if err := decodeMessage("/protocol/ping-req", true, body, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func decodeSync(body []byte, response bool) (*syncMessage, error) {
+	var msg syncMessage
+	if err := decodeMessage("/protocol/sync", response, body, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checkDecoded fails the fuzz test when a decoder returns anything but a
+// MalformedMessageError, or accepts changes that cannot be applied.
+func checkDecoded(t *testing.T, err error, changes []Change) {
+	if err != nil {
+		if _, ok := err.(*MalformedMessageError); !ok {
+			t.Fatalf("expected a MalformedMessageError, got %T: %v", err, err)
+		}
+		return
+	}
+
+	for _, change := range changes {
+		statePrecedence(change.Status)
+	}
+}
+
+func FuzzDecodeJoinRequest(f *testing.F) {
+	f.Add([]byte(`{"app":"test","source":"127.0.0.1:3001","incarnationNumber":1,"timeout":1000}`))
+	f.Add([]byte(`{}`))
+	f.Fuzz(func(t *testing.T, body []byte) {
+		_, err := decodeJoinRequest(body)
+		checkDecoded(t, err, nil)
+	})
+}
+
+func FuzzDecodeJoinResponse(f *testing.F) {
+	f.Add([]byte(`{"app":"test","coordinator":"127.0.0.1:3001","membership":[{"address":"127.0.0.1:3001","status":"alive","incarnationNumber":1}],"membershipChecksum":1}`))
+	f.Add([]byte(`{"coordinator":"127.0.0.1:3001","membership":[{"address":"127.0.0.1:3001","status":"zombie"}]}`))
+	f.Fuzz(func(t *testing.T, body []byte) {
+		res, err := decodeJoinResponse(body)
+		if err == nil {
+			checkDecoded(t, err, res.Membership)
+			return
+		}
+		checkDecoded(t, err, nil)
+	})
+}
+
+func FuzzDecodePing(f *testing.F) {
+	f.Add([]byte(`{"changes":[{"address":"127.0.0.1:3002","status":"suspect","incarnationNumber":2}],"checksum":1,"source":"127.0.0.1:3001","sourceIncarnationNumber":1}`), false)
+	f.Add([]byte(`{"source":"127.0.0.1:3001","pushback":{"retryAfter":100}}`), true)
+	f.Fuzz(func(t *testing.T, body []byte, response bool) {
+		p, err := decodePing(body, response)
+		if err == nil {
+			checkDecoded(t, err, p.Changes)
+			return
+		}
+		checkDecoded(t, err, nil)
+	})
+}
+
+func FuzzDecodePingRequest(f *testing.F) {
+	f.Add([]byte(`{"source":"127.0.0.1:3001","sourceIncarnationNumber":1,"target":"127.0.0.1:3002","checksum":1,"changes":[]}`))
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req, err := decodePingRequest(body)
+		if err == nil {
+			checkDecoded(t, err, req.Changes)
+			return
+		}
+		checkDecoded(t, err, nil)
+	})
+}
+
+func FuzzDecodePingResponse(f *testing.F) {
+	f.Add([]byte(`{"pingStatus":true,"target":"127.0.0.1:3002","changes":[{"address":"127.0.0.1:3002","status":"faulty"}]}`))
+	f.Fuzz(func(t *testing.T, body []byte) {
+		res, err := decodePingResponse(body)
+		if err == nil {
+			checkDecoded(t, err, res.Changes)
+			return
+		}
+		checkDecoded(t, err, nil)
+	})
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode func() error
+	}{
+		{"invalid json", func() error {
+			_, err := decodePing([]byte(`{"source":`), false)
+			return err
+		}},
+		{"ping without source", func() error {
+			_, err := decodePing([]byte(`{"changes":[]}`), false)
+			return err
+		}},
+		{"change without address", func() error {
+			_, err := decodePing([]byte(`{"source":"a","changes":[{"status":"alive"}]}`), false)
+			return err
+		}},
+		{"change with unknown status", func() error {
+			_, err := decodePingResponse([]byte(`{"target":"a","changes":[{"address":"b","status":"zombie"}]}`))
+			return err
+		}},
+		{"ping-req without target", func() error {
+			_, err := decodePingRequest([]byte(`{"source":"a"}`))
+			return err
+		}},
+		{"join without source", func() error {
+			_, err := decodeJoinRequest([]byte(`{"app":"test"}`))
+			return err
+		}},
+		{"join response without coordinator", func() error {
+			_, err := decodeJoinResponse([]byte(`{"membership":[]}`))
+			return err
+		}},
+		{"wrong field type", func() error {
+			_, err := decodeJoinResponse([]byte(`{"coordinator":1}`))
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		err := test.decode()
+		assert.IsType(t, &MalformedMessageError{}, err, test.name)
+	}
+}
+
+func TestPingHandlerRejectsMalformedChanges(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+	bootstrapNodes(t, tnode)
+
+	_, err := tnode.node.pingHandler(nil, &ping{
+		Source:  "127.0.0.1:3002",
+		Changes: []Change{{Address: "127.0.0.1:3002", Status: "zombie"}},
+	})
+	assert.IsType(t, &MalformedMessageError{}, err)
+
+	_, ok := tnode.node.memberlist.Member("127.0.0.1:3002")
+	assert.False(t, ok, "expected malformed change not to be applied")
+}
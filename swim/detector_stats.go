@@ -0,0 +1,168 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"sync"
+
+	log "github.com/uber-common/bark"
+)
+
+// minDetectorSamples is the number of resolved suspicions needed before the
+// false positive rate is compared against the threshold, so a single refuted
+// suspicion right after startup does not trip it.
+const minDetectorSamples = 10
+
+// MemberDetectorStats contains the suspicions of a single member as observed
+// by the node.
+type MemberDetectorStats struct {
+	// Suspected is the number of times the member was suspected.
+	Suspected int64 `json:"suspected"`
+
+	// Refuted is the number of suspicions the member refuted by asserting
+	// it was alive, the false positives of the failure detector.
+	Refuted int64 `json:"refuted"`
+
+	// Confirmed is the number of suspicions that ended with the member
+	// being declared faulty.
+	Confirmed int64 `json:"confirmed"`
+}
+
+// DetectorStats contains statistics about the accuracy of the failure
+// detector, cluster-wide and per member, as observed by the node.
+type DetectorStats struct {
+	MemberDetectorStats
+
+	// FalsePositiveRate is the fraction of resolved suspicions that were
+	// refuted. A high rate indicates that the suspicion timeout or ping
+	// timeouts are too aggressive for the network or load of the cluster.
+	FalsePositiveRate float64 `json:"falsePositiveRate"`
+
+	Members map[string]MemberDetectorStats `json:"members"`
+}
+
+// detectorState tracks the suspicions observed by the node.
+type detectorState struct {
+	total     MemberDetectorStats
+	members   map[string]*MemberDetectorStats
+	threshold float64
+	exceeded  bool
+	sync.Mutex
+}
+
+// a statusTransition is a change in status of a member applied to the
+// memberlist.
+type statusTransition struct {
+	address  string
+	from, to string
+}
+
+// falsePositiveRate returns the fraction of resolved suspicions that were
+// refuted, or zero if none were resolved.
+func (s MemberDetectorStats) falsePositiveRate() float64 {
+	resolved := s.Refuted + s.Confirmed
+	if resolved == 0 {
+		return 0
+	}
+	return float64(s.Refuted) / float64(resolved)
+}
+
+// DetectorStats returns statistics about the suspicions of members observed
+// by the node and how many of them were false positives.
+func (n *Node) DetectorStats() DetectorStats {
+	n.detector.Lock()
+	defer n.detector.Unlock()
+
+	stats := DetectorStats{
+		MemberDetectorStats: n.detector.total,
+		FalsePositiveRate:   n.detector.total.falsePositiveRate(),
+		Members:             make(map[string]MemberDetectorStats, len(n.detector.members)),
+	}
+	for address, member := range n.detector.members {
+		stats.Members[address] = *member
+	}
+
+	return stats
+}
+
+// trackSuspicions records suspicions that were started, refuted or confirmed
+// by the transitions and emits a SuspicionResolvedEvent for every suspicion
+// that ended.
+func (n *Node) trackSuspicions(transitions []statusTransition) {
+	var resolved []SuspicionResolvedEvent
+	var exceeded *FalsePositiveRateExceededEvent
+
+	n.detector.Lock()
+	if n.detector.members == nil {
+		n.detector.members = make(map[string]*MemberDetectorStats)
+	}
+
+	for _, t := range transitions {
+		member, ok := n.detector.members[t.address]
+		if !ok {
+			member = &MemberDetectorStats{}
+			n.detector.members[t.address] = member
+		}
+
+		switch {
+		case t.to == Suspect && t.from != Suspect:
+			member.Suspected++
+			n.detector.total.Suspected++
+
+		case t.from == Suspect && t.to == Alive:
+			member.Refuted++
+			n.detector.total.Refuted++
+			resolved = append(resolved, SuspicionResolvedEvent{Address: t.address, Refuted: true})
+
+		case t.from == Suspect && t.to == Faulty:
+			member.Confirmed++
+			n.detector.total.Confirmed++
+			resolved = append(resolved, SuspicionResolvedEvent{Address: t.address})
+		}
+	}
+
+	if len(resolved) > 0 && n.detector.threshold > 0 &&
+		n.detector.total.Refuted+n.detector.total.Confirmed >= minDetectorSamples {
+
+		rate := n.detector.total.falsePositiveRate()
+		if rate > n.detector.threshold && !n.detector.exceeded {
+			exceeded = &FalsePositiveRateExceededEvent{
+				Rate:      rate,
+				Threshold: n.detector.threshold,
+			}
+		}
+		n.detector.exceeded = rate > n.detector.threshold
+	}
+	n.detector.Unlock()
+
+	for _, event := range resolved {
+		n.emit(event)
+	}
+
+	if exceeded != nil {
+		n.emit(*exceeded)
+
+		n.logger.WithFields(log.Fields{
+			"rate":      exceeded.Rate,
+			"threshold": exceeded.Threshold,
+		}).Warn("failure detector false positive rate exceeded threshold")
+	}
+}
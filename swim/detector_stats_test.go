@@ -0,0 +1,95 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/gl-works/ringpop-go/events/test/mocks"
+	"github.com/gl-works/ringpop-go/util"
+)
+
+func TestDetectorStats(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, nil)
+	incarnation := util.TimeNowMS()
+	node.memberlist.MakeAlive(node.Address(), incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3002", incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3003", incarnation)
+
+	// refuted by a higher incarnation
+	node.memberlist.MakeSuspect("127.0.0.1:3002", incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3002", incarnation+1)
+
+	// confirmed
+	node.memberlist.MakeSuspect("127.0.0.1:3003", incarnation)
+	node.memberlist.MakeFaulty("127.0.0.1:3003", incarnation)
+
+	// the local member refutes suspicions of itself right away
+	node.memberlist.MakeSuspect(node.Address(), incarnation)
+
+	stats := node.DetectorStats()
+	assert.Equal(t, int64(3), stats.Suspected)
+	assert.Equal(t, int64(2), stats.Refuted)
+	assert.Equal(t, int64(1), stats.Confirmed)
+	assert.InDelta(t, 2.0/3.0, stats.FalsePositiveRate, 0.0001)
+
+	assert.Equal(t, MemberDetectorStats{Suspected: 1, Refuted: 1}, stats.Members["127.0.0.1:3002"])
+	assert.Equal(t, MemberDetectorStats{Suspected: 1, Confirmed: 1}, stats.Members["127.0.0.1:3003"])
+	assert.Equal(t, MemberDetectorStats{Suspected: 1, Refuted: 1}, stats.Members[node.Address()])
+}
+
+func TestFalsePositiveRateExceeded(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		FalsePositiveThreshold: 0.5,
+	})
+	incarnation := util.TimeNowMS()
+	node.memberlist.MakeAlive(node.Address(), incarnation)
+
+	listener := &mocks.EventListener{}
+	listener.On("HandleEvent", mock.Anything).Return()
+	node.RegisterListener(listener)
+
+	for i := 0; i < minDetectorSamples; i++ {
+		address := fmt.Sprintf("127.0.0.1:%d", 4000+i)
+		node.memberlist.MakeAlive(address, incarnation)
+		node.memberlist.MakeSuspect(address, incarnation)
+		node.memberlist.MakeAlive(address, incarnation+1)
+	}
+
+	listener.AssertCalled(t, "HandleEvent", FalsePositiveRateExceededEvent{Rate: 1, Threshold: 0.5})
+
+	// the event is only sent again after the rate dropped below the threshold
+	address := "127.0.0.1:5000"
+	node.memberlist.MakeAlive(address, incarnation)
+	node.memberlist.MakeSuspect(address, incarnation)
+	node.memberlist.MakeAlive(address, incarnation+1)
+
+	exceeded := 0
+	for _, call := range listener.Calls {
+		if _, ok := call.Arguments.Get(0).(FalsePositiveRateExceededEvent); ok {
+			exceeded++
+		}
+	}
+	assert.Equal(t, 1, exceeded, "expected a single event while the rate stays above the threshold")
+}
@@ -38,6 +38,9 @@ const defaultPFactor int = 15
 type pChange struct {
 	Change
 	p int
+
+	// seq orders changes by the time they were recorded
+	seq uint64
 }
 
 // A disseminator propagates changes to other nodes.
@@ -50,6 +53,19 @@ type disseminator struct {
 	maxP    int
 	pFactor int
 
+	// maxBacklog is the number of changes kept in memory, beyond which the
+	// oldest low priority changes are spilled to the backlog store. Zero
+	// disables spilling.
+	maxBacklog int
+	backlog    BacklogStore
+	seq        uint64
+
+	// fullSyncBudget bounds the size in bytes of the membership sent in a
+	// full sync, which is then sent in chunks over multiple rounds resuming
+	// from fullSyncCursors. Zero sends the full membership at once.
+	fullSyncBudget  int
+	fullSyncCursors map[string]fullSyncCursor
+
 	sync.RWMutex
 
 	logger log.Logger
@@ -60,8 +76,12 @@ func newDisseminator(n *Node) *disseminator {
 	d := &disseminator{
 		node:    n,
 		changes: make(map[string]*pChange),
+
+		fullSyncCursors: make(map[string]fullSyncCursor),
+
 		maxP:    defaultPFactor,
 		pFactor: defaultPFactor,
+		backlog: NewMemoryBacklogStore(),
 		logger:  logging.Logger("disseminator").WithField("local", n.Address()),
 	}
 
@@ -110,6 +130,12 @@ func (d *disseminator) FullSync() (changes []Change) {
 			Source:            d.node.Address(),
 			SourceIncarnation: d.node.Incarnation(),
 			Status:            member.Status,
+			Health:            member.Health,
+			Ramp:              member.Ramp,
+			Addresses:         member.Addresses,
+			Annotations:       member.Annotations,
+			Labels:            member.Labels,
+			JoinedAt:          member.JoinedAt,
 		})
 	}
 
@@ -141,18 +167,23 @@ func (d *disseminator) bumpPiggybackCounters(changes []Change) {
 			delete(d.changes, c.Address)
 		}
 	}
+	restored := d.restoreNoLock()
 	d.Unlock()
+
+	d.emitBacklog(0, restored)
 }
 
 // IssueAsReceiver collects all changes a node needs when responding to a ping
 // or ping-req. Unlike IssueAsSender, IssueAsReceiver automatically increments
 // the piggyback counters because it's difficult to find out whether a response
 // reaches the client. The second return value indicates whether a full sync
-// is triggered.
+// is triggered. The checksums of the sender are compared as described by
+// memberlist.ChecksumMatches.
 func (d *disseminator) IssueAsReceiver(
 	senderAddress string,
 	senderIncarnation int64,
-	senderChecksum uint32) (changes []Change, fullSync bool) {
+	senderChecksum uint32,
+	senderChecksums map[string]uint32) (changes []Change, fullSync bool) {
 
 	changes = d.issueChanges()
 
@@ -161,11 +192,18 @@ func (d *disseminator) IssueAsReceiver(
 
 	d.bumpPiggybackCounters(changes)
 
-	if len(changes) > 0 || d.node.memberlist.Checksum() == senderChecksum {
+	if d.node.memberlist.ChecksumMatches(senderChecksum, senderChecksums) {
+		d.clearFullSyncCursor(senderAddress)
+		d.node.clearMismatches(senderAddress)
+		return changes, false
+	}
+
+	if len(changes) > 0 {
 		return changes, false
 	}
 
 	d.node.emit(FullSyncEvent{senderAddress, senderChecksum})
+	d.node.recordMismatch(senderAddress, senderChecksum)
 
 	d.node.logger.WithFields(log.Fields{
 		"localChecksum":  d.node.memberlist.Checksum(),
@@ -173,7 +211,7 @@ func (d *disseminator) IssueAsReceiver(
 		"remoteChecksum": senderChecksum,
 	}).Info("full sync")
 
-	return d.FullSync(), true
+	return d.fullSyncTo(senderAddress), true
 }
 
 // filterChangesFromSender returns changes that didn't originate at the sender.
@@ -200,6 +238,12 @@ func (d *disseminator) issueChanges() []Change {
 	// To make JSON output [] instead of null on empty change list
 	result := make([]Change, 0)
 	for _, change := range d.changes {
+		// changes whose dissemination is disabled are kept until it is
+		// enabled again, except those about the local member, which refute
+		// suspicions about it
+		if change.Address != d.node.Address() && !d.node.SubsystemEnabled(DisseminationSubsystem(change.Status)) {
+			continue
+		}
 		result = append(result, change.Change)
 	}
 
@@ -218,8 +262,11 @@ func (d *disseminator) ClearChanges() {
 
 func (d *disseminator) RecordChange(change Change) {
 	d.Lock()
-	d.changes[change.Address] = &pChange{change, 0}
+	d.changes[change.Address] = &pChange{Change: change, seq: d.nextSeqNoLock()}
+	spilled := d.spillNoLock()
 	d.Unlock()
+
+	d.emitBacklog(spilled, 0)
 }
 
 func (d *disseminator) ClearChange(address string) {
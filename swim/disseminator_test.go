@@ -145,21 +145,21 @@ func (s *DisseminatorTestSuite) TestIssueChangesAsReceiver() {
 	s.m.MakeSuspect(suspectAddr, s.incarnation)
 	s.m.MakeFaulty(faultyAddr, s.incarnation)
 
-	changes, fs := s.d.IssueAsReceiver(s.node.Address(), s.node.Incarnation(), s.m.Checksum())
+	changes, fs := s.d.IssueAsReceiver(s.node.Address(), s.node.Incarnation(), s.m.Checksum(), nil)
 	s.Len(changes, 0, "expected no changes to be issued for same sender/receiver")
 	s.False(fs, "expected changes to not be a full sync")
 
-	changes, fs = s.d.IssueAsReceiver(aliveAddr, s.incarnation, s.m.Checksum())
+	changes, fs = s.d.IssueAsReceiver(aliveAddr, s.incarnation, s.m.Checksum(), nil)
 	s.Len(changes, 3, "expected three changes to be issued")
 	s.False(fs, "expected changes to not be a full sync")
 
 	s.d.ClearChanges()
 
-	changes, fs = s.d.IssueAsReceiver(aliveAddr, s.incarnation, s.m.Checksum())
+	changes, fs = s.d.IssueAsReceiver(aliveAddr, s.incarnation, s.m.Checksum(), nil)
 	s.Len(changes, 0, "expected to get no changes")
 	s.False(fs, "expected changes to not be a full sync")
 
-	changes, fs = s.d.IssueAsReceiver(aliveAddr, s.incarnation, s.m.Checksum()+1)
+	changes, fs = s.d.IssueAsReceiver(aliveAddr, s.incarnation, s.m.Checksum()+1, nil)
 	s.Len(changes, 4, "expected change to be issued for each member in membership")
 	s.True(fs, "expected changes to be a full sync")
 }
@@ -208,7 +208,7 @@ func (s *DisseminatorTestSuite) TestBumpPiggybackAsReceiver() {
 	s.Equal(sc.p, 0, "expected piggyback counter isn't bumped")
 	s.Equal(fc.p, 0, "expected piggyback counter isn't bumped")
 
-	_, _ = s.d.IssueAsReceiver(aliveAddr, s.incarnation, s.m.Checksum())
+	_, _ = s.d.IssueAsReceiver(aliveAddr, s.incarnation, s.m.Checksum(), nil)
 	s.Equal(ac.p, 1, "expected piggyback counter is bumped")
 	s.Equal(sc.p, 1, "expected piggyback counter is bumped")
 	s.Equal(fc.p, 1, "expected piggyback counter is bumped")
@@ -260,15 +260,15 @@ func (s *DisseminatorTestSuite) TestChangesDeletedAsReceiver() {
 
 	s.Equal(0, s.d.changes[address].p, "expected propagations for change to be 0")
 
-	changes, _ := s.d.IssueAsReceiver(address, s.incarnation, s.m.Checksum())
+	changes, _ := s.d.IssueAsReceiver(address, s.incarnation, s.m.Checksum(), nil)
 	s.Len(changes, 1, "expected one change to be issued")
 	s.Equal(1, s.d.changes[address].p, "expected propagations for change to be 1")
 
-	changes, _ = s.d.IssueAsReceiver(address, s.incarnation, s.m.Checksum())
+	changes, _ = s.d.IssueAsReceiver(address, s.incarnation, s.m.Checksum(), nil)
 	s.Len(changes, 1, "expected one change to be issued")
 	s.Empty(s.d.changes, "expected changes are cleared after 2 propagations")
 
-	changes, _ = s.d.IssueAsReceiver(address, s.incarnation, s.m.Checksum())
+	changes, _ = s.d.IssueAsReceiver(address, s.incarnation, s.m.Checksum(), nil)
 	s.Empty(changes, "expected no changes to be issued")
 
 	_, ok := s.d.changes[address]
@@ -353,6 +353,60 @@ func contains(cs []Change, address string) bool {
 	return false
 }
 
+func (s *DisseminatorTestSuite) TestFullSyncChunks() {
+	for _, address := range fakeHostPorts(1, 1, 2, 21) {
+		s.m.MakeAlive(address, s.incarnation)
+	}
+	s.d.ClearChanges()
+
+	members := s.d.FullSync()
+	s.Require().Len(members, 21)
+	s.d.fullSyncBudget = 4 * changeSize(members[0])
+
+	synced := make(map[string]int)
+	rounds := 0
+	for {
+		changes, fullSync := s.d.IssueAsReceiver("127.0.0.1:4000", s.incarnation, 0, nil)
+		s.Require().True(fullSync, "expected a full sync")
+		s.Require().NotEmpty(changes)
+		s.True(len(changes) <= 4, "expected chunk to fit in the budget")
+		for _, change := range changes {
+			synced[change.Address]++
+		}
+		rounds++
+		if _, ok := s.d.fullSyncCursors["127.0.0.1:4000"]; !ok {
+			break
+		}
+		s.Require().True(rounds < 21, "expected full sync to complete")
+	}
+
+	s.True(rounds > 1, "expected full sync to take multiple rounds")
+	s.Len(synced, 21, "expected every member to be synced")
+	for address, count := range synced {
+		s.Equal(1, count, "expected %s to be synced once", address)
+	}
+
+	// a peer with the same membership ends the full sync
+	s.d.IssueAsReceiver("127.0.0.1:4000", s.incarnation, 0, nil)
+	s.Len(s.d.fullSyncCursors, 1)
+	s.d.IssueAsReceiver("127.0.0.1:4000", s.incarnation, s.m.Checksum(), nil)
+	s.Empty(s.d.fullSyncCursors)
+
+	// the cursor of a peer is pruned once it is faulty or left
+	peer := members[0].Address
+	incarnation := s.incarnation
+	for _, status := range []string{Faulty, Leave} {
+		s.d.ClearChanges()
+		s.d.IssueAsReceiver(peer, s.incarnation, 0, nil)
+		s.Contains(s.d.fullSyncCursors, peer)
+		incarnation++
+		s.m.MakeChange(peer, incarnation, status)
+		s.NotContains(s.d.fullSyncCursors, peer, "expected cursor of %s peer to be pruned", status)
+		incarnation++
+		s.m.MakeAlive(peer, incarnation)
+	}
+}
+
 func TestDisseminatorTestSuite(t *testing.T) {
 	suite.Run(t, new(DisseminatorTestSuite))
 }
@@ -0,0 +1,191 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+)
+
+// duplicateState detects members whose address is claimed by two processes,
+// for example an old and a new pod that overlap. Both processes refute the
+// suspicions of the other with their own incarnation numbers, so the member
+// keeps being revived and the ring flaps between them. The processes are told
+// apart by the join time each of them gossips: a member that flaps because it
+// is slow keeps its join time, while an address claimed by two processes
+// switches between their join times. When the join time of a member switches
+// threshold times within window, the newest process is kept and the changes
+// of older processes are ignored until they stopped claiming the address for
+// cooldown. Every node keeps the same process because the choice only depends
+// on the gossiped join times, so the membership checksums converge.
+type duplicateState struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	// lineages are the join times of the processes that last made members
+	// alive, switches the recent times those join times changed, and claims
+	// the addresses found to be claimed by several processes
+	lineages map[string]int64
+	switches map[string][]time.Time
+	claims   map[string]*duplicateClaim
+
+	// alerted is the time another process claiming the local address was
+	// last reported
+	alerted time.Time
+
+	sync.Mutex
+}
+
+// duplicateClaim is an address claimed by several processes. JoinedAt is the
+// join time of the newest of them, and until is when the older processes are
+// no longer ignored if they do not claim the address again.
+type duplicateClaim struct {
+	joinedAt int64
+	until    time.Time
+}
+
+// suppressRevival returns whether a change that makes a member alive is
+// ignored because it comes from an older process claiming the address of the
+// member. It tracks the processes that make members alive, and reports
+// changes that claim the local address with an incarnation the node did not
+// issue. It is called while the memberlist is locked.
+func (n *Node) suppressRevival(member *Member, change Change) bool {
+	d := &n.duplicates
+	if d.threshold <= 0 || change.Status != Alive || change.Incarnation <= member.Incarnation {
+		return false
+	}
+
+	now := n.clock.Now()
+	if change.Address == n.Address() {
+		n.reportDuplicateIdentity(member, change, now)
+		return false
+	}
+
+	// processes that do not gossip their join time cannot be told apart
+	if change.JoinedAt == 0 {
+		return false
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if claim, ok := d.claims[change.Address]; ok {
+		if change.JoinedAt < claim.joinedAt && now.Before(claim.until) {
+			claim.until = now.Add(d.cooldown)
+			return true
+		}
+		if change.JoinedAt >= claim.joinedAt {
+			claim.joinedAt = change.JoinedAt
+			return false
+		}
+		delete(d.claims, change.Address)
+	}
+
+	lineage := d.lineages[change.Address]
+	if d.lineages == nil {
+		d.lineages = make(map[string]int64)
+	}
+	d.lineages[change.Address] = change.JoinedAt
+	if lineage == 0 || lineage == change.JoinedAt {
+		return false
+	}
+
+	switches := d.switches[change.Address]
+	for len(switches) > 0 && now.Sub(switches[0]) > d.window {
+		switches = switches[1:]
+	}
+	switches = append(switches, now)
+
+	if len(switches) < d.threshold {
+		if d.switches == nil {
+			d.switches = make(map[string][]time.Time)
+		}
+		d.switches[change.Address] = switches
+		return false
+	}
+
+	delete(d.switches, change.Address)
+	newest := lineage
+	if change.JoinedAt > newest {
+		newest = change.JoinedAt
+	}
+	if d.claims == nil {
+		d.claims = make(map[string]*duplicateClaim)
+	}
+	d.claims[change.Address] = &duplicateClaim{
+		joinedAt: newest,
+		until:    now.Add(d.cooldown),
+	}
+
+	n.logger.WithFields(log.Fields{
+		"member":   change.Address,
+		"joinedAt": newest,
+		"switches": len(switches),
+		"cooldown": d.cooldown,
+	}).Error("member address is claimed by several processes; ignoring the older processes")
+	n.emit(DuplicateAddressEvent{
+		Address:  change.Address,
+		JoinedAt: newest,
+		Switches: len(switches),
+		Cooldown: d.cooldown,
+	})
+	return change.JoinedAt < newest
+}
+
+// reportDuplicateIdentity reports a change that asserts the local member is
+// alive with a higher incarnation than the node issued, which means another
+// process claims the local address. Reports are limited to one per cool-down.
+func (n *Node) reportDuplicateIdentity(member *Member, change Change, now time.Time) {
+	d := &n.duplicates
+	d.Lock()
+	if !d.alerted.IsZero() && now.Sub(d.alerted) < d.cooldown {
+		d.Unlock()
+		return
+	}
+	d.alerted = now
+	d.Unlock()
+
+	n.logger.WithFields(log.Fields{
+		"source":           change.Source,
+		"incarnation":      change.Incarnation,
+		"localIncarnation": member.Incarnation,
+	}).Error("another process claims the local address")
+	n.emit(DuplicateIdentityEvent{
+		Address:          change.Address,
+		Incarnation:      change.Incarnation,
+		LocalIncarnation: member.Incarnation,
+	})
+}
+
+// DuplicateAddress returns whether the address is claimed by several
+// processes and the changes of the older ones are ignored, see
+// DuplicateThreshold.
+func (n *Node) DuplicateAddress(address string) bool {
+	d := &n.duplicates
+	d.Lock()
+	defer d.Unlock()
+
+	claim, ok := d.claims[address]
+	return ok && n.clock.Now().Before(claim.until)
+}
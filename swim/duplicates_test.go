@@ -0,0 +1,123 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/util"
+)
+
+func TestDuplicateAddressLineages(t *testing.T) {
+	mockClock := clock.NewMock()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		SuspicionTimeout:   time.Hour,
+		DuplicateThreshold: 2,
+		DuplicateWindow:    time.Minute,
+		DuplicateCooldown:  5 * time.Minute,
+		Clock:              mockClock,
+	})
+	defer node.Destroy()
+
+	var detected []DuplicateAddressEvent
+	node.RegisterListener(ListenerFunc(func(event events.Event) {
+		if event, ok := event.(DuplicateAddressEvent); ok {
+			detected = append(detected, event)
+		}
+	}))
+
+	incarnation := util.TimeNowMS()
+	address := "127.0.0.1:3002"
+	node.memberlist.MakeAlive(node.Address(), incarnation)
+	node.memberlist.Update([]Change{{Address: address, Incarnation: incarnation, Status: Alive, JoinedAt: 1}})
+	member, _ := node.memberlist.Member(address)
+
+	revive := func(joinedAt int64) {
+		node.memberlist.Update([]Change{{Address: address, Incarnation: incarnation, Status: Suspect, JoinedAt: member.JoinedAt}})
+		incarnation++
+		node.memberlist.Update([]Change{{Address: address, Incarnation: incarnation, Status: Alive, JoinedAt: joinedAt}})
+	}
+
+	// a member that flaps on its own is never reported
+	for i := 0; i < 5; i++ {
+		revive(1)
+		assert.Equal(t, Alive, member.Status)
+	}
+	assert.Empty(t, detected)
+
+	revive(2)
+	assert.Equal(t, Alive, member.Status, "expected a restarted member to be revived")
+	assert.Empty(t, detected)
+
+	revive(1)
+	assert.Equal(t, Suspect, member.Status, "expected the older process to be ignored")
+	assert.True(t, node.DuplicateAddress(address))
+	assert.Equal(t, []DuplicateAddressEvent{{Address: address, JoinedAt: 2, Switches: 2, Cooldown: 5 * time.Minute}}, detected)
+
+	incarnation++
+	node.memberlist.Update([]Change{{Address: address, Incarnation: incarnation, Status: Alive, JoinedAt: 2}})
+	assert.Equal(t, Alive, member.Status, "expected the newest process to be kept")
+	assert.Equal(t, int64(2), member.JoinedAt)
+
+	mockClock.Add(4 * time.Minute)
+	revive(1)
+	assert.Equal(t, Suspect, member.Status, "expected the older process to extend the cool-down")
+
+	mockClock.Add(5 * time.Minute)
+	assert.False(t, node.DuplicateAddress(address))
+	incarnation++
+	node.memberlist.Update([]Change{{Address: address, Incarnation: incarnation, Status: Alive, JoinedAt: 1}})
+	assert.Equal(t, Alive, member.Status, "expected the older process after the cool-down")
+}
+
+func TestDuplicateIdentity(t *testing.T) {
+	mockClock := clock.NewMock()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		DuplicateThreshold: 2,
+		Clock:              mockClock,
+	})
+	defer node.Destroy()
+
+	var detected []DuplicateIdentityEvent
+	node.RegisterListener(ListenerFunc(func(event events.Event) {
+		if event, ok := event.(DuplicateIdentityEvent); ok {
+			detected = append(detected, event)
+		}
+	}))
+
+	incarnation := util.TimeNowMS()
+	node.memberlist.MakeAlive(node.Address(), incarnation)
+
+	node.memberlist.Update([]Change{{Address: node.Address(), Incarnation: incarnation, Status: Alive}})
+	assert.Empty(t, detected, "expected the local incarnation to be ignored")
+
+	node.memberlist.Update([]Change{{Address: node.Address(), Incarnation: incarnation + 1, Status: Alive}})
+	node.memberlist.Update([]Change{{Address: node.Address(), Incarnation: incarnation + 2, Status: Alive}})
+	assert.Equal(t, []DuplicateIdentityEvent{{
+		Address:          node.Address(),
+		Incarnation:      incarnation + 1,
+		LocalIncarnation: incarnation,
+	}}, detected, "expected one report per cool-down")
+}
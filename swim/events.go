@@ -40,6 +40,17 @@ func (f ListenerFunc) HandleEvent(e events.Event) {
 	f(e)
 }
 
+// A FullSyncChunkEvent is sent when the node sent a chunk of a byte-budgeted
+// full sync to a remote node. Resumed is true if the chunk continued a full
+// sync started in an earlier round, and Complete if it ended the full sync
+type FullSyncChunkEvent struct {
+	Remote   string `json:"remote"`
+	Members  int    `json:"members"`
+	Bytes    int    `json:"bytes"`
+	Resumed  bool   `json:"resumed"`
+	Complete bool   `json:"complete"`
+}
+
 // A MaxPAdjustedEvent occurs when the disseminator adjusts the max propagation
 // count for changes
 type MaxPAdjustedEvent struct {
@@ -93,6 +104,15 @@ const (
 
 	// Destroyed as a JoinFailedReason indicates that the join failed because ringpop was destroyed during the join
 	Destroyed = "destroyed"
+
+	// Mismatch as a JoinFailedReason indicates that the join was refused
+	// because the cluster advertised an incompatible checksum algorithm or
+	// ring configuration
+	Mismatch = "mismatch"
+
+	// MaxAttempts as a JoinFailedReason indicates that the join failed
+	// because not enough nodes were joined in the maximum number of attempts
+	MaxAttempts = "max-attempts"
 )
 
 // A JoinFailedEvent is sent when a join request to remote node did not successfully
@@ -190,6 +210,23 @@ type ChangeFilteredEvent struct {
 	Change Change
 }
 
+// A JoinRetriedEvent is sent when a join attempt did not join enough nodes
+// and the node makes another attempt, after backing off.
+type JoinRetriedEvent struct {
+	// Attempt is the number of the attempt that is made, starting at 2.
+	Attempt int `json:"attempt"`
+
+	// Delay is how long the node backed off before the attempt.
+	Delay time.Duration `json:"delay"`
+
+	NumJoined int `json:"numJoined"`
+	JoinSize  int `json:"joinSize"`
+
+	// Seeds are the outcomes of the attempts to join each seed so far, which
+	// tell why not enough nodes were joined.
+	Seeds []SeedDiagnostic `json:"seeds"`
+}
+
 // A JoinTriesUpdateEvent is sent when the joiner tries to join a group
 type JoinTriesUpdateEvent struct {
 	Retries int
@@ -207,3 +244,319 @@ type RequestBeforeReadyEvent struct {
 
 // A RefuteUpdateEvent is sent when a node detects gossip about its own state that needs to be corrected
 type RefuteUpdateEvent struct{}
+
+// A PushbackReceivedEvent is sent when a remote node signaled that it is
+// overloaded and asked to be backed off from
+type PushbackReceivedEvent struct {
+	Remote     string        `json:"remote"`
+	RetryAfter time.Duration `json:"retryAfter"`
+	Load       float64       `json:"load"`
+}
+
+// A TransportFailureReportedEvent is sent when a transport outside of the
+// protocol failed to connect to a member, moving it to the front of the ping
+// order
+type TransportFailureReportedEvent struct {
+	Remote string `json:"remote"`
+}
+
+// A MessageAuthenticationFailedEvent is sent when a protocol message was
+// rejected because it was not sealed with a key of the keyring of the node
+type MessageAuthenticationFailedEvent struct {
+	Endpoint string `json:"endpoint"`
+	Response bool   `json:"response"`
+}
+
+// A PeerFeaturesEvent is sent when the features negotiated with a peer in a
+// join or ping handshake changed, including the first handshake with the peer
+type PeerFeaturesEvent struct {
+	Remote   string   `json:"remote"`
+	Features Features `json:"features"`
+}
+
+// A SuspicionResolvedEvent is sent when a suspicion of a member ended, either
+// because the member refuted it or because it was declared faulty
+type SuspicionResolvedEvent struct {
+	Address string `json:"address"`
+	Refuted bool   `json:"refuted"`
+}
+
+// A FalsePositiveRateExceededEvent is sent when the fraction of suspicions
+// that were refuted rises above the configured threshold
+type FalsePositiveRateExceededEvent struct {
+	Rate      float64 `json:"rate"`
+	Threshold float64 `json:"threshold"`
+}
+
+// A ChangesSpilledEvent is sent when the disseminator moved changes beyond its
+// backlog limit to the backlog store
+type ChangesSpilledEvent struct {
+	Count int `json:"count"`
+}
+
+// A ChangesRestoredEvent is sent when the disseminator moved spilled changes
+// back from the backlog store to be disseminated
+type ChangesRestoredEvent struct {
+	Count int `json:"count"`
+}
+
+// A SuspectTTLExpiredEvent is sent when a member has been suspect for longer
+// than the suspect TTL and was probed directly to re-evaluate its state
+type SuspectTTLExpiredEvent struct {
+	Address   string        `json:"address"`
+	Duration  time.Duration `json:"duration"`
+	Reachable bool          `json:"reachable"`
+}
+
+// A RampChangedEvent is sent when the local member gossiped a new step of its
+// ramp. Ramp is the percentage of its replica points the member has, or zero
+// once it has its full ownership
+type RampChangedEvent struct {
+	Ramp int `json:"ramp"`
+}
+
+// A SuspicionConfirmedEvent is sent when members independently confirmed the
+// suspicion of a member, and its suspect period was shortened to the timeout
+type SuspicionConfirmedEvent struct {
+	Address       string        `json:"address"`
+	Confirmations int           `json:"confirmations"`
+	Timeout       time.Duration `json:"timeout"`
+}
+
+// A DuplicateAddressEvent is sent when a member switched between processes
+// with different join times so often that its address is likely claimed by
+// several processes. The process that joined at JoinedAt is kept, and the
+// changes of older ones are ignored until they stop for the cool-down.
+type DuplicateAddressEvent struct {
+	Address  string        `json:"address"`
+	JoinedAt int64         `json:"joinedAt"`
+	Switches int           `json:"switches"`
+	Cooldown time.Duration `json:"cooldown"`
+}
+
+// A DuplicateIdentityEvent is sent when a change claims the local address with
+// an incarnation number the node did not issue, which means another process
+// uses the same address
+type DuplicateIdentityEvent struct {
+	Address          string `json:"address"`
+	Incarnation      int64  `json:"incarnation"`
+	LocalIncarnation int64  `json:"localIncarnation"`
+}
+
+// A LocalHealthChangedEvent is sent when the local health score of the node
+// changed, see Node.LocalHealth
+type LocalHealthChangedEvent struct {
+	Score  int    `json:"score"`
+	Reason string `json:"reason"`
+}
+
+// A ProtocolStalledEvent is sent by the watchdog when the protocol period loop
+// has not run for longer than the configured number of protocol periods
+type ProtocolStalledEvent struct {
+	Duration time.Duration `json:"duration"`
+}
+
+// A ClockSkewEstimatedEvent is sent when a ping response updated the estimated
+// clock offset of a peer
+type ClockSkewEstimatedEvent struct {
+	Remote string        `json:"remote"`
+	Offset time.Duration `json:"offset"`
+	RTT    time.Duration `json:"rtt"`
+}
+
+// A ClockSkewExceededEvent is sent when the estimated clock offset of a peer
+// rises above the configured threshold
+type ClockSkewExceededEvent struct {
+	Remote    string        `json:"remote"`
+	Offset    time.Duration `json:"offset"`
+	Threshold time.Duration `json:"threshold"`
+}
+
+// A PartitionStartedEvent is sent when the node started a simulated partition
+// from the peers
+type PartitionStartedEvent struct {
+	Peers    []string      `json:"peers"`
+	Duration time.Duration `json:"duration"`
+}
+
+// A PartitionEndedEvent is sent when a simulated partition ended
+type PartitionEndedEvent struct{}
+
+// A ChecksumRemediationEvent is sent when the node takes a step of its
+// remediation policy because its membership checksum mismatched the checksum
+// of the peer Mismatches times in a row.
+type ChecksumRemediationEvent struct {
+	Peer           string            `json:"peer"`
+	Action         RemediationAction `json:"action"`
+	Mismatches     int               `json:"mismatches"`
+	LocalChecksum  uint32            `json:"localChecksum"`
+	RemoteChecksum uint32            `json:"remoteChecksum"`
+}
+
+// A ChecksumConvergedEvent is sent when the checksum of a peer the
+// remediation policy took steps for matches the checksum of the node again.
+type ChecksumConvergedEvent struct {
+	Peer       string `json:"peer"`
+	Mismatches int    `json:"mismatches"`
+}
+
+// A MemberTombstonedEvent is sent when the node turned a member that has
+// been faulty for longer than the faulty TTL into a tombstone
+type MemberTombstonedEvent struct {
+	Address     string `json:"address"`
+	Incarnation int64  `json:"incarnation"`
+}
+
+// A MemberReapedEvent is sent when the node removed a tombstone from its
+// memberlist
+type MemberReapedEvent struct {
+	Address     string `json:"address"`
+	Incarnation int64  `json:"incarnation"`
+}
+
+// An AntiEntropySyncEvent is sent when the node synced its membership with
+// a peer. Pulled and Pushed are the number of members received from and sent
+// to the peer because the checksums mismatched, and Converged is whether the
+// checksums match after the sync.
+type AntiEntropySyncEvent struct {
+	Peer      string        `json:"peer"`
+	Pulled    int           `json:"pulled"`
+	Pushed    int           `json:"pushed"`
+	Converged bool          `json:"converged"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// An AntiEntropySyncFailedEvent is sent when the node failed to sync its
+// membership with a peer
+type AntiEntropySyncFailedEvent struct {
+	Peer  string `json:"peer"`
+	Error string `json:"error"`
+}
+
+// A MemberBlacklistedEvent is sent when communication with a member was cut
+// for TTL, see Node.Blacklist.
+type MemberBlacklistedEvent struct {
+	Member string        `json:"member"`
+	TTL    time.Duration `json:"ttl"`
+}
+
+// A MemberUnblacklistedEvent is sent when a member was removed from the
+// blacklist, because its ttl expired or it was unblacklisted.
+type MemberUnblacklistedEvent struct {
+	Member  string `json:"member"`
+	Expired bool   `json:"expired"`
+}
+
+// A BulkUpdateEvent is sent when the changes of a bulk update were applied to
+// the membership, see Node.BulkUpdate.
+type BulkUpdateEvent struct {
+	Evicted   []string `json:"evicted"`
+	Relabeled []string `json:"relabeled"`
+}
+
+// A SubsystemToggledEvent is sent when a subsystem was disabled or enabled
+// at runtime, see Node.DisableSubsystem.
+type SubsystemToggledEvent struct {
+	Subsystem Subsystem `json:"subsystem"`
+	Enabled   bool      `json:"enabled"`
+}
+
+// A JoinStaggeredEvent is sent when the node delays its first join requests
+// by Delay to spread the joins of nodes that start at the same time.
+type JoinStaggeredEvent struct {
+	Delay time.Duration `json:"delay"`
+}
+
+// A JoinStormDetectedEvent is sent when the node throttles the first join in
+// an admission window, because more nodes are joining than it admits per
+// window. Limit is the admission limit of the window.
+type JoinStormDetectedEvent struct {
+	Limit int `json:"limit"`
+}
+
+// A JoinThrottledEvent is sent when the node refused the join of Source
+// because it already admitted Limit joins in the current admission window.
+type JoinThrottledEvent struct {
+	Source string `json:"source"`
+	Limit  int    `json:"limit"`
+}
+
+// A PartitionDetectedEvent is sent when the node detected that it is
+// partitioned from the target, whose membership disagrees with the membership
+// of the node about which members are reachable. LocalMembers are the number
+// of members reachable for the node but not for the target, and
+// RemoteMembers the number of members reachable for the target but not for
+// the node.
+type PartitionDetectedEvent struct {
+	Target        string `json:"target"`
+	LocalMembers  int    `json:"localMembers"`
+	RemoteMembers int    `json:"remoteMembers"`
+}
+
+// A PartitionHealedEvent is sent when the target of a detected partition
+// became a reachable member, Duration after the partition was detected
+type PartitionHealedEvent struct {
+	Target   string        `json:"target"`
+	Duration time.Duration `json:"duration"`
+}
+
+// A LabelsChangedEvent is sent when the labels of the local member changed
+type LabelsChangedEvent struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// A LabelsRestoredEvent is sent when the node restored the persisted labels
+// of the local member from its LabelStore before bootstrapping
+type LabelsRestoredEvent struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// An AnnotationsChangedEvent is sent when the annotations of the local member
+// changed
+type AnnotationsChangedEvent struct {
+	Annotations map[string]string `json:"annotations"`
+}
+
+// An AdminDeniedEvent is sent when a call to an admin endpoint was denied
+// because the caller is not authenticated or not authorized
+type AdminDeniedEvent struct {
+	Endpoint string `json:"endpoint"`
+	Reason   string `json:"reason"`
+}
+
+// A FaultyVetoedEvent is sent when the arbiter vetoed declaring a suspect
+// member faulty
+type FaultyVetoedEvent struct {
+	Address     string `json:"address"`
+	Incarnation int64  `json:"incarnation"`
+	Reason      string `json:"reason"`
+}
+
+// A MaintenanceDeferredEvent is sent when a suspect member in a maintenance
+// window was not declared faulty at the end of its suspect period, which is
+// started over. Confirmations is the number of its suspect periods that ended
+// before
+type MaintenanceDeferredEvent struct {
+	Address       string `json:"address"`
+	Confirmations int    `json:"confirmations"`
+}
+
+// A ManifestImportedEvent is sent when the node seeded its membership from a
+// manifest during bootstrap
+type ManifestImportedEvent struct {
+	Members int `json:"members"`
+}
+
+// A PingVetoedEvent is sent when the ping hook vetoed the ack to a ping
+type PingVetoedEvent struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// A ChecksumVersionChangedEvent is sent when the node adopted the membership
+// checksum version of the cluster advertised by a remote node during join
+type ChecksumVersionChangedEvent struct {
+	Remote     string          `json:"remote"`
+	OldVersion ChecksumVersion `json:"oldVersion"`
+	NewVersion ChecksumVersion `json:"newVersion"`
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"time"
+
+	log "github.com/uber-common/bark"
+)
+
+// A Verdict is the outcome of probing a member with a FailureDetector.
+type Verdict int
+
+const (
+	// Reachable means the member responded to the probe.
+	Reachable Verdict = iota
+
+	// Unreachable means the member did not respond to the probe and is
+	// declared suspect.
+	Unreachable
+
+	// Inconclusive means the probe could not determine whether the member
+	// is reachable, for example because all helpers of an indirect ping
+	// failed. The member's status is left unchanged.
+	Inconclusive
+)
+
+// String returns the name of the verdict.
+func (v Verdict) String() string {
+	switch v {
+	case Reachable:
+		return "reachable"
+	case Unreachable:
+		return "unreachable"
+	case Inconclusive:
+		return "inconclusive"
+	}
+	return "unknown"
+}
+
+// A FailureDetector decides whether the member the gossip loop selects in a
+// protocol period is reachable. It replaces the direct and indirect pings of
+// the SWIM protocol, so alternative detectors, such as a phi-accrual
+// detector, can be used without changing the gossip loop. Dissemination and
+// the suspicion protocol are unaffected. The default is a SWIMDetector.
+type FailureDetector interface {
+	// Probe probes the member at address using prober and returns the
+	// verdict. It is called once per protocol period and should return
+	// within it.
+	Probe(prober Prober, address string) Verdict
+}
+
+// The FailureDetectorFunc type is an adapter to allow the use of ordinary
+// functions as FailureDetectors.
+type FailureDetectorFunc func(prober Prober, address string) Verdict
+
+// Probe calls f(prober, address).
+func (f FailureDetectorFunc) Probe(prober Prober, address string) Verdict {
+	return f(prober, address)
+}
+
+// A Prober sends the probes of the SWIM protocol on behalf of a
+// FailureDetector. Changes piggybacked on the responses are applied to the
+// membership as usual. The timeouts are multiplied by the local health
+// multiplier of the node, see Node.LocalHealth.
+type Prober interface {
+	// Ping pings the member at address directly and returns an error if it
+	// does not respond within timeout.
+	Ping(address string, timeout time.Duration) error
+
+	// PingRequest asks up to size other members to ping target and returns
+	// whether any of them reached it, and the errors of the helpers that
+	// could not be asked.
+	PingRequest(target string, size int, timeout time.Duration) (reached bool, errs []error)
+}
+
+// nodeProber is the Prober the node passes to its FailureDetector.
+type nodeProber struct {
+	node *Node
+}
+
+// Ping implements Prober.
+func (p nodeProber) Ping(address string, timeout time.Duration) error {
+	res, err := sendPing(p.node, address, p.node.localHealth.scale(timeout))
+	if err != nil {
+		return err
+	}
+
+	p.node.recordPushback(address, res.Pushback)
+	if !p.node.Quarantined(address) {
+		p.node.memberlist.Update(res.Changes)
+	}
+	return nil
+}
+
+// PingRequest implements Prober. It logs a warning with the logger of the node
+// when none of the helpers could be asked.
+func (p nodeProber) PingRequest(target string, size int, timeout time.Duration) (bool, []error) {
+	reached, errs := indirectPing(p.node, target, size, p.node.localHealth.scale(timeout))
+	if len(errs) > 0 && len(errs) == size {
+		p.node.logger.WithFields(log.Fields{
+			"target":    target,
+			"errors":    errs,
+			"numErrors": len(errs),
+		}).Warn("ping request inconclusive due to errors")
+	}
+	return reached, errs
+}
+
+// SWIMDetector is the failure detector of the SWIM protocol. It pings the
+// member directly and, if it does not respond, asks PingRequestSize other
+// members to ping it. The member is unreachable if none of them reach it.
+type SWIMDetector struct {
+	PingTimeout        time.Duration
+	PingRequestTimeout time.Duration
+	PingRequestSize    int
+}
+
+// Probe implements FailureDetector.
+func (d *SWIMDetector) Probe(prober Prober, address string) Verdict {
+	if err := prober.Ping(address, d.PingTimeout); err == nil {
+		return Reachable
+	}
+
+	reached, errs := prober.PingRequest(address, d.PingRequestSize, d.PingRequestTimeout)
+
+	// if all helper nodes are unreachable, the indirect ping is inconclusive
+	if len(errs) == d.PingRequestSize {
+		return Inconclusive
+	}
+
+	if !reached {
+		return Unreachable
+	}
+	return Reachable
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProber struct {
+	pingErr  error
+	reached  bool
+	errs     []error
+	requests int
+}
+
+func (p *fakeProber) Ping(address string, timeout time.Duration) error {
+	return p.pingErr
+}
+
+func (p *fakeProber) PingRequest(target string, size int, timeout time.Duration) (bool, []error) {
+	p.requests++
+	return p.reached, p.errs
+}
+
+func TestSWIMDetector(t *testing.T) {
+	detector := &SWIMDetector{PingRequestSize: 2}
+	failed := errors.New("ping failed")
+
+	prober := &fakeProber{}
+	assert.Equal(t, Reachable, detector.Probe(prober, "127.0.0.1:3002"))
+	assert.Equal(t, 0, prober.requests, "expected no ping requests after a successful ping")
+
+	prober = &fakeProber{pingErr: failed, reached: true}
+	assert.Equal(t, Reachable, detector.Probe(prober, "127.0.0.1:3002"))
+	assert.Equal(t, 1, prober.requests)
+
+	prober = &fakeProber{pingErr: failed, errs: []error{failed}}
+	assert.Equal(t, Unreachable, detector.Probe(prober, "127.0.0.1:3002"))
+
+	prober = &fakeProber{pingErr: failed, errs: []error{failed, failed}}
+	assert.Equal(t, Inconclusive, detector.Probe(prober, "127.0.0.1:3002"))
+}
+
+func TestCustomFailureDetector(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+
+	var probed []string
+	tnode.node.failureDetector = FailureDetectorFunc(func(prober Prober, address string) Verdict {
+		probed = append(probed, address)
+		return Unreachable
+	})
+
+	tnode.node.pingNextMember()
+
+	assert.Equal(t, []string{tpeer.node.Address()}, probed)
+	member, ok := tnode.node.memberlist.Member(tpeer.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, Suspect, member.Status, "expected unreachable member to be suspected")
+}
+
+func TestDefaultFailureDetector(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		PingTimeout:     time.Second,
+		PingRequestSize: 5,
+	})
+
+	detector, ok := node.failureDetector.(*SWIMDetector)
+	require.True(t, ok, "expected the SWIM detector by default")
+	assert.Equal(t, time.Second, detector.PingTimeout)
+	assert.Equal(t, 5, detector.PingRequestSize)
+}
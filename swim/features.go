@@ -0,0 +1,143 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import "sync"
+
+// Features is a bitset of protocol features. Nodes exchange the features they
+// support in join and ping handshakes, so that features that need both sides
+// of a connection to understand them can be enabled per pair of peers in a
+// cluster running mixed versions.
+type Features uint64
+
+// The features built into this version of the protocol. Bits 0 to 31 are
+// reserved for the protocol, bits 32 to 63 for applications, see
+// ApplicationFeature.
+const (
+	// FeaturePushback is support for pushback in ping responses.
+	FeaturePushback Features = 1 << iota
+
+	// FeatureHealth is support for gossiping the health of members.
+	FeatureHealth
+
+	// FeatureRamp is support for gossiping the ramp of members.
+	FeatureRamp
+
+	// FeatureAddresses is support for gossiping advertised addresses.
+	FeatureAddresses
+
+	// FeatureAnnotations is support for gossiping member annotations.
+	FeatureAnnotations
+
+	// FeatureLabels is support for gossiping member labels.
+	FeatureLabels
+
+	// FeatureJoinTimes is support for gossiping the join times of members.
+	FeatureJoinTimes
+
+	// FeatureTombstones is support for the tombstone member state.
+	FeatureTombstones
+
+	// FeatureAntiEntropy is support for anti-entropy syncs of the
+	// membership.
+	FeatureAntiEntropy
+)
+
+// protocolFeatures are the features every node of this version supports.
+const protocolFeatures = FeaturePushback | FeatureHealth | FeatureRamp | FeatureAddresses |
+	FeatureAnnotations | FeatureLabels | FeatureJoinTimes | FeatureTombstones |
+	FeatureAntiEntropy
+
+// ApplicationFeature returns the feature flag for the i-th application
+// defined feature, 0 <= i < 32. Applications pass their flags in the Features
+// option to negotiate capabilities of their own, such as compression of
+// forwarded requests.
+func ApplicationFeature(i uint) Features {
+	return Features(1) << (32 + i%32)
+}
+
+// Has returns whether all features in f are set.
+func (features Features) Has(f Features) bool {
+	return features&f == f
+}
+
+// featureState contains the features of the local node and the features
+// advertised by peers it completed a handshake with.
+type featureState struct {
+	local Features
+	peers map[string]Features
+	sync.RWMutex
+}
+
+// LocalFeatures returns the features the node advertises in handshakes.
+func (n *Node) LocalFeatures() Features {
+	n.features.RLock()
+	local := n.features.local
+	n.features.RUnlock()
+	return local
+}
+
+// PeerFeatures returns the features negotiated with the peer at address,
+// which are the features both nodes support. Ok is false if the node has not
+// completed a join or ping handshake with the peer yet. Peers running a
+// version without feature negotiation have no features.
+func (n *Node) PeerFeatures(address string) (features Features, ok bool) {
+	n.features.RLock()
+	remote, ok := n.features.peers[address]
+	local := n.features.local
+	n.features.RUnlock()
+
+	return local & remote, ok
+}
+
+// forgetPeerFeatures forgets the features of a peer that is no longer a
+// member.
+func (n *Node) forgetPeerFeatures(address string) {
+	n.features.Lock()
+	delete(n.features.peers, address)
+	n.features.Unlock()
+}
+
+// recordPeerFeatures records the features a peer advertised in a handshake
+// and emits a PeerFeaturesEvent when the negotiated features changed.
+func (n *Node) recordPeerFeatures(address string, remote Features) {
+	if address == "" || address == n.address {
+		return
+	}
+
+	n.features.Lock()
+	if n.features.peers == nil {
+		n.features.peers = make(map[string]Features)
+	}
+	previous, known := n.features.peers[address]
+	n.features.peers[address] = remote
+	local := n.features.local
+	n.features.Unlock()
+
+	if known && previous&local == remote&local {
+		return
+	}
+
+	n.emit(PeerFeaturesEvent{
+		Remote:   address,
+		Features: local & remote,
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events/test/mocks"
+)
+
+func TestFeaturesHas(t *testing.T) {
+	features := FeaturePushback | ApplicationFeature(3)
+
+	assert.True(t, features.Has(FeaturePushback))
+	assert.True(t, features.Has(FeaturePushback|ApplicationFeature(3)))
+	assert.False(t, features.Has(FeaturePushback|FeatureRamp))
+	assert.Equal(t, Features(1)<<35, ApplicationFeature(3))
+}
+
+func TestFeaturesNegotiated(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	tnode.node.features.local |= ApplicationFeature(0)
+
+	_, ok := tnode.node.PeerFeatures(tpeer.node.Address())
+	assert.False(t, ok, "expected no features before a handshake")
+
+	bootstrapNodes(t, tpeer, tnode)
+
+	// both sides of the join learn the features of the other
+	features, ok := tnode.node.PeerFeatures(tpeer.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, protocolFeatures, features)
+
+	features, ok = tpeer.node.PeerFeatures(tnode.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, protocolFeatures, features, "expected features only one side supports to be off")
+
+	negotiated := PeerFeaturesEvent{
+		Remote:   tpeer.node.Address(),
+		Features: protocolFeatures | ApplicationFeature(0),
+	}
+	listener := &mocks.EventListener{}
+	listener.On("HandleEvent", mock.Anything).Return()
+	tnode.node.RegisterListener(listener)
+
+	tpeer.node.features.local |= ApplicationFeature(0)
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	features, _ = tnode.node.PeerFeatures(tpeer.node.Address())
+	assert.True(t, features.Has(ApplicationFeature(0)), "expected feature to be negotiated after the peer supports it")
+	listener.AssertCalled(t, "HandleEvent", negotiated)
+}
+
+func TestFeaturesOfPeerWithoutNegotiation(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	// peers running a version without feature negotiation advertise none
+	tnode.node.recordPeerFeatures("192.0.2.1:1", 0)
+
+	features, ok := tnode.node.PeerFeatures("192.0.2.1:1")
+	assert.True(t, ok)
+	assert.Equal(t, Features(0), features)
+}
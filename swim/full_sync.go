@@ -0,0 +1,140 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/dgryski/go-farm"
+)
+
+// fullSyncCursor is the position in the membership, ordered by the hash of
+// the member addresses, after which a byte-budgeted full sync to a peer
+// resumes. Ordering by hash keeps the position meaningful while members join
+// and leave between rounds.
+type fullSyncCursor struct {
+	hash    uint32
+	address string
+}
+
+// before returns whether a member with the given hash and address comes
+// before the cursor, or at it.
+func (c fullSyncCursor) before(hash uint32, address string) bool {
+	return hash < c.hash || hash == c.hash && address <= c.address
+}
+
+// hashedChange is a full sync change with the hash of its address.
+type hashedChange struct {
+	Change
+	hash uint32
+}
+
+type changesByHash []hashedChange
+
+func (c changesByHash) Len() int      { return len(c) }
+func (c changesByHash) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c changesByHash) Less(i, j int) bool {
+	if c[i].hash != c[j].hash {
+		return c[i].hash < c[j].hash
+	}
+	return c[i].Address < c[j].Address
+}
+
+// fullSyncChunk returns the next chunk of the full sync to the peer that fits
+// in the full sync budget, resuming after the chunk sent to the peer before.
+// A chunk contains at least one member. Complete is true when the chunk ends
+// the full sync, after which the next full sync to the peer starts over.
+func (d *disseminator) fullSyncChunk(peer string) (changes []Change, complete bool) {
+	members := d.FullSync()
+	hashed := make(changesByHash, len(members))
+	for i, change := range members {
+		hashed[i] = hashedChange{change, farm.Fingerprint32([]byte(change.Address))}
+	}
+	sort.Sort(hashed)
+
+	d.Lock()
+	cursor, resumed := d.fullSyncCursors[peer]
+	d.Unlock()
+
+	bytes := 0
+	last := -1
+	for i, change := range hashed {
+		if resumed && cursor.before(change.hash, change.Address) {
+			continue
+		}
+
+		size := changeSize(change.Change)
+		if len(changes) > 0 && bytes+size > d.fullSyncBudget {
+			break
+		}
+		bytes += size
+		changes = append(changes, change.Change)
+		last = i
+	}
+
+	complete = last == -1 || last == len(hashed)-1
+
+	d.Lock()
+	if complete {
+		delete(d.fullSyncCursors, peer)
+	} else {
+		d.fullSyncCursors[peer] = fullSyncCursor{hashed[last].hash, hashed[last].Address}
+	}
+	d.Unlock()
+
+	d.node.emit(FullSyncChunkEvent{
+		Remote:   peer,
+		Members:  len(changes),
+		Bytes:    bytes,
+		Resumed:  resumed,
+		Complete: complete,
+	})
+
+	return changes, complete
+}
+
+// fullSyncTo returns the membership to send to the peer in a full sync: the
+// next chunk of it when the full sync budget is set, all of it otherwise.
+func (d *disseminator) fullSyncTo(peer string) []Change {
+	if d.fullSyncBudget > 0 {
+		changes, _ := d.fullSyncChunk(peer)
+		return changes
+	}
+	return d.FullSync()
+}
+
+// clearFullSyncCursor forgets where the full sync to the peer was, once the
+// peer has the same membership or is no longer a reachable member.
+func (d *disseminator) clearFullSyncCursor(peer string) {
+	d.Lock()
+	delete(d.fullSyncCursors, peer)
+	d.Unlock()
+}
+
+// changeSize returns the size of the change as encoded in protocol messages.
+func changeSize(change Change) int {
+	encoded, err := json.Marshal(change)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
@@ -50,6 +50,8 @@ type gossip struct {
 		sync.RWMutex
 	}
 
+	watchdog watchdog
+
 	logger log.Logger
 }
 
@@ -132,6 +134,9 @@ func (g *gossip) Start() {
 	g.SetStopped(false)
 	g.RunProtocolRateLoop()
 	g.RunProtocolPeriodLoop()
+	g.RunWatchdogLoop()
+	g.RunSyncLoop()
+	g.RunHealLoop()
 
 	g.logger.Debug("started gossip protocol")
 }
@@ -144,6 +149,7 @@ func (g *gossip) Stop() {
 	}
 
 	g.SetStopped(true)
+	g.StopHealLoop()
 	g.logger.Debug("stopped gossip protocol")
 }
 
@@ -160,7 +166,9 @@ func (g *gossip) RunProtocolPeriodLoop() {
 			startTimeFreq := time.Now()
 
 			g.ProtocolPeriod()
+			startTimeSleep := time.Now()
 			time.Sleep(delay)
+			g.node.recordProtocolDelay(delay, time.Now().Sub(startTimeSleep))
 
 			g.node.emit(ProtocolFrequencyEvent{
 				Duration: time.Now().Sub(startTimeFreq),
@@ -178,7 +186,11 @@ func (g *gossip) RunProtocolPeriodLoop() {
 func (g *gossip) ProtocolPeriod() {
 	startTime := time.Now()
 
+	g.node.sampleProtocolPeriod()
 	g.node.pingNextMember()
+	g.node.checkSuspectTTL()
+	g.node.reapMembers()
+	g.node.checkRamp()
 
 	g.protocol.Lock()
 	g.protocol.lastPeriod = time.Now()
@@ -22,8 +22,10 @@ package swim
 
 import (
 	"errors"
+	"time"
 
 	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/shared"
 	"github.com/uber/tchannel-go/json"
 	"golang.org/x/net/context"
 )
@@ -56,24 +58,79 @@ func notImplementedHandler(ctx json.Context, req *emptyArg) (*emptyArg, error) {
 
 func (n *Node) registerHandlers() error {
 	handlers := map[string]interface{}{
-		"/protocol/join":      n.joinHandler,
-		"/protocol/ping":      n.pingHandler,
-		"/protocol/ping-req":  n.pingRequestHandler,
-		"/admin/debugSet":     notImplementedHandler,
-		"/admin/debugClear":   notImplementedHandler,
-		"/admin/gossip":       n.gossipHandler, // Deprecated
-		"/admin/gossip/start": n.gossipHandlerStart,
-		"/admin/gossip/stop":  n.gossipHandlerStop,
-		"/admin/tick":         n.tickHandler, // Deprecated
-		"/admin/gossip/tick":  n.tickHandler,
-		"/admin/member/leave": n.adminLeaveHandler,
-		"/admin/member/join":  n.adminJoinHandler,
+		"/protocol/join":            n.joinHandler,
+		"/protocol/ping":            n.pingHandler,
+		"/protocol/ping-req":        n.pingRequestHandler,
+		"/protocol/sync":            n.syncHandler,
+		"/admin/debugSet":           n.debugSetHandler,
+		"/admin/debugClear":         n.debugClearHandler,
+		"/admin/gossip":             n.gossipHandler, // Deprecated
+		"/admin/gossip/start":       n.gossipHandlerStart,
+		"/admin/gossip/stop":        n.gossipHandlerStop,
+		"/admin/tick":               n.tickHandler, // Deprecated
+		"/admin/gossip/tick":        n.tickHandler,
+		"/admin/member/leave":       n.adminLeaveHandler,
+		"/admin/member/join":        n.adminJoinHandler,
+		"/admin/member/annotate":    n.adminAnnotateHandler,
+		"/admin/member/maintenance": n.adminMaintenanceHandler,
+		"/admin/partition":          n.partitionHandler,
+		"/admin/partition/end":      n.partitionEndHandler,
+	}
+
+	// Sealed messages are not JSON objects, so with a keyring the protocol
+	// messages are decoded after they were opened.
+	if n.keyring != nil {
+		handlers["/protocol/join"] = n.rawHandler(n.HandleJoin)
+		handlers["/protocol/ping"] = n.rawHandler(n.HandlePing)
+		handlers["/protocol/ping-req"] = n.rawHandler(n.HandlePingReq)
+		handlers["/protocol/sync"] = n.rawHandler(n.HandleSync)
+	}
+
+	if n.adminReply != nil {
+		handlers = shared.OnReply(handlers, "/admin/", n.adminReply)
 	}
 
 	return json.Register(n.channel, handlers, n.errorHandler)
 }
 
+// rawMessage is the undecoded JSON body of a protocol message. It is a struct,
+// as JSON handlers only take pointers to structs.
+type rawMessage struct {
+	body []byte
+}
+
+func (m *rawMessage) UnmarshalJSON(body []byte) error {
+	m.body = append([]byte(nil), body...)
+	return nil
+}
+
+func (m *rawMessage) MarshalJSON() ([]byte, error) {
+	return m.body, nil
+}
+
+// rawHandler adapts the Handle method of a protocol message to a JSON handler
+// that passes the undecoded request body.
+func (n *Node) rawHandler(handle func(context.Context, []byte) ([]byte, error)) func(json.Context, *rawMessage) (*rawMessage, error) {
+	return func(ctx json.Context, req *rawMessage) (*rawMessage, error) {
+		res, err := handle(ctx, req.body)
+		if err != nil {
+			return nil, err
+		}
+		return &rawMessage{body: res}, nil
+	}
+}
+
 func (n *Node) joinHandler(ctx json.Context, req *joinRequest) (*joinResponse, error) {
+	if err := n.checkPeer(req.Source); err != nil {
+		return nil, err
+	}
+
+	n.capture(Inbound, req.Source, "/protocol/join", false, req)
+
+	if err := validateMessage("/protocol/join", false, req); err != nil {
+		return nil, err
+	}
+
 	res, err := handleJoin(n, req)
 	if err != nil {
 		n.logger.WithFields(log.Fields{
@@ -83,18 +140,51 @@ func (n *Node) joinHandler(ctx json.Context, req *joinRequest) (*joinResponse, e
 		return nil, err
 	}
 
+	n.capture(Outbound, req.Source, "/protocol/join", true, res)
 	return res, nil
 }
 
 func (n *Node) pingHandler(ctx json.Context, req *ping) (*ping, error) {
-	return handlePing(n, req)
+	if err := n.checkPeer(req.Source); err != nil {
+		return nil, err
+	}
+
+	n.capture(Inbound, req.Source, "/protocol/ping", false, req)
+
+	if err := validateMessage("/protocol/ping", false, req); err != nil {
+		return nil, err
+	}
+
+	res, err := handlePing(n, req)
+	if err == nil {
+		n.capture(Outbound, req.Source, "/protocol/ping", true, res)
+	}
+	return res, err
 }
 
 func (n *Node) pingRequestHandler(ctx json.Context, req *pingRequest) (*pingResponse, error) {
-	return handlePingRequest(n, req)
+	if err := n.checkPeer(req.Source); err != nil {
+		return nil, err
+	}
+
+	n.capture(Inbound, req.Source, "/protocol/ping-req", false, req)
+
+	if err := validateMessage("/protocol/ping-req", false, req); err != nil {
+		return nil, err
+	}
+
+	res, err := handlePingRequest(n, req)
+	if err == nil {
+		n.capture(Outbound, req.Source, "/protocol/ping-req", true, res)
+	}
+	return res, err
 }
 
 func (n *Node) gossipHandler(ctx json.Context, req *emptyArg) (*emptyArg, error) {
+	if err := n.AuthorizeAdmin(ctx, "/admin/gossip", AdminWrite); err != nil {
+		return nil, err
+	}
+
 	switch n.gossip.Stopped() {
 	case true:
 		n.gossip.Start()
@@ -106,27 +196,138 @@ func (n *Node) gossipHandler(ctx json.Context, req *emptyArg) (*emptyArg, error)
 }
 
 func (n *Node) gossipHandlerStart(ctx json.Context, req *emptyArg) (*emptyArg, error) {
+	if err := n.AuthorizeAdmin(ctx, "/admin/gossip/start", AdminWrite); err != nil {
+		return nil, err
+	}
+
 	n.gossip.Start()
 	return &emptyArg{}, nil
 }
 
 func (n *Node) gossipHandlerStop(ctx json.Context, req *emptyArg) (*emptyArg, error) {
+	if err := n.AuthorizeAdmin(ctx, "/admin/gossip/stop", AdminWrite); err != nil {
+		return nil, err
+	}
+
 	n.gossip.Stop()
 	return &emptyArg{}, nil
 }
 
 func (n *Node) tickHandler(ctx json.Context, req *emptyArg) (*ping, error) {
+	if err := n.AuthorizeAdmin(ctx, "/admin/gossip/tick", AdminWrite); err != nil {
+		return nil, err
+	}
+
 	n.gossip.ProtocolPeriod()
 	return &ping{Checksum: n.memberlist.Checksum()}, nil
 }
 
+func (n *Node) debugSetHandler(ctx json.Context, req *DebugSampling) (*Status, error) {
+	if err := n.AuthorizeAdmin(ctx, "/admin/debugSet", AdminWrite); err != nil {
+		return nil, err
+	}
+
+	if err := n.SetDebugSampling(*req); err != nil {
+		return nil, err
+	}
+	return &Status{Status: "ok"}, nil
+}
+
+func (n *Node) debugClearHandler(ctx json.Context, req *emptyArg) (*Status, error) {
+	if err := n.AuthorizeAdmin(ctx, "/admin/debugClear", AdminWrite); err != nil {
+		return nil, err
+	}
+
+	n.SetDebugSampling(DebugSampling{})
+	return &Status{Status: "ok"}, nil
+}
+
+// partitionRequest is the request of the /admin/partition endpoint.
+type partitionRequest struct {
+	Peers      []string `json:"peers"`
+	DurationMs int64    `json:"durationMs"`
+}
+
+func (n *Node) partitionHandler(ctx json.Context, req *partitionRequest) (*Status, error) {
+	if err := n.AuthorizeAdmin(ctx, "/admin/partition", AdminWrite); err != nil {
+		return nil, err
+	}
+
+	duration := time.Duration(req.DurationMs) * time.Millisecond
+	if err := n.SimulatePartition(req.Peers, duration); err != nil {
+		return nil, err
+	}
+	return &Status{Status: "ok"}, nil
+}
+
+func (n *Node) partitionEndHandler(ctx json.Context, req *emptyArg) (*Status, error) {
+	if err := n.AuthorizeAdmin(ctx, "/admin/partition/end", AdminWrite); err != nil {
+		return nil, err
+	}
+
+	if !n.EndPartition() {
+		return &Status{Status: "not partitioned"}, nil
+	}
+	return &Status{Status: "ok"}, nil
+}
+
 func (n *Node) adminJoinHandler(ctx json.Context, req *emptyArg) (*Status, error) {
-	n.memberlist.Reincarnate()
+	if err := n.AuthorizeAdmin(ctx, "/admin/member/join", AdminWrite); err != nil {
+		return nil, err
+	}
+
+	if err := n.Rejoin(); err != nil {
+		return nil, err
+	}
 	return &Status{Status: "rejoined"}, nil
 }
 
 func (n *Node) adminLeaveHandler(ctx json.Context, req *emptyArg) (*Status, error) {
-	n.memberlist.MakeLeave(n.address, n.memberlist.local.incarnation())
+	if err := n.AuthorizeAdmin(ctx, "/admin/member/leave", AdminWrite); err != nil {
+		return nil, err
+	}
+
+	if err := n.Leave(); err != nil {
+		return nil, err
+	}
+	return &Status{Status: "ok"}, nil
+}
+
+// annotateRequest is the request of the /admin/member/annotate endpoint.
+type annotateRequest struct {
+	Annotations map[string]string `json:"annotations"`
+}
+
+func (n *Node) adminAnnotateHandler(ctx json.Context, req *annotateRequest) (*Status, error) {
+	if err := n.AuthorizeAdmin(ctx, "/admin/member/annotate", AdminWrite); err != nil {
+		return nil, err
+	}
+
+	if err := n.Annotate(req.Annotations); err != nil {
+		return nil, err
+	}
+	return &Status{Status: "ok"}, nil
+}
+
+// maintenanceRequest is the request of the /admin/member/maintenance
+// endpoint. Duration is the length of the maintenance window in milliseconds
+// from now; zero ends the window.
+type maintenanceRequest struct {
+	Duration int64 `json:"duration"`
+}
+
+func (n *Node) adminMaintenanceHandler(ctx json.Context, req *maintenanceRequest) (*Status, error) {
+	if err := n.AuthorizeAdmin(ctx, "/admin/member/maintenance", AdminWrite); err != nil {
+		return nil, err
+	}
+
+	var until time.Time
+	if req.Duration > 0 {
+		until = n.clock.Now().Add(time.Duration(req.Duration) * time.Millisecond)
+	}
+	if err := n.SetMaintenance(until); err != nil {
+		return nil, err
+	}
 	return &Status{Status: "ok"}, nil
 }
 
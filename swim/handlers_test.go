@@ -141,6 +141,43 @@ func (s *HandlerTestSuite) TestNotImplementedHandler() {
 	s.Nil(res)
 }
 
+func (s *HandlerTestSuite) TestDebugSetClearHandlers() {
+	res, err := s.testNode.node.debugSetHandler(s.ctx, &DebugSampling{Rate: 0.5, Peer: "127.0.0.1:3002"})
+	s.NoError(err)
+	s.Equal(&Status{Status: "ok"}, res)
+	s.Equal(DebugSampling{Rate: 0.5, Peer: "127.0.0.1:3002"}, s.testNode.node.DebugSampling())
+
+	_, err = s.testNode.node.debugSetHandler(s.ctx, &DebugSampling{Rate: 2})
+	s.Error(err)
+
+	_, err = s.testNode.node.debugClearHandler(s.ctx, &emptyArg{})
+	s.NoError(err)
+	s.Equal(DebugSampling{}, s.testNode.node.DebugSampling())
+}
+
+func (s *HandlerTestSuite) TestPartitionHandlers() {
+	res, err := s.testNode.node.partitionHandler(s.ctx, &partitionRequest{
+		Peers:      []string{"127.0.0.1:3002"},
+		DurationMs: 1000,
+	})
+	s.NoError(err)
+	s.Equal(&Status{Status: "ok"}, res)
+	s.Equal([]string{"127.0.0.1:3002"}, s.testNode.node.PartitionedPeers())
+
+	_, err = s.testNode.node.partitionHandler(s.ctx, &partitionRequest{
+		Peers: []string{"127.0.0.1:3002"},
+	})
+	s.Error(err, "expected partition without a duration to be rejected")
+
+	res, err = s.testNode.node.partitionEndHandler(s.ctx, &emptyArg{})
+	s.NoError(err)
+	s.Equal(&Status{Status: "ok"}, res)
+
+	res, err = s.testNode.node.partitionEndHandler(s.ctx, &emptyArg{})
+	s.NoError(err)
+	s.Equal(&Status{Status: "not partitioned"}, res)
+}
+
 // TestErrorHandler tests that the errorHandler logs the correct error message.
 func (s *HandlerTestSuite) TestErrorHandler() {
 	logger := &mocks.Logger{}
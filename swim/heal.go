@@ -0,0 +1,409 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+)
+
+// errNoHealTarget is returned by a partition probe when there is no faulty
+// member or discovered host that is not a reachable member to probe.
+var errNoHealTarget = errors.New("no partition heal target")
+
+// partitionHealer detects and heals partitions of the cluster into live
+// sub-clusters that consider each other's members faulty, which the gossip
+// protocol cannot heal on its own, as members do not ping faulty members.
+type partitionHealer struct {
+	period    time.Duration
+	rateLimit time.Duration
+
+	// discoverProvider provides hosts to probe in addition to the faulty
+	// members, it is the provider the node bootstrapped with.
+	discoverProvider DiscoverProvider
+
+	lastHeal time.Time
+	detected map[string]time.Time
+
+	// stop is closed to stop the heal loop.
+	stop chan struct{}
+	sync.Mutex
+}
+
+// RunHealLoop probes for partitions every partition heal period of the node
+// clock until the gossip protocol is stopped.
+func (g *gossip) RunHealLoop() {
+	h := &g.node.healer
+	if h.period <= 0 {
+		return
+	}
+
+	h.Lock()
+	if h.stop != nil {
+		close(h.stop)
+	}
+	stop := make(chan struct{})
+	h.stop = stop
+	h.Unlock()
+
+	ticker := g.node.clock.Ticker(h.period)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if !g.node.SubsystemEnabled(SubsystemPartitionHealing) {
+				continue
+			}
+			g.node.checkHealed()
+			g.node.probePartition()
+		}
+	}()
+}
+
+// StopHealLoop stops the heal loop started by RunHealLoop.
+func (g *gossip) StopHealLoop() {
+	h := &g.node.healer
+	h.Lock()
+	if h.stop != nil {
+		close(h.stop)
+		h.stop = nil
+	}
+	h.Unlock()
+}
+
+// healTargets returns the faulty members and the discovered hosts that are
+// not reachable members, which may be part of another partition. Quarantined
+// peers are not probed, as their membership is not merged.
+func (n *Node) healTargets() []string {
+	seen := make(map[string]bool)
+	var targets []string
+
+	members := n.memberlist.GetMembers()
+	for i := range members {
+		seen[members[i].Address] = true
+		if members[i].Status == Faulty && !n.Quarantined(members[i].Address) {
+			targets = append(targets, members[i].Address)
+		}
+	}
+
+	n.healer.Lock()
+	provider := n.healer.discoverProvider
+	n.healer.Unlock()
+
+	if provider != nil {
+		hosts, err := provider.Hosts()
+		if err != nil {
+			n.logger.WithField("error", err).Warn("unable to discover partition heal targets")
+		}
+		for _, host := range hosts {
+			if host != n.address && !seen[host] && !n.Quarantined(host) {
+				seen[host] = true
+				targets = append(targets, host)
+			}
+		}
+	}
+
+	return targets
+}
+
+// partitionDiff compares the membership of the node with the membership of a
+// remote node. Local are the members reachable in the membership of the node
+// that the remote node considers faulty or does not know, and remote are the
+// members reachable in the remote membership that the node considers faulty
+// or does not know.
+func (n *Node) partitionDiff(membership []Change) (local, remote []Change) {
+	remoteReachable := make(map[string]bool, len(membership))
+	for _, change := range membership {
+		if change.Status != Alive && change.Status != Suspect {
+			continue
+		}
+		remoteReachable[change.Address] = true
+
+		member, ok := n.memberlist.Member(change.Address)
+		if !ok || !member.isReachable() {
+			remote = append(remote, change)
+		}
+	}
+
+	members := n.memberlist.GetMembers()
+	for i := range members {
+		member := &members[i]
+		if member.isReachable() && !remoteReachable[member.Address] {
+			local = append(local, Change{
+				Address:     member.Address,
+				Incarnation: member.Incarnation,
+				Status:      member.Status,
+			})
+		}
+	}
+
+	return local, remote
+}
+
+// probePartition sends a join request to a random heal target. A target that
+// responds and whose membership disagrees with the membership of the node
+// about which members are reachable is part of another partition, which is
+// healed unless a heal was attempted within the rate limit.
+func (n *Node) probePartition() error {
+	targets := n.healTargets()
+	if len(targets) == 0 {
+		return errNoHealTarget
+	}
+	target := targets[rand.Intn(len(targets))]
+
+	res, err := n.sendHealJoin(target)
+	if err != nil {
+		n.logger.WithFields(log.Fields{
+			"target": target,
+			"error":  err,
+		}).Debug("partition heal target unreachable")
+		return err
+	}
+
+	local, remote := n.partitionDiff(res.Membership)
+	if len(local) == 0 && len(remote) == 0 {
+		return nil
+	}
+
+	h := &n.healer
+	h.Lock()
+	if h.detected == nil {
+		h.detected = make(map[string]time.Time)
+	}
+	_, known := h.detected[target]
+	if !known {
+		h.detected[target] = n.clock.Now()
+	}
+	limited := n.clock.Now().Sub(h.lastHeal) < h.rateLimit && !h.lastHeal.IsZero()
+	if !limited {
+		h.lastHeal = n.clock.Now()
+	}
+	h.Unlock()
+
+	if !known {
+		n.logger.WithFields(log.Fields{
+			"target":        target,
+			"localMembers":  len(local),
+			"remoteMembers": len(remote),
+		}).Warn("detected partition")
+		n.emit(PartitionDetectedEvent{
+			Target:        target,
+			LocalMembers:  len(local),
+			RemoteMembers: len(remote),
+		})
+	}
+
+	if limited {
+		return nil
+	}
+
+	return n.healPartition(target, res.Membership, local, remote)
+}
+
+// healPartition merges the partition of the target with the partition of the
+// node. Members of both partitions that the other partition considers faulty
+// at their current incarnation are suspected first, so they refute the
+// suspicion with a new incarnation that overrides the faulty state in the
+// other partition. The suspicions of the members of the other partition are
+// sent to the target, which disseminates them in its partition, along with
+// the current state of the members of this partition. Only then is the
+// membership of the target merged, fetched again so it holds the
+// refutations, and without the faulty states the other partition holds about
+// the reachable members of this partition, which are merged once those
+// members have reincarnated.
+func (n *Node) healPartition(target string, membership, local, remote []Change) error {
+	remoteState := make(map[string]Change, len(membership))
+	for _, change := range membership {
+		remoteState[change.Address] = change
+	}
+
+	var localSuspects, remoteSuspects []Change
+	for _, change := range local {
+		if faulty, ok := remoteState[change.Address]; ok && faulty.Incarnation >= change.Incarnation {
+			localSuspects = append(localSuspects, change)
+		}
+	}
+	for _, change := range remote {
+		if state, ok := n.memberState(change.Address); ok && state.Incarnation >= change.Incarnation {
+			remoteSuspects = append(remoteSuspects, change)
+		}
+	}
+
+	n.memberlist.Update(n.healSuspects(localSuspects))
+
+	ps := newPingSender(n, target, n.pingTimeout)
+	ps.changes = append(n.healSuspects(remoteSuspects), n.reachableChanges(local)...)
+	res, err := ps.SendPing()
+	if err != nil {
+		n.logger.WithFields(log.Fields{
+			"target": target,
+			"error":  err,
+		}).Info("partition heal failed")
+		return err
+	}
+
+	n.memberlist.Update(n.withoutPartitionFaults(res.Changes))
+
+	// the refutations of the suspected members of the other partition are
+	// learned from the membership of the target after the suspicions
+	joined, err := n.sendHealJoin(target)
+	if err != nil {
+		joined = &joinResponse{Membership: membership}
+	}
+	n.memberlist.Update(n.withoutPartitionFaults(joined.Membership))
+
+	n.logger.WithFields(log.Fields{
+		"target":        target,
+		"localMembers":  len(local),
+		"remoteMembers": len(remote),
+	}).Info("attempted to heal partition")
+
+	return nil
+}
+
+// healSuspects returns suspect changes for the members of the changes at
+// their incarnation.
+func (n *Node) healSuspects(changes []Change) []Change {
+	suspects := make([]Change, 0, len(changes))
+	for _, change := range changes {
+		suspects = append(suspects, Change{
+			Source:            n.address,
+			SourceIncarnation: n.Incarnation(),
+			Address:           change.Address,
+			Incarnation:       change.Incarnation,
+			Status:            Suspect,
+		})
+	}
+	return suspects
+}
+
+// memberState returns the current incarnation and status of the member at
+// address as a change from the node.
+func (n *Node) memberState(address string) (Change, bool) {
+	member, ok := n.memberlist.Member(address)
+	if !ok {
+		return Change{}, false
+	}
+
+	state := Change{
+		Source:            n.address,
+		SourceIncarnation: n.Incarnation(),
+	}
+	member.RLock()
+	state.Address = member.Address
+	state.Incarnation = member.Incarnation
+	state.Status = member.Status
+	member.RUnlock()
+	return state, true
+}
+
+// reachableChanges returns the current state of the members of the changes
+// that are reachable in the membership of the node.
+func (n *Node) reachableChanges(changes []Change) []Change {
+	current := make([]Change, 0, len(changes))
+	for _, change := range changes {
+		if state, ok := n.memberState(change.Address); ok && reachable(state.Status) {
+			current = append(current, state)
+		}
+	}
+	return current
+}
+
+// withoutPartitionFaults returns the changes of another partition without the
+// changes that declare members unreachable that are reachable in the
+// membership of the node, which the other partition has not seen reincarnate
+// yet.
+func (n *Node) withoutPartitionFaults(changes []Change) []Change {
+	merged := make([]Change, 0, len(changes))
+	for _, change := range changes {
+		if !reachable(change.Status) {
+			if state, ok := n.memberState(change.Address); ok && reachable(state.Status) {
+				continue
+			}
+		}
+		merged = append(merged, change)
+	}
+	return merged
+}
+
+// reachable returns whether a member with the status is reachable.
+func reachable(status string) bool {
+	return status == Alive || status == Suspect
+}
+
+// checkHealed reports the partitions whose targets became reachable members.
+func (n *Node) checkHealed() {
+	h := &n.healer
+
+	var healed []PartitionHealedEvent
+	h.Lock()
+	for target, since := range h.detected {
+		member, ok := n.memberlist.Member(target)
+		if ok && member.isReachable() {
+			healed = append(healed, PartitionHealedEvent{
+				Target:   target,
+				Duration: n.clock.Now().Sub(since),
+			})
+			delete(h.detected, target)
+		}
+	}
+	h.Unlock()
+
+	for _, event := range healed {
+		n.logger.WithFields(log.Fields{
+			"target":   event.Target,
+			"duration": event.Duration,
+		}).Info("healed partition")
+		n.emit(event)
+	}
+}
+
+// sendHealJoin sends a join request to the target to learn its membership.
+func (n *Node) sendHealJoin(target string) (*joinResponse, error) {
+	j := &joinSender{
+		node:    n,
+		timeout: n.joinTimeout,
+		logger:  n.logger,
+	}
+
+	ctx, cancel := n.mesh.NewContext(j.timeout, target)
+	defer cancel()
+
+	var res joinResponse
+	select {
+	case err := <-j.MakeCall(ctx, target, &res):
+		if err != nil {
+			return nil, err
+		}
+		return &res, nil
+
+	case <-ctx.Done():
+		return nil, errors.New("join timed out")
+	}
+}
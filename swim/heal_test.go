@@ -0,0 +1,174 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events"
+)
+
+func TestPartitionHealNoTarget(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+	bootstrapNodes(t, tnode)
+
+	assert.Equal(t, errNoHealTarget, tnode.node.probePartition())
+}
+
+func TestPartitionHeal(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+	bootstrapNodes(t, tpeer, tnode)
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	var detected []PartitionDetectedEvent
+	var healed []PartitionHealedEvent
+	tnode.node.RegisterListener(ListenerFunc(func(event events.Event) {
+		switch event := event.(type) {
+		case PartitionDetectedEvent:
+			detected = append(detected, event)
+		case PartitionHealedEvent:
+			healed = append(healed, event)
+		}
+	}))
+
+	// partition the nodes, each declaring the other faulty
+	tnode.node.memberlist.MakeFaulty(tpeer.node.Address(), tpeer.node.Incarnation())
+	tpeer.node.memberlist.MakeFaulty(tnode.node.Address(), tnode.node.Incarnation())
+	tnode.node.clock.(*clock.Mock).Add(time.Second)
+	tpeer.node.clock.(*clock.Mock).Add(time.Second)
+
+	require.NoError(t, tnode.node.probePartition())
+	require.Len(t, detected, 1)
+	assert.Equal(t, PartitionDetectedEvent{
+		Target:        tpeer.node.Address(),
+		LocalMembers:  1,
+		RemoteMembers: 1,
+	}, detected[0])
+
+	member, _ := tnode.node.memberlist.Member(tpeer.node.Address())
+	assert.Equal(t, Alive, member.Status, "expected the peer to refute being faulty")
+	member, _ = tpeer.node.memberlist.Member(tnode.node.Address())
+	assert.Equal(t, Alive, member.Status, "expected the node to refute being faulty")
+
+	tnode.node.checkHealed()
+	require.Len(t, healed, 1)
+	assert.Equal(t, tpeer.node.Address(), healed[0].Target)
+}
+
+func TestPartitionHealRateLimit(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+	bootstrapNodes(t, tpeer, tnode)
+	tnode.node.healer.rateLimit = time.Minute
+	tnode.node.healer.lastHeal = tnode.node.clock.Now()
+
+	tnode.node.memberlist.MakeFaulty(tpeer.node.Address(), tpeer.node.Incarnation())
+	tnode.node.clock.(*clock.Mock).Add(time.Second)
+
+	require.NoError(t, tnode.node.probePartition())
+	member, _ := tnode.node.memberlist.Member(tpeer.node.Address())
+	assert.Equal(t, Faulty, member.Status, "expected no heal within the rate limit")
+}
+
+// TestPartitionHealKeepsLocalMembers tests that healing a partition does not
+// merge the faulty states the other partition holds about the reachable
+// members of this partition, which would make them faulty before they can
+// refute their suspicion.
+func TestPartitionHealKeepsLocalMembers(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+	bootstrapNodes(t, tpeer, tnode)
+
+	// a member of the partition of the node that the peer considers faulty
+	local := "127.0.0.1:3010"
+	tnode.node.memberlist.Update([]Change{{Address: local, Incarnation: 1, Status: Alive}})
+	tpeer.node.memberlist.Update([]Change{{Address: local, Incarnation: 1, Status: Faulty}})
+
+	tnode.node.memberlist.MakeFaulty(tpeer.node.Address(), tpeer.node.Incarnation())
+	tpeer.node.memberlist.MakeFaulty(tnode.node.Address(), tnode.node.Incarnation())
+	tnode.node.clock.(*clock.Mock).Add(time.Second)
+	tpeer.node.clock.(*clock.Mock).Add(time.Second)
+
+	require.NoError(t, tnode.node.probePartition())
+
+	member, _ := tnode.node.memberlist.Member(local)
+	assert.Equal(t, Suspect, member.Status, "expected the local member to be suspected, not made faulty")
+	assert.Equal(t, int64(1), member.Incarnation)
+
+	member, _ = tnode.node.memberlist.Member(tpeer.node.Address())
+	assert.Equal(t, Alive, member.Status, "expected the reincarnated peer to be merged")
+
+	// the member refutes the suspicion, which overrides its faulty state in
+	// the partition of the peer once it is gossiped
+	tnode.node.memberlist.Update([]Change{{Address: local, Incarnation: 2, Status: Alive}})
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	member, _ = tpeer.node.memberlist.Member(local)
+	assert.Equal(t, Alive, member.Status, "expected the reincarnated member to be merged by the peer")
+	assert.Equal(t, int64(2), member.Incarnation)
+}
+
+// TestHealLoop tests that the heal loop probes for partitions every heal
+// period of the node clock, and stops with the gossip protocol.
+func TestHealLoop(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+	bootstrapNodes(t, tpeer, tnode)
+	tnode.node.healer.period = time.Minute
+
+	partition := func() {
+		tnode.node.memberlist.MakeFaulty(tpeer.node.Address(), tpeer.node.Incarnation())
+		tpeer.node.memberlist.MakeFaulty(tnode.node.Address(), tnode.node.Incarnation())
+		tpeer.node.clock.(*clock.Mock).Add(time.Second)
+	}
+	peerStatus := func() string {
+		member, _ := tnode.node.memberlist.Member(tpeer.node.Address())
+		member.RLock()
+		defer member.RUnlock()
+		return member.Status
+	}
+
+	partition()
+	tnode.node.gossip.RunHealLoop()
+	tnode.node.clock.(*clock.Mock).Add(time.Minute)
+	for i := 0; i < 100 && peerStatus() != Alive; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, Alive, peerStatus(), "expected the loop to heal the partition")
+
+	tnode.node.gossip.StopHealLoop()
+	partition()
+	tnode.node.clock.(*clock.Mock).Add(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, Faulty, peerStatus(), "expected no probe after the loop stopped")
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetDegradedNotReady(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	assert.Equal(t, ErrNodeNotReady, tnode.node.SetDegraded(true))
+}
+
+func TestSetDegradedIsGossiped(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+	tclock := tnode.node.clock.(*clock.Mock)
+
+	incarnation := tnode.node.Incarnation()
+	tclock.Add(time.Second)
+
+	require.NoError(t, tnode.node.SetDegraded(true))
+	assert.Equal(t, Degraded, tnode.node.memberlist.local.Health)
+	assert.True(t, tnode.node.Incarnation() > incarnation, "expected health change to bump the incarnation")
+
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	member, ok := tpeer.node.memberlist.Member(tnode.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, Degraded, member.Health, "expected degraded health to be gossiped")
+
+	// refuting a suspicion keeps the health of the local member
+	tclock.Add(time.Second)
+	tnode.node.memberlist.MakeSuspect(tnode.node.Address(), tnode.node.Incarnation())
+	assert.Equal(t, Degraded, tnode.node.memberlist.local.Health)
+
+	tclock.Add(time.Second)
+	require.NoError(t, tnode.node.SetDegraded(false))
+	assert.Empty(t, tnode.node.memberlist.local.Health)
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import "errors"
+
+// IdentityAnnotation is the annotation a member declares its logical identity
+// with, such as "node-17", when it has one, see Options. Unlike its address,
+// the identity of a member stays the same when it is rescheduled to another
+// host, so it can be used to place the member on a hash ring.
+const IdentityAnnotation = "identity"
+
+// errIdentityAnnotation is returned by Annotate when the identity annotation
+// is changed, which is fixed when the node is created.
+var errIdentityAnnotation = errors.New("the identity annotation cannot be changed")
+
+// Identity returns the logical identity of the local member, or its address if
+// it has none.
+func (n *Node) Identity() string {
+	if n.identity == "" {
+		return n.address
+	}
+	return n.identity
+}
+
+// MemberIdentity returns the logical identity the member at address declared,
+// or its address if it declared none. Ok is false if the member is not known.
+func (n *Node) MemberIdentity(address string) (identity string, ok bool) {
+	annotations, ok := n.Annotations(address)
+	if !ok {
+		return "", false
+	}
+	if identity := annotations[IdentityAnnotation]; identity != "" {
+		return identity, true
+	}
+	return address, true
+}
+
+// identityAnnotations returns the annotations the local member declares its
+// identity with, or nil if it has none.
+func (n *Node) identityAnnotations() map[string]string {
+	if n.identity == "" {
+		return nil
+	}
+	return map[string]string{IdentityAnnotation: n.identity}
+}
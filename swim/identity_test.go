@@ -0,0 +1,60 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityIsGossiped(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	tnode.node.identity = "node-17"
+	bootstrapNodes(t, tpeer, tnode)
+
+	assert.Equal(t, "node-17", tnode.node.Identity())
+	assert.Equal(t, tpeer.node.Address(), tpeer.node.Identity(), "expected address without identity")
+
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	identity, ok := tpeer.node.MemberIdentity(tnode.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, "node-17", identity, "expected identity to be gossiped")
+
+	identity, ok = tnode.node.MemberIdentity(tpeer.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, tpeer.node.Address(), identity)
+
+	_, ok = tnode.node.MemberIdentity("127.0.0.1:9999")
+	assert.False(t, ok)
+
+	assert.Equal(t, errIdentityAnnotation, tnode.node.Annotate(map[string]string{IdentityAnnotation: "node-18"}))
+	require.NoError(t, tnode.node.Annotate(map[string]string{"ticket": "OPS-1"}))
+	identity, _ = tnode.node.MemberIdentity(tnode.node.Address())
+	assert.Equal(t, "node-17", identity, "expected identity to be kept by other annotations")
+}
@@ -57,6 +57,7 @@ type delaySleeper func(time.Duration)
 type delayOpts struct {
 	initial    time.Duration
 	max        time.Duration
+	jitter     float64
 	randomizer delayRandomizer
 	sleeper    delaySleeper
 }
@@ -84,6 +85,11 @@ type exponentialDelayer struct {
 	// maxDelay is the maximum delay applied to a join attempt.
 	maxDelay time.Duration
 
+	// jitter is the fraction of each delay that is random. If it is zero,
+	// the delay is random between the previous and the current backoff
+	// instead, and no longer random once the backoff reached maxDelay.
+	jitter float64
+
 	// maxDelayReached is a flag that is toggled once the delay
 	// applied to a join attempt reaches or exceeds the max delay.
 	maxDelayReached bool
@@ -129,6 +135,7 @@ func newExponentialDelayer(joiner string, opts *delayOpts) (*exponentialDelayer,
 		nextDelayMin:    0,
 		maxDelayReached: false,
 		maxDelay:        opts.max,
+		jitter:          math.Min(math.Max(opts.jitter, 0), 1),
 		randomizer:      randomizer,
 		sleeper:         sleeper,
 		numDelays:       0,
@@ -156,7 +163,14 @@ func (d *exponentialDelayer) delay() time.Duration {
 	// at which the exponential backoff has reached its max; apply no more
 	// jitter.
 	var jitteredDelay int
-	if cappedDelay == d.nextDelayMin {
+	if d.jitter > 0 {
+		// Keep the delay random at the max, so that nodes that failed to
+		// join at the same time do not retry at the same time forever.
+		jitteredDelay = int(cappedDelay * (1 - d.jitter))
+		if span := int(cappedDelay * d.jitter); span > 0 {
+			jitteredDelay += d.randomizer(span)
+		}
+	} else if cappedDelay == d.nextDelayMin {
 		jitteredDelay = int(cappedDelay)
 	} else {
 		jitteredDelay = d.randomizer(int(cappedDelay-d.nextDelayMin)) + int(d.nextDelayMin)
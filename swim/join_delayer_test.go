@@ -103,6 +103,34 @@ func (s *joinDelayerTestSuite) TestMaxDelayReached() {
 	}
 }
 
+func (s *joinDelayerTestSuite) TestDelayWithJitter() {
+	s.delayer.jitter = 0.25
+	s.delayer.randomizer = func(n int) int { return 0 }
+
+	// Without randomness, every delay is three quarters of the backoff, also
+	// once the backoff is capped.
+	for _, expectedDelay := range s.expectedDelays {
+		delay := s.delayer.delay()
+		s.EqualValues(expectedDelay*3/4, delay, "join attempt delay is correct")
+	}
+}
+
+func (s *joinDelayerTestSuite) TestJitterBounds() {
+	delayer, err := newExponentialDelayer("dummyjoiner", &delayOpts{
+		initial: time.Second,
+		max:     time.Second,
+		jitter:  2,
+		sleeper: noSleep,
+	})
+	s.NoError(err, "expected valid exponential delayer")
+	s.Equal(1.0, delayer.jitter, "expected jitter to be at most 1")
+
+	for i := 0; i < 10; i++ {
+		delay := delayer.delay()
+		s.True(delay >= 0 && delay < time.Second, "delay should be within bounds")
+	}
+}
+
 func TestJoinDelayerTestSuite(t *testing.T) {
 	suite.Run(t, new(joinDelayerTestSuite))
 }
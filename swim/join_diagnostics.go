@@ -0,0 +1,196 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/json"
+	"golang.org/x/net/context"
+)
+
+// A SeedOutcome classifies the outcome of the last attempt to join a seed,
+// i.e. a bootstrap host.
+type SeedOutcome string
+
+const (
+	// SeedJoined means the seed was joined.
+	SeedJoined SeedOutcome = "joined"
+
+	// SeedConnectionRefused means no connection to the seed could be made.
+	SeedConnectionRefused SeedOutcome = "connection-refused"
+
+	// SeedAppMismatch means the seed is a member of a cluster of another app.
+	SeedAppMismatch SeedOutcome = "app-mismatch"
+
+	// SeedDenied means the seed refused the join, because the join was
+	// throttled or the node is blacklisted, or the node refused to contact
+	// the seed itself.
+	SeedDenied SeedOutcome = "denied"
+
+	// SeedConfigMismatch means the seed advertised an incompatible checksum
+	// algorithm or ring configuration.
+	SeedConfigMismatch SeedOutcome = "config-mismatch"
+
+	// SeedTimeout means the seed did not respond within the join timeout.
+	SeedTimeout SeedOutcome = "timeout"
+
+	// SeedFailed means the join failed for another reason, see the error of
+	// the SeedDiagnostic.
+	SeedFailed SeedOutcome = "failed"
+)
+
+// A SeedDiagnostic describes the attempts to join a seed.
+type SeedDiagnostic struct {
+	Seed     string      `json:"seed"`
+	Outcome  SeedOutcome `json:"outcome"`
+	Attempts int         `json:"attempts"`
+
+	// Error is the error of the last attempt, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// A JoinError is returned by Bootstrap when the node failed to join the
+// cluster, unless it was destroyed while joining. It describes the attempts to join each seed, so that a failure to
+// bootstrap can be told apart from a cluster that is unreachable, configured
+// differently or refusing joins.
+type JoinError struct {
+	Reason JoinFailedReason
+
+	// Duration is how long the node tried to join the cluster.
+	Duration time.Duration
+
+	// Seeds are the outcomes of the seeds the node tried to join, ordered by
+	// address.
+	Seeds []SeedDiagnostic
+
+	Err error
+}
+
+func (e *JoinError) Error() string {
+	return fmt.Sprintf("%v (%s)", e.Err, e.Summary())
+}
+
+// Summary counts the seeds by outcome, such as
+// "3 seeds: 2 connection-refused, 1 timeout".
+func (e *JoinError) Summary() string {
+	counts := make(map[SeedOutcome]int)
+	var outcomes []string
+	for _, seed := range e.Seeds {
+		if counts[seed.Outcome] == 0 {
+			outcomes = append(outcomes, string(seed.Outcome))
+		}
+		counts[seed.Outcome]++
+	}
+	sort.Strings(outcomes)
+
+	parts := make([]string, len(outcomes))
+	for i, outcome := range outcomes {
+		parts[i] = fmt.Sprintf("%d %s", counts[SeedOutcome(outcome)], outcome)
+	}
+	return fmt.Sprintf("%d seeds: %s", len(e.Seeds), strings.Join(parts, ", "))
+}
+
+// joinDiagnostics records the outcomes of the attempts to join seeds.
+type joinDiagnostics struct {
+	seeds map[string]*SeedDiagnostic
+	sync.Mutex
+}
+
+// record records the outcome of an attempt to join the seed. A nil error
+// records a join.
+func (d *joinDiagnostics) record(seed string, outcome SeedOutcome, err error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.seeds == nil {
+		d.seeds = make(map[string]*SeedDiagnostic)
+	}
+	diagnostic, ok := d.seeds[seed]
+	if !ok {
+		diagnostic = &SeedDiagnostic{Seed: seed}
+		d.seeds[seed] = diagnostic
+	}
+
+	diagnostic.Attempts++
+	diagnostic.Outcome = outcome
+	diagnostic.Error = ""
+	if err != nil {
+		diagnostic.Error = err.Error()
+	}
+}
+
+// list returns the diagnostics of the seeds ordered by address.
+func (d *joinDiagnostics) list() []SeedDiagnostic {
+	d.Lock()
+	defer d.Unlock()
+
+	seeds := make([]SeedDiagnostic, 0, len(d.seeds))
+	for _, diagnostic := range d.seeds {
+		seeds = append(seeds, *diagnostic)
+	}
+	sort.Sort(seedsByAddress(seeds))
+	return seeds
+}
+
+type seedsByAddress []SeedDiagnostic
+
+func (s seedsByAddress) Len() int           { return len(s) }
+func (s seedsByAddress) Less(i, j int) bool { return s[i].Seed < s[j].Seed }
+func (s seedsByAddress) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// classifyJoinError classifies the error of a join call. Errors returned by
+// the join handler of the seed only carry their message.
+func classifyJoinError(err error) SeedOutcome {
+	switch err {
+	case ErrBlacklisted, ErrSimulatedPartition, errJoinThrottled:
+		return SeedDenied
+	case context.DeadlineExceeded, tchannel.ErrTimeout:
+		return SeedTimeout
+	}
+
+	message := err.Error()
+	if appErr, ok := err.(json.ErrApplication); ok {
+		message, _ = appErr["message"].(string)
+		switch {
+		case message == ErrBlacklisted.Error(),
+			message == ErrSimulatedPartition.Error(),
+			message == errJoinThrottled.Error():
+			return SeedDenied
+		case strings.Contains(message, "different app cluster"):
+			return SeedAppMismatch
+		}
+		return SeedFailed
+	}
+
+	if strings.Contains(message, "connection refused") {
+		return SeedConnectionRefused
+	}
+	if strings.Contains(message, "timeout") || strings.Contains(message, "timed out") {
+		return SeedTimeout
+	}
+	return SeedFailed
+}
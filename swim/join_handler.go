@@ -25,10 +25,13 @@ import "fmt"
 // A JoinResponse is sent back as a response to a JoinRequest from a
 // remote node
 type joinResponse struct {
-	App         string   `json:"app"`
-	Coordinator string   `json:"coordinator"`
-	Membership  []Change `json:"membership"`
-	Checksum    uint32   `json:"membershipChecksum"`
+	App               string   `json:"app"`
+	Coordinator       string   `json:"coordinator"`
+	Membership        []Change `json:"membership"`
+	Checksum          uint32   `json:"membershipChecksum"`
+	ChecksumAlgorithm string   `json:"checksumAlgorithm,omitempty"`
+	RingFingerprint   string   `json:"ringFingerprint,omitempty"`
+	Features          Features `json:"features,omitempty"`
 }
 
 // TODO: Denying joins?
@@ -66,11 +69,21 @@ func handleJoin(node *Node, req *joinRequest) (*joinResponse, error) {
 		return nil, err
 	}
 
+	if err := node.admitJoin(req.Source); err != nil {
+		return nil, err
+	}
+
+	node.recordPeerFeatures(req.Source, req.Features)
+
 	res := &joinResponse{
 		App:         node.app,
 		Coordinator: node.address,
 		Membership:  node.disseminator.FullSync(),
 		Checksum:    node.memberlist.Checksum(),
+
+		ChecksumAlgorithm: node.ChecksumVersion().algorithm(),
+		RingFingerprint:   node.RingFingerprint(),
+		Features:          node.LocalFeatures(),
 	}
 
 	return res, nil
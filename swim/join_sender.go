@@ -23,13 +23,14 @@ package swim
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	log "github.com/uber-common/bark"
 	"github.com/gl-works/ringpop-go/logging"
-	"github.com/gl-works/ringpop-go/shared"
 	"github.com/gl-works/ringpop-go/util"
 	"github.com/uber/tchannel-go/json"
 )
@@ -58,6 +59,7 @@ type joinRequest struct {
 	Source      string        `json:"source"`
 	Incarnation int64         `json:"incarnationNumber"`
 	Timeout     time.Duration `json:"timeout"`
+	Features    Features      `json:"features,omitempty"`
 }
 
 // joinOpts are opts to perform a join with
@@ -67,12 +69,27 @@ type joinOpts struct {
 	maxJoinDuration   time.Duration
 	parallelismFactor int
 
+	// maxAttempts is the number of groups to join before the join fails, no
+	// limit if zero.
+	maxAttempts int
+
+	// quorum is the fraction of the potential nodes that must be joined, if
+	// that is more than size.
+	quorum float64
+
 	// discoverProvider is the DiscoverProvider that this joinSender will use to
 	// enumerate bootstrap hosts.
 	discoverProvider DiscoverProvider
 
 	// delayer delays repeated join attempts.
 	delayer joinDelayer
+
+	// delayOpts configure the exponential delayer used when no delayer is
+	// set.
+	delayOpts *delayOpts
+
+	// clock measures the join duration, it defaults to the system clock.
+	clock clock.Clock
 }
 
 // A joinSender is used to join an existing cluster of nodes defined in a node's
@@ -91,6 +108,7 @@ type joinSender struct {
 	parallelismFactor int
 
 	maxJoinDuration time.Duration
+	maxAttempts     int
 
 	potentialNodes    []string
 	preferredNodes    []string
@@ -109,9 +127,15 @@ type joinSender struct {
 
 	numTries int
 
+	// diagnostics records the outcomes of the attempts to join seeds, which
+	// are returned in a JoinError when the join fails.
+	diagnostics joinDiagnostics
+
 	// delayer delays repeated join attempts.
 	delayer joinDelayer
 
+	clock clock.Clock
+
 	logger log.Logger
 }
 
@@ -125,6 +149,10 @@ func newJoinSender(node *Node, opts *joinOpts) (*joinSender, error) {
 		return nil, errors.New("missing host provider in join options")
 	}
 
+	if opts.quorum < 0 || opts.quorum > 1 {
+		return nil, errors.New("join quorum must be between 0 and 1")
+	}
+
 	// Resolve/retrieve bootstrap hosts from the provider specified in the
 	// join options.
 	bootstrapHosts, err := opts.discoverProvider.Hosts()
@@ -149,13 +177,21 @@ func newJoinSender(node *Node, opts *joinOpts) (*joinSender, error) {
 	js.maxJoinDuration = util.SelectDuration(opts.maxJoinDuration, defaultMaxJoinDuration)
 	js.parallelismFactor = util.SelectInt(opts.parallelismFactor, defaultParallelismFactor)
 	js.size = util.SelectInt(opts.size, defaultJoinSize)
+	if quorum := int(math.Ceil(opts.quorum * float64(len(js.potentialNodes)))); quorum > js.size {
+		js.size = quorum
+	}
 	js.size = util.Min(js.size, len(js.potentialNodes))
+	js.maxAttempts = opts.maxAttempts
 	js.delayer = opts.delayer
+	js.clock = opts.clock
+	if js.clock == nil {
+		js.clock = clock.New()
+	}
 
 	if js.delayer == nil {
-		// Create and use exponential delayer as the delay mechanism. Create it
-		// with nil opts which uses default delayOpts.
-		js.delayer, err = newExponentialDelayer(js.node.address, nil)
+		// Create and use exponential delayer as the delay mechanism. Nil
+		// delayOpts use the default delayOpts.
+		js.delayer, err = newExponentialDelayer(js.node.address, opts.delayOpts)
 		if err != nil {
 			return nil, err
 		}
@@ -281,7 +317,7 @@ func (j *joinSender) JoinCluster() ([]string, error) {
 	var numGroups = 0
 	var numJoined = 0
 	var numFailed = 0
-	var startTime = time.Now()
+	var startTime = j.clock.Now()
 
 	if util.SingleNodeCluster(j.node.address, j.bootstrapHostsMap) {
 		j.logger.Info("got single node cluster to join")
@@ -297,7 +333,19 @@ func (j *joinSender) JoinCluster() ([]string, error) {
 			return nil, errors.New("node destroyed while attempting to join cluster")
 		}
 		// join group of nodes
-		successes, failures := j.JoinGroup(nodesJoined)
+		successes, failures, err := j.JoinGroup(nodesJoined)
+		if err != nil {
+			joinErr := j.joinError(Mismatch, startTime, err)
+			j.logger.WithFields(log.Fields{
+				"error": err,
+				"seeds": joinErr.Summary(),
+			}).Error("refusing to join cluster")
+			j.node.emit(JoinFailedEvent{
+				Reason: Mismatch,
+				Error:  joinErr,
+			})
+			return nil, joinErr
+		}
 
 		nodesJoined = append(nodesJoined, successes...)
 		numJoined += len(successes)
@@ -307,7 +355,7 @@ func (j *joinSender) JoinCluster() ([]string, error) {
 		if numJoined >= j.size {
 			j.logger.WithFields(log.Fields{
 				"joinSize":  j.size,
-				"joinTime":  time.Now().Sub(startTime),
+				"joinTime":  j.clock.Now().Sub(startTime),
 				"numJoined": numJoined,
 				"numFailed": numFailed,
 				"numGroups": numGroups,
@@ -316,20 +364,21 @@ func (j *joinSender) JoinCluster() ([]string, error) {
 			break
 		}
 
-		joinDuration := time.Now().Sub(startTime)
+		joinDuration := j.clock.Now().Sub(startTime)
 
 		if joinDuration > j.maxJoinDuration {
+			err := j.joinError(Error, startTime, fmt.Errorf(
+				"join duration of %v exceeded max %v", joinDuration, j.maxJoinDuration))
+
 			j.logger.WithFields(log.Fields{
 				"joinDuration":    joinDuration,
 				"maxJoinDuration": j.maxJoinDuration,
 				"numJoined":       numJoined,
 				"numFailed":       numFailed,
+				"seeds":           err.Summary(),
 				"startTime":       startTime,
 			}).Warn("max join duration exceeded")
 
-			err := fmt.Errorf("join duration of %v exceeded max %v",
-				joinDuration, j.maxJoinDuration)
-
 			j.node.emit(JoinFailedEvent{
 				Reason: Error,
 				Error:  err,
@@ -337,6 +386,25 @@ func (j *joinSender) JoinCluster() ([]string, error) {
 			return nodesJoined, err
 		}
 
+		if j.maxAttempts > 0 && numGroups >= j.maxAttempts {
+			err := j.joinError(MaxAttempts, startTime, fmt.Errorf(
+				"joined %d of %d nodes in %d attempts", numJoined, j.size, numGroups))
+
+			j.logger.WithFields(log.Fields{
+				"maxAttempts": j.maxAttempts,
+				"numJoined":   numJoined,
+				"numFailed":   numFailed,
+				"seeds":       err.Summary(),
+				"startTime":   startTime,
+			}).Warn("max join attempts exceeded")
+
+			j.node.emit(JoinFailedEvent{
+				Reason: MaxAttempts,
+				Error:  err,
+			})
+			return nodesJoined, err
+		}
+
 		j.logger.WithFields(log.Fields{
 			"joinSize":  j.size,
 			"numJoined": numJoined,
@@ -344,11 +412,19 @@ func (j *joinSender) JoinCluster() ([]string, error) {
 			"startTime": startTime,
 		}).Debug("join not yet complete")
 
-		j.delayer.delay()
+		delay := j.delayer.delay()
+
+		j.node.emit(JoinRetriedEvent{
+			Attempt:   numGroups + 1,
+			Delay:     delay,
+			NumJoined: numJoined,
+			JoinSize:  j.size,
+			Seeds:     j.diagnostics.list(),
+		})
 	}
 
 	j.node.emit(JoinCompleteEvent{
-		Duration:  time.Now().Sub(startTime),
+		Duration:  j.clock.Now().Sub(startTime),
 		NumJoined: numJoined,
 		Joined:    nodesJoined,
 	})
@@ -356,12 +432,28 @@ func (j *joinSender) JoinCluster() ([]string, error) {
 	return nodesJoined, nil
 }
 
-func (j *joinSender) JoinGroup(nodesJoined []string) ([]string, []string) {
+// joinError returns a JoinError with the outcomes of the attempts to join
+// seeds so far.
+func (j *joinSender) joinError(reason JoinFailedReason, startTime time.Time, err error) *JoinError {
+	return &JoinError{
+		Reason:   reason,
+		Duration: j.clock.Now().Sub(startTime),
+		Seeds:    j.diagnostics.list(),
+		Err:      err,
+	}
+}
+
+// JoinGroup sends join requests to a group of nodes and returns the nodes
+// that were joined successfully and the nodes that failed to respond. A non-nil
+// error is returned when a node responded with a configuration that is
+// incompatible with the local node, in which case the join must be aborted.
+func (j *joinSender) JoinGroup(nodesJoined []string) ([]string, []string, error) {
 	group := j.SelectGroup(nodesJoined)
 
 	var responses struct {
 		successes []string
 		failures  []string
+		mismatch  error
 		sync.Mutex
 	}
 
@@ -376,7 +468,7 @@ func (j *joinSender) JoinGroup(nodesJoined []string) ([]string, []string) {
 	for _, node := range group {
 		wg.Add(1)
 		go func(n string) {
-			ctx, cancel := shared.NewTChannelContext(j.timeout)
+			ctx, cancel := j.node.mesh.NewContext(j.timeout, n)
 			defer cancel()
 
 			var res joinResponse
@@ -389,17 +481,32 @@ func (j *joinSender) JoinGroup(nodesJoined []string) ([]string, []string) {
 						"remote":  n,
 						"timeout": j.timeout,
 					}).Debug("attempt to join node failed")
+					j.diagnostics.record(n, classifyJoinError(err), err)
+					failed = true
+					break
+				}
+
+				if err := j.validateJoinResponse(n, &res); err != nil {
+					responses.Lock()
+					responses.mismatch = err
+					responses.Unlock()
+					j.diagnostics.record(n, SeedConfigMismatch, err)
 					failed = true
 					break
 				}
 
-				j.node.memberlist.AddJoinList(res.Membership)
+				// the membership of a quarantined seed is not merged
+				if !j.node.Quarantined(n) {
+					j.node.memberlist.AddJoinList(res.Membership)
+				}
+				j.diagnostics.record(n, SeedJoined, nil)
 
 			case <-ctx.Done():
 				j.logger.WithFields(log.Fields{
 					"remote":  n,
 					"timeout": j.timeout,
 				}).Debug("attempt to join node timed out")
+				j.diagnostics.record(n, SeedTimeout, ctx.Err())
 				failed = true
 			}
 
@@ -431,7 +538,47 @@ func (j *joinSender) JoinGroup(nodesJoined []string) ([]string, []string) {
 		"successes":    responses.successes,
 	}).Debug("join group complete")
 
-	return responses.successes, responses.failures
+	return responses.successes, responses.failures, responses.mismatch
+}
+
+// RingFingerprint returns the ring fingerprint the node advertises when it
+// joins or is joined, see Options.
+func (n *Node) RingFingerprint() string {
+	n.ringFingerprint.RLock()
+	defer n.ringFingerprint.RUnlock()
+	return n.ringFingerprint.value
+}
+
+// SetRingFingerprint changes the ring fingerprint the node advertises, e.g.
+// when the application changed the configuration of its hash ring without a
+// restart. Nodes that join afterwards must be configured with the new
+// fingerprint.
+func (n *Node) SetRingFingerprint(fingerprint string) {
+	n.ringFingerprint.Lock()
+	n.ringFingerprint.value = fingerprint
+	n.ringFingerprint.Unlock()
+}
+
+// validateJoinResponse checks that the checksum algorithm and ring fingerprint
+// advertised by the remote node are compatible with the local node, and adopts
+// the checksum version of the cluster. Remote nodes that do not advertise these
+// values are assumed to be compatible.
+func (j *joinSender) validateJoinResponse(remote string, res *joinResponse) error {
+	if res.ChecksumAlgorithm != "" {
+		if err := j.node.adoptChecksumAlgorithm(remote, res.ChecksumAlgorithm); err != nil {
+			return err
+		}
+	}
+
+	fingerprint := j.node.RingFingerprint()
+	if res.RingFingerprint != "" && fingerprint != "" &&
+		res.RingFingerprint != fingerprint {
+		return fmt.Errorf("cluster member %s advertises ring configuration "+
+			"%q, while the local node is configured with %q", remote,
+			res.RingFingerprint, fingerprint)
+	}
+
+	return nil
 }
 
 func (j *joinSender) MakeCall(ctx json.Context, node string, res *joinResponse) <-chan error {
@@ -440,16 +587,21 @@ func (j *joinSender) MakeCall(ctx json.Context, node string, res *joinResponse)
 	go func() {
 		defer close(errC)
 
-		peer := j.node.channel.Peers().GetOrAdd(node)
+		if err := j.node.checkPeer(node); err != nil {
+			errC <- err
+			return
+		}
 
 		req := joinRequest{
 			App:         j.node.app,
 			Source:      j.node.address,
 			Incarnation: j.node.Incarnation(),
 			Timeout:     j.timeout,
+			Features:    j.node.LocalFeatures(),
 		}
 
-		err := json.CallPeer(ctx, peer, j.node.service, "/protocol/join", req, res)
+		j.node.capture(Outbound, node, "/protocol/join", false, req)
+		err := j.node.send(ctx, node, "/protocol/join", req, res)
 		if err != nil {
 			j.logger.WithFields(log.Fields{
 				"error": err,
@@ -458,6 +610,14 @@ func (j *joinSender) MakeCall(ctx json.Context, node string, res *joinResponse)
 			return
 		}
 
+		j.node.capture(Inbound, node, "/protocol/join", true, res)
+
+		if err := validateMessage("/protocol/join", true, res); err != nil {
+			errC <- err
+			return
+		}
+		j.node.recordPeerFeatures(res.Coordinator, res.Features)
+
 		errC <- nil
 	}()
 
@@ -0,0 +1,111 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dgryski/go-farm"
+)
+
+// errJoinThrottled is returned to a joining node when the node already
+// admitted as many joins as it admits in the current admission window.
+var errJoinThrottled = errors.New("join throttled, too many nodes are joining")
+
+// joinStaggerDelay returns the delay before a node with the address sends its
+// first join requests, between 0 and window. The delay is a hash of the
+// address, so that nodes that start at the same time, e.g. on a cold start of
+// the whole cluster, spread their joins over the window.
+func joinStaggerDelay(address string, window time.Duration) time.Duration {
+	if window <= 0 {
+		return 0
+	}
+	return time.Duration(farm.Fingerprint64([]byte(address)) % uint64(window))
+}
+
+// joinAdmission limits the number of joins a node admits per window, so that
+// seed nodes are not overwhelmed when many nodes join at once. The limit
+// starts at initial joins and doubles after every window in which joins were
+// throttled, so that a storm of joins is admitted in exponentially growing
+// batches, and returns to initial once joins are no longer throttled.
+type joinAdmission struct {
+	initial int
+	window  time.Duration
+
+	limit     int
+	start     time.Time
+	admitted  int
+	throttled int
+	sync.Mutex
+}
+
+// admit returns whether a join received at now is admitted, the current
+// admission limit, and whether the join is the first one that was throttled
+// in the current window, which means a join storm is detected. All joins are
+// admitted when admission is disabled.
+func (a *joinAdmission) admit(now time.Time) (admitted bool, limit int, storm bool) {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.initial <= 0 {
+		return true, 0, false
+	}
+
+	if elapsed := now.Sub(a.start); elapsed >= a.window {
+		if a.throttled > 0 && elapsed < 2*a.window {
+			a.limit *= 2
+		} else {
+			a.limit = a.initial
+		}
+		a.start = now
+		a.admitted = 0
+		a.throttled = 0
+	}
+
+	if a.admitted < a.limit {
+		a.admitted++
+		return true, a.limit, false
+	}
+
+	a.throttled++
+	return false, a.limit, a.throttled == 1
+}
+
+// admitJoin checks whether the join of source is admitted, and emits the join
+// storm and throttle events when it is not.
+func (n *Node) admitJoin(source string) error {
+	admitted, limit, storm := n.admission.admit(n.clock.Now())
+	if admitted {
+		return nil
+	}
+
+	if storm {
+		n.logger.WithField("limit", limit).Warn("join storm detected, throttling joins")
+		n.emit(JoinStormDetectedEvent{Limit: limit})
+	}
+	n.emit(JoinThrottledEvent{
+		Source: source,
+		Limit:  limit,
+	})
+	return errJoinThrottled
+}
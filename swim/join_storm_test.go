@@ -0,0 +1,105 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/gl-works/ringpop-go/events"
+)
+
+func TestJoinStaggerDelay(t *testing.T) {
+	assert.Equal(t, time.Duration(0), joinStaggerDelay("127.0.0.1:3001", 0))
+
+	window := 10 * time.Second
+	delays := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		address := fmt.Sprintf("127.0.0.1:%d", 3000+i)
+		delay := joinStaggerDelay(address, window)
+		assert.True(t, delay >= 0 && delay < window, "expected delay within the window")
+		assert.Equal(t, delay, joinStaggerDelay(address, window), "expected a stable delay")
+		delays[delay] = true
+	}
+	assert.True(t, len(delays) > 1, "expected joins to be spread over the window")
+}
+
+func TestJoinAdmission(t *testing.T) {
+	mockClock := clock.NewMock()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		Clock:              mockClock,
+		JoinAdmissionLimit: 2,
+	})
+
+	var storms []JoinStormDetectedEvent
+	var throttled []JoinThrottledEvent
+	node.RegisterListener(ListenerFunc(func(event events.Event) {
+		switch event := event.(type) {
+		case JoinStormDetectedEvent:
+			storms = append(storms, event)
+		case JoinThrottledEvent:
+			throttled = append(throttled, event)
+		}
+	}))
+
+	join := func(i int) error {
+		_, err := handleJoin(node, &joinRequest{
+			App:    "test",
+			Source: fmt.Sprintf("127.0.0.1:%d", 4000+i),
+		})
+		return err
+	}
+
+	admitted := func(joins int) int {
+		n := 0
+		for i := 0; i < joins; i++ {
+			if join(i) == nil {
+				n++
+			}
+		}
+		return n
+	}
+
+	assert.Equal(t, 2, admitted(5), "expected joins above the limit to be throttled")
+	assert.Equal(t, []JoinStormDetectedEvent{{Limit: 2}}, storms, "expected one storm per window")
+	assert.Len(t, throttled, 3)
+	assert.Equal(t, errJoinThrottled, join(0))
+
+	mockClock.Add(time.Second)
+	assert.Equal(t, 4, admitted(5), "expected the limit to double after a storm")
+
+	mockClock.Add(time.Second)
+	assert.Equal(t, 8, admitted(10), "expected the limit to keep doubling")
+
+	mockClock.Add(5 * time.Second)
+	assert.Equal(t, 2, admitted(5), "expected the limit to reset after the storm")
+}
+
+func TestJoinAdmissionDisabled(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{Clock: clock.NewMock()})
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, node.admitJoin("127.0.0.1:3002"))
+	}
+}
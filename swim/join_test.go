@@ -21,11 +21,17 @@
 package swim
 
 import (
+	"errors"
 	"sort"
 	"testing"
+	"time"
 
+	"github.com/benbjohnson/clock"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"github.com/uber/tchannel-go/json"
+	"golang.org/x/net/context"
 )
 
 type JoinSenderTestSuite struct {
@@ -78,6 +84,31 @@ func (s *JoinSenderTestSuite) TestSelectGroup() {
 	s.EqualValues([]string{fakeHosts[0], fakeHosts[1]}, group)
 }
 
+func (s *JoinSenderTestSuite) TestJoinQuorum() {
+	bootstrapHosts := append(fakeHostPorts(1, 1, 2, 10), s.node.Address())
+
+	joiner, err := newJoinSender(s.node, &joinOpts{
+		quorum:           0.5,
+		discoverProvider: &StaticHostList{bootstrapHosts},
+	})
+	s.Require().NoError(err)
+	s.Equal(5, joiner.size, "expected half of the 9 other hosts, rounded up, to be joined")
+
+	joiner, err = newJoinSender(s.node, &joinOpts{
+		size:             6,
+		quorum:           0.5,
+		discoverProvider: &StaticHostList{bootstrapHosts},
+	})
+	s.Require().NoError(err)
+	s.Equal(6, joiner.size, "expected join size to be kept when it exceeds the quorum")
+
+	_, err = newJoinSender(s.node, &joinOpts{
+		quorum:           1.5,
+		discoverProvider: &StaticHostList{bootstrapHosts},
+	})
+	s.EqualError(err, "join quorum must be between 0 and 1")
+}
+
 func (s *JoinSenderTestSuite) TestSelectMultipleGroups() {
 	bootstrapHosts := append(fakeHostPorts(1, 1, 2, 3), s.node.Address())
 	expected := fakeHostPorts(1, 1, 2, 3)
@@ -199,6 +230,76 @@ func (s *JoinSenderTestSuite) TestJoinSelf() {
 	}
 }
 
+func (s *JoinSenderTestSuite) TestJoinRingFingerprintMismatch() {
+	tnode := newChannelNode(s.T())
+	tnode.node.SetRingFingerprint("replicaPoints=100")
+	defer tnode.Destroy()
+
+	peer := newChannelNode(s.T())
+	peer.node.SetRingFingerprint("replicaPoints=200")
+	defer peer.Destroy()
+
+	bootstrapNodes(s.T(), peer)
+
+	joined, err := tnode.node.Bootstrap(&BootstrapOptions{
+		DiscoverProvider: &StaticHostList{[]string{peer.node.Address(), tnode.node.Address()}},
+		MaxJoinDuration:  time.Second,
+		Stopped:          true,
+	})
+	s.Error(err, "expected join to be refused on fingerprint mismatch")
+	s.Contains(err.Error(), "replicaPoints=200")
+	s.Require().IsType(&JoinError{}, err)
+	s.Equal(JoinFailedReason(Mismatch), err.(*JoinError).Reason)
+	s.Equal([]SeedDiagnostic{{
+		Seed:     peer.node.Address(),
+		Outcome:  SeedConfigMismatch,
+		Attempts: 1,
+		Error:    err.(*JoinError).Err.Error(),
+	}}, err.(*JoinError).Seeds)
+	s.Empty(joined, "expected no nodes to be joined")
+	s.Equal(1, tnode.node.memberlist.NumMembers(), "expected remote membership to not be applied")
+}
+
+func (s *JoinSenderTestSuite) TestValidateJoinResponse() {
+	joiner, err := newJoinSender(s.node, &joinOpts{
+		discoverProvider: &StaticHostList{fakeHostPorts(1, 1, 1, 1)},
+	})
+	s.Require().NoError(err, "cannot have an error")
+
+	s.node.SetRingFingerprint("a")
+
+	s.NoError(joiner.validateJoinResponse("remote", &joinResponse{}),
+		"expected responses without advertised values to be accepted")
+	s.NoError(joiner.validateJoinResponse("remote", &joinResponse{
+		ChecksumAlgorithm: "farmhash32",
+		RingFingerprint:   "a",
+	}))
+	s.Error(joiner.validateJoinResponse("remote", &joinResponse{
+		ChecksumAlgorithm: "crc32",
+	}), "expected checksum algorithm mismatch to be an error")
+	s.Error(joiner.validateJoinResponse("remote", &joinResponse{
+		RingFingerprint: "b",
+	}), "expected ring fingerprint mismatch to be an error")
+
+	s.node.SetRingFingerprint("")
+	s.NoError(joiner.validateJoinResponse("remote", &joinResponse{
+		RingFingerprint: "b",
+	}), "expected empty local fingerprint to disable the check")
+}
+
+func (s *JoinSenderTestSuite) TestValidateJoinResponseAdoptsChecksumVersion() {
+	joiner, err := newJoinSender(s.node, &joinOpts{
+		discoverProvider: &StaticHostList{fakeHostPorts(1, 1, 1, 1)},
+	})
+	s.Require().NoError(err, "cannot have an error")
+	s.Require().Equal(ChecksumV1, s.node.ChecksumVersion())
+
+	s.NoError(joiner.validateJoinResponse("remote", &joinResponse{
+		ChecksumAlgorithm: "farmhash32-v2",
+	}))
+	s.Equal(ChecksumV2, s.node.ChecksumVersion(), "expected version of the cluster to be adopted")
+}
+
 func (s *JoinSenderTestSuite) TestCustomDelayer() {
 	delayer := &nullDelayer{}
 	joiner, err := newJoinSender(s.node, &joinOpts{
@@ -209,6 +310,128 @@ func (s *JoinSenderTestSuite) TestCustomDelayer() {
 	s.Equal(delayer, joiner.delayer, "custom delayer was set")
 }
 
+func (s *JoinSenderTestSuite) TestJoinBackoffOnJoinClock() {
+	joinClock := clock.NewMock()
+
+	// the seed refuses connections, so every attempt fails right away
+	errC := make(chan error, 1)
+	go func() {
+		_, err := s.node.Bootstrap(&BootstrapOptions{
+			Hosts:                 []string{"127.0.0.1:1"},
+			JoinTimeout:           time.Second,
+			MaxJoinDuration:       3 * time.Second,
+			JoinRetryInitialDelay: time.Second,
+			JoinRetryMaxDelay:     time.Second,
+			JoinClock:             joinClock,
+		})
+		errC <- err
+	}()
+
+	for {
+		select {
+		case err := <-errC:
+			s.Require().Error(err)
+			s.Contains(err.Error(), "exceeded max 3s", "expected max join duration to be measured on the join clock")
+			return
+		case <-time.After(time.Millisecond):
+			joinClock.Add(time.Second)
+		}
+	}
+}
+
+func (s *JoinSenderTestSuite) TestJoinMaxAttempts() {
+	var retried []JoinRetriedEvent
+	var failed []JoinFailedEvent
+	s.node.RegisterListener(ListenerFunc(func(event events.Event) {
+		switch event := event.(type) {
+		case JoinRetriedEvent:
+			retried = append(retried, event)
+		case JoinFailedEvent:
+			failed = append(failed, event)
+		}
+	}))
+
+	_, err := s.node.Bootstrap(&BootstrapOptions{
+		Hosts:                 []string{"127.0.0.1:1"},
+		JoinTimeout:           time.Second,
+		JoinMaxAttempts:       3,
+		JoinRetryInitialDelay: time.Millisecond,
+		JoinRetryMaxDelay:     time.Millisecond,
+		JoinRetryJitter:       0.5,
+	})
+	s.Require().IsType(&JoinError{}, err)
+	s.Equal(JoinFailedReason(MaxAttempts), err.(*JoinError).Reason)
+	s.Contains(err.Error(), "joined 0 of 1 nodes in 3 attempts")
+
+	s.Require().Len(retried, 2, "expected a retried event before every attempt after the first")
+	for i, event := range retried {
+		s.Equal(i+2, event.Attempt)
+		s.True(event.Delay <= time.Millisecond, "expected the delay to be capped")
+		s.Equal(0, event.NumJoined)
+		s.Equal(1, event.JoinSize)
+		s.Require().Len(event.Seeds, 1)
+		s.Equal(SeedConnectionRefused, event.Seeds[0].Outcome, "expected the reason of the retry")
+	}
+
+	s.Require().Len(failed, 1)
+	s.Equal(JoinFailedReason(MaxAttempts), failed[0].Reason)
+}
+
+func (s *JoinSenderTestSuite) TestJoinErrorDiagnostics() {
+	peer := newChannelNode(s.T())
+	peer.node.app = "different"
+	defer peer.Destroy()
+	bootstrapNodes(s.T(), peer)
+
+	tnode := newChannelNode(s.T())
+	defer tnode.Destroy()
+
+	_, err := tnode.node.Bootstrap(&BootstrapOptions{
+		Hosts:                 []string{"127.0.0.1:1", peer.node.Address(), tnode.node.Address()},
+		JoinTimeout:           time.Second,
+		MaxJoinDuration:       100 * time.Millisecond,
+		JoinRetryInitialDelay: time.Millisecond,
+		JoinRetryMaxDelay:     time.Millisecond,
+		Stopped:               true,
+	})
+	s.Require().IsType(&JoinError{}, err)
+	joinErr := err.(*JoinError)
+	s.Equal(Error, joinErr.Reason)
+
+	s.Require().Len(joinErr.Seeds, 2)
+	outcomes := map[string]SeedOutcome{}
+	for _, seed := range joinErr.Seeds {
+		s.NotZero(seed.Attempts)
+		s.NotEmpty(seed.Error)
+		outcomes[seed.Seed] = seed.Outcome
+	}
+	s.Equal(map[string]SeedOutcome{
+		"127.0.0.1:1":       SeedConnectionRefused,
+		peer.node.Address(): SeedAppMismatch,
+	}, outcomes)
+	s.Equal("2 seeds: 1 app-mismatch, 1 connection-refused", joinErr.Summary())
+	s.Contains(err.Error(), joinErr.Summary())
+}
+
+func TestClassifyJoinError(t *testing.T) {
+	tests := []struct {
+		err     error
+		outcome SeedOutcome
+	}{
+		{ErrBlacklisted, SeedDenied},
+		{json.ErrApplication{"type": "error", "message": errJoinThrottled.Error()}, SeedDenied},
+		{json.ErrApplication{"type": "error", "message": "A node tried joining a different app cluster."}, SeedAppMismatch},
+		{json.ErrApplication{"type": "error", "message": "unexpected"}, SeedFailed},
+		{context.DeadlineExceeded, SeedTimeout},
+		{errors.New("dial tcp 127.0.0.1:1: connect: connection refused"), SeedConnectionRefused},
+		{errors.New("unexpected"), SeedFailed},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.outcome, classifyJoinError(test.err), test.err.Error())
+	}
+}
+
 func TestJoinSenderTestSuite(t *testing.T) {
 	suite.Run(t, new(JoinSenderTestSuite))
 }
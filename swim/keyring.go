@@ -0,0 +1,359 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	json2 "encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// KeySize is the size in bytes of the keys of a Keyring.
+const KeySize = 32
+
+// sealVersion is the version of the format of sealed messages.
+const sealVersion = 1
+
+const (
+	keyIDSize = 4
+	ivSize    = aes.BlockSize
+	macSize   = sha256.Size
+)
+
+var (
+	errKeySize        = fmt.Errorf("key must be %d bytes", KeySize)
+	errUnknownKey     = errors.New("key not in keyring")
+	errPrimaryKey     = errors.New("primary key cannot be removed")
+	errSealedTooShort = errors.New("sealed message too short")
+	errSealVersion    = errors.New("unsupported sealed message version")
+	errNoMatchingKey  = errors.New("no key in keyring for message")
+	errMAC            = errors.New("message authentication code mismatch")
+	errPlaintext      = errors.New("plaintext message not accepted")
+)
+
+// A MessageAuthenticationError is returned when a protocol message received
+// from a peer is not sealed with a key of the keyring of the node, or was
+// modified in transit. Such messages are rejected before they are decoded.
+type MessageAuthenticationError struct {
+	// Endpoint is the protocol endpoint the message was sent to.
+	Endpoint string
+
+	// Response is true if the message is a response, false if it is a
+	// request.
+	Response bool
+
+	Err error
+}
+
+func (e *MessageAuthenticationError) Error() string {
+	kind := "request"
+	if e.Response {
+		kind = "response"
+	}
+	return fmt.Sprintf("unauthenticated %s %s: %v", e.Endpoint, kind, e.Err)
+}
+
+// A Keyring holds the shared keys the messages of the membership protocol are
+// encrypted and authenticated with, so that members can gossip across
+// networks that are not trusted. Messages are sealed with the primary key and
+// accepted when sealed with any key of the keyring.
+//
+// Keys are rotated without downtime by adding the new key to the keyring of
+// every member, then making it the primary key of every member, and finally
+// removing the old key from every member.
+//
+// Messages are encrypted with AES-256 in CTR mode and authenticated with an
+// HMAC-SHA256 over the ciphertext, the endpoint and whether the message is a
+// request or response, so a sealed message cannot be replayed to another
+// endpoint. Replays of a message to the same endpoint are not detected; the
+// protocol tolerates them as it tolerates duplicate messages. Only the
+// messages of the protocol, i.e. pings, ping requests, joins and syncs, are
+// sealed, not the admin endpoints.
+type Keyring struct {
+	sync.RWMutex
+	primary *sealKey
+	keys    []*sealKey
+
+	acceptPlaintext bool
+	sendPlaintext   bool
+}
+
+// sealKey is a key of a keyring with the keys derived from it.
+type sealKey struct {
+	key    []byte
+	id     []byte
+	cipher cipher.Block
+	mac    []byte
+}
+
+func newSealKey(key []byte) (*sealKey, error) {
+	if len(key) != KeySize {
+		return nil, errKeySize
+	}
+
+	derive := func(purpose string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(purpose))
+		return h.Sum(nil)
+	}
+
+	block, err := aes.NewCipher(derive("ringpop encryption"))
+	if err != nil {
+		return nil, err
+	}
+
+	id := sha256.Sum256(key)
+	return &sealKey{
+		key:    append([]byte(nil), key...),
+		id:     id[:keyIDSize],
+		cipher: block,
+		mac:    derive("ringpop authentication"),
+	}, nil
+}
+
+// NewKeyring returns a Keyring with the primary key, which also accepts
+// messages sealed with the other keys. Keys must be KeySize bytes.
+func NewKeyring(primary []byte, keys ...[]byte) (*Keyring, error) {
+	k := &Keyring{}
+	for _, key := range append([][]byte{primary}, keys...) {
+		if err := k.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+	if err := k.UseKey(primary); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// AddKey adds a key that messages are accepted with.
+func (k *Keyring) AddKey(key []byte) error {
+	sk, err := newSealKey(key)
+	if err != nil {
+		return err
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	if k.find(key) == nil {
+		k.keys = append(k.keys, sk)
+	}
+	return nil
+}
+
+// UseKey makes a key that was added to the keyring the primary key, which
+// messages are sealed with.
+func (k *Keyring) UseKey(key []byte) error {
+	k.Lock()
+	defer k.Unlock()
+
+	sk := k.find(key)
+	if sk == nil {
+		return errUnknownKey
+	}
+	k.primary = sk
+	return nil
+}
+
+// SetAcceptPlaintext sets whether messages that are not sealed are accepted.
+// Together with SetSendPlaintext it is used to enable sealing on a running
+// cluster: first give every member a keyring that accepts and sends
+// plaintext, then stop sending plaintext on every member, and finally stop
+// accepting it.
+func (k *Keyring) SetAcceptPlaintext(accept bool) {
+	k.Lock()
+	k.acceptPlaintext = accept
+	k.Unlock()
+}
+
+// SetSendPlaintext sets whether messages are sent without sealing them, see
+// SetAcceptPlaintext.
+func (k *Keyring) SetSendPlaintext(send bool) {
+	k.Lock()
+	k.sendPlaintext = send
+	k.Unlock()
+}
+
+// RemoveKey removes a key from the keyring, after which messages sealed with
+// it are rejected. The primary key cannot be removed.
+func (k *Keyring) RemoveKey(key []byte) error {
+	k.Lock()
+	defer k.Unlock()
+
+	if k.primary != nil && bytes.Equal(k.primary.key, key) {
+		return errPrimaryKey
+	}
+	for i, sk := range k.keys {
+		if bytes.Equal(sk.key, key) {
+			k.keys = append(k.keys[:i], k.keys[i+1:]...)
+			return nil
+		}
+	}
+	return errUnknownKey
+}
+
+// PrimaryKey returns the key messages are sealed with, or nil if no key was
+// made the primary key with UseKey.
+func (k *Keyring) PrimaryKey() []byte {
+	k.RLock()
+	defer k.RUnlock()
+
+	if k.primary == nil {
+		return nil
+	}
+	return k.primary.key
+}
+
+// Keys returns the keys of the keyring, the primary key first.
+func (k *Keyring) Keys() [][]byte {
+	k.RLock()
+	defer k.RUnlock()
+
+	var keys [][]byte
+	if k.primary != nil {
+		keys = append(keys, k.primary.key)
+	}
+	for _, sk := range k.keys {
+		if sk != k.primary {
+			keys = append(keys, sk.key)
+		}
+	}
+	return keys
+}
+
+// find returns the key, or nil if it is not in the keyring. The keyring must
+// be locked.
+func (k *Keyring) find(key []byte) *sealKey {
+	for _, sk := range k.keys {
+		if bytes.Equal(sk.key, key) {
+			return sk
+		}
+	}
+	return nil
+}
+
+// findID returns the key with the id, or nil if there is none. The keyring
+// must be locked.
+func (k *Keyring) findID(id []byte) *sealKey {
+	for _, sk := range k.keys {
+		if bytes.Equal(sk.id, id) {
+			return sk
+		}
+	}
+	return nil
+}
+
+// seal encrypts and authenticates the JSON encoded message with the primary
+// key. The sealed message is a JSON string, so that it can be carried by
+// transports that expect JSON, like TChannel JSON calls.
+func (k *Keyring) seal(endpoint string, response bool, msg []byte) ([]byte, error) {
+	k.RLock()
+	sk, plaintext := k.primary, k.sendPlaintext
+	k.RUnlock()
+
+	if plaintext {
+		return msg, nil
+	}
+	if sk == nil {
+		return nil, errNoMatchingKey
+	}
+
+	sealed := make([]byte, 1+keyIDSize+ivSize+len(msg), 1+keyIDSize+ivSize+len(msg)+macSize)
+	sealed[0] = sealVersion
+	copy(sealed[1:], sk.id)
+	iv := sealed[1+keyIDSize : 1+keyIDSize+ivSize]
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	cipher.NewCTR(sk.cipher, iv).XORKeyStream(sealed[1+keyIDSize+ivSize:], msg)
+	sealed = append(sealed, sk.sum(endpoint, response, sealed)...)
+
+	return json2.Marshal(sealed)
+}
+
+// open authenticates and decrypts a message sealed with a key of the keyring.
+// Messages that are not sealed are returned as they are if the keyring
+// accepts plaintext.
+func (k *Keyring) open(endpoint string, response bool, msg []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(msg)
+	if len(trimmed) == 0 || trimmed[0] != '"' {
+		k.RLock()
+		accept := k.acceptPlaintext
+		k.RUnlock()
+
+		if accept {
+			return msg, nil
+		}
+		return nil, errPlaintext
+	}
+
+	var sealed []byte
+	if err := json2.Unmarshal(trimmed, &sealed); err != nil {
+		return nil, err
+	}
+	if len(sealed) < 1+keyIDSize+ivSize+macSize {
+		return nil, errSealedTooShort
+	}
+	if sealed[0] != sealVersion {
+		return nil, errSealVersion
+	}
+
+	k.RLock()
+	sk := k.findID(sealed[1 : 1+keyIDSize])
+	k.RUnlock()
+
+	if sk == nil {
+		return nil, errNoMatchingKey
+	}
+
+	body, mac := sealed[:len(sealed)-macSize], sealed[len(sealed)-macSize:]
+	if !hmac.Equal(mac, sk.sum(endpoint, response, body)) {
+		return nil, errMAC
+	}
+
+	iv := body[1+keyIDSize : 1+keyIDSize+ivSize]
+	ciphertext := body[1+keyIDSize+ivSize:]
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCTR(sk.cipher, iv).XORKeyStream(plaintext, ciphertext)
+	return plaintext, nil
+}
+
+// sum returns the message authentication code of a sealed message to the
+// endpoint.
+func (sk *sealKey) sum(endpoint string, response bool, sealed []byte) []byte {
+	h := hmac.New(sha256.New, sk.mac)
+	h.Write([]byte(endpoint))
+	if response {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+	h.Write(sealed)
+	return h.Sum(nil)
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestNewKeyring(t *testing.T) {
+	_, err := NewKeyring([]byte("short"))
+	assert.Equal(t, errKeySize, err)
+
+	k, err := NewKeyring(testKey(1), testKey(2), testKey(1))
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{testKey(1), testKey(2)}, k.Keys())
+	assert.Equal(t, testKey(1), k.PrimaryKey())
+
+	k = &Keyring{}
+	require.NoError(t, k.AddKey(testKey(1)))
+	assert.Nil(t, k.PrimaryKey(), "expected no primary key until one is used")
+}
+
+func TestKeyringSealOpen(t *testing.T) {
+	k, err := NewKeyring(testKey(1))
+	require.NoError(t, err)
+
+	msg := []byte(`{"source":"127.0.0.1:3001"}`)
+	sealed, err := k.seal("/protocol/ping", false, msg)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(sealed, []byte("127.0.0.1")), "expected the message to be encrypted")
+
+	opened, err := k.open("/protocol/ping", false, sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, opened)
+
+	_, err = k.open("/protocol/join", false, sealed)
+	assert.Equal(t, errMAC, err, "expected a message to another endpoint to be rejected")
+
+	_, err = k.open("/protocol/ping", true, sealed)
+	assert.Equal(t, errMAC, err, "expected a request replayed as response to be rejected")
+
+	resealed, err := k.seal("/protocol/ping", false, msg)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, resealed, "expected a random IV")
+
+	_, err = k.open("/protocol/ping", false, []byte(`"AQ=="`))
+	assert.Equal(t, errSealedTooShort, err)
+}
+
+func TestKeyringTampered(t *testing.T) {
+	k, err := NewKeyring(testKey(1))
+	require.NoError(t, err)
+
+	sealed, err := k.seal("/protocol/ping", false, []byte(`{}`))
+	require.NoError(t, err)
+
+	// flip a bit of the ciphertext in the base64 encoding
+	tampered := append([]byte(nil), sealed...)
+	i := len(tampered) / 2
+	if tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+
+	_, err = k.open("/protocol/ping", false, tampered)
+	assert.Error(t, err)
+}
+
+func TestKeyringPlaintext(t *testing.T) {
+	k, err := NewKeyring(testKey(1))
+	require.NoError(t, err)
+
+	_, err = k.open("/protocol/ping", false, []byte(`{}`))
+	assert.Equal(t, errPlaintext, err)
+
+	k.SetAcceptPlaintext(true)
+	opened, err := k.open("/protocol/ping", false, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{}`), opened)
+
+	k.SetSendPlaintext(true)
+	sealed, err := k.seal("/protocol/ping", false, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{}`), sealed)
+}
+
+func TestKeyringSetPlaintextConcurrently(t *testing.T) {
+	k, err := NewKeyring(testKey(1))
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			k.SetAcceptPlaintext(i%2 == 0)
+			k.SetSendPlaintext(i%2 == 0)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		sealed, err := k.seal("/protocol/ping", false, []byte(`{}`))
+		require.NoError(t, err)
+		k.open("/protocol/ping", false, sealed)
+	}
+	<-done
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old, err := NewKeyring(testKey(1))
+	require.NoError(t, err)
+	rotated, err := NewKeyring(testKey(2), testKey(1))
+	require.NoError(t, err)
+
+	sealed, err := old.seal("/protocol/ping", false, []byte(`{}`))
+	require.NoError(t, err)
+	_, err = rotated.open("/protocol/ping", false, sealed)
+	assert.NoError(t, err, "expected messages sealed with an accepted key to be opened")
+
+	sealed, err = rotated.seal("/protocol/ping", false, []byte(`{}`))
+	require.NoError(t, err)
+	_, err = old.open("/protocol/ping", false, sealed)
+	assert.Equal(t, errNoMatchingKey, err)
+
+	require.NoError(t, old.AddKey(testKey(2)))
+	_, err = old.open("/protocol/ping", false, sealed)
+	assert.NoError(t, err, "expected messages sealed with an added key to be opened")
+
+	assert.Equal(t, errUnknownKey, old.UseKey(testKey(3)))
+	require.NoError(t, old.UseKey(testKey(2)))
+	assert.Equal(t, errPrimaryKey, old.RemoveKey(testKey(2)))
+	require.NoError(t, old.RemoveKey(testKey(1)))
+	assert.Equal(t, errUnknownKey, old.RemoveKey(testKey(1)))
+	assert.Equal(t, [][]byte{testKey(2)}, old.Keys())
+}
+
+func TestKeyringGossip(t *testing.T) {
+	newNode := func(key byte) *testNode {
+		keyring, err := NewKeyring(testKey(key))
+		require.NoError(t, err)
+		return newChannelNodeWithOptions(t, &Options{
+			Clock:   clock.NewMock(),
+			Keyring: keyring,
+		})
+	}
+
+	seed, member, outsider := newNode(1), newNode(1), newNode(2)
+	defer destroyNodes(seed, member, outsider)
+
+	var rejected []MessageAuthenticationFailedEvent
+	seed.node.RegisterListener(ListenerFunc(func(event events.Event) {
+		if event, ok := event.(MessageAuthenticationFailedEvent); ok {
+			rejected = append(rejected, event)
+		}
+	}))
+
+	bootstrapNodes(t, seed, member)
+	_, err := sendPing(seed.node, member.node.Address(), time.Second)
+	assert.NoError(t, err, "expected members that share a key to gossip")
+
+	_, err = outsider.node.Bootstrap(&BootstrapOptions{
+		Hosts:           []string{seed.node.Address()},
+		JoinTimeout:     time.Second,
+		JoinMaxAttempts: 1,
+		Stopped:         true,
+	})
+	assert.Error(t, err, "expected a member with another key not to join")
+	assert.Equal(t, []MessageAuthenticationFailedEvent{{Endpoint: "/protocol/join"}}, rejected)
+}
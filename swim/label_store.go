@@ -0,0 +1,180 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A LabelStore persists labels of the local member, such as an assigned token
+// set or a drain state, so that they survive process restarts. The node saves
+// the persisted labels whenever they change and restores them before it
+// bootstraps, so it rejoins the cluster with the labels it had.
+// Implementations must be safe for concurrent use.
+type LabelStore interface {
+	// Load returns the persisted labels. It returns no labels and no error
+	// if none were saved yet.
+	Load() (map[string]string, error)
+
+	// Save persists the labels, replacing those saved before.
+	Save(labels map[string]string) error
+}
+
+// FileLabelStore is a LabelStore that persists labels in a JSON file.
+type FileLabelStore struct {
+	path string
+}
+
+// NewFileLabelStore returns a FileLabelStore that persists labels in the file
+// at path.
+func NewFileLabelStore(path string) *FileLabelStore {
+	return &FileLabelStore{path: path}
+}
+
+// Load reads the labels from the file.
+func (s *FileLabelStore) Load() (map[string]string, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// Save writes the labels to a temporary file that replaces the file, so that
+// a crash while saving does not leave a truncated file behind.
+func (s *FileLabelStore) Save(labels map[string]string) error {
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// labelPersistence is the store the labels of the local member with the
+// persisted keys are saved to, see Options.LabelStore.
+type labelPersistence struct {
+	store LabelStore
+	keys  map[string]bool
+}
+
+// persisted returns the labels with a persisted key.
+func (p *labelPersistence) persisted(labels map[string]string) map[string]string {
+	if len(p.keys) == 0 {
+		return copyLabels(labels)
+	}
+
+	persisted := make(map[string]string)
+	for key, value := range labels {
+		if p.keys[key] {
+			persisted[key] = value
+		}
+	}
+	return persisted
+}
+
+// saveLabels persists the labels with a persisted key, unless they did not
+// change.
+func (n *Node) saveLabels(old, labels map[string]string) error {
+	p := &n.labelPersistence
+	if p.store == nil {
+		return nil
+	}
+
+	persisted := p.persisted(labels)
+	if labelsEqual(p.persisted(old), persisted) {
+		return nil
+	}
+	return p.store.Save(persisted)
+}
+
+// restoreLabels loads the persisted labels, so that the local member is made
+// alive with them when the node bootstraps.
+func (n *Node) restoreLabels() error {
+	p := &n.labelPersistence
+	if p.store == nil {
+		return nil
+	}
+
+	labels, err := p.store.Load()
+	if err != nil {
+		return err
+	}
+
+	labels = p.persisted(labels)
+	if len(labels) == 0 {
+		return nil
+	}
+	if err := validateLabels(labels); err != nil {
+		return err
+	}
+
+	n.memberlist.RestoreLabels(labels)
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	n.logger.WithField("labels", keys).Info("restored persisted labels")
+	n.emit(LabelsRestoredEvent{Labels: copyLabels(labels)})
+
+	return nil
+}
+
+// labelsEqual returns whether the labels have the same keys and values.
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingLabelStore struct{}
+
+func (failingLabelStore) Load() (map[string]string, error) {
+	return nil, errors.New("load failed")
+}
+
+func (failingLabelStore) Save(labels map[string]string) error {
+	return errors.New("save failed")
+}
+
+func tempLabelStore(t *testing.T) (*FileLabelStore, func()) {
+	dir, err := ioutil.TempDir("", "labels")
+	require.NoError(t, err)
+	return NewFileLabelStore(filepath.Join(dir, "labels.json")), func() { os.RemoveAll(dir) }
+}
+
+func TestFileLabelStore(t *testing.T) {
+	store, cleanup := tempLabelStore(t)
+	defer cleanup()
+
+	labels, err := store.Load()
+	assert.NoError(t, err, "expected a missing file to have no labels")
+	assert.Empty(t, labels)
+
+	require.NoError(t, store.Save(map[string]string{"drain": "true"}))
+	require.NoError(t, store.Save(map[string]string{"tokens": "1,2,3"}))
+
+	labels, err = store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"tokens": "1,2,3"}, labels)
+
+	require.NoError(t, ioutil.WriteFile(store.path, []byte("{"), 0600))
+	_, err = store.Load()
+	assert.Error(t, err, "expected a corrupt file to fail to load")
+}
+
+func TestLabelsSurviveRestart(t *testing.T) {
+	store, cleanup := tempLabelStore(t)
+	defer cleanup()
+
+	tnode := newChannelNode(t)
+	tnode.node.labelPersistence = labelPersistence{
+		store: store,
+		keys:  map[string]bool{"drain": true},
+	}
+	bootstrapNodes(t, tnode)
+
+	require.NoError(t, tnode.node.Labels().Set("drain", "true"))
+	require.NoError(t, tnode.node.Labels().Set("canary", "true"))
+	tnode.Destroy()
+
+	labels, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"drain": "true"}, labels,
+		"expected only labels with a persisted key to be saved")
+
+	restarted := newChannelNode(t)
+	defer restarted.Destroy()
+	restarted.node.labelPersistence = labelPersistence{
+		store: store,
+		keys:  map[string]bool{"drain": true},
+	}
+	bootstrapNodes(t, restarted)
+
+	assert.Equal(t, map[string]string{"drain": "true"}, restarted.node.Labels().AsMap(),
+		"expected persisted labels to be restored before bootstrap")
+	local, ok := restarted.node.MemberLabels(restarted.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"drain": "true"}, local)
+}
+
+func TestLabelStoreFailures(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+	tnode.node.labelPersistence.store = failingLabelStore{}
+
+	_, err := tnode.node.Bootstrap(&BootstrapOptions{
+		Hosts:   []string{tnode.node.Address()},
+		Stopped: true,
+	})
+	assert.Error(t, err, "expected bootstrap to fail when labels cannot be restored")
+
+	tnode.node.labelPersistence.store = nil
+	bootstrapNodes(t, tnode)
+	tnode.node.labelPersistence.store = failingLabelStore{}
+
+	assert.Error(t, tnode.node.Labels().Set("drain", "true"))
+	_, ok := tnode.node.Labels().Get("drain")
+	assert.True(t, ok, "expected label to be set even if it failed to persist")
+}
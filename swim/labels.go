@@ -0,0 +1,160 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxLabels is the maximum number of labels of a member.
+	MaxLabels = 32
+
+	// MaxLabelsSize is the maximum total length of the keys and values of
+	// the labels of a member.
+	MaxLabelsSize = 1024
+)
+
+// Labels are the key/value labels of the local member, such as "gpu": "a100"
+// or "canary": "true". Zones are declared with an annotation instead, see
+// Options.ZoneAnnotation. Unlike annotations, which are operational notes,
+// labels describe the capabilities of a member for routing work, and are
+// covered by the membership checksum from ChecksumV3 on. They are gossiped to
+// all members along with the status of the member and exposed on the remote
+// Member structs.
+type Labels struct {
+	node *Node
+}
+
+// Labels returns the labels of the local member.
+func (n *Node) Labels() *Labels {
+	return &Labels{node: n}
+}
+
+// Get returns the value of the label of the local member with the given key.
+func (l *Labels) Get(key string) (string, bool) {
+	value, ok := l.node.memberlist.LocalLabels()[key]
+	return value, ok
+}
+
+// AsMap returns a copy of the labels of the local member.
+func (l *Labels) AsMap() map[string]string {
+	return copyLabels(l.node.memberlist.LocalLabels())
+}
+
+// Set sets the label of the local member with the given key to value, and
+// disseminates the change. It returns an error if the labels would exceed
+// MaxLabels or MaxLabelsSize, or if the label is persisted and saving it to
+// the LabelStore failed, in which case the label is set but not persisted.
+func (l *Labels) Set(key, value string) error {
+	if key == "" {
+		return errors.New("label key must not be empty")
+	}
+	return l.update(func(labels map[string]string) {
+		labels[key] = value
+	})
+}
+
+// Delete removes the label of the local member with the given key, and
+// disseminates the change.
+func (l *Labels) Delete(key string) error {
+	return l.update(func(labels map[string]string) {
+		delete(labels, key)
+	})
+}
+
+// update changes a copy of the labels of the local member and applies it.
+// Updates are serialized, so that concurrent changes of different labels are
+// not lost.
+func (l *Labels) update(change func(labels map[string]string)) error {
+	n := l.node
+	if !n.Ready() {
+		return ErrNodeNotReady
+	}
+
+	n.labelUpdates.Lock()
+	defer n.labelUpdates.Unlock()
+
+	old := n.memberlist.LocalLabels()
+	labels := copyLabels(old)
+	change(labels)
+
+	if err := validateLabels(labels); err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+
+	if n.memberlist.SetLabels(labels) != nil {
+		n.logger.WithField("labels", labels).Info("changed local member labels")
+		n.emit(LabelsChangedEvent{Labels: labels})
+	}
+
+	// the labels are persisted once they are applied, so that the store never
+	// holds labels the member did not have
+	return n.saveLabels(old, labels)
+}
+
+// MemberLabels returns the labels of the member at address. Ok is false if the
+// member is not known.
+func (n *Node) MemberLabels(address string) (labels map[string]string, ok bool) {
+	member, ok := n.memberlist.Member(address)
+	if !ok {
+		return nil, false
+	}
+
+	member.RLock()
+	defer member.RUnlock()
+	return copyLabels(member.Labels), true
+}
+
+// copyLabels returns a copy of the labels.
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
+
+// validateLabels checks that the labels are within the limits.
+func validateLabels(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("a member can have at most %d labels, got %d",
+			MaxLabels, len(labels))
+	}
+
+	size := 0
+	for key, value := range labels {
+		if key == "" {
+			return errors.New("label key must not be empty")
+		}
+		size += len(key) + len(value)
+	}
+	if size > MaxLabelsSize {
+		return fmt.Errorf("labels of a member must not exceed %d bytes, got %d",
+			MaxLabelsSize, size)
+	}
+
+	return nil
+}
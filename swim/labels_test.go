@@ -0,0 +1,151 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelsNotReady(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	assert.Equal(t, ErrNodeNotReady, tnode.node.Labels().Set("canary", "true"))
+	assert.Equal(t, ErrNodeNotReady, tnode.node.Labels().Delete("canary"))
+}
+
+func TestLabelsAreGossiped(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+	tclock := tnode.node.clock.(*clock.Mock)
+	tclock.Add(time.Second)
+
+	labels := tnode.node.Labels()
+	require.NoError(t, labels.Set("canary", "true"))
+	require.NoError(t, labels.Set("gpu", "a100"))
+
+	value, ok := labels.Get("gpu")
+	assert.True(t, ok)
+	assert.Equal(t, "a100", value)
+
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	remote, ok := tpeer.node.MemberLabels(tnode.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"canary": "true", "gpu": "a100"}, remote,
+		"expected labels to be gossiped")
+
+	// refuting a suspicion keeps the labels of the local member
+	tclock.Add(time.Second)
+	tnode.node.memberlist.MakeSuspect(tnode.node.Address(), tnode.node.Incarnation())
+	assert.Len(t, labels.AsMap(), 2)
+
+	tclock.Add(time.Second)
+	require.NoError(t, labels.Delete("canary"))
+	_, ok = labels.Get("canary")
+	assert.False(t, ok, "expected label to be deleted")
+
+	_, err = sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	remote, _ = tpeer.node.MemberLabels(tnode.node.Address())
+	assert.Equal(t, map[string]string{"gpu": "a100"}, remote,
+		"expected deleted label to be gossiped")
+}
+
+func TestLabelsConcurrentUpdates(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+	bootstrapNodes(t, tnode)
+
+	labels := tnode.node.Labels()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			assert.NoError(t, labels.Set(key, "true"))
+		}(strings.Repeat("k", i+1))
+	}
+	wg.Wait()
+
+	assert.Len(t, labels.AsMap(), 10, "expected no label to be lost")
+}
+
+func TestLabelsLimits(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+	bootstrapNodes(t, tnode)
+
+	labels := tnode.node.Labels()
+	assert.Error(t, labels.Set("", "value"))
+	assert.Error(t, labels.Set("key", strings.Repeat("a", MaxLabelsSize)))
+
+	for i := 0; i < MaxLabels; i++ {
+		require.NoError(t, labels.Set(strings.Repeat("k", i+1), "v"))
+	}
+	assert.Error(t, labels.Set("another", "v"), "expected too many labels to be rejected")
+	assert.Len(t, labels.AsMap(), MaxLabels)
+
+	_, ok := tnode.node.MemberLabels("127.0.0.1:1")
+	assert.False(t, ok, "expected unknown member to have no labels")
+}
+
+func TestLabelsChecksum(t *testing.T) {
+	nodeA := NewNode("test", "127.0.0.1:3001", nil, nil)
+	defer nodeA.Destroy()
+	nodeB := NewNode("test", "127.0.0.1:3001", nil, nil)
+	defer nodeB.Destroy()
+
+	nodeA.memberlist.MakeAlive("127.0.0.1:3001", 1)
+	nodeB.memberlist.MakeAlive("127.0.0.1:3001", 1)
+	nodeA.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive,
+		Incarnation: 1}})
+	nodeB.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive,
+		Incarnation: 1, Labels: map[string]string{"canary": "true"}}})
+
+	nodeA.memberlist.SetChecksumVersion(ChecksumV2)
+	nodeB.memberlist.SetChecksumVersion(ChecksumV2)
+	assert.Equal(t, nodeA.memberlist.Checksum(), nodeB.memberlist.Checksum(),
+		"expected v2 checksum not to cover labels")
+
+	nodeA.memberlist.SetChecksumVersion(ChecksumV3)
+	nodeB.memberlist.SetChecksumVersion(ChecksumV3)
+	assert.NotEqual(t, nodeA.memberlist.Checksum(), nodeB.memberlist.Checksum(),
+		"expected v3 checksum to cover labels")
+}
+
+func TestValidateLabels(t *testing.T) {
+	assert.NoError(t, validateLabels(nil))
+	assert.NoError(t, validateLabels(map[string]string{"gpu": "a100"}))
+	assert.Error(t, validateLabels(map[string]string{"": "us-1"}))
+}
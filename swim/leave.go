@@ -0,0 +1,115 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"math"
+	"sync"
+
+	log "github.com/uber-common/bark"
+)
+
+// leaveFanout is the number of members a leave is sent to right away, so that
+// it spreads before the node shuts down.
+const leaveFanout = 3
+
+// Leave gossips that the node voluntarily leaves the cluster, e.g. before a
+// clean shutdown. Other members remove it from their rings as soon as they
+// learn about it, instead of suspecting it and declaring it faulty once it is
+// gone. Besides being disseminated by the protocol, the leave is sent to a few
+// members right away; Leave returns once they acknowledged it or timed out.
+// The node keeps gossiping, but is no longer pinged by other members, until it
+// rejoins with Rejoin. Leaving a node that already left has no effect.
+func (n *Node) Leave() error {
+	_, err := n.leave(func(int) int { return leaveFanout })
+	return err
+}
+
+// LeaveNotify leaves the cluster like Leave, but sends the leave right away to
+// a fraction of the pingable members, between 0 and 1, rounded up, instead of
+// a few. It returns the number of members the leave was sent to, which is 0
+// when the node already left.
+func (n *Node) LeaveNotify(fraction float64) (int, error) {
+	return n.leave(func(pingable int) int {
+		return int(math.Ceil(fraction * float64(pingable)))
+	})
+}
+
+// leave makes the node leave and sends the leave to the number of pingable
+// members returned by fanout.
+func (n *Node) leave(fanout func(pingable int) int) (int, error) {
+	if !n.Ready() {
+		return 0, ErrNodeNotReady
+	}
+	if n.Left() {
+		return 0, nil
+	}
+
+	n.memberlist.MakeLeave(n.address, n.Incarnation())
+	n.logger.Info("left the cluster")
+
+	targets := n.memberlist.RandomPingableMembers(fanout(n.memberlist.NumPingableMembers()), nil)
+
+	var wg sync.WaitGroup
+	for _, member := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			if _, err := sendPing(n, target, n.pingTimeout); err != nil {
+				n.logger.WithFields(log.Fields{
+					"remote": target,
+					"error":  err,
+				}).Debug("failed to announce leave")
+			}
+		}(member.Address)
+	}
+	wg.Wait()
+
+	return len(targets), nil
+}
+
+// Rejoin makes a node that left the cluster alive again, with a new
+// incarnation number so that the change overrides the leave on all members.
+// Rejoining a node that did not leave has no effect.
+func (n *Node) Rejoin() error {
+	if !n.Ready() {
+		return ErrNodeNotReady
+	}
+	if !n.Left() {
+		return nil
+	}
+
+	n.memberlist.MakeAlive(n.address, n.memberlist.nextIncarnation())
+	n.logger.Info("rejoined the cluster")
+	return nil
+}
+
+// Left returns whether the node left the cluster.
+func (n *Node) Left() bool {
+	local := n.memberlist.local
+	if local == nil {
+		return false
+	}
+
+	local.RLock()
+	defer local.RUnlock()
+	return local.Status == Leave
+}
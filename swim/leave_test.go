@@ -0,0 +1,102 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaveNotReady(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	assert.Equal(t, ErrNodeNotReady, tnode.node.Leave())
+	assert.Equal(t, ErrNodeNotReady, tnode.node.Rejoin())
+	assert.False(t, tnode.node.Left())
+}
+
+func TestLeaveIsAnnounced(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+	tclock := tnode.node.clock.(*clock.Mock)
+	tclock.Add(time.Second)
+
+	require.NoError(t, tnode.node.Leave())
+	assert.True(t, tnode.node.Left())
+	assert.NoError(t, tnode.node.Leave(), "expected leaving again to have no effect")
+
+	// the peer learns about the leave without waiting for a protocol period
+	member, ok := tpeer.node.memberlist.Member(tnode.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, Leave, member.Status, "expected leave to be announced")
+	assert.Equal(t, 0, tpeer.node.memberlist.NumPingableMembers(),
+		"expected member that left not to be pinged")
+
+	// a member that left does not refute suspicions
+	tnode.node.memberlist.MakeSuspect(tnode.node.Address(), tnode.node.Incarnation())
+	assert.True(t, tnode.node.Left())
+
+	incarnation := tnode.node.Incarnation()
+	tclock.Add(time.Second)
+	require.NoError(t, tnode.node.Rejoin())
+	assert.False(t, tnode.node.Left())
+	assert.True(t, tnode.node.Incarnation() > incarnation, "expected rejoin to reincarnate")
+	assert.NoError(t, tnode.node.Rejoin(), "expected rejoining again to have no effect")
+
+	_, err := sendPing(tnode.node, tpeer.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	member, _ = tpeer.node.memberlist.Member(tnode.node.Address())
+	assert.Equal(t, Alive, member.Status, "expected rejoin to be gossiped")
+}
+
+func TestLeaveNotify(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeers := []*testNode{newChannelNode(t), newChannelNode(t), newChannelNode(t), newChannelNode(t)}
+	defer destroyNodes(append(tpeers, tnode)...)
+
+	bootstrapNodes(t, append(tpeers, tnode)...)
+
+	notified, err := tnode.node.LeaveNotify(0.5)
+	require.NoError(t, err)
+	assert.Equal(t, 2, notified, "expected half of the pingable members to be notified")
+	assert.True(t, tnode.node.Left())
+
+	leaves := 0
+	for _, tpeer := range tpeers {
+		if member, ok := tpeer.node.memberlist.Member(tnode.node.Address()); ok && member.Status == Leave {
+			leaves++
+		}
+	}
+	assert.True(t, leaves >= notified, "expected notified members to learn about the leave")
+
+	notified, err = tnode.node.LeaveNotify(0.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, notified, "expected leaving again to have no effect")
+}
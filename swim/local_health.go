@@ -0,0 +1,120 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+)
+
+// localHealth is the local health multiplier of the Lifeguard extensions to
+// SWIM. Its score rises when there is evidence that the node itself is slow,
+// such as failed probes, refuted suspicions of the local member or delayed
+// protocol periods, and falls with every successful probe. The timeouts of
+// the node's probes and suspect periods are multiplied by score+1, so that a
+// node starved of CPU or paused by garbage collection does not declare
+// healthy members faulty.
+type localHealth struct {
+	sync.Mutex
+
+	// max is the highest score. A max of zero disables the multiplier.
+	max   int
+	score int
+}
+
+// adjust adds delta to the score, bounded by zero and max, and returns the
+// new score and whether it changed.
+func (h *localHealth) adjust(delta int) (int, bool) {
+	h.Lock()
+	defer h.Unlock()
+
+	score := h.score + delta
+	if score > h.max {
+		score = h.max
+	}
+	if score < 0 {
+		score = 0
+	}
+
+	changed := score != h.score
+	h.score = score
+	return score, changed
+}
+
+// Score returns the current score.
+func (h *localHealth) Score() int {
+	h.Lock()
+	defer h.Unlock()
+	return h.score
+}
+
+// scale multiplies the timeout by the local health multiplier.
+func (h *localHealth) scale(timeout time.Duration) time.Duration {
+	return timeout * time.Duration(h.Score()+1)
+}
+
+// LocalHealth returns the local health score of the node, between zero for a
+// healthy node and the configured LocalHealthMax. The node's probe and
+// suspicion timeouts are multiplied by the score plus one.
+func (n *Node) LocalHealth() int {
+	return n.localHealth.Score()
+}
+
+// adjustLocalHealth adds delta to the local health score of the node and
+// emits a LocalHealthChangedEvent if the score changed.
+func (n *Node) adjustLocalHealth(delta int, reason string) {
+	score, changed := n.localHealth.adjust(delta)
+	if !changed {
+		return
+	}
+
+	n.logger.WithFields(log.Fields{
+		"score":  score,
+		"reason": reason,
+	}).Debug("local health changed")
+	n.emit(LocalHealthChangedEvent{
+		Score:  score,
+		Reason: reason,
+	})
+}
+
+// recordProbe adjusts the local health score of the node after probing a
+// member. A missing response is evidence that the node itself is slow, since
+// the probe goes through the node's own network stack and timers.
+func (n *Node) recordProbe(verdict Verdict) {
+	switch verdict {
+	case Reachable:
+		n.adjustLocalHealth(-1, "probe succeeded")
+	case Unreachable, Inconclusive:
+		n.adjustLocalHealth(1, "probe failed")
+	}
+}
+
+// recordProtocolDelay adjusts the local health score of the node when the
+// protocol period loop slept longer than requested by more than the minimum
+// protocol period, which means the node missed a protocol period.
+func (n *Node) recordProtocolDelay(requested, slept time.Duration) {
+	if slept-requested > n.gossip.minProtocolPeriod {
+		n.adjustLocalHealth(1, "protocol period delayed")
+	}
+}
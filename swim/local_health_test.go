@@ -0,0 +1,89 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalHealthBounds(t *testing.T) {
+	h := &localHealth{max: 2}
+
+	score, changed := h.adjust(-1)
+	assert.Equal(t, 0, score)
+	assert.False(t, changed, "expected score not to drop below zero")
+
+	h.adjust(1)
+	h.adjust(1)
+	score, changed = h.adjust(1)
+	assert.Equal(t, 2, score)
+	assert.False(t, changed, "expected score not to exceed max")
+
+	assert.Equal(t, 3*time.Second, h.scale(time.Second))
+}
+
+func TestLocalHealthDisabled(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, nil)
+
+	node.recordProbe(Unreachable)
+	assert.Equal(t, 0, node.LocalHealth())
+	assert.Equal(t, time.Second, node.localHealth.scale(time.Second))
+}
+
+func TestLocalHealthScalesTimeouts(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		SuspicionTimeout: time.Second,
+		LocalHealthMax:   8,
+	})
+
+	node.recordProbe(Unreachable)
+	node.recordProbe(Inconclusive)
+	node.recordProtocolDelay(time.Millisecond, time.Second)
+	assert.Equal(t, 3, node.LocalHealth())
+	assert.Equal(t, 4*time.Second, node.suspicion.suspectTimeout("127.0.0.1:3002"))
+
+	node.recordProtocolDelay(time.Millisecond, 2*time.Millisecond)
+	assert.Equal(t, 3, node.LocalHealth(), "expected small delays to be ignored")
+
+	node.recordProbe(Reachable)
+	assert.Equal(t, 2, node.LocalHealth())
+	assert.Equal(t, 2, node.ProtocolStats().LocalHealth)
+}
+
+func TestLocalHealthRefutedSuspicion(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+	tnode.node.localHealth.max = 8
+
+	bootstrapNodes(t, tnode)
+
+	local, _ := tnode.node.memberlist.Member(tnode.node.Address())
+	tnode.node.memberlist.Update([]Change{{
+		Address:     tnode.node.Address(),
+		Incarnation: local.Incarnation,
+		Status:      Suspect,
+	}})
+
+	assert.Equal(t, 1, tnode.node.LocalHealth(), "expected refuted suspicion to raise local health score")
+}
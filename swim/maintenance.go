@@ -0,0 +1,83 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import "time"
+
+// MaintenanceAnnotation is the annotation a member declares a maintenance
+// window with. Its value is the end of the window in RFC 3339 format.
+const MaintenanceAnnotation = "maintenance-until"
+
+// SetMaintenance declares a maintenance window of the local member that ends
+// at until, for planned events such as long garbage collections or
+// compactions that may keep the member from responding to pings. The window is
+// gossiped to all members as the MaintenanceAnnotation annotation. While it
+// lasts, the suspect period of the member is longer and it is only declared
+// faulty after additional suspect periods, see Options. A zero until ends the
+// window.
+func (n *Node) SetMaintenance(until time.Time) error {
+	value := ""
+	if !until.IsZero() {
+		value = until.UTC().Format(time.RFC3339)
+	}
+	return n.Annotate(map[string]string{MaintenanceAnnotation: value})
+}
+
+// InMaintenance returns whether the member at address is in a maintenance
+// window it declared.
+func (n *Node) InMaintenance(address string) bool {
+	annotations, ok := n.Annotations(address)
+	if !ok {
+		return false
+	}
+
+	until, err := time.Parse(time.RFC3339, annotations[MaintenanceAnnotation])
+	if err != nil {
+		return false
+	}
+	return n.clock.Now().Before(until)
+}
+
+// suspectTimeout returns the duration of the suspect period of a member.
+func (s *suspicion) suspectTimeout(address string) time.Duration {
+	timeout := s.node.localHealth.scale(s.baseTimeout())
+	if s.node.InMaintenance(address) {
+		return timeout * time.Duration(s.maintenanceFactor)
+	}
+	return timeout
+}
+
+// deferFaulty returns whether declaring a suspect member faulty is deferred
+// by another suspect period because the member is in a maintenance window and
+// the suspect period has not been confirmed often enough yet.
+func (s *suspicion) deferFaulty(t *suspectTimer) bool {
+	address := t.suspect.address()
+	if !s.node.InMaintenance(address) || t.confirmations >= s.maintenanceConfirmations {
+		return false
+	}
+
+	s.logger.WithField("suspect", address).Info("member in maintenance window not declared faulty yet")
+	s.node.emit(MaintenanceDeferredEvent{
+		Address:       address,
+		Confirmations: t.confirmations,
+	})
+	return true
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/util"
+)
+
+func TestSetMaintenance(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	assert.Equal(t, ErrNodeNotReady, tnode.node.SetMaintenance(time.Now()))
+
+	bootstrapNodes(t, tnode)
+	tclock := tnode.node.clock.(*clock.Mock)
+	address := tnode.node.Address()
+
+	require.NoError(t, tnode.node.SetMaintenance(tclock.Now().Add(time.Hour)))
+	assert.True(t, tnode.node.InMaintenance(address))
+
+	tclock.Add(2 * time.Hour)
+	assert.False(t, tnode.node.InMaintenance(address), "expected maintenance window to end")
+
+	require.NoError(t, tnode.node.SetMaintenance(time.Time{}))
+	annotations, _ := tnode.node.Annotations(address)
+	assert.Empty(t, annotations, "expected maintenance annotation to be removed")
+
+	assert.False(t, tnode.node.InMaintenance("127.0.0.1:1"))
+}
+
+func TestMaintenanceDefersFaulty(t *testing.T) {
+	mockClock := clock.NewMock()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		SuspicionTimeout:         time.Minute,
+		MaintenanceTimeoutFactor: 2,
+		MaintenanceConfirmations: 1,
+		Clock:                    mockClock,
+	})
+	defer node.Destroy()
+
+	var deferred []MaintenanceDeferredEvent
+	node.RegisterListener(ListenerFunc(func(event events.Event) {
+		if event, ok := event.(MaintenanceDeferredEvent); ok {
+			deferred = append(deferred, event)
+		}
+	}))
+
+	incarnation := util.TimeNowMS()
+	node.memberlist.MakeAlive(node.Address(), incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3002", incarnation)
+	node.memberlist.Update([]Change{{
+		Address:     "127.0.0.1:3002",
+		Incarnation: incarnation + 1,
+		Status:      Alive,
+		Annotations: map[string]string{
+			MaintenanceAnnotation: mockClock.Now().Add(time.Hour).Format(time.RFC3339),
+		},
+	}})
+	member, _ := node.memberlist.Member("127.0.0.1:3002")
+	require.True(t, node.InMaintenance(member.Address))
+
+	node.suspicion.Start(Change{Address: member.Address, Incarnation: incarnation + 1})
+
+	mockClock.Add(time.Minute)
+	assert.NotEqual(t, Faulty, member.Status, "expected longer suspect period")
+
+	mockClock.Add(time.Minute)
+	assert.NotEqual(t, Faulty, member.Status, "expected faulty declaration to be deferred")
+	assert.Equal(t, []MaintenanceDeferredEvent{{Address: member.Address}}, deferred)
+
+	mockClock.Add(2 * time.Minute)
+	assert.Equal(t, Faulty, member.Status, "expected member to be faulty after confirmation")
+}
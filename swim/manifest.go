@@ -0,0 +1,109 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gl-works/ringpop-go/util"
+)
+
+// A Manifest is the full member set of a cluster as known by an external
+// control plane, such as an orchestrator. A node bootstrapped with a manifest
+// seeds its membership from it instead of joining other members.
+type Manifest struct {
+	Members []ManifestMember `json:"members"`
+}
+
+// A ManifestMember describes a member in a Manifest.
+type ManifestMember struct {
+	// Address is the identity of the member.
+	Address string `json:"address"`
+
+	// Addresses are the additional addresses the member advertises, see
+	// Options.AdvertiseAddresses.
+	Addresses []string `json:"addresses,omitempty"`
+
+	// Annotations are the annotations of the member, see Node.Annotate.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// validate checks that the manifest describes every member once.
+func (m *Manifest) validate() error {
+	if len(m.Members) == 0 {
+		return errors.New("manifest has no members")
+	}
+
+	seen := make(map[string]bool, len(m.Members))
+	for i, member := range m.Members {
+		if member.Address == "" {
+			return fmt.Errorf("manifest member %d has no address", i)
+		}
+		if seen[member.Address] {
+			return fmt.Errorf("manifest lists member %s more than once", member.Address)
+		}
+		seen[member.Address] = true
+
+		if err := validateAnnotations(member.Annotations); err != nil {
+			return fmt.Errorf("manifest member %s has invalid annotations: %v",
+				member.Address, err)
+		}
+	}
+
+	return nil
+}
+
+// importManifest adds the members of the manifest other than the local member
+// to the membership as alive, and returns their addresses. Imported members get
+// the lowest incarnation number, so that the state they gossip themselves
+// overrides the manifest. Like join lists, imported members are not
+// disseminated.
+func (n *Node) importManifest(manifest *Manifest) []string {
+	local := n.memberlist.local
+	timestamp := util.Timestamp(n.clock.Now())
+
+	var imported []string
+	var changes []Change
+	for _, member := range manifest.Members {
+		if member.Address == n.address {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Source:            local.Address,
+			SourceIncarnation: local.Incarnation,
+			Address:           member.Address,
+			Status:            Alive,
+			Addresses:         member.Addresses,
+			Annotations:       member.Annotations,
+			Timestamp:         timestamp,
+		})
+		imported = append(imported, member.Address)
+	}
+
+	n.memberlist.AddJoinList(changes)
+
+	n.logger.WithField("members", len(imported)).Info("imported membership manifest")
+	n.emit(ManifestImportedEvent{Members: len(imported)})
+
+	return imported
+}
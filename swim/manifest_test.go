@@ -0,0 +1,75 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootstrapFromManifest(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	manifest := &Manifest{Members: []ManifestMember{
+		{Address: tnode.node.Address()},
+		{Address: tpeer.node.Address(), Annotations: map[string]string{"zone": "a"}},
+	}}
+
+	imported, err := tnode.node.Bootstrap(&BootstrapOptions{Manifest: manifest, Stopped: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{tpeer.node.Address()}, imported)
+	assert.True(t, tnode.node.Ready())
+
+	member, ok := tnode.node.memberlist.Member(tpeer.node.Address())
+	require.True(t, ok, "expected peer to be imported without a join")
+	assert.Equal(t, Alive, member.Status)
+	assert.Equal(t, map[string]string{"zone": "a"}, member.Annotations)
+
+	_, err = tpeer.node.Bootstrap(&BootstrapOptions{Manifest: manifest, Stopped: true})
+	require.NoError(t, err)
+
+	_, err = sendPing(tpeer.node, tnode.node.Address(), time.Second)
+	require.NoError(t, err)
+
+	member, _ = tnode.node.memberlist.Member(tpeer.node.Address())
+	assert.Equal(t, tpeer.node.Incarnation(), member.Incarnation,
+		"expected state gossiped by the member to override the manifest")
+}
+
+func TestBootstrapFromInvalidManifest(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	_, err := tnode.node.Bootstrap(&BootstrapOptions{Manifest: &Manifest{}})
+	assert.Error(t, err, "expected empty manifest to be rejected")
+
+	_, err = tnode.node.Bootstrap(&BootstrapOptions{Manifest: &Manifest{Members: []ManifestMember{
+		{Address: "127.0.0.1:3001"},
+		{Address: "127.0.0.1:3001"},
+	}}})
+	assert.Error(t, err, "expected duplicate members to be rejected")
+	assert.False(t, tnode.node.Ready())
+}
@@ -39,14 +39,42 @@ const (
 
 	// Leave is the member "leave" state
 	Leave = "leave"
+
+	// Tombstone is the member "tombstone" state of a member that has been
+	// faulty for long enough to be removed from the memberlist, see
+	// Options.FaultyTTL.
+	Tombstone = "tombstone"
 )
 
+// Degraded is the health of a member that is shedding load. Healthy members
+// have an empty health.
+const Degraded = "degraded"
+
 // A Member is a member in the member list
 type Member struct {
 	sync.RWMutex
 	Address     string `json:"address"`
 	Status      string `json:"status"`
 	Incarnation int64  `json:"incarnationNumber"`
+	Health      string `json:"health,omitempty"`
+	Ramp        int    `json:"ramp,omitempty"`
+
+	// Addresses are additional addresses the member is reachable at, see
+	// AddressSelector.
+	Addresses []string `json:"addresses,omitempty"`
+
+	// Annotations are operational notes gossiped along with the member, see
+	// Node.Annotate.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels describe the capabilities of the member, see Node.Labels.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// JoinedAt is when the member joined the cluster, in milliseconds since
+	// the epoch, see Node.JoinedAt. It is not covered by the checksum, as the
+	// join times of members that do not gossip them are estimated by every
+	// member on its own.
+	JoinedAt int64 `json:"joinedAt,omitempty"`
 }
 
 // suspect interface
@@ -89,12 +117,15 @@ func (m *Member) nonLocalOverride(change Change) bool {
 	return statePrecedence(change.Status) > statePrecedence(m.Status)
 }
 
-// localOverride returns whether a change should be applied to to the member
+// localOverride returns whether a change should be applied to to the member.
+// A local member that left does not refute suspicions, as it does not want
+// to be alive again.
 func (m *Member) localOverride(local string, change Change) bool {
-	if m.Address != local {
+	if m.Address != local || m.Status == Leave {
 		return false
 	}
-	return change.Status == Faulty || change.Status == Suspect
+	return change.Status == Faulty || change.Status == Suspect ||
+		change.Status == Tombstone
 }
 
 func statePrecedence(s string) int {
@@ -107,6 +138,8 @@ func statePrecedence(s string) int {
 		return 2
 	case Leave:
 		return 3
+	case Tombstone:
+		return 4
 	default:
 		panic("invalid state")
 	}
@@ -118,11 +151,20 @@ func (m *Member) isReachable() bool {
 
 // A Change is a change a member to be applied
 type Change struct {
-	Source            string `json:"source"`
-	SourceIncarnation int64  `json:"sourceIncarnationNumber"`
-	Address           string `json:"address"`
-	Incarnation       int64  `json:"incarnationNumber"`
-	Status            string `json:"status"`
+	Source            string            `json:"source"`
+	SourceIncarnation int64             `json:"sourceIncarnationNumber"`
+	Address           string            `json:"address"`
+	Incarnation       int64             `json:"incarnationNumber"`
+	Status            string            `json:"status"`
+	Health            string            `json:"health,omitempty"`
+	Ramp              int               `json:"ramp,omitempty"`
+	Addresses         []string          `json:"addresses,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	JoinedAt          int64             `json:"joinedAt,omitempty"`
+	// Confirmers are the members other than Source known to suspect the
+	// member of a suspect change, see SuspicionConfirmations.
+	Confirmers []string `json:"confirmers,omitempty"`
 	// Use util.Timestamp for bi-direction binding to time encoded as
 	// integer Unix timestamp in JSON
 	Timestamp util.Timestamp `json:"timestamp"`
@@ -104,7 +104,8 @@ func (s *MemberTestSuite) TestLocalOverride() {
 		for _, s2 := range s.states {
 			m := newMember(s.localAddr, s1)
 			c := newChange(s.localAddr, s2)
-			expected := c.Status == Suspect || c.Status == Faulty
+			// a local member that left does not refute
+			expected := (c.Status == Suspect || c.Status == Faulty) && m.Status != Leave
 			got := m.localOverride(s.localAddr, c)
 			s.Equal(expected, got, "expected override when change.Status is suspect or faulty")
 
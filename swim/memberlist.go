@@ -23,8 +23,8 @@ package swim
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"math/rand"
+	"reflect"
 	"sort"
 	"sync"
 	"time"
@@ -39,10 +39,22 @@ type memberlist struct {
 	node  *Node
 	local *Member
 
+	// restoredLabels are the labels the local member is made alive with
+	// when the node bootstraps, see RestoreLabels.
+	restoredLabels map[string]string
+
 	members struct {
 		list      []*Member
 		byAddress map[string]*Member
 		checksum  uint32
+		version   ChecksumVersion
+
+		// preferred is the checksum version the node is configured with.
+		// While it differs from the version of the cluster, checksums holds
+		// the checksum of both versions, so that nodes that prefer the same
+		// version can compare it, see ChecksumMatches.
+		preferred ChecksumVersion
+		checksums map[ChecksumVersion]uint32
 		sync.RWMutex
 	}
 }
@@ -54,6 +66,8 @@ func newMemberlist(n *Node) *memberlist {
 	}
 
 	m.members.byAddress = make(map[string]*Member)
+	m.members.version = ChecksumV1
+	m.members.preferred = ChecksumV1
 
 	return m
 }
@@ -66,12 +80,79 @@ func (m *memberlist) Checksum() uint32 {
 	return checksum
 }
 
+// ChecksumVersion returns the version of the checksum the memberlist computes.
+func (m *memberlist) ChecksumVersion() ChecksumVersion {
+	m.members.RLock()
+	version := m.members.version
+	m.members.RUnlock()
+
+	return version
+}
+
+// SetChecksumVersion changes the version of the checksum the memberlist
+// computes and recomputes the checksum. It returns the previous version.
+func (m *memberlist) SetChecksumVersion(version ChecksumVersion) ChecksumVersion {
+	m.members.Lock()
+	old := m.members.version
+	m.members.version = version
+	m.members.Unlock()
+
+	if old != version {
+		m.ComputeChecksum()
+	}
+
+	return old
+}
+
+// Checksums returns the checksums of every version the memberlist computes by
+// their algorithm, or nil if it only computes the version of the cluster.
+func (m *memberlist) Checksums() map[string]uint32 {
+	m.members.RLock()
+	defer m.members.RUnlock()
+
+	if len(m.members.checksums) == 0 {
+		return nil
+	}
+	checksums := make(map[string]uint32, len(m.members.checksums))
+	for version, checksum := range m.members.checksums {
+		checksums[version.algorithm()] = checksum
+	}
+	return checksums
+}
+
+// ChecksumMatches returns whether the membership checksums of a remote node
+// match the checksums of the memberlist. The highest version both compute is
+// compared, and checksum, which is of the version of the cluster, if they
+// compute no version in common.
+func (m *memberlist) ChecksumMatches(checksum uint32, checksums map[string]uint32) bool {
+	m.members.RLock()
+	defer m.members.RUnlock()
+
+	var common ChecksumVersion
+	for version := range m.members.checksums {
+		if _, ok := checksums[version.algorithm()]; ok && version > common {
+			common = version
+		}
+	}
+	if common == 0 {
+		return m.members.checksum == checksum
+	}
+	return m.members.checksums[common] == checksums[common.algorithm()]
+}
+
 // computes membership checksum
 func (m *memberlist) ComputeChecksum() {
 	startTime := time.Now()
 	m.members.Lock()
 	checksum := farm.Fingerprint32([]byte(m.GenChecksumString()))
 	m.members.checksum = checksum
+	m.members.checksums = nil
+	if preferred := m.members.preferred; preferred != m.members.version {
+		m.members.checksums = map[ChecksumVersion]uint32{
+			m.members.version: checksum,
+			preferred:         farm.Fingerprint32([]byte(m.genChecksumString(preferred))),
+		}
+	}
 	m.members.Unlock()
 	m.node.emit(ChecksumComputeEvent{
 		Duration: time.Now().Sub(startTime),
@@ -81,11 +162,21 @@ func (m *memberlist) ComputeChecksum() {
 
 // generates string to use when computing checksum
 func (m *memberlist) GenChecksumString() string {
+	return m.genChecksumString(m.members.version)
+}
+
+// genChecksumString generates the string to compute the checksum of the
+// version over.
+func (m *memberlist) genChecksumString(version ChecksumVersion) string {
 	var strings sort.StringSlice
 
 	for _, member := range m.members.list {
-		s := fmt.Sprintf("%s%s%v", member.Address, member.Status, member.Incarnation)
-		strings = append(strings, s)
+		// tombstones are about to be removed, members that removed them
+		// already have the same checksum as members that did not yet
+		if member.Status == Tombstone {
+			continue
+		}
+		strings = append(strings, version.memberString(member))
 	}
 
 	strings.Sort()
@@ -127,10 +218,27 @@ func (m *memberlist) NumMembers() int {
 // returns whether or not a member is pingable
 func (m *memberlist) Pingable(member Member) bool {
 	return member.Address != m.local.Address &&
-		(member.Status == Alive || member.Status == Suspect)
+		(member.Status == Alive || member.Status == Suspect) &&
+		!m.node.Blacklisted(member.Address)
 
 }
 
+// pingableMember returns the member at the address and whether it is
+// pingable. The member is read under its lock instead of being copied, as it
+// may be changed concurrently.
+func (m *memberlist) pingableMember(address string) (*Member, bool) {
+	member, ok := m.Member(address)
+	if !ok {
+		return nil, false
+	}
+
+	member.RLock()
+	pingable := member.Address != m.local.Address && member.isReachable()
+	member.RUnlock()
+
+	return member, pingable && !m.node.Blacklisted(address)
+}
+
 // returns the number of pingable members in the memberlist
 func (m *memberlist) NumPingableMembers() (n int) {
 	m.members.Lock()
@@ -202,6 +310,35 @@ func (m *memberlist) MakeLeave(address string, incarnation int64) []Change {
 	return m.MakeChange(address, incarnation, Leave)
 }
 
+func (m *memberlist) MakeTombstone(address string, incarnation int64) []Change {
+	m.node.emit(MakeNodeStatusEvent{Tombstone})
+	return m.MakeChange(address, incarnation, Tombstone)
+}
+
+// RemoveTombstone removes the member at address from the member list if it is
+// a tombstone, and returns its incarnation number.
+func (m *memberlist) RemoveTombstone(address string) (int64, bool) {
+	m.members.Lock()
+	member, ok := m.members.byAddress[address]
+	if !ok || member.Status != Tombstone {
+		m.members.Unlock()
+		return 0, false
+	}
+
+	delete(m.members.byAddress, address)
+	for i, candidate := range m.members.list {
+		if candidate == member {
+			m.members.list = append(m.members.list[:i], m.members.list[i+1:]...)
+			break
+		}
+	}
+	m.members.Unlock()
+
+	m.ComputeChecksum()
+
+	return member.Incarnation, true
+}
+
 // makes a change to the member list
 func (m *memberlist) MakeChange(address string, incarnation int64, status string) []Change {
 	if m.local == nil {
@@ -209,6 +346,30 @@ func (m *memberlist) MakeChange(address string, incarnation int64, status string
 			Address:     m.node.Address(),
 			Incarnation: util.TimeNowMS(),
 			Status:      Alive,
+			Annotations: m.node.identityAnnotations(),
+			Labels:      m.restoredLabels,
+		}
+	}
+
+	var health string
+	var ramp int
+	var addresses []string
+	var annotations map[string]string
+	var labels map[string]string
+	var joinedAt int64
+	if address == m.local.Address {
+		// the health, ramp, annotations and labels of the local member are
+		// only changed by SetHealth, SetRamp, SetAnnotations and SetLabels
+		health = m.local.Health
+		ramp = m.local.Ramp
+		addresses = m.node.advertiseAddresses
+		annotations = m.local.Annotations
+		labels = m.local.Labels
+
+		// the local member joined when it first made itself alive
+		joinedAt = m.local.JoinedAt
+		if joinedAt == 0 {
+			joinedAt = nowInMillis(m.node.clock)
 		}
 	}
 
@@ -218,10 +379,135 @@ func (m *memberlist) MakeChange(address string, incarnation int64, status string
 		Address:           address,
 		Incarnation:       incarnation,
 		Status:            status,
+		Health:            health,
+		Ramp:              ramp,
+		Addresses:         addresses,
+		Annotations:       annotations,
+		Labels:            labels,
+		JoinedAt:          joinedAt,
 		Timestamp:         util.Timestamp(time.Now()),
 	}})
 }
 
+// SetHealth changes the health of the local member. The change is
+// disseminated with a new incarnation number, as an alive change with the
+// same incarnation number would not override the current state on other
+// members.
+func (m *memberlist) SetHealth(health string) []Change {
+	if m.local != nil && m.local.Health == health {
+		return nil
+	}
+
+	if m.local != nil {
+		m.local.Lock()
+		m.local.Health = health
+		m.local.Unlock()
+	}
+
+	return m.MakeAlive(m.node.address, m.nextIncarnation())
+}
+
+// SetRamp changes the ramp of the local member, the percentage of its replica
+// points it has while ramping in. Like SetHealth, the change is disseminated
+// with a new incarnation number.
+func (m *memberlist) SetRamp(ramp int) []Change {
+	if m.local != nil {
+		m.local.Lock()
+		unchanged := m.local.Ramp == ramp
+		m.local.Ramp = ramp
+		m.local.Unlock()
+
+		if unchanged {
+			return nil
+		}
+	}
+
+	return m.MakeAlive(m.node.address, m.nextIncarnation())
+}
+
+// SetAnnotations replaces the annotations of the local member. Like SetHealth,
+// the change is disseminated with a new incarnation number. The annotations
+// must not be modified afterwards.
+func (m *memberlist) SetAnnotations(annotations map[string]string) []Change {
+	if m.local != nil && reflect.DeepEqual(m.LocalAnnotations(), annotations) {
+		return nil
+	}
+
+	if m.local != nil {
+		m.local.Lock()
+		m.local.Annotations = annotations
+		m.local.Unlock()
+	}
+
+	return m.MakeAlive(m.node.address, m.nextIncarnation())
+}
+
+// LocalAnnotations returns the annotations of the local member, which must not
+// be modified.
+func (m *memberlist) LocalAnnotations() map[string]string {
+	if m.local == nil {
+		return nil
+	}
+
+	m.local.RLock()
+	annotations := m.local.Annotations
+	m.local.RUnlock()
+	return annotations
+}
+
+// SetLabels replaces the labels of the local member. Like SetAnnotations, the
+// change is disseminated with a new incarnation number.
+func (m *memberlist) SetLabels(labels map[string]string) []Change {
+	if m.local != nil && reflect.DeepEqual(m.LocalLabels(), labels) {
+		return nil
+	}
+
+	if m.local != nil {
+		m.local.Lock()
+		m.local.Labels = labels
+		m.local.Unlock()
+	}
+
+	return m.MakeAlive(m.node.address, m.nextIncarnation())
+}
+
+// RestoreLabels sets the labels of the local member, which it is made alive
+// with if it was not yet. Unlike SetLabels, the change is not disseminated.
+func (m *memberlist) RestoreLabels(labels map[string]string) {
+	if m.local == nil {
+		m.restoredLabels = labels
+		return
+	}
+
+	m.local.Lock()
+	m.local.Labels = labels
+	m.local.Unlock()
+}
+
+// LocalLabels returns the labels of the local member, which must not be
+// modified.
+func (m *memberlist) LocalLabels() map[string]string {
+	if m.local == nil {
+		return nil
+	}
+
+	m.local.RLock()
+	labels := m.local.Labels
+	m.local.RUnlock()
+	return labels
+}
+
+// nextIncarnation returns an incarnation number for the local member that is
+// higher than its current one, even if it was reincarnated in the same
+// millisecond.
+func (m *memberlist) nextIncarnation() int64 {
+	incarnation := nowInMillis(m.node.clock)
+	if m.local != nil && incarnation <= m.local.Incarnation {
+		incarnation = m.local.Incarnation + 1
+	}
+	return incarnation
+}
+
 // updates the member list with the slice of changes, applying selectively
 func (m *memberlist) Update(changes []Change) (applied []Change) {
 	if m.node.Stopped() || len(changes) == 0 {
@@ -230,37 +516,89 @@ func (m *memberlist) Update(changes []Change) (applied []Change) {
 
 	m.node.emit(MemberlistChangesReceivedEvent{changes})
 
+	var transitions []statusTransition
+	var confirmations []Change
+
+	// the ping hook is called before locking the members, as it may call
+	// back into the node
+	refutationVetoed := m.node.refutationVetoed(changes)
+
 	m.members.Lock()
 
 	for _, change := range changes {
+		// gossip that originates from a blacklisted member is dropped
+		if m.node.Blacklisted(change.Source) {
+			continue
+		}
+
 		member, ok := m.members.byAddress[change.Address]
 
-		// first time member has been seen, take change wholesale
+		// first time member has been seen, take change wholesale, unless
+		// it is a stale change about a member that was reaped
 		if !ok {
+			if change.Status == Tombstone || m.node.wasReaped(change) {
+				continue
+			}
+			change = m.backfillJoinTime(nil, change)
 			m.Apply(change)
 			applied = append(applied, change)
 			continue
 		}
 
-		// if change is local override, reassert member is alive
+		// if change is local override, reassert member is alive, unless
+		// the ping hook keeps the local member down
 		if member.localOverride(m.node.Address(), change) {
+			if refutationVetoed {
+				continue
+			}
 			m.node.emit(RefuteUpdateEvent{})
+			m.node.adjustLocalHealth(1, "suspicion refuted")
 			overrideChange := Change{
 				Source:            change.Source,
 				SourceIncarnation: change.SourceIncarnation,
 				Address:           change.Address,
 				Incarnation:       nowInMillis(m.node.clock),
 				Status:            Alive,
+				Health:            member.Health,
+				Ramp:              member.Ramp,
+				Addresses:         member.Addresses,
+				Annotations:       member.Annotations,
+				Labels:            member.Labels,
+				JoinedAt:          member.JoinedAt,
 				Timestamp:         util.Timestamp(time.Now()),
 			}
 
+			// the suspicion of the local member is refuted right away
+			transitions = append(transitions,
+				statusTransition{change.Address, Alive, Suspect},
+				statusTransition{change.Address, Suspect, Alive})
+
 			m.Apply(overrideChange)
 			applied = append(applied, overrideChange)
 			continue
 		}
 
+		// a suspicion of a member that is already suspect may confirm the
+		// suspicion
+		if change.Status == Suspect && member.Status == Suspect &&
+			change.Incarnation == member.Incarnation {
+			confirmations = append(confirmations, change)
+			continue
+		}
+
+		// changes of older processes claiming the address of a member
+		// claimed by several processes are ignored
+		if m.node.suppressRevival(member, change) {
+			continue
+		}
+
 		// if non-local override, apply change wholesale
 		if member.nonLocalOverride(change) {
+			if member.Status != change.Status {
+				transitions = append(transitions,
+					statusTransition{change.Address, member.Status, change.Status})
+			}
+			change = m.backfillJoinTime(member, change)
 			m.Apply(change)
 			applied = append(applied, change)
 		}
@@ -268,6 +606,14 @@ func (m *memberlist) Update(changes []Change) (applied []Change) {
 
 	m.members.Unlock()
 
+	if len(transitions) > 0 {
+		m.node.trackSuspicions(transitions)
+	}
+
+	for _, change := range confirmations {
+		m.node.confirmSuspicion(change)
+	}
+
 	if len(applied) > 0 {
 		oldChecksum := m.Checksum()
 		m.ComputeChecksum()
@@ -332,6 +678,12 @@ func (m *memberlist) Apply(change Change) {
 	member.Lock()
 	member.Status = change.Status
 	member.Incarnation = change.Incarnation
+	member.Health = change.Health
+	member.Ramp = change.Ramp
+	member.Addresses = change.Addresses
+	member.Annotations = change.Annotations
+	member.Labels = change.Labels
+	member.JoinedAt = change.JoinedAt
 	member.Unlock()
 }
 
@@ -124,6 +124,63 @@ func (s *MemberlistTestSuite) TestChecksumsEqual() {
 		"expected checksums to be equal")
 }
 
+func (s *MemberlistTestSuite) TestChecksumVersions() {
+	nodeA := NewNode("test", "127.0.0.1:3001", nil, nil)
+	defer nodeA.Destroy()
+	nodeB := NewNode("test", "127.0.0.1:3001", nil, nil)
+	defer nodeB.Destroy()
+
+	nodeA.memberlist.MakeAlive("127.0.0.1:3001", s.incarnation)
+	nodeB.memberlist.MakeAlive("127.0.0.1:3001", s.incarnation)
+	nodeA.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive,
+		Incarnation: s.incarnation}})
+	nodeB.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive,
+		Incarnation: s.incarnation, Health: Degraded}})
+
+	s.Equal(nodeA.memberlist.Checksum(), nodeB.memberlist.Checksum(),
+		"expected v1 checksum not to cover health")
+
+	s.Equal(ChecksumV1, nodeA.memberlist.SetChecksumVersion(ChecksumV2))
+	nodeB.memberlist.SetChecksumVersion(ChecksumV2)
+	s.NotEqual(nodeA.memberlist.Checksum(), nodeB.memberlist.Checksum(),
+		"expected v2 checksum to cover health")
+}
+
+// TestChecksumMatchesPreferredVersion tests that nodes that joined a cluster
+// with an older checksum version compare the version they are configured
+// with among each other, and the version of the cluster with other nodes.
+func (s *MemberlistTestSuite) TestChecksumMatchesPreferredVersion() {
+	nodeA := NewNode("test", "127.0.0.1:3001", nil, &Options{ChecksumVersion: ChecksumV2})
+	defer nodeA.Destroy()
+	nodeB := NewNode("test", "127.0.0.1:3001", nil, &Options{ChecksumVersion: ChecksumV2})
+	defer nodeB.Destroy()
+	nodeC := NewNode("test", "127.0.0.1:3001", nil, nil)
+	defer nodeC.Destroy()
+
+	for _, node := range []*Node{nodeA, nodeB, nodeC} {
+		// the cluster computes v1 checksums
+		node.memberlist.SetChecksumVersion(ChecksumV1)
+		node.memberlist.MakeAlive("127.0.0.1:3001", s.incarnation)
+	}
+	nodeA.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive,
+		Incarnation: s.incarnation}})
+	nodeB.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive,
+		Incarnation: s.incarnation, Health: Degraded}})
+	nodeC.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive,
+		Incarnation: s.incarnation, Health: Degraded}})
+
+	s.Equal(nodeA.memberlist.Checksum(), nodeB.memberlist.Checksum())
+	s.Len(nodeA.memberlist.Checksums(), 2, "expected both versions to be computed")
+	s.Nil(nodeC.memberlist.Checksums(), "expected only the version of the cluster to be computed")
+
+	s.False(nodeA.memberlist.ChecksumMatches(nodeB.memberlist.Checksum(), nodeB.memberlist.Checksums()),
+		"expected the preferred version to be compared")
+	s.True(nodeA.memberlist.ChecksumMatches(nodeC.memberlist.Checksum(), nodeC.memberlist.Checksums()),
+		"expected the version of the cluster to be compared")
+	s.True(nodeC.memberlist.ChecksumMatches(nodeA.memberlist.Checksum(), nodeA.memberlist.Checksums()),
+		"expected the version of the cluster to be compared")
+}
+
 func (s *MemberlistTestSuite) TestLocalLeaveOverrideHigher() {
 	s.Require().NotNil(s.m.local, "local member cannot be nil")
 
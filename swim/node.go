@@ -22,15 +22,19 @@ package swim
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/rcrowley/go-metrics"
 	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/events"
 	"github.com/gl-works/ringpop-go/logging"
 	"github.com/gl-works/ringpop-go/shared"
 	"github.com/gl-works/ringpop-go/util"
+	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/json"
 )
 
 var (
@@ -43,6 +47,18 @@ type Options struct {
 	SuspicionTimeout  time.Duration
 	MinProtocolPeriod time.Duration
 
+	// SuspicionTimeoutFunc, if set, computes the suspicion timeout from the
+	// number of reachable members each time a suspect period starts, instead
+	// of using the flat SuspicionTimeout, so that the timeout can grow with
+	// the cluster. See LogScaledSuspicionTimeout.
+	SuspicionTimeoutFunc SuspicionTimeoutFunc
+
+	// RestartSuspicionOnReenable makes the node start suspect periods that
+	// were suspended by stopping the node over with the full
+	// SuspicionTimeout when it is started again, instead of resuming them
+	// with their remaining time.
+	RestartSuspicionOnReenable bool
+
 	JoinTimeout, PingTimeout, PingRequestTimeout time.Duration
 
 	PingRequestSize int
@@ -50,6 +66,227 @@ type Options struct {
 	RollupFlushInterval time.Duration
 	RollupMaxUpdates    int
 
+	// RingFingerprint describes the configuration the application uses to
+	// place members on its hash ring (e.g. hash function and replica
+	// points). It is exchanged during join and a node refuses to join a
+	// cluster that advertises a different fingerprint. An empty fingerprint
+	// disables the check.
+	RingFingerprint string
+
+	// SuspectTTL bounds how long a member may remain suspect. A member that
+	// is suspect for longer is probed directly and declared faulty if it
+	// does not respond. The TTL is raised to SuspicionTimeout if it is
+	// smaller. A TTL of zero disables the check.
+	SuspectTTL time.Duration
+
+	// RampPeriod makes the node ramp in its ownership of the keyspace over
+	// the period after it bootstraps, instead of taking its full share at
+	// once. The ramp is gossiped to all members in RampSteps steps, see
+	// SetRamp. A period of zero disables ramping in.
+	RampPeriod time.Duration
+	RampSteps  int
+
+	// WatchdogPeriods enables a watchdog that fires when the protocol period
+	// loop has not run for that many protocol periods. The stall is logged,
+	// emitted as a ProtocolStalledEvent and passed to Watchdog if set. Zero
+	// disables the watchdog.
+	WatchdogPeriods int
+	Watchdog        WatchdogFunc
+
+	// AdvertiseAddresses are additional addresses the node is reachable at,
+	// such as an external IP or a hostname, in order of preference. They are
+	// gossiped along with the node's identity so that members on other
+	// networks can reach it, see AddressSelector.
+	AdvertiseAddresses []string
+
+	// AddressSelector selects which of the addresses a member advertises the
+	// node dials it at. Members are dialed at their identity when no selector
+	// is set.
+	AddressSelector AddressSelector
+
+	// Mesh routes all protocol calls through the sidecar of a service mesh,
+	// which takes precedence over the AddressSelector.
+	Mesh *shared.Mesh
+
+	// Features are application defined features the node advertises in
+	// handshakes in addition to the protocol features, see
+	// ApplicationFeature and PeerFeatures.
+	Features Features
+
+	// FalsePositiveThreshold is the fraction of suspicions refuted by the
+	// suspected member above which a FalsePositiveRateExceededEvent is
+	// emitted, see DetectorStats. Zero disables the event.
+	FalsePositiveThreshold float64
+
+	// ClockSkewThreshold is the estimated clock offset of a peer above which
+	// a ClockSkewExceededEvent is emitted, see ClockSkew. Zero disables the
+	// event.
+	ClockSkewThreshold time.Duration
+
+	// MaxBacklog bounds the number of changes the node disseminates at a
+	// time. During extreme churn, the oldest low priority changes beyond it
+	// are spilled to BacklogStore, and disseminated again when the backlog
+	// shrinks. Zero disables the bound. BacklogStore defaults to a
+	// MemoryBacklogStore.
+	MaxBacklog   int
+	BacklogStore BacklogStore
+
+	// FullSyncBudget bounds the size in bytes of the membership the node
+	// sends in a full sync to a node with a different membership checksum.
+	// A larger membership is sent in chunks ordered by the hash of the
+	// member addresses, one per protocol round or anti-entropy sync, each
+	// resuming where the previous one ended. Zero sends the full membership
+	// at once.
+	FullSyncBudget int
+
+	// DebugSampling logs the full protocol messages of a fraction of the
+	// protocol periods. It can be changed at runtime with SetDebugSampling
+	// or the /admin/debugSet and /admin/debugClear endpoints.
+	DebugSampling DebugSampling
+
+	// ChecksumVersion is the version of the membership checksum the node
+	// computes when it bootstraps a cluster. A node that joins an existing
+	// cluster adopts the version of the cluster, and also computes and
+	// gossips this version, which nodes that compute it as well compare
+	// instead. Defaults to ChecksumV1.
+	ChecksumVersion ChecksumVersion
+
+	// Arbiter, if set, is consulted before members matching ArbiterFilter,
+	// or all members if the filter is nil, are declared faulty. A vetoed
+	// member stays suspect and the arbiter is consulted again after another
+	// suspect period. See Arbiter.
+	Arbiter       Arbiter
+	ArbiterFilter ArbiterFilter
+
+	// MaintenanceTimeoutFactor multiplies the suspicion timeout of members
+	// in a maintenance window, and MaintenanceConfirmations is the number of
+	// additional suspect periods after which they are declared faulty. They
+	// default to 4 and 2. See SetMaintenance.
+	MaintenanceTimeoutFactor int
+	MaintenanceConfirmations int
+
+	// SuspicionConfirmations enables the suspicion timeout reduction of the
+	// Lifeguard extensions to SWIM. Suspect periods start at
+	// SuspicionMaxTimeoutFactor times the suspicion timeout, and shrink
+	// logarithmically to the suspicion timeout as other members
+	// independently suspect the member, reaching it after
+	// SuspicionConfirmations confirmations. The members known to suspect a
+	// member are disseminated along with the suspect change. The factor
+	// defaults to 6. Zero disables the reduction.
+	SuspicionConfirmations    int
+	SuspicionMaxTimeoutFactor int
+
+	// AdminAuthenticator, if set, authenticates the callers of the admin
+	// endpoints. Read-only endpoints require AdminRead, endpoints that
+	// change the state of the node or cluster require AdminWrite. All
+	// callers are authorized when it is nil.
+	AdminAuthenticator AdminAuthenticator
+
+	// AdminReply, if set, is called with the context of every successful
+	// reply of an admin endpoint, e.g. to attach response headers.
+	AdminReply func(ctx tchannel.ContextWithHeaders)
+
+	// PingHook is called on each ping the node receives and can veto the
+	// ack, see PingHook. It can be changed at runtime with SetPingHook.
+	PingHook PingHook
+
+	// Capture records all protocol messages sent and received by the node
+	// when set. See CaptureBuffer and CaptureWriter.
+	Capture Capturer
+
+	// FailureDetector probes the member selected in each protocol period.
+	// It defaults to a SWIMDetector using PingTimeout, PingRequestTimeout
+	// and PingRequestSize.
+	FailureDetector FailureDetector
+
+	// TargetSelector selects the member probed in each protocol period, see
+	// TargetSelector. It defaults to a round-robin over the members.
+	TargetSelector TargetSelector
+
+	// Transport carries the messages of the protocol to other members, see
+	// Transport. It defaults to TChannel calls on the channel of the node.
+	Transport Transport
+
+	// Keyring encrypts and authenticates the messages of the protocol with
+	// shared keys when set, see Keyring. All members must share a key.
+	Keyring *Keyring
+
+	// LocalHealthMax enables the local health multiplier of the Lifeguard
+	// extensions to SWIM with the given maximum score. A node that detects
+	// it is slow itself, through failed probes, refuted suspicions of the
+	// local member or delayed protocol periods, raises its score and
+	// multiplies its probe and suspicion timeouts by the score plus one,
+	// see LocalHealth. Zero disables the multiplier.
+	LocalHealthMax int
+
+	// DuplicateThreshold enables the detection of members whose address is
+	// claimed by two processes, such as an old and a new pod that overlap.
+	// The processes are told apart by their gossiped join times. A member
+	// that switches between join times DuplicateThreshold times within
+	// DuplicateWindow is reported with a DuplicateAddressEvent, and the
+	// changes of processes older than the newest one are ignored until they
+	// stopped for DuplicateCooldown, so that the member stops flapping.
+	// Changes that claim the local address with an incarnation the node did
+	// not issue are reported with a DuplicateIdentityEvent, at most once per
+	// cool-down. The window and cool-down default to 1 and 5 minutes. Zero
+	// disables the detection.
+	DuplicateThreshold int
+	DuplicateWindow    time.Duration
+	DuplicateCooldown  time.Duration
+
+	// Remediation is the policy of the steps the node takes when its
+	// membership checksum persistently mismatches the checksum of a peer.
+	// The zero policy only sends full syncs as usual.
+	Remediation RemediationPolicy
+
+	// FaultyTTL enables reaping faulty members. A member that is faulty for
+	// longer is turned into a tombstone, which is disseminated to all
+	// members, and removed from the memberlist after TombstoneTTL. Members
+	// are only turned into tombstones while all reachable members support
+	// them. TombstoneTTL defaults to 1 minute. Zero disables reaping.
+	FaultyTTL    time.Duration
+	TombstoneTTL time.Duration
+
+	// SyncInterval enables anti-entropy syncs of the membership. Every
+	// interval, plus a random jitter of up to SyncJitter, the node compares
+	// its membership checksum with a random member, and when they mismatch
+	// the nodes exchange their full memberships in both directions and
+	// merge them. Zero disables the syncs.
+	SyncInterval time.Duration
+	SyncJitter   time.Duration
+
+	// LabelStore, if set, persists the labels of the local member with the
+	// keys in PersistedLabels, or all labels if it is empty, whenever they
+	// change. The persisted labels are restored when the node bootstraps.
+	LabelStore      LabelStore
+	PersistedLabels []string
+
+	// PartitionHealPeriod enables partition healing. Every period, the node
+	// sends a join request to a random faulty member or discovered host that
+	// is not a reachable member. When its membership disagrees about which
+	// members are reachable, the node is partitioned from it, and the
+	// members of both partitions that the other one considers faulty are
+	// suspected so that they refute it and the partitions merge. Heals are
+	// attempted at most once per PartitionHealRateLimit, which defaults to
+	// 30 seconds. Zero disables partition healing.
+	PartitionHealPeriod    time.Duration
+	PartitionHealRateLimit time.Duration
+
+	// JoinStaggerWindow spreads the joins of nodes that bootstrap at the
+	// same time: the node delays its first join requests by a hash of its
+	// address, between 0 and the window. JoinAdmissionLimit limits the
+	// number of joins the node admits per JoinAdmissionWindow, which
+	// defaults to a second; the limit doubles after every window in which
+	// joins were throttled. Zero disables staggering and admission.
+	JoinStaggerWindow   time.Duration
+	JoinAdmissionLimit  int
+	JoinAdmissionWindow time.Duration
+
+	// Identity is the logical identity of the node, such as "node-17", which
+	// stays the same when the node is rescheduled to another address. It is
+	// gossiped as the IdentityAnnotation annotation of the local member.
+	Identity string
+
 	Clock clock.Clock
 }
 
@@ -67,6 +304,24 @@ func defaultOptions() *Options {
 		RollupFlushInterval: 5000 * time.Millisecond,
 		RollupMaxUpdates:    250,
 
+		RampSteps: 10,
+
+		MaintenanceTimeoutFactor: 4,
+		MaintenanceConfirmations: 2,
+
+		SuspicionMaxTimeoutFactor: 6,
+
+		DuplicateWindow:   time.Minute,
+		DuplicateCooldown: 5 * time.Minute,
+
+		TombstoneTTL: time.Minute,
+
+		PartitionHealRateLimit: 30 * time.Second,
+
+		JoinAdmissionWindow: time.Second,
+
+		ChecksumVersion: ChecksumV1,
+
 		Clock: clock.New(),
 	}
 
@@ -99,6 +354,34 @@ func mergeDefaultOptions(opts *Options) *Options {
 	opts.PingRequestSize = util.SelectInt(opts.PingRequestSize,
 		def.PingRequestSize)
 
+	opts.RampSteps = util.SelectInt(opts.RampSteps, def.RampSteps)
+
+	opts.SuspicionMaxTimeoutFactor = util.SelectInt(opts.SuspicionMaxTimeoutFactor,
+		def.SuspicionMaxTimeoutFactor)
+
+	opts.DuplicateWindow = util.SelectDuration(opts.DuplicateWindow, def.DuplicateWindow)
+	opts.DuplicateCooldown = util.SelectDuration(opts.DuplicateCooldown, def.DuplicateCooldown)
+
+	opts.TombstoneTTL = util.SelectDuration(opts.TombstoneTTL, def.TombstoneTTL)
+
+	opts.PartitionHealRateLimit = util.SelectDuration(opts.PartitionHealRateLimit,
+		def.PartitionHealRateLimit)
+	opts.JoinAdmissionWindow = util.SelectDuration(opts.JoinAdmissionWindow,
+		def.JoinAdmissionWindow)
+
+	opts.MaintenanceTimeoutFactor = util.SelectInt(opts.MaintenanceTimeoutFactor,
+		def.MaintenanceTimeoutFactor)
+	opts.MaintenanceConfirmations = util.SelectInt(opts.MaintenanceConfirmations,
+		def.MaintenanceConfirmations)
+
+	if !opts.ChecksumVersion.Valid() {
+		opts.ChecksumVersion = def.ChecksumVersion
+	}
+
+	if opts.SuspectTTL > 0 && opts.SuspectTTL < opts.SuspicionTimeout {
+		opts.SuspectTTL = opts.SuspicionTimeout
+	}
+
 	if opts.Clock == nil {
 		opts.Clock = def.Clock
 	}
@@ -117,6 +400,41 @@ type NodeInterface interface {
 	ProtocolStats() ProtocolStats
 	Ready() bool
 	RegisterListener(l EventListener)
+	SetPushback(retryAfter time.Duration, load float64)
+	Snapshot() *Snapshot
+	Restore(snapshot *Snapshot) error
+	SetDegraded(degraded bool) error
+	SetRamp(ramp int) error
+	Annotate(annotations map[string]string) error
+	Annotations(address string) (map[string]string, bool)
+	Labels() *Labels
+	MemberLabels(address string) (map[string]string, bool)
+	JoinedAt(address string) (time.Time, bool)
+	Leave() error
+	LeaveNotify(fraction float64) (int, error)
+	Blacklist(address string, ttl time.Duration) error
+	Unblacklist(address string) bool
+	Blacklisted(address string) bool
+	BlacklistedMembers() map[string]time.Time
+	BulkUpdate(updates []MemberUpdate) ([]Change, error)
+	SetRingFingerprint(fingerprint string)
+	DisableSubsystem(subsystem Subsystem) error
+	EnableSubsystem(subsystem Subsystem) error
+	Subsystems() map[Subsystem]bool
+	Rejoin() error
+	Left() bool
+	MemberUptime(address string) (time.Duration, bool)
+	SetMaintenance(until time.Time) error
+	InMaintenance(address string) bool
+	ReportTransportFailure(address string)
+	DialAddress(address string) string
+	PeerFeatures(address string) (Features, bool)
+	DetectorStats() DetectorStats
+	ClockSkew(address string) (ClockSkew, bool)
+	ClockSkews() map[string]ClockSkew
+	AuthorizeAdmin(ctx json.Context, endpoint string, required AdminRole) error
+	Checksum() uint32
+	HasChanges() bool
 }
 
 // A Node is a SWIM member
@@ -131,6 +449,8 @@ type Node struct {
 	}
 
 	channel      shared.SubChannel
+	transport    Transport
+	keyring      *Keyring
 	memberlist   *memberlist
 	memberiter   memberIter
 	disseminator *disseminator
@@ -142,7 +462,72 @@ type Node struct {
 
 	pingRequestSize int
 
-	listeners []EventListener
+	ringFingerprint struct {
+		value string
+		sync.RWMutex
+	}
+
+	advertiseAddresses []string
+	addressSelector    AddressSelector
+	mesh               *shared.Mesh
+
+	pushback pushbackState
+
+	transportFailures transportFailures
+
+	features featureState
+
+	detector detectorState
+
+	duplicates duplicateState
+
+	remediation remediationState
+
+	reaper reaperState
+
+	syncer syncer
+
+	labelPersistence labelPersistence
+	labelUpdates     sync.Mutex
+
+	healer partitionHealer
+
+	joinStagger time.Duration
+	admission   joinAdmission
+
+	identity string
+
+	subsystems subsystemState
+
+	blacklist blacklistState
+
+	skew skewState
+
+	debug debugState
+
+	partition partitionState
+
+	pingHook pingHookState
+
+	arbiter       Arbiter
+	arbiterFilter ArbiterFilter
+
+	adminAuth  AdminAuthenticator
+	adminReply func(ctx tchannel.ContextWithHeaders)
+
+	failureDetector FailureDetector
+
+	targets targetState
+
+	localHealth localHealth
+
+	capturer Capturer
+
+	suspects suspectTracker
+
+	ramp rampState
+
+	listeners events.ListenerGroup
 
 	clientRate metrics.Meter
 	serverRate metrics.Meter
@@ -163,10 +548,12 @@ func NewNode(app, address string, channel shared.SubChannel, opts *Options) *Nod
 	opts = mergeDefaultOptions(opts)
 
 	node := &Node{
-		address: address,
-		app:     app,
-		channel: channel,
-		logger:  logging.Logger("node").WithField("local", address),
+		address:   address,
+		app:       app,
+		channel:   channel,
+		transport: opts.Transport,
+		keyring:   opts.Keyring,
+		logger:    logging.Logger("node").WithField("local", address),
 
 		joinTimeout:        opts.JoinTimeout,
 		pingTimeout:        opts.PingTimeout,
@@ -174,17 +561,91 @@ func NewNode(app, address string, channel shared.SubChannel, opts *Options) *Nod
 
 		pingRequestSize: opts.PingRequestSize,
 
+		advertiseAddresses: opts.AdvertiseAddresses,
+		addressSelector:    opts.AddressSelector,
+		mesh:               opts.Mesh,
+
+		capturer: opts.Capture,
+
+		arbiter:       opts.Arbiter,
+		arbiterFilter: opts.ArbiterFilter,
+
+		adminAuth:  opts.AdminAuthenticator,
+		adminReply: opts.AdminReply,
+
+		failureDetector: opts.FailureDetector,
+
 		clientRate: metrics.NewMeter(),
 		serverRate: metrics.NewMeter(),
 		totalRate:  metrics.NewMeter(),
 		clock:      opts.Clock,
 	}
+	node.ringFingerprint.value = opts.RingFingerprint
+
+	if node.failureDetector == nil {
+		node.failureDetector = &SWIMDetector{
+			PingTimeout:        opts.PingTimeout,
+			PingRequestTimeout: opts.PingRequestTimeout,
+			PingRequestSize:    opts.PingRequestSize,
+		}
+	}
+
+	node.localHealth.max = opts.LocalHealthMax
+	node.suspects.ttl = opts.SuspectTTL
+	node.ramp.period = opts.RampPeriod
+	node.ramp.steps = opts.RampSteps
+	node.duplicates.threshold = opts.DuplicateThreshold
+	node.duplicates.window = opts.DuplicateWindow
+	node.duplicates.cooldown = opts.DuplicateCooldown
+	node.remediation.policy = opts.Remediation
+	node.reaper.faultyTTL = opts.FaultyTTL
+	node.reaper.tombstoneTTL = opts.TombstoneTTL
+	node.labelPersistence.store = opts.LabelStore
+	if len(opts.PersistedLabels) > 0 {
+		node.labelPersistence.keys = make(map[string]bool, len(opts.PersistedLabels))
+		for _, key := range opts.PersistedLabels {
+			node.labelPersistence.keys[key] = true
+		}
+	}
+	node.healer.period = opts.PartitionHealPeriod
+	node.healer.rateLimit = opts.PartitionHealRateLimit
+	node.joinStagger = opts.JoinStaggerWindow
+	node.admission.initial = opts.JoinAdmissionLimit
+	node.admission.window = opts.JoinAdmissionWindow
+	node.identity = opts.Identity
+	node.syncer = syncer{
+		node:     node,
+		interval: opts.SyncInterval,
+		jitter:   opts.SyncJitter,
+	}
+	node.features.local = protocolFeatures | opts.Features
+	node.detector.threshold = opts.FalsePositiveThreshold
+	node.skew.threshold = opts.ClockSkewThreshold
+	node.listeners.OnPanic = node.handleListenerPanic
+	node.debug.sampling = opts.DebugSampling
+	node.pingHook.hook = opts.PingHook
+	node.targets.selector = opts.TargetSelector
 
 	node.memberlist = newMemberlist(node)
+	node.memberlist.members.version = opts.ChecksumVersion
+	node.memberlist.members.preferred = opts.ChecksumVersion
 	node.memberiter = newMemberlistIter(node.memberlist)
 	node.suspicion = newSuspicion(node, opts.SuspicionTimeout)
+	node.suspicion.restartOnReenable = opts.RestartSuspicionOnReenable
+	node.suspicion.timeoutFunc = opts.SuspicionTimeoutFunc
+	node.suspicion.expectedConfirmations = opts.SuspicionConfirmations
+	node.suspicion.maxTimeoutFactor = opts.SuspicionMaxTimeoutFactor
+	node.suspicion.maintenanceFactor = opts.MaintenanceTimeoutFactor
+	node.suspicion.maintenanceConfirmations = opts.MaintenanceConfirmations
 	node.gossip = newGossip(node, opts.MinProtocolPeriod)
+	node.gossip.watchdog.periods = opts.WatchdogPeriods
+	node.gossip.watchdog.callback = opts.Watchdog
 	node.disseminator = newDisseminator(node)
+	node.disseminator.maxBacklog = opts.MaxBacklog
+	node.disseminator.fullSyncBudget = opts.FullSyncBudget
+	if opts.BacklogStore != nil {
+		node.disseminator.backlog = opts.BacklogStore
+	}
 	node.rollup = newUpdateRollup(node, opts.RollupFlushInterval,
 		opts.RollupMaxUpdates)
 
@@ -192,6 +653,9 @@ func NewNode(app, address string, channel shared.SubChannel, opts *Options) *Nod
 		node.registerHandlers()
 		node.service = node.channel.ServiceName()
 	}
+	if node.transport == nil && node.channel != nil {
+		node.transport = &channelTransport{node}
+	}
 
 	return node
 }
@@ -206,6 +670,12 @@ func (n *Node) App() string {
 	return n.app
 }
 
+// Checksum returns the checksum of the membership of the Node, which is the
+// same on all nodes once the membership has converged.
+func (n *Node) Checksum() uint32 {
+	return n.memberlist.Checksum()
+}
+
 // HasChanges reports whether Node has changes to disseminate.
 func (n *Node) HasChanges() bool {
 	return n.disseminator.HasChanges()
@@ -223,23 +693,34 @@ func (n *Node) Incarnation() int64 {
 }
 
 func (n *Node) emit(event interface{}) {
-	for _, listener := range n.listeners {
-		listener.HandleEvent(event)
-	}
+	n.listeners.Emit(event)
 }
 
 // RegisterListener adds an EventListener to the node. When a swim event e is
 // emitted, l.HandleEvent(e) is called for every registered listener l.
 // Attention, all listeners are called synchronously. Be careful with
-// registering blocking and other slow calls.
+// registering blocking and other slow calls. A panic in a listener is
+// recovered and logged, so that it does not take down the protocol.
 func (n *Node) RegisterListener(l EventListener) {
-	n.listeners = append(n.listeners, l)
+	n.listeners.Register(events.ListenerName(l), l)
+}
+
+// handleListenerPanic logs a panic of a listener that was recovered.
+func (n *Node) handleListenerPanic(p events.ListenerPanic) {
+	n.logger.WithFields(log.Fields{
+		"listener": p.Listener,
+		"event":    fmt.Sprintf("%T", p.Event),
+		"panic":    p.Value,
+		"panics":   p.Panics,
+	}).Error("event listener panicked")
 }
 
 // Start starts the SWIM protocol and all sub-protocols.
 func (n *Node) Start() {
 	n.gossip.Start()
-	n.suspicion.Reenable()
+	if n.SubsystemEnabled(SubsystemSuspicion) {
+		n.suspicion.Reenable()
+	}
 
 	n.state.Lock()
 	n.state.stopped = false
@@ -303,7 +784,9 @@ func (n *Node) Ready() bool {
 type BootstrapOptions struct {
 	// The DiscoverProvider resolves a list of bootstrap hosts. If this is
 	// specified, it takes priority over the legacy Hosts and File options
-	// below.
+	// below. It is also used by the partition healer to discover hosts that
+	// joined after bootstrap; the discovery package provides DNS, file and
+	// static providers.
 	DiscoverProvider DiscoverProvider
 
 	// Slice of hosts to bootstrap with, prioritized over provided file.
@@ -324,6 +807,14 @@ type BootstrapOptions struct {
 	// Minimum number of nodes to join to satisfy a bootstrap.
 	JoinSize int
 
+	// JoinQuorum is the fraction, between 0 and 1, of the discovered hosts
+	// other than the node itself that must be joined to satisfy a bootstrap,
+	// if that is more than JoinSize. Bootstrap fails, and the node does not
+	// become ready, unless the quorum is joined within MaxJoinDuration and
+	// JoinMaxAttempts, which keeps a node from serving requests on a ring
+	// that is only partially formed.
+	JoinQuorum float64
+
 	// Maximum time to attempt joins before the entire bootstrap process times
 	// out.
 	MaxJoinDuration time.Duration
@@ -333,12 +824,44 @@ type BootstrapOptions struct {
 	// `JoinSize` (the number of nodes that will be contacted at a time is
 	// `ParallelismFactor * JoinSize`).
 	ParallelismFactor int
+
+	// JoinRetryInitialDelay and JoinRetryMaxDelay configure the backoff
+	// between join attempts when not enough nodes could be joined. The delay
+	// starts at JoinRetryInitialDelay and doubles with every attempt, with
+	// jitter, up to JoinRetryMaxDelay. They default to 100ms and 60s.
+	JoinRetryInitialDelay time.Duration
+	JoinRetryMaxDelay     time.Duration
+
+	// JoinRetryJitter is the fraction, between 0 and 1, of each backoff
+	// delay that is random. Without it, the delay stops being random once it
+	// reaches JoinRetryMaxDelay, so nodes that cold start together keep
+	// retrying the seeds together.
+	JoinRetryJitter float64
+
+	// JoinMaxAttempts is the number of attempts to join enough nodes before
+	// the bootstrap fails, each attempt contacting up to ParallelismFactor *
+	// JoinSize nodes. There is no limit other than MaxJoinDuration if zero.
+	JoinMaxAttempts int
+
+	// JoinClock is the clock the join backoff sleeps on and MaxJoinDuration
+	// is measured with, so that bootstrapping against flaky seeds can be
+	// tested deterministically. It defaults to the system clock, independent
+	// of the clock of the node.
+	JoinClock clock.Clock
+
+	// Manifest, if set, seeds the membership with the members it lists
+	// instead of joining other members, for environments where an external
+	// control plane already knows the full member set. The discovery and
+	// join options are ignored. The members are considered alive until
+	// gossip shows otherwise.
+	Manifest *Manifest
 }
 
 // Bootstrap joins a node to a cluster. The channel provided to the node must be
-// listening for the bootstrap to complete.
+// listening for the bootstrap to complete, unless the node has another
+// Transport. A failed join returns a *JoinError.
 func (n *Node) Bootstrap(opts *BootstrapOptions) ([]string, error) {
-	if n.channel == nil {
+	if n.transport == nil {
 		return nil, errors.New("channel required")
 	}
 
@@ -346,6 +869,15 @@ func (n *Node) Bootstrap(opts *BootstrapOptions) ([]string, error) {
 		opts = &BootstrapOptions{}
 	}
 
+	if err := n.restoreLabels(); err != nil {
+		n.logger.WithField("error", err).Error("unable to restore persisted labels")
+		return nil, err
+	}
+
+	if opts.Manifest != nil {
+		return n.bootstrapFromManifest(opts)
+	}
+
 	// This exists to resolve the bootstrap hosts provider implementation from
 	// the deprecated "File" and "Hosts" options in BootstrapOptions.
 	discoverProvider, err := resolveDiscoverProvider(opts)
@@ -354,13 +886,38 @@ func (n *Node) Bootstrap(opts *BootstrapOptions) ([]string, error) {
 	}
 
 	n.memberlist.Reincarnate()
+	n.startRamp()
+
+	n.healer.Lock()
+	n.healer.discoverProvider = discoverProvider
+	n.healer.Unlock()
+
+	joinClock := opts.JoinClock
+	if joinClock == nil {
+		joinClock = clock.New()
+	}
 
 	joinOpts := &joinOpts{
 		timeout:           opts.JoinTimeout,
 		size:              opts.JoinSize,
 		maxJoinDuration:   opts.MaxJoinDuration,
 		parallelismFactor: opts.ParallelismFactor,
+		maxAttempts:       opts.JoinMaxAttempts,
+		quorum:            opts.JoinQuorum,
 		discoverProvider:  discoverProvider,
+		delayOpts: &delayOpts{
+			initial: util.SelectDuration(opts.JoinRetryInitialDelay, defaultInitial),
+			max:     util.SelectDuration(opts.JoinRetryMaxDelay, defaultMax),
+			jitter:  opts.JoinRetryJitter,
+			sleeper: joinClock.Sleep,
+		},
+		clock: joinClock,
+	}
+
+	if delay := joinStaggerDelay(n.address, n.joinStagger); delay > 0 {
+		n.logger.WithField("delay", delay).Info("staggering join")
+		n.emit(JoinStaggeredEvent{Delay: delay})
+		joinClock.Sleep(delay)
 	}
 
 	joined, err := sendJoin(n, joinOpts)
@@ -371,6 +928,31 @@ func (n *Node) Bootstrap(opts *BootstrapOptions) ([]string, error) {
 		return nil, err
 	}
 
+	n.completeBootstrap(opts)
+
+	return joined, nil
+}
+
+// bootstrapFromManifest bootstraps the node with the membership of the
+// manifest, see BootstrapOptions.Manifest.
+func (n *Node) bootstrapFromManifest(opts *BootstrapOptions) ([]string, error) {
+	if err := opts.Manifest.validate(); err != nil {
+		return nil, err
+	}
+
+	n.memberlist.Reincarnate()
+	n.startRamp()
+
+	imported := n.importManifest(opts.Manifest)
+
+	n.completeBootstrap(opts)
+
+	return imported, nil
+}
+
+// completeBootstrap starts gossip unless requested otherwise and marks the node
+// ready.
+func (n *Node) completeBootstrap(opts *BootstrapOptions) {
 	if !opts.Stopped {
 		n.gossip.Start()
 	}
@@ -380,8 +962,6 @@ func (n *Node) Bootstrap(opts *BootstrapOptions) ([]string, error) {
 	n.state.Unlock()
 
 	n.startTime = time.Now()
-
-	return joined, nil
 }
 
 //= = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = =
@@ -397,19 +977,32 @@ func (n *Node) handleChanges(changes []Change) {
 		switch change.Status {
 		case Alive:
 			n.suspicion.Stop(change)
+			n.untrackSuspect(change.Address)
 			n.disseminator.AdjustMaxPropagations()
 
 		case Faulty:
 			n.suspicion.Stop(change)
+			n.untrackSuspect(change.Address)
+			n.disseminator.clearFullSyncCursor(change.Address)
 
 		case Suspect:
 			n.suspicion.Start(change)
+			n.trackSuspect(change.Address)
 			n.disseminator.AdjustMaxPropagations()
 
 		case Leave:
 			n.suspicion.Stop(change)
+			n.untrackSuspect(change.Address)
+			n.disseminator.clearFullSyncCursor(change.Address)
 			n.disseminator.AdjustMaxPropagations()
+
+		case Tombstone:
+			n.suspicion.Stop(change)
+			n.untrackSuspect(change.Address)
+			n.disseminator.clearFullSyncCursor(change.Address)
 		}
+
+		n.trackReapable(change)
 	}
 }
 
@@ -435,7 +1028,12 @@ func (n *Node) setPinging(pinging bool) {
 
 // pingNextMember pings the next member in the memberlist
 func (n *Node) pingNextMember() {
-	member, ok := n.memberiter.Next()
+	// members that others failed to connect to are probed before the regular
+	// round-robin continues, even if they signaled pushback
+	member, ok := n.nextTransportFailure()
+	if !ok {
+		member, ok = n.nextProbeTarget()
+	}
 	if !ok {
 		n.logger.Debug("no pingable members")
 		return
@@ -449,34 +1047,21 @@ func (n *Node) pingNextMember() {
 	n.setPinging(true)
 	defer n.setPinging(false)
 
-	// send ping
-	res, err := sendPing(n, member.Address, n.pingTimeout)
-	if err == nil {
-		n.memberlist.Update(res.Changes)
-		return
+	verdict := n.failureDetector.Probe(nodeProber{n}, member.Address)
+	n.recordProbe(verdict)
+	if verdict == Reachable {
+		n.recordExchange(member.Address)
+	} else {
+		n.recordMissedAck(member.Address)
 	}
 
-	// ping failed, send ping requests
-	target := member.Address
-	targetReached, errs := indirectPing(n, target, n.pingRequestSize, n.pingRequestTimeout)
-
-	// if all helper nodes are unreachable, the indirectPing is inconclusive
-	if len(errs) == n.pingRequestSize {
-		n.logger.WithFields(log.Fields{
-			"target":    target,
-			"errors":    errs,
-			"numErrors": len(errs),
-		}).Warn("ping request inconclusive due to errors")
-		return
-	}
-
-	if !targetReached {
-		n.logger.WithField("target", target).Info("ping request target unreachable")
+	switch verdict {
+	case Unreachable:
+		n.logger.WithField("target", member.Address).Info("probe target unreachable")
 		n.memberlist.MakeSuspect(member.Address, member.Incarnation)
-		return
+	case Inconclusive:
+		n.logger.WithField("target", member.Address).Debug("probe inconclusive")
 	}
-
-	n.logger.WithField("target", target).Info("ping request target reachable")
 }
 
 // GetReachableMembers returns a slice of members currently in this node's
@@ -27,7 +27,7 @@ import (
 )
 
 // A DiscoverProvider is a interface that provides a list of peers for a node
-// to bootstrap from.
+// to bootstrap from. Any discovery.Provider is a DiscoverProvider.
 type DiscoverProvider interface {
 	Hosts() ([]string, error)
 }
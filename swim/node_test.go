@@ -22,8 +22,11 @@ package swim
 
 import (
 	"testing"
+	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/stretchr/testify/suite"
+	"github.com/gl-works/ringpop-go/events"
 )
 
 type NodeTestSuite struct {
@@ -80,6 +83,38 @@ func (s *NodeTestSuite) TestStoppedBootstrapOption() {
 	//s.False(s.testNode.node.suspicion.enabled, "suspicion should not be enabled")
 }
 
+func (s *NodeTestSuite) TestPushbackBackoff() {
+	node := s.testNode.node
+	mockClock := node.clock.(*clock.Mock)
+
+	node.recordPushback("127.0.0.1:3002", &Pushback{RetryAfter: 1000, Load: 0.5})
+	node.recordPushback("127.0.0.1:3003", &Pushback{RetryAfter: 0})
+
+	s.True(node.backingOff("127.0.0.1:3002"), "expected node to back off from member")
+	s.False(node.backingOff("127.0.0.1:3003"), "expected zero retry after to be ignored")
+	s.Equal(map[string]bool{"127.0.0.1:3002": true}, node.backedOffMembers())
+
+	mockClock.Add(time.Second)
+
+	s.False(node.backingOff("127.0.0.1:3002"), "expected backoff to expire")
+	s.Empty(node.backedOffMembers(), "expected no members to be backed off from")
+}
+
+func (s *NodeTestSuite) TestListenerPanicRecovered() {
+	var handled int
+	s.testNode.node.RegisterListener(ListenerFunc(func(events.Event) {
+		panic("listener bug")
+	}))
+	s.testNode.node.RegisterListener(ListenerFunc(func(events.Event) {
+		handled++
+	}))
+
+	s.NotPanics(func() {
+		s.testNode.node.emit(PingSendEvent{})
+	})
+	s.Equal(1, handled, "expected listener after the panicking one to be called")
+}
+
 func TestNodeTestSuite(t *testing.T) {
 	suite.Run(t, new(NodeTestSuite))
 }
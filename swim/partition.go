@@ -0,0 +1,148 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	log "github.com/uber-common/bark"
+)
+
+// MaxSimulatedPartition is the longest a simulated partition can last, so
+// that a node cannot be cut off from its peers indefinitely by mistake.
+const MaxSimulatedPartition = time.Hour
+
+// ErrSimulatedPartition is returned for protocol messages from or to a peer
+// that is cut off by a simulated partition.
+var ErrSimulatedPartition = errors.New("peer is cut off by a simulated partition")
+
+// partitionState contains the peers the node is cut off from by a simulated
+// partition and the timer that ends the partition. The generation counts the
+// partitions, so that the timer of a replaced partition does not end its
+// successor.
+type partitionState struct {
+	peers      map[string]struct{}
+	timer      *clock.Timer
+	generation uint64
+	sync.RWMutex
+}
+
+// SimulatePartition cuts the node off from the given peers for the given
+// duration, to rehearse partitions in staging environments without touching
+// firewall rules. The node drops all protocol messages from the peers and
+// fails all protocol messages to them, so the peers and the node each suspect
+// the other. A partition that is already simulated is replaced. The duration
+// must be positive and at most MaxSimulatedPartition.
+func (n *Node) SimulatePartition(peers []string, duration time.Duration) error {
+	if duration <= 0 || duration > MaxSimulatedPartition {
+		return fmt.Errorf("simulated partition must last between 0 and %v, got %v",
+			MaxSimulatedPartition, duration)
+	}
+	if len(peers) == 0 {
+		return errors.New("simulated partition needs at least one peer")
+	}
+
+	n.partition.Lock()
+	if n.partition.timer != nil {
+		n.partition.timer.Stop()
+	}
+	n.partition.peers = make(map[string]struct{}, len(peers))
+	for _, peer := range peers {
+		n.partition.peers[peer] = struct{}{}
+	}
+	n.partition.generation++
+	generation := n.partition.generation
+	n.partition.timer = n.clock.AfterFunc(duration, func() {
+		n.endPartition(generation)
+	})
+	n.partition.Unlock()
+
+	n.emit(PartitionStartedEvent{
+		Peers:    peers,
+		Duration: duration,
+	})
+
+	n.logger.WithFields(log.Fields{
+		"peers":    peers,
+		"duration": duration,
+	}).Warn("simulated partition started")
+	return nil
+}
+
+// EndPartition ends the simulated partition before its duration passed.
+// Returns whether a partition was simulated.
+func (n *Node) EndPartition() bool {
+	return n.endPartition(0)
+}
+
+// endPartition ends the simulated partition. When generation is not zero, the
+// partition is only ended if it is still that generation.
+func (n *Node) endPartition(generation uint64) bool {
+	n.partition.Lock()
+	if n.partition.peers == nil || (generation != 0 && generation != n.partition.generation) {
+		n.partition.Unlock()
+		return false
+	}
+	if n.partition.timer != nil {
+		n.partition.timer.Stop()
+	}
+	n.partition.peers = nil
+	n.partition.timer = nil
+	n.partition.Unlock()
+
+	n.emit(PartitionEndedEvent{})
+
+	n.logger.Warn("simulated partition ended")
+	return true
+}
+
+// PartitionedPeers returns the peers the node is cut off from by a simulated
+// partition.
+func (n *Node) PartitionedPeers() []string {
+	n.partition.RLock()
+	peers := make([]string, 0, len(n.partition.peers))
+	for peer := range n.partition.peers {
+		peers = append(peers, peer)
+	}
+	n.partition.RUnlock()
+	return peers
+}
+
+// checkPeer returns ErrSimulatedPartition if the node is cut off from the
+// peer by a simulated partition, and ErrBlacklisted if the peer is
+// blacklisted.
+func (n *Node) checkPeer(peer string) error {
+	n.partition.RLock()
+	_, partitioned := n.partition.peers[peer]
+	n.partition.RUnlock()
+
+	if partitioned {
+		return ErrSimulatedPartition
+	}
+	if n.Blacklisted(peer) {
+		return ErrBlacklisted
+	}
+	return nil
+}
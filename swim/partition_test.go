@@ -0,0 +1,81 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulatePartition(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+	bootstrapNodes(t, tnode, tpeer)
+
+	peer := tpeer.node.Address()
+	require.NoError(t, tnode.node.SimulatePartition([]string{peer}, time.Minute))
+	assert.Equal(t, []string{peer}, tnode.node.PartitionedPeers())
+
+	_, err := sendPing(tnode.node, peer, time.Second)
+	assert.Equal(t, ErrSimulatedPartition, err, "expected pings to the peer to fail")
+
+	_, err = sendPing(tpeer.node, tnode.node.Address(), time.Second)
+	assert.Error(t, err, "expected pings from the peer to be dropped")
+
+	tnode.node.clock.(*clock.Mock).Add(time.Minute)
+	assert.Empty(t, tnode.node.PartitionedPeers(), "expected partition to end after its duration")
+
+	_, err = sendPing(tnode.node, peer, time.Second)
+	assert.NoError(t, err)
+	_, err = sendPing(tpeer.node, tnode.node.Address(), time.Second)
+	assert.NoError(t, err)
+}
+
+func TestSimulatePartitionReplaced(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{Clock: clock.NewMock()})
+	mockClock := node.clock.(*clock.Mock)
+
+	require.NoError(t, node.SimulatePartition([]string{"127.0.0.1:3002"}, time.Minute))
+	mockClock.Add(30 * time.Second)
+	require.NoError(t, node.SimulatePartition([]string{"127.0.0.1:3003"}, time.Minute))
+
+	mockClock.Add(30 * time.Second)
+	assert.Equal(t, []string{"127.0.0.1:3003"}, node.PartitionedPeers(),
+		"expected timer of the replaced partition not to end its successor")
+
+	assert.True(t, node.EndPartition())
+	assert.False(t, node.EndPartition())
+	assert.NoError(t, node.checkPeer("127.0.0.1:3003"))
+}
+
+func TestSimulatePartitionInvalid(t *testing.T) {
+	node := NewNode("test", "127.0.0.1:3001", nil, nil)
+
+	assert.Error(t, node.SimulatePartition([]string{"127.0.0.1:3002"}, 0))
+	assert.Error(t, node.SimulatePartition([]string{"127.0.0.1:3002"}, 2*MaxSimulatedPartition))
+	assert.Error(t, node.SimulatePartition(nil, time.Minute))
+	assert.Empty(t, node.PartitionedPeers())
+}
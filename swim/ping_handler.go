@@ -35,10 +35,25 @@ func handlePing(node *Node, req *ping) (*ping, error) {
 	node.serverRate.Mark(1)
 	node.totalRate.Mark(1)
 
-	node.memberlist.Update(req.Changes)
+	if err := node.checkPingHook(req); err != nil {
+		return nil, err
+	}
+
+	node.recordPeerFeatures(req.Source, req.Features)
+	node.recordExchange(req.Source)
+	if !node.Quarantined(req.Source) {
+		node.memberlist.Update(req.Changes)
+	}
 
-	changes, fullSync :=
-		node.disseminator.IssueAsReceiver(req.Source, req.SourceIncarnation, req.Checksum)
+	var changes []Change
+	var fullSync bool
+	if req.FullSyncRequested {
+		// a requested full sync is not a checksum mismatch to remediate
+		changes, fullSync = node.disseminator.fullSyncTo(req.Source), true
+	} else {
+		changes, fullSync =
+			node.disseminator.IssueAsReceiver(req.Source, req.SourceIncarnation, req.Checksum, req.Checksums)
+	}
 
 	if fullSync {
 		// TODO: handle full sync
@@ -46,9 +61,13 @@ func handlePing(node *Node, req *ping) (*ping, error) {
 
 	res := &ping{
 		Checksum:          node.memberlist.Checksum(),
+		Checksums:         node.memberlist.Checksums(),
 		Changes:           changes,
 		Source:            node.Address(),
 		SourceIncarnation: node.Incarnation(),
+		Pushback:          node.localPushback(),
+		Features:          node.LocalFeatures(),
+		Timestamp:         node.clock.Now().UnixNano(),
 	}
 
 	return res, nil
@@ -0,0 +1,132 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/uber-common/bark"
+)
+
+// PingInfo describes a ping the node received.
+type PingInfo struct {
+	// Source is the address of the member that sent the ping.
+	Source string
+
+	// SourceIncarnation is the incarnation number of the sender.
+	SourceIncarnation int64
+
+	// Changes is the number of membership changes piggybacked on the ping
+	// for each status.
+	Changes map[string]int
+}
+
+// A PingHook is called by the node on each ping it receives, before the ping
+// is processed. A non-nil error vetoes the ack: the ping is answered with the
+// error, so the sender treats the node as unresponsive and eventually suspects
+// it. This allows the application to signal that the node should be treated
+// as unhealthy, for example while the process is in a bad state. A hook that
+// panics vetoes the ack as well. While the hook vetoes, the node does not
+// refute suspicions of itself either, so it stays down once the cluster
+// declared it faulty; the hook is consulted with the source of the suspicion.
+type PingHook func(info PingInfo) error
+
+// pingHookState contains the ping hook of the node.
+type pingHookState struct {
+	hook PingHook
+	sync.RWMutex
+}
+
+// SetPingHook sets the hook called on each ping the node receives, see
+// PingHook. A nil hook removes it.
+func (n *Node) SetPingHook(hook PingHook) {
+	n.pingHook.Lock()
+	n.pingHook.hook = hook
+	n.pingHook.Unlock()
+}
+
+// checkPingHook calls the ping hook for the ping and returns its veto.
+func (n *Node) checkPingHook(req *ping) error {
+	err := n.callPingHook(req.Source, req.SourceIncarnation, req.Changes)
+	if err != nil {
+		n.logger.WithFields(log.Fields{
+			"source": req.Source,
+			"error":  err,
+		}).Debug("ping vetoed by hook")
+		n.emit(PingVetoedEvent{
+			Source: req.Source,
+			Reason: err.Error(),
+		})
+	}
+	return err
+}
+
+// refutationVetoed returns whether the local member must not refute the
+// suspicions of it among the changes, because the ping hook vetoes acks. The
+// hook is called for the source of the first such suspicion.
+func (n *Node) refutationVetoed(changes []Change) bool {
+	for _, change := range changes {
+		if change.Address != n.address || change.Status == Alive {
+			continue
+		}
+
+		err := n.callPingHook(change.Source, change.SourceIncarnation, changes)
+		if err == nil {
+			return false
+		}
+		n.logger.WithFields(log.Fields{
+			"source": change.Source,
+			"error":  err,
+		}).Debug("refutation vetoed by ping hook")
+		return true
+	}
+	return false
+}
+
+// callPingHook calls the ping hook with the source and changes of a message
+// and returns its veto, recovering a panic of the hook as a veto.
+func (n *Node) callPingHook(source string, incarnation int64, changes []Change) (err error) {
+	n.pingHook.RLock()
+	hook := n.pingHook.hook
+	n.pingHook.RUnlock()
+
+	if hook == nil {
+		return nil
+	}
+
+	info := PingInfo{
+		Source:            source,
+		SourceIncarnation: incarnation,
+		Changes:           make(map[string]int),
+	}
+	for _, change := range changes {
+		info.Changes[change.Status]++
+	}
+
+	defer func() {
+		if value := recover(); value != nil {
+			err = fmt.Errorf("ping hook panicked: %v", value)
+		}
+	}()
+
+	return hook(info)
+}
@@ -24,9 +24,10 @@ import "time"
 
 // A PingResponse is the response from a successful ping request call
 type pingResponse struct {
-	Ok      bool     `json:"pingStatus"`
-	Target  string   `json:"target"`
-	Changes []Change `json:"changes"`
+	Ok       bool      `json:"pingStatus"`
+	Target   string    `json:"target"`
+	Changes  []Change  `json:"changes"`
+	Pushback *Pushback `json:"pushback,omitempty"`
 }
 
 func handlePingRequest(node *Node, req *pingRequest) (*pingResponse, error) {
@@ -45,7 +46,9 @@ func handlePingRequest(node *Node, req *pingRequest) (*pingResponse, error) {
 	node.serverRate.Mark(1)
 	node.totalRate.Mark(1)
 
-	node.memberlist.Update(req.Changes)
+	if !node.Quarantined(req.Source) {
+		node.memberlist.Update(req.Changes)
+	}
 
 	pingStartTime := time.Now()
 
@@ -64,15 +67,16 @@ func handlePingRequest(node *Node, req *pingRequest) (*pingResponse, error) {
 	}
 
 	changes, fullSync :=
-		node.disseminator.IssueAsReceiver(req.Source, req.SourceIncarnation, req.Checksum)
+		node.disseminator.IssueAsReceiver(req.Source, req.SourceIncarnation, req.Checksum, req.Checksums)
 
 	if fullSync {
 		// TODO: something...
 	}
 
 	return &pingResponse{
-		Target:  req.Target,
-		Ok:      pingOk,
-		Changes: changes,
+		Target:   req.Target,
+		Ok:       pingOk,
+		Changes:  changes,
+		Pushback: node.localPushback(),
 	}, nil
 }
@@ -27,17 +27,17 @@ import (
 
 	log "github.com/uber-common/bark"
 	"github.com/gl-works/ringpop-go/logging"
-	"github.com/gl-works/ringpop-go/shared"
 	"github.com/uber/tchannel-go/json"
 )
 
 // A PingRequest is used to make a ping request to a remote node
 type pingRequest struct {
-	Source            string   `json:"source"`
-	SourceIncarnation int64    `json:"sourceIncarnationNumber"`
-	Target            string   `json:"target"`
-	Checksum          uint32   `json:"checksum"`
-	Changes           []Change `json:"changes"`
+	Source            string            `json:"source"`
+	SourceIncarnation int64             `json:"sourceIncarnationNumber"`
+	Target            string            `json:"target"`
+	Checksum          uint32            `json:"checksum"`
+	Checksums         map[string]uint32 `json:"checksums,omitempty"`
+	Changes           []Change          `json:"changes"`
 }
 
 // A PingRequestSender is used to make a ping request to a remote node
@@ -68,13 +68,14 @@ func (p *pingRequestSender) SendPingRequest() (*pingResponse, error) {
 		"target": p.target,
 	}).Debug("ping request send")
 
-	ctx, cancel := shared.NewTChannelContext(p.timeout)
+	ctx, cancel := p.node.mesh.NewContext(p.timeout, p.peer)
 	defer cancel()
 
 	var res pingResponse
 	select {
 	case err := <-p.MakeCall(ctx, &res):
 		if err == nil {
+			p.node.recordPushback(p.peer, res.Pushback)
 			p.node.memberlist.Update(res.Changes)
 		}
 		return &res, err
@@ -91,23 +92,36 @@ func (p *pingRequestSender) MakeCall(ctx json.Context, res *pingResponse) <-chan
 	go func() {
 		defer close(errC)
 
+		if err := p.node.checkPeer(p.peer); err != nil {
+			errC <- err
+			return
+		}
+
 		changes, bumpPiggybackCounters := p.node.disseminator.IssueAsSender()
 		req := &pingRequest{
 			Source:            p.node.Address(),
 			SourceIncarnation: p.node.Incarnation(),
 			Checksum:          p.node.memberlist.Checksum(),
+			Checksums:         p.node.memberlist.Checksums(),
 			Changes:           changes,
 			Target:            p.target,
 		}
 
-		peer := p.node.channel.Peers().GetOrAdd(p.peer)
-		err := json.CallPeer(ctx, peer, p.node.service, "/protocol/ping-req", req, &res)
+		p.node.capture(Outbound, p.peer, "/protocol/ping-req", false, req)
+		err := p.node.send(ctx, p.peer, "/protocol/ping-req", req, res)
 		if err != nil {
 			bumpPiggybackCounters()
 			errC <- err
 			return
 		}
 
+		p.node.capture(Inbound, p.peer, "/protocol/ping-req", true, res)
+
+		if err := validateMessage("/protocol/ping-req", true, res); err != nil {
+			errC <- err
+			return
+		}
+
 		errC <- nil
 	}()
 
@@ -143,7 +157,11 @@ func indirectPing(n *Node, target string, amount int, timeout time.Duration) (re
 //  (2) PingResponse:   if the peer performed the ping request
 func sendPingRequests(node *Node, target string, size int, timeout time.Duration) <-chan interface{} {
 	var peerAddresses []string
-	peers := node.memberlist.RandomPingableMembers(size, map[string]bool{target: true})
+
+	// don't ask members that signaled pushback to help out
+	excluding := node.backedOffMembers()
+	excluding[target] = true
+	peers := node.memberlist.RandomPingableMembers(size, excluding)
 
 	for _, peer := range peers {
 		peerAddresses = append(peerAddresses, peer.Address)
@@ -27,16 +27,26 @@ import (
 	log "github.com/uber-common/bark"
 
 	"github.com/gl-works/ringpop-go/logging"
-	"github.com/gl-works/ringpop-go/shared"
 	"github.com/uber/tchannel-go/json"
 )
 
 // A Ping is used as an Arg3 for the ping TChannel call / response
 type ping struct {
-	Changes           []Change `json:"changes"`
-	Checksum          uint32   `json:"checksum"`
-	Source            string   `json:"source"`
-	SourceIncarnation int64    `json:"sourceIncarnationNumber"`
+	Changes           []Change          `json:"changes"`
+	Checksum          uint32            `json:"checksum"`
+	Checksums         map[string]uint32 `json:"checksums,omitempty"`
+	Source            string            `json:"source"`
+	SourceIncarnation int64             `json:"sourceIncarnationNumber"`
+	Pushback          *Pushback         `json:"pushback,omitempty"`
+	Features          Features          `json:"features,omitempty"`
+
+	// FullSyncRequested asks the target to respond with its full membership,
+	// regardless of the checksum, see RemediateMerge.
+	FullSyncRequested bool `json:"fullSyncRequested,omitempty"`
+
+	// Timestamp is the time of the responder in unix nanoseconds, used to
+	// estimate the clock skew between the nodes.
+	Timestamp int64 `json:"timestamp,omitempty"`
 }
 
 // A PingSender is used to send a SWIM gossip ping over TChannel to target node
@@ -45,6 +55,16 @@ type pingSender struct {
 	target  string
 	timeout time.Duration
 	logger  log.Logger
+
+	// fullSync sends the full membership instead of the changes to
+	// disseminate, and requestFullSync asks the target to respond with its
+	// full membership, see RemediateMerge.
+	fullSync        bool
+	requestFullSync bool
+
+	// changes are sent in addition to the changes to disseminate, see
+	// healPartition.
+	changes []Change
 }
 
 // NewPingSender returns a new PingSender that can be used to send a ping to target node
@@ -60,7 +80,7 @@ func newPingSender(node *Node, target string, timeout time.Duration) *pingSender
 }
 
 func (p *pingSender) SendPing() (*ping, error) {
-	ctx, cancel := shared.NewTChannelContext(p.timeout)
+	ctx, cancel := p.node.mesh.NewContext(p.timeout, p.target)
 
 	defer cancel()
 
@@ -83,14 +103,25 @@ func (p *pingSender) MakeCall(ctx json.Context, res *ping) <-chan error {
 	go func() {
 		defer close(errC)
 
-		peer := p.node.channel.Peers().GetOrAdd(p.target)
+		if err := p.node.checkPeer(p.target); err != nil {
+			errC <- err
+			return
+		}
 
 		changes, bumpPiggybackCounters := p.node.disseminator.IssueAsSender()
+		if p.fullSync {
+			changes = p.node.disseminator.FullSync()
+		}
+		changes = append(changes, p.changes...)
+
 		req := ping{
 			Checksum:          p.node.memberlist.Checksum(),
+			Checksums:         p.node.memberlist.Checksums(),
 			Changes:           changes,
 			Source:            p.node.Address(),
 			SourceIncarnation: p.node.Incarnation(),
+			Features:          p.node.LocalFeatures(),
+			FullSyncRequested: p.requestFullSync,
 		}
 
 		p.node.emit(PingSendEvent{
@@ -105,8 +136,10 @@ func (p *pingSender) MakeCall(ctx json.Context, res *ping) <-chan error {
 		}).Debug("ping send")
 
 		var startTime = time.Now()
+		sent := p.node.clock.Now()
 
-		err := json.CallPeer(ctx, peer, p.node.service, "/protocol/ping", req, res)
+		p.node.capture(Outbound, p.target, "/protocol/ping", false, req)
+		err := p.node.send(ctx, p.target, "/protocol/ping", req, res)
 		if err != nil {
 			p.logger.WithFields(log.Fields{
 				"remote": p.target,
@@ -117,6 +150,16 @@ func (p *pingSender) MakeCall(ctx json.Context, res *ping) <-chan error {
 		}
 
 		// when ping was successful
+		p.node.capture(Inbound, p.target, "/protocol/ping", true, res)
+
+		if err := validateMessage("/protocol/ping", true, res); err != nil {
+			errC <- err
+			return
+		}
+		p.node.recordPeerFeatures(res.Source, res.Features)
+		if res.Timestamp != 0 {
+			p.node.recordClockSkew(res.Source, sent, time.Unix(0, res.Timestamp), p.node.clock.Now())
+		}
 		bumpPiggybackCounters()
 
 		p.node.emit(PingSendCompleteEvent{
@@ -21,9 +21,11 @@
 package swim
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"github.com/gl-works/ringpop-go/events/test/mocks"
@@ -97,6 +99,96 @@ func (s *PingTestSuite) TestPingBeforeReady() {
 	listener.AssertExpectations(s.T())
 }
 
+func (s *PingTestSuite) TestPingPushback() {
+	s.peer.SetPushback(time.Second, 0.9)
+	defer s.peer.SetPushback(0, 0)
+
+	res, err := sendPing(s.node, s.peer.Address(), time.Second)
+	s.Require().NoError(err, "expected a ping to succeed")
+	s.Require().NotNil(res.Pushback, "expected ping response to carry pushback")
+	s.Equal(int64(1000), res.Pushback.RetryAfter, "expected retry after in ms")
+	s.Equal(0.9, res.Pushback.Load, "expected load to be advertised")
+
+	s.peer.SetPushback(0, 0)
+
+	res, err = sendPing(s.node, s.peer.Address(), time.Second)
+	s.Require().NoError(err, "expected a ping to succeed")
+	s.Nil(res.Pushback, "expected pushback to be cleared")
+}
+
+func (s *PingTestSuite) TestPingHookVeto() {
+	var infos []PingInfo
+	s.peer.SetPingHook(func(info PingInfo) error {
+		infos = append(infos, info)
+		return errors.New("unhealthy")
+	})
+	defer s.peer.SetPingHook(nil)
+
+	res, err := sendPing(s.node, s.peer.Address(), time.Second)
+	s.Error(err, "expected vetoed ping to fail")
+	s.Nil(res, "expected response to be nil")
+	s.Require().Len(infos, 1)
+	s.Equal(s.node.Address(), infos[0].Source)
+
+	s.peer.SetPingHook(func(info PingInfo) error { panic("bad state") })
+	_, err = sendPing(s.node, s.peer.Address(), time.Second)
+	s.Error(err, "expected ping to fail when the hook panics")
+
+	s.peer.SetPingHook(func(info PingInfo) error { return nil })
+	_, err = sendPing(s.node, s.peer.Address(), time.Second)
+	s.NoError(err, "expected ping to succeed when not vetoed")
+}
+
+// TestPingHookKeepsNodeDown tests that a node whose ping hook vetoes acks does
+// not refute being declared faulty by the cluster, and does once the hook
+// accepts pings again.
+func (s *PingTestSuite) TestPingHookKeepsNodeDown() {
+	tnode := newChannelNode(s.T())
+	tpeer := newChannelNode(s.T())
+	tother := newChannelNode(s.T())
+	defer destroyNodes(tnode, tpeer, tother)
+	bootstrapNodes(s.T(), tnode, tpeer, tother)
+
+	// gossip pings the target and applies the changes it responds with, as
+	// a protocol period does
+	gossip := func(from, to *testNode) {
+		res, err := sendPing(from.node, to.node.Address(), time.Second)
+		s.Require().NoError(err)
+		from.node.memberlist.Update(res.Changes)
+	}
+
+	tnode.node.SetPingHook(func(info PingInfo) error {
+		return errors.New("unhealthy")
+	})
+
+	_, err := sendPing(tpeer.node, tnode.node.Address(), time.Second)
+	s.Error(err, "expected vetoed ping to fail")
+	tpeer.node.memberlist.MakeFaulty(tnode.node.Address(), tnode.node.Incarnation())
+	tnode.node.clock.(*clock.Mock).Add(time.Second)
+	incarnation := tnode.node.Incarnation()
+
+	// the node keeps gossiping and learns that it was declared faulty
+	gossip(tnode, tpeer)
+	gossip(tnode, tother)
+	gossip(tpeer, tother)
+	gossip(tnode, tother)
+
+	s.Equal(incarnation, tnode.node.Incarnation(), "expected the node not to refute")
+	for _, observer := range []*testNode{tpeer, tother} {
+		member, ok := observer.node.memberlist.Member(tnode.node.Address())
+		s.Require().True(ok)
+		s.Equal(Faulty, member.Status, "expected the node to stay down")
+	}
+
+	tnode.node.SetPingHook(nil)
+	gossip(tnode, tpeer)
+	gossip(tnode, tpeer)
+
+	s.NotEqual(incarnation, tnode.node.Incarnation(), "expected the node to refute")
+	member, _ := tpeer.node.memberlist.Member(tnode.node.Address())
+	s.Equal(Alive, member.Status, "expected the node to refute once the hook accepts pings")
+}
+
 func TestPingTestSuite(t *testing.T) {
 	suite.Run(t, new(PingTestSuite))
 }
@@ -0,0 +1,169 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/util"
+)
+
+// A Pushback is sent by an overloaded node in its responses to ping and
+// ping-req calls. It asks the caller to back off and not send protocol
+// requests to the node for the duration of RetryAfter.
+type Pushback struct {
+	// RetryAfter is the back off duration in milliseconds.
+	RetryAfter int64 `json:"retryAfter"`
+
+	// Load is an indication of how overloaded the node is, ranging from 0
+	// (idle) to 1 (saturated).
+	Load float64 `json:"load"`
+}
+
+// maxPushbackPeriods is the number of protocol periods a pushback can back off
+// from a member for at most, so that a member cannot hold off the failure
+// detector indefinitely by asking for long backoffs.
+const maxPushbackPeriods = 5
+
+// pushbackState contains the pushback that the node advertises to others and
+// the time until which remote nodes asked to be backed off from.
+type pushbackState struct {
+	local   *Pushback
+	backoff map[string]time.Time
+	sync.RWMutex
+}
+
+// SetPushback makes the node advertise a pushback signal in responses to
+// protocol requests, asking callers to back off for retryAfter. Calling
+// SetPushback with a retryAfter of zero stops advertising pushback.
+func (n *Node) SetPushback(retryAfter time.Duration, load float64) {
+	n.pushback.Lock()
+	if retryAfter <= 0 {
+		n.pushback.local = nil
+	} else {
+		n.pushback.local = &Pushback{
+			RetryAfter: util.MS(retryAfter),
+			Load:       load,
+		}
+	}
+	n.pushback.Unlock()
+}
+
+// localPushback returns the pushback the node currently advertises, or nil if
+// the node is not overloaded.
+func (n *Node) localPushback() *Pushback {
+	n.pushback.RLock()
+	p := n.pushback.local
+	n.pushback.RUnlock()
+	return p
+}
+
+// recordPushback records that the remote node asked to be backed off from. The
+// backoff is capped at maxPushbackPeriods protocol periods.
+func (n *Node) recordPushback(remote string, p *Pushback) {
+	if p == nil || p.RetryAfter <= 0 {
+		return
+	}
+
+	period := n.gossip.ProtocolRate()
+	if period < n.gossip.minProtocolPeriod {
+		period = n.gossip.minProtocolPeriod
+	}
+
+	retryAfter := maxPushbackPeriods * period
+	if p.RetryAfter < util.MS(retryAfter) {
+		retryAfter = time.Duration(p.RetryAfter) * time.Millisecond
+	}
+
+	n.pushback.Lock()
+	if n.pushback.backoff == nil {
+		n.pushback.backoff = make(map[string]time.Time)
+	}
+	n.pushback.backoff[remote] = n.clock.Now().Add(retryAfter)
+	n.pushback.Unlock()
+
+	n.emit(PushbackReceivedEvent{
+		Remote:     remote,
+		RetryAfter: retryAfter,
+		Load:       p.Load,
+	})
+
+	n.logger.WithFields(log.Fields{
+		"remote":     remote,
+		"retryAfter": retryAfter,
+		"load":       p.Load,
+	}).Debug("received pushback")
+}
+
+// backingOff returns whether protocol requests to the remote node should be
+// held back because it signaled pushback.
+func (n *Node) backingOff(remote string) bool {
+	n.pushback.Lock()
+	defer n.pushback.Unlock()
+
+	until, ok := n.pushback.backoff[remote]
+	if !ok {
+		return false
+	}
+
+	if !n.clock.Now().Before(until) {
+		delete(n.pushback.backoff, remote)
+		return false
+	}
+
+	return true
+}
+
+// nextProbeTarget returns the next member to probe that is not being backed
+// off from. Members that are backed off from are skipped, and counted as missed
+// acks, so that the protocol period is used to probe another member.
+func (n *Node) nextProbeTarget() (*Member, bool) {
+	for i := n.memberlist.NumMembers(); i > 0; i-- {
+		member, ok := n.nextTarget()
+		if !ok {
+			return nil, false
+		}
+		if !n.backingOff(member.Address) {
+			return member, true
+		}
+
+		n.recordMissedAck(member.Address)
+		n.logger.WithField("target", member.Address).Debug("skipping ping to member that signaled pushback")
+	}
+	return nil, false
+}
+
+// backedOffMembers returns a set of the addresses of all nodes that are
+// currently being backed off from.
+func (n *Node) backedOffMembers() map[string]bool {
+	n.pushback.RLock()
+	now := n.clock.Now()
+	backedOff := make(map[string]bool, len(n.pushback.backoff))
+	for address, until := range n.pushback.backoff {
+		if now.Before(until) {
+			backedOff[address] = true
+		}
+	}
+	n.pushback.RUnlock()
+	return backedOff
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushbackCapped(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	node := tnode.node
+	mockClock := node.clock.(*clock.Mock)
+
+	var received []PushbackReceivedEvent
+	node.RegisterListener(ListenerFunc(func(e events.Event) {
+		if e, ok := e.(PushbackReceivedEvent); ok {
+			received = append(received, e)
+		}
+	}))
+
+	node.recordPushback("127.0.0.1:3002", &Pushback{RetryAfter: 60000})
+
+	limit := maxPushbackPeriods * node.gossip.minProtocolPeriod
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, limit, received[0].RetryAfter, "expected retry after to be capped")
+	}
+
+	mockClock.Add(limit - time.Millisecond)
+	assert.True(t, node.backingOff("127.0.0.1:3002"), "expected node to back off from member")
+
+	mockClock.Add(time.Millisecond)
+	assert.False(t, node.backingOff("127.0.0.1:3002"), "expected backoff to expire after the cap")
+}
+
+func TestPingNextMemberSkipsPushback(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpushback := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpushback, tpeer)
+
+	bootstrapNodes(t, tpushback, tpeer, tnode)
+
+	var pinged []string
+	tnode.node.RegisterListener(ListenerFunc(func(e events.Event) {
+		if e, ok := e.(PingSendEvent); ok {
+			pinged = append(pinged, e.Remote)
+		}
+	}))
+
+	tnode.node.recordPushback(tpushback.node.Address(), &Pushback{RetryAfter: 1000})
+	for i := 0; i < 4; i++ {
+		tnode.node.pingNextMember()
+	}
+	assert.Equal(t, []string{
+		tpeer.node.Address(),
+		tpeer.node.Address(),
+		tpeer.node.Address(),
+		tpeer.node.Address(),
+	}, pinged, "expected every protocol period to probe the member that did not signal pushback")
+
+	pinged = nil
+	tnode.node.ReportTransportFailure(tpushback.node.Address())
+	tnode.node.pingNextMember()
+	assert.Equal(t, []string{tpushback.node.Address()}, pinged,
+		"expected reported member to be probed despite pushback")
+}
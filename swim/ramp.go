@@ -0,0 +1,124 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"sync"
+	"time"
+)
+
+// rampState tracks how far the local member is ramped in.
+type rampState struct {
+	period time.Duration
+	steps  int
+	start  time.Time
+	sync.Mutex
+}
+
+// startRamp starts ramping in the local member. It is called when the node
+// bootstraps, so the member joins the cluster with only the first step of its
+// ownership.
+func (n *Node) startRamp() {
+	n.ramp.Lock()
+	ramping := n.ramp.period > 0
+	if ramping {
+		n.ramp.start = n.clock.Now()
+	}
+	n.ramp.Unlock()
+
+	if ramping {
+		n.checkRamp()
+	}
+}
+
+// ramping returns whether the local member is ramping in automatically.
+func (n *Node) ramping() bool {
+	n.ramp.Lock()
+	defer n.ramp.Unlock()
+
+	return n.ramp.period > 0
+}
+
+// rampPercentNoLock returns the percentage of its ownership the local member
+// should have, or zero once it has finished ramping in. The ramp must be
+// locked.
+func (n *Node) rampPercentNoLock() int {
+	if n.ramp.period <= 0 || n.ramp.start.IsZero() {
+		return 0
+	}
+
+	elapsed := n.clock.Now().Sub(n.ramp.start)
+	step := int(int64(elapsed)*int64(n.ramp.steps)/int64(n.ramp.period)) + 1
+	if step >= n.ramp.steps {
+		return 0
+	}
+	return step * 100 / n.ramp.steps
+}
+
+// checkRamp gossips the next step of the ramp of the local member when it is
+// due. It is run every protocol period.
+func (n *Node) checkRamp() {
+	// the step is set while the ramp is locked, so it cannot override a ramp
+	// set with SetRamp in the meantime
+	n.ramp.Lock()
+	if n.ramp.period <= 0 {
+		n.ramp.Unlock()
+		return
+	}
+	ramp := n.rampPercentNoLock()
+	changed := n.memberlist.SetRamp(ramp) != nil
+	if ramp == 0 {
+		n.ramp.period = 0
+	}
+	n.ramp.Unlock()
+
+	if changed {
+		n.logger.WithField("ramp", ramp).Info("changed local member ramp")
+		n.emit(RampChangedEvent{Ramp: ramp})
+	}
+}
+
+// SetRamp sets the percentage, between 1 and 99, of its replica points the
+// local member has on the ring while it is ramping in, or 0 for its full
+// ownership. The ramp is gossiped to all members, so that they all give the
+// member the same share of the keyspace. Setting the ramp manually stops an
+// automatic ramp configured with Options.RampPeriod.
+func (n *Node) SetRamp(ramp int) error {
+	if !n.Ready() {
+		return ErrNodeNotReady
+	}
+
+	if ramp < 0 || ramp > 99 {
+		ramp = 0
+	}
+
+	n.ramp.Lock()
+	n.ramp.period = 0
+	changed := n.memberlist.SetRamp(ramp) != nil
+	n.ramp.Unlock()
+
+	if changed {
+		n.logger.WithField("ramp", ramp).Info("changed local member ramp")
+		n.emit(RampChangedEvent{Ramp: ramp})
+	}
+
+	return nil
+}
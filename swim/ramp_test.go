@@ -0,0 +1,94 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRampIn(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	tnode.node.ramp.period = 10 * time.Second
+	tnode.node.ramp.steps = 10
+	tclock := tnode.node.clock.(*clock.Mock)
+
+	bootstrapNodes(t, tnode)
+	assert.Equal(t, 10, tnode.node.memberlist.local.Ramp, "expected node to join with the first step")
+
+	incarnation := tnode.node.Incarnation()
+	tclock.Add(5 * time.Second)
+	tnode.node.checkRamp()
+	assert.Equal(t, 60, tnode.node.memberlist.local.Ramp)
+	assert.True(t, tnode.node.Incarnation() > incarnation, "expected ramp change to bump the incarnation")
+
+	tclock.Add(5 * time.Second)
+	tnode.node.checkRamp()
+	assert.Equal(t, 0, tnode.node.memberlist.local.Ramp, "expected full ownership after the ramp period")
+	assert.Equal(t, time.Duration(0), tnode.node.ramp.period, "expected ramp to stop")
+}
+
+func TestSetRamp(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	assert.Equal(t, ErrNodeNotReady, tnode.node.SetRamp(50))
+
+	bootstrapNodes(t, tnode)
+
+	// the incarnation is bumped even within the same millisecond
+	incarnation := tnode.node.Incarnation()
+	require.NoError(t, tnode.node.SetRamp(50))
+	assert.Equal(t, 50, tnode.node.memberlist.local.Ramp)
+	assert.True(t, tnode.node.Incarnation() > incarnation)
+
+	require.NoError(t, tnode.node.SetRamp(100))
+	assert.Equal(t, 0, tnode.node.memberlist.local.Ramp, "expected out of range ramp to grant full ownership")
+}
+
+func TestSetRampDuringRamp(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	tnode.node.ramp.period = 10 * time.Second
+	tnode.node.ramp.steps = 10
+	bootstrapNodes(t, tnode)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			tnode.node.checkRamp()
+		}
+	}()
+
+	require.NoError(t, tnode.node.SetRamp(50))
+	<-done
+
+	assert.False(t, tnode.node.ramping(), "expected setting the ramp to stop the automatic ramp")
+	assert.Equal(t, 50, tnode.node.memberlist.local.Ramp)
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+)
+
+// reaperState tracks since when members have been faulty or tombstones, so
+// that they can be reaped once the faulty and tombstone TTLs passed.
+type reaperState struct {
+	faultyTTL    time.Duration
+	tombstoneTTL time.Duration
+
+	faulty     map[string]time.Time
+	tombstones map[string]time.Time
+
+	// reaped are the members removed from the memberlist, so that stale
+	// changes still gossiped by members that did not reap them yet do not
+	// add them back. They are forgotten after the faulty TTL.
+	reaped map[string]reapedMember
+
+	sync.Mutex
+}
+
+type reapedMember struct {
+	incarnation int64
+	at          time.Time
+}
+
+// trackReapable records since when the member of the change has been faulty
+// or a tombstone, keeping the time it first entered the state.
+func (n *Node) trackReapable(change Change) {
+	if n.reaper.faultyTTL <= 0 {
+		return
+	}
+
+	n.reaper.Lock()
+	defer n.reaper.Unlock()
+
+	if n.reaper.faulty == nil {
+		n.reaper.faulty = make(map[string]time.Time)
+		n.reaper.tombstones = make(map[string]time.Time)
+	}
+
+	switch change.Status {
+	case Faulty:
+		delete(n.reaper.tombstones, change.Address)
+		if _, ok := n.reaper.faulty[change.Address]; !ok {
+			n.reaper.faulty[change.Address] = n.clock.Now()
+		}
+
+	case Tombstone:
+		delete(n.reaper.faulty, change.Address)
+		if _, ok := n.reaper.tombstones[change.Address]; !ok {
+			n.reaper.tombstones[change.Address] = n.clock.Now()
+		}
+
+	default:
+		delete(n.reaper.faulty, change.Address)
+		delete(n.reaper.tombstones, change.Address)
+	}
+}
+
+// expiredReapable returns the members that have been faulty for longer than
+// the faulty TTL, and those that have been tombstones for longer than the
+// tombstone TTL.
+func (n *Node) expiredReapable() (faulty, tombstones []string) {
+	n.reaper.Lock()
+	defer n.reaper.Unlock()
+
+	now := n.clock.Now()
+	for address, since := range n.reaper.faulty {
+		if now.Sub(since) >= n.reaper.faultyTTL {
+			faulty = append(faulty, address)
+		}
+	}
+	for address, since := range n.reaper.tombstones {
+		if now.Sub(since) >= n.reaper.tombstoneTTL {
+			tombstones = append(tombstones, address)
+		}
+	}
+
+	for address, reaped := range n.reaper.reaped {
+		if now.Sub(reaped.at) >= n.reaper.faultyTTL {
+			delete(n.reaper.reaped, address)
+		}
+	}
+
+	return faulty, tombstones
+}
+
+// wasReaped returns whether the change is about a member that was reaped at
+// the same or a later incarnation, and should therefore be ignored.
+func (n *Node) wasReaped(change Change) bool {
+	n.reaper.Lock()
+	reaped, ok := n.reaper.reaped[change.Address]
+	n.reaper.Unlock()
+
+	return ok && change.Incarnation <= reaped.incarnation
+}
+
+// tombstonesSupported returns whether all reachable members negotiated
+// support for tombstones with the node. Members running a version without
+// tombstones would reject the gossip that carries them.
+func (n *Node) tombstonesSupported() bool {
+	for _, address := range n.memberlist.GetReachableMembers() {
+		if address == n.address {
+			continue
+		}
+
+		features, ok := n.PeerFeatures(address)
+		if !ok || !features.Has(FeatureTombstones) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reapMembers turns members that have been faulty for longer than the faulty
+// TTL into tombstones, which are disseminated like any other change, and
+// removes tombstones from the memberlist once the tombstone TTL passed and
+// the tombstone had time to reach all members. Members are not turned into
+// tombstones while a reachable member does not support them.
+func (n *Node) reapMembers() {
+	if n.reaper.faultyTTL <= 0 {
+		return
+	}
+
+	faulty, tombstones := n.expiredReapable()
+
+	if len(faulty) > 0 && n.tombstonesSupported() {
+		for _, address := range faulty {
+			member, ok := n.memberlist.Member(address)
+			if !ok || member.Status != Faulty {
+				continue
+			}
+			incarnation := member.Incarnation
+
+			n.logger.WithFields(log.Fields{
+				"member":      address,
+				"incarnation": incarnation,
+			}).Info("member faulty for longer than faulty TTL, making it a tombstone")
+
+			n.emit(MemberTombstonedEvent{
+				Address:     address,
+				Incarnation: incarnation,
+			})
+			n.memberlist.MakeTombstone(address, incarnation)
+		}
+	}
+
+	for _, address := range tombstones {
+		incarnation, ok := n.memberlist.RemoveTombstone(address)
+
+		n.reaper.Lock()
+		delete(n.reaper.tombstones, address)
+		if ok {
+			if n.reaper.reaped == nil {
+				n.reaper.reaped = make(map[string]reapedMember)
+			}
+			n.reaper.reaped[address] = reapedMember{
+				incarnation: incarnation,
+				at:          n.clock.Now(),
+			}
+		}
+		n.reaper.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		n.disseminator.ClearChange(address)
+		n.forgetPeerFeatures(address)
+
+		n.logger.WithField("member", address).Info("reaped tombstone member")
+
+		n.emit(MemberReapedEvent{
+			Address:     address,
+			Incarnation: incarnation,
+		})
+	}
+}
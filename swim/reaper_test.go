@@ -0,0 +1,165 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events"
+)
+
+func newReaperNode(t *testing.T) (*Node, *clock.Mock) {
+	mockClock := clock.NewMock()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		FaultyTTL:    time.Minute,
+		TombstoneTTL: 10 * time.Second,
+		Clock:        mockClock,
+	})
+	node.memberlist.MakeAlive(node.Address(), nowInMillis(mockClock))
+	return node, mockClock
+}
+
+func TestReapFaultyMember(t *testing.T) {
+	node, mockClock := newReaperNode(t)
+	defer node.Destroy()
+
+	var tombstoned []MemberTombstonedEvent
+	var reaped []MemberReapedEvent
+	node.RegisterListener(ListenerFunc(func(event events.Event) {
+		switch event := event.(type) {
+		case MemberTombstonedEvent:
+			tombstoned = append(tombstoned, event)
+		case MemberReapedEvent:
+			reaped = append(reaped, event)
+		}
+	}))
+
+	checksum := node.memberlist.GenChecksumString()
+
+	peer := "127.0.0.1:3002"
+	node.memberlist.MakeAlive(peer, 1)
+	node.memberlist.MakeFaulty(peer, 1)
+
+	mockClock.Add(time.Minute - time.Second)
+	node.reapMembers()
+	assert.Empty(t, tombstoned, "expected member to stay faulty before the faulty TTL")
+
+	mockClock.Add(time.Second)
+	node.reapMembers()
+	require.Len(t, tombstoned, 1)
+	assert.Equal(t, MemberTombstonedEvent{Address: peer, Incarnation: 1}, tombstoned[0])
+
+	member, ok := node.memberlist.Member(peer)
+	require.True(t, ok, "expected tombstone to remain a member until the tombstone TTL")
+	assert.Equal(t, Tombstone, member.Status)
+	assert.Equal(t, checksum, node.memberlist.GenChecksumString(),
+		"expected tombstones to be excluded from the checksum")
+
+	change, ok := node.disseminator.ChangesByAddress(peer)
+	require.True(t, ok, "expected tombstone to be disseminated")
+	assert.Equal(t, Tombstone, change.Status)
+
+	mockClock.Add(10 * time.Second)
+	node.reapMembers()
+	assert.Equal(t, []MemberReapedEvent{{Address: peer, Incarnation: 1}}, reaped)
+
+	_, ok = node.memberlist.Member(peer)
+	assert.False(t, ok, "expected tombstone to be removed")
+	_, ok = node.disseminator.ChangesByAddress(peer)
+	assert.False(t, ok, "expected tombstone to no longer be disseminated")
+
+	// stale changes gossiped by members that did not reap the member yet
+	// do not add it back
+	node.memberlist.Update([]Change{
+		{Address: peer, Incarnation: 1, Status: Faulty},
+		{Address: peer, Incarnation: 1, Status: Tombstone},
+	})
+	_, ok = node.memberlist.Member(peer)
+	assert.False(t, ok, "expected stale changes to be ignored")
+
+	// the member may rejoin with a new incarnation
+	node.memberlist.Update([]Change{{Address: peer, Incarnation: 2, Status: Alive}})
+	member, ok = node.memberlist.Member(peer)
+	require.True(t, ok, "expected member to rejoin")
+	assert.Equal(t, Alive, member.Status)
+}
+
+func TestReapWaitsForTombstoneSupport(t *testing.T) {
+	node, mockClock := newReaperNode(t)
+	defer node.Destroy()
+
+	faulty := "127.0.0.1:3002"
+	peer := "127.0.0.1:3003"
+	node.memberlist.MakeAlive(peer, 1)
+	node.memberlist.MakeAlive(faulty, 1)
+	node.memberlist.MakeFaulty(faulty, 1)
+
+	mockClock.Add(time.Minute)
+	node.reapMembers()
+	member, _ := node.memberlist.Member(faulty)
+	assert.Equal(t, Faulty, member.Status,
+		"expected no tombstone before the reachable peer negotiated support")
+
+	node.recordPeerFeatures(peer, FeatureJoinTimes)
+	node.reapMembers()
+	member, _ = node.memberlist.Member(faulty)
+	assert.Equal(t, Faulty, member.Status,
+		"expected no tombstone while the reachable peer does not support it")
+
+	node.recordPeerFeatures(peer, protocolFeatures)
+	node.reapMembers()
+	member, _ = node.memberlist.Member(faulty)
+	assert.Equal(t, Tombstone, member.Status)
+}
+
+func TestReapingDisabled(t *testing.T) {
+	mockClock := clock.NewMock()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{Clock: mockClock})
+	defer node.Destroy()
+	node.memberlist.MakeAlive(node.Address(), nowInMillis(mockClock))
+
+	peer := "127.0.0.1:3002"
+	node.memberlist.MakeAlive(peer, 1)
+	node.memberlist.MakeFaulty(peer, 1)
+
+	mockClock.Add(24 * time.Hour)
+	node.reapMembers()
+	member, ok := node.memberlist.Member(peer)
+	require.True(t, ok)
+	assert.Equal(t, Faulty, member.Status)
+}
+
+func TestRefuteTombstone(t *testing.T) {
+	node, _ := newReaperNode(t)
+	defer node.Destroy()
+
+	node.memberlist.Update([]Change{{
+		Address:     node.Address(),
+		Incarnation: node.Incarnation(),
+		Status:      Tombstone,
+	}})
+	assert.Equal(t, Alive, node.memberlist.local.Status,
+		"expected the local member to refute its tombstone")
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+)
+
+// A RemediationAction is a step the node takes when its membership checksum
+// keeps mismatching the checksum of a peer.
+type RemediationAction string
+
+const (
+	// RemediateFullSync sends the full membership of the node to the peer
+	// right away, instead of waiting for the peer to ping the node.
+	RemediateFullSync RemediationAction = "full-sync"
+
+	// RemediateMerge exchanges the full memberships of the node and the peer,
+	// and merges the membership of the peer into the membership of the node.
+	RemediateMerge RemediationAction = "merge"
+
+	// RemediateAlert raises a ChecksumRemediationEvent that is logged as an
+	// error, for operators to investigate.
+	RemediateAlert RemediationAction = "alert"
+
+	// RemediateQuarantine ignores the changes the peer gossips directly for
+	// the quarantine duration of the policy, so that a peer with a corrupt
+	// membership does not keep spreading it. This covers its pings and
+	// ping-reqs, anti-entropy syncs, full syncs, join responses and partition
+	// heals. The mismatches with the peer are counted from zero once the
+	// quarantine ends.
+	RemediateQuarantine RemediationAction = "quarantine"
+)
+
+// A RemediationStep takes Action once the checksum of the node mismatched
+// the checksum of a peer Mismatches times in a row.
+type RemediationStep struct {
+	Mismatches int
+	Action     RemediationAction
+}
+
+// A RemediationPolicy is the ladder of steps the node takes when its
+// membership checksum persistently mismatches the checksum of a peer, as
+// detected by full syncs to the peer. Each step is taken once when the number
+// of consecutive mismatches reaches it, and the count starts over when the
+// checksums match again. A full sync that is split into chunks by a full sync
+// budget counts every chunk as a mismatch. The zero policy takes no steps.
+type RemediationPolicy struct {
+	Steps              []RemediationStep
+	QuarantineDuration time.Duration
+}
+
+// DefaultRemediationPolicy is a remediation policy that sends a full sync to a
+// peer after 3 mismatches, merges memberships with it after 6 and raises an
+// alert after 10. It does not quarantine peers.
+var DefaultRemediationPolicy = RemediationPolicy{
+	Steps: []RemediationStep{
+		{Mismatches: 3, Action: RemediateFullSync},
+		{Mismatches: 6, Action: RemediateMerge},
+		{Mismatches: 10, Action: RemediateAlert},
+	},
+}
+
+// Validate returns an error if the steps of the policy are not ordered by
+// their number of mismatches, take unknown actions, or quarantine peers
+// without a positive quarantine duration.
+func (p RemediationPolicy) Validate() error {
+	last := 0
+	for _, step := range p.Steps {
+		if step.Mismatches < 1 {
+			return fmt.Errorf("remediation step %q must follow at least 1 mismatch", step.Action)
+		}
+		if step.Mismatches < last {
+			return errors.New("remediation steps must be ordered by their number of mismatches")
+		}
+		last = step.Mismatches
+
+		switch step.Action {
+		case RemediateFullSync, RemediateMerge, RemediateAlert:
+		case RemediateQuarantine:
+			if p.QuarantineDuration <= 0 {
+				return errors.New("remediation quarantine duration must be positive")
+			}
+		default:
+			return fmt.Errorf("unknown remediation action %q", step.Action)
+		}
+	}
+	return nil
+}
+
+// remediationState counts the consecutive checksum mismatches with each peer
+// and contains the peers that are quarantined, until when.
+type remediationState struct {
+	policy      RemediationPolicy
+	mismatches  map[string]int
+	quarantined map[string]time.Time
+	sync.Mutex
+}
+
+// recordMismatch counts a checksum mismatch with the peer and takes the steps
+// of the remediation policy the count reached.
+func (n *Node) recordMismatch(peer string, remoteChecksum uint32) {
+	r := &n.remediation
+	if len(r.policy.Steps) == 0 {
+		return
+	}
+
+	r.Lock()
+	if n.quarantinedNoLock(peer) {
+		r.Unlock()
+		return
+	}
+
+	if r.mismatches == nil {
+		r.mismatches = make(map[string]int)
+	}
+	r.mismatches[peer]++
+	count := r.mismatches[peer]
+
+	var actions []RemediationAction
+	for _, step := range r.policy.Steps {
+		if step.Mismatches != count {
+			continue
+		}
+		actions = append(actions, step.Action)
+
+		if step.Action == RemediateQuarantine {
+			if r.quarantined == nil {
+				r.quarantined = make(map[string]time.Time)
+			}
+			r.quarantined[peer] = n.clock.Now().Add(r.policy.QuarantineDuration)
+			delete(r.mismatches, peer)
+		}
+	}
+	r.Unlock()
+
+	for _, action := range actions {
+		n.remediate(peer, action, count, remoteChecksum)
+	}
+}
+
+// remediate takes a step of the remediation policy.
+func (n *Node) remediate(peer string, action RemediationAction, mismatches int, remoteChecksum uint32) {
+	event := ChecksumRemediationEvent{
+		Peer:           peer,
+		Action:         action,
+		Mismatches:     mismatches,
+		LocalChecksum:  n.memberlist.Checksum(),
+		RemoteChecksum: remoteChecksum,
+	}
+	n.emit(event)
+
+	logger := n.logger.WithFields(log.Fields{
+		"remote":         peer,
+		"action":         action,
+		"mismatches":     mismatches,
+		"localChecksum":  event.LocalChecksum,
+		"remoteChecksum": remoteChecksum,
+	})
+
+	switch action {
+	case RemediateFullSync, RemediateMerge:
+		logger.Warn("remediating persistent checksum mismatch")
+		go n.exchangeFullSync(peer, action == RemediateMerge)
+	case RemediateAlert:
+		logger.Error("persistent checksum mismatch")
+	case RemediateQuarantine:
+		logger.Error("quarantined peer with persistent checksum mismatch")
+	}
+}
+
+// exchangeFullSync sends the full membership of the node to the peer and
+// applies the changes it responds with. When merge is set, the peer is asked
+// to respond with its full membership as well.
+func (n *Node) exchangeFullSync(peer string, merge bool) {
+	ps := newPingSender(n, peer, n.pingTimeout)
+	ps.fullSync = true
+	ps.requestFullSync = merge
+
+	res, err := ps.SendPing()
+	if err != nil {
+		n.logger.WithFields(log.Fields{
+			"remote": peer,
+			"error":  err,
+		}).Info("checksum remediation failed")
+		return
+	}
+
+	if !n.Quarantined(peer) {
+		n.memberlist.Update(res.Changes)
+	}
+}
+
+// clearMismatches forgets the mismatches with the peer once the checksums
+// match, and reports the convergence of a peer the remediation policy took
+// steps for.
+func (n *Node) clearMismatches(peer string) {
+	r := &n.remediation
+	if len(r.policy.Steps) == 0 {
+		return
+	}
+
+	r.Lock()
+	count := r.mismatches[peer]
+	delete(r.mismatches, peer)
+	r.Unlock()
+
+	if count < r.policy.Steps[0].Mismatches {
+		return
+	}
+
+	n.emit(ChecksumConvergedEvent{Peer: peer, Mismatches: count})
+	n.logger.WithFields(log.Fields{
+		"remote":     peer,
+		"mismatches": count,
+	}).Info("checksum mismatch remediated")
+}
+
+// Quarantined returns whether the changes the peer gossips are ignored
+// because of a persistent checksum mismatch, see RemediateQuarantine.
+func (n *Node) Quarantined(peer string) bool {
+	n.remediation.Lock()
+	defer n.remediation.Unlock()
+	return n.quarantinedNoLock(peer)
+}
+
+// quarantinedNoLock returns whether the peer is quarantined, and ends expired
+// quarantines. It must be called while the remediation state is locked.
+func (n *Node) quarantinedNoLock(peer string) bool {
+	until, ok := n.remediation.quarantined[peer]
+	if !ok {
+		return false
+	}
+	if n.clock.Now().Before(until) {
+		return true
+	}
+
+	delete(n.remediation.quarantined, peer)
+	n.logger.WithField("remote", peer).Info("quarantine of peer ended")
+	return false
+}
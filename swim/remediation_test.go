@@ -0,0 +1,159 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events"
+)
+
+func TestRemediationPolicyValidate(t *testing.T) {
+	assert.NoError(t, RemediationPolicy{}.Validate())
+	assert.NoError(t, DefaultRemediationPolicy.Validate())
+
+	assert.Error(t, RemediationPolicy{Steps: []RemediationStep{
+		{Mismatches: 0, Action: RemediateAlert},
+	}}.Validate(), "expected step without mismatches to be rejected")
+	assert.Error(t, RemediationPolicy{Steps: []RemediationStep{
+		{Mismatches: 5, Action: RemediateAlert},
+		{Mismatches: 3, Action: RemediateFullSync},
+	}}.Validate(), "expected unordered steps to be rejected")
+	assert.Error(t, RemediationPolicy{Steps: []RemediationStep{
+		{Mismatches: 3, Action: "reboot"},
+	}}.Validate(), "expected unknown action to be rejected")
+	assert.Error(t, RemediationPolicy{Steps: []RemediationStep{
+		{Mismatches: 3, Action: RemediateQuarantine},
+	}}.Validate(), "expected quarantine without a duration to be rejected")
+}
+
+func TestRemediationLadder(t *testing.T) {
+	mockClock := clock.NewMock()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		Remediation: RemediationPolicy{
+			Steps: []RemediationStep{
+				{Mismatches: 2, Action: RemediateAlert},
+				{Mismatches: 3, Action: RemediateQuarantine},
+			},
+			QuarantineDuration: time.Minute,
+		},
+		Clock: mockClock,
+	})
+	defer node.Destroy()
+	node.memberlist.MakeAlive(node.Address(), nowInMillis(mockClock))
+
+	var steps []ChecksumRemediationEvent
+	var converged []ChecksumConvergedEvent
+	node.RegisterListener(ListenerFunc(func(event events.Event) {
+		switch event := event.(type) {
+		case ChecksumRemediationEvent:
+			steps = append(steps, event)
+		case ChecksumConvergedEvent:
+			converged = append(converged, event)
+		}
+	}))
+
+	peer := "127.0.0.1:3002"
+
+	// mismatches below the first step converge silently
+	node.recordMismatch(peer, 1)
+	node.clearMismatches(peer)
+	assert.Empty(t, steps)
+	assert.Empty(t, converged)
+
+	node.recordMismatch(peer, 1)
+	node.recordMismatch(peer, 1)
+	require.Len(t, steps, 1)
+	assert.Equal(t, RemediateAlert, steps[0].Action)
+	assert.Equal(t, 2, steps[0].Mismatches)
+	assert.Equal(t, uint32(1), steps[0].RemoteChecksum)
+
+	node.recordMismatch(peer, 1)
+	require.Len(t, steps, 2)
+	assert.Equal(t, RemediateQuarantine, steps[1].Action)
+	assert.True(t, node.Quarantined(peer))
+
+	// changes from a quarantined peer are ignored, and no steps are taken
+	node.recordMismatch(peer, 1)
+	assert.Len(t, steps, 2)
+
+	mockClock.Add(time.Minute)
+	assert.False(t, node.Quarantined(peer), "expected quarantine to end")
+
+	// the ladder starts over after the quarantine
+	node.recordMismatch(peer, 1)
+	node.recordMismatch(peer, 1)
+	assert.Len(t, steps, 3)
+	node.clearMismatches(peer)
+	assert.Equal(t, []ChecksumConvergedEvent{{Peer: peer, Mismatches: 2}}, converged)
+}
+
+func TestRemediationMerge(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+
+	// each node knows a member the other one does not, and has nothing left
+	// to disseminate
+	tnode.node.memberlist.Update([]Change{{Address: "127.0.0.1:1", Status: Alive, Incarnation: 1}})
+	tpeer.node.memberlist.Update([]Change{{Address: "127.0.0.1:2", Status: Alive, Incarnation: 1}})
+	tnode.node.disseminator.ClearChanges()
+	tpeer.node.disseminator.ClearChanges()
+	tpeer.node.remediation.policy = DefaultRemediationPolicy
+
+	tnode.node.exchangeFullSync(tpeer.node.Address(), true)
+
+	_, ok := tpeer.node.memberlist.Member("127.0.0.1:1")
+	assert.True(t, ok, "expected full membership to be sent to the peer")
+	_, ok = tnode.node.memberlist.Member("127.0.0.1:2")
+	assert.True(t, ok, "expected membership of the peer to be merged")
+	assert.Equal(t, tnode.node.memberlist.Checksum(), tpeer.node.memberlist.Checksum())
+	assert.Zero(t, tpeer.node.remediation.mismatches[tnode.node.Address()],
+		"expected the requested full sync not to count as a mismatch")
+}
+
+func TestRemediationQuarantineIgnoresMerge(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+
+	tpeer.node.memberlist.Update([]Change{{Address: "127.0.0.1:2", Status: Alive, Incarnation: 1}})
+	tpeer.node.disseminator.ClearChanges()
+	tnode.node.remediation.quarantined = map[string]time.Time{
+		tpeer.node.Address(): tnode.node.clock.Now().Add(time.Minute),
+	}
+
+	tnode.node.exchangeFullSync(tpeer.node.Address(), true)
+	_, ok := tnode.node.memberlist.Member("127.0.0.1:2")
+	assert.False(t, ok, "expected membership of a quarantined peer not to be merged")
+
+	tnode.node.memberlist.MakeFaulty(tpeer.node.Address(), tpeer.node.Incarnation())
+	assert.NotContains(t, tnode.node.healTargets(), tpeer.node.Address(),
+		"expected quarantined peers not to be probed for partitions")
+}
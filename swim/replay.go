@@ -0,0 +1,105 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import "fmt"
+
+// Replay feeds captured protocol messages into the node, in order, to
+// reproduce the membership changes the capturing node observed. Inbound
+// requests are handled as if they were received over the network and inbound
+// responses are applied as if they were answers to requests the node sent.
+// Outbound messages are skipped, as they were produced by the capturing node
+// itself. Replay does not make any network calls; ping-reqs only have their
+// piggybacked changes applied, and syncs the memberships they carry merged.
+func Replay(n *Node, messages []CapturedMessage) error {
+	for i, msg := range messages {
+		if msg.Direction != Inbound {
+			continue
+		}
+
+		if err := replayMessage(n, msg); err != nil {
+			return fmt.Errorf("replaying message %d (%s %s): %v", i, msg.Endpoint,
+				msg.Direction, err)
+		}
+	}
+
+	return nil
+}
+
+func replayMessage(n *Node, msg CapturedMessage) error {
+	switch {
+	case msg.Endpoint == "/protocol/ping" && !msg.Response:
+		req, err := decodePing(msg.Body, false)
+		if err != nil {
+			return err
+		}
+		_, err = handlePing(n, req)
+		return err
+
+	case msg.Endpoint == "/protocol/ping" && msg.Response:
+		res, err := decodePing(msg.Body, true)
+		if err != nil {
+			return err
+		}
+		n.memberlist.Update(res.Changes)
+
+	case msg.Endpoint == "/protocol/ping-req" && !msg.Response:
+		req, err := decodePingRequest(msg.Body)
+		if err != nil {
+			return err
+		}
+		n.memberlist.Update(req.Changes)
+
+	case msg.Endpoint == "/protocol/ping-req" && msg.Response:
+		res, err := decodePingResponse(msg.Body)
+		if err != nil {
+			return err
+		}
+		n.memberlist.Update(res.Changes)
+
+	case msg.Endpoint == "/protocol/join" && !msg.Response:
+		req, err := decodeJoinRequest(msg.Body)
+		if err != nil {
+			return err
+		}
+		_, err = handleJoin(n, req)
+		return err
+
+	case msg.Endpoint == "/protocol/join" && msg.Response:
+		res, err := decodeJoinResponse(msg.Body)
+		if err != nil {
+			return err
+		}
+		n.memberlist.AddJoinList(res.Membership)
+
+	case msg.Endpoint == "/protocol/sync":
+		sync, err := decodeSync(msg.Body, msg.Response)
+		if err != nil {
+			return err
+		}
+		n.memberlist.Update(sync.Members)
+
+	default:
+		return fmt.Errorf("unknown endpoint %s", msg.Endpoint)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gl-works/ringpop-go/util"
+)
+
+// snapshotVersion is the version of the snapshot format produced by Snapshot.
+const snapshotVersion = 1
+
+// A Snapshot is the complete gossip state of a node: every member it knows
+// about with its status and incarnation number. Members that are faulty or
+// have left are included, so that a restored node does not resurrect them.
+type Snapshot struct {
+	Version   int       `json:"version"`
+	App       string    `json:"app"`
+	Source    string    `json:"source"`
+	Timestamp time.Time `json:"timestamp"`
+	Checksum  uint32    `json:"checksum"`
+	Members   []Change  `json:"members"`
+}
+
+// Snapshot exports the gossip state of the node. The snapshot can be imported
+// into a node of a different cluster with Restore, for example to start a
+// new cluster with the view of an existing one during a blue/green
+// migration.
+func (n *Node) Snapshot() *Snapshot {
+	members := n.memberlist.GetMembers()
+
+	changes := make([]Change, 0, len(members))
+	for i := range members {
+		changes = append(changes, Change{
+			Address:     members[i].Address,
+			Status:      members[i].Status,
+			Incarnation: members[i].Incarnation,
+			Health:      members[i].Health,
+			Ramp:        members[i].Ramp,
+			Addresses:   members[i].Addresses,
+			Annotations: members[i].Annotations,
+			Labels:      members[i].Labels,
+			JoinedAt:    members[i].JoinedAt,
+		})
+	}
+	sort.Sort(changesByAddress(changes))
+
+	return &Snapshot{
+		Version:   snapshotVersion,
+		App:       n.app,
+		Source:    n.address,
+		Timestamp: n.clock.Now(),
+		Checksum:  n.memberlist.Checksum(),
+		Members:   changes,
+	}
+}
+
+// Restore imports the members of a snapshot into the node's membership.
+// The snapshot may have been taken from a cluster with a different app name;
+// the node keeps its own app name, which makes it the identity of the new
+// cluster. The local member is never taken from the snapshot. The node must
+// be bootstrapped before a snapshot can be restored.
+func (n *Node) Restore(snapshot *Snapshot) error {
+	if !n.Ready() {
+		return ErrNodeNotReady
+	}
+
+	if snapshot.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+
+	local := n.memberlist.local
+	timestamp := util.Timestamp(n.clock.Now())
+
+	var changes []Change
+	for _, member := range snapshot.Members {
+		if member.Address == n.address {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Source:            local.Address,
+			SourceIncarnation: local.Incarnation,
+			Address:           member.Address,
+			Incarnation:       member.Incarnation,
+			Status:            member.Status,
+			Health:            member.Health,
+			Ramp:              member.Ramp,
+			Addresses:         member.Addresses,
+			Annotations:       member.Annotations,
+			Labels:            member.Labels,
+			JoinedAt:          member.JoinedAt,
+			Timestamp:         timestamp,
+		})
+	}
+
+	// restored members are not disseminated, the same as join lists
+	n.memberlist.AddJoinList(changes)
+
+	n.logger.WithField("members", len(changes)).Info("restored gossip snapshot")
+
+	return nil
+}
+
+type changesByAddress []Change
+
+func (c changesByAddress) Len() int           { return len(c) }
+func (c changesByAddress) Less(i, j int) bool { return c[i].Address < c[j].Address }
+func (c changesByAddress) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
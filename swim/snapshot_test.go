@@ -0,0 +1,85 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+
+	faulty := fakeHostPorts(1, 1, 1, 1)[0]
+	tnode.node.memberlist.MakeFaulty(faulty, 1)
+
+	snapshot := tnode.node.Snapshot()
+	assert.Equal(t, "test", snapshot.App)
+	assert.Equal(t, tnode.node.Address(), snapshot.Source)
+	assert.Equal(t, tnode.node.memberlist.Checksum(), snapshot.Checksum)
+	require.Len(t, snapshot.Members, 3)
+
+	// a node of a new cluster, with a different app name
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	require.NoError(t, ch.ListenAndServe("127.0.0.1:0"))
+	restored := NewNode("test-green", ch.PeerInfo().HostPort, ch.GetSubChannel("test"), &Options{
+		Clock: clock.NewMock(),
+	})
+	defer restored.Destroy()
+
+	assert.Equal(t, ErrNodeNotReady, restored.Restore(snapshot), "expected restore to require a bootstrapped node")
+
+	_, err = restored.Bootstrap(&BootstrapOptions{
+		DiscoverProvider: &StaticHostList{[]string{restored.Address()}},
+		Stopped:          true,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, restored.Restore(snapshot))
+	assert.Equal(t, "test-green", restored.App(), "expected node to keep its own identity")
+
+	for _, member := range snapshot.Members {
+		m, ok := restored.memberlist.Member(member.Address)
+		require.True(t, ok, "expected %s to be restored", member.Address)
+		assert.Equal(t, member.Status, m.Status)
+		assert.Equal(t, member.Incarnation, m.Incarnation)
+	}
+
+	local, _ := restored.memberlist.Member(restored.Address())
+	assert.Equal(t, Alive, local.Status, "expected local member to be kept")
+}
+
+func TestRestoreUnsupportedVersion(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+	bootstrapNodes(t, tnode)
+
+	assert.Error(t, tnode.node.Restore(&Snapshot{Version: 0}))
+}
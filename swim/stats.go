@@ -53,6 +53,10 @@ type ProtocolStats struct {
 	ClientRate float64       `json:"clientRate"`
 	ServerRate float64       `json:"serverRate"`
 	TotalRate  float64       `json:"totalRate"`
+
+	// LocalHealth is the local health score of the node, see
+	// Node.LocalHealth.
+	LocalHealth int `json:"localHealth"`
 }
 
 // Timing contains timing information for the SWIM protocol for the node
@@ -95,6 +99,7 @@ func (n *Node) ProtocolStats() ProtocolStats {
 		n.clientRate.Rate1(),
 		n.serverRate.Rate1(),
 		n.totalRate.Rate1(),
+		n.LocalHealth(),
 	}
 }
 
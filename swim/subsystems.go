@@ -0,0 +1,148 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/uber-common/bark"
+)
+
+// A Subsystem is a part of the protocol that can be disabled and enabled at
+// runtime, e.g. to mitigate an incident without restarting the node.
+type Subsystem string
+
+const (
+	// SubsystemSuspicion is the suspicion protocol. While it is disabled,
+	// suspect members are not declared faulty; their suspect periods resume
+	// when it is enabled again, and those of members suspected meanwhile
+	// start then.
+	SubsystemSuspicion Subsystem = "suspicion"
+
+	// SubsystemPartitionHealing is partition healing, see Options.
+	SubsystemPartitionHealing Subsystem = "partition-healing"
+)
+
+// DisseminationSubsystem returns the subsystem that disseminates membership
+// changes with the given status, such as "dissemination.faulty". While it is
+// disabled, changes with the status are not piggybacked on pings; they are
+// kept and disseminated when it is enabled again. Full syncs still include
+// them, and changes about the local member, such as its refutations, are
+// always disseminated.
+func DisseminationSubsystem(status string) Subsystem {
+	return Subsystem("dissemination." + status)
+}
+
+// subsystems returns all subsystems that can be disabled.
+func subsystems() []Subsystem {
+	all := []Subsystem{SubsystemSuspicion, SubsystemPartitionHealing}
+	for _, status := range []string{Alive, Suspect, Faulty, Leave, Tombstone} {
+		all = append(all, DisseminationSubsystem(status))
+	}
+	return all
+}
+
+// subsystemState holds the subsystems that are disabled.
+type subsystemState struct {
+	disabled map[Subsystem]bool
+	sync.RWMutex
+}
+
+// DisableSubsystem disables a subsystem of the node until it is enabled again
+// with EnableSubsystem. It returns an error for unknown subsystems.
+func (n *Node) DisableSubsystem(subsystem Subsystem) error {
+	return n.setSubsystem(subsystem, false)
+}
+
+// EnableSubsystem enables a subsystem that was disabled with
+// DisableSubsystem. It returns an error for unknown subsystems.
+func (n *Node) EnableSubsystem(subsystem Subsystem) error {
+	return n.setSubsystem(subsystem, true)
+}
+
+// SubsystemEnabled returns whether a subsystem is enabled. Subsystems are
+// enabled unless they were disabled with DisableSubsystem.
+func (n *Node) SubsystemEnabled(subsystem Subsystem) bool {
+	n.subsystems.RLock()
+	defer n.subsystems.RUnlock()
+	return !n.subsystems.disabled[subsystem]
+}
+
+// Subsystems returns whether each of the subsystems of the node is enabled.
+func (n *Node) Subsystems() map[Subsystem]bool {
+	n.subsystems.RLock()
+	defer n.subsystems.RUnlock()
+
+	enabled := make(map[Subsystem]bool)
+	for _, subsystem := range subsystems() {
+		enabled[subsystem] = !n.subsystems.disabled[subsystem]
+	}
+	return enabled
+}
+
+// setSubsystem enables or disables a subsystem and emits a
+// SubsystemToggledEvent when its state changed.
+func (n *Node) setSubsystem(subsystem Subsystem, enabled bool) error {
+	known := false
+	for _, s := range subsystems() {
+		if s == subsystem {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown subsystem %q", subsystem)
+	}
+
+	n.subsystems.Lock()
+	if !n.subsystems.disabled[subsystem] == enabled {
+		n.subsystems.Unlock()
+		return nil
+	}
+	if n.subsystems.disabled == nil {
+		n.subsystems.disabled = make(map[Subsystem]bool)
+	}
+	if enabled {
+		delete(n.subsystems.disabled, subsystem)
+	} else {
+		n.subsystems.disabled[subsystem] = true
+	}
+	n.subsystems.Unlock()
+
+	if subsystem == SubsystemSuspicion && !n.Stopped() {
+		if enabled {
+			n.suspicion.Reenable()
+		} else {
+			n.suspicion.Disable()
+		}
+	}
+
+	n.logger.WithFields(log.Fields{
+		"subsystem": subsystem,
+		"enabled":   enabled,
+	}).Warn("toggled subsystem")
+	n.emit(SubsystemToggledEvent{
+		Subsystem: subsystem,
+		Enabled:   enabled,
+	})
+	return nil
+}
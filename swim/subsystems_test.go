@@ -0,0 +1,102 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events"
+)
+
+func TestSubsystems(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+	bootstrapNodes(t, tnode)
+
+	var toggled []SubsystemToggledEvent
+	tnode.node.RegisterListener(ListenerFunc(func(event events.Event) {
+		if event, ok := event.(SubsystemToggledEvent); ok {
+			toggled = append(toggled, event)
+		}
+	}))
+
+	for subsystem, enabled := range tnode.node.Subsystems() {
+		assert.True(t, enabled, "expected %s to be enabled by default", subsystem)
+	}
+	assert.Contains(t, tnode.node.Subsystems(), DisseminationSubsystem(Faulty))
+
+	assert.Error(t, tnode.node.DisableSubsystem("unknown"))
+
+	require.NoError(t, tnode.node.DisableSubsystem(SubsystemSuspicion))
+	require.NoError(t, tnode.node.DisableSubsystem(SubsystemSuspicion))
+	assert.False(t, tnode.node.SubsystemEnabled(SubsystemSuspicion))
+	assert.False(t, tnode.node.Subsystems()[SubsystemSuspicion])
+	assert.False(t, tnode.node.suspicion.enabled, "expected suspicion protocol to be disabled")
+
+	// restarting the node keeps the subsystem disabled
+	tnode.node.Stop()
+	tnode.node.Start()
+	assert.False(t, tnode.node.suspicion.enabled)
+
+	require.NoError(t, tnode.node.EnableSubsystem(SubsystemSuspicion))
+	assert.True(t, tnode.node.suspicion.enabled, "expected suspicion protocol to be enabled")
+
+	assert.Equal(t, []SubsystemToggledEvent{
+		{Subsystem: SubsystemSuspicion, Enabled: false},
+		{Subsystem: SubsystemSuspicion, Enabled: true},
+	}, toggled, "expected an event per change")
+}
+
+func TestDisseminationSubsystem(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+	bootstrapNodes(t, tnode)
+
+	tnode.node.memberlist.MakeAlive("127.0.0.1:3002", 1)
+	tnode.node.memberlist.MakeFaulty("127.0.0.1:3003", 1)
+	tnode.node.disseminator.ClearChanges()
+	tnode.node.memberlist.MakeFaulty("127.0.0.1:3002", 2)
+
+	require.NoError(t, tnode.node.DisableSubsystem(DisseminationSubsystem(Faulty)))
+	changes, _ := tnode.node.disseminator.IssueAsSender()
+	assert.Empty(t, changes, "expected faulty changes not to be disseminated")
+
+	require.NoError(t, tnode.node.EnableSubsystem(DisseminationSubsystem(Faulty)))
+	changes, _ = tnode.node.disseminator.IssueAsSender()
+	require.Len(t, changes, 1, "expected faulty changes to be kept while disabled")
+	assert.Equal(t, "127.0.0.1:3002", changes[0].Address)
+
+	// the local member refutes suspicions even while alive changes are not
+	// disseminated
+	require.NoError(t, tnode.node.DisableSubsystem(DisseminationSubsystem(Alive)))
+	tnode.node.disseminator.ClearChanges()
+	tnode.node.memberlist.Update([]Change{{
+		Address:     tnode.node.Address(),
+		Incarnation: tnode.node.Incarnation(),
+		Status:      Suspect,
+	}})
+	changes, _ = tnode.node.disseminator.IssueAsSender()
+	require.Len(t, changes, 1, "expected the refutation to be disseminated")
+	assert.Equal(t, tnode.node.Address(), changes[0].Address)
+	assert.Equal(t, Alive, changes[0].Status)
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+)
+
+// suspectTracker records since when members have been suspect, so members
+// that stay suspect for longer than the suspect TTL can be re-evaluated.
+type suspectTracker struct {
+	ttl   time.Duration
+	since map[string]time.Time
+
+	// checking is closed when the running check of an expired suspect is
+	// done, and nil if no check is running.
+	checking chan struct{}
+
+	sync.Mutex
+}
+
+// trackSuspect records the member as suspect, keeping the time it first
+// became suspect if it already was.
+func (n *Node) trackSuspect(address string) {
+	if n.suspects.ttl <= 0 {
+		return
+	}
+
+	n.suspects.Lock()
+	if n.suspects.since == nil {
+		n.suspects.since = make(map[string]time.Time)
+	}
+	if _, ok := n.suspects.since[address]; !ok {
+		n.suspects.since[address] = n.clock.Now()
+	}
+	n.suspects.Unlock()
+}
+
+// untrackSuspect stops tracking the member, as its suspicion is resolved.
+func (n *Node) untrackSuspect(address string) {
+	n.suspects.Lock()
+	delete(n.suspects.since, address)
+	n.suspects.Unlock()
+}
+
+// expiredSuspect returns the address of a member that has been suspect for
+// longer than the suspect TTL, and for how long it has been suspect.
+func (n *Node) expiredSuspect() (string, time.Duration, bool) {
+	n.suspects.Lock()
+	defer n.suspects.Unlock()
+
+	now := n.clock.Now()
+	for address, since := range n.suspects.since {
+		if suspected := now.Sub(since); suspected >= n.suspects.ttl {
+			return address, suspected, true
+		}
+	}
+
+	return "", 0, false
+}
+
+// checkSuspectTTL re-evaluates a member that has been suspect for longer
+// than the suspect TTL by probing it directly. This is a safety net for
+// suspicions whose timer got lost. A member that does not respond is
+// declared faulty; a member that does respond but remains suspect gets a new
+// suspect period. The member is probed in the background, so the protocol
+// period is not held up by the ping, and one member is probed at a time.
+func (n *Node) checkSuspectTTL() {
+	if n.suspects.ttl <= 0 {
+		return
+	}
+
+	address, suspected, ok := n.expiredSuspect()
+	if !ok {
+		return
+	}
+
+	incarnation, suspect := n.suspectIncarnation(address)
+	if !suspect {
+		n.untrackSuspect(address)
+		return
+	}
+
+	n.suspects.Lock()
+	if n.suspects.checking != nil {
+		n.suspects.Unlock()
+		n.logger.WithField("suspect", address).Debug("suspect ttl check already running")
+		return
+	}
+	checking := make(chan struct{})
+	n.suspects.checking = checking
+	n.suspects.Unlock()
+
+	go func() {
+		defer func() {
+			n.suspects.Lock()
+			n.suspects.checking = nil
+			n.suspects.Unlock()
+			close(checking)
+		}()
+
+		n.probeExpiredSuspect(address, suspected, incarnation)
+	}()
+}
+
+// suspectIncarnation returns the incarnation of the member and whether it is
+// suspect, read under the lock of the member as a running check may change
+// it.
+func (n *Node) suspectIncarnation(address string) (int64, bool) {
+	member, ok := n.memberlist.Member(address)
+	if !ok {
+		return 0, false
+	}
+
+	member.RLock()
+	defer member.RUnlock()
+	return member.Incarnation, member.Status == Suspect
+}
+
+// probeExpiredSuspect pings a member whose suspect TTL expired and declares
+// it faulty or starts a new suspect period depending on the response.
+func (n *Node) probeExpiredSuspect(address string, suspected time.Duration, incarnation int64) {
+	res, err := sendPing(n, address, n.pingTimeout)
+	reachable := err == nil
+
+	n.emit(SuspectTTLExpiredEvent{
+		Address:   address,
+		Duration:  suspected,
+		Reachable: reachable,
+	})
+
+	n.logger.WithFields(log.Fields{
+		"suspect":   address,
+		"duration":  suspected,
+		"reachable": reachable,
+	}).Warn("member exceeded suspect TTL")
+
+	if !reachable {
+		if n.confirmFaulty(address, incarnation) {
+			n.memberlist.MakeFaulty(address, incarnation)
+			return
+		}
+
+		// the arbiter vetoed, check the member again after another TTL
+		n.untrackSuspect(address)
+		n.trackSuspect(address)
+		return
+	}
+
+	n.memberlist.Update(res.Changes)
+
+	incarnation, suspect := n.suspectIncarnation(address)
+	if !suspect {
+		return
+	}
+
+	// the member is reachable but did not refute the suspicion yet, start a
+	// new suspect period to give it time to do so
+	n.untrackSuspect(address)
+	n.trackSuspect(address)
+	change := Change{Address: address, Incarnation: incarnation}
+	n.suspicion.Stop(change)
+	n.suspicion.Start(change)
+}
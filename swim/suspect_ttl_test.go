@@ -0,0 +1,167 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/context"
+)
+
+type SuspectTTLTestSuite struct {
+	suite.Suite
+	tnode, tpeer *testNode
+	node         *Node
+	clock        *clock.Mock
+}
+
+func (s *SuspectTTLTestSuite) SetupTest() {
+	s.tnode = newChannelNode(s.T())
+	s.tpeer = newChannelNode(s.T())
+	s.node = s.tnode.node
+	s.clock = s.node.clock.(*clock.Mock)
+
+	// bootstrap the peer first so that the node learns about it when joining
+	bootstrapNodes(s.T(), s.tpeer, s.tnode)
+
+	s.node.suspects.ttl = time.Minute
+	s.node.pingTimeout = 10 * time.Millisecond
+}
+
+func (s *SuspectTTLTestSuite) TearDownTest() {
+	destroyNodes(s.tnode, s.tpeer)
+}
+
+// checkSuspectTTL checks the suspect TTL and waits for the check to finish.
+func (s *SuspectTTLTestSuite) checkSuspectTTL() {
+	s.node.checkSuspectTTL()
+
+	s.node.suspects.Lock()
+	checking := s.node.suspects.checking
+	s.node.suspects.Unlock()
+
+	if checking != nil {
+		<-checking
+	}
+}
+
+func (s *SuspectTTLTestSuite) TestSuspectTTLDisabledByDefault() {
+	node := NewNode("test", "127.0.0.1:3001", nil, nil)
+	defer node.Destroy()
+	s.Zero(node.suspects.ttl, "expected suspect ttl to be disabled")
+
+	node = NewNode("test", "127.0.0.1:3001", nil, &Options{
+		SuspicionTimeout: time.Minute,
+		SuspectTTL:       time.Second,
+	})
+	defer node.Destroy()
+	s.Equal(time.Minute, node.suspects.ttl, "expected suspect ttl to be at least the suspicion timeout")
+}
+
+func (s *SuspectTTLTestSuite) TestUnreachableSuspectBecomesFaulty() {
+	address := fakeHostPorts(1, 1, 1, 1)[0]
+	s.node.memberlist.MakeAlive(address, 1)
+	s.node.memberlist.MakeSuspect(address, 1)
+
+	// simulate a lost suspicion timer
+	s.node.suspicion.Stop(Change{Address: address})
+
+	s.checkSuspectTTL()
+	member, _ := s.node.memberlist.Member(address)
+	s.Equal(Suspect, member.Status, "expected member to stay suspect before ttl expires")
+
+	s.clock.Add(time.Minute)
+	s.checkSuspectTTL()
+
+	member, _ = s.node.memberlist.Member(address)
+	s.Equal(Faulty, member.Status, "expected unreachable member to be declared faulty")
+
+	_, _, ok := s.node.expiredSuspect()
+	s.False(ok, "expected faulty member to no longer be tracked")
+}
+
+func (s *SuspectTTLTestSuite) TestReachableSuspectGetsNewSuspectPeriod() {
+	address := s.tpeer.node.Address()
+	member, ok := s.node.memberlist.Member(address)
+	s.Require().True(ok, "expected peer to be a member")
+	s.node.memberlist.MakeSuspect(address, member.Incarnation)
+
+	s.node.suspicion.Stop(Change{Address: address})
+	s.Nil(s.node.suspicion.Timer(address), "expected suspicion timer to be lost")
+
+	s.clock.Add(time.Minute)
+	s.checkSuspectTTL()
+
+	member, _ = s.node.memberlist.Member(address)
+	s.NotEqual(Faulty, member.Status, "expected reachable member not to be declared faulty")
+	if member.Status == Suspect {
+		s.NotNil(s.node.suspicion.Timer(address), "expected a new suspicion timer")
+		_, _, ok := s.node.expiredSuspect()
+		s.False(ok, "expected suspect period to be restarted")
+	}
+}
+
+// hangingTransport is a Transport whose pings are not answered.
+type hangingTransport struct {
+	Transport
+}
+
+func (hangingTransport) SendPing(ctx context.Context, address string, req []byte) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *SuspectTTLTestSuite) TestSuspectCheckedInBackground() {
+	address := s.tpeer.node.Address()
+	member, ok := s.node.memberlist.Member(address)
+	s.Require().True(ok, "expected peer to be a member")
+	s.node.memberlist.MakeSuspect(address, member.Incarnation)
+	s.node.suspicion.Stop(Change{Address: address})
+
+	s.node.transport = hangingTransport{s.node.transport}
+	s.node.pingTimeout = time.Second
+	s.clock.Add(time.Minute)
+
+	start := time.Now()
+	s.node.checkSuspectTTL()
+	s.True(time.Since(start) < s.node.pingTimeout, "expected the suspect to be pinged in the background")
+
+	s.node.suspects.Lock()
+	checking := s.node.suspects.checking
+	s.node.suspects.Unlock()
+	s.Require().NotNil(checking, "expected a check to be running")
+
+	s.node.checkSuspectTTL()
+	s.node.suspects.Lock()
+	s.True(checking == s.node.suspects.checking, "expected one check to run at a time")
+	s.node.suspects.Unlock()
+
+	<-checking
+	member, _ = s.node.memberlist.Member(address)
+	s.Equal(Faulty, member.Status, "expected unreachable member to be declared faulty")
+}
+
+func TestSuspectTTLTestSuite(t *testing.T) {
+	suite.Run(t, new(SuspectTTLTestSuite))
+}
@@ -21,18 +21,61 @@
 package swim
 
 import (
+	"math"
 	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	log "github.com/uber-common/bark"
 	"github.com/gl-works/ringpop-go/logging"
 )
 
+// A SuspicionTimeoutFunc returns the suspicion timeout for a cluster of the
+// given number of reachable members.
+type SuspicionTimeoutFunc func(clusterSize int) time.Duration
+
+// LogScaledSuspicionTimeout returns a SuspicionTimeoutFunc that scales the
+// base timeout by the base 10 logarithm of the cluster size, and uses the base
+// timeout for clusters of up to 10 members. Suspicions take longer to
+// disseminate to all members of larger clusters, which would otherwise flap
+// with a timeout tuned for small ones.
+func LogScaledSuspicionTimeout(base time.Duration) SuspicionTimeoutFunc {
+	return func(clusterSize int) time.Duration {
+		scale := math.Max(1, math.Log10(float64(clusterSize)))
+		return time.Duration(scale * float64(base))
+	}
+}
+
 type suspect interface {
 	address() string
 	incarnation() int64
 }
 
+// suspectTimer is a running suspect period
+type suspectTimer struct {
+	suspect  suspect
+	timer    *clock.Timer
+	deadline time.Time
+
+	// confirmations is the number of suspect periods of a member in a
+	// maintenance window that expired before this one
+	confirmations int
+
+	// suspectors are the members known to suspect the member, if the period
+	// can be shortened by their confirmations, and the period shrinks from
+	// max to min since it started as they arrive
+	suspectors map[string]bool
+	started    time.Time
+	min, max   time.Duration
+}
+
+// suspendedSuspect is a suspect period that was interrupted by disabling the
+// suspicion protocol
+type suspendedSuspect struct {
+	suspect   suspect
+	remaining time.Duration
+}
+
 // Suspicion handles the suspicion sub-protocol of the SWIM protocol
 type suspicion struct {
 	sync.Mutex
@@ -40,19 +83,45 @@ type suspicion struct {
 	node *Node
 
 	timeout time.Duration
-	timers  map[string]*time.Timer
+	timers  map[string]*suspectTimer
+
+	// timeoutFunc, if set, computes the timeout from the cluster size
+	// instead
+	timeoutFunc SuspicionTimeoutFunc
+
 	enabled bool
 	logger  log.Logger
+
+	// suspended holds the suspect periods interrupted by Disable, which are
+	// resumed by Reenable
+	suspended map[string]suspendedSuspect
+
+	// restartOnReenable makes Reenable start suspended suspect periods over
+	// with the full timeout instead of resuming them
+	restartOnReenable bool
+
+	// maintenanceFactor multiplies the timeout of members in a maintenance
+	// window, which are declared faulty after maintenanceConfirmations
+	// additional suspect periods
+	maintenanceFactor        int
+	maintenanceConfirmations int
+
+	// expectedConfirmations is the number of independent confirmations
+	// after which a suspect period that started at maxTimeoutFactor times
+	// the timeout has shrunk to the timeout. Zero disables confirmations.
+	expectedConfirmations int
+	maxTimeoutFactor      int
 }
 
 // newSuspicion returns a new suspicion SWIM sub-protocol with the given timeout
 func newSuspicion(n *Node, timeout time.Duration) *suspicion {
 	suspicion := &suspicion{
-		node:    n,
-		timeout: timeout,
-		timers:  make(map[string]*time.Timer),
-		enabled: true,
-		logger:  logging.Logger("suspicion").WithField("local", n.Address()),
+		node:      n,
+		timeout:   timeout,
+		timers:    make(map[string]*suspectTimer),
+		suspended: make(map[string]suspendedSuspect),
+		enabled:   true,
+		logger:    logging.Logger("suspicion").WithField("local", n.Address()),
 	}
 
 	return suspicion
@@ -60,13 +129,20 @@ func newSuspicion(n *Node, timeout time.Duration) *suspicion {
 
 func (s *suspicion) Start(suspect suspect) {
 	s.withLock(func() {
-		if !s.enabled {
-			s.logger.Warn("cannot start suspect period while disabled")
+		if s.node.Address() == suspect.address() {
+			s.logger.Warn("cannot start suspect period for local member")
 			return
 		}
 
-		if s.node.Address() == suspect.address() {
-			s.logger.Warn("cannot start suspect period for local member")
+		if !s.enabled {
+			// the suspect period starts when the protocol is reenabled
+			if _, ok := s.suspended[suspect.address()]; !ok {
+				s.suspended[suspect.address()] = suspendedSuspect{
+					suspect:   suspect,
+					remaining: s.suspectTimeout(suspect.address()),
+				}
+			}
+			s.logger.WithField("suspect", suspect.address()).Debug("suspended member suspect period while disabled")
 			return
 		}
 
@@ -75,28 +151,64 @@ func (s *suspicion) Start(suspect suspect) {
 			return
 		}
 
-		s.timers[suspect.address()] = time.AfterFunc(s.timeout, func() {
-			s.logger.WithField("faulty", suspect.address()).Info("member declared faulty")
-			s.node.memberlist.MakeFaulty(suspect.address(), suspect.incarnation())
-		})
+		if s.expectedConfirmations > 0 {
+			s.startConfirmedTimer(suspect, s.suspectTimeout(suspect.address()))
+		} else {
+			s.startTimer(suspect, s.suspectTimeout(suspect.address()))
+		}
 
 		s.logger.WithField("suspect", suspect.address()).Debug("started member suspect period")
 	})
 }
 
+// startTimer starts a suspect period that declares the suspect faulty after
+// the timeout. It should be called while holding the lock.
+func (s *suspicion) startTimer(suspect suspect, timeout time.Duration) *suspectTimer {
+	t := &suspectTimer{
+		suspect:  suspect,
+		deadline: s.node.clock.Now().Add(timeout),
+	}
+	t.timer = s.node.clock.AfterFunc(timeout, func() {
+		if s.deferFaulty(t) || !s.node.confirmFaulty(suspect.address(), suspect.incarnation()) {
+			s.restartTimer(t)
+			return
+		}
+
+		s.logger.WithField("faulty", suspect.address()).Info("member declared faulty")
+		s.node.memberlist.MakeFaulty(suspect.address(), suspect.incarnation())
+	})
+	s.timers[suspect.address()] = t
+	return t
+}
+
+// restartTimer starts the suspect period of an expired timer over, unless the
+// suspect period was stopped in the meantime.
+func (s *suspicion) restartTimer(t *suspectTimer) {
+	s.withLock(func() {
+		if s.timers[t.suspect.address()] != t {
+			return
+		}
+		restarted := s.startTimer(t.suspect, s.suspectTimeout(t.suspect.address()))
+		restarted.confirmations = t.confirmations + 1
+	})
+}
+
 func (s *suspicion) Stop(suspect suspect) {
 	s.Lock()
 
-	if timer, ok := s.timers[suspect.address()]; ok {
-		timer.Stop()
+	if t, ok := s.timers[suspect.address()]; ok {
+		t.timer.Stop()
 		delete(s.timers, suspect.address())
 		s.logger.WithField("suspect", suspect.address()).Debug("stopped member suspect period")
 	}
 
+	delete(s.suspended, suspect.address())
+
 	s.Unlock()
 }
 
-// reenable suspicion protocol
+// reenable suspicion protocol and resume the suspect periods that were
+// suspended when it was disabled
 func (s *suspicion) Reenable() {
 	s.Lock()
 
@@ -107,11 +219,23 @@ func (s *suspicion) Reenable() {
 	}
 
 	s.enabled = true
+
+	numResumed := len(s.suspended)
+	for address, suspended := range s.suspended {
+		timeout := suspended.remaining
+		if s.restartOnReenable {
+			timeout = s.suspectTimeout(address)
+		}
+		s.startTimer(suspended.suspect, timeout)
+		delete(s.suspended, address)
+	}
+
 	s.Unlock()
-	s.logger.Info("reenabled suspicion protocol")
+	s.logger.WithField("timersResumed", numResumed).Info("reenabled suspicion protocol")
 }
 
-// stop all suspicion timers and disables suspicion protocol
+// stop all suspicion timers and disables suspicion protocol. The remaining
+// time of every suspect period is kept so that it can be resumed by Reenable.
 func (s *suspicion) Disable() {
 	s.Lock()
 
@@ -123,9 +247,19 @@ func (s *suspicion) Disable() {
 
 	s.enabled = false
 
+	now := s.node.clock.Now()
 	numTimers := len(s.timers)
-	for address, timer := range s.timers {
-		timer.Stop()
+	for address, t := range s.timers {
+		if t.timer.Stop() {
+			remaining := t.deadline.Sub(now)
+			if remaining < 0 {
+				remaining = 0
+			}
+			s.suspended[address] = suspendedSuspect{
+				suspect:   t.suspect,
+				remaining: remaining,
+			}
+		}
 		delete(s.timers, address)
 	}
 
@@ -133,11 +267,22 @@ func (s *suspicion) Disable() {
 	s.logger.WithField("timersStopped", numTimers).Info("disabled suspicion protocol")
 }
 
+// baseTimeout returns the timeout of a suspect period before it is adjusted
+// for the local health of the node and maintenance windows.
+func (s *suspicion) baseTimeout() time.Duration {
+	if s.timeoutFunc == nil {
+		return s.timeout
+	}
+	return s.timeoutFunc(s.node.memberlist.CountReachableMembers())
+}
+
 // testing func to avoid data races
-func (s *suspicion) Timer(address string) *time.Timer {
-	var rv *time.Timer
+func (s *suspicion) Timer(address string) *clock.Timer {
+	var rv *clock.Timer
 	s.withLock(func() {
-		rv = s.timers[address]
+		if t, ok := s.timers[address]; ok {
+			rv = t.timer
+		}
 	})
 	return rv
 }
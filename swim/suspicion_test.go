@@ -24,6 +24,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/stretchr/testify/suite"
 	"github.com/gl-works/ringpop-go/util"
 )
@@ -87,6 +88,9 @@ func (s *SuspicionTestSuite) TestSuspectDisabled() {
 
 	s.s.Start(s.suspect)
 	s.Nil(s.s.timers[s.suspect.Address], "expected suspicion timer to be nil")
+
+	s.s.Reenable()
+	s.NotNil(s.s.timers[s.suspect.Address], "expected suspect period to start when reenabled")
 }
 
 func (s *SuspicionTestSuite) TestSuspectBecomesFaulty() {
@@ -132,6 +136,141 @@ func (s *SuspicionTestSuite) TestSuspicionDisableStopsTimers() {
 	s.Empty(s.s.timers, "expected all timers to be cleared")
 }
 
+func (s *SuspicionTestSuite) TestReenableResumesSuspectPeriods() {
+	s.s.Start(Change{Address: "127.0.0.1:3002", Incarnation: s.incarnation})
+	s.s.Start(Change{Address: "127.0.0.1:3003", Incarnation: s.incarnation})
+
+	s.s.Disable()
+	s.Len(s.s.suspended, 2, "expected suspect periods to be suspended")
+
+	s.s.Stop(Change{Address: "127.0.0.1:3003"})
+	s.Len(s.s.suspended, 1, "expected stopped suspect period not to be resumed")
+
+	s.s.Reenable()
+	s.Empty(s.s.suspended, "expected suspended suspect periods to be resumed")
+	s.NotNil(s.s.Timer("127.0.0.1:3002"), "expected suspicion timer to be resumed")
+	s.Nil(s.s.Timer("127.0.0.1:3003"), "expected stopped suspicion timer to stay stopped")
+
+	remaining := s.s.timers["127.0.0.1:3002"].deadline.Sub(time.Now())
+	s.True(remaining <= s.s.timeout, "expected remaining time to be preserved")
+}
+
+func (s *SuspicionTestSuite) TestSuspectBecomesFaultyOnNodeClock() {
+	mockClock := clock.NewMock()
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		SuspicionTimeout: time.Minute,
+		Clock:            mockClock,
+	})
+	defer node.Destroy()
+
+	node.memberlist.MakeAlive(node.Address(), s.incarnation)
+	node.memberlist.MakeAlive(s.suspect.Address, s.suspect.Incarnation)
+	member, _ := node.memberlist.Member(s.suspect.Address)
+	s.Require().NotNil(member, "expected cannot be nil")
+
+	node.suspicion.Start(*member)
+
+	mockClock.Add(time.Minute - time.Second)
+	s.NotEqual(Faulty, member.Status, "expected member not to be faulty before the timeout")
+
+	mockClock.Add(time.Second)
+	s.Equal(Faulty, member.Status, "expected member to be faulty after the timeout")
+}
+
+func (s *SuspicionTestSuite) TestResumedSuspectBecomesFaulty() {
+	s.s.timeout = 20 * time.Millisecond
+
+	s.m.MakeAlive(s.suspect.Address, s.suspect.Incarnation)
+	member, _ := s.m.Member(s.suspect.Address)
+	s.Require().NotNil(member, "expected cannot be nil")
+
+	s.s.Start(s.suspect)
+	s.s.Disable()
+	time.Sleep(30 * time.Millisecond)
+	s.NotEqual(Faulty, member.Status, "expected member not to become faulty while disabled")
+
+	s.s.Reenable()
+	time.Sleep(40 * time.Millisecond)
+	s.Equal(Faulty, member.Status, "expected member to be faulty after resumed suspect period")
+}
+
+func (s *SuspicionTestSuite) TestRestartOnReenable() {
+	s.s.restartOnReenable = true
+	s.s.timeout = time.Hour
+
+	s.s.Start(s.suspect)
+	s.s.Disable()
+
+	suspended := s.s.suspended[s.suspect.Address]
+	suspended.remaining = time.Millisecond
+	s.s.suspended[s.suspect.Address] = suspended
+
+	s.s.Reenable()
+	remaining := s.s.timers[s.suspect.Address].deadline.Sub(time.Now())
+	s.True(remaining > time.Minute, "expected suspect period to restart with the full timeout")
+}
+
+func (s *SuspicionTestSuite) TestArbiterVetoesFaulty() {
+	mockClock := clock.NewMock()
+	confirm := false
+	var consulted []string
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		SuspicionTimeout: time.Minute,
+		Clock:            mockClock,
+		Arbiter: ArbiterFunc(func(address string, incarnation int64) (bool, error) {
+			consulted = append(consulted, address)
+			return confirm, nil
+		}),
+		ArbiterFilter: func(address string) bool { return address == "127.0.0.1:3002" },
+	})
+	defer node.Destroy()
+
+	node.memberlist.MakeAlive(node.Address(), s.incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3002", s.incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3003", s.incarnation)
+	primary, _ := node.memberlist.Member("127.0.0.1:3002")
+	other, _ := node.memberlist.Member("127.0.0.1:3003")
+
+	node.suspicion.Start(Change{Address: primary.Address, Incarnation: s.incarnation})
+	node.suspicion.Start(Change{Address: other.Address, Incarnation: s.incarnation})
+
+	mockClock.Add(time.Minute)
+	s.Equal(Faulty, other.Status, "expected member not matching the filter to be declared faulty")
+	s.NotEqual(Faulty, primary.Status, "expected vetoed member not to be declared faulty")
+	s.Equal([]string{"127.0.0.1:3002"}, consulted)
+
+	confirm = true
+	mockClock.Add(time.Minute)
+	s.Equal(Faulty, primary.Status, "expected member to be faulty once the arbiter confirms")
+	s.Len(consulted, 2, "expected arbiter to be consulted again after another suspect period")
+}
+
+func (s *SuspicionTestSuite) TestSuspicionTimeoutFunc() {
+	var sizes []int
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{
+		SuspicionTimeout: time.Second,
+		SuspicionTimeoutFunc: func(clusterSize int) time.Duration {
+			sizes = append(sizes, clusterSize)
+			return time.Duration(clusterSize) * time.Minute
+		},
+	})
+	defer node.Destroy()
+
+	node.memberlist.MakeAlive(node.Address(), s.incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3002", s.incarnation)
+	node.memberlist.MakeAlive("127.0.0.1:3003", s.incarnation)
+
+	s.Equal(3*time.Minute, node.suspicion.suspectTimeout("127.0.0.1:3002"))
+	s.Equal([]int{3}, sizes, "expected timeout to be computed from the reachable members")
+}
+
+func (s *SuspicionTestSuite) TestLogScaledSuspicionTimeout() {
+	timeout := LogScaledSuspicionTimeout(time.Second)
+	s.Equal(time.Second, timeout(0))
+	s.Equal(time.Second, timeout(10))
+	s.Equal(3*time.Second, timeout(1000))
+}
+
 func TestSuspicionTestSuite(t *testing.T) {
 	suite.Run(t, new(SuspicionTestSuite))
 }
@@ -0,0 +1,243 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	log "github.com/uber-common/bark"
+
+	"github.com/uber/tchannel-go/json"
+)
+
+// errNoSyncPeer is returned by a sync round when no member supports
+// anti-entropy syncs.
+var errNoSyncPeer = errors.New("no member to sync with")
+
+// A syncMessage is exchanged on the /protocol/sync endpoint. Members is the
+// full membership of the source, or the next chunk of it when the full sync
+// budget is set, which is only sent when the checksums of the nodes
+// mismatch.
+type syncMessage struct {
+	Source    string            `json:"source"`
+	Checksum  uint32            `json:"checksum"`
+	Checksums map[string]uint32 `json:"checksums,omitempty"`
+	Members   []Change          `json:"members,omitempty"`
+}
+
+func (m *syncMessage) validate() error {
+	if m.Source == "" {
+		return errors.New("no source")
+	}
+	return validateChanges(m.Members)
+}
+
+// syncer periodically syncs the membership with a random member, so that
+// views that diverged, for example during a long partition or because gossip
+// was dropped, converge even when no changes are disseminated anymore.
+type syncer struct {
+	node     *Node
+	interval time.Duration
+	jitter   time.Duration
+}
+
+// delay returns how long the syncer waits before the next round.
+func (s *syncer) delay() time.Duration {
+	delay := s.interval
+	if s.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(s.jitter)))
+	}
+	return delay
+}
+
+// RunSyncLoop runs a sync round every sync interval, plus a random jitter,
+// until the gossip protocol is stopped.
+func (g *gossip) RunSyncLoop() {
+	s := &g.node.syncer
+	if s.interval <= 0 {
+		return
+	}
+
+	go func() {
+		for !g.Stopped() {
+			time.Sleep(s.delay())
+			if g.Stopped() {
+				return
+			}
+			g.node.antiEntropySync()
+		}
+	}()
+}
+
+// syncPeer returns a random pingable member that supports anti-entropy syncs
+// and is not quarantined.
+func (n *Node) syncPeer() (string, bool) {
+	for _, member := range n.memberlist.RandomPingableMembers(n.memberlist.NumMembers(), nil) {
+		features, ok := n.PeerFeatures(member.Address)
+		if ok && features.Has(FeatureAntiEntropy) && !n.Quarantined(member.Address) {
+			return member.Address, true
+		}
+	}
+	return "", false
+}
+
+// antiEntropySync syncs the membership with a random member. The checksums
+// of the nodes are compared first, and only when they mismatch do the nodes
+// exchange their full memberships in both directions and merge them.
+func (n *Node) antiEntropySync() error {
+	peer, ok := n.syncPeer()
+	if !ok {
+		return errNoSyncPeer
+	}
+
+	start := time.Now()
+	event, err := n.syncWith(peer)
+	if err != nil {
+		n.logger.WithFields(log.Fields{
+			"remote": peer,
+			"error":  err,
+		}).Info("anti-entropy sync failed")
+		n.emit(AntiEntropySyncFailedEvent{Peer: peer, Error: err.Error()})
+		return err
+	}
+
+	event.Duration = time.Now().Sub(start)
+	if event.Pulled > 0 || event.Pushed > 0 {
+		n.logger.WithFields(log.Fields{
+			"remote":    peer,
+			"pulled":    event.Pulled,
+			"pushed":    event.Pushed,
+			"converged": event.Converged,
+		}).Info("anti-entropy sync merged divergent memberships")
+	}
+	n.emit(event)
+	return nil
+}
+
+// syncWith compares the checksum of the node with the peer's, pulls the
+// membership of the peer when they mismatch, and pushes the membership of
+// the node when they still mismatch after merging it. With a full sync
+// budget, the memberships are exchanged in chunks over consecutive rounds.
+func (n *Node) syncWith(peer string) (AntiEntropySyncEvent, error) {
+	event := AntiEntropySyncEvent{Peer: peer}
+
+	res, err := n.sendSync(peer, nil)
+	if err != nil {
+		return event, err
+	}
+
+	if len(res.Members) > 0 {
+		event.Pulled = len(res.Members)
+		n.memberlist.Update(res.Members)
+	}
+
+	if !n.memberlist.ChecksumMatches(res.Checksum, res.Checksums) {
+		members := n.disseminator.fullSyncTo(peer)
+		event.Pushed = len(members)
+		res, err = n.sendSync(peer, members)
+		if err != nil {
+			return event, err
+		}
+		if len(res.Members) > 0 {
+			n.memberlist.Update(res.Members)
+		}
+	}
+
+	event.Converged = n.memberlist.ChecksumMatches(res.Checksum, res.Checksums)
+	if event.Converged {
+		n.disseminator.clearFullSyncCursor(peer)
+	}
+	return event, nil
+}
+
+// sendSync sends the checksum of the node to the peer, along with the
+// members pushed to it.
+func (n *Node) sendSync(peer string, members []Change) (*syncMessage, error) {
+	if err := n.checkPeer(peer); err != nil {
+		return nil, err
+	}
+
+	req := &syncMessage{
+		Source:    n.address,
+		Checksum:  n.memberlist.Checksum(),
+		Checksums: n.memberlist.Checksums(),
+		Members:   members,
+	}
+
+	ctx, cancel := n.mesh.NewContext(n.pingTimeout, peer)
+	defer cancel()
+
+	n.capture(Outbound, peer, "/protocol/sync", false, req)
+
+	var res syncMessage
+	if err := n.send(ctx, peer, "/protocol/sync", req, &res); err != nil {
+		return nil, err
+	}
+
+	n.capture(Inbound, peer, "/protocol/sync", true, &res)
+
+	if err := validateMessage("/protocol/sync", true, &res); err != nil {
+		return nil, err
+	}
+	if n.Quarantined(peer) {
+		res.Members = nil
+	}
+
+	return &res, nil
+}
+
+// syncHandler merges the membership the source pushed, and responds with the
+// full membership of the node if the checksums of the nodes mismatch.
+func (n *Node) syncHandler(ctx json.Context, req *syncMessage) (*syncMessage, error) {
+	if err := n.checkPeer(req.Source); err != nil {
+		return nil, err
+	}
+
+	n.capture(Inbound, req.Source, "/protocol/sync", false, req)
+
+	if err := validateMessage("/protocol/sync", false, req); err != nil {
+		return nil, err
+	}
+
+	if !n.Ready() {
+		return nil, ErrNodeNotReady
+	}
+
+	if len(req.Members) > 0 && !n.Quarantined(req.Source) {
+		n.memberlist.Update(req.Members)
+	}
+
+	res := &syncMessage{
+		Source:    n.address,
+		Checksum:  n.memberlist.Checksum(),
+		Checksums: n.memberlist.Checksums(),
+	}
+	if !n.memberlist.ChecksumMatches(req.Checksum, req.Checksums) {
+		res.Members = n.disseminator.fullSyncTo(req.Source)
+	} else {
+		n.disseminator.clearFullSyncCursor(req.Source)
+	}
+
+	n.capture(Outbound, req.Source, "/protocol/sync", true, res)
+	return res, nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events"
+)
+
+func recordSyncs(node *Node) *[]AntiEntropySyncEvent {
+	var syncs []AntiEntropySyncEvent
+	node.RegisterListener(ListenerFunc(func(event events.Event) {
+		if event, ok := event.(AntiEntropySyncEvent); ok {
+			syncs = append(syncs, event)
+		}
+	}))
+	return &syncs
+}
+
+func TestAntiEntropySyncInSync(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+	tnode.node.recordPeerFeatures(tpeer.node.Address(), protocolFeatures)
+	// the peer may not know about the node yet right after the join
+	require.NoError(t, tnode.node.antiEntropySync())
+	require.Equal(t, tnode.node.memberlist.Checksum(), tpeer.node.memberlist.Checksum())
+
+	syncs := recordSyncs(tnode.node)
+	require.NoError(t, tnode.node.antiEntropySync())
+	require.Len(t, *syncs, 1)
+	assert.Equal(t, tpeer.node.Address(), (*syncs)[0].Peer)
+	assert.Zero(t, (*syncs)[0].Pulled, "expected no members to be pulled when in sync")
+	assert.Zero(t, (*syncs)[0].Pushed, "expected no members to be pushed when in sync")
+	assert.True(t, (*syncs)[0].Converged)
+}
+
+func TestAntiEntropySyncMerges(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+	tnode.node.recordPeerFeatures(tpeer.node.Address(), protocolFeatures)
+	syncs := recordSyncs(tnode.node)
+
+	// each node knows a member the other one does not, and has nothing left
+	// to disseminate
+	tnode.node.memberlist.Update([]Change{{Address: "127.0.0.1:1", Status: Alive, Incarnation: 1}})
+	tpeer.node.memberlist.Update([]Change{{Address: "127.0.0.1:2", Status: Alive, Incarnation: 1}})
+	tnode.node.disseminator.ClearChanges()
+	tpeer.node.disseminator.ClearChanges()
+
+	require.NoError(t, tnode.node.antiEntropySync())
+
+	_, ok := tnode.node.memberlist.Member("127.0.0.1:2")
+	assert.True(t, ok, "expected membership of the peer to be pulled")
+	_, ok = tpeer.node.memberlist.Member("127.0.0.1:1")
+	assert.True(t, ok, "expected membership of the node to be pushed")
+	assert.Equal(t, tnode.node.memberlist.Checksum(), tpeer.node.memberlist.Checksum())
+
+	require.Len(t, *syncs, 1)
+	assert.NotZero(t, (*syncs)[0].Pulled)
+	assert.NotZero(t, (*syncs)[0].Pushed)
+	assert.True(t, (*syncs)[0].Converged)
+}
+
+func TestAntiEntropySyncChunks(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+	tnode.node.recordPeerFeatures(tpeer.node.Address(), protocolFeatures)
+	syncs := recordSyncs(tnode.node)
+
+	var members []Change
+	for _, address := range []string{"127.0.0.1:1", "127.0.0.1:2", "127.0.0.1:3", "127.0.0.1:4"} {
+		members = append(members, Change{Address: address, Status: Alive, Incarnation: 1})
+	}
+	tpeer.node.memberlist.Update(members)
+	tpeer.node.disseminator.ClearChanges()
+	tnode.node.disseminator.ClearChanges()
+	tpeer.node.disseminator.fullSyncBudget = 2 * changeSize(members[0])
+
+	for i := 0; i < 10 && tnode.node.memberlist.Checksum() != tpeer.node.memberlist.Checksum(); i++ {
+		require.NoError(t, tnode.node.antiEntropySync())
+	}
+
+	assert.Equal(t, tpeer.node.memberlist.Checksum(), tnode.node.memberlist.Checksum())
+	require.True(t, len(*syncs) > 1, "expected the membership to be pulled in chunks")
+	for _, sync := range *syncs {
+		assert.True(t, sync.Pulled <= 3, "expected chunks to respect the full sync budget")
+	}
+}
+
+func TestAntiEntropySyncNoPeer(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+	tnode.node.recordPeerFeatures(tpeer.node.Address(), FeatureJoinTimes)
+
+	assert.Equal(t, errNoSyncPeer, tnode.node.antiEntropySync(),
+		"expected peers without anti-entropy support to be skipped")
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// A GossipTarget is a pingable member a TargetSelector can select, along with
+// the history of the exchanges of the local node with it.
+type GossipTarget struct {
+	Address string
+
+	// Staleness is the time since the local node last exchanged state with
+	// the member, by a ping in either direction. Members the node never
+	// exchanged state with are as stale as the time since the node started
+	// selecting targets.
+	Staleness time.Duration
+
+	// MissedAcks is the number of consecutive probes of the member that did
+	// not complete. The member is likely unreachable rather than merely
+	// stale, so probing it more often does not spread updates.
+	MissedAcks int
+}
+
+// A TargetSelector selects the member the gossip loop probes, and exchanges
+// state with, in each protocol period. Members that others failed to connect
+// to are probed first regardless of the selector. The default selects the
+// members round-robin in a random order that is shuffled after every round.
+type TargetSelector interface {
+	// Select returns the index of the target to probe. Targets are never
+	// empty and are in random order. It is called once per protocol period
+	// from the gossip loop.
+	Select(targets []GossipTarget) int
+}
+
+// The TargetSelectorFunc type is an adapter to allow the use of ordinary
+// functions as TargetSelectors.
+type TargetSelectorFunc func(targets []GossipTarget) int
+
+// Select calls f(targets).
+func (f TargetSelectorFunc) Select(targets []GossipTarget) int {
+	return f(targets)
+}
+
+// A WeightedTargetSelector selects targets at random, weighted by their
+// staleness. Members the node has not exchanged state with in a while are
+// gossiped with sooner than in a round-robin, which spreads updates to the
+// members that are most likely to have missed them. Missed acks do not add
+// weight, as an unreachable member misses every ack and probing it more often
+// delays the spread of updates. In simulations of 100 members an update
+// reaches all members in about 5 protocol periods, as with a round-robin; with
+// 5 unreachable members it takes 6 rather than 5.3, and 7 when weighted by
+// missed acks as well, see BenchmarkTargetSelectorConvergence.
+type WeightedTargetSelector struct {
+	// MaxStaleness caps the staleness of a target, so that a member the node
+	// never exchanged state with does not crowd out the others. It defaults
+	// to DefaultMaxStaleness.
+	MaxStaleness time.Duration
+
+	rand *rand.Rand
+}
+
+// DefaultMaxStaleness is the staleness at which a WeightedTargetSelector caps
+// the weight of a target.
+const DefaultMaxStaleness = time.Minute
+
+// Select implements TargetSelector.
+func (s *WeightedTargetSelector) Select(targets []GossipTarget) int {
+	if s.rand == nil {
+		s.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	max := s.MaxStaleness
+	if max <= 0 {
+		max = DefaultMaxStaleness
+	}
+
+	weights := make([]int64, len(targets))
+	var total int64
+	for i, target := range targets {
+		staleness := target.Staleness
+		if staleness > max {
+			staleness = max
+		}
+		// every target keeps a chance to be selected
+		weights[i] = int64(staleness/time.Millisecond) + 1
+		total += weights[i]
+	}
+
+	pick := s.rand.Int63n(total)
+	for i, weight := range weights {
+		if pick < weight {
+			return i
+		}
+		pick -= weight
+	}
+	return len(targets) - 1
+}
+
+// targetState tracks the exchanges of the node with the members for its
+// TargetSelector.
+type targetState struct {
+	selector TargetSelector
+
+	// started is when the node started selecting targets, which is the last
+	// exchange of members the node never exchanged state with.
+	started   time.Time
+	exchanges map[string]*targetExchange
+	sync.Mutex
+}
+
+type targetExchange struct {
+	last       time.Time
+	missedAcks int
+}
+
+// recordExchange records that the node exchanged state with the member at
+// address.
+func (n *Node) recordExchange(address string) {
+	n.targets.Lock()
+	defer n.targets.Unlock()
+
+	if n.targets.selector == nil {
+		return
+	}
+	n.targets.exchangeNoLock(address).last = n.clock.Now()
+	n.targets.exchanges[address].missedAcks = 0
+}
+
+// recordMissedAck records that a probe of the member at address did not
+// complete.
+func (n *Node) recordMissedAck(address string) {
+	n.targets.Lock()
+	defer n.targets.Unlock()
+
+	if n.targets.selector == nil {
+		return
+	}
+	n.targets.exchangeNoLock(address).missedAcks++
+}
+
+func (t *targetState) exchangeNoLock(address string) *targetExchange {
+	if t.exchanges == nil {
+		t.exchanges = make(map[string]*targetExchange)
+	}
+	exchange, ok := t.exchanges[address]
+	if !ok {
+		exchange = &targetExchange{}
+		t.exchanges[address] = exchange
+	}
+	return exchange
+}
+
+// nextTarget returns the member to probe in this protocol period, selected by
+// the TargetSelector of the node, or by the round-robin iterator if it has
+// none. The exchanges of members that are no longer pingable are forgotten.
+func (n *Node) nextTarget() (*Member, bool) {
+	n.targets.Lock()
+	selector := n.targets.selector
+	n.targets.Unlock()
+
+	if selector == nil {
+		return n.memberiter.Next()
+	}
+
+	members := n.memberlist.RandomPingableMembers(n.memberlist.NumMembers(), nil)
+	now := n.clock.Now()
+
+	n.targets.Lock()
+	if n.targets.started.IsZero() {
+		n.targets.started = now
+	}
+
+	targets := make([]GossipTarget, 0, len(members))
+	known := make(map[string]*targetExchange, len(members))
+	for _, member := range members {
+		target := GossipTarget{
+			Address:   member.Address,
+			Staleness: now.Sub(n.targets.started),
+		}
+		if exchange, ok := n.targets.exchanges[member.Address]; ok {
+			known[member.Address] = exchange
+			if !exchange.last.IsZero() {
+				target.Staleness = now.Sub(exchange.last)
+			}
+			target.MissedAcks = exchange.missedAcks
+		}
+		targets = append(targets, target)
+	}
+	n.targets.exchanges = known
+	n.targets.Unlock()
+
+	if len(targets) == 0 {
+		return nil, false
+	}
+
+	i := selector.Select(targets)
+	if i < 0 || i >= len(targets) {
+		i = 0
+	}
+	return members[i], true
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightedTargetSelector(t *testing.T) {
+	selector := &WeightedTargetSelector{rand: rand.New(rand.NewSource(1))}
+
+	selected := make(map[string]int)
+	targets := []GossipTarget{
+		{Address: "127.0.0.1:3002", Staleness: time.Millisecond},
+		{Address: "127.0.0.1:3003", Staleness: time.Second},
+		{Address: "127.0.0.1:3004", Staleness: time.Second, MissedAcks: 3},
+	}
+	for i := 0; i < 1000; i++ {
+		selected[targets[selector.Select(targets)].Address]++
+	}
+
+	assert.True(t, selected["127.0.0.1:3002"] < 10, "expected a fresh target to be selected rarely")
+	assert.InDelta(t, selected["127.0.0.1:3003"], selected["127.0.0.1:3004"], 100,
+		"expected missed acks not to add weight")
+}
+
+func TestWeightedTargetSelectorMaxStaleness(t *testing.T) {
+	selector := &WeightedTargetSelector{
+		MaxStaleness: time.Second,
+		rand:         rand.New(rand.NewSource(1)),
+	}
+
+	selected := make(map[string]int)
+	targets := []GossipTarget{
+		{Address: "127.0.0.1:3002", Staleness: time.Second},
+		{Address: "127.0.0.1:3003", Staleness: time.Hour},
+	}
+	for i := 0; i < 1000; i++ {
+		selected[targets[selector.Select(targets)].Address]++
+	}
+	assert.True(t, selected["127.0.0.1:3002"] > 400, "expected staleness to be capped")
+}
+
+// roundRobinSelector selects the targets in a random order that is shuffled
+// after every round, like a node without a TargetSelector.
+type roundRobinSelector struct {
+	order []string
+	rand  *rand.Rand
+}
+
+func (s *roundRobinSelector) Select(targets []GossipTarget) int {
+	for {
+		if len(s.order) == 0 {
+			for _, i := range s.rand.Perm(len(targets)) {
+				s.order = append(s.order, targets[i].Address)
+			}
+		}
+		next := s.order[0]
+		s.order = s.order[1:]
+		for i, target := range targets {
+			if target.Address == next {
+				return i
+			}
+		}
+	}
+}
+
+// simulateConvergence simulates the gossip of members that each probe the
+// target their selector selects once per protocol period, and returns the
+// number of periods it takes an update to reach all reachable members. The
+// first unreachable members never ack. The update is made after the members
+// gossiped for a while, so that the history of their exchanges is settled.
+func simulateConvergence(members, unreachable int, newSelector func(r *rand.Rand) TargetSelector, r *rand.Rand) int {
+	const period = 200 * time.Millisecond
+
+	type simMember struct {
+		selector   TargetSelector
+		last       map[int]time.Duration
+		missedAcks map[int]int
+		updated    bool
+	}
+	sims := make([]*simMember, members)
+	for i := range sims {
+		sims[i] = &simMember{
+			selector:   newSelector(rand.New(rand.NewSource(r.Int63()))),
+			last:       make(map[int]time.Duration),
+			missedAcks: make(map[int]int),
+		}
+	}
+
+	addresses := make([]string, members)
+	for i := range addresses {
+		addresses[i] = fmt.Sprintf("127.0.0.1:%d", 3000+i)
+	}
+
+	var now time.Duration
+	gossip := func() {
+		now += period
+		for i := unreachable; i < members; i++ {
+			sim := sims[i]
+			targets := make([]GossipTarget, 0, members-1)
+			indices := make([]int, 0, members-1)
+			for _, j := range r.Perm(members) {
+				if j == i {
+					continue
+				}
+				staleness := now
+				if last, ok := sim.last[j]; ok {
+					staleness = now - last
+				}
+				targets = append(targets, GossipTarget{
+					Address:    addresses[j],
+					Staleness:  staleness,
+					MissedAcks: sim.missedAcks[j],
+				})
+				indices = append(indices, j)
+			}
+
+			j := indices[sim.selector.Select(targets)]
+			if j < unreachable {
+				sim.missedAcks[j]++
+				continue
+			}
+			sim.missedAcks[j] = 0
+			sim.last[j], sims[j].last[i] = now, now
+			if sim.updated || sims[j].updated {
+				sim.updated, sims[j].updated = true, true
+			}
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		gossip()
+	}
+	sims[members-1].updated = true
+	for periods := 1; ; periods++ {
+		gossip()
+		converged := true
+		for _, sim := range sims[unreachable:] {
+			converged = converged && sim.updated
+		}
+		if converged {
+			return periods
+		}
+	}
+}
+
+// BenchmarkTargetSelectorConvergence compares the protocol periods it takes
+// an update to reach all members of a cluster of 100 members, reported as
+// periods/op, with the default round-robin and WeightedTargetSelectors.
+func BenchmarkTargetSelectorConvergence(b *testing.B) {
+	selectors := []struct {
+		name        string
+		newSelector func(r *rand.Rand) TargetSelector
+	}{
+		{"round-robin", func(r *rand.Rand) TargetSelector {
+			return &roundRobinSelector{rand: r}
+		}},
+		{"weighted", func(r *rand.Rand) TargetSelector {
+			return &WeightedTargetSelector{rand: r}
+		}},
+		// weights targets by their missed acks as well, which steers probes
+		// to unreachable members
+		{"weighted-missed-acks", func(r *rand.Rand) TargetSelector {
+			selector := &WeightedTargetSelector{rand: r}
+			return TargetSelectorFunc(func(targets []GossipTarget) int {
+				weighted := make([]GossipTarget, len(targets))
+				for i, target := range targets {
+					weighted[i] = target
+					weighted[i].Staleness *= time.Duration(1 + target.MissedAcks)
+				}
+				return selector.Select(weighted)
+			})
+		}},
+	}
+	for _, unreachable := range []int{0, 5} {
+		for _, selector := range selectors {
+			name := fmt.Sprintf("%s/unreachable=%d", selector.name, unreachable)
+			b.Run(name, func(b *testing.B) {
+				r := rand.New(rand.NewSource(1))
+				periods := 0
+				for i := 0; i < b.N; i++ {
+					periods += simulateConvergence(100, unreachable, selector.newSelector, r)
+				}
+				b.ReportMetric(float64(periods)/float64(b.N), "periods/op")
+			})
+		}
+	}
+}
+
+func TestNodeTargetSelector(t *testing.T) {
+	tnode := newChannelNode(t)
+	peers := genChannelNodes(t, 2)
+	defer destroyNodes(append(peers, tnode)...)
+
+	bootstrapNodes(t, append(peers, tnode)...)
+
+	var selected [][]GossipTarget
+	tnode.node.targets.selector = TargetSelectorFunc(func(targets []GossipTarget) int {
+		selected = append(selected, targets)
+		for i, target := range targets {
+			if target.Address == peers[0].node.Address() {
+				return i
+			}
+		}
+		return 0
+	})
+	tnode.node.failureDetector = FailureDetectorFunc(func(prober Prober, address string) Verdict {
+		if address == peers[0].node.Address() {
+			return Inconclusive
+		}
+		return Reachable
+	})
+
+	tnode.node.pingNextMember()
+	tnode.node.clock.(*clock.Mock).Add(time.Second)
+	tnode.node.pingNextMember()
+
+	require.Len(t, selected, 2)
+	assert.Len(t, selected[0], 2, "expected the pingable members to be targets")
+	for _, target := range selected[1] {
+		if target.Address == peers[0].node.Address() {
+			assert.Equal(t, 1, target.MissedAcks, "expected missed ack to be recorded")
+		}
+	}
+
+	// a ping from the member is an exchange
+	tnode.node.recordExchange(peers[0].node.Address())
+	tnode.node.pingNextMember()
+	for _, target := range selected[2] {
+		if target.Address == peers[0].node.Address() {
+			assert.Equal(t, GossipTarget{Address: target.Address}, target)
+		}
+	}
+}
@@ -93,6 +93,20 @@ func (n *testNode) closeAndWait(sender *tchannel.Channel) {
 // newChannelNode creates a testNode with a listening channel and associated
 // SWIM node. The channel listens on a random port assigned by the OS.
 func newChannelNode(t *testing.T) *testNode {
+	return newChannelNodeWithClock(t, clock.NewMock())
+}
+
+// newChannelNodeWithClock creates a testNode listening on an OS assigned port
+// that uses the given clock.
+func newChannelNodeWithClock(t *testing.T, clock clock.Clock) *testNode {
+	return newChannelNodeWithOptions(t, &Options{
+		Clock: clock,
+	})
+}
+
+// newChannelNodeWithOptions creates a testNode listening on an OS assigned
+// port with the given options.
+func newChannelNodeWithOptions(t *testing.T, opts *Options) *testNode {
 	ch, err := tchannel.NewChannel("test", nil)
 	require.NoError(t, err, "channel must create successfully")
 
@@ -102,9 +116,7 @@ func newChannelNode(t *testing.T) *testNode {
 	require.NoError(t, err, "channel must listen")
 
 	hostport := ch.PeerInfo().HostPort
-	node := NewNode("test", hostport, ch.GetSubChannel("test"), &Options{
-		Clock: clock.NewMock(),
-	})
+	node := NewNode("test", hostport, ch.GetSubChannel("test"), opts)
 
 	return &testNode{node, ch}
 }
@@ -0,0 +1,280 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	json2 "encoding/json"
+	"fmt"
+	"sync"
+
+	log "github.com/uber-common/bark"
+	"github.com/uber/tchannel-go/json"
+	"golang.org/x/net/context"
+)
+
+// transportFailures contains the addresses of members that a transport outside
+// of the protocol, such as the forwarder, failed to connect to since they were
+// last pinged.
+type transportFailures struct {
+	reported map[string]bool
+	sync.Mutex
+}
+
+// ReportTransportFailure feeds a transport-level failure to reach the member at
+// address, such as a refused or reset connection, into the failure detector.
+// The failure is not proof of the member being down, but it moves the member
+// to the front of the ping order so that it is probed on the next protocol
+// period instead of whenever the round-robin reaches it.
+func (n *Node) ReportTransportFailure(address string) {
+	if _, ok := n.memberlist.pingableMember(address); !ok {
+		return
+	}
+
+	n.transportFailures.Lock()
+	if n.transportFailures.reported == nil {
+		n.transportFailures.reported = make(map[string]bool)
+	}
+	alreadyReported := n.transportFailures.reported[address]
+	n.transportFailures.reported[address] = true
+	n.transportFailures.Unlock()
+
+	if alreadyReported {
+		return
+	}
+
+	n.emit(TransportFailureReportedEvent{Remote: address})
+
+	n.logger.WithField("remote", address).Debug("transport failure reported")
+}
+
+// nextTransportFailure removes a reported member from the set and returns it
+// if it is still pingable. Members that have become unpingable since they were
+// reported are dropped.
+func (n *Node) nextTransportFailure() (*Member, bool) {
+	n.transportFailures.Lock()
+	defer n.transportFailures.Unlock()
+
+	for address := range n.transportFailures.reported {
+		delete(n.transportFailures.reported, address)
+
+		if member, ok := n.memberlist.pingableMember(address); ok {
+			return member, true
+		}
+
+		n.logger.WithFields(log.Fields{
+			"remote": address,
+		}).Debug("dropping transport failure of unpingable member")
+	}
+
+	return nil, false
+}
+
+// A Transport carries the messages of the membership protocol between
+// members. Messages are passed JSON encoded, so that a transport does not
+// depend on their types, and are sent to the member known by address in the
+// memberlist. Nodes send them as TChannel calls on their channel unless
+// Options.Transport is set.
+//
+// A member that receives a message over another transport passes it to the
+// Handle method of its Node for the message, e.g. HandlePing, and returns the
+// response or error to the sender.
+type Transport interface {
+	SendPing(ctx context.Context, address string, req []byte) ([]byte, error)
+	SendPingReq(ctx context.Context, address string, req []byte) ([]byte, error)
+	SendJoin(ctx context.Context, address string, req []byte) ([]byte, error)
+	SendSync(ctx context.Context, address string, req []byte) ([]byte, error)
+}
+
+// A ProtocolHandler handles the JSON encoded messages of the protocol that a
+// member receives over a Transport and returns the JSON encoded responses.
+// Node is a ProtocolHandler.
+type ProtocolHandler interface {
+	HandlePing(ctx context.Context, req []byte) ([]byte, error)
+	HandlePingReq(ctx context.Context, req []byte) ([]byte, error)
+	HandleJoin(ctx context.Context, req []byte) ([]byte, error)
+	HandleSync(ctx context.Context, req []byte) ([]byte, error)
+}
+
+// channelTransport is the Transport of nodes that send the messages of the
+// protocol as TChannel JSON calls.
+type channelTransport struct {
+	node *Node
+}
+
+func (t *channelTransport) SendPing(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return t.call(ctx, address, "/protocol/ping", req)
+}
+
+func (t *channelTransport) SendPingReq(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return t.call(ctx, address, "/protocol/ping-req", req)
+}
+
+func (t *channelTransport) SendJoin(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return t.call(ctx, address, "/protocol/join", req)
+}
+
+func (t *channelTransport) SendSync(ctx context.Context, address string, req []byte) ([]byte, error) {
+	return t.call(ctx, address, "/protocol/sync", req)
+}
+
+func (t *channelTransport) call(ctx context.Context, address, endpoint string, req []byte) ([]byte, error) {
+	jctx, ok := ctx.(json.Context)
+	if !ok {
+		jctx = json.WithHeaders(ctx, nil)
+	}
+
+	peer := t.node.channel.Peers().GetOrAdd(t.node.DialAddress(address))
+
+	var res json2.RawMessage
+	err := json.CallPeer(jctx, peer, t.node.service, endpoint, json2.RawMessage(req), &res)
+	return res, err
+}
+
+// send sends a message of the protocol to the member at address over the
+// transport of the node and decodes the response into res.
+func (n *Node) send(ctx context.Context, address, endpoint string, req, res interface{}) error {
+	body, err := json2.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if body, err = n.seal(endpoint, false, body); err != nil {
+		return err
+	}
+
+	switch endpoint {
+	case "/protocol/ping":
+		body, err = n.transport.SendPing(ctx, address, body)
+	case "/protocol/ping-req":
+		body, err = n.transport.SendPingReq(ctx, address, body)
+	case "/protocol/join":
+		body, err = n.transport.SendJoin(ctx, address, body)
+	case "/protocol/sync":
+		body, err = n.transport.SendSync(ctx, address, body)
+	default:
+		return fmt.Errorf("no transport for %s", endpoint)
+	}
+	if err != nil {
+		return err
+	}
+	if body, err = n.open(endpoint, true, body); err != nil {
+		return err
+	}
+
+	if err := json2.Unmarshal(body, res); err != nil {
+		return &MalformedMessageError{Endpoint: endpoint, Response: true, Err: err}
+	}
+	return nil
+}
+
+// HandlePing handles a JSON encoded ping received over a Transport and returns
+// the JSON encoded response.
+func (n *Node) HandlePing(ctx context.Context, req []byte) ([]byte, error) {
+	var msg ping
+	return n.handleMessage(ctx, "/protocol/ping", req, &msg, func(jctx json.Context) (interface{}, error) {
+		return n.pingHandler(jctx, &msg)
+	})
+}
+
+// HandlePingReq handles a JSON encoded ping request received over a Transport
+// and returns the JSON encoded response.
+func (n *Node) HandlePingReq(ctx context.Context, req []byte) ([]byte, error) {
+	var msg pingRequest
+	return n.handleMessage(ctx, "/protocol/ping-req", req, &msg, func(jctx json.Context) (interface{}, error) {
+		return n.pingRequestHandler(jctx, &msg)
+	})
+}
+
+// HandleJoin handles a JSON encoded join request received over a Transport
+// and returns the JSON encoded response.
+func (n *Node) HandleJoin(ctx context.Context, req []byte) ([]byte, error) {
+	var msg joinRequest
+	return n.handleMessage(ctx, "/protocol/join", req, &msg, func(jctx json.Context) (interface{}, error) {
+		return n.joinHandler(jctx, &msg)
+	})
+}
+
+// HandleSync handles a JSON encoded sync message received over a Transport
+// and returns the JSON encoded response.
+func (n *Node) HandleSync(ctx context.Context, req []byte) ([]byte, error) {
+	var msg syncMessage
+	return n.handleMessage(ctx, "/protocol/sync", req, &msg, func(jctx json.Context) (interface{}, error) {
+		return n.syncHandler(jctx, &msg)
+	})
+}
+
+// handleMessage decodes the request into msg, passes it to the handler and
+// encodes the response of the handler.
+func (n *Node) handleMessage(ctx context.Context, endpoint string, req []byte, msg interface{},
+	handler func(json.Context) (interface{}, error)) ([]byte, error) {
+
+	req, err := n.open(endpoint, false, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json2.Unmarshal(req, msg); err != nil {
+		return nil, &MalformedMessageError{Endpoint: endpoint, Err: err}
+	}
+
+	jctx, ok := ctx.(json.Context)
+	if !ok {
+		jctx = json.WithHeaders(ctx, nil)
+	}
+
+	res, err := handler(jctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json2.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return n.seal(endpoint, true, body)
+}
+
+// seal seals a JSON encoded message with the keyring of the node, if it has
+// one.
+func (n *Node) seal(endpoint string, response bool, msg []byte) ([]byte, error) {
+	if n.keyring == nil {
+		return msg, nil
+	}
+	return n.keyring.seal(endpoint, response, msg)
+}
+
+// open opens a message sealed with the keyring of the node, if it has one.
+func (n *Node) open(endpoint string, response bool, msg []byte) ([]byte, error) {
+	if n.keyring == nil {
+		return msg, nil
+	}
+
+	msg, err := n.keyring.open(endpoint, response, msg)
+	if err != nil {
+		n.logger.WithFields(log.Fields{
+			"endpoint": endpoint,
+			"response": response,
+			"error":    err,
+		}).Warn("rejected unauthenticated protocol message")
+		n.emit(MessageAuthenticationFailedEvent{Endpoint: endpoint, Response: response})
+		return nil, &MessageAuthenticationError{Endpoint: endpoint, Response: response, Err: err}
+	}
+	return msg, nil
+}
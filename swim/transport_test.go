@@ -0,0 +1,174 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events/test/mocks"
+	"golang.org/x/net/context"
+)
+
+func TestReportTransportFailure(t *testing.T) {
+	tnode := newChannelNode(t)
+	tpeer := newChannelNode(t)
+	defer destroyNodes(tnode, tpeer)
+
+	bootstrapNodes(t, tpeer, tnode)
+
+	listener := &mocks.EventListener{}
+	listener.On("HandleEvent", TransportFailureReportedEvent{Remote: tpeer.node.Address()}).Return()
+	tnode.node.RegisterListener(listener)
+
+	// unknown members and the local member are ignored
+	tnode.node.ReportTransportFailure("192.0.2.1:1")
+	tnode.node.ReportTransportFailure(tnode.node.Address())
+
+	// repeated reports are collapsed until the member has been probed
+	tnode.node.ReportTransportFailure(tpeer.node.Address())
+	tnode.node.ReportTransportFailure(tpeer.node.Address())
+	listener.AssertNumberOfCalls(t, "HandleEvent", 1)
+
+	member, ok := tnode.node.nextTransportFailure()
+	require.True(t, ok, "expected reported member to be probed next")
+	assert.Equal(t, tpeer.node.Address(), member.Address)
+
+	_, ok = tnode.node.nextTransportFailure()
+	assert.False(t, ok, "expected reported member to be probed once")
+}
+
+func TestTransportFailureSuspectedNextPeriod(t *testing.T) {
+	tnode := newChannelNode(t)
+	thelper := newChannelNode(t)
+	tdead := newChannelNode(t)
+	defer destroyNodes(tnode, thelper, tdead)
+
+	bootstrapNodes(t, thelper, tdead, tnode)
+	tdead.Destroy()
+
+	tnode.node.ReportTransportFailure(tdead.node.Address())
+	tnode.node.pingNextMember()
+
+	member, ok := tnode.node.memberlist.Member(tdead.node.Address())
+	require.True(t, ok)
+	assert.Equal(t, Suspect, member.Status, "expected reported member to be suspected on the next protocol period")
+}
+
+// memoryTransport passes the messages of the protocol directly to the nodes
+// it knows.
+type memoryTransport struct {
+	nodes map[string]*Node
+}
+
+func (t *memoryTransport) node(address string) (*Node, error) {
+	node, ok := t.nodes[address]
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	return node, nil
+}
+
+func (t *memoryTransport) SendPing(ctx context.Context, address string, req []byte) ([]byte, error) {
+	node, err := t.node(address)
+	if err != nil {
+		return nil, err
+	}
+	return node.HandlePing(ctx, req)
+}
+
+func (t *memoryTransport) SendPingReq(ctx context.Context, address string, req []byte) ([]byte, error) {
+	node, err := t.node(address)
+	if err != nil {
+		return nil, err
+	}
+	return node.HandlePingReq(ctx, req)
+}
+
+func (t *memoryTransport) SendJoin(ctx context.Context, address string, req []byte) ([]byte, error) {
+	node, err := t.node(address)
+	if err != nil {
+		return nil, err
+	}
+	return node.HandleJoin(ctx, req)
+}
+
+func (t *memoryTransport) SendSync(ctx context.Context, address string, req []byte) ([]byte, error) {
+	node, err := t.node(address)
+	if err != nil {
+		return nil, err
+	}
+	return node.HandleSync(ctx, req)
+}
+
+func TestMemoryTransport(t *testing.T) {
+	transport := &memoryTransport{nodes: make(map[string]*Node)}
+	var nodes []*Node
+	for _, address := range []string{"192.0.2.1:1", "192.0.2.2:1", "192.0.2.3:1"} {
+		node := NewNode("test", address, nil, &Options{
+			Clock:     clock.NewMock(),
+			Transport: transport,
+		})
+		defer node.Destroy()
+		transport.nodes[address] = node
+		nodes = append(nodes, node)
+	}
+
+	for _, node := range nodes {
+		_, err := node.Bootstrap(&BootstrapOptions{
+			DiscoverProvider: &StaticHostList{[]string{"192.0.2.1:1"}},
+			Stopped:          true,
+		})
+		require.NoError(t, err, "expected node to join over the transport")
+	}
+	assert.Len(t, nodes[1].GetReachableMembers(), 2, "expected joined node to learn about the seed")
+
+	// pings carry the changes of the joined nodes to the seed, and a sync
+	// the full membership of the seed back
+	for _, node := range nodes[1:] {
+		_, err := sendPing(node, "192.0.2.1:1", time.Second)
+		require.NoError(t, err)
+	}
+	assert.Len(t, nodes[0].GetReachableMembers(), 3, "expected seed to learn about the joined nodes")
+
+	_, err := nodes[1].syncWith("192.0.2.1:1")
+	require.NoError(t, err)
+	assert.Len(t, nodes[1].GetReachableMembers(), 3, "expected nodes to converge")
+
+	reached, errs := indirectPing(nodes[0], "192.0.2.2:1", 1, time.Second)
+	assert.True(t, reached, "expected indirect ping to reach the target")
+	assert.Empty(t, errs)
+
+	_, err = sendPing(nodes[0], "192.0.2.4:1", time.Second)
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestHandleMalformedMessage(t *testing.T) {
+	node := NewNode("test", "192.0.2.1:1", nil, nil)
+	defer node.Destroy()
+
+	_, err := node.HandlePing(context.Background(), []byte("{"))
+	assert.IsType(t, &MalformedMessageError{}, err)
+}
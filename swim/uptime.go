@@ -0,0 +1,73 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import "time"
+
+// backfillJoinTime fills in the join time of a change that does not carry one,
+// because it was sent by a member that does not gossip join times. The join
+// time the member is known by is kept, and members that are first seen alive,
+// or seen alive again after they left or were declared faulty, are dated to
+// now. It must be called while the members are locked; member is nil for
+// members that are not known yet.
+func (m *memberlist) backfillJoinTime(member *Member, change Change) Change {
+	if change.JoinedAt != 0 {
+		return change
+	}
+
+	if member != nil && member.JoinedAt != 0 &&
+		(member.isReachable() || change.Status != Alive) {
+		change.JoinedAt = member.JoinedAt
+	} else if change.Status == Alive {
+		change.JoinedAt = nowInMillis(m.node.clock)
+	}
+
+	return change
+}
+
+// JoinedAt returns when the member at address joined the cluster, as gossiped
+// by the member itself or, for members that do not gossip it, when this node
+// first saw it alive. Ok is false if the member or its join time is not known.
+func (n *Node) JoinedAt(address string) (joinedAt time.Time, ok bool) {
+	member, ok := n.memberlist.Member(address)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	member.RLock()
+	ms := member.JoinedAt
+	member.RUnlock()
+
+	if ms == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), true
+}
+
+// MemberUptime returns how long ago the member at address joined the cluster.
+// Ok is false if the member or its join time is not known.
+func (n *Node) MemberUptime(address string) (uptime time.Duration, ok bool) {
+	joinedAt, ok := n.JoinedAt(address)
+	if !ok {
+		return 0, false
+	}
+	return n.clock.Now().Sub(joinedAt), true
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalJoinTime(t *testing.T) {
+	tclock := clock.NewMock()
+	tclock.Add(time.Hour)
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{Clock: tclock})
+	defer node.Destroy()
+
+	_, ok := node.JoinedAt("127.0.0.1:3001")
+	assert.False(t, ok, "expected unknown member to have no join time")
+
+	node.memberlist.MakeAlive("127.0.0.1:3001", nowInMillis(tclock))
+	joinedAt, ok := node.JoinedAt("127.0.0.1:3001")
+	require.True(t, ok)
+	assert.Equal(t, tclock.Now().UnixNano(), joinedAt.UnixNano())
+
+	// refuting a suspicion keeps the join time of the local member
+	tclock.Add(time.Minute)
+	node.memberlist.MakeSuspect("127.0.0.1:3001", node.Incarnation())
+	uptime, ok := node.MemberUptime("127.0.0.1:3001")
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, uptime)
+}
+
+func TestGossipedJoinTime(t *testing.T) {
+	tclock := clock.NewMock()
+	tclock.Add(time.Hour)
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{Clock: tclock})
+	defer node.Destroy()
+	node.memberlist.MakeAlive("127.0.0.1:3001", nowInMillis(tclock))
+
+	joinedAt := tclock.Now().Add(-time.Minute)
+	node.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive,
+		Incarnation: 1, JoinedAt: joinedAt.UnixNano() / int64(time.Millisecond)}})
+
+	uptime, ok := node.MemberUptime("127.0.0.1:3002")
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, uptime, "expected gossiped join time")
+
+	// changes from members that do not gossip join times keep it
+	node.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Suspect, Incarnation: 1}})
+	uptime, _ = node.MemberUptime("127.0.0.1:3002")
+	assert.Equal(t, time.Minute, uptime)
+}
+
+func TestBackfilledJoinTime(t *testing.T) {
+	tclock := clock.NewMock()
+	tclock.Add(time.Hour)
+	node := NewNode("test", "127.0.0.1:3001", nil, &Options{Clock: tclock})
+	defer node.Destroy()
+	node.memberlist.MakeAlive("127.0.0.1:3001", nowInMillis(tclock))
+
+	// members that do not gossip their join time are dated to when they
+	// were first seen alive
+	node.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive, Incarnation: 1}})
+	tclock.Add(time.Minute)
+	node.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive, Incarnation: 2}})
+
+	uptime, ok := node.MemberUptime("127.0.0.1:3002")
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, uptime)
+
+	// and again when they are revived
+	node.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Faulty, Incarnation: 2}})
+	tclock.Add(time.Minute)
+	node.memberlist.Update([]Change{{Address: "127.0.0.1:3002", Status: Alive, Incarnation: 3}})
+
+	uptime, _ = node.MemberUptime("127.0.0.1:3002")
+	assert.Equal(t, time.Duration(0), uptime, "expected revived member to be dated anew")
+
+	// members first seen faulty have no join time
+	node.memberlist.Update([]Change{{Address: "127.0.0.1:3003", Status: Faulty, Incarnation: 1}})
+	_, ok = node.JoinedAt("127.0.0.1:3003")
+	assert.False(t, ok)
+}
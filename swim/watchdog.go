@@ -0,0 +1,108 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"time"
+
+	log "github.com/uber-common/bark"
+)
+
+// A WatchdogFunc is called by the watchdog when the protocol period loop of
+// the node has not run for the given duration, for example because it is
+// starved or deadlocked. It can log, record a metric, panic or evict the
+// node from the cluster, so a wedged node does not silently remain a member.
+type WatchdogFunc func(stalled time.Duration)
+
+// watchdog detects a stalled protocol period loop.
+type watchdog struct {
+	periods  int
+	callback WatchdogFunc
+
+	// fired is set when the watchdog fired for the current stall, so it
+	// fires only once until the loop runs again.
+	fired bool
+}
+
+// stallThreshold returns how long the protocol period loop may not run
+// before the watchdog fires.
+func (g *gossip) stallThreshold() time.Duration {
+	rate := g.ProtocolRate()
+	if rate < g.minProtocolPeriod {
+		rate = g.minProtocolPeriod
+	}
+	return time.Duration(g.watchdog.periods) * rate
+}
+
+// RunWatchdogLoop checks whether the protocol period loop stalled until the
+// gossip protocol is stopped.
+func (g *gossip) RunWatchdogLoop() {
+	if g.watchdog.periods <= 0 {
+		return
+	}
+
+	g.protocol.Lock()
+	// a loop that did not run yet stalls from the moment it is started
+	g.protocol.lastPeriod = time.Now()
+	g.protocol.Unlock()
+
+	go func() {
+		for !g.Stopped() {
+			time.Sleep(g.minProtocolPeriod)
+			g.checkWatchdog(time.Now())
+		}
+	}()
+}
+
+// checkWatchdog fires the watchdog if the protocol period loop has not run
+// for longer than the stall threshold at the given time.
+func (g *gossip) checkWatchdog(now time.Time) {
+	if g.Stopped() {
+		return
+	}
+
+	threshold := g.stallThreshold()
+
+	g.protocol.Lock()
+	stalled := now.Sub(g.protocol.lastPeriod)
+	if stalled < threshold {
+		g.watchdog.fired = false
+		g.protocol.Unlock()
+		return
+	}
+	if g.watchdog.fired {
+		g.protocol.Unlock()
+		return
+	}
+	g.watchdog.fired = true
+	g.protocol.Unlock()
+
+	g.logger.WithFields(log.Fields{
+		"stalled": stalled,
+		"periods": g.watchdog.periods,
+	}).Error("protocol period loop stalled")
+
+	g.node.emit(ProtocolStalledEvent{Duration: stalled})
+
+	if g.watchdog.callback != nil {
+		g.watchdog.callback(stalled)
+	}
+}
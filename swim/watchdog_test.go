@@ -0,0 +1,70 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package swim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gl-works/ringpop-go/events/test/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdog(t *testing.T) {
+	tnode := newChannelNode(t)
+	defer tnode.Destroy()
+
+	var stalls []time.Duration
+	g := tnode.node.gossip
+	g.watchdog.periods = 3
+	g.watchdog.callback = func(stalled time.Duration) {
+		stalls = append(stalls, stalled)
+	}
+
+	now := time.Now()
+	g.protocol.lastPeriod = now
+	threshold := g.stallThreshold()
+
+	listener := &mocks.EventListener{}
+	listener.On("HandleEvent", ProtocolStalledEvent{Duration: threshold}).Return()
+	tnode.node.RegisterListener(listener)
+
+	g.checkWatchdog(now.Add(threshold))
+	assert.Empty(t, stalls, "expected watchdog not to fire while gossip is stopped")
+
+	g.SetStopped(false)
+	defer g.SetStopped(true)
+
+	g.checkWatchdog(now.Add(threshold - time.Millisecond))
+	assert.Empty(t, stalls, "expected watchdog not to fire within the threshold")
+
+	g.checkWatchdog(now.Add(threshold))
+	g.checkWatchdog(now.Add(2 * threshold))
+	assert.Equal(t, []time.Duration{threshold}, stalls, "expected watchdog to fire once per stall")
+
+	// the loop ran again, so a new stall fires again
+	g.protocol.lastPeriod = now.Add(2 * threshold)
+	g.checkWatchdog(now.Add(2 * threshold))
+	g.checkWatchdog(now.Add(3 * threshold))
+	assert.Len(t, stalls, 2)
+
+	listener.AssertNumberOfCalls(t, "HandleEvent", 2)
+}
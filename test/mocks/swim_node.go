@@ -1,7 +1,10 @@
 package mocks
 
+import "time"
+
 import "github.com/gl-works/ringpop-go/swim"
 import "github.com/stretchr/testify/mock"
+import "github.com/uber/tchannel-go/json"
 
 type SwimNode struct {
 	mock.Mock
@@ -111,3 +114,538 @@ func (_m *SwimNode) Ready() bool {
 func (_m *SwimNode) RegisterListener(l swim.EventListener) {
 	_m.Called(l)
 }
+
+// SetPushback provides a mock function with given fields: retryAfter, load
+func (_m *SwimNode) SetPushback(retryAfter time.Duration, load float64) {
+	_m.Called(retryAfter, load)
+}
+
+// Snapshot provides a mock function with given fields:
+func (_m *SwimNode) Snapshot() *swim.Snapshot {
+	ret := _m.Called()
+
+	var r0 *swim.Snapshot
+	if rf, ok := ret.Get(0).(func() *swim.Snapshot); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swim.Snapshot)
+		}
+	}
+
+	return r0
+}
+
+// Restore provides a mock function with given fields: snapshot
+func (_m *SwimNode) Restore(snapshot *swim.Snapshot) error {
+	ret := _m.Called(snapshot)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*swim.Snapshot) error); ok {
+		r0 = rf(snapshot)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetDegraded provides a mock function with given fields: degraded
+func (_m *SwimNode) SetDegraded(degraded bool) error {
+	ret := _m.Called(degraded)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = rf(degraded)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Annotate provides a mock function with given fields: annotations
+func (_m *SwimNode) Annotate(annotations map[string]string) error {
+	ret := _m.Called(annotations)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(map[string]string) error); ok {
+		r0 = rf(annotations)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Annotations provides a mock function with given fields: address
+func (_m *SwimNode) Annotations(address string) (map[string]string, bool) {
+	ret := _m.Called(address)
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func(string) map[string]string); ok {
+		r0 = rf(address)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(address)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// Labels provides a mock function with given fields:
+func (_m *SwimNode) Labels() *swim.Labels {
+	ret := _m.Called()
+
+	var r0 *swim.Labels
+	if rf, ok := ret.Get(0).(func() *swim.Labels); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swim.Labels)
+		}
+	}
+
+	return r0
+}
+
+// MemberLabels provides a mock function with given fields: address
+func (_m *SwimNode) MemberLabels(address string) (map[string]string, bool) {
+	ret := _m.Called(address)
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func(string) map[string]string); ok {
+		r0 = rf(address)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(address)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// JoinedAt provides a mock function with given fields: address
+func (_m *SwimNode) JoinedAt(address string) (time.Time, bool) {
+	ret := _m.Called(address)
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func(string) time.Time); ok {
+		r0 = rf(address)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(address)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// MemberUptime provides a mock function with given fields: address
+func (_m *SwimNode) MemberUptime(address string) (time.Duration, bool) {
+	ret := _m.Called(address)
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func(string) time.Duration); ok {
+		r0 = rf(address)
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(address)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// Leave provides a mock function with given fields:
+func (_m *SwimNode) Leave() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// LeaveNotify provides a mock function with given fields: fraction
+func (_m *SwimNode) LeaveNotify(fraction float64) (int, error) {
+	ret := _m.Called(fraction)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(float64) int); ok {
+		r0 = rf(fraction)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(float64) error); ok {
+		r1 = rf(fraction)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Rejoin provides a mock function with given fields:
+func (_m *SwimNode) Rejoin() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Blacklist provides a mock function with given fields: address, ttl
+func (_m *SwimNode) Blacklist(address string, ttl time.Duration) error {
+	ret := _m.Called(address, ttl)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, time.Duration) error); ok {
+		r0 = rf(address, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Unblacklist provides a mock function with given fields: address
+func (_m *SwimNode) Unblacklist(address string) bool {
+	ret := _m.Called(address)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(address)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// Blacklisted provides a mock function with given fields: address
+func (_m *SwimNode) Blacklisted(address string) bool {
+	ret := _m.Called(address)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(address)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// BlacklistedMembers provides a mock function with given fields:
+func (_m *SwimNode) BlacklistedMembers() map[string]time.Time {
+	ret := _m.Called()
+
+	var r0 map[string]time.Time
+	if rf, ok := ret.Get(0).(func() map[string]time.Time); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]time.Time)
+		}
+	}
+
+	return r0
+}
+
+// BulkUpdate provides a mock function with given fields: updates
+func (_m *SwimNode) BulkUpdate(updates []swim.MemberUpdate) ([]swim.Change, error) {
+	ret := _m.Called(updates)
+
+	var r0 []swim.Change
+	if rf, ok := ret.Get(0).(func([]swim.MemberUpdate) []swim.Change); ok {
+		r0 = rf(updates)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swim.Change)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]swim.MemberUpdate) error); ok {
+		r1 = rf(updates)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetRingFingerprint provides a mock function with given fields: fingerprint
+func (_m *SwimNode) SetRingFingerprint(fingerprint string) {
+	_m.Called(fingerprint)
+}
+
+// DisableSubsystem provides a mock function with given fields: subsystem
+func (_m *SwimNode) DisableSubsystem(subsystem swim.Subsystem) error {
+	ret := _m.Called(subsystem)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(swim.Subsystem) error); ok {
+		r0 = rf(subsystem)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnableSubsystem provides a mock function with given fields: subsystem
+func (_m *SwimNode) EnableSubsystem(subsystem swim.Subsystem) error {
+	ret := _m.Called(subsystem)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(swim.Subsystem) error); ok {
+		r0 = rf(subsystem)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Subsystems provides a mock function with given fields:
+func (_m *SwimNode) Subsystems() map[swim.Subsystem]bool {
+	ret := _m.Called()
+
+	var r0 map[swim.Subsystem]bool
+	if rf, ok := ret.Get(0).(func() map[swim.Subsystem]bool); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[swim.Subsystem]bool)
+		}
+	}
+
+	return r0
+}
+
+// Left provides a mock function with given fields:
+func (_m *SwimNode) Left() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// SetMaintenance provides a mock function with given fields: until
+func (_m *SwimNode) SetMaintenance(until time.Time) error {
+	ret := _m.Called(until)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(time.Time) error); ok {
+		r0 = rf(until)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// InMaintenance provides a mock function with given fields: address
+func (_m *SwimNode) InMaintenance(address string) bool {
+	ret := _m.Called(address)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(address)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// SetRamp provides a mock function with given fields: ramp
+func (_m *SwimNode) SetRamp(ramp int) error {
+	ret := _m.Called(ramp)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(ramp)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReportTransportFailure provides a mock function with given fields: address
+func (_m *SwimNode) ReportTransportFailure(address string) {
+	_m.Called(address)
+}
+
+// DialAddress provides a mock function with given fields: address
+func (_m *SwimNode) DialAddress(address string) string {
+	ret := _m.Called(address)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string) string); ok {
+		r0 = rf(address)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// PeerFeatures provides a mock function with given fields: address
+func (_m *SwimNode) PeerFeatures(address string) (swim.Features, bool) {
+	ret := _m.Called(address)
+
+	var r0 swim.Features
+	if rf, ok := ret.Get(0).(func(string) swim.Features); ok {
+		r0 = rf(address)
+	} else {
+		r0 = ret.Get(0).(swim.Features)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(address)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// DetectorStats provides a mock function with given fields:
+func (_m *SwimNode) DetectorStats() swim.DetectorStats {
+	ret := _m.Called()
+
+	var r0 swim.DetectorStats
+	if rf, ok := ret.Get(0).(func() swim.DetectorStats); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(swim.DetectorStats)
+	}
+
+	return r0
+}
+
+// ClockSkew provides a mock function with given fields: address
+func (_m *SwimNode) ClockSkew(address string) (swim.ClockSkew, bool) {
+	ret := _m.Called(address)
+
+	var r0 swim.ClockSkew
+	if rf, ok := ret.Get(0).(func(string) swim.ClockSkew); ok {
+		r0 = rf(address)
+	} else {
+		r0 = ret.Get(0).(swim.ClockSkew)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(address)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// ClockSkews provides a mock function with given fields:
+func (_m *SwimNode) ClockSkews() map[string]swim.ClockSkew {
+	ret := _m.Called()
+
+	var r0 map[string]swim.ClockSkew
+	if rf, ok := ret.Get(0).(func() map[string]swim.ClockSkew); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]swim.ClockSkew)
+		}
+	}
+
+	return r0
+}
+
+// AuthorizeAdmin provides a mock function with given fields: ctx, endpoint, required
+func (_m *SwimNode) AuthorizeAdmin(ctx json.Context, endpoint string, required swim.AdminRole) error {
+	ret := _m.Called(ctx, endpoint, required)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(json.Context, string, swim.AdminRole) error); ok {
+		r0 = rf(ctx, endpoint, required)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Checksum provides a mock function with given fields:
+func (_m *SwimNode) Checksum() uint32 {
+	ret := _m.Called()
+
+	var r0 uint32
+	if rf, ok := ret.Get(0).(func() uint32); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint32)
+	}
+
+	return r0
+}
+
+// HasChanges provides a mock function with given fields:
+func (_m *SwimNode) HasChanges() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/swim"
+)
+
+// DefaultTimelineSize is the number of entries the event timeline keeps when
+// the EventTimeline option is not used.
+const DefaultTimelineSize = 256
+
+// A TimelineEntry is a significant event in the event timeline of a Ringpop
+// instance, see Timeline.
+type TimelineEntry struct {
+	Time    time.Time   `json:"time"`
+	Type    string      `json:"type"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// timeline is a ring buffer of the last timeline entries.
+type timeline struct {
+	entries []TimelineEntry
+	next    int
+	full    bool
+	sync.Mutex
+}
+
+// add records an entry, overwriting the oldest entry when the timeline is
+// full.
+func (t *timeline) add(entry TimelineEntry) {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.entries) == 0 {
+		return
+	}
+
+	t.entries[t.next] = entry
+	t.next = (t.next + 1) % len(t.entries)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// since returns the entries recorded at or after the time, oldest first.
+func (t *timeline) since(from time.Time) []TimelineEntry {
+	t.Lock()
+	defer t.Unlock()
+
+	ordered := t.entries[:t.next]
+	if t.full {
+		ordered = append(append([]TimelineEntry(nil), t.entries[t.next:]...), ordered...)
+	}
+
+	entries := []TimelineEntry{}
+	for _, entry := range ordered {
+		if !entry.Time.Before(from) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Timeline returns the significant events of this Ringpop instance, such as
+// state changes, bootstrap, membership and ring changes, joins and partitions,
+// that happened in the given window before now, oldest first. A window of zero
+// returns all events the timeline holds. The timeline is bounded, see
+// EventTimeline.
+func (rp *Ringpop) Timeline(window time.Duration) []TimelineEntry {
+	var from time.Time
+	if window > 0 {
+		from = rp.clock.Now().Add(-window)
+	}
+	return rp.timeline.since(from)
+}
+
+// recordTimeline adds an entry to the timeline.
+func (rp *Ringpop) recordTimeline(typ string, details interface{}) {
+	rp.timeline.add(TimelineEntry{
+		Time:    rp.clock.Now(),
+		Type:    typ,
+		Details: details,
+	})
+}
+
+// recordTimelineEvent adds the event to the timeline if it is significant.
+func (rp *Ringpop) recordTimelineEvent(event events.Event) {
+	switch event := event.(type) {
+	case swim.MemberlistChangesAppliedEvent:
+		statuses := make(map[string]string, len(event.Changes))
+		for _, change := range event.Changes {
+			statuses[change.Address] = change.Status
+		}
+		rp.recordTimeline("membership.changed", statuses)
+
+	case events.RingChangedEvent:
+		rp.recordTimeline("ring.changed", event)
+
+	case swim.JoinCompleteEvent:
+		rp.recordTimeline("join.complete", event)
+
+	case swim.JoinFailedEvent:
+		rp.recordTimeline("join.failed", map[string]string{
+			"reason": string(event.Reason),
+			"error":  errorString(event.Error),
+		})
+
+	case swim.FullSyncEvent:
+		rp.recordTimeline("full-sync", event)
+
+	case swim.SuspectTTLExpiredEvent:
+		rp.recordTimeline("suspect-ttl.expired", event)
+
+	case swim.FaultyVetoedEvent:
+		rp.recordTimeline("faulty.vetoed", event)
+
+	case swim.MaintenanceDeferredEvent:
+		rp.recordTimeline("maintenance.deferred", event)
+
+	case swim.DuplicateAddressEvent:
+		rp.recordTimeline("duplicate-address", event)
+
+	case swim.DuplicateIdentityEvent:
+		rp.recordTimeline("duplicate-identity", event)
+
+	case swim.ChecksumRemediationEvent:
+		rp.recordTimeline("remediation."+string(event.Action), event)
+
+	case swim.ChecksumConvergedEvent:
+		rp.recordTimeline("remediation.converged", event)
+
+	case swim.MemberTombstonedEvent:
+		rp.recordTimeline("reaper.tombstoned", event)
+
+	case swim.MemberReapedEvent:
+		rp.recordTimeline("reaper.reaped", event)
+
+	case swim.AntiEntropySyncEvent:
+		// only syncs that merged divergent memberships are significant
+		if event.Pulled > 0 || event.Pushed > 0 {
+			rp.recordTimeline("anti-entropy.merged", event)
+		}
+
+	case swim.ProtocolStalledEvent:
+		rp.recordTimeline("watchdog.stalled", event)
+
+	case swim.PartitionStartedEvent:
+		rp.recordTimeline("partition.started", event)
+
+	case swim.PartitionEndedEvent:
+		rp.recordTimeline("partition.ended", nil)
+
+	case swim.PartitionDetectedEvent:
+		rp.recordTimeline("partition.detected", event)
+
+	case swim.PartitionHealedEvent:
+		rp.recordTimeline("partition.healed", event)
+
+	case swim.MemberBlacklistedEvent:
+		rp.recordTimeline("blacklist.added", event)
+
+	case swim.MemberUnblacklistedEvent:
+		rp.recordTimeline("blacklist.removed", event)
+
+	case swim.BulkUpdateEvent:
+		rp.recordTimeline("membership.bulk-update", event)
+
+	case swim.SubsystemToggledEvent:
+		rp.recordTimeline("subsystem.toggled", event)
+
+	case swim.JoinStormDetectedEvent:
+		rp.recordTimeline("join.storm", event)
+
+	case swim.ClockSkewExceededEvent:
+		rp.recordTimeline("clock-skew.exceeded", event)
+
+	case swim.ChecksumVersionChangedEvent:
+		rp.recordTimeline("membership.checksum-version", event)
+
+	case swim.AnnotationsChangedEvent:
+		rp.recordTimeline("annotations.changed", event)
+
+	case swim.LabelsChangedEvent:
+		rp.recordTimeline("labels.changed", event)
+
+	case swim.LabelsRestoredEvent:
+		rp.recordTimeline("labels.restored", event)
+
+	case swim.ManifestImportedEvent:
+		rp.recordTimeline("bootstrap.manifest", event)
+
+	case events.ZoneUnreachableEvent:
+		rp.recordTimeline("zone.unreachable", event)
+
+	case events.ZoneReachableEvent:
+		rp.recordTimeline("zone.reachable", event)
+
+	case events.RoutingOverridesReloadedEvent:
+		rp.recordTimeline("overrides.reloaded", event)
+
+	case events.RoutingOverridesFailedEvent:
+		rp.recordTimeline("overrides.failed", event)
+
+	case events.MeshReadinessChangedEvent:
+		rp.recordTimeline("mesh.readiness", event)
+
+	case events.KeyLockLostEvent:
+		rp.recordTimeline("keylock.lost", event)
+
+	case events.MemberWeightChangedEvent:
+		rp.recordTimeline("member.weight-changed", event)
+
+	case events.SelfEvictedEvent:
+		rp.recordTimeline("self-evicted", event)
+
+	case events.RingCutoverEvent:
+		rp.recordTimeline("ring.cutover", event)
+	}
+}
+
+// errorString returns the message of the error, or an empty string if it is
+// nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
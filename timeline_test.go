@@ -0,0 +1,84 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/tchannel-go"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/swim"
+)
+
+func TestTimelineIsBounded(t *testing.T) {
+	tl := &timeline{entries: make([]TimelineEntry, 3)}
+	start := time.Unix(0, 0)
+	for i := 0; i < 5; i++ {
+		tl.add(TimelineEntry{Time: start.Add(time.Duration(i) * time.Second), Type: "event"})
+	}
+
+	entries := tl.since(time.Time{})
+	if assert.Len(t, entries, 3, "expected oldest entries to be overwritten") {
+		assert.Equal(t, start.Add(2*time.Second), entries[0].Time, "expected oldest entry first")
+		assert.Equal(t, start.Add(4*time.Second), entries[2].Time)
+	}
+
+	assert.Len(t, tl.since(start.Add(3*time.Second)), 2)
+}
+
+func TestTimelineDisabled(t *testing.T) {
+	tl := &timeline{}
+	tl.add(TimelineEntry{Type: "event"})
+	assert.Empty(t, tl.since(time.Time{}))
+}
+
+func TestRingpopTimeline(t *testing.T) {
+	ch, err := tchannel.NewChannel("test", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ch.Close()
+
+	mockClock := clock.NewMock()
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(mockClock), EventTimeline(10))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rp.recordTimelineEvent(swim.PingSendEvent{})
+	rp.recordTimelineEvent(events.RingChangedEvent{ServersAdded: []string{"127.0.0.1:3002"}})
+	mockClock.Add(time.Hour)
+	rp.recordTimelineEvent(swim.MemberlistChangesAppliedEvent{Changes: []swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Faulty},
+	}})
+
+	entries := rp.Timeline(0)
+	if assert.Len(t, entries, 2, "expected insignificant events to be left out") {
+		assert.Equal(t, "ring.changed", entries[0].Type)
+		assert.Equal(t, "membership.changed", entries[1].Type)
+		assert.Equal(t, map[string]string{"127.0.0.1:3002": swim.Faulty}, entries[1].Details)
+	}
+
+	assert.Len(t, rp.Timeline(time.Minute), 1, "expected window to select recent entries")
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"errors"
+
+	"github.com/gl-works/ringpop-go/swim"
+	"golang.org/x/net/context"
+)
+
+// Protocol returns the handler a Transport passes the messages of the
+// membership protocol to that this instance receives, see the Transport
+// option. Messages received before Bootstrap is called are rejected with
+// ErrNotBootstrapped.
+func (rp *Ringpop) Protocol() swim.ProtocolHandler {
+	return protocolHandler{rp}
+}
+
+// protocolHandler passes the messages of the protocol to the swim node of a
+// Ringpop instance once it has been initialized.
+type protocolHandler struct {
+	rp *Ringpop
+}
+
+func (h protocolHandler) node() (swim.ProtocolHandler, error) {
+	if h.rp.getState() < initialized {
+		return nil, ErrNotBootstrapped
+	}
+	node, ok := h.rp.node.(swim.ProtocolHandler)
+	if !ok {
+		return nil, errors.New("swim node does not handle protocol messages")
+	}
+	return node, nil
+}
+
+func (h protocolHandler) HandlePing(ctx context.Context, req []byte) ([]byte, error) {
+	node, err := h.node()
+	if err != nil {
+		return nil, err
+	}
+	return node.HandlePing(ctx, req)
+}
+
+func (h protocolHandler) HandlePingReq(ctx context.Context, req []byte) ([]byte, error) {
+	node, err := h.node()
+	if err != nil {
+		return nil, err
+	}
+	return node.HandlePingReq(ctx, req)
+}
+
+func (h protocolHandler) HandleJoin(ctx context.Context, req []byte) ([]byte, error) {
+	node, err := h.node()
+	if err != nil {
+		return nil, err
+	}
+	return node.HandleJoin(ctx, req)
+}
+
+func (h protocolHandler) HandleSync(ctx context.Context, req []byte) ([]byte, error) {
+	node, err := h.node()
+	if err != nil {
+		return nil, err
+	}
+	return node.HandleSync(ctx, req)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"sort"
+	"time"
+)
+
+// MemberUptime returns how long ago the member at address joined the cluster.
+// The join time is gossiped by the member itself; for members running a
+// version that does not gossip it, it is when they were first seen alive. Ok
+// is false if the member or its join time is not known.
+func (rp *Ringpop) MemberUptime(address string) (uptime time.Duration, ok bool) {
+	if !rp.Ready() {
+		return 0, false
+	}
+	return rp.node.MemberUptime(address)
+}
+
+// MembersByAge returns the reachable members, oldest first, e.g. to prefer
+// the oldest member when electing a coordinator. Members whose join time is
+// not known come last, and members that joined at the same time are sorted
+// by address so that all members agree on the order.
+func (rp *Ringpop) MembersByAge() ([]string, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+
+	members := rp.node.GetReachableMembers()
+	ages := make(membersByAge, 0, len(members))
+	for _, address := range members {
+		joinedAt, ok := rp.node.JoinedAt(address)
+		ages = append(ages, memberAge{address, joinedAt, ok})
+	}
+	sort.Sort(ages)
+
+	for i, member := range ages {
+		members[i] = member.address
+	}
+	return members, nil
+}
+
+// memberAge is a member with its join time.
+type memberAge struct {
+	address  string
+	joinedAt time.Time
+	known    bool
+}
+
+// membersByAge sorts members by join time, oldest first.
+type membersByAge []memberAge
+
+func (m membersByAge) Len() int      { return len(m) }
+func (m membersByAge) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m membersByAge) Less(i, j int) bool {
+	if m[i].known != m[j].known {
+		return m[i].known
+	}
+	if !m[i].joinedAt.Equal(m[j].joinedAt) {
+		return m[i].joinedAt.Before(m[j].joinedAt)
+	}
+	return m[i].address < m[j].address
+}
@@ -36,3 +36,15 @@ func (noopStatsReporter) RecordTimer(name string, tags bark.Tags, d time.Duratio
 func genStatsHostport(hostport string) string {
 	return strings.Replace(strings.Replace(hostport, ".", "_", -1), ":", "_", -1)
 }
+
+// genStatsEndpoint returns the endpoint as a segment of a stat key, such as
+// "Service__method" for "Service::method", without the leading slash of HTTP
+// and JSON endpoints.
+func genStatsEndpoint(endpoint string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(endpoint, "/"))
+}
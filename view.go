@@ -0,0 +1,117 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/gl-works/ringpop-go/hashring"
+	"github.com/gl-works/ringpop-go/swim"
+)
+
+// A View is a consistent snapshot of the membership of a Ringpop instance and
+// of the ring built from it. Computing replica sets on Ring and checking the
+// status of the replicas in Members cannot race with membership changes, as
+// both reflect the same changes.
+type View struct {
+	// Version is incremented every time membership changes are applied to
+	// the ring. Two views with the same version are identical.
+	Version uint64 `json:"version"`
+
+	// Members are all members with their status, sorted by address.
+	Members []swim.Change `json:"members"`
+
+	// Ring is a copy of the ring that is not affected by later changes.
+	Ring *hashring.HashRing `json:"-"`
+}
+
+// Member returns the member with the given address.
+func (v *View) Member(address string) (member swim.Change, ok bool) {
+	i := sort.Search(len(v.Members), func(i int) bool {
+		return v.Members[i].Address >= address
+	})
+	if i < len(v.Members) && v.Members[i].Address == address {
+		return v.Members[i], true
+	}
+	return swim.Change{}, false
+}
+
+// viewState contains the membership as applied to the ring and its version.
+// It is locked while changes are applied to the ring.
+type viewState struct {
+	version uint64
+	members map[string]swim.Change
+	sync.RWMutex
+}
+
+// View returns a consistent snapshot of the membership and the ring. The ring
+// is copied, which takes time linear in the number of replica points, so
+// callers that only need lookups should use Lookup and LookupN instead.
+func (rp *Ringpop) View() (*View, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+
+	rp.view.RLock()
+	defer rp.view.RUnlock()
+
+	view := &View{
+		Version: rp.view.version,
+		Members: make([]swim.Change, 0, len(rp.view.members)),
+		Ring:    rp.ring.Copy(),
+	}
+	for _, member := range rp.view.members {
+		view.Members = append(view.Members, member)
+	}
+	sort.Sort(changesByAddress(view.Members))
+
+	return view, nil
+}
+
+// updateViewNoLock records the changes in the membership of the view. It must
+// be called while the view is locked.
+func (rp *Ringpop) updateViewNoLock(changes []swim.Change) {
+	if rp.view.members == nil {
+		rp.view.members = make(map[string]swim.Change)
+	}
+	for _, change := range changes {
+		rp.view.members[change.Address] = swim.Change{
+			Address:     change.Address,
+			Status:      change.Status,
+			Incarnation: change.Incarnation,
+			Health:      change.Health,
+			Ramp:        change.Ramp,
+			Addresses:   change.Addresses,
+			Annotations: change.Annotations,
+			Labels:      change.Labels,
+			JoinedAt:    change.JoinedAt,
+		}
+	}
+	rp.view.version++
+}
+
+// changesByAddress sorts changes by the address of the member.
+type changesByAddress []swim.Change
+
+func (c changesByAddress) Len() int           { return len(c) }
+func (c changesByAddress) Less(i, j int) bool { return c[i].Address < c[j].Address }
+func (c changesByAddress) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
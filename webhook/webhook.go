@@ -0,0 +1,322 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package webhook extends Ringpop functionality by notifying external systems
+// of ring and membership changes. A Notifier is registered as an event listener
+// on a Ringpop instance and POSTs a JSON summary of every change to the
+// configured URLs.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/logging"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/gl-works/ringpop-go/util"
+)
+
+// SignatureHeader is the HTTP header that carries the hex encoded HMAC-SHA256
+// signature of the request body when a secret is configured.
+const SignatureHeader = "X-Ringpop-Signature"
+
+const (
+	// RingChanged is the type of a summary sent when servers are added to
+	// or removed from the ring.
+	RingChanged = "ring.changed"
+
+	// MembershipChanged is the type of a summary sent when changes are
+	// applied to the membership list.
+	MembershipChanged = "membership.changed"
+)
+
+// ErrClosed is returned when a summary is sent on a closed Notifier.
+var ErrClosed = errors.New("webhook notifier is closed")
+
+// A Summary is the JSON body POSTed to webhook URLs.
+type Summary struct {
+	Type           string        `json:"type"`
+	Timestamp      int64         `json:"timestamp"`
+	ServersAdded   []string      `json:"serversAdded,omitempty"`
+	ServersRemoved []string      `json:"serversRemoved,omitempty"`
+	ServersUpdated []string      `json:"serversUpdated,omitempty"`
+	Changes        []swim.Change `json:"changes,omitempty"`
+	Checksum       uint32        `json:"checksum,omitempty"`
+	NumMembers     int           `json:"numMembers,omitempty"`
+}
+
+// Options for a Notifier.
+type Options struct {
+	// Secret is used to sign request bodies. Requests are not signed if no
+	// secret is set.
+	Secret []byte
+
+	// MaxRetries is the number of times a failed delivery is retried,
+	// defaults to 3. A negative number disables retries.
+	MaxRetries int
+
+	// RetryBackoff is the time waited before the first retry, doubled on
+	// every subsequent retry.
+	RetryBackoff time.Duration
+
+	// Timeout is the timeout of a single POST request.
+	Timeout time.Duration
+
+	// QueueSize is the number of summaries that can be waiting for delivery.
+	// Summaries are dropped when the queue is full.
+	QueueSize int
+
+	// Client is the HTTP client used to POST summaries.
+	Client *http.Client
+}
+
+func defaultOptions() *Options {
+	return &Options{
+		MaxRetries:   3,
+		RetryBackoff: 100 * time.Millisecond,
+		Timeout:      3 * time.Second,
+		QueueSize:    64,
+	}
+}
+
+func mergeDefaultOptions(opts *Options, def *Options) *Options {
+	if opts == nil {
+		return def
+	}
+
+	var merged Options
+
+	merged.Secret = opts.Secret
+	merged.MaxRetries = util.SelectInt(opts.MaxRetries, def.MaxRetries)
+	merged.RetryBackoff = util.SelectDuration(opts.RetryBackoff, def.RetryBackoff)
+	merged.Timeout = util.SelectDuration(opts.Timeout, def.Timeout)
+	merged.QueueSize = util.SelectInt(opts.QueueSize, def.QueueSize)
+	merged.Client = opts.Client
+
+	return &merged
+}
+
+// A Notifier POSTs summaries of ring and membership changes to webhook URLs.
+// Summaries are delivered asynchronously and in order; failed deliveries are
+// retried with exponential backoff.
+type Notifier struct {
+	urls   []string
+	opts   *Options
+	client *http.Client
+	logger log.Logger
+
+	queue chan Summary
+	wg    sync.WaitGroup
+
+	// closed is closed by Close to abort the backoff of failed deliveries.
+	closed chan struct{}
+
+	state struct {
+		closed bool
+		sync.RWMutex
+	}
+}
+
+// NewNotifier returns a Notifier that sends summaries to the given URLs. The
+// Notifier starts delivering immediately and should be registered as a
+// listener with Ringpop.RegisterListener.
+func NewNotifier(urls []string, opts *Options) (*Notifier, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("at least one webhook url is required")
+	}
+
+	opts = mergeDefaultOptions(opts, defaultOptions())
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: opts.Timeout}
+	}
+
+	n := &Notifier{
+		urls:   urls,
+		opts:   opts,
+		client: client,
+		logger: logging.Logger("webhook"),
+		queue:  make(chan Summary, opts.QueueSize),
+		closed: make(chan struct{}),
+	}
+
+	n.wg.Add(1)
+	go n.deliverLoop()
+
+	return n, nil
+}
+
+// HandleEvent is used to satisfy the events.EventListener interface.
+func (n *Notifier) HandleEvent(event events.Event) {
+	switch event := event.(type) {
+	case events.RingChangedEvent:
+		var updated []string
+		for _, update := range event.ServersUpdated {
+			updated = append(updated, update.Server)
+		}
+		n.Send(Summary{
+			Type:           RingChanged,
+			ServersAdded:   event.ServersAdded,
+			ServersRemoved: event.ServersRemoved,
+			ServersUpdated: updated,
+		})
+
+	case swim.MemberlistChangesAppliedEvent:
+		n.Send(Summary{
+			Type:       MembershipChanged,
+			Changes:    event.Changes,
+			Checksum:   event.NewChecksum,
+			NumMembers: event.NumMembers,
+		})
+	}
+}
+
+// Send queues a summary for delivery. The summary is dropped if the queue is
+// full.
+func (n *Notifier) Send(summary Summary) error {
+	n.state.RLock()
+	defer n.state.RUnlock()
+
+	if n.state.closed {
+		return ErrClosed
+	}
+
+	if summary.Timestamp == 0 {
+		summary.Timestamp = util.TimeNowMS()
+	}
+
+	select {
+	case n.queue <- summary:
+		return nil
+	default:
+		n.logger.WithField("type", summary.Type).Warn("webhook queue full, dropping summary")
+		return errors.New("webhook queue is full")
+	}
+}
+
+// Close stops the Notifier after the queued summaries have been delivered.
+// Failed deliveries are not retried once the Notifier is closed, so Close does
+// not wait for their backoff.
+func (n *Notifier) Close() {
+	n.state.Lock()
+	if n.state.closed {
+		n.state.Unlock()
+		return
+	}
+	n.state.closed = true
+	close(n.queue)
+	close(n.closed)
+	n.state.Unlock()
+
+	n.wg.Wait()
+}
+
+func (n *Notifier) deliverLoop() {
+	defer n.wg.Done()
+
+	for summary := range n.queue {
+		body, err := json.Marshal(summary)
+		if err != nil {
+			n.logger.WithField("error", err).Warn("unable to marshal webhook summary")
+			continue
+		}
+
+		for _, url := range n.urls {
+			n.deliver(url, body)
+		}
+	}
+}
+
+// deliver POSTs the body to the url, retrying on failure until the Notifier
+// is closed.
+func (n *Notifier) deliver(url string, body []byte) {
+	backoff := n.opts.RetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := n.post(url, body)
+		if err == nil {
+			return
+		}
+
+		if attempt >= n.opts.MaxRetries || !n.wait(backoff) {
+			n.logger.WithFields(log.Fields{
+				"url":      url,
+				"attempts": attempt + 1,
+				"error":    err,
+			}).Warn("webhook delivery failed")
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// wait waits for the backoff of a failed delivery. It returns false if the
+// Notifier is closed in the meantime.
+func (n *Notifier) wait(backoff time.Duration) bool {
+	select {
+	case <-time.After(backoff):
+		return true
+	case <-n.closed:
+		return false
+	}
+}
+
+func (n *Notifier) post(url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.opts.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(n.opts.Secret, body))
+	}
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of body using secret, as
+// sent in the SignatureHeader. Receivers can use it to verify summaries.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/swim"
+)
+
+type receiver struct {
+	sync.Mutex
+	failures   int
+	summaries  []Summary
+	signatures []string
+	bodies     [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	var summary Summary
+	json.Unmarshal(body, &summary)
+
+	r.summaries = append(r.summaries, summary)
+	r.signatures = append(r.signatures, req.Header.Get(SignatureHeader))
+	r.bodies = append(r.bodies, body)
+}
+
+// waitFor waits until the receiver has the given number of failures left and
+// summaries delivered.
+func (r *receiver) waitFor(t *testing.T, failures, summaries int) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		r.Lock()
+		done := r.failures == failures && len(r.summaries) == summaries
+		r.Unlock()
+
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("expected %d failures left and %d summaries delivered", failures, summaries)
+}
+
+func TestNewNotifierRequiresURLs(t *testing.T) {
+	_, err := NewNotifier(nil, nil)
+	assert.Error(t, err)
+}
+
+func TestNotifierDeliversEvents(t *testing.T) {
+	r := &receiver{}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	n, err := NewNotifier([]string{server.URL}, nil)
+	require.NoError(t, err)
+
+	n.HandleEvent(events.RingChangedEvent{ServersAdded: []string{"127.0.0.1:3001"}})
+	n.HandleEvent(swim.MemberlistChangesAppliedEvent{
+		Changes:     []swim.Change{{Address: "127.0.0.1:3001", Status: swim.Alive}},
+		NewChecksum: 42,
+		NumMembers:  2,
+	})
+	n.HandleEvent(events.LookupEvent{Key: "ignored"})
+	n.Close()
+
+	require.Len(t, r.summaries, 2, "expected ring and membership summaries")
+	assert.Equal(t, RingChanged, r.summaries[0].Type)
+	assert.Equal(t, []string{"127.0.0.1:3001"}, r.summaries[0].ServersAdded)
+	assert.NotZero(t, r.summaries[0].Timestamp)
+	assert.Equal(t, MembershipChanged, r.summaries[1].Type)
+	assert.Equal(t, uint32(42), r.summaries[1].Checksum)
+	assert.Equal(t, 2, r.summaries[1].NumMembers)
+	assert.Empty(t, r.signatures[0], "expected unsigned request without secret")
+}
+
+func TestNotifierSignsRequests(t *testing.T) {
+	r := &receiver{}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	secret := []byte("secret")
+	n, err := NewNotifier([]string{server.URL}, &Options{Secret: secret})
+	require.NoError(t, err)
+
+	n.Send(Summary{Type: RingChanged})
+	n.Close()
+
+	require.Len(t, r.summaries, 1)
+	assert.Equal(t, Sign(secret, r.bodies[0]), r.signatures[0])
+}
+
+func TestNotifierRetries(t *testing.T) {
+	r := &receiver{failures: 2}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	n, err := NewNotifier([]string{server.URL}, &Options{
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	n.Send(Summary{Type: RingChanged})
+	r.waitFor(t, 0, 1)
+	n.Close()
+
+	assert.Len(t, r.summaries, 1, "expected summary to be delivered after retries")
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	r := &receiver{failures: 3}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	n, err := NewNotifier([]string{server.URL}, &Options{
+		MaxRetries:   1,
+		RetryBackoff: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	n.Send(Summary{Type: RingChanged})
+	r.waitFor(t, 1, 0)
+	n.Close()
+
+	assert.Empty(t, r.summaries, "expected summary to be dropped after max retries")
+	assert.Equal(t, 1, r.failures, "expected exactly two attempts")
+}
+
+func TestNotifierNoRetries(t *testing.T) {
+	r := &receiver{failures: 2}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	n, err := NewNotifier([]string{server.URL}, &Options{
+		MaxRetries:   -1,
+		RetryBackoff: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	n.Send(Summary{Type: RingChanged})
+	r.waitFor(t, 1, 0)
+	n.Send(Summary{Type: RingChanged})
+	r.waitFor(t, 0, 0)
+	n.Close()
+
+	assert.Empty(t, r.summaries, "expected every summary to be attempted once")
+}
+
+func TestCloseAbortsRetries(t *testing.T) {
+	r := &receiver{failures: 1}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	n, err := NewNotifier([]string{server.URL}, &Options{
+		RetryBackoff: time.Hour,
+	})
+	require.NoError(t, err)
+
+	n.Send(Summary{Type: RingChanged})
+	r.waitFor(t, 0, 0)
+
+	closed := make(chan struct{})
+	go func() {
+		n.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected Close not to wait for the backoff of a failed delivery")
+	}
+	assert.Empty(t, r.summaries, "expected the failed delivery not to be retried")
+}
+
+func TestSendAfterClose(t *testing.T) {
+	n, err := NewNotifier([]string{"http://127.0.0.1:0"}, nil)
+	require.NoError(t, err)
+
+	n.Close()
+	n.Close()
+
+	assert.Equal(t, ErrClosed, n.Send(Summary{Type: RingChanged}))
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/gl-works/ringpop-go/events"
+	log "github.com/uber-common/bark"
+)
+
+// maxMemberWeight is the largest weight a member can declare, so that a
+// single member cannot take an unbounded number of replica points.
+const maxMemberWeight = 100
+
+// weightState contains the weights of the members whose weight is not 1. It
+// is locked along with the view.
+type weightState struct {
+	weights map[string]float64
+}
+
+// MemberWeight returns the weight the member at address is placed on the ring
+// with, which is 1 unless it declares another weight, see WeightLabel.
+func (rp *Ringpop) MemberWeight(address string) float64 {
+	rp.view.RLock()
+	defer rp.view.RUnlock()
+	return rp.weightNoLock(address)
+}
+
+// weightNoLock returns the weight of a member. The view must be locked.
+func (rp *Ringpop) weightNoLock(address string) float64 {
+	if weight, ok := rp.weights.weights[address]; ok {
+		return weight
+	}
+	return 1
+}
+
+// labelWeight returns the weight a member declares with its labels, or 1 if
+// it declares none or an invalid one.
+func (rp *Ringpop) labelWeight(address string, labels map[string]string) float64 {
+	if rp.config.WeightLabel == "" {
+		return 1
+	}
+
+	value, ok := labels[rp.config.WeightLabel]
+	if !ok {
+		return 1
+	}
+
+	weight, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(weight) || weight <= 0 || weight > maxMemberWeight {
+		rp.logger.WithFields(log.Fields{
+			"member": address,
+			"weight": value,
+		}).Warn("ignoring invalid member weight")
+		return 1
+	}
+	return weight
+}
+
+// updateWeightNoLock records the weight of a member, which is placed on the
+// ring with points replica points, or the default number if zero, and emits
+// an events.MemberWeightChangedEvent when it changed. The view must be locked.
+func (rp *Ringpop) updateWeightNoLock(address string, weight float64, points int) {
+	old := rp.weightNoLock(address)
+	if old == weight {
+		return
+	}
+	if points == 0 {
+		points = rp.configHashRing.ReplicaPoints
+	}
+
+	if weight == 1 {
+		delete(rp.weights.weights, address)
+	} else {
+		if rp.weights.weights == nil {
+			rp.weights.weights = make(map[string]float64)
+		}
+		rp.weights.weights[address] = weight
+	}
+
+	rp.logger.WithFields(log.Fields{
+		"member": address,
+		"weight": weight,
+		"points": points,
+	}).Info("member weight changed")
+	rp.HandleEvent(events.MemberWeightChangedEvent{
+		Member:    address,
+		OldWeight: old,
+		Weight:    weight,
+		Points:    points,
+	})
+}
+
+// memberPoints returns the number of replica points of a member with the
+// given ramp and weight, or zero for the default number of points.
+func (rp *Ringpop) memberPoints(ramp int, weight float64) int {
+	if ramp <= 0 && weight == 1 {
+		return 0
+	}
+
+	points := float64(rp.configHashRing.ReplicaPoints) * weight
+	if ramp > 0 {
+		points = points * float64(ramp) / 100
+	}
+	if points < 1 {
+		return 1
+	}
+	return int(points)
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/swim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+)
+
+// weightListener records the weight events emitted by Ringpop.
+type weightListener struct {
+	events []events.MemberWeightChangedEvent
+	sync.Mutex
+}
+
+func (l *weightListener) HandleEvent(event events.Event) {
+	if event, ok := event.(events.MemberWeightChangedEvent); ok {
+		l.Lock()
+		l.events = append(l.events, event)
+		l.Unlock()
+	}
+}
+
+// wait waits for a bit until n events are recorded, and returns the events.
+func (l *weightListener) wait(n int) []events.MemberWeightChangedEvent {
+	for i := 0; i < 100; i++ {
+		l.Lock()
+		recorded := l.events
+		l.Unlock()
+		if len(recorded) >= n {
+			return recorded
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	return l.events
+}
+
+func TestMemberWeights(t *testing.T) {
+	ch, err := tchannel.NewChannel("test", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	rp, err := New("test", Channel(ch), Identity("127.0.0.1:3001"), Clock(clock.NewMock()),
+		WeightLabel("weight"))
+	require.NoError(t, err)
+	require.NoError(t, createSingleNodeCluster(rp))
+	defer rp.Destroy()
+
+	listener := &weightListener{}
+	rp.RegisterListener(listener)
+	points := rp.configHashRing.ReplicaPoints
+
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive, Labels: map[string]string{"weight": "2"}},
+		{Address: "127.0.0.1:3003", Status: swim.Alive},
+	})
+	assert.Equal(t, 2*points, rp.ring.ServerPoints("127.0.0.1:3002"))
+	assert.Equal(t, points, rp.ring.ServerPoints("127.0.0.1:3003"))
+	assert.Equal(t, 2.0, rp.MemberWeight("127.0.0.1:3002"))
+	assert.Equal(t, 1.0, rp.MemberWeight("127.0.0.1:3003"))
+	assert.Equal(t, []events.MemberWeightChangedEvent{
+		{Member: "127.0.0.1:3002", OldWeight: 1, Weight: 2, Points: 2 * points},
+	}, listener.wait(1))
+
+	// a ramping member ramps in to its weighted points
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive, Ramp: 50, Labels: map[string]string{"weight": "2"}},
+	})
+	assert.Equal(t, points, rp.ring.ServerPoints("127.0.0.1:3002"))
+	assert.Len(t, listener.wait(2), 1, "expected no event without a weight change")
+
+	rp.handleChanges([]swim.Change{
+		{Address: "127.0.0.1:3002", Status: swim.Alive, Labels: map[string]string{"weight": "0.5"}},
+		{Address: "127.0.0.1:3003", Status: swim.Alive, Labels: map[string]string{"weight": "-1"}},
+	})
+	assert.Equal(t, points/2, rp.ring.ServerPoints("127.0.0.1:3002"))
+	assert.Equal(t, points, rp.ring.ServerPoints("127.0.0.1:3003"), "expected invalid weights to be ignored")
+	changed := listener.wait(2)
+	require.Len(t, changed, 2)
+	assert.Equal(t, 0.5, changed[1].Weight)
+
+	rp.handleChanges([]swim.Change{{Address: "127.0.0.1:3002", Status: swim.Faulty}})
+	assert.Equal(t, 1.0, rp.MemberWeight("127.0.0.1:3002"), "expected weights of removed members to be forgotten")
+}
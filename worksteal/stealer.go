@@ -0,0 +1,387 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package worksteal extends Ringpop functionality by letting idle members take
+// over work from overloaded ones. The ring defines the home owner of every
+// work item by its key. Idle members ask the home owner of a key for work
+// through a standard negotiation endpoint, and the owner hands out the work it
+// cannot keep up with as leases. A leased item that is not completed before
+// its lease expires, or that is released, goes back to the owner's queue.
+package worksteal
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	log "github.com/uber-common/bark"
+	"github.com/gl-works/ringpop-go/logging"
+	"github.com/gl-works/ringpop-go/shared"
+	"github.com/gl-works/ringpop-go/util"
+	"github.com/uber/tchannel-go/json"
+	"golang.org/x/net/context"
+)
+
+var (
+	// ErrLocalOwner is returned by Steal when the key is owned by the local
+	// member, which has no one to steal from.
+	ErrLocalOwner = errors.New("key is owned by the local member")
+
+	// ErrUnknownLease is returned when a lease is completed or released that
+	// the owner does not know, because it expired or belongs to another
+	// member.
+	ErrUnknownLease = errors.New("unknown or expired lease")
+)
+
+// A Sender is used to look up the home owner of keys.
+type Sender interface {
+	// Lookup should return a server address
+	Lookup(string) (string, error)
+
+	// WhoAmI should return the local address of the sender
+	WhoAmI() (string, error)
+}
+
+// An Item is a unit of work. The home owner of an item is the owner of its key.
+type Item struct {
+	// ID identifies the item among the items of its owner.
+	ID      string `json:"id"`
+	Key     string `json:"key"`
+	Payload []byte `json:"payload,omitempty"`
+}
+
+// A Queue holds the work items of the local member that are waiting to be
+// processed. It is implemented by the application and must be safe for
+// concurrent use.
+type Queue interface {
+	// Pending returns the number of items waiting to be processed.
+	Pending() int
+
+	// Take removes up to n items from the queue, to be leased to another
+	// member.
+	Take(n int) []Item
+
+	// Requeue adds items back to the queue whose lease expired or was
+	// released.
+	Requeue(items []Item)
+}
+
+// A Lease is a work item taken over from its owner. The item must be completed
+// with Complete before the lease expires, or it goes back to the queue of the
+// owner.
+type Lease struct {
+	Owner   string
+	Item    Item
+	Expires time.Time
+}
+
+// Options for a Stealer.
+type Options struct {
+	// MinBacklog is the number of pending items an owner keeps for itself.
+	// Only items beyond it are handed out. Defaults to 1.
+	MinBacklog int
+
+	// MaxSteal is the maximum number of items handed out per request.
+	// Defaults to 10.
+	MaxSteal int
+
+	// LeaseTTL is how long a member has to complete an item it took over.
+	// Defaults to one minute.
+	LeaseTTL time.Duration
+
+	// Timeout is the timeout of requests to owners. Defaults to one second.
+	Timeout time.Duration
+
+	// Clock measures lease expiry. Defaults to the system clock.
+	Clock clock.Clock
+}
+
+func defaultOptions() *Options {
+	return &Options{
+		MinBacklog: 1,
+		MaxSteal:   10,
+		LeaseTTL:   time.Minute,
+		Timeout:    time.Second,
+		Clock:      clock.New(),
+	}
+}
+
+func mergeDefaultOptions(opts *Options) *Options {
+	def := defaultOptions()
+	if opts == nil {
+		return def
+	}
+
+	merged := *opts
+	merged.MinBacklog = util.SelectInt(opts.MinBacklog, def.MinBacklog)
+	merged.MaxSteal = util.SelectInt(opts.MaxSteal, def.MaxSteal)
+	merged.LeaseTTL = util.SelectDuration(opts.LeaseTTL, def.LeaseTTL)
+	merged.Timeout = util.SelectDuration(opts.Timeout, def.Timeout)
+	if merged.Clock == nil {
+		merged.Clock = def.Clock
+	}
+
+	return &merged
+}
+
+// lease is a lease granted by the local member.
+type lease struct {
+	thief string
+	item  Item
+	timer *clock.Timer
+}
+
+// A Stealer takes over work from other members and hands out the work of the
+// local member through the /worksteal endpoints of its SubChannel.
+type Stealer struct {
+	sender  Sender
+	channel shared.SubChannel
+	queue   Queue
+	opts    *Options
+	logger  log.Logger
+
+	leases struct {
+		byID map[string]*lease
+		sync.Mutex
+	}
+}
+
+// NewStealer returns a new Stealer that hands out the items of the queue and
+// registers its endpoints on the given SubChannel. Members steal from each
+// other through SubChannels of the same service.
+func NewStealer(s Sender, channel shared.SubChannel, q Queue, opts *Options) (*Stealer, error) {
+	stealer := &Stealer{
+		sender:  s,
+		channel: channel,
+		queue:   q,
+		opts:    mergeDefaultOptions(opts),
+		logger:  logging.Logger("worksteal"),
+	}
+	stealer.leases.byID = make(map[string]*lease)
+
+	if identity, err := s.WhoAmI(); err == nil {
+		stealer.logger = stealer.logger.WithField("local", identity)
+	}
+
+	handlers := map[string]interface{}{
+		"/worksteal/steal":    stealer.stealHandler,
+		"/worksteal/complete": stealer.completeHandler,
+		"/worksteal/release":  stealer.releaseHandler,
+	}
+	if err := json.Register(channel, handlers, stealer.errorHandler); err != nil {
+		return nil, err
+	}
+
+	return stealer, nil
+}
+
+// Steal asks the home owner of key for up to max work items. It returns no
+// leases when the owner is not overloaded.
+func (s *Stealer) Steal(key string, max int) ([]Lease, error) {
+	owner, err := s.sender.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+
+	me, err := s.sender.WhoAmI()
+	if err != nil {
+		return nil, err
+	}
+	if owner == me {
+		return nil, ErrLocalOwner
+	}
+
+	return s.StealFrom(owner, max)
+}
+
+// StealFrom asks the member at owner for up to max work items.
+func (s *Stealer) StealFrom(owner string, max int) ([]Lease, error) {
+	me, err := s.sender.WhoAmI()
+	if err != nil {
+		return nil, err
+	}
+
+	var res stealResponse
+	if err := s.call(owner, "/worksteal/steal", &stealRequest{Thief: me, Max: max}, &res); err != nil {
+		return nil, err
+	}
+
+	expires := s.opts.Clock.Now().Add(time.Duration(res.TTLMs) * time.Millisecond)
+	leases := make([]Lease, 0, len(res.Items))
+	for _, item := range res.Items {
+		leases = append(leases, Lease{Owner: owner, Item: item, Expires: expires})
+	}
+
+	return leases, nil
+}
+
+// Complete tells the owner of the lease that its item was processed.
+func (s *Stealer) Complete(l Lease) error {
+	return s.settle(l, "/worksteal/complete")
+}
+
+// Release hands the item of the lease back to its owner without processing it.
+func (s *Stealer) Release(l Lease) error {
+	return s.settle(l, "/worksteal/release")
+}
+
+// Outstanding returns the number of leases the local member granted that are
+// neither completed, released nor expired.
+func (s *Stealer) Outstanding() int {
+	s.leases.Lock()
+	n := len(s.leases.byID)
+	s.leases.Unlock()
+	return n
+}
+
+func (s *Stealer) settle(l Lease, method string) error {
+	me, err := s.sender.WhoAmI()
+	if err != nil {
+		return err
+	}
+
+	var res settleResponse
+	return s.call(l.Owner, method, &settleRequest{Thief: me, ID: l.Item.ID}, &res)
+}
+
+func (s *Stealer) call(owner, method string, req, res interface{}) error {
+	ctx, cancel := shared.NewTChannelContext(s.opts.Timeout)
+	defer cancel()
+
+	peer := s.channel.Peers().GetOrAdd(owner)
+	return json.CallPeer(json.Wrap(ctx), peer, s.channel.ServiceName(), method, req, res)
+}
+
+//= = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = =
+//
+//	Handlers
+//
+//= = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = = =
+
+type stealRequest struct {
+	Thief string `json:"thief"`
+	Max   int    `json:"max"`
+}
+
+type stealResponse struct {
+	Items []Item `json:"items"`
+	TTLMs int64  `json:"ttlMs"`
+}
+
+type settleRequest struct {
+	Thief string `json:"thief"`
+	ID    string `json:"id"`
+}
+
+type settleResponse struct{}
+
+func (s *Stealer) stealHandler(ctx json.Context, req *stealRequest) (*stealResponse, error) {
+	n := s.queue.Pending() - s.opts.MinBacklog
+	if n > req.Max {
+		n = req.Max
+	}
+	if n > s.opts.MaxSteal {
+		n = s.opts.MaxSteal
+	}
+
+	res := &stealResponse{TTLMs: int64(s.opts.LeaseTTL / time.Millisecond)}
+	if n <= 0 {
+		return res, nil
+	}
+
+	res.Items = s.queue.Take(n)
+
+	s.leases.Lock()
+	for _, item := range res.Items {
+		s.grantNoLock(req.Thief, item)
+	}
+	s.leases.Unlock()
+
+	s.logger.WithFields(log.Fields{
+		"thief": req.Thief,
+		"items": len(res.Items),
+	}).Debug("work stolen")
+
+	return res, nil
+}
+
+// grantNoLock records a lease of the item to the thief, which expires after
+// the lease TTL.
+func (s *Stealer) grantNoLock(thief string, item Item) {
+	l := &lease{thief: thief, item: item}
+	l.timer = s.opts.Clock.AfterFunc(s.opts.LeaseTTL, func() {
+		if s.remove(l) {
+			s.logger.WithFields(log.Fields{
+				"thief": thief,
+				"item":  item.ID,
+			}).Info("work lease expired")
+			s.queue.Requeue([]Item{item})
+		}
+	})
+	s.leases.byID[item.ID] = l
+}
+
+// remove removes the lease if it is still outstanding.
+func (s *Stealer) remove(l *lease) bool {
+	s.leases.Lock()
+	defer s.leases.Unlock()
+
+	if s.leases.byID[l.item.ID] != l {
+		return false
+	}
+	delete(s.leases.byID, l.item.ID)
+	return true
+}
+
+// take removes and returns the outstanding lease of the item to the thief.
+func (s *Stealer) take(thief, id string) (*lease, error) {
+	s.leases.Lock()
+	l, ok := s.leases.byID[id]
+	if !ok || l.thief != thief {
+		s.leases.Unlock()
+		return nil, ErrUnknownLease
+	}
+	delete(s.leases.byID, id)
+	s.leases.Unlock()
+
+	l.timer.Stop()
+	return l, nil
+}
+
+func (s *Stealer) completeHandler(ctx json.Context, req *settleRequest) (*settleResponse, error) {
+	if _, err := s.take(req.Thief, req.ID); err != nil {
+		return nil, err
+	}
+	return &settleResponse{}, nil
+}
+
+func (s *Stealer) releaseHandler(ctx json.Context, req *settleRequest) (*settleResponse, error) {
+	l, err := s.take(req.Thief, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.queue.Requeue([]Item{l.item})
+	return &settleResponse{}, nil
+}
+
+func (s *Stealer) errorHandler(ctx context.Context, err error) {
+	s.logger.WithField("error", err).Debug("work steal request failed")
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package worksteal
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber/tchannel-go"
+)
+
+type fakeSender struct {
+	me     string
+	owners map[string]string
+}
+
+func (s *fakeSender) Lookup(key string) (string, error) { return s.owners[key], nil }
+func (s *fakeSender) WhoAmI() (string, error)           { return s.me, nil }
+
+type fakeQueue struct {
+	items []Item
+	sync.Mutex
+}
+
+func (q *fakeQueue) Pending() int {
+	q.Lock()
+	defer q.Unlock()
+	return len(q.items)
+}
+
+func (q *fakeQueue) Take(n int) []Item {
+	q.Lock()
+	defer q.Unlock()
+	taken := append([]Item(nil), q.items[:n]...)
+	q.items = q.items[n:]
+	return taken
+}
+
+func (q *fakeQueue) Requeue(items []Item) {
+	q.Lock()
+	q.items = append(q.items, items...)
+	q.Unlock()
+}
+
+type StealerTestSuite struct {
+	suite.Suite
+	clock                *clock.Mock
+	ownerCh, thiefCh     *tchannel.Channel
+	owner, thief         *Stealer
+	ownerQueue           *fakeQueue
+	ownerAddr, thiefAddr string
+}
+
+func (s *StealerTestSuite) SetupTest() {
+	var err error
+	s.clock = clock.NewMock()
+
+	s.ownerCh, err = tchannel.NewChannel("test", nil)
+	s.Require().NoError(err)
+	s.Require().NoError(s.ownerCh.ListenAndServe("127.0.0.1:0"))
+	s.thiefCh, err = tchannel.NewChannel("test", nil)
+	s.Require().NoError(err)
+	s.Require().NoError(s.thiefCh.ListenAndServe("127.0.0.1:0"))
+
+	s.ownerAddr = s.ownerCh.PeerInfo().HostPort
+	s.thiefAddr = s.thiefCh.PeerInfo().HostPort
+	owners := map[string]string{"busy": s.ownerAddr, "idle": s.thiefAddr}
+	opts := &Options{MinBacklog: 2, MaxSteal: 3, LeaseTTL: time.Minute, Clock: s.clock}
+
+	s.ownerQueue = &fakeQueue{}
+	for _, id := range []string{"a", "b", "c", "d", "e", "f"} {
+		s.ownerQueue.items = append(s.ownerQueue.items, Item{ID: id, Key: "busy"})
+	}
+
+	s.owner, err = NewStealer(&fakeSender{s.ownerAddr, owners},
+		s.ownerCh.GetSubChannel("worksteal"), s.ownerQueue, opts)
+	s.Require().NoError(err)
+	s.thief, err = NewStealer(&fakeSender{s.thiefAddr, owners},
+		s.thiefCh.GetSubChannel("worksteal"), &fakeQueue{}, opts)
+	s.Require().NoError(err)
+}
+
+func (s *StealerTestSuite) TearDownTest() {
+	s.ownerCh.Close()
+	s.thiefCh.Close()
+}
+
+func (s *StealerTestSuite) TestStealLeavesOwnerBacklog() {
+	leases, err := s.thief.Steal("busy", 10)
+	s.Require().NoError(err)
+	s.Len(leases, 3, "expected steal to be capped at MaxSteal")
+	s.Equal(s.ownerAddr, leases[0].Owner)
+	s.Equal(3, s.owner.Outstanding())
+
+	leases, err = s.thief.Steal("busy", 10)
+	s.Require().NoError(err)
+	s.Len(leases, 1, "expected owner to keep MinBacklog items")
+
+	leases, err = s.thief.Steal("busy", 10)
+	s.NoError(err)
+	s.Empty(leases, "expected no work from an owner that is not overloaded")
+}
+
+func (s *StealerTestSuite) TestStealFromLocalOwner() {
+	_, err := s.thief.Steal("idle", 1)
+	s.Equal(ErrLocalOwner, err)
+}
+
+func (s *StealerTestSuite) TestCompleteAndRelease() {
+	leases, err := s.thief.Steal("busy", 2)
+	s.Require().NoError(err)
+	s.Require().Len(leases, 2)
+
+	s.NoError(s.thief.Complete(leases[0]))
+	s.Error(s.thief.Complete(leases[0]), "expected completed lease to be unknown")
+
+	s.NoError(s.thief.Release(leases[1]))
+	s.Equal(0, s.owner.Outstanding())
+	s.Equal(5, s.ownerQueue.Pending(), "expected released item to be requeued")
+}
+
+func (s *StealerTestSuite) TestLeaseExpires() {
+	leases, err := s.thief.Steal("busy", 1)
+	s.Require().NoError(err)
+	s.Require().Len(leases, 1)
+	s.Equal(s.clock.Now().Add(time.Minute), leases[0].Expires)
+
+	s.clock.Add(time.Minute)
+	s.Equal(0, s.owner.Outstanding())
+	s.Equal(6, s.ownerQueue.Pending(), "expected expired item to be requeued")
+
+	s.Error(s.thief.Complete(leases[0]), "expected expired lease to be unknown")
+}
+
+func TestStealerTestSuite(t *testing.T) {
+	suite.Run(t, new(StealerTestSuite))
+}
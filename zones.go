@@ -0,0 +1,149 @@
+// Copyright (c) 2015 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"sort"
+
+	"github.com/gl-works/ringpop-go/events"
+	"github.com/gl-works/ringpop-go/swim"
+)
+
+// DefaultZoneAnnotation is the default annotation members declare their zone
+// with, see ZoneAnnotation.
+const DefaultZoneAnnotation = "zone"
+
+// A Zone is the membership of a zone, as declared by the members with the
+// zone annotation.
+type Zone struct {
+	// Name is the zone of the members. Members without the zone annotation
+	// are in the zone with an empty name.
+	Name string `json:"name"`
+
+	// Members are the members of the zone with their status, sorted by
+	// address.
+	Members []swim.Change `json:"members"`
+
+	// Statuses counts the members of the zone by status.
+	Statuses map[string]int `json:"statuses"`
+
+	// Reachable is the number of members that are alive or suspect, and
+	// ReachableFraction the fraction of the members that did not leave the
+	// cluster that are reachable.
+	Reachable         int     `json:"reachable"`
+	ReachableFraction float64 `json:"reachableFraction"`
+}
+
+// unreachable returns whether the zone has members that did not leave the
+// cluster, and none of them are reachable.
+func (z *Zone) unreachable() bool {
+	return z.Reachable == 0 && len(z.Members) > z.Statuses[swim.Leave]
+}
+
+// zoneState contains the zones that are unreachable. It is locked along with
+// the view.
+type zoneState struct {
+	unreachable map[string]bool
+}
+
+// Zones returns the membership grouped by zone, sorted by name, with the
+// number of members per status and the fraction of reachable members of each
+// zone. Members declare their zone with an annotation, see ZoneAnnotation.
+// When all members of a zone become unreachable, which indicates a failure of
+// the zone rather than of individual members, an events.ZoneUnreachableEvent
+// is emitted. The zones are also available through the /admin/zones endpoint.
+func (rp *Ringpop) Zones() ([]Zone, error) {
+	if !rp.Ready() {
+		return nil, rp.errNotReady()
+	}
+
+	rp.view.RLock()
+	defer rp.view.RUnlock()
+	return rp.zonesNoLock(), nil
+}
+
+// zonesNoLock groups the members of the view by zone. It must be called while
+// the view is locked.
+func (rp *Ringpop) zonesNoLock() []Zone {
+	byName := make(map[string]*Zone)
+	for _, member := range rp.view.members {
+		name := member.Annotations[rp.config.ZoneAnnotation]
+		zone, ok := byName[name]
+		if !ok {
+			zone = &Zone{Name: name, Statuses: make(map[string]int)}
+			byName[name] = zone
+		}
+
+		zone.Members = append(zone.Members, member)
+		zone.Statuses[member.Status]++
+		if member.Status == swim.Alive || member.Status == swim.Suspect {
+			zone.Reachable++
+		}
+	}
+
+	zones := make([]Zone, 0, len(byName))
+	for _, zone := range byName {
+		sort.Sort(changesByAddress(zone.Members))
+		if present := len(zone.Members) - zone.Statuses[swim.Leave]; present > 0 {
+			zone.ReachableFraction = float64(zone.Reachable) / float64(present)
+		}
+		zones = append(zones, *zone)
+	}
+	sort.Sort(zonesByName(zones))
+
+	return zones
+}
+
+// trackZonesNoLock emits events when all members of a zone become unreachable
+// and when the zone is reachable again. Members without a zone are not
+// tracked. It must be called while the view is locked.
+func (rp *Ringpop) trackZonesNoLock() {
+	for _, zone := range rp.zonesNoLock() {
+		if zone.Name == "" {
+			continue
+		}
+
+		unreachable := zone.unreachable()
+		if unreachable == rp.zones.unreachable[zone.Name] {
+			continue
+		}
+
+		if unreachable {
+			if rp.zones.unreachable == nil {
+				rp.zones.unreachable = make(map[string]bool)
+			}
+			rp.zones.unreachable[zone.Name] = true
+			rp.logger.WithField("zone", zone.Name).Warn("all members of zone are unreachable")
+			rp.HandleEvent(events.ZoneUnreachableEvent{Zone: zone.Name, Members: len(zone.Members)})
+		} else {
+			delete(rp.zones.unreachable, zone.Name)
+			rp.logger.WithField("zone", zone.Name).Info("zone is reachable again")
+			rp.HandleEvent(events.ZoneReachableEvent{Zone: zone.Name})
+		}
+	}
+}
+
+// zonesByName sorts zones by name.
+type zonesByName []Zone
+
+func (z zonesByName) Len() int           { return len(z) }
+func (z zonesByName) Less(i, j int) bool { return z[i].Name < z[j].Name }
+func (z zonesByName) Swap(i, j int)      { z[i], z[j] = z[j], z[i] }